@@ -0,0 +1,251 @@
+// Package client is a Go client of the example-db API. Every endpoint of the
+// users and webhooks resources has a typed method; list endpoints also come
+// as iterators that follow the pagination cursor.
+//
+//	c := client.New("http://localhost:8080", client.WithTenant("acme"))
+//	u, err := c.CreateUser(ctx, client.UserRequest{Name: "Ann", Email: "ann@example.com"})
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client calls the API at a base URL. It is safe for concurrent use.
+type Client struct {
+	baseURL string
+	http    *http.Client
+	header  http.Header
+	retry   RetryPolicy
+}
+
+// RetryPolicy controls how failed requests are retried. Requests the server
+// rejected with 429 are retried whatever their method, as they were not
+// processed; network errors and 502, 503 and 504 responses only for GET, PUT
+// and DELETE, which are idempotent.
+type RetryPolicy struct {
+	// MaxAttempts is the number of tries including the first; 1 disables
+	// retries
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry, doubled before each
+	// following one up to MaxBackoff. A Retry-After header takes precedence;
+	// one longer than MaxBackoff ends the retries.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy tries requests three times
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sends requests with hc instead of http.DefaultClient
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.http = hc
+	}
+}
+
+// WithRetry replaces DefaultRetryPolicy
+func WithRetry(p RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = p
+	}
+}
+
+// WithHeader sends a header with every request, such as Authorization
+func WithHeader(key, value string) Option {
+	return func(c *Client) {
+		c.header.Set(key, value)
+	}
+}
+
+// WithTenant scopes every request to a tenant with the X-Tenant-ID header
+func WithTenant(id string) Option {
+	return WithHeader("X-Tenant-ID", id)
+}
+
+// WithLanguage asks for error messages in a language with Accept-Language
+func WithLanguage(lang string) Option {
+	return WithHeader("Accept-Language", lang)
+}
+
+// New creates a Client of the API at baseURL, such as http://localhost:8080
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    http.DefaultClient,
+		header:  http.Header{},
+		retry:   DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Error is returned for responses with a 4xx or 5xx status
+type Error struct {
+	StatusCode int
+	// Message is the error the API returned
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("api: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// IsNotFound reports whether err is a 404 response
+func IsNotFound(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// IsConflict reports whether err is a 409 response, such as a taken email
+func IsConflict(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict
+}
+
+// IsResyncRequired reports whether err is a 410 response of Changes: the
+// since is no longer served and the users have to be downloaded again
+func IsResyncRequired(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusGone
+}
+
+// request describes a call; body is encoded as JSON unless it is a
+// *multipartBody
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	body   any
+}
+
+// do sends req and decodes the JSON response into out unless out is nil
+func (c *Client) do(ctx context.Context, req request, out any) (*http.Response, error) {
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return resp, nil
+}
+
+// send sends req, retrying per the RetryPolicy, and returns the first
+// response with a status below 400. The caller closes its body.
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	var (
+		body        []byte
+		contentType string
+	)
+	switch b := req.body.(type) {
+	case nil:
+	case *multipartBody:
+		body, contentType = b.data, b.contentType
+	default:
+		var err error
+		if body, err = json.Marshal(b); err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+		contentType = "application/json"
+	}
+
+	u := c.baseURL + req.path
+	if len(req.query) > 0 {
+		u += "?" + req.query.Encode()
+	}
+	idempotent := req.method == http.MethodGet || req.method == http.MethodPut || req.method == http.MethodDelete
+	backoff := c.retry.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		hreq, err := http.NewRequestWithContext(ctx, req.method, u, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range c.header {
+			hreq.Header[k] = v
+		}
+		if contentType != "" {
+			hreq.Header.Set("Content-Type", contentType)
+		}
+
+		resp, err := c.http.Do(hreq)
+		retry := false
+		switch {
+		case err != nil:
+			retry = idempotent && ctx.Err() == nil
+		case resp.StatusCode < http.StatusBadRequest:
+			return resp, nil
+		case resp.StatusCode == http.StatusTooManyRequests:
+			retry = true
+		case resp.StatusCode == http.StatusBadGateway,
+			resp.StatusCode == http.StatusServiceUnavailable,
+			resp.StatusCode == http.StatusGatewayTimeout:
+			retry = idempotent
+		}
+
+		if !retry || attempt >= c.retry.MaxAttempts {
+			if err != nil {
+				return nil, err
+			}
+			return nil, readError(resp)
+		}
+
+		wait := backoff
+		if resp != nil {
+			if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+				wait = time.Duration(s) * time.Second
+			}
+			// Give up rather than wait out a used up quota
+			if c.retry.MaxBackoff > 0 && wait > c.retry.MaxBackoff {
+				return nil, readError(resp)
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		if backoff *= 2; c.retry.MaxBackoff > 0 && backoff > c.retry.MaxBackoff {
+			backoff = c.retry.MaxBackoff
+		}
+	}
+}
+
+// readError turns an error response into an *Error and closes its body
+func readError(resp *http.Response) error {
+	defer resp.Body.Close()
+
+	var body struct {
+		Error string `json:"error"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if err := json.Unmarshal(data, &body); err != nil || body.Error == "" {
+		body.Error = strings.TrimSpace(string(data))
+	}
+	return &Error{StatusCode: resp.StatusCode, Message: body.Error}
+}
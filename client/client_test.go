@@ -0,0 +1,214 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var fastRetry = WithRetry(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+
+func TestRetry(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			_, _ = fmt.Fprint(w, `{"id":1,"name":"Ann"}`)
+		}
+	}))
+	defer srv.Close()
+
+	u, err := New(srv.URL, fastRetry).GetUser(context.Background(), "1", false)
+	require.NoError(t, err)
+	assert.Equal(t, "Ann", u.Name)
+	assert.EqualValues(t, 3, calls.Load())
+}
+
+func TestRetryGivesUpOnLongRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = fmt.Fprint(w, `{"error":"Daily quota exceeded"}`)
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL).GetUser(context.Background(), "1", false)
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+	assert.Equal(t, "Daily quota exceeded", apiErr.Message)
+	assert.EqualValues(t, 1, calls.Load())
+}
+
+func TestRetryOnlyIdempotentRequests(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = fmt.Fprint(w, `{"error":"Database is unavailable"}`)
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL, fastRetry).CreateUser(context.Background(), UserRequest{Name: "Ann"})
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	assert.Equal(t, "Database is unavailable", apiErr.Message)
+	assert.EqualValues(t, 1, calls.Load(), "a POST may have been processed")
+
+	calls.Store(0)
+	err = New(srv.URL, fastRetry).DeleteUser(context.Background(), "1")
+	assert.Error(t, err)
+	assert.EqualValues(t, 3, calls.Load())
+}
+
+func TestErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "acme", r.Header.Get("X-Tenant-ID"))
+		assert.Equal(t, "de", r.Header.Get("Accept-Language"))
+		switch r.URL.Path {
+		case "/users/404":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprint(w, `{"error":"Benutzer nicht gefunden"}`)
+		default:
+			w.WriteHeader(http.StatusConflict)
+			_, _ = fmt.Fprint(w, `not json`)
+		}
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, WithTenant("acme"), WithLanguage("de"))
+
+	_, err := c.GetUser(context.Background(), "404", false)
+	assert.True(t, IsNotFound(err))
+	assert.EqualError(t, err, "api: 404 Not Found: Benutzer nicht gefunden")
+
+	_, err = c.UpdateUser(context.Background(), "1", UserRequest{})
+	assert.True(t, IsConflict(err))
+	assert.EqualError(t, err, "api: 409 Conflict: not json")
+}
+
+func TestID(t *testing.T) {
+	var users []User
+	require.NoError(t, json.Unmarshal([]byte(`[{"id":42},{"id":"01890a5d-ac96-774b-bcce-b302099a8057"}]`), &users))
+	assert.Equal(t, ID("42"), users[0].ID)
+	assert.Equal(t, ID("01890a5d-ac96-774b-bcce-b302099a8057"), users[1].ID)
+}
+
+func TestUsersFollowsCursor(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "2", r.URL.Query().Get("limit"))
+		assert.Equal(t, "active", r.URL.Query().Get("status"))
+
+		// Pages of 2 out of 5 users; the cursor is the last ID of the page
+		after, _ := strconv.Atoi(r.URL.Query().Get("cursor"))
+		var page []User
+		for id := after + 1; id <= 5 && len(page) < 2; id++ {
+			page = append(page, User{ID: ID(strconv.Itoa(id))})
+		}
+		if len(page) == 2 {
+			w.Header().Set("X-Next-Cursor", string(page[1].ID))
+		}
+		_ = json.NewEncoder(w).Encode(page)
+	}))
+	defer srv.Close()
+
+	var ids []ID
+	for u, err := range New(srv.URL).Users(context.Background(), ListOptions{Status: StatusActive, Limit: 2}) {
+		require.NoError(t, err)
+		ids = append(ids, u.ID)
+	}
+	assert.Equal(t, []ID{"1", "2", "3", "4", "5"}, ids)
+}
+
+func TestListUsersTotal(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "true", r.URL.Query().Get("count"))
+		assert.Equal(t, "profile", r.URL.Query().Get("expand"))
+		assert.Equal(t, "dark", r.URL.Query().Get("preference.theme"))
+		w.Header().Set("X-Total-Count", "7")
+		_, _ = fmt.Fprint(w, `[{"id":1,"profile":{"bio":"hi"}}]`)
+	}))
+	defer srv.Close()
+
+	page, err := New(srv.URL).ListUsers(context.Background(), ListOptions{
+		Count:         true,
+		ExpandProfile: true,
+		Preferences:   map[string]string{"theme": "dark"},
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, 7, page.Total)
+	assert.Empty(t, page.NextCursor)
+	require.Len(t, page.Users, 1)
+	assert.Equal(t, "hi", page.Users[0].Profile.Bio)
+}
+
+func TestStreamUsers(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.URL.Query().Get("limit"))
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		_, _ = fmt.Fprint(w, "{\"id\":1}\n{\"id\":2}\n{\"id\":")
+	}))
+	defer srv.Close()
+
+	var ids []ID
+	var err error
+	for u, e := range New(srv.URL).StreamUsers(context.Background(), ListOptions{Limit: 10}) {
+		if e != nil {
+			err = e
+			break
+		}
+		ids = append(ids, u.ID)
+	}
+	assert.Equal(t, []ID{"1", "2"}, ids)
+	assert.Error(t, err, "a truncated stream is reported")
+}
+
+func TestChanges(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/users/changes", r.URL.Path)
+		assert.Equal(t, "41", r.URL.Query().Get("since"))
+		assert.Empty(t, r.URL.Query().Get("limit"))
+		_, _ = fmt.Fprint(w, `{"changes":[{"type":"updated","id":1,"user":{"id":1,"name":"Ann"}},`+
+			`{"type":"deleted","id":2,"deleted_at":"2024-01-02T03:04:05Z"}],"cursor":"42","has_more":false}`)
+	}))
+	defer srv.Close()
+
+	page, err := New(srv.URL).Changes(context.Background(), "41", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "42", page.Cursor)
+	require.Len(t, page.Changes, 2)
+	assert.Equal(t, "Ann", page.Changes[0].User.Name)
+	assert.Equal(t, ChangeDeleted, page.Changes[1].Type)
+	assert.Equal(t, ID("2"), page.Changes[1].ID)
+	assert.Nil(t, page.Changes[1].User)
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), *page.Changes[1].DeletedAt)
+}
+
+func TestChangesResyncRequired(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+		_, _ = fmt.Fprint(w, `{"error":"changes since this position are no longer available"}`)
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL).Changes(context.Background(), "41", 0)
+	assert.True(t, IsResyncRequired(err))
+	assert.False(t, IsNotFound(err))
+}
@@ -0,0 +1,504 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ID identifies a user: a number, or a UUID when the server uses UUID keys
+type ID string
+
+// UnmarshalJSON accepts both JSON numbers and strings
+func (id *ID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*id = ID(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("user ID must be a number or a string: %w", err)
+	}
+	*id = ID(n)
+	return nil
+}
+
+// User statuses
+const (
+	StatusActive      = "active"
+	StatusSuspended   = "suspended"
+	StatusDeactivated = "deactivated"
+	// StatusAll lists users of every status
+	StatusAll = "all"
+)
+
+// User is a user as returned by the API
+type User struct {
+	ID          ID             `json:"id"`
+	Name        string         `json:"name"`
+	Email       string         `json:"email"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	Status      string         `json:"status"`
+	Preferences map[string]any `json:"preferences"`
+	// Profile is only set when requested with ExpandProfile
+	Profile *Profile `json:"profile,omitempty"`
+}
+
+// Profile is the profile of a user. UpdatedAt is nil for users without one.
+type Profile struct {
+	Phone     string     `json:"phone"`
+	Address   string     `json:"address"`
+	Bio       string     `json:"bio"`
+	AvatarURL string     `json:"avatar_url"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// UserRequest creates or replaces a user
+type UserRequest struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// ProfileRequest creates or replaces a profile
+type ProfileRequest struct {
+	Phone     string `json:"phone"`
+	Address   string `json:"address"`
+	Bio       string `json:"bio"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+// Avatar is a time-limited download link of an avatar
+type Avatar struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// AuditEntry is a recorded change of a user
+type AuditEntry struct {
+	ID        int64           `json:"id"`
+	UserID    int64           `json:"user_id"`
+	Action    string          `json:"action"`
+	Actor     string          `json:"actor"`
+	Old       json.RawMessage `json:"old,omitempty"`
+	New       json.RawMessage `json:"new,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Export is everything stored about a user
+type Export struct {
+	User    User           `json:"user"`
+	Profile *Profile       `json:"profile"`
+	Audit   []AuditEntry   `json:"audit"`
+	Objects []ExportObject `json:"objects"`
+}
+
+// ExportObject is a stored file of a user, such as their avatar
+type ExportObject struct {
+	Key         string `json:"key"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+	Data        []byte `json:"data"`
+}
+
+// Usage is the API usage of a user for the current day
+type Usage struct {
+	Limit     int64     `json:"limit"`
+	Used      int64     `json:"used"`
+	Remaining int64     `json:"remaining"`
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+// Change types of the changes feed
+const (
+	ChangeCreated = "created"
+	ChangeUpdated = "updated"
+	ChangeDeleted = "deleted"
+)
+
+// Change is a user created, updated or deleted since a point. User is nil
+// for deleted users, which only carry their ID and DeletedAt.
+type Change struct {
+	Type      string     `json:"type"`
+	ID        ID         `json:"id"`
+	User      *User      `json:"user,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// Changes is a page of the changes feed
+type Changes struct {
+	Changes []Change `json:"changes"`
+	// Cursor is the since of the next call; empty when there were no changes
+	// after a timestamp, which is then passed again
+	Cursor  string `json:"cursor"`
+	HasMore bool   `json:"has_more"`
+}
+
+// ListOptions filters and orders users. Zero values are left to the server.
+type ListOptions struct {
+	CreatedAfter, CreatedBefore time.Time
+	UpdatedAfter, UpdatedBefore time.Time
+	// Preferences matches users whose preference key has the value
+	Preferences map[string]string
+	// Status is one of the Status constants; empty lists all but suspended
+	Status string
+	// SortBy is created_at or updated_at
+	SortBy string
+	// Ascending orders oldest first
+	Ascending bool
+	// Limit is the page size
+	Limit int
+	// Offset skips users; Cursor is preferred for paging
+	Offset int
+	// Cursor resumes after the last user of a previous page
+	Cursor string
+	// Count returns the number of matching users in UserPage.Total
+	Count bool
+	// ExpandProfile embeds each user's profile
+	ExpandProfile bool
+}
+
+func (o ListOptions) query() url.Values {
+	q := url.Values{}
+	for param, t := range map[string]time.Time{
+		"created_after":  o.CreatedAfter,
+		"created_before": o.CreatedBefore,
+		"updated_after":  o.UpdatedAfter,
+		"updated_before": o.UpdatedBefore,
+	} {
+		if !t.IsZero() {
+			q.Set(param, t.Format(time.RFC3339Nano))
+		}
+	}
+	for k, v := range o.Preferences {
+		q.Set("preference."+k, v)
+	}
+	if o.Status != "" {
+		q.Set("status", o.Status)
+	}
+	if o.SortBy != "" {
+		q.Set("sort", o.SortBy)
+	}
+	if o.Ascending {
+		q.Set("order", "asc")
+	}
+	if o.Limit > 0 {
+		q.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Offset > 0 {
+		q.Set("offset", strconv.Itoa(o.Offset))
+	}
+	if o.Cursor != "" {
+		q.Set("cursor", o.Cursor)
+	}
+	if o.Count {
+		q.Set("count", "true")
+	}
+	if o.ExpandProfile {
+		q.Set("expand", "profile")
+	}
+	return q
+}
+
+// UserPage is a page of users
+type UserPage struct {
+	Users []User
+	// NextCursor resumes after the page; empty on the last page
+	NextCursor string
+	// Total is the number of matching users when ListOptions.Count is set
+	Total int64
+}
+
+// CreateUser creates a user
+func (c *Client) CreateUser(ctx context.Context, req UserRequest) (*User, error) {
+	var u User
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/users", body: req}, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// ImportUsers creates users in bulk, all or none, and returns their number
+func (c *Client) ImportUsers(ctx context.Context, reqs []UserRequest) (int64, error) {
+	var resp struct {
+		Imported int64 `json:"imported"`
+	}
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/users/import", body: reqs}, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Imported, nil
+}
+
+// GetUser returns a user, with its profile when expandProfile is set
+func (c *Client) GetUser(ctx context.Context, id ID, expandProfile bool) (*User, error) {
+	req := request{method: http.MethodGet, path: userPath(id, "")}
+	if expandProfile {
+		req.query = url.Values{"expand": {"profile"}}
+	}
+
+	var u User
+	if _, err := c.do(ctx, req, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// UpdateUser replaces the name and email of a user
+func (c *Client) UpdateUser(ctx context.Context, id ID, req UserRequest) (*User, error) {
+	var u User
+	if _, err := c.do(ctx, request{method: http.MethodPut, path: userPath(id, ""), body: req}, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// DeleteUser deletes a user
+func (c *Client) DeleteUser(ctx context.Context, id ID) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: userPath(id, "")}, nil)
+	return err
+}
+
+// PatchPreferences merges patch into the preferences of a user: objects are
+// merged, nil removes a key and other values replace it
+func (c *Client) PatchPreferences(ctx context.Context, id ID, patch map[string]any) (*User, error) {
+	var u User
+	if _, err := c.do(ctx, request{method: http.MethodPatch, path: userPath(id, "/preferences"), body: patch}, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// UpdateProfile creates or replaces the profile of a user
+func (c *Client) UpdateProfile(ctx context.Context, id ID, req ProfileRequest) (*Profile, error) {
+	var p Profile
+	if _, err := c.do(ctx, request{method: http.MethodPut, path: userPath(id, "/profile"), body: req}, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SuspendUser suspends an active user
+func (c *Client) SuspendUser(ctx context.Context, id ID) (*User, error) {
+	return c.setStatus(ctx, id, "/suspend")
+}
+
+// ActivateUser reactivates a suspended or deactivated user
+func (c *Client) ActivateUser(ctx context.Context, id ID) (*User, error) {
+	return c.setStatus(ctx, id, "/activate")
+}
+
+// DeactivateUser deactivates a user
+func (c *Client) DeactivateUser(ctx context.Context, id ID) (*User, error) {
+	return c.setStatus(ctx, id, "/deactivate")
+}
+
+func (c *Client) setStatus(ctx context.Context, id ID, action string) (*User, error) {
+	var u User
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: userPath(id, action)}, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// multipartBody is a request body sent as is
+type multipartBody struct {
+	data        []byte
+	contentType string
+}
+
+// UploadAvatar uploads a PNG, JPEG, GIF or WebP image as a user's avatar.
+// The image is buffered so the upload can be retried.
+func (c *Client) UploadAvatar(ctx context.Context, id ID, filename string, image io.Reader) error {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	part, err := w.CreateFormFile("avatar", filename)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, image); err != nil {
+		return fmt.Errorf("failed to read avatar: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	body := &multipartBody{data: buf.Bytes(), contentType: w.FormDataContentType()}
+	_, err = c.do(ctx, request{method: http.MethodPost, path: userPath(id, "/avatar"), body: body}, nil)
+	return err
+}
+
+// GetAvatar returns a download link of a user's avatar
+func (c *Client) GetAvatar(ctx context.Context, id ID) (*Avatar, error) {
+	var a Avatar
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: userPath(id, "/avatar")}, &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// Audit returns up to limit recorded changes of a user, newest first. A
+// limit of 0 uses the server default.
+func (c *Client) Audit(ctx context.Context, id ID, limit int) ([]AuditEntry, error) {
+	req := request{method: http.MethodGet, path: userPath(id, "/audit")}
+	if limit > 0 {
+		req.query = url.Values{"limit": {strconv.Itoa(limit)}}
+	}
+
+	var entries []AuditEntry
+	if _, err := c.do(ctx, req, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ExportUser returns everything stored about a user, for a data takeout
+func (c *Client) ExportUser(ctx context.Context, id ID) (*Export, error) {
+	var e Export
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: userPath(id, "/export")}, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Usage returns the daily quota of a user and how much of it is used
+func (c *Client) Usage(ctx context.Context, id ID) (*Usage, error) {
+	var u Usage
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: userPath(id, "/usage")}, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// EraseUser removes the personal data of a user for good
+func (c *Client) EraseUser(ctx context.Context, id ID) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: userPath(id, "/erase")}, nil)
+	return err
+}
+
+// MergeUsers merges the duplicate user into the survivor and returns the
+// survivor. The duplicate is deleted.
+func (c *Client) MergeUsers(ctx context.Context, survivor, duplicate ID) (*User, error) {
+	body := map[string]ID{"survivor_id": survivor, "duplicate_id": duplicate}
+	var u User
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/admin/users/merge", body: body}, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// Changes returns the users changed after since, the Cursor of a previous
+// page or an RFC 3339 timestamp; an empty since returns every user. A limit
+// of 0 uses the server's default page size. Check the error with
+// IsResyncRequired for a since the server no longer serves.
+func (c *Client) Changes(ctx context.Context, since string, limit int) (*Changes, error) {
+	query := url.Values{}
+	if since != "" {
+		query.Set("since", since)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	var page Changes
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/users/changes", query: query}, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// ListUsers returns a page of users
+func (c *Client) ListUsers(ctx context.Context, opts ListOptions) (*UserPage, error) {
+	var page UserPage
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: "/users", query: opts.query()}, &page.Users)
+	if err != nil {
+		return nil, err
+	}
+
+	page.NextCursor = resp.Header.Get("X-Next-Cursor")
+	if total := resp.Header.Get("X-Total-Count"); total != "" {
+		if page.Total, err = strconv.ParseInt(total, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid X-Total-Count: %w", err)
+		}
+	}
+	return &page, nil
+}
+
+// defaultPageSize is the page size of Users when opts.Limit is 0
+const defaultPageSize = 100
+
+// Users iterates over every user matching opts, fetching pages of opts.Limit
+// users and following the cursor. Offset is ignored. Iteration stops at the
+// first error, which is yielded.
+func (c *Client) Users(ctx context.Context, opts ListOptions) iter.Seq2[User, error] {
+	return func(yield func(User, error) bool) {
+		if opts.Limit <= 0 {
+			opts.Limit = defaultPageSize
+		}
+		opts.Offset = 0
+		for {
+			page, err := c.ListUsers(ctx, opts)
+			if err != nil {
+				yield(User{}, err)
+				return
+			}
+			for _, u := range page.Users {
+				if !yield(u, nil) {
+					return
+				}
+			}
+			if page.NextCursor == "" {
+				return
+			}
+			opts.Cursor = page.NextCursor
+		}
+	}
+}
+
+// StreamUsers iterates over every user matching opts from a single
+// newline-delimited JSON response. Limit, Offset, Cursor, Count and
+// ExpandProfile are ignored. Unlike Users it reads one consistent snapshot,
+// but a failed stream can't be resumed.
+func (c *Client) StreamUsers(ctx context.Context, opts ListOptions) iter.Seq2[User, error] {
+	return func(yield func(User, error) bool) {
+		q := opts.query()
+		for _, param := range []string{"limit", "offset", "cursor", "count", "expand"} {
+			q.Del(param)
+		}
+		resp, err := c.send(ctx, request{method: http.MethodGet, path: "/users/stream", query: q})
+		if err != nil {
+			yield(User{}, err)
+			return
+		}
+		defer resp.Body.Close()
+
+		dec := json.NewDecoder(bufio.NewReader(resp.Body))
+		for {
+			var u User
+			err := dec.Decode(&u)
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(User{}, fmt.Errorf("failed to read user stream: %w", err))
+				return
+			}
+			if !yield(u, nil) {
+				return
+			}
+		}
+	}
+}
+
+func userPath(id ID, suffix string) string {
+	return "/users/" + url.PathEscape(string(id)) + suffix
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Webhook is an endpoint events are delivered to
+type Webhook struct {
+	ID  int64  `json:"id"`
+	URL string `json:"url"`
+	// Secret signs the deliveries; it is only returned by CreateWebhook
+	Secret    string    `json:"secret,omitempty"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Delivery is an attempt to deliver an event to a webhook
+type Delivery struct {
+	ID         int64     `json:"id"`
+	WebhookID  int64     `json:"webhook_id"`
+	EventID    string    `json:"event_id"`
+	EventType  string    `json:"event_type"`
+	Attempt    int       `json:"attempt"`
+	StatusCode *int      `json:"status_code,omitempty"`
+	Error      *string   `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// CreateWebhook registers an endpoint for event deliveries. Keep the
+// returned Secret to verify their signatures.
+func (c *Client) CreateWebhook(ctx context.Context, endpoint string) (*Webhook, error) {
+	body := struct {
+		URL string `json:"url"`
+	}{endpoint}
+
+	var w Webhook
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/webhooks", body: body}, &w); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// ListWebhooks returns the registered webhooks
+func (c *Client) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	var ws []Webhook
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/webhooks"}, &ws); err != nil {
+		return nil, err
+	}
+	return ws, nil
+}
+
+// GetWebhook returns a webhook
+func (c *Client) GetWebhook(ctx context.Context, id int64) (*Webhook, error) {
+	var w Webhook
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: webhookPath(id, "")}, &w); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// DeleteWebhook removes a webhook
+func (c *Client) DeleteWebhook(ctx context.Context, id int64) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: webhookPath(id, "")}, nil)
+	return err
+}
+
+// ListDeliveries returns the latest delivery attempts of a webhook
+func (c *Client) ListDeliveries(ctx context.Context, id int64) ([]Delivery, error) {
+	var ds []Delivery
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: webhookPath(id, "/deliveries")}, &ds); err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
+
+func webhookPath(id int64, suffix string) string {
+	return "/webhooks/" + strconv.FormatInt(id, 10) + suffix
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/things-kit/example-db/client"
+)
+
+// options configures a run
+type options struct {
+	url    string
+	tenant string
+	// rate is the number of requests started per second
+	rate     int
+	duration time.Duration
+	mix      string
+	// users is the number of users created before the run for the mix to
+	// address
+	users       int
+	maxInFlight int
+	timeout     time.Duration
+	// seed makes the sequence of endpoints and users reproducible
+	seed uint64
+}
+
+func (o *options) validate() error {
+	switch {
+	case o.url == "":
+		return errors.New("--url is required")
+	case o.rate < 1:
+		return errors.New("--rate must be at least 1")
+	case o.duration <= 0:
+		return errors.New("--duration must be positive")
+	case o.maxInFlight < 1:
+		return errors.New("--max-in-flight must be at least 1")
+	case o.users < 1:
+		return errors.New("--users must be at least 1")
+	case o.timeout <= 0:
+		return errors.New("--timeout must be positive")
+	}
+	return nil
+}
+
+// run prepares the target, puts it under load and writes the report to out
+func run(ctx context.Context, o options, out io.Writer) error {
+	if err := o.validate(); err != nil {
+		return err
+	}
+	m, err := parseMix(o.mix)
+	if err != nil {
+		return err
+	}
+
+	hc := &http.Client{
+		Timeout:   o.timeout,
+		Transport: &http.Transport{MaxIdleConnsPerHost: o.maxInFlight},
+	}
+	t := &target{
+		base:   strings.TrimRight(o.url, "/"),
+		header: http.Header{},
+		rand:   rand.New(rand.NewPCG(o.seed, o.seed)),
+		run:    strconv.FormatInt(time.Now().UnixNano(), 36),
+	}
+	if o.tenant != "" {
+		t.header.Set("X-Tenant-ID", o.tenant)
+	}
+
+	if m.needsUsers() {
+		if t.ids, err = createUsers(ctx, hc, o, t.run); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Created %d users to address\n", len(t.ids))
+	}
+
+	fmt.Fprintf(out, "Sending %d requests/s for %s\n\n", o.rate, o.duration)
+	return attack(ctx, hc, t, m, o).write(out)
+}
+
+// createUsers creates the users that get, update and preferences requests
+// address
+func createUsers(ctx context.Context, hc *http.Client, o options, run string) ([]client.ID, error) {
+	opts := []client.Option{client.WithHTTPClient(hc)}
+	if o.tenant != "" {
+		opts = append(opts, client.WithTenant(o.tenant))
+	}
+	c := client.New(o.url, opts...)
+
+	ids := make([]client.ID, 0, o.users)
+	for i := range o.users {
+		u, err := c.CreateUser(ctx, client.UserRequest{
+			Name:  fmt.Sprintf("Load User %d", i),
+			Email: fmt.Sprintf("load.%s.setup.%d@example.com", run, i),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create user: %w", err)
+		}
+		ids = append(ids, u.ID)
+	}
+	return ids, nil
+}
+
+// attack starts o.rate requests a second until o.duration passed or ctx is
+// done, then waits for the pending ones. The rate is kept whatever the
+// latency: a request due while o.maxInFlight are pending is dropped and
+// counted rather than delayed, so a slow server can't lower the load it is
+// measured under.
+func attack(ctx context.Context, hc *http.Client, t *target, m *mix, o options) *report {
+	rep := newReport()
+	results := make(chan result, o.maxInFlight)
+	collected := make(chan struct{})
+	go func() {
+		defer close(collected)
+		for res := range results {
+			rep.add(res)
+		}
+	}()
+
+	// Pending requests run to completion when the run ends
+	reqCtx := context.WithoutCancel(ctx)
+	ctx, cancel := context.WithTimeout(ctx, o.duration)
+	defer cancel()
+
+	ticker := time.NewTicker(time.Second / time.Duration(o.rate))
+	defer ticker.Stop()
+
+	var (
+		wg       sync.WaitGroup
+		inFlight = make(chan struct{}, o.maxInFlight)
+		start    = time.Now()
+	)
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-ticker.C:
+		}
+
+		e := m.pick(t.rand)
+		req, err := e.build(t)
+		if err != nil {
+			results <- result{endpoint: e.name, err: err}
+			continue
+		}
+		req = req.WithContext(reqCtx)
+		for key, values := range t.header {
+			req.Header[key] = values
+		}
+
+		select {
+		case inFlight <- struct{}{}:
+		default:
+			rep.dropped++
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results <- send(hc, e.name, req)
+			<-inFlight
+		}()
+	}
+	rep.duration = time.Since(start)
+
+	wg.Wait()
+	close(results)
+	<-collected
+	return rep
+}
+
+// send sends req and measures the time until its body was read
+func send(hc *http.Client, endpoint string, req *http.Request) result {
+	start := time.Now()
+	resp, err := hc.Do(req)
+	if err != nil {
+		// Drop the method and URL, so that one error counts once in the report
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return result{endpoint: endpoint, err: err}
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return result{endpoint: endpoint, err: err}
+	}
+	return result{endpoint: endpoint, latency: time.Since(start), status: resp.StatusCode}
+}
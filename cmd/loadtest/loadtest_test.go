@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMix(t *testing.T) {
+	m, err := parseMix("get=3, list=1,create=0")
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 4}, m.cumulative)
+	assert.True(t, m.needsUsers())
+
+	m, err = parseMix("list=1,create=1")
+	require.NoError(t, err)
+	assert.False(t, m.needsUsers())
+
+	for _, s := range []string{"", "get", "get=x", "get=-1", "delete=1", "get=1,get=2", "get=0"} {
+		_, err := parseMix(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestMixPick(t *testing.T) {
+	m, err := parseMix("get=3,list=1")
+	require.NoError(t, err)
+
+	r := rand.New(rand.NewPCG(1, 1))
+	counts := map[string]int{}
+	for range 4000 {
+		counts[m.pick(r).name]++
+	}
+	assert.InDelta(t, 3000, counts["get"], 150)
+	assert.InDelta(t, 1000, counts["list"], 150)
+}
+
+func TestPercentile(t *testing.T) {
+	var latencies []time.Duration
+	for i := range 100 {
+		latencies = append(latencies, time.Duration(i+1)*time.Millisecond)
+	}
+
+	assert.Equal(t, 50*time.Millisecond, percentile(latencies, 50))
+	assert.Equal(t, 99*time.Millisecond, percentile(latencies, 99))
+	assert.Equal(t, 100*time.Millisecond, percentile(latencies, 100))
+	assert.Equal(t, time.Millisecond, percentile(latencies, 0))
+	assert.Zero(t, percentile(nil, 50))
+}
+
+func TestRun(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests = map[string]int{}
+		nextID   = 0
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		assert.Equal(t, "acme", r.Header.Get("X-Tenant-ID"))
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/users":
+			nextID++
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]any{"id": nextID})
+			requests["create"]++
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/users/"):
+			w.Write([]byte(`{}`))
+			requests["get"]++
+		default:
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	var out bytes.Buffer
+	err := run(context.Background(), options{
+		url:         srv.URL,
+		tenant:      "acme",
+		rate:        200,
+		duration:    250 * time.Millisecond,
+		mix:         "get=1",
+		users:       3,
+		maxInFlight: 10,
+		timeout:     time.Second,
+		seed:        1,
+	}, &out)
+	require.NoError(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 3, requests["create"])
+	assert.Positive(t, requests["get"])
+
+	report := out.String()
+	assert.Contains(t, report, "Created 3 users to address")
+	assert.Contains(t, report, "p99")
+	assert.Regexp(t, `get\s+\d+`, report)
+	assert.NotContains(t, report, "error")
+}
+
+func TestRunValidatesOptions(t *testing.T) {
+	o := options{url: "http://localhost:8080", rate: 0, duration: time.Second, mix: "get=1", users: 1, maxInFlight: 1, timeout: time.Second}
+	assert.ErrorContains(t, run(context.Background(), o, &bytes.Buffer{}), "--rate")
+}
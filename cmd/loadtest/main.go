@@ -0,0 +1,60 @@
+// Command loadtest puts a running example-db service under a constant rate
+// of requests, spread over a weighted mix of endpoints, and reports the
+// latency percentiles of each endpoint:
+//
+//	go run ./cmd/loadtest --url http://localhost:8080 --rate 200 --duration 30s --mix get=60,list=30,create=10
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := newRootCmd().ExecuteContext(ctx); err != nil {
+		os.Exit(1)
+	}
+}
+
+func newRootCmd() *cobra.Command {
+	o := options{}
+
+	cmd := &cobra.Command{
+		Use:   "loadtest",
+		Short: "Measure the latency of a running service under load",
+		Long: `Send requests to a running service at a constant rate for a while, picking
+each request's endpoint at random from a weighted mix, then print the latency
+percentiles of every endpoint. Interrupting the run prints the report so far.
+
+Endpoints: ` + strings.Join(endpointNames(), ", ") + `.
+
+Before the run, --users users are created for get, update and preferences
+requests to address. Use --seed to repeat the same sequence of requests.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd.Context(), o, cmd.OutOrStdout())
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&o.url, "url", "http://localhost:8080", "base URL of the service")
+	flags.StringVar(&o.tenant, "tenant", "", "tenant ID sent in the X-Tenant-ID header")
+	flags.IntVar(&o.rate, "rate", 50, "requests started per second")
+	flags.DurationVar(&o.duration, "duration", 10*time.Second, "length of the run")
+	flags.StringVar(&o.mix, "mix", "get=60,list=30,create=10", "weighted endpoints as name=weight,...")
+	flags.IntVar(&o.users, "users", 100, "users created before the run")
+	flags.IntVar(&o.maxInFlight, "max-in-flight", 1000, "pending requests above which new ones are dropped")
+	flags.DurationVar(&o.timeout, "timeout", 10*time.Second, "timeout of each request")
+	flags.Uint64Var(&o.seed, "seed", 1, "random seed of the endpoint and user choice")
+	return cmd
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/things-kit/example-db/client"
+)
+
+// endpoint is one kind of request of the load
+type endpoint struct {
+	name string
+	// build creates the next request against the target
+	build func(t *target) (*http.Request, error)
+}
+
+// endpoints holds every endpoint a mix can name
+var endpoints = map[string]endpoint{
+	"get": {"get", func(t *target) (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, t.userURL(""), nil)
+	}},
+	"list": {"list", func(t *target) (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, t.base+"/users?limit=20", nil)
+	}},
+	"list-total": {"list-total", func(t *target) (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, t.base+"/users?limit=20&count=true", nil)
+	}},
+	"create": {"create", func(t *target) (*http.Request, error) {
+		n := t.next()
+		return t.jsonRequest(http.MethodPost, t.base+"/users", client.UserRequest{
+			Name:  "Load User " + strconv.FormatInt(n, 10),
+			Email: fmt.Sprintf("load.%s.%d@example.com", t.run, n),
+		})
+	}},
+	"update": {"update", func(t *target) (*http.Request, error) {
+		n := t.next()
+		return t.jsonRequest(http.MethodPut, t.userURL(""), client.UserRequest{
+			Name:  "Load User " + strconv.FormatInt(n, 10),
+			Email: fmt.Sprintf("load.%s.%d@example.com", t.run, n),
+		})
+	}},
+	"preferences": {"preferences", func(t *target) (*http.Request, error) {
+		theme := []string{"light", "dark"}[t.rand.IntN(2)]
+		return t.jsonRequest(http.MethodPatch, t.userURL("/preferences"), map[string]any{"theme": theme})
+	}},
+}
+
+// target builds requests against the service under load. It is used by the
+// single goroutine that schedules requests, so it needs no locking.
+type target struct {
+	base   string
+	header http.Header
+	// ids are the users that get, update and preferences requests address
+	ids  []client.ID
+	rand *rand.Rand
+	// run and seq make the emails of created users unique across runs
+	run string
+	seq int64
+}
+
+// next returns the next number of the run
+func (t *target) next() int64 {
+	t.seq++
+	return t.seq
+}
+
+// userURL returns the URL of a random user of the target, with suffix
+func (t *target) userURL(suffix string) string {
+	id := t.ids[t.rand.IntN(len(t.ids))]
+	return t.base + "/users/" + url.PathEscape(string(id)) + suffix
+}
+
+func (t *target) jsonRequest(method, u string, body any) (*http.Request, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, u, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// mix picks endpoints at random in proportion to their weights
+type mix struct {
+	endpoints []endpoint
+	// cumulative holds the running sum of the weights, in endpoint order
+	cumulative []int
+}
+
+// parseMix parses a mix such as "get=60,list=30,create=10". Weights are
+// relative; they need not add up to 100.
+func parseMix(s string) (*mix, error) {
+	m := &mix{}
+	total := 0
+	for _, part := range strings.Split(s, ",") {
+		name, weight, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("mix entry %q is not name=weight", part)
+		}
+		e, ok := endpoints[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown endpoint %q, want one of %s", name, strings.Join(endpointNames(), ", "))
+		}
+		if slices.ContainsFunc(m.endpoints, func(o endpoint) bool { return o.name == name }) {
+			return nil, fmt.Errorf("endpoint %q is listed twice", name)
+		}
+		w, err := strconv.Atoi(weight)
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("weight of %q must be a non-negative integer", name)
+		}
+		if w == 0 {
+			continue
+		}
+		total += w
+		m.endpoints = append(m.endpoints, e)
+		m.cumulative = append(m.cumulative, total)
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("mix %q has no endpoint with a positive weight", s)
+	}
+	return m, nil
+}
+
+// pick returns a random endpoint of the mix
+func (m *mix) pick(r *rand.Rand) endpoint {
+	n := r.IntN(m.cumulative[len(m.cumulative)-1])
+	i, _ := slices.BinarySearch(m.cumulative, n+1)
+	return m.endpoints[i]
+}
+
+// needsUsers reports whether an endpoint of the mix addresses existing users
+func (m *mix) needsUsers() bool {
+	return slices.ContainsFunc(m.endpoints, func(e endpoint) bool {
+		return e.name == "get" || e.name == "update" || e.name == "preferences"
+	})
+}
+
+func endpointNames() []string {
+	names := make([]string, 0, len(endpoints))
+	for name := range endpoints {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
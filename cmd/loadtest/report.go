@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// result is the outcome of one request
+type result struct {
+	endpoint string
+	latency  time.Duration
+	// status is the response status, or 0 if the request failed
+	status int
+	err    error
+}
+
+// stats collects the results of one endpoint
+type stats struct {
+	latencies []time.Duration
+	statuses  map[int]int
+	errors    map[string]int
+}
+
+// report collects the results of a run
+type report struct {
+	endpoints map[string]*stats
+	// dropped counts requests not sent because maxInFlight were pending
+	dropped  int
+	duration time.Duration
+}
+
+func newReport() *report {
+	return &report{endpoints: map[string]*stats{}}
+}
+
+func (r *report) add(res result) {
+	s, ok := r.endpoints[res.endpoint]
+	if !ok {
+		s = &stats{statuses: map[int]int{}, errors: map[string]int{}}
+		r.endpoints[res.endpoint] = s
+	}
+
+	if res.err != nil {
+		s.errors[res.err.Error()]++
+		return
+	}
+	s.latencies = append(s.latencies, res.latency)
+	s.statuses[res.status]++
+}
+
+// percentile returns the p-th percentile of sorted latencies with the
+// nearest-rank method, or 0 for none
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// write prints a table of the latency percentiles of each endpoint and of
+// all requests, followed by the status codes and errors seen
+func (r *report) write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "endpoint\trequests\trate/s\t2xx\tfailed\tp50\tp90\tp95\tp99\tmax\t")
+
+	total := &stats{statuses: map[int]int{}, errors: map[string]int{}}
+	names := slices.Sorted(maps.Keys(r.endpoints))
+	for _, name := range names {
+		s := r.endpoints[name]
+		r.writeRow(tw, name, s)
+
+		total.latencies = append(total.latencies, s.latencies...)
+		for status, n := range s.statuses {
+			total.statuses[status] += n
+		}
+		for msg, n := range s.errors {
+			total.errors[msg] += n
+		}
+	}
+	if len(names) > 1 {
+		r.writeRow(tw, "total", total)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\nduration %s", r.duration.Round(time.Millisecond))
+	if r.dropped > 0 {
+		fmt.Fprintf(w, ", %d requests dropped at the in-flight limit", r.dropped)
+	}
+	fmt.Fprintln(w)
+
+	if len(total.statuses) > 0 {
+		codes := make([]string, 0, len(total.statuses))
+		for _, status := range slices.Sorted(maps.Keys(total.statuses)) {
+			codes = append(codes, fmt.Sprintf("%d×%d", status, total.statuses[status]))
+		}
+		fmt.Fprintf(w, "status codes: %s\n", strings.Join(codes, " "))
+	}
+	for _, msg := range slices.Sorted(maps.Keys(total.errors)) {
+		fmt.Fprintf(w, "error ×%d: %s\n", total.errors[msg], msg)
+	}
+	return nil
+}
+
+func (r *report) writeRow(w io.Writer, name string, s *stats) {
+	slices.Sort(s.latencies)
+
+	ok, failed := 0, 0
+	for status, n := range s.statuses {
+		if status >= 200 && status < 300 {
+			ok += n
+		} else {
+			failed += n
+		}
+	}
+	for _, n := range s.errors {
+		failed += n
+	}
+
+	requests := ok + failed
+	rate := 0.0
+	if r.duration > 0 {
+		rate = float64(requests) / r.duration.Seconds()
+	}
+
+	fmt.Fprintf(w, "%s\t%d\t%s\t%d\t%d\t%s\t%s\t%s\t%s\t%s\t\n",
+		name, requests, strconv.FormatFloat(rate, 'f', 1, 64), ok, failed,
+		ms(percentile(s.latencies, 50)),
+		ms(percentile(s.latencies, 90)),
+		ms(percentile(s.latencies, 95)),
+		ms(percentile(s.latencies, 99)),
+		ms(percentile(s.latencies, 100)),
+	)
+}
+
+// ms formats d in milliseconds with two decimals
+func ms(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 2, 64) + "ms"
+}
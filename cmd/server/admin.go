@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/things-kit/example-db/internal/audit"
+	"github.com/things-kit/example-db/internal/user"
+	"go.uber.org/fx"
+)
+
+func newCreateAdminCmd() *cobra.Command {
+	var req user.CreateUserRequest
+
+	cmd := &cobra.Command{
+		Use:   "create-admin",
+		Short: "Create an admin user, or grant admin to an existing one",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var svc *user.Service
+			return runTask(cmd.Context(), fx.Options(userOptions(), fx.Populate(&svc)), func(ctx context.Context) error {
+				ctx = audit.WithActor(ctx, "cli:create-admin")
+
+				u, err := svc.GetByEmail(ctx, req.Email)
+				if err != nil && !errors.Is(err, user.ErrNotFound) {
+					return err
+				}
+				if err != nil {
+					if req.Name == "" {
+						return fmt.Errorf("--name is required to create a new user")
+					}
+					if u, err = svc.Create(ctx, req); err != nil {
+						return err
+					}
+				}
+
+				if err := svc.GrantAdmin(ctx, u.ID); err != nil {
+					return err
+				}
+
+				fmt.Fprintf(cmd.OutOrStdout(), "User %d <%s> is an admin\n", u.ID, u.Email)
+				return nil
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&req.Name, "name", "", "name of the user")
+	cmd.Flags().StringVar(&req.Email, "email", "", "email of the user")
+	_ = cmd.MarkFlagRequired("email")
+	return cmd
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"github.com/spf13/viper"
+	"github.com/things-kit/example-db/internal/config"
+	"github.com/things-kit/example-db/internal/database"
+	"github.com/things-kit/example-db/internal/secrets"
+)
+
+// loadConfig decorates the configuration read by viperconfig with the
+// APP_ENV profile, environment overrides and secrets, and adds db.password
+// and the UTC session time zone to the DSN used by both database pools
+func loadConfig(v *viper.Viper) (*viper.Viper, error) {
+	v, err := config.WithProfile(v)
+	if err != nil {
+		return nil, err
+	}
+	if v, err = secrets.Resolve(v); err != nil {
+		return nil, err
+	}
+	if v, err = database.WithPassword(v); err != nil {
+		return nil, err
+	}
+	return database.WithUTC(v)
+}
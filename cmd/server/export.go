@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/things-kit/example-db/internal/user"
+	"go.uber.org/fx"
+)
+
+func newExportUsersCmd() *cobra.Command {
+	var format, output string
+
+	cmd := &cobra.Command{
+		Use:   "export-users",
+		Short: "Export all users as JSON or CSV",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "json" && format != "csv" {
+				return fmt.Errorf("unsupported format %q", format)
+			}
+
+			w := cmd.OutOrStdout()
+			if output != "" {
+				f, err := os.Create(output)
+				if err != nil {
+					return fmt.Errorf("failed to create output file: %w", err)
+				}
+				defer f.Close()
+				w = f
+			}
+
+			var (
+				svc *user.Service
+				cfg *user.Config
+			)
+			return runTask(cmd.Context(), fx.Options(userOptions(), fx.Populate(&svc, &cfg)), func(ctx context.Context) error {
+				users, err := svc.List(ctx, user.ListFilter{})
+				if err != nil {
+					return err
+				}
+
+				if format == "csv" {
+					return writeUsersCSV(w, users, cfg.IDType)
+				}
+				return writeUsersJSON(w, users, cfg.IDType)
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "json", "output format: json or csv")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "output file (default stdout)")
+	return cmd
+}
+
+func writeUsersJSON(w io.Writer, users []*user.User, ids user.IDType) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(user.NewUserResponses(users, ids)); err != nil {
+		return fmt.Errorf("failed to write users: %w", err)
+	}
+	return nil
+}
+
+func writeUsersCSV(w io.Writer, users []*user.User, ids user.IDType) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"id", "name", "email", "created_at", "updated_at"})
+	for _, u := range users {
+		_ = cw.Write([]string{
+			fmt.Sprint(user.NewUserResponse(u, ids).ID),
+			u.Name,
+			u.Email,
+			u.CreatedAt.Format(time.RFC3339),
+			u.UpdatedAt.Format(time.RFC3339),
+		})
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to write users: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/user"
+)
+
+func TestWriteUsersCSV(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	users := []*user.User{
+		{ID: 1, Name: "Doe, Jane", Email: "jane@example.com", CreatedAt: ts, UpdatedAt: ts},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeUsersCSV(&buf, users, user.IDSerial))
+
+	assert.Equal(t, "id,name,email,created_at,updated_at\n"+
+		"1,\"Doe, Jane\",jane@example.com,2024-01-02T03:04:05Z,2024-01-02T03:04:05Z\n", buf.String())
+}
+
+func TestWriteUsersJSONEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, writeUsersJSON(&buf, nil, user.IDSerial))
+	assert.Equal(t, "[]\n", buf.String())
+}
+
+func TestWriteUsersCSVWithUUIDs(t *testing.T) {
+	key := uuid.MustParse("01890a5d-ac96-774b-bcce-b302099a8057")
+	users := []*user.User{{ID: 1, Name: "Jane", Email: "jane@example.com", UUID: key}}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeUsersCSV(&buf, users, user.IDUUID))
+	assert.Contains(t, buf.String(), "\n01890a5d-ac96-774b-bcce-b302099a8057,Jane,")
+}
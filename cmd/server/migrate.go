@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/things-kit/example-db/internal/migrations"
+	"go.uber.org/fx"
+)
+
+func newMigrateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Manage database migrations",
+	}
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "up",
+			Short: "Apply all pending migrations",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runMigrator(cmd, func(ctx context.Context, m *migrations.Migrator) error {
+					results, err := m.Up(ctx)
+					for _, r := range results {
+						fmt.Fprintf(cmd.OutOrStdout(), "Applied %s (%s)\n", r.Source.Path, r.Duration.Round(time.Millisecond))
+					}
+					if err == nil && len(results) == 0 {
+						fmt.Fprintln(cmd.OutOrStdout(), "No pending migrations")
+					}
+					return err
+				})
+			},
+		},
+		&cobra.Command{
+			Use:   "down",
+			Short: "Roll back the most recent migration",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runMigrator(cmd, func(ctx context.Context, m *migrations.Migrator) error {
+					r, err := m.Down(ctx)
+					if err != nil {
+						return err
+					}
+					fmt.Fprintf(cmd.OutOrStdout(), "Rolled back %s (%s)\n", r.Source.Path, r.Duration.Round(time.Millisecond))
+					return nil
+				})
+			},
+		},
+		&cobra.Command{
+			Use:   "status",
+			Short: "Show which migrations are applied",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runMigrator(cmd, func(ctx context.Context, m *migrations.Migrator) error {
+					status, err := m.Status(ctx)
+					if err != nil {
+						return err
+					}
+
+					w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+					fmt.Fprintln(w, "VERSION\tMIGRATION\tSTATE\tAPPLIED AT")
+					for _, s := range status {
+						appliedAt := "-"
+						if !s.AppliedAt.IsZero() {
+							appliedAt = s.AppliedAt.Format(time.RFC3339)
+						}
+						fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Source.Version, s.Source.Path, s.State, appliedAt)
+					}
+					return w.Flush()
+				})
+			},
+		},
+	)
+
+	return cmd
+}
+
+// runMigrator runs fn with a Migrator for the configured database
+func runMigrator(cmd *cobra.Command, fn func(ctx context.Context, m *migrations.Migrator) error) error {
+	var m *migrations.Migrator
+	opts := fx.Options(fx.Provide(migrations.NewMigrator), fx.Populate(&m))
+	return runTask(cmd.Context(), opts, func(ctx context.Context) error {
+		return fn(ctx, m)
+	})
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/things-kit/example-db/internal/tenant"
+	"github.com/things-kit/example-db/internal/user"
+	"go.uber.org/fx"
+)
+
+func newReplayEventsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "replay-events",
+		Short: "Rebuild the users table from the user event log",
+		Long: `Fold the events in user_events and write the result over the users
+table, recreating missing rows. Only users changed while users.storage was
+"events" have events to replay; other users are left untouched, and so are
+deleted users.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var repo *user.Repository
+			return runTask(cmd.Context(), fx.Options(userOptions(), fx.Populate(&repo)), func(ctx context.Context) error {
+				n, err := user.NewEventStore(repo).Replay(tenant.WithTenant(ctx, tenant.All))
+				if err != nil {
+					return err
+				}
+
+				fmt.Fprintf(cmd.OutOrStdout(), "Replayed the events of %d users\n", n)
+				return nil
+			})
+		},
+	}
+}
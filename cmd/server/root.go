@@ -0,0 +1,28 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// newRootCmd creates the command tree. Without a subcommand the server runs.
+func newRootCmd() *cobra.Command {
+	root := &cobra.Command{
+		Use:          "server",
+		Short:        "Example user service built on things-kit",
+		Args:         cobra.NoArgs,
+		RunE:         runServe,
+		SilenceUsage: true,
+	}
+
+	root.AddCommand(
+		newServeCmd(),
+		newMigrateCmd(),
+		newSeedCmd(),
+		newCreateAdminCmd(),
+		newExportUsersCmd(),
+		newReplayEventsCmd(),
+		newAnonymizeUsersCmd(),
+	)
+
+	return root
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/things-kit/example-db/internal/seed"
+	"github.com/things-kit/example-db/internal/user"
+	"go.uber.org/fx"
+)
+
+func newSeedCmd() *cobra.Command {
+	var (
+		fake     int
+		fakeSeed uint64
+	)
+
+	cmd := &cobra.Command{
+		Use:   "seed",
+		Short: "Create demo users",
+		Long: `Create the demo users through the user service, so events and welcome
+emails are produced. Users whose email already exists are left untouched.
+
+With --fake, that many generated users are also inserted directly through the
+repository with a single COPY, without events or emails, for load and
+pagination testing.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				svc  *user.Service
+				repo *user.Repository
+			)
+			opts := fx.Options(userOptions(), fx.Populate(&svc, &repo))
+			return runTask(cmd.Context(), opts, func(ctx context.Context) error {
+				users, err := seed.Demo(ctx, svc)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Seeded %d demo users\n", len(users))
+
+				if fake > 0 {
+					created, err := seed.Fake(ctx, repo, fake, fakeSeed)
+					fmt.Fprintf(cmd.OutOrStdout(), "Created %d fake users\n", created)
+					return err
+				}
+				return nil
+			})
+		},
+	}
+
+	cmd.Flags().IntVar(&fake, "fake", 0, "number of generated users to add")
+	cmd.Flags().Uint64Var(&fakeSeed, "fake-seed", 1, "random seed for generated users")
+	return cmd
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/things-kit/app"
+	"github.com/things-kit/example-db/internal/server"
+	"github.com/things-kit/module/viperconfig"
+	"go.uber.org/fx"
+)
+
+func newServeCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "serve",
+		Short: "Run the HTTP server and background workers",
+		Args:  cobra.NoArgs,
+		RunE:  runServe,
+	}
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	app.New(serverOptions()).Run()
+	return nil
+}
+
+// serverOptions wires the complete service on top of the configuration file
+func serverOptions() fx.Option {
+	return fx.Options(
+		viperconfig.Module,
+		fx.Decorate(loadConfig),
+		server.Options(),
+	)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/things-kit/example-db/internal/clock"
+	"github.com/things-kit/example-db/internal/config"
+	"github.com/things-kit/example-db/internal/database"
+	"github.com/things-kit/example-db/internal/feature"
+	"github.com/things-kit/example-db/internal/idgen"
+	"github.com/things-kit/example-db/internal/mail"
+	"github.com/things-kit/example-db/internal/secrets"
+	"github.com/things-kit/example-db/internal/storage"
+	"github.com/things-kit/example-db/internal/user"
+	"github.com/things-kit/module/logging"
+	"github.com/things-kit/module/sqlc"
+	"github.com/things-kit/module/viperconfig"
+	"go.uber.org/fx"
+)
+
+// taskStopTimeout bounds the shutdown of a task application
+const taskStopTimeout = 15 * time.Second
+
+// userOptions provides the user Service and its dependencies without the
+// HTTP server or background workers
+func userOptions() fx.Option {
+	return fx.Options(
+		database.Module,
+		clock.Module,
+		idgen.Module,
+		feature.Module,
+		storage.Module,
+		mail.Module,
+		fx.Provide(user.NewConfig, user.NewMetrics, user.NewRepository, user.AsUserRepository, user.NewService),
+		config.Validate[*user.Config]("users"),
+	)
+}
+
+// taskOptions wires the core modules of a task application with opts
+func taskOptions(opts fx.Option) fx.Option {
+	return fx.Options(
+		viperconfig.Module,
+		fx.Decorate(loadConfig),
+		logging.Module,
+		config.Module,
+		secrets.Module,
+		sqlc.Module,
+		opts,
+	)
+}
+
+// runTask starts an application with the core modules and opts, runs fn and
+// stops the application again. Use fx.Populate in opts to hand values to fn.
+func runTask(ctx context.Context, opts fx.Option, fn func(ctx context.Context) error) error {
+	app := fx.New(taskOptions(opts), fx.NopLogger)
+	if err := app.Err(); err != nil {
+		return fmt.Errorf("failed to build application: %w", err)
+	}
+
+	if err := app.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start application: %w", err)
+	}
+
+	runErr := fn(ctx)
+
+	stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), taskStopTimeout)
+	defer cancel()
+
+	if err := app.Stop(stopCtx); err != nil && runErr == nil {
+		return fmt.Errorf("failed to stop application: %w", err)
+	}
+
+	return runErr
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/migrations"
+	"github.com/things-kit/example-db/internal/user"
+	"go.uber.org/fx"
+)
+
+// The tests check that every dependency of the applications built by the
+// commands is provided. fx.ValidateApp resolves the graph without calling
+// constructors, so neither a config file nor a database is needed.
+
+func TestServeWiring(t *testing.T) {
+	require.NoError(t, fx.ValidateApp(serverOptions(), fx.NopLogger))
+}
+
+func TestTaskWiring(t *testing.T) {
+	var (
+		svc  *user.Service
+		repo *user.Repository
+		cfg  *user.Config
+		m    *migrations.Migrator
+	)
+	tests := map[string]fx.Option{
+		"users":   fx.Options(userOptions(), fx.Populate(&svc, &repo, &cfg)),
+		"migrate": fx.Options(fx.Provide(migrations.NewMigrator), fx.Populate(&m)),
+	}
+
+	for name, opts := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, fx.ValidateApp(taskOptions(opts), fx.NopLogger))
+		})
+	}
+}
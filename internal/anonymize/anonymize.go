@@ -0,0 +1,207 @@
+// Package anonymize copies users from one database to another with their
+// personal data replaced by pseudonyms, to seed staging and development
+// environments with realistic data from production.
+package anonymize
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/things-kit/example-db/internal/tenant"
+)
+
+// DefaultBatchSize is the number of users copied per transaction
+const DefaultBatchSize = 1000
+
+// ErrNoKey is returned by New without a key
+var ErrNoKey = errors.New("anonymization key is required")
+
+// Pseudonymizer replaces names and emails with pseudonyms. A pseudonym is
+// derived from an HMAC of the original value, so the same key always yields
+// the same pseudonym, while without the key it can't be traced back.
+type Pseudonymizer struct {
+	key []byte
+}
+
+// New returns a Pseudonymizer keyed with key
+func New(key []byte) (*Pseudonymizer, error) {
+	if len(key) == 0 {
+		return nil, ErrNoKey
+	}
+	return &Pseudonymizer{key: key}, nil
+}
+
+// sum returns the HMAC of value in the domain kind, so a name and an email
+// with the same text don't share a pseudonym
+func (p *Pseudonymizer) sum(kind, value string) []byte {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(kind))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+// Name returns a generated full name for name
+func (p *Pseudonymizer) Name(name string) string {
+	f := gofakeit.New(binary.BigEndian.Uint64(p.sum("name", name)))
+	return f.FirstName() + " " + f.LastName()
+}
+
+// Email returns a pseudonymous address at example.com for email. Emails are
+// compared case-insensitively, so they map to the same pseudonym in any case,
+// and 64 bits of the HMAC keep pseudonyms unique in practice.
+func (p *Pseudonymizer) Email(email string) string {
+	sum := p.sum("email", strings.ToLower(email))
+	return "user-" + hex.EncodeToString(sum[:8]) + "@example.com"
+}
+
+// user is a row of the users table
+type user struct {
+	id        int64
+	uuid      uuid.UUID
+	tenantID  string
+	name      string
+	email     string
+	status    string
+	isAdmin   bool
+	createdAt time.Time
+	updatedAt time.Time
+}
+
+// Copy copies the live users from src to dst in batches of batchSize,
+// replacing their names and emails with pseudonyms from p, and returns the
+// number copied. Users keep their IDs, UUIDs, tenant, status and timestamps,
+// so a copy can be rerun: users already in dst are overwritten. Avatars,
+// preferences, profiles, audit entries and events are not copied, and
+// soft-deleted users are skipped.
+func Copy(ctx context.Context, src, dst *pgxpool.Pool, p *Pseudonymizer, batchSize int) (int64, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	var (
+		copied int64
+		after  int64
+	)
+	for {
+		users, err := readBatch(ctx, src, after, batchSize)
+		if err != nil {
+			return copied, err
+		}
+		if len(users) == 0 {
+			break
+		}
+
+		for i := range users {
+			users[i].name = p.Name(users[i].name)
+			users[i].email = p.Email(users[i].email)
+		}
+		if err := writeBatch(ctx, dst, users); err != nil {
+			return copied, err
+		}
+
+		copied += int64(len(users))
+		after = users[len(users)-1].id
+	}
+
+	return copied, nil
+}
+
+// allTenants begins a transaction that sees the rows of every tenant under
+// row-level security
+func allTenants(ctx context.Context, pool *pgxpool.Pool, opts pgx.TxOptions) (pgx.Tx, error) {
+	tx, err := pool.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, "SELECT set_config('app.tenant_id', $1, true)", tenant.All); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, fmt.Errorf("failed to set tenant: %w", err)
+	}
+	return tx, nil
+}
+
+// readBatch reads up to limit live users with an ID above after, by ID
+func readBatch(ctx context.Context, pool *pgxpool.Pool, after int64, limit int) ([]user, error) {
+	const query = `
+		SELECT id, uuid, tenant_id, name, email, status::text, is_admin, created_at, updated_at
+		FROM users
+		WHERE id > $1 AND deleted_at IS NULL
+		ORDER BY id
+		LIMIT $2
+	`
+
+	tx, err := allTenants(ctx, pool, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, query, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read users: %w", err)
+	}
+
+	users, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (user, error) {
+		var u user
+		err := row.Scan(&u.id, &u.uuid, &u.tenantID, &u.name, &u.email, &u.status, &u.isAdmin, &u.createdAt, &u.updatedAt)
+		return u, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read users: %w", err)
+	}
+	return users, nil
+}
+
+// writeBatch upserts users into pool in one transaction and moves the ID
+// sequence past them, so users created later don't collide
+func writeBatch(ctx context.Context, pool *pgxpool.Pool, users []user) error {
+	const query = `
+		INSERT INTO users (id, uuid, tenant_id, name, email, status, is_admin, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6::user_status, $7, $8, $9)
+		ON CONFLICT (id) DO UPDATE
+		SET uuid = EXCLUDED.uuid,
+			tenant_id = EXCLUDED.tenant_id,
+			name = EXCLUDED.name,
+			email = EXCLUDED.email,
+			status = EXCLUDED.status,
+			is_admin = EXCLUDED.is_admin,
+			created_at = EXCLUDED.created_at,
+			updated_at = EXCLUDED.updated_at,
+			deleted_at = NULL,
+			avatar_key = NULL,
+			preferences = '{}'
+	`
+
+	tx, err := allTenants(ctx, pool, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	batch := &pgx.Batch{}
+	for _, u := range users {
+		batch.Queue(query, u.id, u.uuid, u.tenantID, u.name, u.email, u.status, u.isAdmin, u.createdAt, u.updatedAt)
+	}
+	batch.Queue(`SELECT setval(pg_get_serial_sequence('users', 'id'), max(id)) FROM users`)
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to write users: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package anonymize
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRequiresKey(t *testing.T) {
+	_, err := New(nil)
+	assert.ErrorIs(t, err, ErrNoKey)
+}
+
+func TestPseudonymsAreDeterministic(t *testing.T) {
+	p, err := New([]byte("staging"))
+	require.NoError(t, err)
+	again, err := New([]byte("staging"))
+	require.NoError(t, err)
+
+	assert.Equal(t, p.Name("Jane Doe"), again.Name("Jane Doe"))
+	assert.Equal(t, p.Email("jane@example.org"), again.Email("jane@example.org"))
+	assert.Equal(t, p.Email("jane@example.org"), p.Email("Jane@Example.org"), "emails ignore case")
+
+	assert.NotEqual(t, p.Name("Jane Doe"), "Jane Doe")
+	assert.Regexp(t, `^\S+ \S+$`, p.Name("Jane Doe"))
+	assert.Regexp(t, `^user-[0-9a-f]{16}@example\.com$`, p.Email("jane@example.org"))
+}
+
+func TestPseudonymsDependOnKey(t *testing.T) {
+	a, err := New([]byte("a"))
+	require.NoError(t, err)
+	b, err := New([]byte("b"))
+	require.NoError(t, err)
+
+	assert.NotEqual(t, a.Email("jane@example.org"), b.Email("jane@example.org"))
+}
+
+func TestEmailPseudonymsAreUnique(t *testing.T) {
+	p, err := New([]byte("staging"))
+	require.NoError(t, err)
+
+	seen := map[string]bool{}
+	for i := range 10000 {
+		email := p.Email(fmt.Sprintf("user%d@example.org", i))
+		require.False(t, seen[email], "duplicate pseudonym %s", email)
+		seen[email] = true
+	}
+}
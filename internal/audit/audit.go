@@ -0,0 +1,63 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/things-kit/example-db/internal/middleware"
+	"github.com/things-kit/example-db/internal/proxy"
+	"go.uber.org/fx"
+)
+
+// ActorHeader names the caller a request acts on behalf of. The example has
+// no authentication of its own: the reverse proxy in front of it
+// authenticates callers and sets the header, which is only honored on
+// requests from a trusted proxy.
+const ActorHeader = "X-Actor"
+
+// Anonymous is recorded for requests that don't come through a trusted
+// proxy or that it names no actor for
+const Anonymous = "anonymous"
+
+// DefaultActor is recorded for changes made without an actor, such as by
+// background jobs
+const DefaultActor = "system"
+
+// Module adds the middleware that records the actor of each request. It
+// needs the *proxy.Config of proxy.Module.
+var Module = fx.Module("audit",
+	middleware.AsMiddleware(NewMiddleware),
+)
+
+type actorKey struct{}
+
+// WithActor returns a context recording who is making changes
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Actor returns the actor recorded in ctx, or DefaultActor
+func Actor(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return DefaultActor
+}
+
+// NewMiddleware records the actor named by the X-Actor header in the request
+// context. The header of requests that don't come from a trusted proxy is
+// ignored, as a client could name anyone; their actor is Anonymous.
+func NewMiddleware(proxies *proxy.Config) middleware.Middleware {
+	return middleware.Middleware{
+		Name:  "audit",
+		Order: 20,
+		Handler: func(c *gin.Context) {
+			actor := Anonymous
+			if header := c.GetHeader(ActorHeader); header != "" && proxies.Trusted(c.Request) {
+				actor = header
+			}
+			c.Request = c.Request.WithContext(WithActor(c.Request.Context(), actor))
+			c.Next()
+		},
+	}
+}
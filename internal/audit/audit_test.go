@@ -0,0 +1,36 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/things-kit/example-db/internal/proxy"
+)
+
+func TestMiddlewareRecordsActor(t *testing.T) {
+	v := viper.New()
+	v.Set("http.trusted_proxies", []string{"10.0.0.1"})
+
+	var got []string
+	engine := gin.New()
+	engine.Use(NewMiddleware(proxy.NewConfig(v)).Handler)
+	engine.GET("/", func(c *gin.Context) {
+		got = append(got, Actor(c.Request.Context()))
+	})
+
+	for _, peer := range []string{"10.0.0.1:4000", "203.0.113.1:4000"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = peer
+		req.Header.Set(ActorHeader, "alice")
+		engine.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, []string{"alice", Anonymous, Anonymous}, got, "only a trusted proxy names the actor")
+	assert.Equal(t, DefaultActor, Actor(context.Background()))
+}
@@ -0,0 +1,75 @@
+// Package clock provides the current time. Code that stores timestamps takes
+// a Clock instead of calling time.Now, so tests can fix the time with a Fake
+// and assert exact values.
+package clock
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/fx"
+)
+
+// Module provides the system Clock
+var Module = fx.Module("clock",
+	fx.Provide(New),
+)
+
+// Clock tells the current time
+type Clock interface {
+	Now() time.Time
+}
+
+// New returns the system clock
+func New() Clock {
+	return System{}
+}
+
+// System is the Clock of the system time
+type System struct{}
+
+// Now returns time.Now()
+func (System) Now() time.Time {
+	return time.Now()
+}
+
+// Or returns c, or the system clock when c is nil
+func Or(c Clock) Clock {
+	if c == nil {
+		return System{}
+	}
+	return c
+}
+
+// Fake is a Clock that only moves when told to. It is safe for concurrent use.
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFake returns a Fake set to now
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+// Now returns the time the clock is set to
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Set sets the clock to now
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = now
+}
+
+// Advance moves the clock forward by d and returns the new time
+func (f *Fake) Advance(d time.Duration) time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	return f.now
+}
@@ -0,0 +1,148 @@
+// Package config validates the configuration sections of the application
+// on startup, so a bad value stops the process with a clear message instead
+// of failing the first request that needs it.
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+)
+
+// Module checks every registered configuration section when the application
+// is built
+var Module = fx.Module("config",
+	fx.Invoke(Check),
+)
+
+// Validator is a configuration section that can check its values. Validate
+// returns one error per invalid field, joined with errors.Join.
+type Validator interface {
+	Validate() error
+}
+
+// Section is a configuration section registered with Validate
+type Section struct {
+	// Key is the viper key the section is loaded from
+	Key string
+	// Config is the loaded section
+	Config Validator
+}
+
+// Validate registers the configuration section of type T, loaded from key,
+// to be checked on startup
+func Validate[T Validator](key string) fx.Option {
+	return fx.Provide(fx.Annotate(
+		func(cfg T) Section { return Section{Key: key, Config: cfg} },
+		fx.ResultTags(`group:"config"`),
+	))
+}
+
+// Params holds the registered configuration sections
+type Params struct {
+	fx.In
+
+	Sections []Section `group:"config"`
+}
+
+// Check validates every registered section and reports all invalid fields
+// at once, prefixed by their section key
+func Check(p Params) error {
+	sections := append([]Section(nil), p.Sections...)
+	sort.Slice(sections, func(i, j int) bool {
+		return sections[i].Key < sections[j].Key
+	})
+
+	var problems []string
+	for _, s := range sections {
+		err := s.Config.Validate()
+		if err == nil {
+			continue
+		}
+		for _, line := range strings.Split(err.Error(), "\n") {
+			problems = append(problems, "  "+s.Key+"."+line)
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// Number is a numeric configuration value, including time.Duration
+type Number interface {
+	~int | ~int32 | ~int64 | ~uint32 | ~float64
+}
+
+// Required returns an error unless value is set
+func Required(field, value string) error {
+	if strings.TrimSpace(value) == "" {
+		return fmt.Errorf("%s is required", field)
+	}
+	return nil
+}
+
+// Positive returns an error unless n is greater than zero
+func Positive[N Number](field string, n N) error {
+	if n <= 0 {
+		return fmt.Errorf("%s must be positive, got %v", field, n)
+	}
+	return nil
+}
+
+// NonNegative returns an error if n is below zero
+func NonNegative[N Number](field string, n N) error {
+	if n < 0 {
+		return fmt.Errorf("%s must not be negative, got %v", field, n)
+	}
+	return nil
+}
+
+// Between returns an error unless min <= n <= max
+func Between[N Number](field string, n, min, max N) error {
+	if n < min || n > max {
+		return fmt.Errorf("%s must be between %v and %v, got %v", field, min, max, n)
+	}
+	return nil
+}
+
+// OneOf returns an error unless value is one of allowed
+func OneOf(field, value string, allowed ...string) error {
+	for _, a := range allowed {
+		if value == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s must be one of %s, got %q", field, strings.Join(allowed, ", "), value)
+}
+
+// HTTPConfig is the part of the "http" section read by the HTTP server
+// module that is checked on startup
+type HTTPConfig struct {
+	Port int `mapstructure:"port"`
+}
+
+// NewHTTPConfig loads the HTTP server port from the "http" key
+func NewHTTPConfig(v *viper.Viper) *HTTPConfig {
+	cfg := &HTTPConfig{Port: 8080}
+
+	if v != nil {
+		_ = v.UnmarshalKey("http", cfg)
+	}
+
+	return cfg
+}
+
+// Validate checks the port range
+func (c *HTTPConfig) Validate() error {
+	return Between("port", c.Port, 1, 65535)
+}
+
+// HTTP checks the "http" section of the HTTP server module
+var HTTP = fx.Options(
+	fx.Provide(NewHTTPConfig),
+	Validate[*HTTPConfig]("http"),
+)
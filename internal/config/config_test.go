@@ -0,0 +1,63 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/fx"
+)
+
+type testConfig struct {
+	DSN     string
+	Timeout time.Duration
+	Workers int
+}
+
+func (c *testConfig) Validate() error {
+	return errors.Join(
+		Required("dsn", c.DSN),
+		Positive("timeout", c.Timeout),
+		Positive("workers", c.Workers),
+	)
+}
+
+func TestCheckReportsEveryProblem(t *testing.T) {
+	err := Check(Params{Sections: []Section{
+		{Key: "http", Config: &HTTPConfig{Port: 70000}},
+		{Key: "db", Config: &testConfig{Timeout: -time.Second, Workers: 1}},
+	}})
+	assert.EqualError(t, err, `invalid configuration:
+  db.dsn is required
+  db.timeout must be positive, got -1s
+  http.port must be between 1 and 65535, got 70000`)
+
+	assert.NoError(t, Check(Params{Sections: []Section{
+		{Key: "http", Config: NewHTTPConfig(nil)},
+		{Key: "db", Config: &testConfig{DSN: "postgres://", Timeout: time.Second, Workers: 1}},
+	}}))
+}
+
+func TestModuleFailsStartup(t *testing.T) {
+	app := fx.New(
+		Module,
+		fx.Supply(&testConfig{DSN: "postgres://", Timeout: time.Second}),
+		Validate[*testConfig]("db"),
+		fx.NopLogger,
+	)
+	assert.ErrorContains(t, app.Err(), "db.workers must be positive, got 0")
+
+	app = fx.New(
+		Module,
+		fx.Supply(&testConfig{DSN: "postgres://", Timeout: time.Second, Workers: 1}),
+		Validate[*testConfig]("db"),
+		fx.NopLogger,
+	)
+	assert.NoError(t, app.Err())
+}
+
+func TestOneOf(t *testing.T) {
+	assert.NoError(t, OneOf("mode", "log", "smtp", "log"))
+	assert.EqualError(t, OneOf("mode", "mail", "smtp", "log"), `mode must be one of smtp, log, got "mail"`)
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"os"
+
+	"github.com/spf13/viper"
+	"github.com/things-kit/module/log"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Log levels of the "logging.level" setting
+const (
+	LevelDebug = "debug"
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+)
+
+// Level is the log level, which can change while the application runs
+type Level struct {
+	level zap.AtomicLevel
+}
+
+// NewLevel loads the level from "logging.level". An invalid level leaves
+// the default, info.
+func NewLevel(v *viper.Viper) *Level {
+	l := &Level{level: zap.NewAtomicLevel()}
+	_ = l.Set(readLevel(v))
+	return l
+}
+
+func readLevel(v *viper.Viper) string {
+	if v == nil || !v.IsSet("logging.level") {
+		return LevelInfo
+	}
+	return v.GetString("logging.level")
+}
+
+// Name returns the current level
+func (l *Level) Name() string {
+	return l.level.Level().String()
+}
+
+// Set changes the level
+func (l *Level) Set(name string) error {
+	if err := OneOf("level", name, LevelDebug, LevelInfo, LevelWarn, LevelError); err != nil {
+		return err
+	}
+	return l.level.UnmarshalText([]byte(name))
+}
+
+// NewLevelListener applies "logging.level" when the configuration changes
+func NewLevelListener(l *Level) Listener {
+	return Listener{
+		Name: "logging",
+		Reload: func(v *viper.Viper) error {
+			return l.Set(readLevel(v))
+		},
+	}
+}
+
+// WithLevel decorates the logger so Info and Error messages are written by
+// a JSON zap logger on stderr whose level is l. The logging module builds its
+// logger with the level set on startup, so its own level can't be lowered
+// at runtime; this one follows every reload in both directions.
+func WithLevel(logger log.Logger, l *Level) log.Logger {
+	encoder := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	return newLevelLogger(logger, zapcore.NewCore(encoder, zapcore.Lock(os.Stderr), l.level))
+}
+
+func newLevelLogger(logger log.Logger, core zapcore.Core) log.Logger {
+	return levelLogger{Logger: logger, zap: zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1))}
+}
+
+type levelLogger struct {
+	log.Logger
+	zap *zap.Logger
+}
+
+// Info implements log.Logger
+func (l levelLogger) Info(msg string, fields ...log.Field) {
+	l.zap.Info(msg, zapFields(fields)...)
+}
+
+// Error implements log.Logger
+func (l levelLogger) Error(msg string, err error, fields ...log.Field) {
+	l.zap.Error(msg, append(zapFields(fields), zap.Error(err))...)
+}
+
+func zapFields(fields []log.Field) []zap.Field {
+	out := make([]zap.Field, 0, len(fields)+1)
+	for _, f := range fields {
+		out = append(out, zap.Any(f.Key, f.Value))
+	}
+	return out
+}
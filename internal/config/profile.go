@@ -0,0 +1,95 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// EnvVar names the environment variable selecting the configuration profile
+const EnvVar = "APP_ENV"
+
+// WithProfile decorates the configuration loaded from config.yaml with the
+// profile named by APP_ENV and with environment variable overrides. The
+// logger is configured from the result, so it can't report the profile.
+func WithProfile(v *viper.Viper) (*viper.Viper, error) {
+	return LoadProfile(v, os.Getenv(EnvVar), os.LookupEnv)
+}
+
+// LoadProfile returns the settings of base, merged with config.<profile>.yaml
+// from the directory of the base config file if profile is set, and then
+// with environment variables. A setting such as db.pool.max_open_conns is
+// overridden by DB_POOL_MAX_OPEN_CONNS; only settings present in one of the
+// files can be overridden, so list every key production sets from the
+// environment in its profile.
+func LoadProfile(base *viper.Viper, profile string, lookupEnv func(string) (string, bool)) (*viper.Viper, error) {
+	merged := viper.New()
+	if err := merged.MergeConfigMap(base.AllSettings()); err != nil {
+		return nil, fmt.Errorf("failed to copy configuration: %w", err)
+	}
+
+	if profile != "" {
+		path := profilePath(base, profile)
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%s=%s but %s does not exist", EnvVar, profile, path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		merged.SetConfigType("yaml")
+		if err := merged.MergeConfig(bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	}
+
+	overrides := map[string]any{}
+	for _, key := range merged.AllKeys() {
+		name := strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+		if value, ok := lookupEnv(name); ok {
+			overrides[key] = value
+		}
+	}
+
+	// Keep the file path so the configuration can be watched for changes
+	merged.SetConfigFile(base.ConfigFileUsed())
+	return Override(merged, overrides)
+}
+
+// Override returns a copy of v with the given keys set. Sections are read
+// with UnmarshalKey, which doesn't merge values set on individual keys into
+// their section, so the copy holds everything in one map.
+func Override(v *viper.Viper, values map[string]any) (*viper.Viper, error) {
+	settings := viper.New()
+	if err := settings.MergeConfigMap(v.AllSettings()); err != nil {
+		return nil, fmt.Errorf("failed to copy configuration: %w", err)
+	}
+	for key, value := range values {
+		settings.Set(key, value)
+	}
+
+	out := viper.New()
+	if err := out.MergeConfigMap(settings.AllSettings()); err != nil {
+		return nil, fmt.Errorf("failed to apply configuration overrides: %w", err)
+	}
+	if used := v.ConfigFileUsed(); used != "" {
+		out.SetConfigFile(used)
+	}
+	return out, nil
+}
+
+// profilePath is the path of config.<profile>.yaml, next to the base config
+// file
+func profilePath(base *viper.Viper, profile string) string {
+	dir := "."
+	if used := base.ConfigFileUsed(); used != "" {
+		dir = filepath.Dir(used)
+	}
+	return filepath.Join(dir, "config."+profile+".yaml")
+}
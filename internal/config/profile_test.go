@@ -0,0 +1,85 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadBase reads config.yaml from a directory holding files
+func loadBase(t *testing.T, files map[string]string) *viper.Viper {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+	v := viper.New()
+	v.SetConfigFile(filepath.Join(dir, "config.yaml"))
+	require.NoError(t, v.ReadInConfig())
+	return v
+}
+
+func noEnv(string) (string, bool) { return "", false }
+
+func TestLoadProfile(t *testing.T) {
+	base := loadBase(t, map[string]string{
+		"config.yaml": `
+http:
+  port: 8080
+db:
+  dsn: "postgres://localhost/dev"
+  pool:
+    max_open_conns: 10
+    max_idle_conns: 2
+`,
+		"config.prod.yaml": `
+http:
+  mode: release
+db:
+  dsn: ""
+  pool:
+    max_open_conns: 50
+`,
+	})
+
+	env := map[string]string{"DB_DSN": "postgres://managed/prod", "HTTP_PORT": "9090"}
+	v, err := LoadProfile(base, "prod", func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	})
+	require.NoError(t, err)
+
+	var db struct {
+		DSN  string `mapstructure:"dsn"`
+		Pool struct {
+			MaxOpenConns int `mapstructure:"max_open_conns"`
+			MaxIdleConns int `mapstructure:"max_idle_conns"`
+		} `mapstructure:"pool"`
+	}
+	require.NoError(t, v.UnmarshalKey("db", &db))
+	assert.Equal(t, "postgres://managed/prod", db.DSN, "the environment overrides the profile")
+	assert.Equal(t, 50, db.Pool.MaxOpenConns, "the profile overrides config.yaml")
+	assert.Equal(t, 2, db.Pool.MaxIdleConns, "settings missing from the profile are kept")
+
+	http := NewHTTPConfig(v)
+	assert.Equal(t, 9090, http.Port)
+	assert.Equal(t, "release", v.GetString("http.mode"))
+}
+
+func TestLoadProfileWithoutProfile(t *testing.T) {
+	base := loadBase(t, map[string]string{"config.yaml": "health:\n  timeout: 2s\n"})
+
+	v, err := LoadProfile(base, "", noEnv)
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Second, v.GetDuration("health.timeout"))
+
+	_, err = LoadProfile(base, "staging", noEnv)
+	assert.ErrorContains(t, err, "APP_ENV=staging but")
+	assert.ErrorContains(t, err, "config.staging.yaml does not exist")
+}
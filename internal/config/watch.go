@@ -0,0 +1,173 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"github.com/things-kit/module/log"
+	"go.uber.org/fx"
+)
+
+// Reload watches the config file and its profile for changes and hands the
+// new configuration to every registered Listener. Only the settings the
+// listeners apply change at runtime; everything else needs a restart.
+var Reload = fx.Options(
+	fx.Provide(NewLevel, NewWatcher),
+	fx.Decorate(WithLevel),
+	AsListener(NewLevelListener),
+	fx.Invoke(func(lc fx.Lifecycle, w *Watcher) {
+		lc.Append(fx.Hook{OnStart: w.Start, OnStop: w.Stop})
+	}),
+)
+
+// Listener applies the runtime-tunable settings of a module when the
+// configuration changes
+type Listener struct {
+	// Name identifies the listener in logs
+	Name string
+	// Reload validates the module's sections of v and applies them. On error
+	// the module keeps its current settings.
+	Reload func(v *viper.Viper) error
+}
+
+// AsListener annotates a Listener constructor for the watcher
+func AsListener(f any) fx.Option {
+	return fx.Provide(fx.Annotate(f, fx.ResultTags(`group:"config_listeners"`)))
+}
+
+// WatcherParams holds the watcher dependencies
+type WatcherParams struct {
+	fx.In
+
+	Viper     *viper.Viper
+	Listeners []Listener `group:"config_listeners"`
+	Logger    log.Logger
+}
+
+// Watcher reloads the configuration when config.yaml or the profile file
+// changes. Like viper's WatchConfig it watches the directory rather than the
+// files, so editors that replace a file instead of writing it are noticed
+// too.
+type Watcher struct {
+	path      string
+	profile   string
+	listeners []Listener
+	log       log.Logger
+	lookupEnv func(string) (string, bool)
+	debounce  time.Duration
+
+	watcher *fsnotify.Watcher
+	wg      sync.WaitGroup
+}
+
+// NewWatcher creates a watcher for the file the configuration was read from
+func NewWatcher(p WatcherParams) *Watcher {
+	return &Watcher{
+		path:      p.Viper.ConfigFileUsed(),
+		profile:   os.Getenv(EnvVar),
+		listeners: p.Listeners,
+		log:       p.Logger,
+		lookupEnv: os.LookupEnv,
+		debounce:  100 * time.Millisecond,
+	}
+}
+
+// Start watches the config directory in the background
+func (w *Watcher) Start(context.Context) error {
+	if w.path == "" {
+		w.log.Info("Configuration reload disabled: no config file")
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := watcher.Add(filepath.Dir(w.path)); err != nil {
+		watcher.Close()
+		return err
+	}
+	w.watcher = watcher
+
+	files := map[string]bool{filepath.Clean(w.path): true}
+	if w.profile != "" {
+		base := viper.New()
+		base.SetConfigFile(w.path)
+		files[filepath.Clean(profilePath(base, w.profile))] = true
+	}
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.watch(files)
+	}()
+
+	w.log.Info("Watching configuration for changes", log.Field{Key: "path", Value: w.path})
+	return nil
+}
+
+// watch reloads once events for the watched files have settled, as an editor
+// may write a file several times when saving it
+func (w *Watcher) watch(files map[string]bool) {
+	timer := time.NewTimer(w.debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if files[filepath.Clean(event.Name)] && event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+				timer.Reset(w.debounce)
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.log.Error("Failed to watch configuration", err)
+		case <-timer.C:
+			w.Reload()
+		}
+	}
+}
+
+// Stop stops watching
+func (w *Watcher) Stop(context.Context) error {
+	if w.watcher == nil {
+		return nil
+	}
+	err := w.watcher.Close()
+	w.wg.Wait()
+	return err
+}
+
+// Reload reads the configuration again, with the same profile and
+// environment overrides as on startup, and passes it to every listener. A
+// listener that rejects it doesn't stop the others.
+func (w *Watcher) Reload() {
+	base := viper.New()
+	base.SetConfigFile(w.path)
+	if err := base.ReadInConfig(); err != nil {
+		w.log.Error("Failed to reload configuration", err)
+		return
+	}
+	v, err := LoadProfile(base, w.profile, w.lookupEnv)
+	if err != nil {
+		w.log.Error("Failed to reload configuration", err)
+		return
+	}
+
+	for _, l := range w.listeners {
+		if err := l.Reload(v); err != nil {
+			w.log.Error("Failed to apply reloaded configuration", err, log.Field{Key: "listener", Value: l.Name})
+		}
+	}
+	w.log.Info("Configuration reloaded", log.Field{Key: "listeners", Value: len(w.listeners)})
+}
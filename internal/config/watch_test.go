@@ -0,0 +1,84 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/module/log"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWatcherReloadsOnChange(t *testing.T) {
+	base := loadBase(t, map[string]string{
+		"config.yaml":     "logging:\n  level: info\n",
+		"config.dev.yaml": "db:\n  slow_query_threshold: 1s\n",
+	})
+	v, err := LoadProfile(base, "dev", noEnv)
+	require.NoError(t, err)
+	require.Equal(t, base.ConfigFileUsed(), v.ConfigFileUsed(), "the file is kept for watching")
+
+	reloaded := make(chan *viper.Viper, 10)
+	w := NewWatcher(WatcherParams{
+		Viper: v,
+		Listeners: []Listener{{
+			Name:   "test",
+			Reload: func(v *viper.Viper) error { reloaded <- v; return nil },
+		}},
+		Logger: testutil.NopLogger{},
+	})
+	w.profile, w.lookupEnv, w.debounce = "dev", noEnv, 10*time.Millisecond
+	require.NoError(t, w.Start(context.Background()))
+	t.Cleanup(func() { _ = w.Stop(context.Background()) })
+
+	dir := filepath.Dir(v.ConfigFileUsed())
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.dev.yaml"), []byte("db:\n  slow_query_threshold: 2s\n"), 0o600))
+	select {
+	case next := <-reloaded:
+		assert.Equal(t, 2*time.Second, next.GetDuration("db.slow_query_threshold"), "the profile is merged again")
+		assert.Equal(t, "info", next.GetString("logging.level"))
+	case <-time.After(5 * time.Second):
+		t.Fatal("configuration was not reloaded")
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other.yaml"), []byte("x: 1\n"), 0o600))
+	select {
+	case <-reloaded:
+		t.Fatal("other files don't trigger a reload")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestLevel(t *testing.T) {
+	v := viper.New()
+	v.Set("logging.level", "warn")
+	level := NewLevel(v)
+	core, logs := observer.New(level.level)
+	logger := newLevelLogger(nil, core)
+
+	logger.Info("dropped")
+	logger.Error("kept", nil)
+	assert.Equal(t, 0, logs.FilterMessage("dropped").Len())
+	assert.Equal(t, 1, logs.FilterMessage("kept").Len())
+
+	v.Set("logging.level", "info")
+	require.NoError(t, NewLevelListener(level).Reload(v))
+	logger.Info("kept", log.Field{Key: "id", Value: 1})
+	assert.Equal(t, 2, logs.FilterMessage("kept").Len(), "lowering the level brings Info messages back")
+	assert.Equal(t, map[string]any{"id": int64(1)}, logs.All()[1].ContextMap())
+
+	v.Set("logging.level", "error")
+	require.NoError(t, NewLevelListener(level).Reload(v))
+	logger.Info("dropped")
+	assert.Equal(t, 0, logs.FilterMessage("dropped").Len(), "raising the level drops them again")
+
+	v.Set("logging.level", "verbose")
+	assert.Error(t, NewLevelListener(level).Reload(v))
+	assert.Equal(t, LevelError, level.Name(), "an invalid level is not applied")
+}
@@ -0,0 +1,215 @@
+// Package crud implements the Create, Get, List, Update and Delete statements
+// every entity needs once, for any type described by a Table. Entity
+// repositories embed a Repo for the boilerplate and add their own queries,
+// such as the sqlc-generated ones, for everything else.
+package crud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/things-kit/example-db/internal/clock"
+)
+
+// ErrNotFound is returned when no live row has the given key
+var ErrNotFound = errors.New("not found")
+
+// DBTX is satisfied by *pgxpool.Pool and pgx.Tx
+type DBTX interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Table describes how values of T map to a table
+type Table[T any] struct {
+	// Name is the table name
+	Name string
+	// Key is the primary key column
+	Key string
+	// Columns are the columns read into a T, in the order Scan expects
+	Columns []string
+	// Scan reads a row of Columns into v
+	Scan func(row pgx.Row, v *T) error
+
+	// InsertColumns are written by Create with the values of InsertValues
+	InsertColumns []string
+	InsertValues  func(v *T) []any
+	// UpdateColumns are written by Update with the values of UpdateValues
+	UpdateColumns []string
+	UpdateValues  func(v *T) []any
+
+	// SoftDelete is a nullable timestamp column set by Delete instead of
+	// removing the row. Rows where it is set are invisible to the other
+	// methods. Leave it empty to delete rows.
+	SoftDelete string
+	// Touch is a timestamp column also set by a soft Delete, such as updated_at
+	Touch string
+
+	// Scope is a column, such as tenant_id, that restricts every statement to
+	// the rows of the caller. ScopeValue reads the caller's value from the
+	// context; Create writes it. Leave Scope empty for unscoped tables.
+	Scope      string
+	ScopeValue func(ctx context.Context) any
+}
+
+// Repo runs the CRUD statements for a Table on a DBTX
+type Repo[T any] struct {
+	table Table[T]
+	db    DBTX
+	clock clock.Clock
+}
+
+// New creates a Repo for table running on db. clk stamps soft deletes; nil
+// uses the system clock.
+func New[T any](table Table[T], db DBTX, clk clock.Clock) Repo[T] {
+	return Repo[T]{table: table, db: db, clock: clock.Or(clk)}
+}
+
+// Get retrieves the row with the given key
+func (r Repo[T]) Get(ctx context.Context, key any) (*T, error) {
+	where, args := r.where(ctx, nil, key)
+	query := r.header("get") + fmt.Sprintf("SELECT %s FROM %s%s", r.columns(), r.table.Name, where)
+
+	return r.one(r.db.QueryRow(ctx, query, args...))
+}
+
+// List retrieves every row, ordered by key
+func (r Repo[T]) List(ctx context.Context) ([]*T, error) {
+	where, args := r.where(ctx, nil)
+	query := r.header("list") + fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s",
+		r.columns(), r.table.Name, where, r.table.Key)
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*T
+	for rows.Next() {
+		item := new(T)
+		if err := r.table.Scan(rows, item); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// Create inserts v and returns the stored row
+func (r Repo[T]) Create(ctx context.Context, v *T) (*T, error) {
+	columns, args := r.table.InsertColumns, r.table.InsertValues(v)
+	if r.table.Scope != "" {
+		columns = append(columns[:len(columns):len(columns)], r.table.Scope)
+		args = append(args, r.table.ScopeValue(ctx))
+	}
+
+	query := r.header("create") + fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING %s",
+		r.table.Name, strings.Join(columns, ", "), placeholders(1, len(columns)), r.columns())
+
+	return r.one(r.db.QueryRow(ctx, query, args...))
+}
+
+// Update overwrites the update columns of the row with the given key and
+// returns the stored row
+func (r Repo[T]) Update(ctx context.Context, key any, v *T) (*T, error) {
+	set := make([]string, len(r.table.UpdateColumns))
+	for i, col := range r.table.UpdateColumns {
+		set[i] = fmt.Sprintf("%s = $%d", col, i+1)
+	}
+
+	where, args := r.where(ctx, r.table.UpdateValues(v), key)
+	query := r.header("update") + fmt.Sprintf("UPDATE %s SET %s%s RETURNING %s",
+		r.table.Name, strings.Join(set, ", "), where, r.columns())
+
+	return r.one(r.db.QueryRow(ctx, query, args...))
+}
+
+// Delete removes the row with the given key, or marks it deleted when the
+// table uses soft deletes
+func (r Repo[T]) Delete(ctx context.Context, key any) error {
+	var (
+		tag pgconn.CommandTag
+		err error
+	)
+
+	if r.table.SoftDelete == "" {
+		where, args := r.where(ctx, nil, key)
+		query := r.header("delete") + fmt.Sprintf("DELETE FROM %s%s", r.table.Name, where)
+		tag, err = r.db.Exec(ctx, query, args...)
+	} else {
+		set := r.table.SoftDelete + " = $1"
+		if r.table.Touch != "" {
+			set += ", " + r.table.Touch + " = $1"
+		}
+		where, args := r.where(ctx, []any{r.clock.Now()}, key)
+		query := r.header("delete") + fmt.Sprintf("UPDATE %s SET %s%s", r.table.Name, set, where)
+		tag, err = r.db.Exec(ctx, query, args...)
+	}
+
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// one scans a single row, mapping a missing row to ErrNotFound
+func (r Repo[T]) one(row pgx.Row) (*T, error) {
+	item := new(T)
+	if err := r.table.Scan(row, item); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return item, nil
+}
+
+// header names the statement the way sqlc does, so metrics and the slow query
+// log can tell statements apart
+func (r Repo[T]) header(op string) string {
+	return fmt.Sprintf("-- name: %s.%s\n", r.table.Name, op)
+}
+
+func (r Repo[T]) columns() string {
+	return strings.Join(r.table.Columns, ", ")
+}
+
+// where returns the WHERE clause matching key, if given, within the caller's
+// scope and excluding soft-deleted rows. Its placeholders follow args, which
+// it returns with the values appended.
+func (r Repo[T]) where(ctx context.Context, args []any, key ...any) (string, []any) {
+	var conds []string
+	for _, k := range key {
+		args = append(args, k)
+		conds = append(conds, fmt.Sprintf("%s = $%d", r.table.Key, len(args)))
+	}
+	if r.table.Scope != "" {
+		args = append(args, r.table.ScopeValue(ctx))
+		conds = append(conds, fmt.Sprintf("%s = $%d", r.table.Scope, len(args)))
+	}
+	if r.table.SoftDelete != "" {
+		conds = append(conds, r.table.SoftDelete+" IS NULL")
+	}
+
+	if len(conds) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+func placeholders(from, n int) string {
+	p := make([]string, n)
+	for i := range p {
+		p[i] = fmt.Sprintf("$%d", from+i)
+	}
+	return strings.Join(p, ", ")
+}
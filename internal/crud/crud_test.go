@@ -0,0 +1,143 @@
+package crud
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/clock"
+)
+
+type item struct {
+	ID   int64
+	Name string
+}
+
+var items = Table[item]{
+	Name:          "items",
+	Key:           "id",
+	Columns:       []string{"id", "name"},
+	Scan:          func(row pgx.Row, v *item) error { return row.Scan(&v.ID, &v.Name) },
+	InsertColumns: []string{"name"},
+	InsertValues:  func(v *item) []any { return []any{v.Name} },
+	UpdateColumns: []string{"name"},
+	UpdateValues:  func(v *item) []any { return []any{v.Name} },
+	SoftDelete:    "deleted_at",
+	Touch:         "updated_at",
+}
+
+// recordDB records the last statement and answers with a fixed row or
+// affected row count
+type recordDB struct {
+	sql      string
+	args     []any
+	affected int64
+	noRows   bool
+}
+
+func (d *recordDB) Exec(_ context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	d.sql, d.args = sql, args
+	if d.affected == 0 {
+		return pgconn.NewCommandTag("UPDATE 0"), nil
+	}
+	return pgconn.NewCommandTag("UPDATE 1"), nil
+}
+
+func (d *recordDB) Query(context.Context, string, ...any) (pgx.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func (d *recordDB) QueryRow(_ context.Context, sql string, args ...any) pgx.Row {
+	d.sql, d.args = sql, args
+	return d
+}
+
+func (d *recordDB) Scan(dest ...any) error {
+	if d.noRows {
+		return pgx.ErrNoRows
+	}
+	*dest[0].(*int64) = 1
+	*dest[1].(*string) = "widget"
+	return nil
+}
+
+func TestRepoStatements(t *testing.T) {
+	ctx := context.Background()
+	d := &recordDB{affected: 1}
+	repo := New(items, d, nil)
+
+	got, err := repo.Get(ctx, int64(1))
+	require.NoError(t, err)
+	assert.Equal(t, &item{ID: 1, Name: "widget"}, got)
+	assert.Equal(t, "-- name: items.get\nSELECT id, name FROM items WHERE id = $1 AND deleted_at IS NULL", d.sql)
+
+	_, err = repo.Create(ctx, &item{Name: "widget"})
+	require.NoError(t, err)
+	assert.Equal(t, "-- name: items.create\nINSERT INTO items (name) VALUES ($1) RETURNING id, name", d.sql)
+	assert.Equal(t, []any{"widget"}, d.args)
+
+	_, err = repo.Update(ctx, int64(1), &item{Name: "gadget"})
+	require.NoError(t, err)
+	assert.Equal(t, "-- name: items.update\nUPDATE items SET name = $1 WHERE id = $2 AND deleted_at IS NULL RETURNING id, name", d.sql)
+	assert.Equal(t, []any{"gadget", int64(1)}, d.args)
+
+	require.NoError(t, repo.Delete(ctx, int64(1)))
+	assert.Equal(t, "-- name: items.delete\nUPDATE items SET deleted_at = $1, updated_at = $1 WHERE id = $2 AND deleted_at IS NULL", d.sql)
+}
+
+func TestRepoDeleteUsesClock(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	d := &recordDB{affected: 1}
+	require.NoError(t, New(items, d, clock.NewFake(now)).Delete(context.Background(), int64(1)))
+	assert.Equal(t, []any{now, int64(1)}, d.args)
+}
+
+func TestRepoNotFound(t *testing.T) {
+	ctx := context.Background()
+	repo := New(items, &recordDB{noRows: true}, nil)
+
+	_, err := repo.Get(ctx, int64(1))
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, repo.Delete(ctx, int64(1)), ErrNotFound)
+
+	hard := items
+	hard.SoftDelete = ""
+	d := &recordDB{affected: 1}
+	require.NoError(t, New(hard, d, nil).Delete(ctx, int64(1)))
+	assert.Equal(t, "-- name: items.delete\nDELETE FROM items WHERE id = $1", d.sql)
+}
+
+type scopeKey struct{}
+
+func TestRepoScope(t *testing.T) {
+	ctx := context.WithValue(context.Background(), scopeKey{}, "acme")
+	scoped := items
+	scoped.Scope = "tenant_id"
+	scoped.ScopeValue = func(ctx context.Context) any { return ctx.Value(scopeKey{}) }
+
+	d := &recordDB{affected: 1}
+	repo := New(scoped, d, nil)
+
+	_, err := repo.Get(ctx, int64(1))
+	require.NoError(t, err)
+	assert.Equal(t, "-- name: items.get\nSELECT id, name FROM items WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL", d.sql)
+	assert.Equal(t, []any{int64(1), "acme"}, d.args)
+
+	_, err = repo.Create(ctx, &item{Name: "widget"})
+	require.NoError(t, err)
+	assert.Equal(t, "-- name: items.create\nINSERT INTO items (name, tenant_id) VALUES ($1, $2) RETURNING id, name", d.sql)
+	assert.Equal(t, []any{"widget", "acme"}, d.args)
+
+	_, err = repo.Update(ctx, int64(1), &item{Name: "gadget"})
+	require.NoError(t, err)
+	assert.Equal(t, "-- name: items.update\nUPDATE items SET name = $1 WHERE id = $2 AND tenant_id = $3 AND deleted_at IS NULL RETURNING id, name", d.sql)
+
+	require.NoError(t, repo.Delete(ctx, int64(1)))
+	assert.Equal(t, "-- name: items.delete\nUPDATE items SET deleted_at = $1, updated_at = $1 WHERE id = $2 AND tenant_id = $3 AND deleted_at IS NULL", d.sql)
+	assert.Equal(t, "acme", d.args[2])
+}
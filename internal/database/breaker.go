@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sony/gobreaker"
+	"github.com/things-kit/example-db/internal/health"
+	"github.com/things-kit/module/log"
+)
+
+// ErrUnavailable is returned without touching the database while the circuit
+// breaker is open, or half-open with its probe already in flight
+var ErrUnavailable = errors.New("database unavailable")
+
+// BreakerConfig controls the circuit breaker around the database
+type BreakerConfig struct {
+	// Enabled turns the breaker on
+	Enabled bool `mapstructure:"enabled"`
+	// FailureThreshold is the number of consecutive failures that opens the breaker
+	FailureThreshold uint32 `mapstructure:"failure_threshold"`
+	// OpenTimeout is how long the breaker stays open before probing again
+	OpenTimeout time.Duration `mapstructure:"open_timeout"`
+	// HalfOpenRequests is the number of probe calls let through while half-open
+	HalfOpenRequests uint32 `mapstructure:"half_open_requests"`
+}
+
+// Breaker fails calls fast while the database is down instead of letting them
+// queue for connections. Only errors that mean the database is unreachable or
+// overloaded count as failures; "not found" and constraint violations don't.
+// A nil *Breaker runs every call.
+type Breaker struct {
+	cb    *gobreaker.CircuitBreaker
+	state prometheus.Gauge
+}
+
+// NewBreaker creates the circuit breaker from the "db.breaker" configuration.
+// It returns nil when the breaker is disabled.
+func NewBreaker(cfg *Config, logger log.Logger) *Breaker {
+	if !cfg.Breaker.Enabled {
+		return nil
+	}
+
+	b := &Breaker{
+		state: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "db_circuit_breaker_state",
+			Help: "State of the database circuit breaker: 0 closed, 1 half-open, 2 open.",
+		}),
+	}
+
+	b.cb = gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		Name:        "postgres",
+		MaxRequests: cfg.Breaker.HalfOpenRequests,
+		Timeout:     cfg.Breaker.OpenTimeout,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= cfg.Breaker.FailureThreshold
+		},
+		IsSuccessful: func(err error) bool {
+			return err == nil || !(IsTransient(err) || IsTimeout(err))
+		},
+		OnStateChange: func(_ string, from, to gobreaker.State) {
+			b.state.Set(float64(to))
+			logger.Info("Database circuit breaker changed state",
+				log.Field{Key: "from", Value: from.String()},
+				log.Field{Key: "to", Value: to.String()},
+			)
+		},
+	})
+
+	return b
+}
+
+// Do calls fn unless the breaker is open, in which case it returns ErrUnavailable
+func (b *Breaker) Do(fn func() error) error {
+	if b == nil {
+		return fn()
+	}
+
+	_, err := b.cb.Execute(func() (any, error) {
+		return nil, fn()
+	})
+	if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+		return fmt.Errorf("%w: circuit breaker %s", ErrUnavailable, b.cb.State())
+	}
+	return err
+}
+
+// State returns the breaker state: "closed", "half-open" or "open"
+func (b *Breaker) State() string {
+	if b == nil {
+		return gobreaker.StateClosed.String()
+	}
+	return b.cb.State().String()
+}
+
+// Collectors returns the Prometheus collectors for the breaker state
+func (b *Breaker) Collectors() []prometheus.Collector {
+	if b == nil {
+		return nil
+	}
+	return []prometheus.Collector{b.state}
+}
+
+// NewBreakerCheck reports the database as down while the breaker is open.
+// The check is skipped when the breaker is disabled.
+func NewBreakerCheck(b *Breaker) health.Check {
+	check := health.Check{Name: "postgres_circuit_breaker"}
+	if b == nil {
+		return check
+	}
+
+	check.Func = func(context.Context) error {
+		if state := b.cb.State(); state == gobreaker.StateOpen {
+			return fmt.Errorf("circuit breaker %s", state)
+		}
+		return nil
+	}
+	return check
+}
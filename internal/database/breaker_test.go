@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	kittest "github.com/things-kit/example-db/internal/testutil"
+)
+
+func TestBreakerOpensOnUnavailableDatabase(t *testing.T) {
+	b := NewBreaker(&Config{Breaker: BreakerConfig{
+		Enabled:          true,
+		FailureThreshold: 2,
+		OpenTimeout:      20 * time.Millisecond,
+		HalfOpenRequests: 1,
+	}}, kittest.NopLogger{})
+	check := NewBreakerCheck(b)
+
+	notFound := errors.New("user not found")
+	for range 3 {
+		assert.ErrorIs(t, b.Do(func() error { return notFound }), notFound)
+	}
+	assert.Equal(t, "closed", b.State(), "ordinary errors don't trip the breaker")
+
+	down := &pgconn.PgError{Code: "57P03"}
+	for range 2 {
+		_ = b.Do(func() error { return down })
+	}
+	assert.Equal(t, "open", b.State())
+	assert.Error(t, check.Func(context.Background()))
+
+	calls := 0
+	err := b.Do(func() error { calls++; return nil })
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.Zero(t, calls, "an open breaker fails fast")
+
+	time.Sleep(30 * time.Millisecond)
+	assert.NoError(t, b.Do(func() error { calls++; return nil }), "a half-open probe is let through")
+	assert.Equal(t, "closed", b.State())
+	assert.NoError(t, check.Func(context.Background()))
+}
+
+func TestDisabledBreaker(t *testing.T) {
+	b := NewBreaker(&Config{}, kittest.NopLogger{})
+	assert.Nil(t, b)
+	assert.NoError(t, b.Do(func() error { return nil }))
+	assert.Nil(t, NewBreakerCheck(b).Func)
+}
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrOverloaded is returned when no query slot frees up within the limiter's
+// wait time
+var ErrOverloaded = errors.New("database overloaded")
+
+// LimiterConfig bounds the number of database calls in flight
+type LimiterConfig struct {
+	// MaxInFlight caps concurrent repository calls; 0 disables the limiter
+	MaxInFlight int `mapstructure:"max_in_flight"`
+	// MaxWait is how long a call waits for a free slot before it is rejected
+	MaxWait time.Duration `mapstructure:"max_wait"`
+}
+
+// Limiter is a semaphore in front of the repository. It keeps bursts from
+// queueing on the connection pool by rejecting calls that can't get a slot
+// quickly. A nil *Limiter admits every call.
+type Limiter struct {
+	state    atomic.Pointer[limiterState]
+	inFlight prometheus.Gauge
+	rejected prometheus.Counter
+}
+
+// limiterState is replaced as a whole on Resize; calls release the slot to
+// the state they acquired it from
+type limiterState struct {
+	slots   chan struct{}
+	maxWait time.Duration
+}
+
+// NewLimiter creates the limiter from the "db.limiter" configuration.
+// It returns nil when the limiter is disabled.
+func NewLimiter(cfg *Config) *Limiter {
+	if cfg.Limiter.MaxInFlight <= 0 {
+		return nil
+	}
+
+	l := &Limiter{
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "db_limiter_in_flight",
+			Help: "Repository calls currently holding a limiter slot.",
+		}),
+		rejected: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "db_limiter_rejected_total",
+			Help: "Repository calls rejected because no limiter slot freed up in time.",
+		}),
+	}
+	l.state.Store(&limiterState{
+		slots:   make(chan struct{}, cfg.Limiter.MaxInFlight),
+		maxWait: cfg.Limiter.MaxWait,
+	})
+	return l
+}
+
+// Resize applies a new limit and wait time. Calls already holding a slot
+// keep it, so until they finish up to the old and new limits combined may be
+// in flight. A disabled limiter can't be enabled without a restart.
+func (l *Limiter) Resize(cfg LimiterConfig) error {
+	if l == nil {
+		if cfg.MaxInFlight > 0 {
+			return errors.New("limiter is disabled, restart to enable it")
+		}
+		return nil
+	}
+	if cfg.MaxInFlight <= 0 {
+		return errors.New("limiter can't be disabled, restart to disable it")
+	}
+
+	current := l.state.Load()
+	if cap(current.slots) == cfg.MaxInFlight && current.maxWait == cfg.MaxWait {
+		return nil
+	}
+	l.state.Store(&limiterState{
+		slots:   make(chan struct{}, cfg.MaxInFlight),
+		maxWait: cfg.MaxWait,
+	})
+	return nil
+}
+
+// Do runs fn once a slot is free. It returns ErrOverloaded if none frees up
+// within the wait time, or the context error if ctx is done first.
+func (l *Limiter) Do(ctx context.Context, fn func() error) error {
+	if l == nil {
+		return fn()
+	}
+
+	state := l.state.Load()
+	select {
+	case state.slots <- struct{}{}:
+	default:
+		timer := time.NewTimer(state.maxWait)
+		defer timer.Stop()
+
+		select {
+		case state.slots <- struct{}{}:
+		case <-timer.C:
+			l.rejected.Inc()
+			return ErrOverloaded
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	l.inFlight.Inc()
+	defer func() {
+		l.inFlight.Dec()
+		<-state.slots
+	}()
+
+	return fn()
+}
+
+// Collectors returns the Prometheus collectors for the limiter
+func (l *Limiter) Collectors() []prometheus.Collector {
+	if l == nil {
+		return nil
+	}
+	return []prometheus.Collector{l.inFlight, l.rejected}
+}
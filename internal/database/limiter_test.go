@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiterRejectsExcessCalls(t *testing.T) {
+	l := NewLimiter(&Config{Limiter: LimiterConfig{MaxInFlight: 1, MaxWait: 10 * time.Millisecond}})
+
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- l.Do(context.Background(), func() error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	calls := 0
+	err := l.Do(context.Background(), func() error { calls++; return nil })
+	assert.ErrorIs(t, err, ErrOverloaded)
+	assert.Zero(t, calls)
+	assert.Equal(t, 1.0, testutil.ToFloat64(l.rejected))
+
+	close(release)
+	assert.NoError(t, <-done)
+	assert.NoError(t, l.Do(context.Background(), func() error { calls++; return nil }), "the slot is freed")
+	assert.Equal(t, 1, calls)
+	assert.Zero(t, testutil.ToFloat64(l.inFlight))
+}
+
+func TestDisabledLimiter(t *testing.T) {
+	l := NewLimiter(&Config{})
+	assert.Nil(t, l)
+	assert.NoError(t, l.Do(context.Background(), func() error { return nil }))
+}
+
+func TestLimiterResize(t *testing.T) {
+	l := NewLimiter(&Config{Limiter: LimiterConfig{MaxInFlight: 1, MaxWait: 10 * time.Millisecond}})
+
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- l.Do(context.Background(), func() error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	assert.NoError(t, l.Resize(LimiterConfig{MaxInFlight: 2, MaxWait: 10 * time.Millisecond}))
+	calls := 0
+	assert.NoError(t, l.Do(context.Background(), func() error { calls++; return nil }), "the new limit applies")
+	assert.Equal(t, 1, calls)
+
+	close(release)
+	assert.NoError(t, <-done, "the call holding an old slot releases it")
+	assert.Zero(t, testutil.ToFloat64(l.inFlight))
+
+	assert.Error(t, l.Resize(LimiterConfig{}))
+	var disabled *Limiter
+	assert.Error(t, disabled.Resize(LimiterConfig{MaxInFlight: 1}))
+	assert.NoError(t, disabled.Resize(LimiterConfig{}))
+}
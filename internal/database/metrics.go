@@ -0,0 +1,32 @@
+package database
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RegisterMetrics exports the effective pool settings as gauges labelled by
+// pool, so dashboards can compare usage against the configured limits, along
+// with the retry, circuit breaker and limiter metrics
+func RegisterMetrics(reg *prometheus.Registry, cfg *Config, retrier *Retrier, breaker *Breaker, limiter *Limiter) {
+	reg.MustRegister(retrier.Collectors()...)
+	reg.MustRegister(breaker.Collectors()...)
+	reg.MustRegister(limiter.Collectors()...)
+
+	gauge := func(name, help string) *prometheus.GaugeVec {
+		g := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: help}, []string{"pool"})
+		reg.MustRegister(g)
+		return g
+	}
+
+	maxOpen := gauge("db_pool_max_open_connections", "Configured maximum open connections.")
+	maxIdle := gauge("db_pool_max_idle_connections", "Configured maximum idle connections.")
+	lifetime := gauge("db_pool_conn_max_lifetime_seconds", "Configured maximum connection lifetime.")
+	idleTime := gauge("db_pool_conn_max_idle_time_seconds", "Configured maximum connection idle time.")
+
+	for _, pool := range []string{"pgx", "sql"} {
+		maxOpen.WithLabelValues(pool).Set(float64(cfg.Pool.MaxOpenConns))
+		lifetime.WithLabelValues(pool).Set(cfg.Pool.ConnMaxLifetime.Seconds())
+		idleTime.WithLabelValues(pool).Set(cfg.Pool.ConnMaxIdleTime.Seconds())
+	}
+	maxIdle.WithLabelValues("sql").Set(float64(cfg.Pool.MaxIdleConns))
+}
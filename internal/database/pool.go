@@ -0,0 +1,165 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/exaring/otelpgx"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/multitracer"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/spf13/viper"
+	"github.com/things-kit/example-db/internal/config"
+	"go.uber.org/fx"
+)
+
+// PasswordFunc returns the current database password
+type PasswordFunc func(ctx context.Context) (string, error)
+
+// PoolParams holds the pool dependencies. Password and QueryLog may be nil.
+type PoolParams struct {
+	fx.In
+
+	Lifecycle fx.Lifecycle
+	Config    *Config
+	Password  PasswordFunc `optional:"true"`
+	QueryLog  *QueryLog    `optional:"true"`
+}
+
+// NewPool creates the pgx connection pool for the primary with the configured
+// limits. The pool is pinged on start and closed on stop. With a Password,
+// every new connection asks it for the password, so a rotated password is
+// picked up without a restart.
+func NewPool(p PoolParams) (*pgxpool.Pool, error) {
+	cfg := p.Config
+	poolCfg, err := newPoolConfig(cfg.DSN, cfg, p.QueryLog, p.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connection pool: %w", err)
+	}
+
+	p.Lifecycle.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			if err := pool.Ping(ctx); err != nil {
+				return fmt.Errorf("failed to ping database: %w", err)
+			}
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			pool.Close()
+			return nil
+		},
+	})
+
+	return pool, nil
+}
+
+// newPoolConfig parses dsn and applies the pool limits, statement timeout and
+// session time zone. With a password, new connections use the password it
+// returns rather than the one in dsn.
+// Statements are traced through the global OpenTelemetry provider, which is a
+// no-op unless tracing is enabled, and logged by queryLog while it is on.
+func newPoolConfig(dsn string, cfg *Config, queryLog *QueryLog, password PasswordFunc) (*pgxpool.Config, error) {
+	poolCfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database DSN: %w", err)
+	}
+
+	poolCfg.ConnConfig.Tracer = otelpgx.NewTracer()
+	if queryLog != nil {
+		poolCfg.ConnConfig.Tracer = multitracer.New(poolCfg.ConnConfig.Tracer, queryLog)
+	}
+	poolCfg.MaxConns = int32(cfg.Pool.MaxOpenConns)
+	poolCfg.MaxConnLifetime = cfg.Pool.ConnMaxLifetime
+	poolCfg.MaxConnIdleTime = cfg.Pool.ConnMaxIdleTime
+
+	// The server enforces the timeout as well, so statements are cancelled
+	// even if the client stops waiting without cancelling them
+	if cfg.StatementTimeout > 0 {
+		poolCfg.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+	}
+
+	// The TIMESTAMP columns hold UTC: CURRENT_TIMESTAMP defaults and
+	// comparisons with now() must not depend on the server's time zone
+	poolCfg.ConnConfig.RuntimeParams["timezone"] = "UTC"
+
+	if password != nil {
+		poolCfg.BeforeConnect = func(ctx context.Context, cc *pgx.ConnConfig) error {
+			p, err := password(ctx)
+			if err != nil {
+				return err
+			}
+			cc.Password = p
+			return nil
+		}
+	}
+
+	if cfg.RowLevelSecurity {
+		poolCfg.BeforeAcquire = setTenant
+	}
+
+	return poolCfg, nil
+}
+
+// WithPassword sets the password of db.dsn to db.password, if set, so the
+// DSN in the config file can leave the password out. Both DSN formats are
+// supported: postgres:// URLs and key=value connection strings.
+func WithPassword(v *viper.Viper) (*viper.Viper, error) {
+	password := v.GetString("db.password")
+	if password == "" {
+		return v, nil
+	}
+
+	dsn, err := dsnWithPassword(v.GetString("db.dsn"), password)
+	if err != nil {
+		return nil, err
+	}
+	return config.Override(v, map[string]any{"db.dsn": dsn})
+}
+
+// WithUTC sets the session time zone of db.dsn to UTC, as newPoolConfig does
+// for the pgx pools, so the database/sql pool opened on the same DSN writes
+// and compares the TIMESTAMP columns in UTC as well
+func WithUTC(v *viper.Viper) (*viper.Viper, error) {
+	dsn, err := dsnWithUTC(v.GetString("db.dsn"))
+	if err != nil {
+		return nil, err
+	}
+	return config.Override(v, map[string]any{"db.dsn": dsn})
+}
+
+func dsnWithUTC(dsn string) (string, error) {
+	if strings.Contains(dsn, "://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse database DSN: %w", err)
+		}
+		q := u.Query()
+		q.Set("timezone", "UTC")
+		u.RawQuery = q.Encode()
+		return u.String(), nil
+	}
+
+	return dsn + " timezone=UTC", nil
+}
+
+func dsnWithPassword(dsn, password string) (string, error) {
+	if strings.Contains(dsn, "://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse database DSN: %w", err)
+		}
+		u.User = url.UserPassword(u.User.Username(), password)
+		return u.String(), nil
+	}
+
+	quoted := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(password)
+	return dsn + " password='" + quoted + "'", nil
+}
@@ -0,0 +1,237 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/things-kit/example-db/internal/reqlog"
+	"github.com/things-kit/module/log"
+)
+
+// redacted replaces a masked statement argument
+const redacted = "[REDACTED]"
+
+// maxLoggedArg truncates long string arguments
+const maxLoggedArg = 100
+
+// QueryLog is a pgx tracer that logs every statement run on the pgx pools
+// with its duration and arguments while db.log_queries is on. It is meant for
+// tracing SQL locally: arguments bound to email, password, secret or token
+// columns and strings that look like an email address are masked, other
+// arguments are logged as they are.
+type QueryLog struct {
+	log     log.Logger
+	enabled atomic.Bool
+}
+
+// NewQueryLog creates the query log, enabled by db.log_queries
+func NewQueryLog(cfg *Config, logger log.Logger) *QueryLog {
+	q := &QueryLog{log: logger}
+	q.SetEnabled(cfg.LogQueries)
+	return q
+}
+
+// SetEnabled turns logging on or off. A nil *QueryLog stays off.
+func (q *QueryLog) SetEnabled(enabled bool) {
+	if q != nil {
+		q.enabled.Store(enabled)
+	}
+}
+
+func (q *QueryLog) on() bool {
+	return q != nil && q.enabled.Load()
+}
+
+// tracedStatement is a statement being run, kept in its context
+type tracedStatement struct {
+	sql   string
+	args  []any
+	start time.Time
+}
+
+type tracedStatementKey struct{}
+
+// TraceQueryStart implements pgx.QueryTracer
+func (q *QueryLog) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	if !q.on() {
+		return ctx
+	}
+	return context.WithValue(ctx, tracedStatementKey{}, &tracedStatement{sql: data.SQL, args: data.Args, start: time.Now()})
+}
+
+// TraceQueryEnd implements pgx.QueryTracer
+func (q *QueryLog) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	if s, ok := ctx.Value(tracedStatementKey{}).(*tracedStatement); ok {
+		q.write(ctx, s.sql, s.args, time.Since(s.start), data.CommandTag.RowsAffected(), data.Err)
+	}
+}
+
+// TraceBatchStart implements pgx.BatchTracer. The statements of a batch are
+// sent together, so each is logged with the time since the previous one
+// completed.
+func (q *QueryLog) TraceBatchStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceBatchStartData) context.Context {
+	if !q.on() {
+		return ctx
+	}
+	return context.WithValue(ctx, tracedStatementKey{}, &tracedStatement{start: time.Now()})
+}
+
+// TraceBatchQuery implements pgx.BatchTracer
+func (q *QueryLog) TraceBatchQuery(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchQueryData) {
+	if s, ok := ctx.Value(tracedStatementKey{}).(*tracedStatement); ok {
+		now := time.Now()
+		q.write(ctx, data.SQL, data.Args, now.Sub(s.start), data.CommandTag.RowsAffected(), data.Err)
+		s.start = now
+	}
+}
+
+// TraceBatchEnd implements pgx.BatchTracer
+func (q *QueryLog) TraceBatchEnd(context.Context, *pgx.Conn, pgx.TraceBatchEndData) {}
+
+// TraceCopyFromStart implements pgx.CopyFromTracer. The copied rows are not
+// logged.
+func (q *QueryLog) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	if !q.on() {
+		return ctx
+	}
+	sql := fmt.Sprintf("COPY %s (%s) FROM STDIN", data.TableName.Sanitize(), strings.Join(data.ColumnNames, ", "))
+	return context.WithValue(ctx, tracedStatementKey{}, &tracedStatement{sql: sql, start: time.Now()})
+}
+
+// TraceCopyFromEnd implements pgx.CopyFromTracer
+func (q *QueryLog) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	if s, ok := ctx.Value(tracedStatementKey{}).(*tracedStatement); ok {
+		q.write(ctx, s.sql, nil, time.Since(s.start), data.CommandTag.RowsAffected(), data.Err)
+	}
+}
+
+func (q *QueryLog) write(ctx context.Context, sql string, args []any, elapsed time.Duration, rows int64, err error) {
+	fields := []log.Field{
+		{Key: "sql", Value: compactSQL(sql)},
+		{Key: "args", Value: redactArgs(sql, args)},
+		{Key: "duration_ms", Value: float64(elapsed.Microseconds()) / 1000},
+		{Key: "rows", Value: rows},
+	}
+	if m := queryNamePattern.FindStringSubmatch(sql); m != nil {
+		fields = append(fields, log.Field{Key: "query", Value: m[1]})
+	}
+
+	logger := reqlog.FromContext(ctx, q.log)
+	if err != nil {
+		logger.Error("Query failed", err, fields...)
+		return
+	}
+	logger.Info("Query", fields...)
+}
+
+var (
+	queryNamePattern = regexp.MustCompile(`--\s*name:\s*([\w.]+)`)
+	commentPattern   = regexp.MustCompile(`(?m)^\s*--.*$`)
+	// emailPattern matches argument values that look like an email address
+	emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	// sensitiveColumn matches column names whose values are masked
+	sensitiveColumn = regexp.MustCompile(`(?i)email|password|secret|token`)
+	// comparedParam matches a parameter assigned to or compared with a
+	// column, such as "email = $2" or "lower(email) = lower($1)"
+	comparedParam = regexp.MustCompile(`(?i)([\w.]+)\)?\s*(?:=|<>|!=|\blike\b|\bilike\b)\s*(?:\w+\()?\$(\d+)`)
+	// insertParams matches the column and value lists of an INSERT
+	insertParams = regexp.MustCompile(`(?is)insert\s+into\s+[\w.]+\s*\(([^)]*)\)\s*values\s*\(([^)]*)\)`)
+	paramPattern = regexp.MustCompile(`^\$(\d+)$`)
+)
+
+// compactSQL returns a statement on one line, without its comments
+func compactSQL(sql string) string {
+	return strings.Join(strings.Fields(commentPattern.ReplaceAllString(sql, "")), " ")
+}
+
+// redactArgs renders the arguments of a statement for logging. Arguments bound
+// to a sensitive column in sql and strings that look like an email address
+// are replaced by [REDACTED].
+func redactArgs(sql string, args []any) []string {
+	sensitive := sensitiveParams(sql)
+	out := make([]string, len(args))
+	for i, arg := range args {
+		if sensitive[i+1] {
+			out[i] = redacted
+			continue
+		}
+		out[i] = formatArg(arg)
+	}
+	return out
+}
+
+// sensitiveParams returns the numbers of the parameters of sql that are bound
+// to a sensitive column
+func sensitiveParams(sql string) map[int]bool {
+	params := map[int]bool{}
+	mark := func(column, param string) {
+		if !sensitiveColumn.MatchString(column) {
+			return
+		}
+		if n, err := strconv.Atoi(param); err == nil {
+			params[n] = true
+		}
+	}
+
+	for _, m := range comparedParam.FindAllStringSubmatch(sql, -1) {
+		mark(m[1], m[2])
+	}
+	for _, m := range insertParams.FindAllStringSubmatch(sql, -1) {
+		columns, values := strings.Split(m[1], ","), strings.Split(m[2], ",")
+		if len(columns) != len(values) {
+			continue
+		}
+		for i, v := range values {
+			if p := paramPattern.FindStringSubmatch(strings.TrimSpace(v)); p != nil {
+				mark(columns[i], p[1])
+			}
+		}
+	}
+	return params
+}
+
+// formatArg renders an argument, masking strings that look like an email
+func formatArg(arg any) string {
+	switch v := arg.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return formatString(v)
+	case []string:
+		out := make([]string, len(v))
+		for i, s := range v {
+			out[i] = formatString(s)
+		}
+		return "[" + strings.Join(out, " ") + "]"
+	case []byte:
+		return fmt.Sprintf("<%d bytes>", len(v))
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case driver.Valuer:
+		// Such as the pgtype values sqlc uses for nullable columns
+		value, err := v.Value()
+		if err != nil {
+			return fmt.Sprintf("<%T>", v)
+		}
+		return formatArg(value)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func formatString(s string) string {
+	if emailPattern.MatchString(s) {
+		return redacted
+	}
+	if len(s) > maxLoggedArg {
+		s = s[:maxLoggedArg] + "..."
+	}
+	return strconv.Quote(s)
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/reqlog"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/module/log"
+)
+
+// recordingLogger keeps the message and fields of every Info and Error
+type recordingLogger struct {
+	testutil.NopLogger
+	entries []map[string]any
+}
+
+func (l *recordingLogger) Info(msg string, fields ...log.Field) {
+	l.record(msg, nil, fields)
+}
+
+func (l *recordingLogger) Error(msg string, err error, fields ...log.Field) {
+	l.record(msg, err, fields)
+}
+
+func (l *recordingLogger) record(msg string, err error, fields []log.Field) {
+	m := map[string]any{"msg": msg, "error": err}
+	for _, f := range fields {
+		m[f.Key] = f.Value
+	}
+	l.entries = append(l.entries, m)
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT id, name, email
+FROM users
+WHERE email = $1 AND tenant_id = $2 AND deleted_at IS NULL
+`
+
+func TestQueryLog(t *testing.T) {
+	logger := &recordingLogger{}
+	q := NewQueryLog(&Config{}, logger)
+
+	run := func(ctx context.Context, sql string, args []any, err error) {
+		ctx = q.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: sql, Args: args})
+		q.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("SELECT 1"), Err: err})
+	}
+
+	run(context.Background(), getUserByEmail, []any{"ann@example.com", "default"}, nil)
+	assert.Empty(t, logger.entries, "the log is off by default")
+
+	q.SetEnabled(true)
+	ctx := reqlog.WithFields(context.Background(), log.Field{Key: "request_id", Value: "req-1"})
+	run(ctx, getUserByEmail, []any{"Ann@Example.com", "default"}, nil)
+	run(ctx, "SELECT 1 WHERE $1", []any{true}, errors.New("boom"))
+
+	require.Len(t, logger.entries, 2)
+	entry := logger.entries[0]
+	assert.Equal(t, "Query", entry["msg"])
+	assert.Equal(t, "SELECT id, name, email FROM users WHERE email = $1 AND tenant_id = $2 AND deleted_at IS NULL", entry["sql"])
+	assert.Equal(t, []string{"[REDACTED]", `"default"`}, entry["args"])
+	assert.Equal(t, "GetUserByEmail", entry["query"])
+	assert.EqualValues(t, 1, entry["rows"])
+	assert.Equal(t, "req-1", entry["request_id"])
+	assert.IsType(t, float64(0), entry["duration_ms"])
+
+	assert.Equal(t, "Query failed", logger.entries[1]["msg"])
+	assert.EqualError(t, logger.entries[1]["error"].(error), "boom")
+	assert.Nil(t, logger.entries[1]["query"])
+
+	q.SetEnabled(false)
+	run(ctx, getUserByEmail, []any{"ann@example.com", "default"}, nil)
+	assert.Len(t, logger.entries, 2)
+}
+
+func TestRedactArgs(t *testing.T) {
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := map[string]struct {
+		sql  string
+		args []any
+		want []string
+	}{
+		"Insert": {
+			sql:  "INSERT INTO users (name, email, password_hash, created_at) VALUES ($1, $2, $3, $4)",
+			args: []any{"Ann", "not-an-email", "hash", at},
+			want: []string{`"Ann"`, "[REDACTED]", "[REDACTED]", "2024-01-02T03:04:05Z"},
+		},
+		"Update": {
+			sql:  "UPDATE users SET name = $1, email = $2 WHERE id = $3",
+			args: []any{"Ann", "x", int64(42)},
+			want: []string{`"Ann"`, "[REDACTED]", "42"},
+		},
+		"Function": {
+			sql:  "SELECT id FROM users WHERE lower(email) = lower($1) OR api_token = ANY($2)",
+			args: []any{"x", []string{"a"}},
+			want: []string{"[REDACTED]", "[REDACTED]"},
+		},
+		"EmailValues": {
+			sql:  "SELECT $1, $2, $3",
+			args: []any{"ann@example.com", []string{"bob@example.com", "Bob"}, pgtype.Text{String: "eve@example.com", Valid: true}},
+			want: []string{"[REDACTED]", `[[REDACTED] "Bob"]`, "[REDACTED]"},
+		},
+		"OtherValues": {
+			sql:  "SELECT $1, $2, $3, $4",
+			args: []any{nil, []byte("abc"), pgtype.Text{}, true},
+			want: []string{"NULL", "<3 bytes>", "NULL", "true"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, redactArgs(tt.sql, tt.args))
+		})
+	}
+}
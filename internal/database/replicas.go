@@ -0,0 +1,165 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/things-kit/module/log"
+	"go.uber.org/fx"
+)
+
+// replicaPingTimeout bounds a single replica health check
+const replicaPingTimeout = 2 * time.Second
+
+// Replica is a read-only connection pool and its last known health
+type Replica struct {
+	// Pool is the replica's connection pool
+	Pool    *pgxpool.Pool
+	name    string
+	healthy atomic.Bool
+}
+
+// Healthy reports whether the replica answered its last health check
+func (r *Replica) Healthy() bool {
+	return r.healthy.Load()
+}
+
+// MarkDown takes the replica out of rotation until its next successful health check
+func (r *Replica) MarkDown() {
+	r.healthy.Store(false)
+}
+
+// Replicas routes reads across read replicas in round-robin order, skipping
+// replicas that failed their health check. A nil *Replicas has no replicas.
+type Replicas struct {
+	replicas []*Replica
+	next     atomic.Uint64
+	interval time.Duration
+	log      log.Logger
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Pick returns the next healthy replica, or nil if there is none and reads
+// should go to the primary
+func (r *Replicas) Pick() *Replica {
+	if r == nil || len(r.replicas) == 0 {
+		return nil
+	}
+
+	start := r.next.Add(1)
+	for i := range uint64(len(r.replicas)) {
+		replica := r.replicas[(start+i)%uint64(len(r.replicas))]
+		if replica.Healthy() {
+			return replica
+		}
+	}
+	return nil
+}
+
+// Start checks every replica once and then keeps checking them in the background
+func (r *Replicas) Start(ctx context.Context) {
+	r.checkAll(ctx)
+
+	ctx, r.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.checkAll(ctx)
+			}
+		}
+	}()
+}
+
+// Stop ends the health checks and closes the replica pools
+func (r *Replicas) Stop(ctx context.Context) error {
+	if r.cancel != nil {
+		r.cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	for _, replica := range r.replicas {
+		replica.Pool.Close()
+	}
+	return nil
+}
+
+func (r *Replicas) checkAll(ctx context.Context) {
+	for _, replica := range r.replicas {
+		pingCtx, cancel := context.WithTimeout(ctx, replicaPingTimeout)
+		err := replica.Pool.Ping(pingCtx)
+		cancel()
+
+		wasHealthy := replica.healthy.Swap(err == nil)
+		switch {
+		case err != nil && wasHealthy:
+			r.log.Error("Read replica is down, routing its reads to the primary", err,
+				log.Field{Key: "replica", Value: replica.name})
+		case err == nil && !wasHealthy:
+			r.log.Info("Read replica is up", log.Field{Key: "replica", Value: replica.name})
+		}
+	}
+}
+
+// ReplicasParams holds the replica dependencies. Password and QueryLog may
+// be nil.
+type ReplicasParams struct {
+	fx.In
+
+	Config   *Config
+	Password PasswordFunc `optional:"true"`
+	QueryLog *QueryLog    `optional:"true"`
+	Logger   log.Logger
+}
+
+// NewReplicas creates a pool for every configured replica DSN. Replicas start
+// out of rotation until their first health check passes. Like the primary,
+// they ask Password for the password of every new connection.
+func NewReplicas(p ReplicasParams) (*Replicas, error) {
+	cfg := p.Config
+	r := &Replicas{interval: cfg.ReplicaCheckInterval, log: p.Logger}
+
+	for i, dsn := range cfg.Replicas {
+		poolCfg, err := newPoolConfig(dsn, cfg, p.QueryLog, p.Password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure replica %d: %w", i, err)
+		}
+
+		pool, err := pgxpool.NewWithConfig(context.Background(), poolCfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create replica pool %d: %w", i, err)
+		}
+
+		r.replicas = append(r.replicas, &Replica{
+			Pool: pool,
+			name: fmt.Sprintf("%s:%d", poolCfg.ConnConfig.Host, poolCfg.ConnConfig.Port),
+		})
+	}
+
+	return r, nil
+}
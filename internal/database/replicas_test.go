@@ -0,0 +1,35 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplicasPick(t *testing.T) {
+	a, b, c := &Replica{name: "a"}, &Replica{name: "b"}, &Replica{name: "c"}
+	for _, replica := range []*Replica{a, b, c} {
+		replica.healthy.Store(true)
+	}
+	r := &Replicas{replicas: []*Replica{a, b, c}}
+
+	seen := map[string]int{}
+	for range 6 {
+		seen[r.Pick().name]++
+	}
+	assert.Equal(t, map[string]int{"a": 2, "b": 2, "c": 2}, seen, "reads are spread round-robin")
+
+	b.MarkDown()
+	for range 6 {
+		assert.NotEqual(t, "b", r.Pick().name, "unhealthy replicas are skipped")
+	}
+
+	a.MarkDown()
+	c.MarkDown()
+	assert.Nil(t, r.Pick(), "no healthy replica falls back to the primary")
+}
+
+func TestNilReplicasPick(t *testing.T) {
+	var r *Replicas
+	assert.Nil(t, r.Pick())
+}
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/things-kit/module/log"
+)
+
+// RetryConfig controls how transient database errors are retried
+type RetryConfig struct {
+	// MaxAttempts caps the attempts per call, including the first; 1 disables retries
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// InitialBackoff is the upper bound of the first jittered delay
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"`
+	// MaxBackoff caps the delay between attempts
+	MaxBackoff time.Duration `mapstructure:"max_backoff"`
+}
+
+// transientCodes are the SQLSTATEs after which the statement or transaction
+// can safely be run again
+var transientCodes = map[string]bool{
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected
+	"57P01": true, // admin_shutdown
+	"57P02": true, // crash_shutdown
+	"57P03": true, // cannot_connect_now
+	// class 08 (connection_exception) is matched by prefix
+}
+
+// IsTransient reports whether err is worth retrying: serialization failures,
+// deadlocks, connection resets and the errors a failover produces. Only
+// reads may be retried on all of them: a write whose connection broke may
+// have been committed, see IsRetryableWrite.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return transientCodes[pgErr.Code] || strings.HasPrefix(pgErr.Code, "08")
+	}
+
+	return pgconn.SafeToRetry(err) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// IsRetryableWrite reports whether a write that failed with err can be run
+// again without applying it twice: the server rolled it back after a
+// serialization failure or deadlock, or pgx never sent it
+func IsRetryableWrite(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "40001" || pgErr.Code == "40P01"
+	}
+
+	return pgconn.SafeToRetry(err)
+}
+
+// Retrier runs database calls again with jittered exponential backoff when
+// they fail with a transient error. A nil *Retrier runs each call once.
+type Retrier struct {
+	cfg       RetryConfig
+	log       log.Logger
+	retries   *prometheus.CounterVec
+	exhausted *prometheus.CounterVec
+}
+
+// NewRetrier creates a retrier from the "db.retry" configuration
+func NewRetrier(cfg *Config, logger log.Logger) *Retrier {
+	return &Retrier{
+		cfg: cfg.Retry,
+		log: logger,
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "db_retries_total",
+			Help: "Database calls retried after a transient error.",
+		}, []string{"operation"}),
+		exhausted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "db_retries_exhausted_total",
+			Help: "Database calls that still failed with a transient error after the last attempt.",
+		}, []string{"operation"}),
+	}
+}
+
+// Collectors returns the Prometheus collectors for the retry counters
+func (r *Retrier) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{r.retries, r.exhausted}
+}
+
+// Do calls fn until it succeeds, fails with a non-transient error, the
+// attempts run out or ctx is done. op names the call in logs and metrics.
+// Use it for reads; writes go through DoWrite.
+func (r *Retrier) Do(ctx context.Context, op string, fn func() error) error {
+	return r.do(ctx, op, IsTransient, fn)
+}
+
+// DoWrite is Do for writes and transactions: it only retries the errors
+// IsRetryableWrite accepts, so a write is never applied twice
+func (r *Retrier) DoWrite(ctx context.Context, op string, fn func() error) error {
+	return r.do(ctx, op, IsRetryableWrite, fn)
+}
+
+// do calls fn until it succeeds, fails with an error retryable rejects, the
+// attempts run out or ctx is done
+func (r *Retrier) do(ctx context.Context, op string, retryable func(error) bool, fn func() error) error {
+	if r == nil {
+		return fn()
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if !retryable(err) {
+			return err
+		}
+
+		if attempt >= r.cfg.MaxAttempts {
+			if r.cfg.MaxAttempts > 1 {
+				r.exhausted.WithLabelValues(op).Inc()
+			}
+			return err
+		}
+
+		delay := r.backoff(attempt)
+		r.retries.WithLabelValues(op).Inc()
+		r.log.Info("Retrying transient database error",
+			log.Field{Key: "operation", Value: op},
+			log.Field{Key: "attempt", Value: attempt},
+			log.Field{Key: "delay", Value: delay.String()},
+			log.Field{Key: "error", Value: err.Error()},
+		)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns a random delay up to InitialBackoff doubled for every
+// previous attempt, capped at MaxBackoff ("full jitter")
+func (r *Retrier) backoff(attempt int) time.Duration {
+	ceiling := r.cfg.InitialBackoff << (attempt - 1)
+	if ceiling <= 0 || ceiling > r.cfg.MaxBackoff {
+		ceiling = r.cfg.MaxBackoff
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling)
+}
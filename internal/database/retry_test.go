@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	kittest "github.com/things-kit/example-db/internal/testutil"
+)
+
+func newTestRetrier(maxAttempts int) *Retrier {
+	return NewRetrier(&Config{Retry: RetryConfig{
+		MaxAttempts:    maxAttempts,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+	}}, kittest.NopLogger{})
+}
+
+func TestIsTransient(t *testing.T) {
+	assert.True(t, IsTransient(&pgconn.PgError{Code: "40001"}))
+	assert.True(t, IsTransient(fmt.Errorf("failed to get user: %w", &pgconn.PgError{Code: "08006"})))
+	assert.False(t, IsTransient(&pgconn.PgError{Code: "23505"}))
+	assert.False(t, IsTransient(errors.New("user not found")))
+	assert.False(t, IsTransient(context.DeadlineExceeded))
+	assert.False(t, IsTransient(nil))
+}
+
+func TestIsRetryableWrite(t *testing.T) {
+	assert.True(t, IsRetryableWrite(fmt.Errorf("failed to create user: %w", &pgconn.PgError{Code: "40001"})))
+	assert.True(t, IsRetryableWrite(&pgconn.PgError{Code: "40P01"}))
+	assert.False(t, IsRetryableWrite(&pgconn.PgError{Code: "08006"}), "the write may have been committed")
+	assert.False(t, IsRetryableWrite(&pgconn.PgError{Code: "57P01"}))
+	assert.False(t, IsRetryableWrite(io.ErrUnexpectedEOF))
+	assert.False(t, IsRetryableWrite(nil))
+}
+
+func TestRetrierRetriesTransientErrors(t *testing.T) {
+	r := newTestRetrier(3)
+
+	calls := 0
+	err := r.Do(context.Background(), "GetByID", func() error {
+		if calls++; calls < 3 {
+			return &pgconn.PgError{Code: "40P01"}
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, 2.0, testutil.ToFloat64(r.retries.WithLabelValues("GetByID")))
+}
+
+func TestRetrierGivesUp(t *testing.T) {
+	r := newTestRetrier(3)
+
+	calls := 0
+	err := r.Do(context.Background(), "Create", func() error {
+		calls++
+		return &pgconn.PgError{Code: "40001"}
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, 3, calls, "attempts are capped")
+	assert.Equal(t, 1.0, testutil.ToFloat64(r.exhausted.WithLabelValues("Create")))
+
+	calls = 0
+	err = r.Do(context.Background(), "Create", func() error {
+		calls++
+		return &pgconn.PgError{Code: "23505"}
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls, "permanent errors are not retried")
+}
+
+func TestNilRetrierRunsOnce(t *testing.T) {
+	var r *Retrier
+
+	calls := 0
+	_ = r.Do(context.Background(), "List", func() error {
+		calls++
+		return &pgconn.PgError{Code: "40001"}
+	})
+
+	assert.Equal(t, 1, calls)
+}
+
+func TestDoWriteRetriesOnlySafeErrors(t *testing.T) {
+	r := newTestRetrier(3)
+
+	calls := 0
+	err := r.DoWrite(context.Background(), "Update", func() error {
+		calls++
+		return io.ErrUnexpectedEOF
+	})
+
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Equal(t, 1, calls, "a broken connection may have applied the write")
+
+	calls = 0
+	err = r.DoWrite(context.Background(), "Update", func() error {
+		if calls++; calls < 2 {
+			return &pgconn.PgError{Code: "40001"}
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+}
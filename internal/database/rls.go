@@ -0,0 +1,21 @@
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/things-kit/example-db/internal/tenant"
+)
+
+// setTenantQuery records the tenant for the row-level security policies for
+// the rest of the session, until the connection is acquired again
+const setTenantQuery = "SELECT set_config('app.tenant_id', $1, false)"
+
+// setTenant is a pool BeforeAcquire hook that sets app.tenant_id to the tenant
+// of the acquiring context. Every acquire costs a round trip, so the database
+// enforces tenant isolation even for a query that misses the tenant filter.
+// A connection that fails is discarded and another one acquired.
+func setTenant(ctx context.Context, conn *pgx.Conn) bool {
+	_, err := conn.Exec(ctx, setTenantQuery, tenant.FromContext(ctx))
+	return err == nil
+}
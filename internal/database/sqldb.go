@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+
+	"go.uber.org/fx"
+)
+
+// SQLOpener opens a database/sql pool on a connector, like sql.OpenDB. The
+// tracing module provides one that instruments the driver.
+type SQLOpener func(driver.Connector) *sql.DB
+
+// SQLDBParams holds the dependencies of ReopenSQLDB. Open and Password may be
+// nil.
+type SQLDBParams struct {
+	fx.In
+
+	Lifecycle fx.Lifecycle
+	DB        *sql.DB
+	Config    *Config
+	Open      SQLOpener    `optional:"true"`
+	Password  PasswordFunc `optional:"true"`
+}
+
+// ReopenSQLDB replaces the database/sql pool opened by the sqlc module with
+// one opened on a connector of the same driver, so the application keeps a
+// single pool. The connector asks Password for the password of every new
+// connection, like the pgx pools, and the pool is opened by Open, or
+// sql.OpenDB without one. It is an fx decorator for *sql.DB and must be
+// applied at the application root. Without an Open or a Password the pool is
+// kept as it is.
+func ReopenSQLDB(p SQLDBParams) *sql.DB {
+	if p.Open == nil && p.Password == nil {
+		return p.DB
+	}
+
+	open := p.Open
+	if open == nil {
+		open = sql.OpenDB
+	}
+	db := open(&connector{driver: p.DB.Driver(), dsn: p.Config.DSN, password: p.Password})
+	p.Lifecycle.Append(fx.Hook{
+		// Runs after the hooks of the sqlc module, which may ping the
+		// replaced pool, and closes its connections
+		OnStart: func(context.Context) error {
+			return p.DB.Close()
+		},
+		OnStop: func(context.Context) error {
+			return db.Close()
+		},
+	})
+	return db
+}
+
+// connector opens connections to dsn with a database/sql driver. password
+// may be nil.
+type connector struct {
+	driver   driver.Driver
+	dsn      string
+	password PasswordFunc
+}
+
+// Connect implements driver.Connector
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	dsn := c.dsn
+	if c.password != nil {
+		password, err := c.password(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if dsn, err = dsnWithPassword(dsn, password); err != nil {
+			return nil, err
+		}
+	}
+
+	if dc, ok := c.driver.(driver.DriverContext); ok {
+		conn, err := dc.OpenConnector(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return conn.Connect(ctx)
+	}
+	return c.driver.Open(dsn)
+}
+
+// Driver implements driver.Connector
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx/fxtest"
+)
+
+// recordingDriver records the DSNs it opens and fails every connection
+type recordingDriver struct {
+	mu   sync.Mutex
+	dsns []string
+}
+
+func (d *recordingDriver) Open(dsn string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dsns = append(d.dsns, dsn)
+	return nil, errors.New("no database")
+}
+
+func TestReopenSQLDB(t *testing.T) {
+	drv := &recordingDriver{}
+	original := sql.OpenDB(&connector{driver: drv, dsn: "original"})
+	cfg := &Config{DSN: "postgres://db/app"}
+
+	lc := fxtest.NewLifecycle(t)
+	assert.Same(t, original, ReopenSQLDB(SQLDBParams{Lifecycle: lc, DB: original, Config: cfg}),
+		"without an opener the pool is kept")
+
+	var opened bool
+	db := ReopenSQLDB(SQLDBParams{
+		Lifecycle: lc,
+		DB:        original,
+		Config:    cfg,
+		Open: func(c driver.Connector) *sql.DB {
+			opened = true
+			return sql.OpenDB(c)
+		},
+	})
+	require.True(t, opened)
+	require.NotSame(t, original, db)
+
+	lc.RequireStart()
+	assert.ErrorContains(t, original.PingContext(context.Background()), "closed", "the replaced pool is closed")
+
+	assert.Error(t, db.PingContext(context.Background()))
+	assert.Equal(t, []string{"postgres://db/app"}, drv.dsns, "the new pool uses the driver of the replaced one")
+
+	lc.RequireStop()
+}
+
+func TestReopenSQLDBWithPassword(t *testing.T) {
+	drv := &recordingDriver{}
+	original := sql.OpenDB(&connector{driver: drv, dsn: "original"})
+
+	lc := fxtest.NewLifecycle(t)
+	password := "first"
+	db := ReopenSQLDB(SQLDBParams{
+		Lifecycle: lc,
+		DB:        original,
+		Config:    &Config{DSN: "postgres://app:startup@db/app"},
+		Password:  func(context.Context) (string, error) { return password, nil },
+	})
+	require.NotSame(t, original, db, "a password source reopens the pool without an opener")
+	lc.RequireStart()
+
+	assert.Error(t, db.PingContext(context.Background()))
+	password = "rotated"
+	assert.Error(t, db.PingContext(context.Background()))
+	assert.Equal(t, []string{"postgres://app:first@db/app", "postgres://app:rotated@db/app"}, drv.dsns,
+		"every new connection uses the current password")
+
+	lc.RequireStop()
+}
@@ -0,0 +1,30 @@
+package debug
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/things-kit/example-db/internal/ops"
+)
+
+// NewRoute serves the debug routes on the ops server when enabled
+func NewRoute(cfg *Config) ops.Route {
+	route := ops.Route{Pattern: "/debug/"}
+	if cfg.Enabled {
+		route.Handler = NewHandler()
+	}
+	return route
+}
+
+// NewHandler returns the debug routes: /debug/pprof/* and /debug/vars
+func NewHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
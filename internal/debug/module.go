@@ -0,0 +1,34 @@
+package debug
+
+import (
+	"github.com/spf13/viper"
+	"github.com/things-kit/example-db/internal/ops"
+	"go.uber.org/fx"
+)
+
+// Module serves pprof profiles and expvar counters on the ops server when
+// enabled
+var Module = fx.Module("debug",
+	fx.Provide(NewConfig),
+	ops.AsRoute(NewRoute),
+)
+
+// Config holds the debug configuration
+type Config struct {
+	// Enabled serves the profiles, which expose the command line and can
+	// stall the process, so it is off unless asked for
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// NewConfig loads the debug configuration from the "debug" key
+func NewConfig(v *viper.Viper) *Config {
+	cfg := &Config{
+		Enabled: false,
+	}
+
+	if v != nil {
+		_ = v.UnmarshalKey("debug", cfg)
+	}
+
+	return cfg
+}
@@ -0,0 +1,39 @@
+package debug
+
+import (
+	"expvar"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	varsMu sync.Mutex
+	vars   = map[string]*latestVar{}
+)
+
+// latestVar is an expvar.Var that reads the most recently stored Var
+type latestVar struct {
+	atomic.Pointer[expvar.Var]
+}
+
+// String returns the value of the stored Var
+func (l *latestVar) String() string {
+	return (*l.Load()).String()
+}
+
+// Publish serves v under name in /debug/vars. expvar.Publish panics when a
+// name is reused, and an application built more than once in one process,
+// as the integration tests do, publishes its names again: each name is
+// published once and then reads the most recently published v.
+func Publish(name string, v expvar.Var) {
+	varsMu.Lock()
+	defer varsMu.Unlock()
+
+	l, ok := vars[name]
+	if !ok {
+		l = &latestVar{}
+		vars[name] = l
+		expvar.Publish(name, l)
+	}
+	l.Store(&v)
+}
@@ -0,0 +1,18 @@
+package debug
+
+import (
+	"expvar"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishTwice(t *testing.T) {
+	first, second := new(expvar.Int), new(expvar.Int)
+	first.Set(1)
+	second.Set(2)
+
+	Publish("debug_test_publish_twice", first)
+	assert.NotPanics(t, func() { Publish("debug_test_publish_twice", second) }, "an app built again publishes the same name")
+	assert.Equal(t, "2", expvar.Get("debug_test_publish_twice").String(), "the name reads the latest value")
+}
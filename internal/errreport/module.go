@@ -0,0 +1,67 @@
+package errreport
+
+import (
+	"context"
+
+	"github.com/spf13/viper"
+	"github.com/things-kit/example-db/internal/config"
+	"github.com/things-kit/example-db/internal/middleware"
+	"github.com/things-kit/module/log"
+	"go.uber.org/fx"
+)
+
+// Module provides the error Reporter and its middleware
+var Module = fx.Module("errreport",
+	fx.Provide(NewConfig, NewReporter),
+	config.Validate[*Config]("sentry"),
+	middleware.AsMiddleware(NewMiddleware),
+)
+
+// Config holds the error reporting configuration
+type Config struct {
+	DSN         string  `mapstructure:"dsn"`
+	Environment string  `mapstructure:"environment"`
+	Release     string  `mapstructure:"release"`
+	SampleRate  float64 `mapstructure:"sample_rate"`
+}
+
+// NewConfig loads the error reporting configuration from the "sentry" key
+func NewConfig(v *viper.Viper) *Config {
+	cfg := &Config{
+		Environment: "development",
+		SampleRate:  1,
+	}
+
+	if v != nil {
+		_ = v.UnmarshalKey("sentry", cfg)
+	}
+
+	return cfg
+}
+
+// Validate checks the sample rate
+func (c *Config) Validate() error {
+	return config.Between("sample_rate", c.SampleRate, 0, 1)
+}
+
+// NewReporter creates a Sentry reporter, or a NopReporter when no DSN is set
+func NewReporter(lc fx.Lifecycle, cfg *Config, logger log.Logger) (Reporter, error) {
+	if cfg.DSN == "" {
+		logger.Info("Error reporting disabled")
+		return NopReporter{}, nil
+	}
+
+	r, err := NewSentryReporter(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			r.Flush(ctx)
+			return nil
+		},
+	})
+
+	return r, nil
+}
@@ -0,0 +1,101 @@
+package errreport
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+	"github.com/things-kit/example-db/internal/i18n"
+	"github.com/things-kit/example-db/internal/middleware"
+)
+
+// Reporter sends errors to an error tracking service
+type Reporter interface {
+	// CaptureError reports err, attaching the request it occurred in if any
+	CaptureError(ctx context.Context, req *http.Request, err error)
+	// Flush waits until buffered reports are sent or the context is done
+	Flush(ctx context.Context)
+}
+
+// NopReporter discards every report. It is used when no DSN is configured.
+type NopReporter struct{}
+
+// CaptureError does nothing
+func (NopReporter) CaptureError(context.Context, *http.Request, error) {}
+
+// Flush does nothing
+func (NopReporter) Flush(context.Context) {}
+
+// SentryReporter reports errors to Sentry
+type SentryReporter struct {
+	hub *sentry.Hub
+}
+
+// NewSentryReporter creates a reporter from the given configuration
+func NewSentryReporter(cfg *Config) (*SentryReporter, error) {
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:         cfg.DSN,
+		Environment: cfg.Environment,
+		Release:     cfg.Release,
+		SampleRate:  cfg.SampleRate,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sentry client: %w", err)
+	}
+
+	return &SentryReporter{hub: sentry.NewHub(client, sentry.NewScope())}, nil
+}
+
+// CaptureError sends err to Sentry with the request method, URL and headers
+func (r *SentryReporter) CaptureError(ctx context.Context, req *http.Request, err error) {
+	hub := r.hub.Clone()
+	if req != nil {
+		hub.Scope().SetRequest(req)
+	}
+	hub.CaptureException(err)
+}
+
+// Flush waits for buffered events to be sent
+func (r *SentryReporter) Flush(ctx context.Context) {
+	timeout := 2 * time.Second
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	r.hub.Flush(timeout)
+}
+
+// NewMiddleware reports panics and 5xx responses. A panic is recovered and
+// answered with a 500 after it is reported.
+func NewMiddleware(reporter Reporter) middleware.Middleware {
+	return middleware.Middleware{
+		Name:    "errreport",
+		Order:   5,
+		Handler: newHandler(reporter),
+	}
+}
+
+func newHandler(reporter Reporter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if v := recover(); v != nil {
+				reporter.CaptureError(c.Request.Context(), c.Request, fmt.Errorf("panic: %v", v))
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c.Request.Context(), "Internal server error")})
+			}
+		}()
+
+		c.Next()
+
+		if c.Writer.Status() < http.StatusInternalServerError {
+			return
+		}
+
+		err := fmt.Errorf("%s %s returned %d", c.Request.Method, c.FullPath(), c.Writer.Status())
+		if last := c.Errors.Last(); last != nil {
+			err = last.Err
+		}
+		reporter.CaptureError(c.Request.Context(), c.Request, err)
+	}
+}
@@ -0,0 +1,58 @@
+package errreport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingReporter struct {
+	errs []error
+}
+
+func (r *recordingReporter) CaptureError(_ context.Context, _ *http.Request, err error) {
+	r.errs = append(r.errs, err)
+}
+
+func (r *recordingReporter) Flush(context.Context) {}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	reporter := &recordingReporter{}
+
+	engine := gin.New()
+	engine.Use(newHandler(reporter))
+	engine.GET("/ok", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	engine.GET("/fail", func(c *gin.Context) {
+		_ = c.Error(errors.New("database is down"))
+		c.Status(http.StatusInternalServerError)
+	})
+	engine.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	for _, tt := range []struct {
+		path   string
+		status int
+	}{
+		{"/ok", http.StatusOK},
+		{"/fail", http.StatusInternalServerError},
+		{"/panic", http.StatusInternalServerError},
+	} {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		assert.Equal(t, tt.status, w.Code, tt.path)
+	}
+
+	require.Len(t, reporter.errs, 2)
+	assert.EqualError(t, reporter.errs[0], "database is down")
+	assert.EqualError(t, reporter.errs[1], "panic: boom")
+}
@@ -0,0 +1,157 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/things-kit/example-db/internal/clock"
+	"github.com/things-kit/example-db/internal/idgen"
+)
+
+// SchemaVersion is the version of the event envelope and payloads.
+// Bump it whenever a field is removed or changes meaning.
+const SchemaVersion = 1
+
+// Event types published for user mutations
+const (
+	UserCreated = "user.created"
+	UserUpdated = "user.updated"
+	UserDeleted = "user.deleted"
+	// UserErased carries no data: consumers must forget everything they
+	// keep about the user
+	UserErased = "user.erased"
+	// UserMerged is recorded for the surviving user of a merge, with the
+	// ID of the merged user, which gets its own UserDeleted
+	UserMerged = "user.merged"
+)
+
+// Event is the envelope published to the message broker
+type Event struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	SchemaVersion int             `json:"schema_version"`
+	AggregateID   int64           `json:"aggregate_id"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Data          json.RawMessage `json:"data,omitempty"`
+	// TenantID is the tenant of the aggregate. Events written before it was
+	// recorded have none and belong to the default tenant.
+	TenantID string `json:"tenant_id,omitempty"`
+}
+
+// New creates an event of the given type with data encoded as JSON. clk
+// stamps the event and ids generates its ID; nil uses the system clock and
+// random UUIDs.
+func New(eventType string, aggregateID int64, data any, clk clock.Clock, ids idgen.Generator) (Event, error) {
+	id, err := idgen.Or(ids).UUID()
+	if err != nil {
+		return Event{}, err
+	}
+
+	evt := Event{
+		ID:            id.String(),
+		Type:          eventType,
+		SchemaVersion: SchemaVersion,
+		AggregateID:   aggregateID,
+		OccurredAt:    clock.Or(clk).Now().UTC(),
+	}
+
+	if data != nil {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return Event{}, err
+		}
+		evt.Data = raw
+	}
+
+	return evt, nil
+}
+
+// Publisher publishes events to a message broker
+type Publisher interface {
+	Publish(ctx context.Context, evt Event) error
+}
+
+// Pinger is implemented by publishers that can check their broker connection
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// NopPublisher discards all events
+type NopPublisher struct{}
+
+// Publish implements Publisher
+func (NopPublisher) Publish(context.Context, Event) error { return nil }
+
+// maxPartial bounds the events a MultiPublisher remembers as published to
+// only some of its publishers
+const maxPartial = 1000
+
+// MultiPublisher publishes every event to all of its publishers. When some of
+// them fail it remembers which ones took the event, and a retried Publish of
+// that event only goes to the others, so a retry by the outbox relay doesn't
+// duplicate it on the publishers that succeeded.
+type MultiPublisher struct {
+	publishers []Publisher
+
+	mu sync.Mutex
+	// partial holds, by event ID, the publishers that took an event that
+	// failed on others
+	partial map[string]map[int]bool
+}
+
+// NewMultiPublisher creates a publisher publishing to all of publishers
+func NewMultiPublisher(publishers ...Publisher) *MultiPublisher {
+	return &MultiPublisher{publishers: publishers, partial: map[string]map[int]bool{}}
+}
+
+// Publish implements Publisher. All publishers that haven't taken the event
+// yet are attempted; their errors are joined.
+func (m *MultiPublisher) Publish(ctx context.Context, evt Event) error {
+	m.mu.Lock()
+	done := m.partial[evt.ID]
+	m.mu.Unlock()
+
+	var errs []error
+	took := map[int]bool{}
+	for i, p := range m.publishers {
+		if done[i] {
+			took[i] = true
+			continue
+		}
+		if err := p.Publish(ctx, evt); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		took[i] = true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(errs) == 0 {
+		delete(m.partial, evt.ID)
+		return nil
+	}
+	if len(m.partial) >= maxPartial {
+		// Forgetting only means a duplicate on retry, which consumers
+		// tolerate under at-least-once delivery
+		clear(m.partial)
+	}
+	m.partial[evt.ID] = took
+	return errors.Join(errs...)
+}
+
+// Ping implements Pinger by pinging every publisher that supports it
+func (m *MultiPublisher) Ping(ctx context.Context) error {
+	var errs []error
+	for _, p := range m.publishers {
+		if pinger, ok := p.(Pinger); ok {
+			if err := pinger.Ping(ctx); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
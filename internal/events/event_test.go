@@ -0,0 +1,71 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/clock"
+	"github.com/things-kit/example-db/internal/idgen"
+)
+
+func TestNewEncodesEnvelope(t *testing.T) {
+	evt, err := New(UserCreated, 42, map[string]string{"email": "john@example.com"}, nil, nil)
+	require.NoError(t, err)
+
+	raw, err := json.Marshal(evt)
+	require.NoError(t, err)
+
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(raw, &decoded))
+
+	assert.NotEmpty(t, decoded["id"])
+	assert.Equal(t, UserCreated, decoded["type"])
+	assert.Equal(t, float64(SchemaVersion), decoded["schema_version"])
+	assert.Equal(t, float64(42), decoded["aggregate_id"])
+	assert.Equal(t, "john@example.com", decoded["data"].(map[string]any)["email"])
+}
+
+func TestNewUsesClockAndIDs(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	evt, err := New(UserDeleted, 42, nil, clock.NewFake(now), idgen.NewSequence())
+	require.NoError(t, err)
+
+	assert.Equal(t, idgen.SequenceUUID(1).String(), evt.ID)
+	assert.Equal(t, now, evt.OccurredAt)
+}
+
+// countingPublisher counts published events and fails while err is set
+type countingPublisher struct {
+	calls int
+	err   error
+}
+
+func (p *countingPublisher) Publish(context.Context, Event) error {
+	p.calls++
+	return p.err
+}
+
+func TestMultiPublisherRetriesOnlyFailed(t *testing.T) {
+	ok, failing := &countingPublisher{}, &countingPublisher{err: errors.New("down")}
+	m := NewMultiPublisher(ok, failing)
+	evt, err := New(UserCreated, 1, nil, nil, nil)
+	require.NoError(t, err)
+
+	assert.Error(t, m.Publish(context.Background(), evt))
+	assert.Error(t, m.Publish(context.Background(), evt))
+	assert.Equal(t, 1, ok.calls, "the event isn't sent again where it succeeded")
+	assert.Equal(t, 2, failing.calls)
+
+	failing.err = nil
+	require.NoError(t, m.Publish(context.Background(), evt))
+	assert.Equal(t, 1, ok.calls)
+	assert.Equal(t, 3, failing.calls)
+
+	require.NoError(t, m.Publish(context.Background(), evt))
+	assert.Equal(t, 2, ok.calls, "a published event is forgotten")
+}
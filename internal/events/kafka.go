@@ -0,0 +1,75 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaPublisher publishes events as JSON messages to a Kafka topic
+type KafkaPublisher struct {
+	writer  *kafka.Writer
+	brokers []string
+}
+
+// NewKafkaPublisher creates a publisher writing to the configured topic
+func NewKafkaPublisher(cfg *Config) *KafkaPublisher {
+	return &KafkaPublisher{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(cfg.Brokers...),
+			Topic:        cfg.Topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			// Publish writes synchronously, so each call waits up to
+			// BatchTimeout for more messages before sending its batch
+			BatchTimeout: cfg.BatchTimeout,
+		},
+		brokers: cfg.Brokers,
+	}
+}
+
+// Publish writes the event keyed by its aggregate ID, so all events for one
+// user land on the same partition and keep their order
+func (p *KafkaPublisher) Publish(ctx context.Context, evt Event) error {
+	value, err := json.Marshal(evt)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	err = p.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(strconv.FormatInt(evt.AggregateID, 10)),
+		Value: value,
+		Headers: []kafka.Header{
+			{Key: "type", Value: []byte(evt.Type)},
+			{Key: "schema_version", Value: []byte(strconv.Itoa(evt.SchemaVersion))},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+
+	return nil
+}
+
+// Ping succeeds if any broker accepts a connection
+func (p *KafkaPublisher) Ping(ctx context.Context) error {
+	var errs []error
+	for _, broker := range p.brokers {
+		conn, err := kafka.DialContext(ctx, "tcp", broker)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		return conn.Close()
+	}
+	return fmt.Errorf("failed to reach kafka: %w", errors.Join(errs...))
+}
+
+// Close flushes pending messages and closes the writer
+func (p *KafkaPublisher) Close() error {
+	return p.writer.Close()
+}
@@ -0,0 +1,114 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/things-kit/example-db/internal/health"
+	"github.com/things-kit/module/log"
+	"go.uber.org/fx"
+)
+
+// Module provides the event Publisher
+var Module = fx.Module("events",
+	fx.Provide(NewConfig, NewNATSConfig, NewPublisher),
+	health.AsCheck(NewHealthCheck),
+)
+
+// NewHealthCheck checks the broker connection of the publisher, if it has one
+func NewHealthCheck(p Publisher) health.Check {
+	check := health.Check{Name: "events"}
+	if pinger, ok := p.(Pinger); ok {
+		check.Func = pinger.Ping
+	}
+	return check
+}
+
+// Config holds the Kafka publishing configuration
+type Config struct {
+	Enabled bool     `mapstructure:"enabled"`
+	Brokers []string `mapstructure:"brokers"`
+	Topic   string   `mapstructure:"topic"`
+	// BatchTimeout bounds how long a write waits to fill a batch
+	BatchTimeout time.Duration `mapstructure:"batch_timeout"`
+}
+
+// NewConfig loads the Kafka configuration from the "kafka" key
+func NewConfig(v *viper.Viper) *Config {
+	cfg := &Config{
+		Enabled:      false,
+		Brokers:      []string{"localhost:9092"},
+		Topic:        "users",
+		BatchTimeout: 10 * time.Millisecond,
+	}
+
+	if v != nil {
+		_ = v.UnmarshalKey("kafka", cfg)
+	}
+
+	return cfg
+}
+
+// NATSConfig holds the NATS configuration
+type NATSConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	URL     string `mapstructure:"url"`
+	Subject string `mapstructure:"subject"`
+}
+
+// NewNATSConfig loads the NATS configuration from the "nats" key
+func NewNATSConfig(v *viper.Viper) *NATSConfig {
+	cfg := &NATSConfig{
+		Enabled: false,
+		URL:     "nats://localhost:4222",
+		Subject: "users",
+	}
+
+	if v != nil {
+		_ = v.UnmarshalKey("nats", cfg)
+	}
+
+	return cfg
+}
+
+// NewPublisher returns the publisher for the enabled broker. Kafka takes
+// precedence over NATS; with neither enabled events are discarded.
+func NewPublisher(lc fx.Lifecycle, cfg *Config, natsCfg *NATSConfig, logger log.Logger) (Publisher, error) {
+	switch {
+	case cfg.Enabled:
+		p := NewKafkaPublisher(cfg)
+		lc.Append(fx.Hook{
+			OnStop: func(ctx context.Context) error {
+				return p.Close()
+			},
+		})
+
+		logger.Info("Publishing events to Kafka",
+			log.Field{Key: "brokers", Value: cfg.Brokers},
+			log.Field{Key: "topic", Value: cfg.Topic},
+		)
+		return p, nil
+
+	case natsCfg.Enabled:
+		p, err := NewNATSPublisher(natsCfg)
+		if err != nil {
+			return nil, err
+		}
+		lc.Append(fx.Hook{
+			OnStop: func(ctx context.Context) error {
+				return p.Close()
+			},
+		})
+
+		logger.Info("Publishing events to NATS",
+			log.Field{Key: "url", Value: natsCfg.URL},
+			log.Field{Key: "subject", Value: natsCfg.Subject},
+		)
+		return p, nil
+
+	default:
+		logger.Info("Event publishing disabled")
+		return NopPublisher{}, nil
+	}
+}
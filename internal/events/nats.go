@@ -0,0 +1,57 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSPublisher publishes events as JSON messages to NATS subjects of the
+// form "<subject>.<event type>", e.g. "users.user.created"
+type NATSPublisher struct {
+	conn    *nats.Conn
+	subject string
+}
+
+// NewNATSPublisher connects to NATS and creates a publisher
+func NewNATSPublisher(cfg *NATSConfig) (*NATSPublisher, error) {
+	conn, err := nats.Connect(cfg.URL, nats.Name("example-db-publisher"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+
+	return &NATSPublisher{conn: conn, subject: cfg.Subject}, nil
+}
+
+// Publish sends the event and waits until the server has processed it
+func (p *NATSPublisher) Publish(ctx context.Context, evt Event) error {
+	data, err := json.Marshal(evt)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	if err := p.conn.Publish(p.subject+"."+evt.Type, data); err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+
+	if err := p.conn.FlushWithContext(ctx); err != nil {
+		return fmt.Errorf("failed to flush event: %w", err)
+	}
+
+	return nil
+}
+
+// Ping round-trips a PING to the server
+func (p *NATSPublisher) Ping(ctx context.Context) error {
+	if err := p.conn.FlushWithContext(ctx); err != nil {
+		return fmt.Errorf("failed to reach nats: %w", err)
+	}
+	return nil
+}
+
+// Close drains pending messages and closes the connection
+func (p *NATSPublisher) Close() error {
+	return p.conn.Drain()
+}
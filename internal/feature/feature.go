@@ -0,0 +1,151 @@
+// Package feature gates behaviors behind flags so they can be rolled out
+// progressively: on or off for everyone, for listed tenants, or for a stable
+// share of tenants. Flags are read from the "features" key and follow
+// changes of the config file.
+package feature
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sort"
+	"sync/atomic"
+
+	"github.com/spf13/viper"
+	"github.com/things-kit/example-db/internal/config"
+	"github.com/things-kit/example-db/internal/tenant"
+	"go.uber.org/fx"
+)
+
+// Flags of the service
+const (
+	// SoftDelete keeps deleted users until the purge worker removes them. Off,
+	// DELETE /users/:id removes the row at once.
+	SoftDelete = "soft_delete"
+	// CursorPagination returns X-Next-Cursor from GET /users and accepts its
+	// cursor parameter
+	CursorPagination = "cursor_pagination"
+)
+
+// defaults are the states of the flags missing from the configuration
+var defaults = map[string]bool{
+	SoftDelete:       true,
+	CursorPagination: true,
+}
+
+// Module provides the Flags and reloads them when the configuration changes
+var Module = fx.Module("feature",
+	fx.Provide(NewConfig, New),
+	config.Validate[*Config]("features"),
+	config.AsListener(NewReloadListener),
+)
+
+// Flag is the rollout of a feature
+type Flag struct {
+	// Enabled turns the feature on; Tenants and Percent narrow it down
+	Enabled bool `mapstructure:"enabled"`
+	// Tenants limits the feature to these tenants; empty means every tenant
+	Tenants []string `mapstructure:"tenants"`
+	// Percent limits the feature to that share of tenants, from 1 to 100; 0
+	// means every tenant. Each tenant falls in a stable bucket per flag, so
+	// raising it only adds tenants.
+	Percent int `mapstructure:"percent"`
+}
+
+// Config holds the feature flags by name
+type Config struct {
+	Flags map[string]Flag
+}
+
+// NewConfig loads the feature flags from the "features" key
+func NewConfig(v *viper.Viper) *Config {
+	cfg := &Config{}
+
+	if v != nil {
+		_ = v.UnmarshalKey("features", &cfg.Flags)
+	}
+
+	return cfg
+}
+
+// Validate checks that every flag is known and its percent is in range
+func (c *Config) Validate() error {
+	names := make([]string, 0, len(c.Flags))
+	for name := range c.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if _, ok := defaults[name]; !ok {
+			errs = append(errs, fmt.Errorf("%s is not a known feature flag", name))
+			continue
+		}
+		errs = append(errs, config.Between(name+".percent", c.Flags[name].Percent, 0, 100))
+	}
+	return errors.Join(errs...)
+}
+
+// Flags evaluates the feature flags. It is safe for concurrent use. A nil
+// *Flags reports the default of every flag.
+type Flags struct {
+	cfg atomic.Pointer[Config]
+}
+
+// New creates Flags evaluating cfg
+func New(cfg *Config) *Flags {
+	f := &Flags{}
+	f.Set(cfg)
+	return f
+}
+
+// Set replaces the flags being evaluated
+func (f *Flags) Set(cfg *Config) {
+	f.cfg.Store(cfg)
+}
+
+// Enabled reports whether a flag is on for the tenant of ctx
+func (f *Flags) Enabled(ctx context.Context, name string) bool {
+	if f == nil {
+		return defaults[name]
+	}
+	flag, ok := f.cfg.Load().Flags[name]
+	if !ok {
+		return defaults[name]
+	}
+	if !flag.Enabled {
+		return false
+	}
+
+	id := tenant.FromContext(ctx)
+	if len(flag.Tenants) > 0 && !slices.Contains(flag.Tenants, id) {
+		return false
+	}
+	return flag.Percent <= 0 || flag.Percent >= 100 || bucket(name, id) < flag.Percent
+}
+
+// bucket places a tenant in one of 100 buckets. The flag name is part of the
+// hash so each flag rolls out to a different first set of tenants.
+func bucket(name, tenantID string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name + "/" + tenantID))
+	return int(h.Sum32() % 100)
+}
+
+// NewReloadListener applies the flags when the configuration changes
+func NewReloadListener(f *Flags) config.Listener {
+	return config.Listener{
+		Name: "features",
+		Reload: func(v *viper.Viper) error {
+			cfg := NewConfig(v)
+			if err := cfg.Validate(); err != nil {
+				return err
+			}
+			f.Set(cfg)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,77 @@
+package feature
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/things-kit/example-db/internal/tenant"
+)
+
+func TestDefaults(t *testing.T) {
+	ctx := context.Background()
+
+	var flags *Flags
+	assert.True(t, flags.Enabled(ctx, SoftDelete))
+	assert.False(t, flags.Enabled(ctx, "unknown"))
+
+	flags = New(NewConfig(nil))
+	assert.True(t, flags.Enabled(ctx, CursorPagination))
+}
+
+func TestTenants(t *testing.T) {
+	flags := New(&Config{Flags: map[string]Flag{
+		SoftDelete: {Enabled: true, Tenants: []string{"acme"}},
+	}})
+
+	assert.True(t, flags.Enabled(tenant.WithTenant(context.Background(), "acme"), SoftDelete))
+	assert.False(t, flags.Enabled(tenant.WithTenant(context.Background(), "globex"), SoftDelete))
+	assert.False(t, flags.Enabled(context.Background(), SoftDelete))
+}
+
+func TestPercent(t *testing.T) {
+	enabled := func(percent int) map[string]bool {
+		flags := New(&Config{Flags: map[string]Flag{
+			CursorPagination: {Enabled: true, Percent: percent},
+		}})
+		on := map[string]bool{}
+		for i := range 1000 {
+			id := fmt.Sprintf("tenant-%d", i)
+			if flags.Enabled(tenant.WithTenant(context.Background(), id), CursorPagination) {
+				on[id] = true
+			}
+		}
+		return on
+	}
+
+	quarter, half := enabled(25), enabled(50)
+	assert.InDelta(t, 250, len(quarter), 60)
+	assert.InDelta(t, 500, len(half), 60)
+	for id := range quarter {
+		assert.True(t, half[id], "raising the percent keeps %s enabled", id)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	v := viper.New()
+	v.Set("features", map[string]any{
+		"soft_delete": map[string]any{"enabled": false},
+		"cursor_pagination": map[string]any{
+			"enabled": true,
+			"tenants": []string{"acme"},
+			"percent": 10,
+		},
+	})
+
+	cfg := NewConfig(v)
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, Flag{Enabled: true, Tenants: []string{"acme"}, Percent: 10}, cfg.Flags[CursorPagination])
+	assert.False(t, New(cfg).Enabled(context.Background(), SoftDelete))
+
+	cfg.Flags["soft_delte"] = Flag{Enabled: true}
+	cfg.Flags[SoftDelete] = Flag{Percent: 101}
+	assert.EqualError(t, cfg.Validate(),
+		"soft_delete.percent must be between 0 and 100, got 101\nsoft_delte is not a known feature flag")
+}
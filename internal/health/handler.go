@@ -0,0 +1,36 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/things-kit/example-db/internal/ops"
+)
+
+// Handler serves the readiness endpoint
+type Handler struct {
+	registry *Registry
+}
+
+// NewHandler creates a new readiness handler
+func NewHandler(registry *Registry) *Handler {
+	return &Handler{registry: registry}
+}
+
+// NewRoute serves the handler at /readyz
+func NewRoute(h *Handler) ops.Route {
+	return ops.Route{Pattern: "GET /readyz", Handler: h}
+}
+
+// ServeHTTP runs all checks and responds 503 if any dependency is down
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	report := h.registry.Run(r.Context())
+
+	status := http.StatusOK
+	if report.Status != StatusUp {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,127 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/fx"
+)
+
+// Check is a named readiness check for one dependency
+type Check struct {
+	// Name identifies the dependency in the report
+	Name string
+	// Timeout bounds a single run; zero uses the registry default
+	Timeout time.Duration
+	// Func returns nil when the dependency is usable. A nil Func marks a
+	// dependency that isn't configured; such checks are skipped.
+	Func func(ctx context.Context) error
+}
+
+// AsCheck annotates a constructor returning a Check so it is added to the Registry
+func AsCheck(f any) fx.Option {
+	return fx.Provide(fx.Annotate(f, fx.ResultTags(`group:"health_checks"`)))
+}
+
+// NewDBCheck checks that the database answers a ping
+func NewDBCheck(db *sql.DB) Check {
+	return Check{Name: "postgres", Func: db.PingContext}
+}
+
+// Status values used in reports
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Result is the outcome of a single check
+type Result struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the outcome of all checks
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// Params holds the registered checks
+type Params struct {
+	fx.In
+
+	Checks []Check `group:"health_checks"`
+}
+
+// Registry runs the registered checks
+type Registry struct {
+	checks  []Check
+	timeout time.Duration
+}
+
+// NewRegistry creates a registry of the configured checks
+func NewRegistry(p Params, cfg *Config) *Registry {
+	r := &Registry{timeout: cfg.Timeout}
+	for _, c := range p.Checks {
+		if c.Func != nil {
+			r.checks = append(r.checks, c)
+		}
+	}
+
+	sort.Slice(r.checks, func(i, j int) bool {
+		return r.checks[i].Name < r.checks[j].Name
+	})
+	return r
+}
+
+// Run executes all checks concurrently, each under its own timeout. The
+// report is up only if every check passed.
+func (r *Registry) Run(ctx context.Context) Report {
+	results := make([]Result, len(r.checks))
+
+	var wg sync.WaitGroup
+	for i, c := range r.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = r.run(ctx, c)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Checks: results}
+	for _, res := range results {
+		if res.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+func (r *Registry) run(ctx context.Context, c Check) Result {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = r.timeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.Func(ctx)
+	res := Result{
+		Name:      c.Name,
+		Status:    StatusUp,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		res.Status = StatusDown
+		res.Error = err.Error()
+	}
+	return res
+}
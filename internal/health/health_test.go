@@ -0,0 +1,59 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryRun(t *testing.T) {
+	r := NewRegistry(Params{Checks: []Check{
+		{Name: "postgres", Func: func(context.Context) error { return nil }},
+		{Name: "storage"},
+		{Name: "kafka", Func: func(context.Context) error { return errors.New("no brokers") }},
+		{Name: "slow", Timeout: 20 * time.Millisecond, Func: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+	}}, &Config{Timeout: time.Second})
+
+	start := time.Now()
+	report := r.Run(context.Background())
+
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, StatusDown, report.Status)
+	require.Len(t, report.Checks, 3, "unconfigured checks are skipped")
+
+	byName := map[string]Result{}
+	for _, res := range report.Checks {
+		byName[res.Name] = res
+	}
+	assert.Equal(t, StatusUp, byName["postgres"].Status)
+	assert.Equal(t, "no brokers", byName["kafka"].Error)
+	assert.Equal(t, context.DeadlineExceeded.Error(), byName["slow"].Error)
+}
+
+func TestRegistryRunAllUp(t *testing.T) {
+	r := NewRegistry(Params{Checks: []Check{
+		{Name: "postgres", Func: func(context.Context) error { return nil }},
+	}}, &Config{Timeout: time.Second})
+
+	assert.Equal(t, StatusUp, r.Run(context.Background()).Status)
+}
+
+func TestHandlerRespondsUnavailableWhenDown(t *testing.T) {
+	r := NewRegistry(Params{Checks: []Check{
+		{Name: "postgres", Func: func(context.Context) error { return errors.New("connection refused") }},
+	}}, &Config{Timeout: time.Second})
+
+	w := httptest.NewRecorder()
+	NewHandler(r).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"connection refused"`)
+}
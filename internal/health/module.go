@@ -0,0 +1,42 @@
+package health
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/things-kit/example-db/internal/config"
+	"github.com/things-kit/example-db/internal/ops"
+	"go.uber.org/fx"
+)
+
+// Module provides the check Registry and the /readyz endpoint of the ops
+// server
+var Module = fx.Module("health",
+	fx.Provide(NewConfig, NewRegistry),
+	config.Validate[*Config]("health"),
+	fx.Provide(NewHandler),
+	ops.AsRoute(NewRoute),
+)
+
+// Config holds the health check configuration
+type Config struct {
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// NewConfig loads the health check configuration from the "health" key
+func NewConfig(v *viper.Viper) *Config {
+	cfg := &Config{
+		Timeout: 2 * time.Second,
+	}
+
+	if v != nil {
+		_ = v.UnmarshalKey("health", cfg)
+	}
+
+	return cfg
+}
+
+// Validate checks the check timeout
+func (c *Config) Validate() error {
+	return config.Positive("timeout", c.Timeout)
+}
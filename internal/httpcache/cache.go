@@ -0,0 +1,244 @@
+// Package httpcache caches the responses of GET requests. Responses are
+// keyed by URL, Authorization header and tenant, and every successful
+// mutation of a tenant invalidates its cached responses.
+package httpcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/things-kit/example-db/internal/middleware"
+	"github.com/things-kit/example-db/internal/tenant"
+	"github.com/things-kit/module/log"
+)
+
+// cachedHeaders are the response headers stored with a cached response.
+// Other headers, such as Set-Cookie, X-Request-ID or the rate limit
+// headers, belong to the request that produced the response.
+var cachedHeaders = []string{
+	"Content-Type",
+	"Content-Language",
+	"Content-Disposition",
+	"ETag",
+	"Last-Modified",
+	"Vary",
+	"X-Total-Count",
+	"X-Next-Cursor",
+}
+
+// Cache serves cached responses and stores new ones
+type Cache struct {
+	cfg   atomic.Pointer[Config]
+	store Store
+	log   log.Logger
+	now   func() time.Time
+}
+
+// New creates a response cache
+func New(cfg *Config, store Store, logger log.Logger) *Cache {
+	cache := &Cache{store: store, log: logger, now: time.Now}
+	cache.cfg.Store(cfg)
+	return cache
+}
+
+// SetTTL changes how long responses are fresh and then stale. Responses
+// already cached expire from the store as they were stored.
+func (cache *Cache) SetTTL(maxAge, staleWhileRevalidate time.Duration) {
+	cfg := *cache.cfg.Load()
+	cfg.MaxAge, cfg.StaleWhileRevalidate = maxAge, staleWhileRevalidate
+	cache.cfg.Store(&cfg)
+}
+
+// NewMiddleware runs the cache after the tenant is resolved, so cached
+// responses never cross tenants
+func NewMiddleware(cache *Cache) middleware.Middleware {
+	return middleware.Middleware{
+		Name:    "httpcache",
+		Order:   30,
+		Handler: cache.Handle,
+	}
+}
+
+// Handle serves GET requests from the cache and invalidates the cache after
+// other requests succeed
+func (cache *Cache) Handle(c *gin.Context) {
+	cfg := cache.cfg.Load()
+	if !cfg.Enabled {
+		c.Next()
+		return
+	}
+
+	if c.Request.Method != http.MethodGet {
+		c.Next()
+		if c.Writer.Status() < http.StatusBadRequest {
+			if err := cache.store.Invalidate(c.Request.Context(), tenant.FromContext(c.Request.Context())); err != nil {
+				cache.log.Error("Failed to invalidate cached responses", err)
+			}
+		}
+		return
+	}
+
+	key, err := cache.key(c)
+	if err != nil {
+		cache.log.Error("Failed to read the response cache", err)
+		c.Next()
+		return
+	}
+
+	entry, err := cache.store.Get(c.Request.Context(), key)
+	if err != nil {
+		cache.log.Error("Failed to read the response cache", err)
+	}
+	if entry != nil {
+		age := cache.now().Sub(entry.StoredAt)
+		if age < cfg.MaxAge {
+			cache.serve(c, cfg, entry, age, "HIT")
+			return
+		}
+		// Serve the stale entry unless this request is the one refreshing it
+		claimed, err := cache.store.Claim(c.Request.Context(), key, cfg.StaleWhileRevalidate)
+		if err == nil && !claimed {
+			cache.serve(c, cfg, entry, age, "STALE")
+			return
+		}
+	}
+
+	w := &recorder{ResponseWriter: c.Writer, cacheControl: cacheControl(cfg), max: cfg.MaxBodySize}
+	c.Writer = w
+	c.Header("X-Cache", "MISS")
+	c.Next()
+
+	if w.Status() != http.StatusOK || w.overflow || c.IsAborted() || noStore(w.Header()) {
+		return
+	}
+	entry = &Entry{
+		Status:   w.Status(),
+		Header:   cacheable(w.Header()),
+		Body:     w.body.Bytes(),
+		StoredAt: cache.now(),
+	}
+	if err := cache.store.Set(c.Request.Context(), key, entry, cfg.MaxAge+cfg.StaleWhileRevalidate); err != nil {
+		cache.log.Error("Failed to write the response cache", err)
+	}
+}
+
+// key identifies a response by the URL, Authorization header and tenant,
+// including the tenant's generation so invalidated entries are not found
+func (cache *Cache) key(c *gin.Context) (string, error) {
+	scope := tenant.FromContext(c.Request.Context())
+	gen, err := cache.store.Generation(c.Request.Context(), scope)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s", c.Request.URL.RequestURI(), c.GetHeader("Authorization"))
+	return scope + ":" + strconv.FormatInt(gen, 10) + ":" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// serve writes a cached entry. Its headers replace those already set for
+// this request, so none is sent twice.
+func (cache *Cache) serve(c *gin.Context, cfg *Config, e *Entry, age time.Duration, status string) {
+	for name, values := range cacheable(e.Header) {
+		c.Writer.Header()[name] = values
+	}
+	c.Header("Cache-Control", cacheControl(cfg))
+	c.Header("Age", strconv.Itoa(int(age.Seconds())))
+	c.Header("X-Cache", status)
+	c.Data(e.Status, c.Writer.Header().Get("Content-Type"), e.Body)
+	c.Abort()
+}
+
+// cacheable returns a copy of the cachedHeaders of header. Entries stored before a
+// header was dropped from the list are filtered when served, too.
+func cacheable(header http.Header) http.Header {
+	kept := http.Header{}
+	for _, name := range cachedHeaders {
+		if values := header.Values(name); len(values) > 0 {
+			kept[name] = append([]string(nil), values...)
+		}
+	}
+	return kept
+}
+
+// cacheControl is the Cache-Control header of cacheable responses. They are
+// private as they depend on the Authorization header and tenant.
+func cacheControl(cfg *Config) string {
+	return fmt.Sprintf("private, max-age=%d, stale-while-revalidate=%d",
+		int(cfg.MaxAge.Seconds()), int(cfg.StaleWhileRevalidate.Seconds()))
+}
+
+// noStore reports whether header forbids storing the response, as handlers
+// of responses that change on every request set it
+func noStore(header http.Header) bool {
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		if strings.EqualFold(strings.TrimSpace(directive), "no-store") {
+			return true
+		}
+	}
+	return false
+}
+
+// recorder keeps a copy of the response body up to max bytes and sets the
+// Cache-Control header once the status is known, unless the handler set
+// no-store
+type recorder struct {
+	gin.ResponseWriter
+	cacheControl string
+	max          int
+	body         bytes.Buffer
+	overflow     bool
+	headerSet    bool
+}
+
+func (w *recorder) Write(data []byte) (int, error) {
+	w.setCacheControl()
+	if !w.overflow {
+		if w.body.Len()+len(data) > w.max {
+			w.overflow = true
+			w.body.Reset()
+		} else {
+			w.body.Write(data)
+		}
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recorder) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *recorder) WriteHeaderNow() {
+	w.setCacheControl()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+// Flush marks streamed responses as not cacheable
+func (w *recorder) Flush() {
+	w.overflow = true
+	w.body.Reset()
+	w.ResponseWriter.Flush()
+}
+
+func (w *recorder) setCacheControl() {
+	if w.headerSet || w.Written() {
+		return
+	}
+	w.headerSet = true
+	if noStore(w.Header()) {
+		return
+	}
+	if w.Status() == http.StatusOK {
+		w.Header().Set("Cache-Control", w.cacheControl)
+	} else {
+		w.Header().Set("Cache-Control", "no-store")
+	}
+}
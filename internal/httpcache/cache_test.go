@@ -0,0 +1,174 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/things-kit/example-db/internal/testutil"
+)
+
+// newTestEngine serves GET /users, counting its calls, behind a cache
+func newTestEngine(t *testing.T) (*gin.Engine, *Cache, *int) {
+	t.Helper()
+
+	cfg := NewConfig(nil)
+	cfg.Enabled = true
+	cache := New(cfg, NewMemoryStore(cfg.MaxEntries), testutil.NopLogger{})
+
+	calls := 0
+	engine := gin.New()
+	engine.Use(cache.Handle)
+	engine.GET("/users", func(c *gin.Context) {
+		calls++
+		c.Header("X-Total-Count", "1")
+		c.JSON(http.StatusOK, gin.H{"calls": calls})
+	})
+	engine.GET("/missing", func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	})
+	engine.POST("/users", func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+	return engine, cache, &calls
+}
+
+func get(engine *gin.Engine, path, auth string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestCacheServesFreshResponses(t *testing.T) {
+	engine, _, calls := newTestEngine(t)
+
+	w := get(engine, "/users", "")
+	assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+	assert.Equal(t, "private, max-age=30, stale-while-revalidate=30", w.Header().Get("Cache-Control"))
+
+	w = get(engine, "/users", "")
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "1", w.Header().Get("X-Total-Count"), "response headers are cached")
+	assert.JSONEq(t, `{"calls":1}`, w.Body.String())
+
+	get(engine, "/users?page=2", "")
+	get(engine, "/users", "Bearer other")
+	assert.Equal(t, 3, *calls, "the query and Authorization header are part of the key")
+
+	w = get(engine, "/missing", "")
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.Equal(t, "MISS", get(engine, "/missing", "").Header().Get("X-Cache"), "errors are not cached")
+}
+
+func TestCacheReplaysOnlyCachedHeaders(t *testing.T) {
+	cfg := NewConfig(nil)
+	cfg.Enabled = true
+	cache := New(cfg, NewMemoryStore(cfg.MaxEntries), testutil.NopLogger{})
+
+	requests := 0
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		requests++
+		c.Header("X-Request-ID", strconv.Itoa(requests))
+		c.Header("X-Total-Count", "1")
+		c.Next()
+	})
+	engine.Use(cache.Handle)
+	engine.GET("/users", func(c *gin.Context) {
+		c.Header("Set-Cookie", "session=abc")
+		c.JSON(http.StatusOK, gin.H{})
+	})
+
+	get(engine, "/users", "")
+	w := get(engine, "/users", "")
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	assert.Equal(t, []string{"2"}, w.Header().Values("X-Request-ID"), "the request ID is not replayed")
+	assert.Empty(t, w.Header().Values("Set-Cookie"), "cookies are not replayed")
+	assert.Equal(t, []string{"1"}, w.Header().Values("X-Total-Count"), "cached headers replace those already set")
+}
+
+func TestCacheSkipsNoStoreResponses(t *testing.T) {
+	engine, _, calls := newTestEngine(t)
+	engine.GET("/usage", func(c *gin.Context) {
+		*calls++
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, gin.H{"calls": *calls})
+	})
+
+	get(engine, "/usage", "")
+	w := get(engine, "/usage", "")
+	assert.Equal(t, "MISS", w.Header().Get("X-Cache"), "the handler opted out of caching")
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.JSONEq(t, `{"calls":2}`, w.Body.String())
+}
+
+func TestCacheInvalidatesOnMutation(t *testing.T) {
+	engine, _, calls := newTestEngine(t)
+
+	get(engine, "/users", "")
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/users", nil))
+
+	w := get(engine, "/users", "")
+	assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+	assert.Equal(t, 2, *calls)
+}
+
+func TestCacheServesStaleWhileOneRequestRevalidates(t *testing.T) {
+	engine, cache, calls := newTestEngine(t)
+	clock := time.Now()
+	cache.now = func() time.Time { return clock }
+	cache.store.(*MemoryStore).now = cache.now
+
+	entered, release := make(chan struct{}), make(chan struct{})
+	engine.GET("/slow", func(c *gin.Context) {
+		*calls++
+		if *calls > 1 {
+			close(entered)
+			<-release
+		}
+		c.JSON(http.StatusOK, gin.H{"calls": *calls})
+	})
+	get(engine, "/slow", "")
+
+	clock = clock.Add(45 * time.Second)
+	refreshed := make(chan *httptest.ResponseRecorder)
+	go func() { refreshed <- get(engine, "/slow", "") }()
+	<-entered
+
+	w := get(engine, "/slow", "")
+	assert.Equal(t, "STALE", w.Header().Get("X-Cache"), "others are served the stale entry during the refresh")
+	assert.Equal(t, strconv.Itoa(45), w.Header().Get("Age"))
+	assert.JSONEq(t, `{"calls":1}`, w.Body.String())
+
+	close(release)
+	assert.Equal(t, "MISS", (<-refreshed).Header().Get("X-Cache"), "the first request after max-age refreshes")
+
+	w = get(engine, "/slow", "")
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"), "fresh again after the refresh")
+	assert.Equal(t, "0", w.Header().Get("Age"))
+	assert.Equal(t, 2, *calls)
+}
+
+func TestCacheSetTTL(t *testing.T) {
+	engine, cache, _ := newTestEngine(t)
+	clock := time.Now()
+	cache.now = func() time.Time { return clock }
+	cache.store.(*MemoryStore).now = cache.now
+
+	get(engine, "/users", "")
+	cache.SetTTL(time.Minute, 0)
+
+	clock = clock.Add(45 * time.Second)
+	w := get(engine, "/users", "")
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"), "the new max-age applies to cached responses")
+	assert.Equal(t, "private, max-age=60, stale-while-revalidate=0", w.Header().Get("Cache-Control"))
+}
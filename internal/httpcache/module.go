@@ -0,0 +1,105 @@
+package httpcache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/things-kit/example-db/internal/config"
+	"github.com/things-kit/example-db/internal/middleware"
+	"go.uber.org/fx"
+)
+
+// Module caches the responses of GET requests and invalidates them when a
+// mutation succeeds
+var Module = fx.Module("httpcache",
+	fx.Provide(NewConfig, NewStore, New),
+	config.Validate[*Config]("http_cache"),
+	config.AsListener(NewReloadListener),
+	middleware.AsMiddleware(NewMiddleware),
+	fx.Invoke(func(lc fx.Lifecycle, s Store) {
+		lc.Append(fx.Hook{
+			OnStop: func(context.Context) error {
+				return s.Close()
+			},
+		})
+	}),
+)
+
+// NewReloadListener applies max_age and stale_while_revalidate when the
+// configuration changes
+func NewReloadListener(cache *Cache) config.Listener {
+	return config.Listener{
+		Name: "http_cache",
+		Reload: func(v *viper.Viper) error {
+			cfg := NewConfig(v)
+			if err := cfg.Validate(); err != nil {
+				return err
+			}
+			cache.SetTTL(cfg.MaxAge, cfg.StaleWhileRevalidate)
+			return nil
+		},
+	}
+}
+
+// Storage backends of the response cache
+const (
+	BackendMemory = "memory"
+	BackendRedis  = "redis"
+)
+
+// Config holds the response cache configuration
+type Config struct {
+	// Enabled caches responses and sets their Cache-Control header
+	Enabled bool `mapstructure:"enabled"`
+	// MaxAge is how long a response is served as fresh
+	MaxAge time.Duration `mapstructure:"max_age"`
+	// StaleWhileRevalidate is how long after MaxAge a response may still be
+	// served while one request refreshes it
+	StaleWhileRevalidate time.Duration `mapstructure:"stale_while_revalidate"`
+	// Backend is memory or redis
+	Backend string `mapstructure:"backend"`
+	// MaxEntries bounds the responses held by the memory backend
+	MaxEntries int `mapstructure:"max_entries"`
+	// MaxBodySize is the largest response body cached, in bytes
+	MaxBodySize int `mapstructure:"max_body_size"`
+	// RedisAddr is the host:port of the redis backend
+	RedisAddr string `mapstructure:"redis_addr"`
+}
+
+// NewConfig loads the response cache configuration from the "http_cache" key
+func NewConfig(v *viper.Viper) *Config {
+	cfg := &Config{
+		Enabled:              false,
+		MaxAge:               30 * time.Second,
+		StaleWhileRevalidate: 30 * time.Second,
+		Backend:              BackendMemory,
+		MaxEntries:           10000,
+		MaxBodySize:          1 << 20,
+		RedisAddr:            "localhost:6379",
+	}
+
+	if v != nil {
+		_ = v.UnmarshalKey("http_cache", cfg)
+	}
+
+	return cfg
+}
+
+// Validate checks the settings of an enabled cache
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	err := errors.Join(
+		config.Positive("max_age", c.MaxAge),
+		config.NonNegative("stale_while_revalidate", c.StaleWhileRevalidate),
+		config.OneOf("backend", c.Backend, BackendMemory, BackendRedis),
+		config.Positive("max_body_size", c.MaxBodySize),
+	)
+	if c.Backend == BackendRedis {
+		err = errors.Join(err, config.Required("redis_addr", c.RedisAddr))
+	}
+	return err
+}
@@ -0,0 +1,215 @@
+package httpcache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Entry is a cached response
+type Entry struct {
+	Status   int         `json:"status"`
+	Header   http.Header `json:"header"`
+	Body     []byte      `json:"body"`
+	StoredAt time.Time   `json:"stored_at"`
+}
+
+// Store holds cached responses. Entries belong to a scope, such as a tenant,
+// whose generation is part of their key: bumping it with Invalidate makes
+// every entry of the scope unreachable.
+type Store interface {
+	// Get returns the entry stored under key, or nil if there is none
+	Get(ctx context.Context, key string) (*Entry, error)
+	// Set stores an entry under key for ttl
+	Set(ctx context.Context, key string, e *Entry, ttl time.Duration) error
+	// Claim reports whether the caller is the first to claim key within ttl,
+	// so only one request refreshes a stale entry
+	Claim(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	// Generation returns the current generation of scope
+	Generation(ctx context.Context, scope string) (int64, error)
+	// Invalidate bumps the generation of scope
+	Invalidate(ctx context.Context, scope string) error
+	// Close releases the store's connections
+	Close() error
+}
+
+// NewStore creates the store of the configured backend
+func NewStore(cfg *Config) (Store, error) {
+	switch cfg.Backend {
+	case BackendMemory, "":
+		return NewMemoryStore(cfg.MaxEntries), nil
+	case BackendRedis:
+		return NewRedisStore(redis.NewClient(&redis.Options{Addr: cfg.RedisAddr})), nil
+	default:
+		return nil, fmt.Errorf("unknown http_cache.backend %q", cfg.Backend)
+	}
+}
+
+// MemoryStore keeps entries in process memory. Each replica of the service
+// has its own, so an invalidation only reaches the replica that handled the
+// mutation.
+type MemoryStore struct {
+	max int
+	now func() time.Time
+
+	mu          sync.Mutex
+	entries     map[string]memoryEntry
+	generations map[string]int64
+}
+
+type memoryEntry struct {
+	entry   *Entry
+	expires time.Time
+}
+
+// NewMemoryStore creates a store holding up to max entries
+func NewMemoryStore(max int) *MemoryStore {
+	return &MemoryStore{
+		max:         max,
+		now:         time.Now,
+		entries:     map[string]memoryEntry{},
+		generations: map[string]int64{},
+	}
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok || !s.now().Before(e.expires) {
+		return nil, nil
+	}
+	return e.entry, nil
+}
+
+func (s *MemoryStore) Set(_ context.Context, key string, e *Entry, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.put(key, memoryEntry{entry: e, expires: s.now().Add(ttl)})
+	return nil
+}
+
+func (s *MemoryStore) Claim(_ context.Context, key string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key = "claim:" + key
+	if e, ok := s.entries[key]; ok && s.now().Before(e.expires) {
+		return false, nil
+	}
+	s.put(key, memoryEntry{expires: s.now().Add(ttl)})
+	return true, nil
+}
+
+// put stores an entry, making room by dropping expired entries and then
+// arbitrary ones. Callers hold mu.
+func (s *MemoryStore) put(key string, e memoryEntry) {
+	if _, ok := s.entries[key]; !ok && s.max > 0 && len(s.entries) >= s.max {
+		now := s.now()
+		for k, old := range s.entries {
+			if !now.Before(old.expires) {
+				delete(s.entries, k)
+			}
+		}
+		for k := range s.entries {
+			if len(s.entries) < s.max {
+				break
+			}
+			delete(s.entries, k)
+		}
+	}
+	s.entries[key] = e
+}
+
+func (s *MemoryStore) Generation(_ context.Context, scope string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.generations[scope], nil
+}
+
+func (s *MemoryStore) Invalidate(_ context.Context, scope string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.generations[scope]++
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// RedisStore keeps entries in Redis, shared by every replica of the service
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore creates a store on a Redis client
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) (*Entry, error) {
+	data, err := s.client.Get(ctx, "httpcache:"+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cached response: %w", err)
+	}
+
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("failed to decode cached response: %w", err)
+	}
+	return &e, nil
+}
+
+func (s *RedisStore) Set(ctx context.Context, key string, e *Entry, ttl time.Duration) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode cached response: %w", err)
+	}
+	if err := s.client.Set(ctx, "httpcache:"+key, data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to cache response: %w", err)
+	}
+	return nil
+}
+
+func (s *RedisStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	ok, err := s.client.SetNX(ctx, "httpcache:claim:"+key, 1, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim cached response: %w", err)
+	}
+	return ok, nil
+}
+
+func (s *RedisStore) Generation(ctx context.Context, scope string) (int64, error) {
+	value, err := s.client.Get(ctx, "httpcache:gen:"+scope).Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get cache generation: %w", err)
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func (s *RedisStore) Invalidate(ctx context.Context, scope string) error {
+	if err := s.client.Incr(ctx, "httpcache:gen:"+scope).Err(); err != nil {
+		return fmt.Errorf("failed to invalidate cached responses: %w", err)
+	}
+	return nil
+}
+
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,119 @@
+// Package https serves the API over TLS next to the plain HTTP server of the
+// httpgin module, which then only redirects API requests to HTTPS.
+package https
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/things-kit/example-db/internal/config"
+	"github.com/things-kit/example-db/internal/middleware"
+	"github.com/things-kit/module/log"
+	"go.uber.org/fx"
+)
+
+// Module runs the HTTPS server when enabled
+var Module = fx.Module("https",
+	fx.Provide(NewConfig, NewServer),
+	config.Validate[*Config]("http.tls"),
+	middleware.AsMiddleware(NewRedirectMiddleware),
+	fx.Invoke(func(lc fx.Lifecycle, s *Server, cfg *Config, logger log.Logger) {
+		if !cfg.Enabled {
+			logger.Info("HTTPS disabled")
+			return
+		}
+		lc.Append(fx.Hook{
+			OnStart: func(ctx context.Context) error {
+				return s.Start()
+			},
+			OnStop: s.Stop,
+		})
+	}),
+)
+
+// Config holds the HTTPS configuration
+type Config struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Port is the HTTPS port
+	Port int `mapstructure:"port"`
+	// CertFile and KeyFile are PEM files of the certificate chain and key
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
+	// Autocert obtains certificates from Let's Encrypt instead of files
+	Autocert AutocertConfig `mapstructure:"autocert"`
+	// RedirectHTTP answers API requests on the plain HTTP port with a
+	// redirect to HTTPS
+	RedirectHTTP bool `mapstructure:"redirect_http"`
+	// Host is the host name redirects point to, rather than the Host header
+	// of the request; it defaults to the first autocert domain
+	Host string `mapstructure:"host"`
+	// HSTSMaxAge sets Strict-Transport-Security on HTTPS responses; 0 omits it
+	HSTSMaxAge time.Duration `mapstructure:"hsts_max_age"`
+}
+
+// AutocertConfig configures certificates from an ACME CA. The TLS-ALPN-01
+// challenge is answered on the HTTPS port, which must be reachable on 443.
+type AutocertConfig struct {
+	// Domains are the host names certificates are requested for
+	Domains []string `mapstructure:"domains"`
+	// Email is given to the CA for expiry notices
+	Email string `mapstructure:"email"`
+	// CacheDir keeps certificates across restarts
+	CacheDir string `mapstructure:"cache_dir"`
+}
+
+// NewConfig loads the HTTPS configuration from the "http.tls" key
+func NewConfig(v *viper.Viper) *Config {
+	cfg := &Config{
+		Enabled:      false,
+		Port:         8443,
+		RedirectHTTP: true,
+		HSTSMaxAge:   365 * 24 * time.Hour,
+		Autocert: AutocertConfig{
+			CacheDir: "certs",
+		},
+	}
+
+	if v != nil {
+		_ = v.UnmarshalKey("http.tls", cfg)
+	}
+
+	return cfg
+}
+
+// Validate checks that an enabled server has one source of certificates and
+// a host to redirect to
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	errs := []error{
+		config.Between("port", c.Port, 1, 65535),
+		config.NonNegative("hsts_max_age", c.HSTSMaxAge),
+	}
+	if c.RedirectHTTP {
+		errs = append(errs, config.Required("host", c.redirectHost()))
+	}
+	switch autocert := len(c.Autocert.Domains) > 0; {
+	case autocert && (c.CertFile != "" || c.KeyFile != ""):
+		errs = append(errs, errors.New("cert_file and key_file can't be combined with autocert"))
+	case autocert:
+		errs = append(errs, config.Required("autocert.cache_dir", c.Autocert.CacheDir))
+	default:
+		errs = append(errs,
+			config.Required("cert_file", c.CertFile),
+			config.Required("key_file", c.KeyFile),
+		)
+	}
+	return errors.Join(errs...)
+}
+
+// redirectHost is the host name HTTP requests are redirected to
+func (c *Config) redirectHost() string {
+	if c.Host == "" && len(c.Autocert.Domains) > 0 {
+		return c.Autocert.Domains[0]
+	}
+	return c.Host
+}
@@ -0,0 +1,158 @@
+package https
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/things-kit/example-db/internal/middleware"
+	"github.com/things-kit/example-db/internal/proxy"
+	"github.com/things-kit/module/log"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Server serves the gin engine over TLS
+type Server struct {
+	cfg *Config
+	srv *http.Server
+	log log.Logger
+}
+
+// NewServer creates the HTTPS server for the engine of the httpgin module
+func NewServer(cfg *Config, engine *gin.Engine, logger log.Logger) *Server {
+	return &Server{
+		cfg: cfg,
+		srv: &http.Server{
+			Addr:              ":" + strconv.Itoa(cfg.Port),
+			Handler:           engine,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		log: logger,
+	}
+}
+
+// Start loads the certificate and begins listening on the HTTPS port
+func (s *Server) Start() error {
+	tlsCfg, err := s.tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on HTTPS port: %w", err)
+	}
+	s.srv.TLSConfig = tlsCfg
+
+	go func() {
+		if err := s.srv.ServeTLS(ln, "", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.log.Error("HTTPS server stopped", err)
+		}
+	}()
+
+	s.log.Info("HTTPS server listening", log.Field{Key: "addr", Value: ln.Addr().String()})
+	return nil
+}
+
+// Stop shuts the HTTPS server down
+func (s *Server) Stop(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
+
+// tlsConfig returns the TLS settings with the certificate from the files or
+// from autocert
+func (s *Server) tlsConfig() (*tls.Config, error) {
+	cfg := newTLSConfig()
+
+	if len(s.cfg.Autocert.Domains) > 0 {
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(s.cfg.Autocert.Domains...),
+			Cache:      autocert.DirCache(s.cfg.Autocert.CacheDir),
+			Email:      s.cfg.Autocert.Email,
+		}
+		cfg.GetCertificate = m.GetCertificate
+		cfg.NextProtos = append(cfg.NextProtos, acme.ALPNProto)
+		return cfg, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(s.cfg.CertFile, s.cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	cfg.Certificates = []tls.Certificate{cert}
+	return cfg, nil
+}
+
+// newTLSConfig allows TLS 1.2 with forward-secret AEAD ciphers only, and TLS
+// 1.3, whose cipher suites are not configurable and all modern
+func newTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+		NextProtos:       []string{"h2", "http/1.1"},
+	}
+}
+
+// NewRedirectMiddleware redirects API requests that didn't arrive over TLS
+// to the HTTPS port of the configured host and sets Strict-Transport-Security
+// on the ones that did. X-Forwarded-Proto is only honored from trusted
+// proxies. It runs first, so redirected requests aren't traced or counted.
+func NewRedirectMiddleware(cfg *Config, proxies *proxy.Config) middleware.Middleware {
+	return middleware.Middleware{
+		Name:    "https",
+		Order:   -10,
+		Handler: redirectHandler(cfg, proxies),
+	}
+}
+
+func redirectHandler(cfg *Config, proxies *proxy.Config) gin.HandlerFunc {
+	hsts := "max-age=" + strconv.Itoa(int(cfg.HSTSMaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		if !cfg.Enabled {
+			c.Next()
+			return
+		}
+
+		// Behind a proxy terminating TLS, the proxy tells how the client connected
+		secure := c.Request.TLS != nil ||
+			(c.GetHeader("X-Forwarded-Proto") == "https" && proxies.Trusted(c.Request))
+		if secure {
+			if cfg.HSTSMaxAge > 0 {
+				c.Header("Strict-Transport-Security", hsts)
+			}
+			c.Next()
+			return
+		}
+		if !cfg.RedirectHTTP {
+			c.Next()
+			return
+		}
+
+		// The Host header is the client's, so the redirect goes to the
+		// configured host rather than wherever the client names
+		host := cfg.redirectHost()
+		if cfg.Port != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(cfg.Port))
+		}
+		// 308 keeps the method and body, unlike 301
+		c.Redirect(http.StatusPermanentRedirect, "https://"+host+c.Request.URL.RequestURI())
+		c.Abort()
+	}
+}
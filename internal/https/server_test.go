@@ -0,0 +1,161 @@
+package https
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/proxy"
+	"github.com/things-kit/example-db/internal/testutil"
+)
+
+func TestRedirectMiddleware(t *testing.T) {
+	cfg := NewConfig(nil)
+	cfg.Enabled = true
+	cfg.Host = "api.example.com"
+	v := viper.New()
+	v.Set("http.trusted_proxies", []string{"192.0.2.1"})
+
+	engine := gin.New()
+	engine.Use(NewRedirectMiddleware(cfg, proxy.NewConfig(v)).Handler)
+	engine.POST("/users", func(c *gin.Context) { c.Status(http.StatusCreated) })
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "http://api.example.com:8080/users?page=2", nil))
+	assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+	assert.Equal(t, "https://api.example.com:8443/users?page=2", w.Header().Get("Location"))
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "http://evil.example.net/users", nil))
+	assert.Equal(t, "https://api.example.com:8443/users", w.Header().Get("Location"), "the Host header is not trusted")
+
+	req := httptest.NewRequest(http.MethodPost, "https://api.example.com/users", nil)
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "max-age=31536000", w.Header().Get("Strict-Transport-Security"))
+
+	req = httptest.NewRequest(http.MethodPost, "http://api.example.com/users", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code, "TLS terminated by a trusted proxy is not redirected")
+
+	req = httptest.NewRequest(http.MethodPost, "http://api.example.com/users", nil)
+	req.RemoteAddr = "198.51.100.7:1234"
+	req.Header.Set("X-Forwarded-Proto", "https")
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusPermanentRedirect, w.Code, "other peers can't claim TLS")
+	assert.Empty(t, w.Header().Get("Strict-Transport-Security"))
+
+	cfg.Port = 443
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "http://api.example.com/users", nil))
+	assert.Equal(t, "https://api.example.com/users", w.Header().Get("Location"), "the default port is left out")
+
+	cfg.RedirectHTTP = false
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "http://api.example.com/users", nil))
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestServerServesTLS(t *testing.T) {
+	cfg := NewConfig(nil)
+	cfg.Enabled = true
+	cfg.Port = freePort(t)
+	cfg.CertFile, cfg.KeyFile = writeCert(t)
+
+	engine := gin.New()
+	engine.GET("/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+	s := NewServer(cfg, engine, testutil.NopLogger{})
+	require.NoError(t, s.Start())
+	t.Cleanup(func() { _ = s.Stop(context.Background()) })
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		InsecureSkipVerify: true,
+		MaxVersion:         tls.VersionTLS12,
+		CipherSuites:       []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA},
+	}}}
+	_, err := client.Get("https://localhost:" + strconv.Itoa(cfg.Port) + "/ping")
+	assert.Error(t, err, "CBC cipher suites are refused")
+
+	client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	resp, err := client.Get("https://localhost:" + strconv.Itoa(cfg.Port) + "/ping")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, uint16(tls.VersionTLS13), resp.TLS.Version)
+}
+
+func TestConfigValidate(t *testing.T) {
+	cfg := NewConfig(nil)
+	require.NoError(t, cfg.Validate(), "disabled by default")
+
+	cfg.Enabled = true
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cert_file is required")
+
+	cfg.CertFile, cfg.KeyFile = "cert.pem", "key.pem"
+	assert.ErrorContains(t, cfg.Validate(), "host is required", "redirects need a host")
+	cfg.Host = "api.example.com"
+	require.NoError(t, cfg.Validate())
+
+	cfg.Autocert.Domains = []string{"api.example.com"}
+	assert.ErrorContains(t, cfg.Validate(), "can't be combined with autocert")
+
+	cfg.CertFile, cfg.KeyFile, cfg.Host = "", "", ""
+	require.NoError(t, cfg.Validate(), "redirects default to the first autocert domain")
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+// writeCert writes a self-signed certificate for localhost
+func writeCert(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
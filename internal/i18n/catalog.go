@@ -0,0 +1,152 @@
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// source is the language messages are written in
+var source = language.English
+
+//go:embed locales/*.json
+var builtin embed.FS
+
+// Catalog holds the translations of every supported language
+type Catalog struct {
+	matcher language.Matcher
+	// locales lines up with the tags given to matcher, default first
+	locales []*Localizer
+}
+
+// NewCatalog loads the built-in catalogs and those in cfg.Dir. The default
+// locale must be English or one of them.
+func NewCatalog(cfg *Config) (*Catalog, error) {
+	messages := map[language.Tag]map[string]string{source: {}}
+	if err := loadDir(messages, builtin, "locales"); err != nil {
+		return nil, err
+	}
+	if cfg.Dir != "" {
+		if err := loadDir(messages, os.DirFS(cfg.Dir), "."); err != nil {
+			return nil, err
+		}
+	}
+
+	def, err := language.Parse(cfg.DefaultLocale)
+	if err != nil {
+		return nil, fmt.Errorf("invalid default locale: %w", err)
+	}
+	if _, ok := messages[def]; !ok {
+		return nil, fmt.Errorf("no catalog for default locale %s", def)
+	}
+
+	tags := []language.Tag{def}
+	for tag := range messages {
+		if tag != def {
+			tags = append(tags, tag)
+		}
+	}
+	// Keep the order stable so ties between languages are broken the same way
+	sort.Slice(tags[1:], func(i, j int) bool {
+		return tags[i+1].String() < tags[j+1].String()
+	})
+
+	c := &Catalog{matcher: language.NewMatcher(tags)}
+	for _, tag := range tags {
+		c.locales = append(c.locales, &Localizer{tag: tag, messages: messages[tag]})
+	}
+	return c, nil
+}
+
+// loadDir merges the <locale>.json files of dir into messages. Each file is
+// a JSON object from English messages to their translation.
+func loadDir(messages map[language.Tag]map[string]string, fsys fs.FS, dir string) error {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		tag, err := language.Parse(strings.TrimSuffix(path.Base(file), ".json"))
+		if err != nil {
+			return fmt.Errorf("catalog %s is not named after a locale: %w", file, err)
+		}
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		var m map[string]string
+		if err := json.Unmarshal(data, &m); err != nil {
+			return fmt.Errorf("failed to parse catalog %s: %w", file, err)
+		}
+
+		if messages[tag] == nil {
+			messages[tag] = map[string]string{}
+		}
+		for k, v := range m {
+			messages[tag][k] = v
+		}
+	}
+	return nil
+}
+
+// Localizer returns the Localizer of the language best matching an
+// Accept-Language header, or of the default locale
+func (c *Catalog) Localizer(acceptLanguage string) *Localizer {
+	_, i := language.MatchStrings(c.matcher, acceptLanguage)
+	return c.locales[i]
+}
+
+// Localizer translates messages into one language. A nil Localizer leaves
+// them in English.
+type Localizer struct {
+	tag      language.Tag
+	messages map[string]string
+}
+
+// Tag returns the language of the Localizer
+func (l *Localizer) Tag() language.Tag {
+	if l == nil {
+		return source
+	}
+	return l.tag
+}
+
+// T translates msg, formatting it with args as fmt.Sprintf does when args
+// are given. Messages without a translation are formatted as they are.
+func (l *Localizer) T(msg string, args ...any) string {
+	if l != nil {
+		if translated, ok := l.messages[msg]; ok && translated != "" {
+			msg = translated
+		}
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+type localizerKey struct{}
+
+// WithLocalizer returns a context whose messages are translated by l
+func WithLocalizer(ctx context.Context, l *Localizer) context.Context {
+	return context.WithValue(ctx, localizerKey{}, l)
+}
+
+// FromContext returns the Localizer recorded in ctx, or nil
+func FromContext(ctx context.Context) *Localizer {
+	l, _ := ctx.Value(localizerKey{}).(*Localizer)
+	return l
+}
+
+// T translates msg into the language of the request ctx belongs to
+func T(ctx context.Context, msg string, args ...any) string {
+	return FromContext(ctx).T(msg, args...)
+}
@@ -0,0 +1,108 @@
+package i18n
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errInvalid = errors.New("invalid user")
+
+func TestCatalogMatchesAcceptLanguage(t *testing.T) {
+	catalog, err := NewCatalog(NewConfig(nil))
+	require.NoError(t, err)
+
+	for header, want := range map[string]string{
+		"":                       "en",
+		"de":                     "de",
+		"de-AT,de;q=0.9":         "de",
+		"fr-FR,es;q=0.8,de;q=.5": "es",
+		"fr":                     "en",
+		"not a header":           "en",
+	} {
+		assert.Equal(t, want, catalog.Localizer(header).Tag().String(), header)
+	}
+}
+
+func TestCatalogDefaultLocale(t *testing.T) {
+	catalog, err := NewCatalog(&Config{DefaultLocale: "de"})
+	require.NoError(t, err)
+	assert.Equal(t, "de", catalog.Localizer("fr").Tag().String(), "unsupported languages get the default")
+	assert.Equal(t, "en", catalog.Localizer("en-US").Tag().String())
+
+	_, err = NewCatalog(&Config{DefaultLocale: "fr"})
+	assert.Error(t, err, "the default locale needs a catalog")
+}
+
+func TestCatalogDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "de.json"), []byte(`{"User not found": "Kein Benutzer"}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "fr.json"), []byte(`{"User not found": "Utilisateur introuvable"}`), 0o600))
+
+	catalog, err := NewCatalog(&Config{DefaultLocale: "fr", Dir: dir})
+	require.NoError(t, err)
+
+	assert.Equal(t, "Utilisateur introuvable", catalog.Localizer("").T("User not found"))
+	assert.Equal(t, "Kein Benutzer", catalog.Localizer("de").T("User not found"), "files override built-in translations")
+	assert.Equal(t, "Ungültige Benutzer-ID", catalog.Localizer("de").T("Invalid user ID"), "other built-in translations are kept")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "de.json"), []byte(`{`), 0o600))
+	_, err = NewCatalog(&Config{DefaultLocale: "en", Dir: dir})
+	assert.Error(t, err)
+}
+
+func TestLocalize(t *testing.T) {
+	catalog, err := NewCatalog(NewConfig(nil))
+	require.NoError(t, err)
+	de := WithLocalizer(context.Background(), catalog.Localizer("de"))
+
+	msg := Wrap(errInvalid, "name is longer than %d characters", 100)
+	assert.Equal(t, "invalid user: name is longer than 100 characters", msg.Error())
+	assert.ErrorIs(t, msg, errInvalid)
+	assert.Equal(t, "ungültiger Benutzer: name ist länger als 100 Zeichen", Localize(de, msg))
+	assert.Equal(t, msg.Error(), Localize(context.Background(), msg), "without a Localizer messages stay in English")
+
+	assert.Equal(t, "user 1: ungültiger Benutzer: name ist erforderlich",
+		Localize(de, fmt.Errorf("user 1: %w", Wrap(errInvalid, "name is required"))))
+	assert.Equal(t, "ungültiges limit: darf höchstens 1000 sein", Localize(de, Errorf("invalid limit: must be at most %d", 1000)))
+	assert.Equal(t, "ungültiger Benutzer", Localize(de, errInvalid))
+	assert.Equal(t, "no translation", Localize(de, errors.New("no translation")))
+}
+
+func TestLocalizeBindingErrors(t *testing.T) {
+	useJSONFieldNames()
+	catalog, err := NewCatalog(NewConfig(nil))
+	require.NoError(t, err)
+
+	var got string
+	engine := gin.New()
+	engine.Use(NewMiddleware(catalog).Handler)
+	engine.POST("/", func(c *gin.Context) {
+		var req struct {
+			URL  string `json:"url" binding:"required,url"`
+			Name string `json:"name" binding:"max=3"`
+		}
+		got = Localize(c.Request.Context(), c.ShouldBindJSON(&req))
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"url":"nope","name":"toolong"}`))
+	req.Header.Set("Accept-Language", "es")
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "url debe ser una URL; name debe ser como máximo 3", got)
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, NewConfig(nil).Validate())
+	assert.Error(t, (&Config{}).Validate())
+	assert.Error(t, (&Config{DefaultLocale: "not a tag"}).Validate())
+}
@@ -0,0 +1,92 @@
+package i18n
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Message is an error whose text can be translated. Its format is the key
+// of the translation and is formatted with the arguments afterwards.
+type Message struct {
+	// Err is the error the message details, such as a sentinel error
+	Err    error
+	Format string
+	Args   []any
+}
+
+// Errorf returns a Message formatted from format and args
+func Errorf(format string, args ...any) error {
+	return &Message{Format: format, Args: args}
+}
+
+// Wrap returns a Message detailing err, which reads as err followed by a
+// colon and the formatted message, like fmt.Errorf("%w: "+format)
+func Wrap(err error, format string, args ...any) error {
+	return &Message{Err: err, Format: format, Args: args}
+}
+
+// Error returns the message in English
+func (m *Message) Error() string {
+	msg := fmt.Sprintf(m.Format, m.Args...)
+	if m.Err == nil {
+		return msg
+	}
+	return m.Err.Error() + ": " + msg
+}
+
+// Unwrap returns the error the message details
+func (m *Message) Unwrap() error {
+	return m.Err
+}
+
+// Localize returns the text of err in the language of ctx. Messages, also
+// when wrapped, and binding errors are translated; any other error is looked
+// up in the catalog by its text, so sentinel errors can be translated too.
+func Localize(ctx context.Context, err error) string {
+	l := FromContext(ctx)
+
+	var msg *Message
+	var fields validator.ValidationErrors
+	switch {
+	case errors.As(err, &msg):
+		text := l.T(msg.Format, msg.Args...)
+		if msg.Err != nil {
+			text = Localize(ctx, msg.Err) + ": " + text
+		}
+		// Keep the context added by errors wrapping the message, such as the
+		// position of an invalid user in an import
+		return strings.Replace(err.Error(), msg.Error(), text, 1)
+	case errors.As(err, &fields):
+		texts := make([]string, len(fields))
+		for i, f := range fields {
+			texts[i] = fieldError(l, f)
+		}
+		return strings.Join(texts, "; ")
+	default:
+		return l.T(err.Error())
+	}
+}
+
+// fieldError describes a failed binding tag
+func fieldError(l *Localizer, f validator.FieldError) string {
+	switch f.Tag() {
+	case "required":
+		return l.T("%s is required", f.Field())
+	case "url":
+		return l.T("%s must be a URL", f.Field())
+	case "email":
+		return l.T("%s must be a valid email address", f.Field())
+	case "min":
+		return l.T("%s must be at least %s", f.Field(), f.Param())
+	case "max":
+		return l.T("%s must be at most %s", f.Field(), f.Param())
+	case "oneof":
+		return l.T("%s must be one of %s", f.Field(), f.Param())
+	default:
+		return l.T("%s is invalid", f.Field())
+	}
+}
@@ -0,0 +1,93 @@
+// Package i18n translates the error and validation messages of the API into
+// the language asked for with Accept-Language. Messages are written in
+// English in the code, which doubles as the key of their translations in the
+// catalog, so a message missing from a catalog is answered in English.
+package i18n
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/viper"
+	"github.com/things-kit/example-db/internal/config"
+	"github.com/things-kit/example-db/internal/middleware"
+	"go.uber.org/fx"
+	"golang.org/x/text/language"
+)
+
+// Module loads the message catalog and adds the middleware choosing the
+// language of each request
+var Module = fx.Module("i18n",
+	fx.Provide(NewConfig, NewCatalog),
+	config.Validate[*Config]("i18n"),
+	middleware.AsMiddleware(NewMiddleware),
+	fx.Invoke(useJSONFieldNames),
+)
+
+// Config holds the i18n configuration
+type Config struct {
+	// DefaultLocale answers requests asking for no language the catalog has
+	DefaultLocale string `mapstructure:"default_locale"`
+	// Dir holds <locale>.json catalogs read at startup, which add languages
+	// or override the translations built into the binary
+	Dir string `mapstructure:"dir"`
+}
+
+// NewConfig loads the i18n configuration from the "i18n" key
+func NewConfig(v *viper.Viper) *Config {
+	cfg := &Config{
+		DefaultLocale: "en",
+	}
+
+	if v != nil {
+		_ = v.UnmarshalKey("i18n", cfg)
+	}
+
+	return cfg
+}
+
+// Validate checks that the default locale is a language tag
+func (c *Config) Validate() error {
+	if err := config.Required("default_locale", c.DefaultLocale); err != nil {
+		return err
+	}
+	if _, err := language.Parse(c.DefaultLocale); err != nil {
+		return fmt.Errorf("default_locale must be a BCP 47 language tag, got %q", c.DefaultLocale)
+	}
+	return nil
+}
+
+// NewMiddleware records the Localizer matching the Accept-Language header in
+// the request context. It runs before errreport so the response to a
+// recovered panic is translated too.
+func NewMiddleware(catalog *Catalog) middleware.Middleware {
+	return middleware.Middleware{
+		Name:  "i18n",
+		Order: 1,
+		Handler: func(c *gin.Context) {
+			l := catalog.Localizer(c.GetHeader("Accept-Language"))
+			c.Request = c.Request.WithContext(WithLocalizer(c.Request.Context(), l))
+			c.Next()
+		},
+	}
+}
+
+// useJSONFieldNames makes binding errors name fields as clients send them
+// rather than by their Go names
+func useJSONFieldNames() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+}
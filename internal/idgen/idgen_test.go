@@ -0,0 +1,45 @@
+package idgen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSequence(t *testing.T) {
+	s := NewSequence()
+
+	first, err := s.UUID()
+	require.NoError(t, err)
+	assert.Equal(t, "00000000-0000-7000-8000-000000000001", first.String())
+	assert.EqualValues(t, 7, first.Version())
+
+	second, err := s.UUID()
+	require.NoError(t, err)
+	assert.Equal(t, SequenceUUID(2), second)
+
+	token, err := s.Token(4)
+	require.NoError(t, err)
+	assert.Equal(t, "01010101", token)
+	token, err = s.Token(2)
+	require.NoError(t, err)
+	assert.Equal(t, "0202", token)
+
+	_, err = s.Token(0)
+	assert.Error(t, err)
+}
+
+func TestRandom(t *testing.T) {
+	a, err := Random{}.Token(32)
+	require.NoError(t, err)
+	assert.Len(t, a, 64)
+
+	b, err := Random{}.Token(32)
+	require.NoError(t, err)
+	assert.NotEqual(t, a, b)
+
+	key, err := Random{}.UUID()
+	require.NoError(t, err)
+	assert.EqualValues(t, 7, key.Version())
+}
@@ -0,0 +1,127 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/things-kit/module/log"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var templates = template.Must(template.ParseFS(templateFS, "templates/*.tmpl"))
+
+// ErrInvalidAddress is returned for a sender or recipient containing a line
+// break, which would inject headers
+var ErrInvalidAddress = errors.New("mail address contains a line break")
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Render builds a message from the named template. Each template defines a
+// "subject" and a "body" block; templates are loaded from templates/<name>.tmpl.
+func Render(name, to string, data any) (Message, error) {
+	tmpl := templates.Lookup(name + ".tmpl")
+	if tmpl == nil {
+		return Message{}, fmt.Errorf("unknown mail template %q", name)
+	}
+
+	var subject, body bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render subject: %w", err)
+	}
+	if err := tmpl.ExecuteTemplate(&body, "body", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render body: %w", err)
+	}
+
+	// The subject ends up in a header: keep it on one line
+	return Message{To: to, Subject: strings.Join(strings.Fields(subject.String()), " "), Body: body.String()}, nil
+}
+
+// Mailer sends email messages
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// SMTPMailer sends messages through an SMTP server
+type SMTPMailer struct {
+	cfg *Config
+}
+
+// NewSMTPMailer creates a new SMTP mailer
+func NewSMTPMailer(cfg *Config) *SMTPMailer {
+	return &SMTPMailer{cfg: cfg}
+}
+
+// Send implements Mailer
+func (m *SMTPMailer) Send(_ context.Context, msg Message) error {
+	var auth smtp.Auth
+	if m.cfg.Username != "" {
+		auth = smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+	}
+
+	data, err := build(m.cfg.From, msg)
+	if err != nil {
+		return err
+	}
+
+	addr := m.cfg.Host + ":" + strconv.Itoa(m.cfg.Port)
+	if err := smtp.SendMail(addr, auth, m.cfg.From, []string{msg.To}, data); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+
+	return nil
+}
+
+// build returns the headers and body of a message. The subject is
+// Q-encoded, so neither a line break nor non-ASCII text in it can break out
+// of its header.
+func build(from string, msg Message) ([]byte, error) {
+	if strings.ContainsAny(from, "\r\n") || strings.ContainsAny(msg.To, "\r\n") {
+		return nil, ErrInvalidAddress
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", msg.Subject))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	buf.WriteString(msg.Body)
+	return buf.Bytes(), nil
+}
+
+// LogMailer logs messages instead of sending them, for local development.
+// Only the recipient and subject are logged: bodies can hold links such as
+// password resets that must not end up in logs.
+type LogMailer struct {
+	log log.Logger
+}
+
+// NewLogMailer creates a new log-only mailer
+func NewLogMailer(logger log.Logger) *LogMailer {
+	return &LogMailer{log: logger}
+}
+
+// Send implements Mailer
+func (m *LogMailer) Send(_ context.Context, msg Message) error {
+	m.log.Info("Mail (dry run)",
+		log.Field{Key: "to", Value: msg.To},
+		log.Field{Key: "subject", Value: msg.Subject},
+		log.Field{Key: "body_bytes", Value: len(msg.Body)},
+	)
+	return nil
+}
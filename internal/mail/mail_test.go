@@ -0,0 +1,46 @@
+package mail
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderWelcome(t *testing.T) {
+	msg, err := Render("welcome", "john@example.com", map[string]string{
+		"Name":  "John",
+		"Email": "john@example.com",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "john@example.com", msg.To)
+	assert.Equal(t, "Welcome, John!", msg.Subject)
+	assert.Contains(t, msg.Body, "Hi John,")
+	assert.Contains(t, msg.Body, "john@example.com")
+}
+
+func TestRenderUnknownTemplate(t *testing.T) {
+	_, err := Render("missing", "john@example.com", nil)
+	assert.Error(t, err)
+}
+
+func TestBuildKeepsHeadersIntact(t *testing.T) {
+	data, err := build("noreply@example.com", Message{
+		To:      "john@example.com",
+		Subject: "Welcome, John\r\nBcc: victim@example.com",
+		Body:    "Hi",
+	})
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "\r\nBcc:")
+	assert.Contains(t, string(data), "Subject: =?UTF-8?q?")
+
+	_, err = build("noreply@example.com", Message{To: "john@example.com\r\nBcc: victim@example.com"})
+	assert.ErrorIs(t, err, ErrInvalidAddress)
+}
+
+func TestRenderKeepsSubjectOnOneLine(t *testing.T) {
+	msg, err := Render("welcome", "john@example.com", map[string]string{"Name": "John\nBcc: x@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "Welcome, John Bcc: x@example.com!", msg.Subject)
+}
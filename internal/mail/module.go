@@ -0,0 +1,78 @@
+package mail
+
+import (
+	"errors"
+
+	"github.com/spf13/viper"
+	"github.com/things-kit/example-db/internal/config"
+	"github.com/things-kit/module/log"
+	"go.uber.org/fx"
+)
+
+// Module provides the mail Queue
+var Module = fx.Module("mail",
+	fx.Provide(NewConfig, NewMailer, NewQueue),
+	config.Validate[*Config]("mail"),
+	fx.Invoke(func(lc fx.Lifecycle, q *Queue) {
+		lc.Append(fx.Hook{OnStart: q.Start, OnStop: q.Stop})
+	}),
+)
+
+// Config holds the mail configuration
+type Config struct {
+	// Mode is "smtp" to send mail or "log" to only log it
+	Mode      string `mapstructure:"mode"`
+	Host      string `mapstructure:"host"`
+	Port      int    `mapstructure:"port"`
+	Username  string `mapstructure:"username"`
+	Password  string `mapstructure:"password"`
+	From      string `mapstructure:"from"`
+	Workers   int    `mapstructure:"workers"`
+	QueueSize int    `mapstructure:"queue_size"`
+}
+
+// NewConfig loads the mail configuration from the "mail" key
+func NewConfig(v *viper.Viper) *Config {
+	cfg := &Config{
+		Mode:      "log",
+		Host:      "localhost",
+		Port:      1025,
+		From:      "no-reply@example.com",
+		Workers:   2,
+		QueueSize: 100,
+	}
+
+	if v != nil {
+		_ = v.UnmarshalKey("mail", cfg)
+	}
+
+	return cfg
+}
+
+// Validate checks the mode, the SMTP server and the worker pool
+func (c *Config) Validate() error {
+	err := errors.Join(
+		config.OneOf("mode", c.Mode, "smtp", "log"),
+		config.Required("from", c.From),
+		config.Positive("workers", c.Workers),
+		config.Positive("queue_size", c.QueueSize),
+	)
+	if c.Mode != "smtp" {
+		return err
+	}
+	return errors.Join(err,
+		config.Required("host", c.Host),
+		config.Between("port", c.Port, 1, 65535),
+	)
+}
+
+// NewMailer returns the mailer for the configured mode
+func NewMailer(cfg *Config, logger log.Logger) Mailer {
+	if cfg.Mode == "smtp" {
+		logger.Info("Sending mail via SMTP", log.Field{Key: "host", Value: cfg.Host})
+		return NewSMTPMailer(cfg)
+	}
+
+	logger.Info("Mail dry-run mode: messages are logged, not sent")
+	return NewLogMailer(logger)
+}
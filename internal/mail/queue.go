@@ -0,0 +1,96 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/things-kit/module/log"
+)
+
+var (
+	// ErrQueueFull is returned when the mail queue cannot take more messages
+	ErrQueueFull = errors.New("mail queue is full")
+	// ErrQueueClosed is returned when enqueueing after the queue was stopped
+	ErrQueueClosed = errors.New("mail queue is closed")
+)
+
+// Queue sends messages asynchronously with a pool of background workers, so
+// slow mail servers never delay the request that triggered the message
+type Queue struct {
+	mailer Mailer
+	cfg    *Config
+	log    log.Logger
+
+	mu       sync.RWMutex
+	closed   bool
+	messages chan Message
+	wg       sync.WaitGroup
+}
+
+// NewQueue creates a new mail queue
+func NewQueue(mailer Mailer, cfg *Config, logger log.Logger) *Queue {
+	return &Queue{
+		mailer:   mailer,
+		cfg:      cfg,
+		log:      logger,
+		messages: make(chan Message, cfg.QueueSize),
+	}
+}
+
+// Enqueue schedules a message for sending without blocking
+func (q *Queue) Enqueue(msg Message) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	select {
+	case q.messages <- msg:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Start launches the workers
+func (q *Queue) Start(context.Context) error {
+	for i := 0; i < q.cfg.Workers; i++ {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			for msg := range q.messages {
+				if err := q.mailer.Send(context.Background(), msg); err != nil {
+					q.log.Error("Failed to send mail", err,
+						log.Field{Key: "to", Value: msg.To},
+						log.Field{Key: "subject", Value: msg.Subject},
+					)
+				}
+			}
+		}()
+	}
+	return nil
+}
+
+// Stop closes the queue and waits for queued messages to be sent
+func (q *Queue) Stop(ctx context.Context) error {
+	q.mu.Lock()
+	q.closed = true
+	close(q.messages)
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,204 @@
+// Package maintenance rejects writes while the service is in maintenance
+// mode, such as during a migration. Reads and health checks keep working.
+// The mode follows the maintenance.enabled setting and can be switched at
+// runtime on the ops server.
+package maintenance
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/things-kit/example-db/internal/config"
+	"github.com/things-kit/example-db/internal/i18n"
+	"github.com/things-kit/example-db/internal/middleware"
+	"github.com/things-kit/example-db/internal/ops"
+	"github.com/things-kit/module/log"
+	"go.uber.org/fx"
+)
+
+// Module adds the maintenance middleware and the /maintenance ops route
+var Module = fx.Module("maintenance",
+	fx.Provide(NewConfig, New),
+	config.Validate[*Config]("maintenance"),
+	config.AsListener(NewReloadListener),
+	middleware.AsMiddleware(NewMiddleware),
+	ops.AsRoute(NewRoute),
+)
+
+// Config holds the maintenance configuration
+type Config struct {
+	// Enabled starts the service in maintenance mode
+	Enabled bool `mapstructure:"enabled"`
+	// RetryAfter is sent in the Retry-After header of rejected writes
+	RetryAfter time.Duration `mapstructure:"retry_after"`
+}
+
+// NewConfig loads the maintenance configuration from the "maintenance" key
+func NewConfig(v *viper.Viper) *Config {
+	cfg := &Config{
+		Enabled:    false,
+		RetryAfter: time.Minute,
+	}
+
+	if v != nil {
+		_ = v.UnmarshalKey("maintenance", cfg)
+	}
+
+	return cfg
+}
+
+// Validate checks the Retry-After duration
+func (c *Config) Validate() error {
+	return config.Positive("retry_after", c.RetryAfter)
+}
+
+// Mode tells whether the service is in maintenance mode. It is safe for
+// concurrent use.
+type Mode struct {
+	log log.Logger
+
+	mu         sync.Mutex
+	enabled    bool
+	retryAfter time.Duration
+	// configured is the last maintenance.enabled applied, so a reload only
+	// overrides a switch made on the ops server when the setting changed
+	configured bool
+}
+
+// New creates the mode from the configuration
+func New(cfg *Config, logger log.Logger) *Mode {
+	return &Mode{
+		log:        logger,
+		enabled:    cfg.Enabled,
+		retryAfter: cfg.RetryAfter,
+		configured: cfg.Enabled,
+	}
+}
+
+// Enabled reports whether writes are rejected
+func (m *Mode) Enabled() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.enabled
+}
+
+// Set turns maintenance mode on or off
+func (m *Mode) Set(enabled bool) {
+	m.mu.Lock()
+	changed := m.enabled != enabled
+	m.enabled = enabled
+	m.mu.Unlock()
+
+	if changed {
+		m.log.Info("Maintenance mode changed", log.Field{Key: "enabled", Value: enabled})
+	}
+}
+
+// apply applies a reloaded configuration
+func (m *Mode) apply(cfg *Config) {
+	m.mu.Lock()
+	m.retryAfter = cfg.RetryAfter
+	changed := m.configured != cfg.Enabled
+	m.configured = cfg.Enabled
+	m.mu.Unlock()
+
+	if changed {
+		m.Set(cfg.Enabled)
+	}
+}
+
+// state returns whether the mode is on and the Retry-After duration
+func (m *Mode) state() (bool, time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.enabled, m.retryAfter
+}
+
+// NewReloadListener applies the maintenance settings when the configuration
+// changes. maintenance.enabled is only applied when it changed, so an
+// unrelated reload keeps a mode switched on the ops server.
+func NewReloadListener(m *Mode) config.Listener {
+	return config.Listener{
+		Name: "maintenance",
+		Reload: func(v *viper.Viper) error {
+			cfg := NewConfig(v)
+			if err := cfg.Validate(); err != nil {
+				return err
+			}
+			m.apply(cfg)
+			return nil
+		},
+	}
+}
+
+// NewMiddleware rejects writes in maintenance mode. It runs after the
+// metrics and tracing middleware, so rejected requests are recorded.
+func NewMiddleware(m *Mode) middleware.Middleware {
+	return middleware.Middleware{
+		Name:    "maintenance",
+		Order:   12,
+		Handler: m.Handle,
+	}
+}
+
+// Handle rejects requests other than GET, HEAD and OPTIONS with 503 and a
+// Retry-After header while maintenance mode is on
+func (m *Mode) Handle(c *gin.Context) {
+	enabled, retryAfter := m.state()
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		enabled = false
+	}
+	if !enabled {
+		c.Next()
+		return
+	}
+
+	c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": i18n.T(c.Request.Context(), "Service is under maintenance, try again later")})
+}
+
+// NewRoute serves GET and PUT /maintenance on the ops server to read and
+// switch the mode, with a body like {"enabled": true}
+func NewRoute(m *Mode) ops.Route {
+	return ops.Route{Pattern: "/maintenance", Handler: NewHandler(m)}
+}
+
+// status is the body of the /maintenance route
+type status struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// NewHandler returns the handler of the /maintenance route
+func NewHandler(m *Mode) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var req status
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&req); err != nil || req.Enabled == nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": `body must be {"enabled": true} or {"enabled": false}`})
+				return
+			}
+			m.Set(*req.Enabled)
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		enabled := m.Enabled()
+		writeJSON(w, http.StatusOK, status{Enabled: &enabled})
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,90 @@
+package maintenance
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/testutil"
+)
+
+// newTestEngine serves GET and POST /users behind the maintenance middleware
+func newTestEngine(m *Mode) *gin.Engine {
+	engine := gin.New()
+	engine.Use(NewMiddleware(m).Handler)
+	engine.GET("/users", func(c *gin.Context) { c.JSON(http.StatusOK, []any{}) })
+	engine.POST("/users", func(c *gin.Context) { c.Status(http.StatusCreated) })
+	return engine
+}
+
+func serve(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w
+}
+
+func TestMiddlewareRejectsWrites(t *testing.T) {
+	m := New(&Config{RetryAfter: 2 * time.Minute}, testutil.NopLogger{})
+	engine := newTestEngine(m)
+
+	assert.Equal(t, http.StatusCreated, serve(engine, http.MethodPost, "/users", "").Code)
+
+	m.Set(true)
+	w := serve(engine, http.MethodPost, "/users", "")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "120", w.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"error":"Service is under maintenance, try again later"}`, w.Body.String())
+	assert.Equal(t, http.StatusOK, serve(engine, http.MethodGet, "/users", "").Code, "reads keep working")
+
+	m.Set(false)
+	assert.Equal(t, http.StatusCreated, serve(engine, http.MethodPost, "/users", "").Code)
+}
+
+func TestReloadOnlyAppliesChanges(t *testing.T) {
+	m := New(NewConfig(nil), testutil.NopLogger{})
+	reload := NewReloadListener(m).Reload
+
+	v := viper.New()
+	v.Set("maintenance.enabled", true)
+	require.NoError(t, reload(v))
+	assert.True(t, m.Enabled())
+
+	// Switched off on the ops server, an unrelated reload keeps it off
+	m.Set(false)
+	v.Set("maintenance.retry_after", "5m")
+	require.NoError(t, reload(v))
+	assert.False(t, m.Enabled())
+
+	v.Set("maintenance.enabled", false)
+	require.NoError(t, reload(v))
+	v.Set("maintenance.enabled", true)
+	require.NoError(t, reload(v))
+	assert.True(t, m.Enabled())
+
+	v.Set("maintenance.retry_after", "0s")
+	assert.Error(t, reload(v))
+}
+
+func TestHandler(t *testing.T) {
+	m := New(NewConfig(nil), testutil.NopLogger{})
+	h := NewHandler(m)
+
+	w := serve(h, http.MethodGet, "/maintenance", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"enabled":false}`, w.Body.String())
+
+	w = serve(h, http.MethodPut, "/maintenance", `{"enabled":true}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"enabled":true}`, w.Body.String())
+	assert.True(t, m.Enabled())
+
+	assert.Equal(t, http.StatusBadRequest, serve(h, http.MethodPut, "/maintenance", `{}`).Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(h, http.MethodPost, "/maintenance", "").Code)
+	assert.True(t, m.Enabled())
+}
@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/things-kit/example-db/internal/middleware"
+	"github.com/things-kit/example-db/internal/ops"
+	"go.uber.org/fx"
+)
+
+// Module provides the Prometheus registry, HTTP instrumentation, the
+// connection pool collectors and the /metrics endpoint of the ops server
+var Module = fx.Module("metrics",
+	fx.Provide(NewRegistry, NewHTTPMetrics),
+	middleware.AsMiddleware(NewMiddleware),
+	ops.AsRoute(NewRoute),
+	fx.Invoke(RegisterDBStats, RegisterPoolStats),
+)
+
+// NewRegistry creates a registry with the Go runtime and process collectors
+func NewRegistry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return reg
+}
+
+// RegisterDBStats exports the connection pool statistics of the database
+func RegisterDBStats(reg *prometheus.Registry, db *sql.DB) {
+	reg.MustRegister(collectors.NewDBStatsCollector(db, "primary"))
+}
+
+// HTTPMetrics holds the HTTP request instruments
+type HTTPMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// NewHTTPMetrics creates and registers the HTTP request instruments
+func NewHTTPMetrics(reg *prometheus.Registry) *HTTPMetrics {
+	m := &HTTPMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests by method, route and status code.",
+		}, []string{"method", "route", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency by method and route.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+	}
+
+	reg.MustRegister(m.requests, m.duration)
+	return m
+}
+
+// NewMiddleware records a request count and latency for every request.
+// Requests are labelled with the route template so IDs don't explode cardinality.
+func NewMiddleware(m *HTTPMetrics) middleware.Middleware {
+	return middleware.Middleware{
+		Name:  "metrics",
+		Order: 10,
+		Handler: func(c *gin.Context) {
+			start := time.Now()
+			c.Next()
+
+			route := c.FullPath()
+			if route == "" {
+				route = "unmatched"
+			}
+
+			m.requests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+			m.duration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+		},
+	}
+}
+
+// NewRoute serves the registry at /metrics
+func NewRoute(reg *prometheus.Registry) ops.Route {
+	return ops.Route{
+		Pattern: "GET /metrics",
+		Handler: promhttp.HandlerFor(reg, promhttp.HandlerOpts{Registry: reg}),
+	}
+}
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolCollector exports the statistics of a pgx pool on every scrape, next
+// to the go_sql_* series of the database/sql pool. Totals since startup are
+// counters; the current connection counts are gauges.
+type PoolCollector struct {
+	pool *pgxpool.Pool
+
+	total           *prometheus.Desc
+	acquired        *prometheus.Desc
+	idle            *prometheus.Desc
+	constructing    *prometheus.Desc
+	max             *prometheus.Desc
+	acquires        *prometheus.Desc
+	acquireDuration *prometheus.Desc
+	emptyAcquires   *prometheus.Desc
+	canceled        *prometheus.Desc
+	newConns        *prometheus.Desc
+	lifetimeClosed  *prometheus.Desc
+	idleClosed      *prometheus.Desc
+}
+
+// NewPoolCollector creates a collector for pool, labelled with db_name
+func NewPoolCollector(pool *pgxpool.Pool, dbName string) *PoolCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc("pgxpool_"+name, help, nil, prometheus.Labels{"db_name": dbName})
+	}
+
+	return &PoolCollector{
+		pool: pool,
+
+		total:           desc("total_connections", "Established connections, in use, idle or being constructed."),
+		acquired:        desc("acquired_connections", "Connections currently in use."),
+		idle:            desc("idle_connections", "Idle connections."),
+		constructing:    desc("constructing_connections", "Connections being established."),
+		max:             desc("max_connections", "Maximum size of the pool."),
+		acquires:        desc("acquires_total", "Successful connection acquires."),
+		acquireDuration: desc("acquire_duration_seconds_total", "Time spent in successful acquires, including the ones that did not wait."),
+		emptyAcquires:   desc("empty_acquires_total", "Successful acquires that waited for a connection because the pool was empty."),
+		canceled:        desc("canceled_acquires_total", "Acquires canceled by their context."),
+		newConns:        desc("new_connections_total", "Connections opened."),
+		lifetimeClosed:  desc("max_lifetime_closed_total", "Connections closed because they reached the maximum lifetime."),
+		idleClosed:      desc("max_idle_closed_total", "Connections closed because they reached the maximum idle time."),
+	}
+}
+
+// RegisterPoolStats exports the connection pool statistics of the pgx pool
+func RegisterPoolStats(reg *prometheus.Registry, pool *pgxpool.Pool) {
+	reg.MustRegister(NewPoolCollector(pool, "primary"))
+}
+
+// Describe implements prometheus.Collector
+func (c *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		c.total, c.acquired, c.idle, c.constructing, c.max,
+		c.acquires, c.acquireDuration, c.emptyAcquires, c.canceled,
+		c.newConns, c.lifetimeClosed, c.idleClosed,
+	} {
+		ch <- d
+	}
+}
+
+// Collect implements prometheus.Collector
+func (c *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.pool.Stat()
+	gauge := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v)
+	}
+	counter := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, v)
+	}
+
+	gauge(c.total, float64(s.TotalConns()))
+	gauge(c.acquired, float64(s.AcquiredConns()))
+	gauge(c.idle, float64(s.IdleConns()))
+	gauge(c.constructing, float64(s.ConstructingConns()))
+	gauge(c.max, float64(s.MaxConns()))
+	counter(c.acquires, float64(s.AcquireCount()))
+	counter(c.acquireDuration, s.AcquireDuration().Seconds())
+	counter(c.emptyAcquires, float64(s.EmptyAcquireCount()))
+	counter(c.canceled, float64(s.CanceledAcquireCount()))
+	counter(c.newConns, float64(s.NewConnsCount()))
+	counter(c.lifetimeClosed, float64(s.MaxLifetimeDestroyCount()))
+	counter(c.idleClosed, float64(s.MaxIdleDestroyCount()))
+}
@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolCollector(t *testing.T) {
+	// The pool does not connect until it is used
+	pool, err := pgxpool.New(context.Background(), "postgres://localhost:1/none?pool_max_conns=4")
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	reg := prometheus.NewRegistry()
+	RegisterPoolStats(reg, pool)
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP pgxpool_max_connections Maximum size of the pool.
+# TYPE pgxpool_max_connections gauge
+pgxpool_max_connections{db_name="primary"} 4
+# HELP pgxpool_acquires_total Successful connection acquires.
+# TYPE pgxpool_acquires_total counter
+pgxpool_acquires_total{db_name="primary"} 0
+`), "pgxpool_max_connections", "pgxpool_acquires_total"))
+
+	n, err := testutil.GatherAndCount(reg)
+	require.NoError(t, err)
+	assert.Equal(t, 12, n, "one series per statistic")
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/fx"
+)
+
+// Middleware is a gin middleware applied to all application routes
+type Middleware struct {
+	// Name identifies the middleware in logs
+	Name string
+	// Order sorts the chain; lower values run first (outermost)
+	Order int
+	// Handler is the gin middleware function
+	Handler gin.HandlerFunc
+}
+
+// AsMiddleware annotates a constructor returning a Middleware so it is added to the Chain
+func AsMiddleware(f any) fx.Option {
+	return fx.Provide(fx.Annotate(f, fx.ResultTags(`group:"middleware"`)))
+}
+
+// Chain is the ordered list of middleware handlers. Handlers pass it to the
+// route groups they create so middleware applies regardless of the order in
+// which handlers register their routes.
+type Chain []gin.HandlerFunc
+
+// Params holds the registered middleware
+type Params struct {
+	fx.In
+
+	Middleware []Middleware `group:"middleware"`
+}
+
+// NewChain orders the registered middleware into a Chain
+func NewChain(p Params) Chain {
+	items := append([]Middleware(nil), p.Middleware...)
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Order < items[j].Order
+	})
+
+	chain := make(Chain, 0, len(items))
+	for _, m := range items {
+		chain = append(chain, m.Handler)
+	}
+	return chain
+}
+
+// Module provides the middleware Chain
+var Module = fx.Module("middleware",
+	fx.Provide(NewChain),
+)
@@ -0,0 +1,92 @@
+-- Baseline schema. Statements use IF NOT EXISTS so databases created from
+-- schema.sql before migrations existed can adopt them.
+
+-- +goose Up
+-- Create users table
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
+    avatar_key TEXT,
+    is_admin BOOLEAN NOT NULL DEFAULT false
+);
+
+-- Create unique index on email; soft-deleted users don't hold on to their address
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users(email) WHERE deleted_at IS NULL;
+
+-- Create index used by the purge worker
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Create outbox table for events written alongside user mutations
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGSERIAL PRIMARY KEY,
+    event_id UUID NOT NULL UNIQUE,
+    event_type VARCHAR(255) NOT NULL,
+    aggregate_id BIGINT NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMP
+);
+
+-- Create partial index so the relay only scans pending events
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox(id) WHERE published_at IS NULL;
+
+-- Create denormalized user directory maintained by the event consumer
+CREATE TABLE IF NOT EXISTS user_directory (
+    user_id BIGINT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Create table of events already applied by consumers, for idempotency
+CREATE TABLE IF NOT EXISTS processed_events (
+    consumer VARCHAR(255) NOT NULL,
+    event_id UUID NOT NULL,
+    processed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (consumer, event_id)
+);
+
+-- Create daily user statistics filled by the nightly rollup job
+CREATE TABLE IF NOT EXISTS user_stats_daily (
+    day DATE PRIMARY KEY,
+    total_users BIGINT NOT NULL,
+    created_users BIGINT NOT NULL,
+    deleted_users BIGINT NOT NULL
+);
+
+-- Create webhook subscriptions receiving user change events
+CREATE TABLE IF NOT EXISTS webhooks (
+    id BIGSERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create log of webhook delivery attempts
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id BIGINT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_id UUID NOT NULL,
+    event_type VARCHAR(255) NOT NULL,
+    attempt INT NOT NULL,
+    status_code INT,
+    error TEXT,
+    duration_ms BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, id);
+
+-- +goose Down
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
+DROP TABLE IF EXISTS user_stats_daily;
+DROP TABLE IF EXISTS processed_events;
+DROP TABLE IF EXISTS user_directory;
+DROP TABLE IF EXISTS outbox;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- Create indexes for filtering and sorting users by their audit columns
+CREATE INDEX IF NOT EXISTS idx_users_created_at ON users(created_at, id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_users_updated_at ON users(updated_at, id) WHERE deleted_at IS NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_users_updated_at;
+DROP INDEX IF EXISTS idx_users_created_at;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- Create audit log of user mutations. user_id has no foreign key so the
+-- history outlives purged users.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    old_data JSONB,
+    new_data JSONB,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_user ON audit_log(user_id, id);
+
+-- +goose Down
+DROP TABLE IF EXISTS audit_log;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- Add a UUID key to users, used as the public ID when users.id_type is "uuid".
+-- New users get a UUIDv7 from the application; existing rows are backfilled.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS uuid UUID;
+UPDATE users SET uuid = gen_random_uuid() WHERE uuid IS NULL;
+ALTER TABLE users ALTER COLUMN uuid SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_uuid ON users(uuid);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_users_uuid;
+ALTER TABLE users DROP COLUMN IF EXISTS uuid;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- Add free-form user preferences. The jsonb_path_ops GIN index serves the
+-- containment (@>) filters used by ?preference.<key>=<value>.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS preferences JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_users_preferences ON users USING GIN (preferences jsonb_path_ops);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_users_preferences;
+ALTER TABLE users DROP COLUMN IF EXISTS preferences;
@@ -0,0 +1,23 @@
+-- +goose Up
+-- Restrict users and audit_log rows to the tenant in the app.tenant_id
+-- setting, which the application sets on every connection when
+-- db.row_level_security is enabled. '*' matches every tenant and is only
+-- used by trusted background jobs. Rows are hidden when the setting is
+-- missing. Table owners and superusers bypass the policies unless the tables
+-- use FORCE ROW LEVEL SECURITY, so connect as a role that doesn't own them.
+ALTER TABLE users ENABLE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON users
+    USING (current_setting('app.tenant_id', true) IN (tenant_id, '*'))
+    WITH CHECK (current_setting('app.tenant_id', true) IN (tenant_id, '*'));
+
+ALTER TABLE audit_log ENABLE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON audit_log
+    USING (current_setting('app.tenant_id', true) IN (tenant_id, '*'))
+    WITH CHECK (current_setting('app.tenant_id', true) IN (tenant_id, '*'));
+
+-- +goose Down
+DROP POLICY IF EXISTS tenant_isolation ON audit_log;
+ALTER TABLE audit_log DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON users;
+ALTER TABLE users DISABLE ROW LEVEL SECURITY;
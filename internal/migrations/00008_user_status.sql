@@ -0,0 +1,9 @@
+-- +goose Up
+-- Add the account status. Suspended users are hidden from default listings
+-- and can't log in; deactivated users closed their account.
+CREATE TYPE user_status AS ENUM ('active', 'suspended', 'deactivated');
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status user_status NOT NULL DEFAULT 'active';
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS status;
+DROP TYPE IF EXISTS user_status;
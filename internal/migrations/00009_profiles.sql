@@ -0,0 +1,20 @@
+-- +goose Up
+-- Create one-to-one user profiles. tenant_id repeats the user's tenant so the
+-- row-level security policy can check it without a join.
+CREATE TABLE IF NOT EXISTS profiles (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    tenant_id VARCHAR(63) NOT NULL DEFAULT 'default',
+    phone VARCHAR(32) NOT NULL DEFAULT '',
+    address TEXT NOT NULL DEFAULT '',
+    bio TEXT NOT NULL DEFAULT '',
+    avatar_url TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE profiles ENABLE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON profiles
+    USING (current_setting('app.tenant_id', true) IN (tenant_id, '*'))
+    WITH CHECK (current_setting('app.tenant_id', true) IN (tenant_id, '*'));
+
+-- +goose Down
+DROP TABLE IF EXISTS profiles;
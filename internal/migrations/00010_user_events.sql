@@ -0,0 +1,21 @@
+-- +goose Up
+-- Create the event log of the event-sourced user storage (users.storage:
+-- events), numbered per user from 1. The users table is its projection.
+-- user_id has no foreign key so the log outlives purged users.
+CREATE TABLE IF NOT EXISTS user_events (
+    user_id BIGINT NOT NULL,
+    version INT NOT NULL,
+    type VARCHAR(64) NOT NULL,
+    data JSONB NOT NULL DEFAULT '{}',
+    tenant_id VARCHAR(63) NOT NULL DEFAULT 'default',
+    occurred_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, version)
+);
+
+ALTER TABLE user_events ENABLE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON user_events
+    USING (current_setting('app.tenant_id', true) IN (tenant_id, '*'))
+    WITH CHECK (current_setting('app.tenant_id', true) IN (tenant_id, '*'));
+
+-- +goose Down
+DROP TABLE IF EXISTS user_events;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- Create the pending webhook deliveries. The outbox relay stores one job per
+-- active webhook and event, and the dispatcher deletes it once delivered or
+-- out of attempts, so deliveries survive a restart.
+CREATE TABLE IF NOT EXISTS webhook_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id BIGINT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_id UUID NOT NULL,
+    event_type VARCHAR(255) NOT NULL,
+    body BYTEA NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (webhook_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_jobs_due ON webhook_jobs(next_attempt_at, id);
+
+-- +goose Down
+DROP TABLE IF EXISTS webhook_jobs;
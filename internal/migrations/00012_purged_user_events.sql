@@ -0,0 +1,9 @@
+-- +goose Up
+-- Delete the events of users that are gone. The purge worker used to leave
+-- them behind, keeping the personal data of purged users past the retention
+-- window; it now deletes them with the users.
+DELETE FROM user_events e
+WHERE NOT EXISTS (SELECT 1 FROM users u WHERE u.id = e.user_id);
+
+-- +goose Down
+-- The deleted events can't be restored.
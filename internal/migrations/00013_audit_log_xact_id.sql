@@ -0,0 +1,11 @@
+-- +goose Up
+-- Record the transaction of each audit entry. Entry IDs are assigned on
+-- insert, not on commit, so the changes feed pages on the transaction and
+-- only lists entries once every transaction before theirs has ended.
+-- Existing entries get the ID of this migration's transaction.
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS xact_id xid8 NOT NULL DEFAULT pg_current_xact_id();
+CREATE INDEX IF NOT EXISTS idx_audit_log_tenant_xact ON audit_log(tenant_id, xact_id, id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_audit_log_tenant_xact;
+ALTER TABLE audit_log DROP COLUMN IF EXISTS xact_id;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- Scope webhooks to the tenant that registered them, so the webhook API only
+-- exposes a tenant's own webhooks and they only receive the events of its
+-- users. Existing webhooks belong to the default tenant. The policy is that
+-- of the users table.
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(63) NOT NULL DEFAULT 'default';
+CREATE INDEX IF NOT EXISTS idx_webhooks_tenant ON webhooks(tenant_id, id);
+
+ALTER TABLE webhooks ENABLE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON webhooks
+    USING (current_setting('app.tenant_id', true) IN (tenant_id, '*'))
+    WITH CHECK (current_setting('app.tenant_id', true) IN (tenant_id, '*'));
+
+-- +goose Down
+DROP POLICY IF EXISTS tenant_isolation ON webhooks;
+ALTER TABLE webhooks DISABLE ROW LEVEL SECURITY;
+
+DROP INDEX IF EXISTS idx_webhooks_tenant;
+ALTER TABLE webhooks DROP COLUMN IF EXISTS tenant_id;
@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationsAreEmbedded(t *testing.T) {
+	files, err := fs.Glob(FS, "*.sql")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, f := range files {
+		data, err := fs.ReadFile(FS, f)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "-- +goose Up", f)
+		assert.Contains(t, string(data), "-- +goose Down", f)
+	}
+}
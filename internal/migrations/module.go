@@ -0,0 +1,54 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/spf13/viper"
+	"github.com/things-kit/module/log"
+	"go.uber.org/fx"
+)
+
+// Module provides the Migrator and, when auto_migrate is set, applies pending
+// migrations on startup before other components use the database
+var Module = fx.Module("migrations",
+	fx.Provide(NewConfig, NewMigrator),
+	fx.Invoke(func(lc fx.Lifecycle, m *Migrator, cfg *Config, logger log.Logger) {
+		if !cfg.AutoMigrate {
+			return
+		}
+		lc.Append(fx.Hook{
+			OnStart: func(ctx context.Context) error {
+				results, err := m.Up(ctx)
+				if err != nil {
+					return err
+				}
+
+				for _, r := range results {
+					logger.Info("Applied migration",
+						log.Field{Key: "version", Value: r.Source.Version},
+						log.Field{Key: "duration", Value: r.Duration.String()},
+					)
+				}
+				return nil
+			},
+		})
+	}),
+)
+
+// Config holds the migration configuration
+type Config struct {
+	AutoMigrate bool `mapstructure:"auto_migrate"`
+}
+
+// NewConfig loads the migration configuration from the "migrations" key
+func NewConfig(v *viper.Viper) *Config {
+	cfg := &Config{
+		AutoMigrate: false,
+	}
+
+	if v != nil {
+		_ = v.UnmarshalKey("migrations", cfg)
+	}
+
+	return cfg
+}
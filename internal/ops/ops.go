@@ -0,0 +1,142 @@
+// Package ops serves operational endpoints — probes, metrics and profiles —
+// on an internal listener, so they are never reachable on the public API
+// port.
+package ops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/things-kit/example-db/internal/config"
+	"github.com/things-kit/module/log"
+	"go.uber.org/fx"
+)
+
+// Module runs the ops server for the lifetime of the application
+var Module = fx.Module("ops",
+	fx.Provide(NewConfig, NewServer),
+	config.Validate[*Config]("ops"),
+	fx.Invoke(func(lc fx.Lifecycle, s *Server) {
+		lc.Append(fx.Hook{
+			OnStart: func(ctx context.Context) error {
+				return s.Start()
+			},
+			OnStop: s.Stop,
+		})
+	}),
+)
+
+// Config holds the ops server configuration
+type Config struct {
+	// Addr is the internal listen address. Publish only the API port; this
+	// one is for probes and scrapers inside the network. It defaults to the
+	// loopback interface, as it serves the debug and maintenance routes.
+	Addr string `mapstructure:"addr"`
+}
+
+// NewConfig loads the ops configuration from the "ops" key
+func NewConfig(v *viper.Viper) *Config {
+	cfg := &Config{
+		Addr: "127.0.0.1:9090",
+	}
+
+	if v != nil {
+		_ = v.UnmarshalKey("ops", cfg)
+	}
+
+	return cfg
+}
+
+// Validate checks the listen address
+func (c *Config) Validate() error {
+	return config.Required("addr", c.Addr)
+}
+
+// Route is an endpoint of the ops server. A nil Handler is skipped, so
+// modules can leave out disabled endpoints.
+type Route struct {
+	// Pattern is an http.ServeMux pattern; a trailing slash matches the subtree
+	Pattern string
+	Handler http.Handler
+}
+
+// AsRoute annotates a constructor returning a Route for the ops server
+func AsRoute(f any) fx.Option {
+	return fx.Provide(fx.Annotate(f, fx.ResultTags(`group:"ops_routes"`)))
+}
+
+// Params holds the ops server dependencies
+type Params struct {
+	fx.In
+
+	Config *Config
+	Routes []Route `group:"ops_routes"`
+	Logger log.Logger
+}
+
+// Server serves the registered routes and the /healthz liveness probe
+type Server struct {
+	cfg *Config
+	srv *http.Server
+	log log.Logger
+}
+
+// NewServer creates the ops server
+func NewServer(p Params) *Server {
+	return &Server{
+		cfg: p.Config,
+		srv: &http.Server{
+			Addr:              p.Config.Addr,
+			Handler:           NewHandler(p.Routes),
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		log: p.Logger,
+	}
+}
+
+// NewHandler routes /healthz and the registered routes
+func NewHandler(routes []Route) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	})
+	for _, r := range routes {
+		if r.Handler != nil {
+			mux.Handle(r.Pattern, r.Handler)
+		}
+	}
+	return mux
+}
+
+// Handler returns the handler serving the ops routes
+func (s *Server) Handler() http.Handler {
+	return s.srv.Handler
+}
+
+// Start begins listening on the ops address
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on ops address: %w", err)
+	}
+
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.log.Error("Ops server stopped", err)
+		}
+	}()
+
+	s.log.Info("Ops server listening", log.Field{Key: "addr", Value: ln.Addr().String()})
+	return nil
+}
+
+// Stop shuts the ops server down
+func (s *Server) Stop(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
@@ -0,0 +1,47 @@
+package outbox
+
+import (
+	"errors"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/things-kit/example-db/internal/config"
+	"go.uber.org/fx"
+)
+
+// Module runs the outbox relay for the lifetime of the application
+var Module = fx.Module("outbox",
+	fx.Provide(NewConfig, NewRelay),
+	config.Validate[*Config]("outbox"),
+	fx.Invoke(func(lc fx.Lifecycle, r *Relay) {
+		lc.Append(fx.Hook{OnStart: r.Start, OnStop: r.Stop})
+	}),
+)
+
+// Config holds the outbox relay configuration
+type Config struct {
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	BatchSize    int           `mapstructure:"batch_size"`
+}
+
+// NewConfig loads the relay configuration from the "outbox" key
+func NewConfig(v *viper.Viper) *Config {
+	cfg := &Config{
+		PollInterval: time.Second,
+		BatchSize:    100,
+	}
+
+	if v != nil {
+		_ = v.UnmarshalKey("outbox", cfg)
+	}
+
+	return cfg
+}
+
+// Validate checks the polling settings
+func (c *Config) Validate() error {
+	return errors.Join(
+		config.Positive("poll_interval", c.PollInterval),
+		config.Positive("batch_size", c.BatchSize),
+	)
+}
@@ -0,0 +1,36 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/things-kit/example-db/internal/events"
+)
+
+// Execer is satisfied by *pgxpool.Pool and pgx.Tx
+type Execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// Write stores an event in the outbox table. Pass the transaction of the
+// mutation that produced the event so both commit or roll back together.
+func Write(ctx context.Context, db Execer, evt events.Event) error {
+	query := `
+		INSERT INTO outbox (event_id, event_type, aggregate_id, payload, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	payload, err := json.Marshal(evt)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	_, err = db.Exec(ctx, query, evt.ID, evt.Type, evt.AggregateID, payload, evt.OccurredAt)
+	if err != nil {
+		return fmt.Errorf("failed to write outbox event: %w", err)
+	}
+
+	return nil
+}
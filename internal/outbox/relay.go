@@ -0,0 +1,163 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/things-kit/example-db/internal/events"
+	"github.com/things-kit/module/log"
+)
+
+// Relay forwards pending outbox rows to the event publisher.
+// Delivery is at-least-once: a row is marked published only after the broker
+// accepted it, so a crash in between re-sends the event on the next run.
+type Relay struct {
+	db        *sql.DB
+	publisher events.Publisher
+	cfg       *Config
+	log       log.Logger
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRelay creates a new outbox relay
+func NewRelay(db *sql.DB, publisher events.Publisher, cfg *Config, logger log.Logger) *Relay {
+	return &Relay{
+		db:        db,
+		publisher: publisher,
+		cfg:       cfg,
+		log:       logger,
+	}
+}
+
+// Start launches the relay loop in the background
+func (r *Relay) Start(context.Context) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.run(ctx)
+	}()
+
+	r.log.Info("Outbox relay started", log.Field{Key: "poll_interval", Value: r.cfg.PollInterval.String()})
+	return nil
+}
+
+// Stop stops the relay loop and waits for the current batch to finish
+func (r *Relay) Stop(ctx context.Context) error {
+	if r.cancel == nil {
+		return nil
+	}
+	r.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Relay) run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Drain full batches back to back before waiting for the next tick
+		n, err := r.RelayBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.log.Error("Failed to relay outbox events", err)
+		}
+		if err == nil && n == r.cfg.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayBatch publishes up to BatchSize pending events in order and marks them
+// as published. It returns the number of events published.
+func (r *Relay) RelayBatch(ctx context.Context) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// SKIP LOCKED lets several relay instances share the table safely
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, payload
+		FROM outbox
+		WHERE published_at IS NULL
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`, r.cfg.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load outbox events: %w", err)
+	}
+
+	type pending struct {
+		id  int64
+		evt events.Event
+	}
+
+	var batch []pending
+	for rows.Next() {
+		var p pending
+		var payload []byte
+		if err := rows.Scan(&p.id, &payload); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		if err := json.Unmarshal(payload, &p.evt); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to decode outbox event %d: %w", p.id, err)
+		}
+		batch = append(batch, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating outbox events: %w", err)
+	}
+
+	published := 0
+	var publishErr error
+	for _, p := range batch {
+		// Stop at the first failure so events for a user are never reordered
+		if publishErr = r.publisher.Publish(ctx, p.evt); publishErr != nil {
+			publishErr = fmt.Errorf("failed to publish event %s: %w", p.evt.ID, publishErr)
+			break
+		}
+
+		_, err := tx.ExecContext(ctx, `UPDATE outbox SET published_at = $1 WHERE id = $2`, time.Now(), p.id)
+		if err != nil {
+			return 0, fmt.Errorf("failed to mark outbox event %d: %w", p.id, err)
+		}
+		published++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return published, publishErr
+}
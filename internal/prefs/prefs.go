@@ -0,0 +1,69 @@
+// Package prefs holds free-form user preferences, stored as a JSONB object.
+package prefs
+
+// Preferences is a JSON object of user preferences, such as
+// {"theme": "dark", "notifications": {"email": true}}
+type Preferences map[string]any
+
+// String returns the string at key, or def when it is missing or not a string
+func (p Preferences) String(key, def string) string {
+	if v, ok := p[key].(string); ok {
+		return v
+	}
+	return def
+}
+
+// Bool returns the boolean at key, or def when it is missing or not a boolean
+func (p Preferences) Bool(key string, def bool) bool {
+	if v, ok := p[key].(bool); ok {
+		return v
+	}
+	return def
+}
+
+// Float returns the number at key, or def when it is missing or not a number
+func (p Preferences) Float(key string, def float64) float64 {
+	if v, ok := p[key].(float64); ok {
+		return v
+	}
+	return def
+}
+
+// Object returns the nested preferences at key, or nil when it is missing or
+// not an object
+func (p Preferences) Object(key string) Preferences {
+	if v, ok := p[key].(map[string]any); ok {
+		return v
+	}
+	return nil
+}
+
+// Merge applies a JSON merge patch (RFC 7386) and returns the result, leaving
+// p untouched: objects are merged recursively, null removes a key and any
+// other value replaces it.
+func (p Preferences) Merge(patch Preferences) Preferences {
+	out := make(Preferences, len(p)+len(patch))
+	for k, v := range p {
+		out[k] = v
+	}
+
+	for k, v := range patch {
+		switch v := v.(type) {
+		case nil:
+			delete(out, k)
+		case map[string]any:
+			out[k] = map[string]any(Preferences(asObject(out[k])).Merge(v))
+		default:
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// asObject returns v when it is an object, so merging into a scalar replaces it
+func asObject(v any) map[string]any {
+	if m, ok := v.(map[string]any); ok {
+		return m
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package prefs
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decode(t *testing.T, s string) Preferences {
+	t.Helper()
+	var p Preferences
+	require.NoError(t, json.Unmarshal([]byte(s), &p))
+	return p
+}
+
+func TestAccessors(t *testing.T) {
+	p := decode(t, `{"theme":"dark","beta":true,"page_size":50,"notifications":{"email":false}}`)
+
+	assert.Equal(t, "dark", p.String("theme", "light"))
+	assert.Equal(t, "light", p.String("beta", "light"), "wrong types fall back to the default")
+	assert.True(t, p.Bool("beta", false))
+	assert.Equal(t, 50.0, p.Float("page_size", 20))
+	assert.False(t, p.Object("notifications").Bool("email", true))
+	assert.Nil(t, p.Object("theme"))
+	assert.Equal(t, "en", Preferences(nil).String("locale", "en"))
+}
+
+func TestMerge(t *testing.T) {
+	p := decode(t, `{"theme":"dark","beta":true,"notifications":{"email":true,"sms":true}}`)
+	patch := decode(t, `{"beta":null,"locale":"de","notifications":{"sms":null,"push":true}}`)
+
+	merged := p.Merge(patch)
+	data, err := json.Marshal(merged)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"theme":"dark","locale":"de","notifications":{"email":true,"push":true}}`, string(data))
+
+	assert.Equal(t, true, p["beta"], "the original is not modified")
+	assert.Equal(t, Preferences{"a": map[string]any{"b": 1.0}},
+		Preferences{"a": "scalar"}.Merge(Preferences{"a": map[string]any{"b": 1.0}}))
+}
@@ -0,0 +1,91 @@
+// Package proxy decides which peers are trusted reverse proxies. Headers that
+// assert something about the caller, such as the actor or the original
+// scheme, are only honored on requests that come through one of them.
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/spf13/viper"
+	"github.com/things-kit/example-db/internal/config"
+	"go.uber.org/fx"
+)
+
+// Module provides the trusted proxy configuration
+var Module = fx.Module("proxy",
+	fx.Provide(NewConfig),
+	config.Validate[*Config]("http"),
+)
+
+// Config holds the trusted proxy part of the "http" section
+type Config struct {
+	// TrustedProxies are the addresses and CIDR ranges of the reverse proxies
+	// in front of the server. They authenticate callers and overwrite the
+	// headers clients could forge. Empty trusts no peer.
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+
+	prefixes []netip.Prefix
+}
+
+// NewConfig loads the trusted proxies from the "http" key
+func NewConfig(v *viper.Viper) *Config {
+	cfg := &Config{}
+
+	if v != nil {
+		_ = v.UnmarshalKey("http", cfg)
+	}
+
+	cfg.prefixes, _ = parsePrefixes(cfg.TrustedProxies)
+	return cfg
+}
+
+// Validate checks that every trusted proxy is an address or CIDR range
+func (c *Config) Validate() error {
+	_, err := parsePrefixes(c.TrustedProxies)
+	return err
+}
+
+// Trusted reports whether r was sent by a trusted proxy
+func (c *Config) Trusted(r *http.Request) bool {
+	if c == nil || len(c.prefixes) == 0 {
+		return false
+	}
+
+	addr, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := addr.Addr().Unmap()
+	for _, p := range c.prefixes {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePrefixes parses addresses and CIDR ranges; an address is a range of one
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if !strings.Contains(v, "/") {
+			addr, err := netip.ParseAddr(v)
+			if err != nil {
+				return nil, fmt.Errorf("trusted_proxies: %q is not an IP address or CIDR range", v)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, fmt.Errorf("trusted_proxies: %q is not an IP address or CIDR range", v)
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return prefixes, nil
+}
@@ -0,0 +1,38 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrusted(t *testing.T) {
+	v := viper.New()
+	v.Set("http.trusted_proxies", []string{"10.0.0.0/8", "192.0.2.7"})
+	cfg := NewConfig(v)
+	assert.NoError(t, cfg.Validate())
+
+	for addr, want := range map[string]bool{
+		"10.1.2.3:4000":          true,
+		"192.0.2.7:4000":         true,
+		"[::ffff:10.1.2.3]:4000": true,
+		"192.0.2.8:4000":         false,
+		"203.0.113.1:4000":       false,
+		"not an address":         false,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = addr
+		assert.Equal(t, want, cfg.Trusted(r), addr)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	assert.False(t, NewConfig(nil).Trusted(r), "no peer is trusted by default")
+}
+
+func TestValidate(t *testing.T) {
+	cfg := &Config{TrustedProxies: []string{"10.0.0.0/8", "proxy.internal"}}
+	assert.ErrorContains(t, cfg.Validate(), `"proxy.internal"`)
+}
@@ -0,0 +1,67 @@
+package purge
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/viper"
+	"github.com/things-kit/example-db/internal/config"
+	"github.com/things-kit/example-db/internal/debug"
+	"github.com/things-kit/example-db/internal/user"
+	"go.uber.org/fx"
+)
+
+// Module runs the purge worker for the lifetime of the application
+var Module = fx.Module("purge",
+	fx.Provide(NewConfig, NewWorker),
+	config.Validate[*Config]("purge"),
+	fx.Invoke(func(lc fx.Lifecycle, w *Worker, reg *prometheus.Registry) {
+		debug.Publish("user_purge", w.Metrics())
+		reg.MustRegister(w.Metrics().Collectors()...)
+		lc.Append(fx.Hook{OnStart: w.Start, OnStop: w.Stop})
+	}),
+	// Changes feed positions older than the retention may have missed users
+	// purged since, so their clients are sent to a full download
+	fx.Invoke(func(cfg *Config, svc *user.Service) {
+		if cfg.Enabled {
+			svc.SetPurgeRetention(cfg.Retention)
+		}
+	}),
+)
+
+// Config holds the purge worker configuration
+type Config struct {
+	Enabled   bool          `mapstructure:"enabled"`
+	Interval  time.Duration `mapstructure:"interval"`
+	Retention time.Duration `mapstructure:"retention"`
+	BatchSize int           `mapstructure:"batch_size"`
+}
+
+// NewConfig loads the purge configuration from the "purge" key
+func NewConfig(v *viper.Viper) *Config {
+	cfg := &Config{
+		Enabled:   true,
+		Interval:  time.Hour,
+		Retention: 30 * 24 * time.Hour,
+		BatchSize: 500,
+	}
+
+	if v != nil {
+		_ = v.UnmarshalKey("purge", cfg)
+	}
+
+	return cfg
+}
+
+// Validate checks the schedule of an enabled worker
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	return errors.Join(
+		config.Positive("interval", c.Interval),
+		config.Positive("retention", c.Retention),
+		config.Positive("batch_size", c.BatchSize),
+	)
+}
@@ -0,0 +1,178 @@
+package purge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/things-kit/example-db/internal/storage"
+	"github.com/things-kit/example-db/internal/tenant"
+	"github.com/things-kit/example-db/internal/user"
+	"github.com/things-kit/module/log"
+)
+
+// Metrics counts purge activity.
+// It implements expvar.Var so it can be published under /debug/vars.
+type Metrics struct {
+	Runs   atomic.Int64
+	Failed atomic.Int64
+	Purged atomic.Int64
+}
+
+// String returns the counters as a JSON object
+func (m *Metrics) String() string {
+	b, _ := json.Marshal(map[string]int64{
+		"runs_total":   m.Runs.Load(),
+		"failed_total": m.Failed.Load(),
+		"purged_total": m.Purged.Load(),
+	})
+	return string(b)
+}
+
+// Collectors returns the Prometheus collectors for these counters
+func (m *Metrics) Collectors() []prometheus.Collector {
+	counter := func(name, help string, v *atomic.Int64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{Name: name, Help: help},
+			func() float64 { return float64(v.Load()) })
+	}
+
+	return []prometheus.Collector{
+		counter("user_purge_runs_total", "Purge worker runs.", &m.Runs),
+		counter("user_purge_failed_total", "Purge worker runs that failed.", &m.Failed),
+		counter("user_purge_purged_total", "Users permanently deleted by the purge worker.", &m.Purged),
+	}
+}
+
+// Worker periodically deletes users that were soft-deleted longer ago than
+// the configured retention window, along with their avatars
+type Worker struct {
+	repo    *user.Repository
+	store   *storage.Store
+	cfg     *Config
+	log     log.Logger
+	metrics *Metrics
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewWorker creates a new purge worker
+func NewWorker(repo *user.Repository, store *storage.Store, cfg *Config, logger log.Logger) *Worker {
+	return &Worker{
+		repo:    repo,
+		store:   store,
+		cfg:     cfg,
+		log:     logger,
+		metrics: &Metrics{},
+	}
+}
+
+// Metrics returns the worker's counters
+func (w *Worker) Metrics() *Metrics {
+	return w.metrics
+}
+
+// Start launches the purge loop in the background. It fails on an invalid
+// schedule, which config.Module reports on startup already, instead of letting
+// the ticker panic.
+func (w *Worker) Start(context.Context) error {
+	if !w.cfg.Enabled {
+		w.log.Info("User purge worker disabled")
+		return nil
+	}
+	if err := w.cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid purge configuration: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+
+		ticker := time.NewTicker(w.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			w.RunOnce(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	w.log.Info("User purge worker started",
+		log.Field{Key: "interval", Value: w.cfg.Interval.String()},
+		log.Field{Key: "retention", Value: w.cfg.Retention.String()},
+	)
+	return nil
+}
+
+// Stop stops the purge loop and waits for a running purge to finish
+func (w *Worker) Stop(ctx context.Context) error {
+	if w.cancel == nil {
+		return nil
+	}
+	w.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RunOnce purges all users past the retention window, in batches, across all
+// tenants. The avatars of each batch are deleted once the batch committed; a
+// failure to delete one is logged and leaves the object behind.
+func (w *Worker) RunOnce(ctx context.Context) {
+	ctx = tenant.WithTenant(ctx, tenant.All)
+	w.metrics.Runs.Add(1)
+	start := time.Now()
+	cutoff := start.Add(-w.cfg.Retention)
+
+	var total int64
+	for {
+		n, avatars, err := w.repo.PurgeDeleted(ctx, cutoff, w.cfg.BatchSize)
+		total += n
+		w.metrics.Purged.Add(n)
+		for _, key := range avatars {
+			if err := w.store.Delete(ctx, key); err != nil {
+				w.log.Error("Failed to delete avatar of purged user", err, log.Field{Key: "key", Value: key})
+			}
+		}
+
+		if err != nil {
+			if ctx.Err() == nil {
+				w.metrics.Failed.Add(1)
+				w.log.Error("Failed to purge deleted users", err, log.Field{Key: "purged", Value: total})
+			}
+			return
+		}
+
+		if n < int64(w.cfg.BatchSize) {
+			break
+		}
+	}
+
+	w.log.Info("Purged deleted users",
+		log.Field{Key: "purged", Value: total},
+		log.Field{Key: "cutoff", Value: cutoff},
+		log.Field{Key: "duration", Value: time.Since(start).String()},
+	)
+}
@@ -0,0 +1,34 @@
+package purge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/things-kit/example-db/internal/testutil"
+)
+
+func TestValidate(t *testing.T) {
+	cfg := NewConfig(nil)
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Interval = 0
+	assert.EqualError(t, cfg.Validate(), "interval must be positive, got 0s")
+
+	cfg.Enabled = false
+	assert.NoError(t, cfg.Validate(), "a disabled worker has no schedule")
+}
+
+func TestStartRejectsZeroInterval(t *testing.T) {
+	cfg := NewConfig(nil)
+	cfg.Interval = 0
+	w := NewWorker(nil, nil, cfg, testutil.NopLogger{})
+
+	assert.ErrorContains(t, w.Start(context.Background()), "interval must be positive")
+	assert.NoError(t, w.Stop(context.Background()))
+
+	cfg.Interval = time.Hour
+	cfg.Enabled = false
+	assert.NoError(t, w.Start(context.Background()))
+}
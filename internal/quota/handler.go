@@ -0,0 +1,43 @@
+package quota
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/things-kit/example-db/internal/i18n"
+	"github.com/things-kit/example-db/internal/middleware"
+	"github.com/things-kit/module/log"
+)
+
+// Handler serves the usage endpoint
+type Handler struct {
+	quota *Quota
+	chain middleware.Chain
+	log   log.Logger
+}
+
+// NewHandler creates a new usage handler
+func NewHandler(q *Quota, chain middleware.Chain, logger log.Logger) *Handler {
+	return &Handler{quota: q, chain: chain, log: logger}
+}
+
+// RegisterRoutes registers the usage route
+func (h *Handler) RegisterRoutes(engine *gin.Engine) {
+	engine.Group("", h.chain...).GET(UsagePath, h.Usage)
+}
+
+// Usage handles GET /users/:id/usage. The ID is the caller as sent in the
+// quota header, so users that never made a request have no usage. The usage
+// changes with every counted request, so the response is not cached.
+func (h *Handler) Usage(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	usage, err := h.quota.Usage(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.log.Error("Failed to get quota usage", err)
+		_ = c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c.Request.Context(), "Failed to get quota usage")})
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
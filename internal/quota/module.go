@@ -0,0 +1,100 @@
+package quota
+
+import (
+	"context"
+	"errors"
+
+	"github.com/spf13/viper"
+	"github.com/things-kit/example-db/internal/config"
+	"github.com/things-kit/example-db/internal/middleware"
+	"github.com/things-kit/module/httpgin"
+	"go.uber.org/fx"
+)
+
+// Module counts the requests of each caller against a daily quota and serves
+// GET /users/:id/usage
+var Module = fx.Module("quota",
+	fx.Provide(NewConfig, NewStore, New),
+	config.Validate[*Config]("quota"),
+	config.AsListener(NewReloadListener),
+	middleware.AsMiddleware(NewMiddleware),
+	httpgin.AsGinHandler(NewHandler),
+	fx.Invoke(func(lc fx.Lifecycle, s Store) {
+		lc.Append(fx.Hook{
+			OnStop: func(context.Context) error {
+				return s.Close()
+			},
+		})
+	}),
+)
+
+// NewReloadListener applies enabled and daily_limit when the configuration
+// changes. Counts are kept, so a new limit applies to today's requests.
+func NewReloadListener(q *Quota) config.Listener {
+	return config.Listener{
+		Name: "quota",
+		Reload: func(v *viper.Viper) error {
+			cfg := NewConfig(v)
+			if err := cfg.Validate(); err != nil {
+				return err
+			}
+			q.SetLimit(cfg.Enabled, cfg.DailyLimit)
+			return nil
+		},
+	}
+}
+
+// Storage backends of the request counts
+const (
+	BackendMemory = "memory"
+	BackendRedis  = "redis"
+)
+
+// Config holds the quota configuration
+type Config struct {
+	// Enabled counts requests and rejects them once the quota is used up
+	Enabled bool `mapstructure:"enabled"`
+	// DailyLimit is the number of requests a caller may make per UTC day
+	DailyLimit int64 `mapstructure:"daily_limit"`
+	// Header names the request header identifying the caller, such as the
+	// user ID or API key set by an authenticating proxy. Requests without it
+	// are not counted.
+	Header string `mapstructure:"header"`
+	// Backend is memory or redis
+	Backend string `mapstructure:"backend"`
+	// RedisAddr is the host:port of the redis backend
+	RedisAddr string `mapstructure:"redis_addr"`
+}
+
+// NewConfig loads the quota configuration from the "quota" key
+func NewConfig(v *viper.Viper) *Config {
+	cfg := &Config{
+		Enabled:    false,
+		DailyLimit: 10000,
+		Header:     "X-User-ID",
+		Backend:    BackendMemory,
+		RedisAddr:  "localhost:6379",
+	}
+
+	if v != nil {
+		_ = v.UnmarshalKey("quota", cfg)
+	}
+
+	return cfg
+}
+
+// Validate checks the settings of enabled quotas
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	err := errors.Join(
+		config.Positive("daily_limit", c.DailyLimit),
+		config.Required("header", c.Header),
+		config.OneOf("backend", c.Backend, BackendMemory, BackendRedis),
+	)
+	if c.Backend == BackendRedis {
+		err = errors.Join(err, config.Required("redis_addr", c.RedisAddr))
+	}
+	return err
+}
@@ -0,0 +1,130 @@
+// Package quota enforces a daily request quota per caller. Callers are
+// identified by a request header, counted per tenant and UTC day, and
+// rejected with 429 once they used up their quota.
+package quota
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/things-kit/example-db/internal/clock"
+	"github.com/things-kit/example-db/internal/i18n"
+	"github.com/things-kit/example-db/internal/middleware"
+	"github.com/things-kit/example-db/internal/tenant"
+	"github.com/things-kit/module/log"
+)
+
+// UsagePath is the route of the usage endpoint. Requests to it are not
+// counted, so callers can check their usage after running out.
+const UsagePath = "/users/:id/usage"
+
+// Usage is the quota of a caller for the current day
+type Usage struct {
+	// Limit is the number of requests allowed per day
+	Limit int64 `json:"limit"`
+	// Used is the number of requests made today, including rejected ones
+	Used int64 `json:"used"`
+	// Remaining is the number of requests left today
+	Remaining int64 `json:"remaining"`
+	// ResetsAt is when the next day starts and the count is reset
+	ResetsAt time.Time `json:"resets_at"`
+}
+
+// limits is the part of the configuration that can be reloaded
+type limits struct {
+	enabled bool
+	daily   int64
+}
+
+// Quota counts requests and enforces the daily limit
+type Quota struct {
+	limits atomic.Pointer[limits]
+	header string
+	store  Store
+	clock  clock.Clock
+	log    log.Logger
+}
+
+// New creates a quota. clk may be nil to use the system clock.
+func New(cfg *Config, store Store, clk clock.Clock, logger log.Logger) *Quota {
+	q := &Quota{header: cfg.Header, store: store, clock: clock.Or(clk), log: logger}
+	q.SetLimit(cfg.Enabled, cfg.DailyLimit)
+	return q
+}
+
+// SetLimit turns counting on or off and changes the daily limit
+func (q *Quota) SetLimit(enabled bool, daily int64) {
+	q.limits.Store(&limits{enabled: enabled, daily: daily})
+}
+
+// NewMiddleware runs the quota after the tenant and actor are resolved and
+// before the response cache, so cached responses count too
+func NewMiddleware(q *Quota) middleware.Middleware {
+	return middleware.Middleware{
+		Name:    "quota",
+		Order:   25,
+		Handler: q.Handle,
+	}
+}
+
+// Handle counts the request against the quota of its caller and rejects it
+// with 429 once the quota is used up. Requests without a caller are not
+// counted. When the store fails the request is let through.
+func (q *Quota) Handle(c *gin.Context) {
+	l := q.limits.Load()
+	caller := c.GetHeader(q.header)
+	if !l.enabled || caller == "" || c.FullPath() == UsagePath {
+		c.Next()
+		return
+	}
+
+	ctx := c.Request.Context()
+	resets := q.resetsAt()
+	used, err := q.store.Incr(ctx, q.key(ctx, caller), resets)
+	if err != nil {
+		q.log.Error("Failed to count request against the quota", err)
+		c.Next()
+		return
+	}
+
+	usage := newUsage(l.daily, used, resets)
+	c.Header("X-RateLimit-Limit", strconv.FormatInt(usage.Limit, 10))
+	c.Header("X-RateLimit-Remaining", strconv.FormatInt(usage.Remaining, 10))
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(resets.Unix(), 10))
+	if used > l.daily {
+		c.Header("Retry-After", strconv.Itoa(int(resets.Sub(q.clock.Now()).Seconds())+1))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": i18n.T(ctx, "Daily quota exceeded")})
+		return
+	}
+	c.Next()
+}
+
+// Usage returns the usage of caller in the tenant of ctx for the current day
+func (q *Quota) Usage(ctx context.Context, caller string) (Usage, error) {
+	used, err := q.store.Get(ctx, q.key(ctx, caller))
+	if err != nil {
+		return Usage{}, err
+	}
+	return newUsage(q.limits.Load().daily, used, q.resetsAt()), nil
+}
+
+// key identifies the counter of caller in the tenant of ctx for the current
+// day
+func (q *Quota) key(ctx context.Context, caller string) string {
+	day := q.clock.Now().UTC().Format(time.DateOnly)
+	return tenant.FromContext(ctx) + ":" + day + ":" + caller
+}
+
+// resetsAt returns the start of the next UTC day
+func (q *Quota) resetsAt() time.Time {
+	now := q.clock.Now().UTC()
+	return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+}
+
+func newUsage(limit, used int64, resets time.Time) Usage {
+	return Usage{Limit: limit, Used: used, Remaining: max(limit-used, 0), ResetsAt: resets}
+}
@@ -0,0 +1,131 @@
+package quota
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/clock"
+	"github.com/things-kit/example-db/internal/proxy"
+	"github.com/things-kit/example-db/internal/tenant"
+	"github.com/things-kit/example-db/internal/testutil"
+)
+
+// newTestEngine serves GET /users and the usage endpoint behind a quota of
+// limit requests a day, on a clock set to 2024-01-02 22:00 UTC
+func newTestEngine(t *testing.T, limit int64) (*gin.Engine, *Quota, *clock.Fake) {
+	t.Helper()
+
+	clk := clock.NewFake(time.Date(2024, 1, 2, 22, 0, 0, 0, time.UTC))
+	store := NewMemoryStore(clk)
+
+	cfg := NewConfig(nil)
+	cfg.Enabled = true
+	cfg.DailyLimit = limit
+	q := New(cfg, store, clk, testutil.NopLogger{})
+
+	// Trust the peer address of httptest requests to name the tenant
+	v := viper.New()
+	v.Set("http.trusted_proxies", []string{"192.0.2.1"})
+	chain := []gin.HandlerFunc{
+		tenant.NewMiddleware(tenant.NewConfig(nil), proxy.NewConfig(v)).Handler,
+		NewMiddleware(q).Handler,
+	}
+	engine := gin.New()
+	engine.Group("/users", chain...).GET("", func(c *gin.Context) {
+		c.JSON(http.StatusOK, []any{})
+	})
+	NewHandler(q, chain, testutil.NopLogger{}).RegisterRoutes(engine)
+	return engine, q, clk
+}
+
+func request(engine *gin.Engine, path, caller, tenantID string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if caller != "" {
+		req.Header.Set("X-User-ID", caller)
+	}
+	if tenantID != "" {
+		req.Header.Set("X-Tenant-ID", tenantID)
+	}
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func usage(t *testing.T, engine *gin.Engine, id, tenantID string) Usage {
+	t.Helper()
+
+	w := request(engine, "/users/"+id+"/usage", id, tenantID)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"), "the usage changes with every request")
+	var u Usage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &u))
+	return u
+}
+
+func TestQuotaRejectsOnceUsedUp(t *testing.T) {
+	engine, _, _ := newTestEngine(t, 3)
+
+	for i := range 3 {
+		w := request(engine, "/users", "1", "")
+		require.Equal(t, http.StatusOK, w.Code, "request %d", i+1)
+		assert.Equal(t, "3", w.Header().Get("X-RateLimit-Limit"))
+		assert.Equal(t, []string{"2", "1", "0"}[i], w.Header().Get("X-RateLimit-Remaining"))
+	}
+
+	w := request(engine, "/users", "1", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.JSONEq(t, `{"error":"Daily quota exceeded"}`, w.Body.String())
+	assert.Equal(t, "7201", w.Header().Get("Retry-After"))
+	assert.Equal(t, "1704240000", w.Header().Get("X-RateLimit-Reset"))
+
+	// Other callers, tenants and anonymous requests have their own count
+	assert.Equal(t, http.StatusOK, request(engine, "/users", "2", "").Code)
+	assert.Equal(t, http.StatusOK, request(engine, "/users", "1", "acme").Code)
+	assert.Equal(t, http.StatusOK, request(engine, "/users", "", "").Code)
+
+	// Checking the usage is allowed and not counted
+	assert.Equal(t, Usage{
+		Limit:     3,
+		Used:      4,
+		Remaining: 0,
+		ResetsAt:  time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC),
+	}, usage(t, engine, "1", ""))
+	assert.Equal(t, int64(4), usage(t, engine, "1", "").Used)
+	assert.Equal(t, int64(1), usage(t, engine, "1", "acme").Used)
+	assert.Equal(t, int64(0), usage(t, engine, "3", "").Used)
+}
+
+func TestQuotaResetsDaily(t *testing.T) {
+	engine, _, clk := newTestEngine(t, 1)
+
+	assert.Equal(t, http.StatusOK, request(engine, "/users", "1", "").Code)
+	assert.Equal(t, http.StatusTooManyRequests, request(engine, "/users", "1", "").Code)
+
+	clk.Advance(2 * time.Hour)
+	assert.Equal(t, http.StatusOK, request(engine, "/users", "1", "").Code)
+	assert.Equal(t, time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC), usage(t, engine, "1", "").ResetsAt)
+}
+
+func TestQuotaSetLimit(t *testing.T) {
+	engine, q, _ := newTestEngine(t, 1)
+
+	request(engine, "/users", "1", "")
+	assert.Equal(t, http.StatusTooManyRequests, request(engine, "/users", "1", "").Code)
+
+	q.SetLimit(true, 10)
+	w := request(engine, "/users", "1", "")
+	assert.Equal(t, http.StatusOK, w.Code, "the new limit applies to today's count")
+	assert.Equal(t, "7", w.Header().Get("X-RateLimit-Remaining"))
+
+	q.SetLimit(false, 10)
+	w = request(engine, "/users", "1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("X-RateLimit-Limit"), "a disabled quota doesn't count")
+}
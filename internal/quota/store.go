@@ -0,0 +1,139 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/things-kit/example-db/internal/clock"
+)
+
+// Store holds request counters. A counter expires at the time given when it
+// is first incremented, which is the end of the day it counts.
+type Store interface {
+	// Incr increments the counter under key and returns its new value. A new
+	// counter expires at expires.
+	Incr(ctx context.Context, key string, expires time.Time) (int64, error)
+	// Get returns the counter under key, or 0 if there is none
+	Get(ctx context.Context, key string) (int64, error)
+	// Close releases the store's connections
+	Close() error
+}
+
+// NewStore creates the store of the configured backend. clk may be nil to
+// use the system clock.
+func NewStore(cfg *Config, clk clock.Clock) (Store, error) {
+	switch cfg.Backend {
+	case BackendMemory, "":
+		return NewMemoryStore(clk), nil
+	case BackendRedis:
+		return NewRedisStore(redis.NewClient(&redis.Options{Addr: cfg.RedisAddr})), nil
+	default:
+		return nil, fmt.Errorf("unknown quota.backend %q", cfg.Backend)
+	}
+}
+
+// MemoryStore keeps counters in process memory. Each replica of the service
+// counts on its own, so with n replicas a caller may make up to n times the
+// quota.
+type MemoryStore struct {
+	clock clock.Clock
+
+	mu       sync.Mutex
+	counters map[string]memoryCounter
+}
+
+type memoryCounter struct {
+	n       int64
+	expires time.Time
+}
+
+// NewMemoryStore creates an empty store. Counters expire by clk, which may be
+// nil to use the system clock.
+func NewMemoryStore(clk clock.Clock) *MemoryStore {
+	return &MemoryStore{clock: clock.Or(clk), counters: map[string]memoryCounter{}}
+}
+
+// Incr increments the counter under key, starting a new one when it has
+// expired by the store's clock
+func (s *MemoryStore) Incr(_ context.Context, key string, expires time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	c, ok := s.counters[key]
+	if !ok || !now.Before(c.expires) {
+		// Drop the counters of past days before starting a new one
+		for k, old := range s.counters {
+			if !now.Before(old.expires) {
+				delete(s.counters, k)
+			}
+		}
+		c = memoryCounter{expires: expires}
+	}
+	c.n++
+	s.counters[key] = c
+	return c.n, nil
+}
+
+// Get returns the counter under key, or 0 if it has expired
+func (s *MemoryStore) Get(_ context.Context, key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.counters[key]
+	if !ok || !s.clock.Now().Before(c.expires) {
+		return 0, nil
+	}
+	return c.n, nil
+}
+
+// Close does nothing, as the counters live in memory
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// RedisStore keeps counters in Redis, shared by every replica of the service
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore creates a store on a Redis client
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+// Incr increments the counter under key and sets its expiry in one
+// transaction
+func (s *RedisStore) Incr(ctx context.Context, key string, expires time.Time) (int64, error) {
+	var incr *redis.IntCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, "quota:"+key)
+		pipe.ExpireAt(ctx, "quota:"+key, expires)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count request: %w", err)
+	}
+	return incr.Val(), nil
+}
+
+// Get returns the counter under key, or 0 if Redis has expired it
+func (s *RedisStore) Get(ctx context.Context, key string) (int64, error) {
+	n, err := s.client.Get(ctx, "quota:"+key).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get request count: %w", err)
+	}
+	return n, nil
+}
+
+// Close closes the Redis client
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
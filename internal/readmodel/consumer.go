@@ -0,0 +1,81 @@
+package readmodel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+	"github.com/things-kit/example-db/internal/events"
+	"github.com/things-kit/module/log"
+)
+
+// queueGroup spreads events across all running instances of the consumer
+const queueGroup = "user-directory"
+
+// Consumer subscribes to user events on NATS and feeds them to the projector
+type Consumer struct {
+	cfg       *events.NATSConfig
+	projector *Projector
+	log       log.Logger
+
+	conn *nats.Conn
+	sub  *nats.Subscription
+}
+
+// NewConsumer creates a new consumer
+func NewConsumer(cfg *events.NATSConfig, projector *Projector, logger log.Logger) *Consumer {
+	return &Consumer{
+		cfg:       cfg,
+		projector: projector,
+		log:       logger,
+	}
+}
+
+// Start connects to NATS and subscribes to user events
+func (c *Consumer) Start(context.Context) error {
+	if !c.cfg.Enabled {
+		c.log.Info("User event consumer disabled")
+		return nil
+	}
+
+	conn, err := nats.Connect(c.cfg.URL, nats.Name("example-db-consumer"))
+	if err != nil {
+		return fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+
+	subject := c.cfg.Subject + ".>"
+	sub, err := conn.QueueSubscribe(subject, queueGroup, c.handle)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to subscribe to %s: %w", subject, err)
+	}
+
+	c.conn = conn
+	c.sub = sub
+	c.log.Info("User event consumer started", log.Field{Key: "subject", Value: subject})
+	return nil
+}
+
+// Stop drains the subscription, letting in-flight messages finish
+func (c *Consumer) Stop(context.Context) error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Drain()
+}
+
+func (c *Consumer) handle(msg *nats.Msg) {
+	var evt events.Event
+	if err := json.Unmarshal(msg.Data, &evt); err != nil {
+		c.log.Error("Failed to decode user event", err, log.Field{Key: "subject", Value: msg.Subject})
+		return
+	}
+
+	if err := c.projector.Apply(context.Background(), evt); err != nil {
+		c.log.Error("Failed to apply user event", err,
+			log.Field{Key: "event_id", Value: evt.ID},
+			log.Field{Key: "type", Value: evt.Type},
+		)
+	}
+}
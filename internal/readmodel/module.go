@@ -0,0 +1,12 @@
+package readmodel
+
+import "go.uber.org/fx"
+
+// Module runs the user event consumer that maintains the user directory.
+// It requires the events module for the NATS configuration.
+var Module = fx.Module("readmodel",
+	fx.Provide(NewProjector, NewConsumer),
+	fx.Invoke(func(lc fx.Lifecycle, c *Consumer) {
+		lc.Append(fx.Hook{OnStart: c.Start, OnStop: c.Stop})
+	}),
+)
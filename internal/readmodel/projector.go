@@ -0,0 +1,99 @@
+package readmodel
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/things-kit/example-db/internal/events"
+)
+
+// consumerName identifies this consumer in the processed_events table
+const consumerName = "user_directory"
+
+// directoryEntry is the subset of the user payload kept in the directory
+type directoryEntry struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Projector maintains the user_directory read model from user events
+type Projector struct {
+	db *sql.DB
+}
+
+// NewProjector creates a new projector
+func NewProjector(db *sql.DB) *Projector {
+	return &Projector{db: db}
+}
+
+// Apply applies an event to the read model. Events that were already applied
+// are skipped, so redelivered events are harmless.
+func (p *Projector) Apply(ctx context.Context, evt events.Event) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO processed_events (consumer, event_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, consumerName, evt.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record event: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return nil
+	}
+
+	if err := apply(ctx, tx, evt); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func apply(ctx context.Context, tx *sql.Tx, evt events.Event) error {
+	switch evt.Type {
+	case events.UserCreated, events.UserUpdated:
+		var entry directoryEntry
+		if err := json.Unmarshal(evt.Data, &entry); err != nil {
+			return fmt.Errorf("failed to decode user payload: %w", err)
+		}
+
+		// Guard on updated_at so an older event can't overwrite newer state
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO user_directory (user_id, name, email, updated_at)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (user_id) DO UPDATE
+			SET name = EXCLUDED.name, email = EXCLUDED.email, updated_at = EXCLUDED.updated_at
+			WHERE user_directory.updated_at <= EXCLUDED.updated_at
+		`, evt.AggregateID, entry.Name, entry.Email, entry.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to upsert directory entry: %w", err)
+		}
+
+	case events.UserDeleted, events.UserErased:
+		_, err := tx.ExecContext(ctx, `DELETE FROM user_directory WHERE user_id = $1`, evt.AggregateID)
+		if err != nil {
+			return fmt.Errorf("failed to delete directory entry: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package reqlog
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/things-kit/example-db/internal/middleware"
+	"github.com/things-kit/module/log"
+	"go.uber.org/fx"
+)
+
+// Header carries the request ID. An ID sent by the client is kept so logs can
+// be correlated across services; otherwise one is generated. It is echoed in
+// the response either way.
+const Header = "X-Request-ID"
+
+// validID limits client-supplied request IDs to short, log-safe tokens
+var validID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// Module adds the middleware that records the fields of each request
+var Module = fx.Module("reqlog",
+	middleware.AsMiddleware(NewMiddleware),
+)
+
+type fieldsKey struct{}
+type requestIDKey struct{}
+
+// WithFields returns a context whose logger adds fields to every message,
+// after the fields already recorded in ctx
+func WithFields(ctx context.Context, fields ...log.Field) context.Context {
+	prev := contextFields(ctx)
+	all := make([]log.Field, 0, len(prev)+len(fields))
+	all = append(append(all, prev...), fields...)
+	return context.WithValue(ctx, fieldsKey{}, all)
+}
+
+func contextFields(ctx context.Context) []log.Field {
+	fields, _ := ctx.Value(fieldsKey{}).([]log.Field)
+	return fields
+}
+
+// FromContext returns a child of logger adding the fields recorded in ctx, or
+// logger itself when there are none
+func FromContext(ctx context.Context, logger log.Logger) log.Logger {
+	return With(logger, contextFields(ctx)...)
+}
+
+// RequestID returns the ID of the request ctx belongs to, or ""
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// With returns a child of logger adding fields to every Info and Error
+// message. Other methods are passed through without them.
+func With(logger log.Logger, fields ...log.Field) log.Logger {
+	if len(fields) == 0 || logger == nil {
+		return logger
+	}
+	if parent, ok := logger.(fieldLogger); ok {
+		all := make([]log.Field, 0, len(parent.fields)+len(fields))
+		return fieldLogger{Logger: parent.Logger, fields: append(append(all, parent.fields...), fields...)}
+	}
+	return fieldLogger{Logger: logger, fields: fields}
+}
+
+type fieldLogger struct {
+	log.Logger
+	fields []log.Field
+}
+
+func (l fieldLogger) Info(msg string, fields ...log.Field) {
+	l.Logger.Info(msg, l.with(fields)...)
+}
+
+func (l fieldLogger) Error(msg string, err error, fields ...log.Field) {
+	l.Logger.Error(msg, err, l.with(fields)...)
+}
+
+func (l fieldLogger) with(fields []log.Field) []log.Field {
+	all := make([]log.Field, 0, len(l.fields)+len(fields))
+	return append(append(all, l.fields...), fields...)
+}
+
+// NewMiddleware assigns each request an ID and records it with the method and
+// route in the request context, so loggers taken from it carry them
+func NewMiddleware() middleware.Middleware {
+	return middleware.Middleware{
+		Name:    "reqlog",
+		Order:   -5,
+		Handler: handle,
+	}
+}
+
+func handle(c *gin.Context) {
+	id := c.GetHeader(Header)
+	if !validID.MatchString(id) {
+		id = uuid.NewString()
+	}
+	c.Header(Header, id)
+
+	ctx := context.WithValue(c.Request.Context(), requestIDKey{}, id)
+	ctx = WithFields(ctx,
+		log.Field{Key: "request_id", Value: id},
+		log.Field{Key: "method", Value: c.Request.Method},
+		log.Field{Key: "route", Value: c.FullPath()},
+	)
+	c.Request = c.Request.WithContext(ctx)
+	c.Next()
+}
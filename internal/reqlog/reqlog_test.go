@@ -0,0 +1,89 @@
+package reqlog
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/module/log"
+)
+
+// recordingLogger keeps the fields of every Info message
+type recordingLogger struct {
+	testutil.NopLogger
+	infos []map[string]any
+}
+
+func (l *recordingLogger) Info(_ string, fields ...log.Field) {
+	m := map[string]any{}
+	for _, f := range fields {
+		m[f.Key] = f.Value
+	}
+	l.infos = append(l.infos, m)
+}
+
+func TestMiddlewareRecordsFields(t *testing.T) {
+	logger := &recordingLogger{}
+	engine := gin.New()
+	engine.Use(NewMiddleware().Handler)
+	engine.GET("/users/:id", func(c *gin.Context) {
+		ctx := WithFields(c.Request.Context(), log.Field{Key: "user_id", Value: c.Param("id")})
+		FromContext(ctx, logger).Info("handled", log.Field{Key: "extra", Value: 1})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	req.Header.Set(Header, "req-1")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+
+	assert.Equal(t, "req-1", w.Header().Get(Header))
+	require.Len(t, logger.infos, 1)
+	assert.Equal(t, map[string]any{
+		"request_id": "req-1",
+		"method":     http.MethodGet,
+		"route":      "/users/:id",
+		"user_id":    "42",
+		"extra":      1,
+	}, logger.infos[0])
+}
+
+func TestMiddlewareGeneratesID(t *testing.T) {
+	var got string
+	engine := gin.New()
+	engine.Use(NewMiddleware().Handler)
+	engine.GET("/", func(c *gin.Context) {
+		got = RequestID(c.Request.Context())
+	})
+
+	for _, header := range []string{"", "has spaces", strings.Repeat("a", 200)} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if header != "" {
+			req.Header.Set(Header, header)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+
+		assert.Len(t, got, 36, "%q", header)
+		assert.Equal(t, got, w.Header().Get(Header))
+	}
+}
+
+func TestFromContextWithoutFields(t *testing.T) {
+	logger := &recordingLogger{}
+	assert.Same(t, logger, FromContext(context.Background(), logger))
+}
+
+func TestWithFlattens(t *testing.T) {
+	logger := &recordingLogger{}
+	child := With(With(logger, log.Field{Key: "a", Value: 1}), log.Field{Key: "b", Value: 2})
+
+	child.Info("msg")
+	assert.Equal(t, map[string]any{"a": 1, "b": 2}, logger.infos[0])
+	assert.Same(t, logger, child.(fieldLogger).Logger)
+}
@@ -0,0 +1,36 @@
+package scheduler
+
+import (
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+)
+
+// Module runs all jobs registered with AsJob
+var Module = fx.Module("scheduler",
+	fx.Provide(NewConfig, New),
+	fx.Invoke(func(lc fx.Lifecycle, s *Scheduler) {
+		lc.Append(fx.Hook{OnStart: s.Start, OnStop: s.Stop})
+	}),
+)
+
+// Config holds the scheduler configuration
+type Config struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	Timezone string `mapstructure:"timezone"`
+	// Jobs overrides the schedule of individual jobs by name
+	Jobs map[string]string `mapstructure:"jobs"`
+}
+
+// NewConfig loads the scheduler configuration from the "scheduler" key
+func NewConfig(v *viper.Viper) *Config {
+	cfg := &Config{
+		Enabled:  true,
+		Timezone: "UTC",
+	}
+
+	if v != nil {
+		_ = v.UnmarshalKey("scheduler", cfg)
+	}
+
+	return cfg
+}
@@ -0,0 +1,148 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/things-kit/module/log"
+	"go.uber.org/fx"
+)
+
+// Job is a unit of work run on a cron schedule
+type Job struct {
+	// Name identifies the job in logs and configuration overrides
+	Name string
+	// Schedule is a standard five-field cron spec or a descriptor like "@daily"
+	Schedule string
+	// Run performs the work; ctx is cancelled when the application stops
+	Run func(ctx context.Context) error
+}
+
+// AsJob annotates a constructor returning a Job so the scheduler picks it up
+func AsJob(f any) fx.Option {
+	return fx.Provide(fx.Annotate(f, fx.ResultTags(`group:"scheduler_jobs"`)))
+}
+
+// Params holds the scheduler dependencies
+type Params struct {
+	fx.In
+
+	Config *Config
+	Logger log.Logger
+	Jobs   []Job `group:"scheduler_jobs"`
+}
+
+// Scheduler runs registered jobs on their schedules. A run is skipped while
+// the previous run of the same job is still going, and panics are recovered
+// and logged rather than crashing the process.
+type Scheduler struct {
+	cron *cron.Cron
+	cfg  *Config
+	log  log.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// New creates a scheduler with all registered jobs
+func New(p Params) (*Scheduler, error) {
+	loc := time.Local
+	if p.Config.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(p.Config.Timezone); err != nil {
+			return nil, fmt.Errorf("invalid scheduler timezone: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Scheduler{
+		cron:   cron.New(cron.WithLocation(loc)),
+		cfg:    p.Config,
+		log:    p.Logger,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	for _, job := range p.Jobs {
+		if err := s.Register(job); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// Register adds a job. The schedule can be overridden via scheduler.jobs.<name>.
+func (s *Scheduler) Register(job Job) error {
+	schedule := job.Schedule
+	if override, ok := s.cfg.Jobs[job.Name]; ok {
+		schedule = override
+	}
+
+	wrapped := cron.NewChain(cron.SkipIfStillRunning(cron.DiscardLogger)).Then(s.wrap(job))
+	if _, err := s.cron.AddJob(schedule, wrapped); err != nil {
+		return fmt.Errorf("failed to schedule job %s: %w", job.Name, err)
+	}
+
+	s.log.Info("Scheduled job",
+		log.Field{Key: "job", Value: job.Name},
+		log.Field{Key: "schedule", Value: schedule},
+	)
+	return nil
+}
+
+// wrap adds logging and panic recovery around a job run
+func (s *Scheduler) wrap(job Job) cron.Job {
+	return cron.FuncJob(func() {
+		start := time.Now()
+		defer func() {
+			if r := recover(); r != nil {
+				s.log.Error("Job panicked", fmt.Errorf("panic: %v", r),
+					log.Field{Key: "job", Value: job.Name},
+					log.Field{Key: "stack", Value: string(debug.Stack())},
+				)
+			}
+		}()
+
+		if err := job.Run(s.ctx); err != nil {
+			s.log.Error("Job failed", err,
+				log.Field{Key: "job", Value: job.Name},
+				log.Field{Key: "duration", Value: time.Since(start).String()},
+			)
+			return
+		}
+
+		s.log.Info("Job finished",
+			log.Field{Key: "job", Value: job.Name},
+			log.Field{Key: "duration", Value: time.Since(start).String()},
+		)
+	})
+}
+
+// Start starts running jobs on their schedules
+func (s *Scheduler) Start(context.Context) error {
+	if !s.cfg.Enabled {
+		s.log.Info("Scheduler disabled")
+		return nil
+	}
+
+	s.cron.Start()
+	return nil
+}
+
+// Stop stops scheduling new runs, cancels running jobs and waits for them
+func (s *Scheduler) Stop(ctx context.Context) error {
+	done := s.cron.Stop()
+	s.cancel()
+
+	select {
+	case <-done.Done():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
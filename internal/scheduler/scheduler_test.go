@@ -0,0 +1,33 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/testutil"
+)
+
+func TestWrapRecoversPanics(t *testing.T) {
+	s, err := New(Params{Config: &Config{}, Logger: testutil.NopLogger{}})
+	require.NoError(t, err)
+
+	job := s.wrap(Job{
+		Name: "panics",
+		Run:  func(context.Context) error { panic("boom") },
+	})
+
+	assert.NotPanics(t, job.Run)
+}
+
+func TestRegisterHonorsScheduleOverride(t *testing.T) {
+	s, err := New(Params{
+		Config: &Config{Jobs: map[string]string{"rollup": "not a schedule"}},
+		Logger: testutil.NopLogger{},
+	})
+	require.NoError(t, err)
+
+	err = s.Register(Job{Name: "rollup", Schedule: "@daily", Run: func(context.Context) error { return nil }})
+	assert.Error(t, err)
+}
@@ -0,0 +1,77 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+)
+
+// AWSConfig holds the AWS Secrets Manager settings. Credentials come from
+// the default chain: environment, shared config or the instance role.
+type AWSConfig struct {
+	// Region defaults to AWS_REGION
+	Region string `mapstructure:"region"`
+	// Endpoint overrides the Secrets Manager endpoint, for LocalStack
+	Endpoint string `mapstructure:"endpoint"`
+}
+
+// awsProvider reads secrets from AWS Secrets Manager
+type awsProvider struct {
+	client *secretsmanager.Client
+}
+
+func newAWSProvider(ctx context.Context, cfg AWSConfig) (*awsProvider, error) {
+	var opts []func(*awsconfig.LoadOptions) error
+	if cfg.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(cfg.Region))
+	}
+	acfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	client := secretsmanager.NewFromConfig(acfg, func(o *secretsmanager.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+	})
+	return &awsProvider{client: client}, nil
+}
+
+// Get reads the secret string of a secret ID, or a field of it when the
+// secret is JSON and ref is secret-id#field
+func (p *awsProvider) Get(ctx context.Context, ref string) (string, error) {
+	id, field, _ := strings.Cut(ref, "#")
+
+	out, err := p.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(id)})
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return "", err
+	}
+	if out.SecretString == nil {
+		return "", fmt.Errorf("%w: %s has no secret string", ErrNotFound, id)
+	}
+	if field == "" {
+		return *out.SecretString, nil
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(*out.SecretString), &fields); err != nil {
+		return "", fmt.Errorf("secret %s is not JSON: %w", id, err)
+	}
+	value, ok := fields[field].(string)
+	if !ok {
+		return "", fmt.Errorf("%w: %s has no string field %s", ErrNotFound, id, field)
+	}
+	return value, nil
+}
@@ -0,0 +1,28 @@
+package secrets
+
+import (
+	"context"
+
+	"github.com/things-kit/example-db/internal/config"
+	"github.com/things-kit/example-db/internal/database"
+	"go.uber.org/fx"
+)
+
+// Module provides the secret Store and reads the database password from it
+// for every new connection, so a rotated password is used once the cached
+// one expires. Resolve sets the configured keys on startup.
+var Module = fx.Module("secrets",
+	fx.Provide(NewConfig, NewStore, NewPasswordFunc),
+	config.Validate[*Config]("secrets"),
+)
+
+// NewPasswordFunc returns the database password source, or nil if
+// db.password isn't read from a secret
+func NewPasswordFunc(s *Store) database.PasswordFunc {
+	if !s.Has("db.password") {
+		return nil
+	}
+	return func(ctx context.Context) (string, error) {
+		return s.Get(ctx, "db.password")
+	}
+}
@@ -0,0 +1,247 @@
+// Package secrets resolves configuration values such as the database
+// password from Vault or AWS Secrets Manager, so they don't have to be kept
+// in config files. For local development the values come from environment
+// variables instead.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/things-kit/example-db/internal/config"
+)
+
+// Secret providers
+const (
+	ProviderEnv   = "env"
+	ProviderVault = "vault"
+	ProviderAWS   = "aws"
+)
+
+// ErrNotFound is returned when a secret or one of its fields doesn't exist
+var ErrNotFound = errors.New("secret not found")
+
+// Config holds the secrets configuration
+type Config struct {
+	// Provider is env, vault or aws
+	Provider string `mapstructure:"provider"`
+	// CacheTTL is how long a secret is used before it is fetched again, so
+	// rotated secrets are picked up; 0 keeps the first value
+	CacheTTL time.Duration `mapstructure:"cache_ttl"`
+	// Timeout bounds every lookup
+	Timeout time.Duration `mapstructure:"timeout"`
+	// Secrets maps configuration keys to the secrets they are read from
+	Secrets []Secret `mapstructure:"secrets"`
+	// Vault configures the vault provider
+	Vault VaultConfig `mapstructure:"vault"`
+	// AWS configures the aws provider
+	AWS AWSConfig `mapstructure:"aws"`
+}
+
+// Secret sets a configuration key from a secret
+type Secret struct {
+	// Key is the configuration key, such as db.password
+	Key string `mapstructure:"key"`
+	// Ref locates the secret: an environment variable for env, which
+	// defaults to the key in upper case with dots replaced by underscores
+	// (DB_PASSWORD); path#field for vault; secret-id or secret-id#field of a
+	// JSON secret for aws
+	Ref string `mapstructure:"ref"`
+}
+
+// NewConfig loads the secrets configuration from the "secrets" key
+func NewConfig(v *viper.Viper) *Config {
+	cfg := &Config{
+		Provider: ProviderEnv,
+		CacheTTL: 5 * time.Minute,
+		Timeout:  10 * time.Second,
+		Vault: VaultConfig{
+			Mount: "secret",
+		},
+	}
+
+	if v != nil {
+		_ = v.UnmarshalKey("secrets", cfg)
+	}
+
+	return cfg
+}
+
+// Validate checks the provider and every secret reference
+func (c *Config) Validate() error {
+	errs := []error{
+		config.OneOf("provider", c.Provider, ProviderEnv, ProviderVault, ProviderAWS),
+		config.NonNegative("cache_ttl", c.CacheTTL),
+		config.Positive("timeout", c.Timeout),
+	}
+	for i, s := range c.Secrets {
+		field := fmt.Sprintf("secrets[%d]", i)
+		errs = append(errs, config.Required(field+".key", s.Key))
+		switch c.Provider {
+		case ProviderVault:
+			if _, f, _ := strings.Cut(s.Ref, "#"); f == "" {
+				errs = append(errs, fmt.Errorf("%s.ref must be path#field, got %q", field, s.Ref))
+			}
+		case ProviderAWS:
+			errs = append(errs, config.Required(field+".ref", s.Ref))
+		}
+	}
+	if c.Provider == ProviderVault {
+		errs = append(errs, config.Required("vault.mount", c.Vault.Mount))
+	}
+	return errors.Join(errs...)
+}
+
+// Provider reads secrets from a secret store
+type Provider interface {
+	Get(ctx context.Context, ref string) (string, error)
+}
+
+// NewProvider creates the configured provider
+func NewProvider(ctx context.Context, cfg *Config) (Provider, error) {
+	switch cfg.Provider {
+	case ProviderVault:
+		return newVaultProvider(cfg.Vault)
+	case ProviderAWS:
+		return newAWSProvider(ctx, cfg.AWS)
+	default:
+		return envProvider{lookupEnv: os.LookupEnv}, nil
+	}
+}
+
+// envProvider reads secrets from environment variables
+type envProvider struct {
+	lookupEnv func(string) (string, bool)
+}
+
+func (p envProvider) Get(_ context.Context, ref string) (string, error) {
+	value, ok := p.lookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("%w: environment variable %s is not set", ErrNotFound, ref)
+	}
+	return value, nil
+}
+
+// Store reads the configured secrets through a provider and caches them for
+// the cache TTL
+type Store struct {
+	provider Provider
+	refs     map[string]string
+	ttl      time.Duration
+	timeout  time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]entry
+}
+
+type entry struct {
+	value   string
+	fetched time.Time
+}
+
+// NewStore creates the store of the configured secrets
+func NewStore(cfg *Config) (*Store, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+
+	provider, err := NewProvider(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s secrets provider: %w", cfg.Provider, err)
+	}
+	return newStore(cfg, provider), nil
+}
+
+func newStore(cfg *Config, provider Provider) *Store {
+	refs := make(map[string]string, len(cfg.Secrets))
+	for _, s := range cfg.Secrets {
+		ref := s.Ref
+		if ref == "" {
+			ref = strings.ToUpper(strings.ReplaceAll(s.Key, ".", "_"))
+		}
+		refs[s.Key] = ref
+	}
+
+	return &Store{
+		provider: provider,
+		refs:     refs,
+		ttl:      cfg.CacheTTL,
+		timeout:  cfg.Timeout,
+		now:      time.Now,
+		entries:  map[string]entry{},
+	}
+}
+
+// Has reports whether key is read from a secret
+func (s *Store) Has(key string) bool {
+	_, ok := s.refs[key]
+	return ok
+}
+
+// Get returns the secret of a configuration key. A cached value older than
+// the cache TTL is fetched again; if that fails the old value is returned,
+// so an unavailable secret store doesn't break a running service.
+func (s *Store) Get(ctx context.Context, key string) (string, error) {
+	ref, ok := s.refs[key]
+	if !ok {
+		return "", fmt.Errorf("%w: no secret configured for %s", ErrNotFound, key)
+	}
+
+	now := s.now()
+	s.mu.Lock()
+	cached, ok := s.entries[key]
+	s.mu.Unlock()
+	if ok && (s.ttl == 0 || now.Sub(cached.fetched) < s.ttl) {
+		return cached.value, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	value, err := s.provider.Get(ctx, ref)
+	if err != nil {
+		if ok {
+			return cached.value, nil
+		}
+		return "", fmt.Errorf("failed to read secret for %s: %w", key, err)
+	}
+
+	s.mu.Lock()
+	s.entries[key] = entry{value: value, fetched: now}
+	s.mu.Unlock()
+	return value, nil
+}
+
+// Resolve returns a copy of v with every configured key set to its secret.
+// It runs before the application is built, so a missing secret stops the
+// process on startup.
+func Resolve(v *viper.Viper) (*viper.Viper, error) {
+	cfg := NewConfig(v)
+	if len(cfg.Secrets) == 0 {
+		return v, nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid secrets configuration: %w", err)
+	}
+
+	store, err := NewStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]any, len(cfg.Secrets))
+	for _, secret := range cfg.Secrets {
+		value, err := store.Get(context.Background(), secret.Key)
+		if err != nil {
+			return nil, err
+		}
+		values[secret.Key] = value
+	}
+	return config.Override(v, values)
+}
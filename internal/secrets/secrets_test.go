@@ -0,0 +1,154 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeProvider struct {
+	values map[string]string
+	err    error
+	calls  int
+}
+
+func (p *fakeProvider) Get(_ context.Context, ref string) (string, error) {
+	p.calls++
+	if p.err != nil {
+		return "", p.err
+	}
+	value, ok := p.values[ref]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+func TestStoreCachesAndPicksUpRotatedSecrets(t *testing.T) {
+	cfg := NewConfig(nil)
+	cfg.Secrets = []Secret{{Key: "db.password", Ref: "db#password"}, {Key: "users.cursor_secret"}}
+	provider := &fakeProvider{values: map[string]string{"db#password": "first", "USERS_CURSOR_SECRET": "cursor"}}
+	store := newStore(cfg, provider)
+	clock := time.Now()
+	store.now = func() time.Time { return clock }
+
+	ctx := context.Background()
+	value, err := store.Get(ctx, "db.password")
+	require.NoError(t, err)
+	assert.Equal(t, "first", value)
+
+	provider.values["db#password"] = "second"
+	value, _ = store.Get(ctx, "db.password")
+	assert.Equal(t, "first", value, "cached for the TTL")
+	assert.Equal(t, 1, provider.calls)
+
+	clock = clock.Add(cfg.CacheTTL)
+	value, _ = store.Get(ctx, "db.password")
+	assert.Equal(t, "second", value, "the rotated secret is fetched once the TTL expires")
+
+	clock = clock.Add(cfg.CacheTTL)
+	provider.err = errors.New("vault sealed")
+	value, err = store.Get(ctx, "db.password")
+	require.NoError(t, err)
+	assert.Equal(t, "second", value, "the last value is kept while the provider fails")
+
+	provider.err = nil
+	value, err = store.Get(ctx, "users.cursor_secret")
+	require.NoError(t, err)
+	assert.Equal(t, "cursor", value, "the reference defaults to the key as an environment variable")
+
+	_, err = store.Get(ctx, "mail.password")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestResolve(t *testing.T) {
+	t.Setenv("DB_PASSWORD", "s3cret")
+
+	v := viper.New()
+	v.Set("db", map[string]any{"dsn": "postgres://app@localhost/app"})
+	v.Set("secrets", map[string]any{
+		"provider": "env",
+		"secrets":  []map[string]any{{"key": "db.password"}},
+	})
+
+	resolved, err := Resolve(v)
+	require.NoError(t, err)
+
+	var db struct {
+		DSN      string `mapstructure:"dsn"`
+		Password string `mapstructure:"password"`
+	}
+	require.NoError(t, resolved.UnmarshalKey("db", &db))
+	assert.Equal(t, "postgres://app@localhost/app", db.DSN)
+	assert.Equal(t, "s3cret", db.Password, "sections include the resolved keys")
+
+	v.Set("secrets.secrets", []map[string]any{{"key": "db.password", "ref": "MISSING_PASSWORD"}})
+	_, err = Resolve(v)
+	assert.ErrorIs(t, err, ErrNotFound, "a missing secret stops startup")
+}
+
+func TestVaultProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "token", r.Header.Get("X-Vault-Token"))
+		if r.URL.Path != "/v1/kv/data/example-db" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":{"data":{"password":"from-vault"},"metadata":{"version":3}}}`))
+	}))
+	defer server.Close()
+
+	provider, err := newVaultProvider(VaultConfig{Addr: server.URL, Token: "token", Mount: "kv"})
+	require.NoError(t, err)
+
+	value, err := provider.Get(context.Background(), "example-db#password")
+	require.NoError(t, err)
+	assert.Equal(t, "from-vault", value)
+
+	_, err = provider.Get(context.Background(), "example-db#missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = provider.Get(context.Background(), "other#password")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestAWSProvider(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		_, _ = w.Write([]byte(`{"Name":"example-db","SecretString":"{\"password\":\"from-aws\"}"}`))
+	}))
+	defer server.Close()
+
+	provider, err := newAWSProvider(context.Background(), AWSConfig{Region: "us-east-1", Endpoint: server.URL})
+	require.NoError(t, err)
+
+	value, err := provider.Get(context.Background(), "example-db#password")
+	require.NoError(t, err)
+	assert.Equal(t, "from-aws", value)
+
+	value, err = provider.Get(context.Background(), "example-db")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"password":"from-aws"}`, value, "without a field the whole secret is returned")
+}
+
+func TestConfigValidate(t *testing.T) {
+	cfg := NewConfig(nil)
+	require.NoError(t, cfg.Validate(), "the defaults are valid")
+
+	cfg.Provider = ProviderVault
+	cfg.Secrets = []Secret{{Key: "db.password", Ref: "example-db"}, {Ref: "example-db#key"}}
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `secrets[0].ref must be path#field, got "example-db"`)
+	assert.Contains(t, err.Error(), "secrets[1].key is required")
+}
@@ -0,0 +1,62 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+// VaultConfig holds the Vault connection settings. Empty values fall back to
+// the VAULT_ADDR and VAULT_TOKEN environment variables.
+type VaultConfig struct {
+	Addr  string `mapstructure:"addr"`
+	Token string `mapstructure:"token"`
+	// Mount is the path of the KV version 2 secrets engine
+	Mount string `mapstructure:"mount"`
+}
+
+// vaultProvider reads fields of KV version 2 secrets
+type vaultProvider struct {
+	kv *vault.KVv2
+}
+
+func newVaultProvider(cfg VaultConfig) (*vaultProvider, error) {
+	vcfg := vault.DefaultConfig()
+	if vcfg.Error != nil {
+		return nil, vcfg.Error
+	}
+	if cfg.Addr != "" {
+		vcfg.Address = cfg.Addr
+	}
+
+	client, err := vault.NewClient(vcfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Token != "" {
+		client.SetToken(cfg.Token)
+	}
+	return &vaultProvider{kv: client.KVv2(cfg.Mount)}, nil
+}
+
+// Get reads the field of the secret at path, given as path#field
+func (p *vaultProvider) Get(ctx context.Context, ref string) (string, error) {
+	path, field, _ := strings.Cut(ref, "#")
+
+	secret, err := p.kv.Get(ctx, path)
+	if errors.Is(err, vault.ErrSecretNotFound) {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, path)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := secret.Data[field].(string)
+	if !ok {
+		return "", fmt.Errorf("%w: %s has no string field %s", ErrNotFound, path, field)
+	}
+	return value, nil
+}
@@ -0,0 +1,108 @@
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/things-kit/example-db/internal/user"
+)
+
+// Store is where seeded users are created. Both *user.Service and
+// *user.Repository satisfy it: the service also records events and sends
+// welcome emails, the repository only writes rows.
+type Store interface {
+	Create(ctx context.Context, req user.CreateUserRequest) (*user.User, error)
+	GetByEmail(ctx context.Context, email string) (*user.User, error)
+}
+
+// Copier is a Store that can also insert users in bulk, such as
+// *user.Repository
+type Copier interface {
+	Store
+	CopyFrom(ctx context.Context, reqs []user.CreateUserRequest) (int64, error)
+}
+
+// DemoUsers is the fixed set of users created by Demo
+var DemoUsers = []user.CreateUserRequest{
+	{Name: "Alice Example", Email: "alice@example.com"},
+	{Name: "Bob Example", Email: "bob@example.com"},
+	{Name: "Carol Example", Email: "carol@example.com"},
+	{Name: "Dave Example", Email: "dave@example.com"},
+	{Name: "Eve Example", Email: "eve@example.com"},
+}
+
+// Demo creates the DemoUsers. Users whose email already exists are returned
+// as they are, so Demo can be run repeatedly.
+func Demo(ctx context.Context, store Store) ([]*user.User, error) {
+	users := make([]*user.User, 0, len(DemoUsers))
+	for _, req := range DemoUsers {
+		u, err := store.GetByEmail(ctx, req.Email)
+		if err == nil {
+			users = append(users, u)
+			continue
+		}
+		if !errors.Is(err, user.ErrNotFound) {
+			return users, fmt.Errorf("failed to look up %s: %w", req.Email, err)
+		}
+
+		u, err = store.Create(ctx, req)
+		if err != nil {
+			return users, fmt.Errorf("failed to seed %s: %w", req.Email, err)
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+// FakeUsers generates n users with random names. The same seed always yields
+// the same users. Emails are numbered so they are unique within one call, and
+// use example.com so no real address is ever contacted.
+func FakeUsers(n int, seed uint64) []user.CreateUserRequest {
+	f := gofakeit.New(seed)
+
+	reqs := make([]user.CreateUserRequest, n)
+	for i := range reqs {
+		first, last := f.FirstName(), f.LastName()
+		reqs[i] = user.CreateUserRequest{
+			Name:  first + " " + last,
+			Email: strings.ToLower(fmt.Sprintf("%s.%s.%d@example.com", first, last, i+1)),
+		}
+	}
+	return reqs
+}
+
+// Fake creates n generated users, skipping emails that already exist, and
+// returns the number created. A Copier inserts them all with one COPY.
+func Fake(ctx context.Context, store Store, n int, seed uint64) (int, error) {
+	var missing []user.CreateUserRequest
+	for _, req := range FakeUsers(n, seed) {
+		_, err := store.GetByEmail(ctx, req.Email)
+		if errors.Is(err, user.ErrNotFound) {
+			missing = append(missing, req)
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to look up %s: %w", req.Email, err)
+		}
+	}
+
+	if copier, ok := store.(Copier); ok && len(missing) > 0 {
+		created, err := copier.CopyFrom(ctx, missing)
+		if err != nil {
+			return 0, fmt.Errorf("failed to seed fake users: %w", err)
+		}
+		return int(created), nil
+	}
+
+	created := 0
+	for _, req := range missing {
+		if _, err := store.Create(ctx, req); err != nil {
+			return created, fmt.Errorf("failed to seed %s: %w", req.Email, err)
+		}
+		created++
+	}
+	return created, nil
+}
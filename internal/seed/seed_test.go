@@ -0,0 +1,121 @@
+package seed
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/user"
+)
+
+// memStore is an in-memory Store
+type memStore struct {
+	users map[string]*user.User
+}
+
+func newMemStore() *memStore {
+	return &memStore{users: map[string]*user.User{}}
+}
+
+func (s *memStore) Create(_ context.Context, req user.CreateUserRequest) (*user.User, error) {
+	u := &user.User{ID: int64(len(s.users) + 1), Name: req.Name, Email: req.Email}
+	s.users[req.Email] = u
+	return u, nil
+}
+
+func (s *memStore) GetByEmail(_ context.Context, email string) (*user.User, error) {
+	if u, ok := s.users[email]; ok {
+		return u, nil
+	}
+	return nil, user.ErrNotFound
+}
+
+func TestDemoIsIdempotent(t *testing.T) {
+	store := newMemStore()
+	ctx := context.Background()
+
+	first, err := Demo(ctx, store)
+	require.NoError(t, err)
+	second, err := Demo(ctx, store)
+	require.NoError(t, err)
+
+	assert.Len(t, store.users, len(DemoUsers))
+	assert.Equal(t, first, second)
+}
+
+// failingStore fails every lookup
+type failingStore struct{ memStore }
+
+func (s *failingStore) GetByEmail(context.Context, string) (*user.User, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestSeedStopsOnLookupErrors(t *testing.T) {
+	store := &failingStore{memStore: *newMemStore()}
+	ctx := context.Background()
+
+	_, err := Demo(ctx, store)
+	assert.ErrorContains(t, err, "connection refused")
+	_, err = Fake(ctx, store, 5, 1)
+	assert.ErrorContains(t, err, "connection refused")
+	assert.Empty(t, store.users, "nothing is created when lookups fail")
+}
+
+func TestFakeUsersAreDeterministic(t *testing.T) {
+	a := FakeUsers(50, 42)
+	b := FakeUsers(50, 42)
+	assert.Equal(t, a, b)
+	assert.NotEqual(t, a, FakeUsers(50, 43))
+
+	emails := map[string]bool{}
+	for _, req := range a {
+		assert.NotEmpty(t, req.Name)
+		assert.Regexp(t, `^[a-z.'-]+\.\d+@example\.com$`, req.Email)
+		emails[req.Email] = true
+	}
+	assert.Len(t, emails, len(a))
+}
+
+func TestFakeSkipsExisting(t *testing.T) {
+	store := newMemStore()
+	ctx := context.Background()
+
+	created, err := Fake(ctx, store, 20, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 20, created)
+
+	created, err = Fake(ctx, store, 25, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 5, created)
+}
+
+// memCopier is a memStore that records bulk inserts
+type memCopier struct {
+	*memStore
+	batches int
+}
+
+func (s *memCopier) CopyFrom(ctx context.Context, reqs []user.CreateUserRequest) (int64, error) {
+	s.batches++
+	for _, req := range reqs {
+		_, _ = s.Create(ctx, req)
+	}
+	return int64(len(reqs)), nil
+}
+
+func TestFakeCopiesInOneBatch(t *testing.T) {
+	store := &memCopier{memStore: newMemStore()}
+	ctx := context.Background()
+
+	created, err := Fake(ctx, store, 20, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 20, created)
+	assert.Equal(t, 1, store.batches)
+
+	created, err = Fake(ctx, store, 20, 1)
+	require.NoError(t, err)
+	assert.Zero(t, created)
+	assert.Equal(t, 1, store.batches, "nothing left to copy")
+}
@@ -0,0 +1,105 @@
+// Package server wires the complete service: the HTTP API, its middleware and
+// the background workers. The configuration *viper.Viper is supplied by the
+// caller, which is the server command or a test harness.
+package server
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/things-kit/example-db/internal/audit"
+	"github.com/things-kit/example-db/internal/clock"
+	"github.com/things-kit/example-db/internal/config"
+	"github.com/things-kit/example-db/internal/database"
+	"github.com/things-kit/example-db/internal/debug"
+	"github.com/things-kit/example-db/internal/errreport"
+	"github.com/things-kit/example-db/internal/events"
+	"github.com/things-kit/example-db/internal/feature"
+	"github.com/things-kit/example-db/internal/health"
+	"github.com/things-kit/example-db/internal/httpcache"
+	"github.com/things-kit/example-db/internal/https"
+	"github.com/things-kit/example-db/internal/i18n"
+	"github.com/things-kit/example-db/internal/idgen"
+	"github.com/things-kit/example-db/internal/mail"
+	"github.com/things-kit/example-db/internal/maintenance"
+	"github.com/things-kit/example-db/internal/metrics"
+	"github.com/things-kit/example-db/internal/middleware"
+	"github.com/things-kit/example-db/internal/migrations"
+	"github.com/things-kit/example-db/internal/ops"
+	"github.com/things-kit/example-db/internal/outbox"
+	"github.com/things-kit/example-db/internal/proxy"
+	"github.com/things-kit/example-db/internal/purge"
+	"github.com/things-kit/example-db/internal/quota"
+	"github.com/things-kit/example-db/internal/readmodel"
+	"github.com/things-kit/example-db/internal/reqlog"
+	"github.com/things-kit/example-db/internal/scheduler"
+	"github.com/things-kit/example-db/internal/secrets"
+	"github.com/things-kit/example-db/internal/stats"
+	"github.com/things-kit/example-db/internal/storage"
+	"github.com/things-kit/example-db/internal/tenant"
+	"github.com/things-kit/example-db/internal/tracing"
+	"github.com/things-kit/example-db/internal/user"
+	"github.com/things-kit/example-db/internal/webhook"
+	"github.com/things-kit/module/httpgin"
+	"github.com/things-kit/module/logging"
+	"github.com/things-kit/module/sqlc"
+	"go.uber.org/fx"
+)
+
+// Options wires the complete service on top of the configuration
+func Options() fx.Option {
+	return fx.Options(
+		logging.Module,
+		// Validates the configuration before the other modules are invoked
+		config.Module,
+		config.HTTP,
+		// Applies runtime-tunable settings when the config file changes
+		config.Reload,
+		httpgin.Module,
+		secrets.Module,
+		sqlc.Module,
+		database.Module,
+		clock.Module,
+		idgen.Module,
+
+		// Application modules
+		migrations.Module,
+		middleware.Module,
+		metrics.Module,
+		tracing.Module,
+		i18n.Module,
+		feature.Module,
+		fx.Decorate(database.ReopenSQLDB),
+		ops.Module,
+		debug.Module,
+		https.Module,
+		errreport.Module,
+		maintenance.Module,
+		proxy.Module,
+		tenant.Module,
+		audit.Module,
+		reqlog.Module,
+		httpcache.Module,
+		quota.Module,
+		health.Module,
+		health.AsCheck(health.NewDBCheck),
+		events.Module,
+		outbox.Module,
+		readmodel.Module,
+		purge.Module,
+		storage.Module,
+		mail.Module,
+		scheduler.Module,
+		webhook.Module,
+		fx.Decorate(webhook.WithDelivery),
+		fx.Provide(user.NewConfig, user.NewMetrics, user.NewRepository, user.AsUserRepository, user.NewService, user.NewHandler),
+		config.Validate[*user.Config]("users"),
+		config.AsListener(user.NewReloadListener),
+		fx.Invoke(database.RegisterMetrics),
+		fx.Invoke(func(m *user.Metrics, reg *prometheus.Registry) {
+			debug.Publish("user_repository", m)
+			reg.MustRegister(m.Collectors()...)
+		}),
+		fx.Provide(stats.NewRollup),
+		scheduler.AsJob(stats.NewRollupJob),
+		httpgin.AsGinHandler(func(h *user.Handler) *user.Handler { return h }),
+	)
+}
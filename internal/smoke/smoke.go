@@ -0,0 +1,184 @@
+// Package smoke runs a short scenario through the API of a running service:
+// it creates a user, reads it back, updates it, finds it in the list and
+// deletes it, checking every response. cmd/smoketest runs it against a
+// deployment; the integration tests run it against the service on a test
+// container.
+package smoke
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/things-kit/example-db/client"
+)
+
+// ErrFailed is returned by Run when a step failed
+var ErrFailed = errors.New("smoke test failed")
+
+// Result is the outcome of one step
+type Result struct {
+	Step     string
+	Duration time.Duration
+	// Err is why the step failed, or nil if it passed
+	Err error
+	// Skipped is set for steps not run because an earlier one failed
+	Skipped bool
+}
+
+// step checks one call of the scenario
+type step struct {
+	name string
+	run  func(s *scenario, ctx context.Context) error
+}
+
+// scenario holds what the steps share
+type scenario struct {
+	c *client.Client
+	// email is unique to the run, so runs against the same deployment don't
+	// conflict
+	email string
+	user  *client.User
+}
+
+var steps = []step{
+	{"create", (*scenario).create},
+	{"get", (*scenario).get},
+	{"update", (*scenario).update},
+	{"list", (*scenario).list},
+	{"delete", (*scenario).delete},
+	{"get deleted", (*scenario).getDeleted},
+}
+
+// Run runs the scenario with c, writing a line per step and a summary to
+// out. It returns ErrFailed if a step failed; the steps after it are skipped,
+// and the user is deleted if it was created.
+func Run(ctx context.Context, c *client.Client, out io.Writer) ([]Result, error) {
+	s := &scenario{
+		c:     c,
+		email: "smoke." + strconv.FormatInt(time.Now().UnixNano(), 36) + "@example.com",
+	}
+
+	results := make([]Result, 0, len(steps))
+	failed := false
+	for _, st := range steps {
+		res := Result{Step: st.name, Skipped: failed}
+		if !failed {
+			start := time.Now()
+			res.Err = st.run(s, ctx)
+			res.Duration = time.Since(start)
+			failed = res.Err != nil
+		}
+		results = append(results, res)
+		writeResult(out, res)
+	}
+
+	if failed && s.user != nil {
+		// Leave nothing behind; the user may already be gone
+		if err := c.DeleteUser(context.WithoutCancel(ctx), s.user.ID); err != nil && !client.IsNotFound(err) {
+			fmt.Fprintf(out, "cleanup: failed to delete user %s: %v\n", s.user.ID, err)
+		}
+	}
+
+	passed := 0
+	for _, res := range results {
+		if res.Err == nil && !res.Skipped {
+			passed++
+		}
+	}
+	if failed {
+		fmt.Fprintf(out, "\nFAIL: %d of %d steps passed\n", passed, len(results))
+		return results, ErrFailed
+	}
+	fmt.Fprintf(out, "\nPASS: %d of %d steps passed\n", passed, len(results))
+	return results, nil
+}
+
+func writeResult(w io.Writer, res Result) {
+	switch {
+	case res.Skipped:
+		fmt.Fprintf(w, "SKIP  %s\n", res.Step)
+	case res.Err != nil:
+		fmt.Fprintf(w, "FAIL  %s (%s): %v\n", res.Step, res.Duration.Round(time.Millisecond), res.Err)
+	default:
+		fmt.Fprintf(w, "PASS  %s (%s)\n", res.Step, res.Duration.Round(time.Millisecond))
+	}
+}
+
+func (s *scenario) create(ctx context.Context) error {
+	u, err := s.c.CreateUser(ctx, client.UserRequest{Name: "Smoke Test", Email: s.email})
+	if err != nil {
+		return err
+	}
+	s.user = u
+	if u.ID == "" {
+		return errors.New("created user has no ID")
+	}
+	return expect("email", u.Email, s.email)
+}
+
+func (s *scenario) get(ctx context.Context) error {
+	u, err := s.c.GetUser(ctx, s.user.ID, false)
+	if err != nil {
+		return err
+	}
+	return errors.Join(expect("name", u.Name, "Smoke Test"), expect("email", u.Email, s.email))
+}
+
+func (s *scenario) update(ctx context.Context) error {
+	u, err := s.c.UpdateUser(ctx, s.user.ID, client.UserRequest{Name: "Smoke Test Updated", Email: s.email})
+	if err != nil {
+		return err
+	}
+	if err := expect("name", u.Name, "Smoke Test Updated"); err != nil {
+		return err
+	}
+
+	// Read it back, in case the response and the database disagree
+	u, err = s.c.GetUser(ctx, s.user.ID, false)
+	if err != nil {
+		return err
+	}
+	return expect("name after update", u.Name, "Smoke Test Updated")
+}
+
+// list looks for the user among the newest ones, which it should be unless
+// the service is creating users faster than the scenario runs
+func (s *scenario) list(ctx context.Context) error {
+	page, err := s.c.ListUsers(ctx, client.ListOptions{Limit: 100})
+	if err != nil {
+		return err
+	}
+	for _, u := range page.Users {
+		if u.ID == s.user.ID {
+			return nil
+		}
+	}
+	return fmt.Errorf("user %s not among the %d newest users", s.user.ID, len(page.Users))
+}
+
+func (s *scenario) delete(ctx context.Context) error {
+	return s.c.DeleteUser(ctx, s.user.ID)
+}
+
+func (s *scenario) getDeleted(ctx context.Context) error {
+	_, err := s.c.GetUser(ctx, s.user.ID, false)
+	switch {
+	case err == nil:
+		return errors.New("deleted user is still found")
+	case !client.IsNotFound(err):
+		return err
+	}
+	return nil
+}
+
+// expect returns an error unless got is want
+func expect(field, got, want string) error {
+	if got != want {
+		return fmt.Errorf("%s is %q, want %q", field, got, want)
+	}
+	return nil
+}
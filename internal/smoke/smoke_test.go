@@ -0,0 +1,78 @@
+package smoke
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/client"
+	"github.com/things-kit/example-db/internal/mail"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/user"
+)
+
+// newServer serves the user API on an in-memory repository, passing
+// requests through intercept first when it is set
+func newServer(t *testing.T, intercept gin.HandlerFunc) *httptest.Server {
+	t.Helper()
+
+	queue := mail.NewQueue(nil, &mail.Config{QueueSize: 1}, testutil.NopLogger{})
+	svc := user.NewService(user.NewMemoryRepository(), nil, queue, nil, nil, testutil.NopLogger{})
+	engine := gin.New()
+	if intercept != nil {
+		engine.Use(intercept)
+	}
+	user.NewHandler(svc, &user.Config{IDType: user.IDSerial}, nil, nil, testutil.NopLogger{}).RegisterRoutes(engine)
+
+	srv := httptest.NewServer(engine)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRunPasses(t *testing.T) {
+	srv := newServer(t, nil)
+
+	var out bytes.Buffer
+	results, err := Run(context.Background(), client.New(srv.URL), &out)
+	require.NoError(t, err, out.String())
+
+	require.Len(t, results, len(steps))
+	for _, res := range results {
+		assert.NoError(t, res.Err, res.Step)
+		assert.False(t, res.Skipped, res.Step)
+	}
+	assert.Contains(t, out.String(), "PASS  create")
+	assert.Contains(t, out.String(), "PASS: 6 of 6 steps passed")
+}
+
+func TestRunFailsAndCleansUp(t *testing.T) {
+	var deletes int
+	srv := newServer(t, func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPut:
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "down for maintenance"})
+		case http.MethodDelete:
+			deletes++
+		}
+	})
+
+	var out bytes.Buffer
+	c := client.New(srv.URL, client.WithRetry(client.RetryPolicy{MaxAttempts: 1}))
+	results, err := Run(context.Background(), c, &out)
+	require.ErrorIs(t, err, ErrFailed)
+
+	assert.NoError(t, results[1].Err, "get")
+	assert.ErrorContains(t, results[2].Err, "down for maintenance")
+	assert.True(t, results[3].Skipped, "steps after a failure are skipped")
+	assert.Equal(t, 1, deletes, "the user is deleted after a failure")
+
+	report := out.String()
+	assert.Contains(t, report, "FAIL  update")
+	assert.Contains(t, report, "SKIP  list")
+	assert.Contains(t, report, "FAIL: 2 of 6 steps passed")
+}
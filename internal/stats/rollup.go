@@ -0,0 +1,71 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/things-kit/example-db/internal/scheduler"
+	"github.com/things-kit/example-db/internal/tenant"
+)
+
+// Rollup aggregates daily user statistics into user_stats_daily
+type Rollup struct {
+	db *sql.DB
+}
+
+// NewRollup creates a new rollup
+func NewRollup(db *sql.DB) *Rollup {
+	return &Rollup{db: db}
+}
+
+// NewRollupJob schedules the rollup of the previous day every night
+func NewRollupJob(r *Rollup) scheduler.Job {
+	return scheduler.Job{
+		Name:     "user-stats-rollup",
+		Schedule: "5 0 * * *",
+		Run: func(ctx context.Context) error {
+			return r.Run(ctx, time.Now().UTC().AddDate(0, 0, -1))
+		},
+	}
+}
+
+// Run computes the statistics for the given day. Running it again for the
+// same day overwrites the previous result.
+func (r *Rollup) Run(ctx context.Context, day time.Time) error {
+	query := `
+		INSERT INTO user_stats_daily (day, total_users, created_users, deleted_users)
+		SELECT
+			$1::date,
+			COUNT(*) FILTER (WHERE created_at < $2 AND (deleted_at IS NULL OR deleted_at >= $2)),
+			COUNT(*) FILTER (WHERE created_at >= $1 AND created_at < $2),
+			COUNT(*) FILTER (WHERE deleted_at >= $1 AND deleted_at < $2)
+		FROM users
+		ON CONFLICT (day) DO UPDATE
+		SET total_users = EXCLUDED.total_users,
+			created_users = EXCLUDED.created_users,
+			deleted_users = EXCLUDED.deleted_users
+	`
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Count the users of all tenants under row-level security
+	if _, err := tx.ExecContext(ctx, "SELECT set_config('app.tenant_id', $1, true)", tenant.All); err != nil {
+		return fmt.Errorf("failed to set tenant: %w", err)
+	}
+
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	if _, err := tx.ExecContext(ctx, query, start, start.AddDate(0, 0, 1)); err != nil {
+		return fmt.Errorf("failed to roll up user stats: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
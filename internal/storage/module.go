@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/things-kit/example-db/internal/config"
+	"github.com/things-kit/example-db/internal/health"
+	"github.com/things-kit/module/log"
+	"go.uber.org/fx"
+)
+
+// Module provides the object Store
+var Module = fx.Module("storage",
+	fx.Provide(NewConfig, NewStore),
+	config.Validate[*Config]("storage"),
+	health.AsCheck(NewHealthCheck),
+	fx.Invoke(func(lc fx.Lifecycle, s *Store, cfg *Config, logger log.Logger) {
+		if !cfg.Enabled {
+			logger.Info("Object storage disabled")
+			return
+		}
+		lc.Append(fx.Hook{
+			OnStart: func(ctx context.Context) error {
+				return s.EnsureBucket(ctx)
+			},
+		})
+	}),
+)
+
+// NewHealthCheck checks the bucket when storage is enabled
+func NewHealthCheck(s *Store, cfg *Config) health.Check {
+	check := health.Check{Name: "storage"}
+	if cfg.Enabled {
+		check.Func = s.Ping
+	}
+	return check
+}
+
+// Config holds the object storage configuration
+type Config struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	Endpoint      string        `mapstructure:"endpoint"`
+	Region        string        `mapstructure:"region"`
+	AccessKey     string        `mapstructure:"access_key"`
+	SecretKey     string        `mapstructure:"secret_key"`
+	Bucket        string        `mapstructure:"bucket"`
+	UseSSL        bool          `mapstructure:"use_ssl"`
+	PresignExpiry time.Duration `mapstructure:"presign_expiry"`
+}
+
+// NewConfig loads the storage configuration from the "storage" key.
+// The defaults match a local MinIO started with its default credentials.
+func NewConfig(v *viper.Viper) *Config {
+	cfg := &Config{
+		Enabled:       false,
+		Endpoint:      "localhost:9000",
+		Region:        "us-east-1",
+		AccessKey:     "minioadmin",
+		SecretKey:     "minioadmin",
+		Bucket:        "avatars",
+		UseSSL:        false,
+		PresignExpiry: 15 * time.Minute,
+	}
+
+	if v != nil {
+		_ = v.UnmarshalKey("storage", cfg)
+	}
+
+	return cfg
+}
+
+// Validate checks the connection settings of enabled storage
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	return errors.Join(
+		config.Required("endpoint", c.Endpoint),
+		config.Required("bucket", c.Bucket),
+		config.Positive("presign_expiry", c.PresignExpiry),
+	)
+}
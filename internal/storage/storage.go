@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// ErrDisabled is returned by Store methods when object storage is not configured
+var ErrDisabled = errors.New("object storage is disabled")
+
+// Store stores objects in an S3-compatible bucket
+type Store struct {
+	client *minio.Client
+	cfg    *Config
+}
+
+// NewStore creates a store for the configured bucket. A disabled store is
+// returned when storage is turned off; all of its methods return ErrDisabled.
+func NewStore(cfg *Config) (*Store, error) {
+	if !cfg.Enabled {
+		return &Store{cfg: cfg}, nil
+	}
+
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage client: %w", err)
+	}
+
+	return &Store{client: client, cfg: cfg}, nil
+}
+
+// EnsureBucket creates the bucket if it doesn't exist yet
+func (s *Store) EnsureBucket(ctx context.Context) error {
+	if s.client == nil {
+		return ErrDisabled
+	}
+
+	exists, err := s.client.BucketExists(ctx, s.cfg.Bucket)
+	if err != nil {
+		return fmt.Errorf("failed to check bucket: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	if err := s.client.MakeBucket(ctx, s.cfg.Bucket, minio.MakeBucketOptions{Region: s.cfg.Region}); err != nil {
+		return fmt.Errorf("failed to create bucket: %w", err)
+	}
+
+	return nil
+}
+
+// Ping checks that the bucket is reachable
+func (s *Store) Ping(ctx context.Context) error {
+	if s.client == nil {
+		return ErrDisabled
+	}
+
+	exists, err := s.client.BucketExists(ctx, s.cfg.Bucket)
+	if err != nil {
+		return fmt.Errorf("failed to check bucket: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("bucket %q does not exist", s.cfg.Bucket)
+	}
+
+	return nil
+}
+
+// Put uploads an object
+func (s *Store) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if s.client == nil {
+		return ErrDisabled
+	}
+
+	_, err := s.client.PutObject(ctx, s.cfg.Bucket, key, r, size, minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		return fmt.Errorf("failed to upload object: %w", err)
+	}
+
+	return nil
+}
+
+// Get opens an object for reading
+func (s *Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if s.client == nil {
+		return nil, ErrDisabled
+	}
+
+	obj, err := s.client.GetObject(ctx, s.cfg.Bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+
+	return obj, nil
+}
+
+// Delete removes an object
+func (s *Store) Delete(ctx context.Context, key string) error {
+	if s.client == nil {
+		return ErrDisabled
+	}
+
+	if err := s.client.RemoveObject(ctx, s.cfg.Bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+
+	return nil
+}
+
+// PresignGet returns a time-limited download URL for an object
+func (s *Store) PresignGet(ctx context.Context, key string) (string, time.Time, error) {
+	if s.client == nil {
+		return "", time.Time{}, ErrDisabled
+	}
+
+	expires := time.Now().Add(s.cfg.PresignExpiry)
+	u, err := s.client.PresignedGetObject(ctx, s.cfg.Bucket, key, s.cfg.PresignExpiry, url.Values{})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to presign object: %w", err)
+	}
+
+	return u.String(), expires, nil
+}
@@ -0,0 +1,126 @@
+// Package tenant resolves the tenant of each request. Repositories scope
+// every query to the tenant in the context.
+package tenant
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/things-kit/example-db/internal/i18n"
+	"github.com/things-kit/example-db/internal/middleware"
+	"github.com/things-kit/example-db/internal/proxy"
+	"go.uber.org/fx"
+)
+
+// Default is the tenant of requests that name none and of background jobs
+const Default = "default"
+
+// All matches the rows of every tenant under row-level security. Only trusted
+// background jobs, such as the purge worker, may use it; requests can't name
+// it. Queries scoped in the application match no rows for it.
+const All = "*"
+
+// Module adds the middleware that resolves the tenant of each request. It
+// needs the *proxy.Config of proxy.Module.
+var Module = fx.Module("tenant",
+	fx.Provide(NewConfig),
+	middleware.AsMiddleware(NewMiddleware),
+)
+
+// Config holds the tenant resolution configuration
+type Config struct {
+	// Header names the request header carrying the tenant ID. It is only
+	// honored on requests from a trusted proxy, which must set or overwrite
+	// it for the caller it authenticated.
+	Header string `mapstructure:"header"`
+	// Domain is the base domain of tenant subdomains, so acme.example.com
+	// resolves to acme when Domain is example.com. Empty disables subdomains.
+	Domain string `mapstructure:"domain"`
+	// Required rejects requests without a tenant instead of using Default
+	Required bool `mapstructure:"required"`
+}
+
+// NewConfig loads the tenant configuration from the "tenant" key
+func NewConfig(v *viper.Viper) *Config {
+	cfg := &Config{
+		Header: "X-Tenant-ID",
+	}
+
+	if v != nil {
+		_ = v.UnmarshalKey("tenant", cfg)
+	}
+
+	return cfg
+}
+
+// validID matches tenant IDs, which must be usable as DNS labels
+var validID = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+type tenantKey struct{}
+
+// WithTenant returns a context scoped to the tenant
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// FromContext returns the tenant recorded in ctx, or Default
+func FromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(tenantKey{}).(string); ok && id != "" {
+		return id
+	}
+	return Default
+}
+
+// Resolve returns the tenant named by the request header, if proxies trust
+// the peer that sent it, or, failing that, the subdomain of the request host.
+// It returns "" when the request names no tenant.
+func (c *Config) Resolve(r *http.Request, proxies *proxy.Config) string {
+	if id := r.Header.Get(c.Header); c.Header != "" && id != "" && proxies.Trusted(r) {
+		return strings.ToLower(id)
+	}
+
+	if c.Domain == "" {
+		return ""
+	}
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	sub, ok := strings.CutSuffix(strings.ToLower(host), "."+strings.ToLower(c.Domain))
+	if !ok || strings.Contains(sub, ".") {
+		return ""
+	}
+	return sub
+}
+
+// NewMiddleware records the tenant of each request in its context. Requests
+// naming an invalid tenant are rejected with 400, as are requests naming none
+// when a tenant is required. The tenant header of requests that don't come
+// from a trusted proxy is ignored.
+func NewMiddleware(cfg *Config, proxies *proxy.Config) middleware.Middleware {
+	return middleware.Middleware{
+		Name:  "tenant",
+		Order: 15,
+		Handler: func(c *gin.Context) {
+			id := cfg.Resolve(c.Request, proxies)
+			switch {
+			case id == "" && cfg.Required:
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.Request.Context(), "Missing tenant")})
+				return
+			case id == "":
+				id = Default
+			case !validID.MatchString(id):
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.Request.Context(), "Invalid tenant")})
+				return
+			}
+
+			c.Request = c.Request.WithContext(WithTenant(c.Request.Context(), id))
+			c.Next()
+		},
+	}
+}
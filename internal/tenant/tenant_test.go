@@ -0,0 +1,89 @@
+package tenant
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/things-kit/example-db/internal/proxy"
+)
+
+// trustedProxies trusts the peer address of httptest requests
+func trustedProxies() *proxy.Config {
+	v := viper.New()
+	v.Set("http.trusted_proxies", []string{"192.0.2.1"})
+	return proxy.NewConfig(v)
+}
+
+func TestMiddlewareResolvesTenant(t *testing.T) {
+	cfg := &Config{Header: "X-Tenant-ID", Domain: "example.com"}
+
+	var got string
+	engine := gin.New()
+	engine.Use(NewMiddleware(cfg, trustedProxies()).Handler)
+	engine.GET("/", func(c *gin.Context) {
+		got = FromContext(c.Request.Context())
+	})
+
+	for _, tc := range []struct {
+		host, header string
+		status       int
+		tenant       string
+	}{
+		{host: "localhost:8080", header: "Acme", status: http.StatusOK, tenant: "acme"},
+		{host: "globex.example.com:8080", status: http.StatusOK, tenant: "globex"},
+		{host: "globex.example.com", header: "acme", status: http.StatusOK, tenant: "acme"},
+		{host: "a.b.example.com", status: http.StatusOK, tenant: Default},
+		{host: "localhost", status: http.StatusOK, tenant: Default},
+		{host: "localhost", header: "no_underscores", status: http.StatusBadRequest},
+		{host: "-bad.example.com", status: http.StatusBadRequest},
+	} {
+		got = ""
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Host = tc.host
+		if tc.header != "" {
+			req.Header.Set("X-Tenant-ID", tc.header)
+		}
+
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, tc.status, w.Code, "%s %s", tc.host, tc.header)
+		assert.Equal(t, tc.tenant, got, "%s %s", tc.host, tc.header)
+	}
+}
+
+func TestMiddlewareIgnoresUntrustedHeader(t *testing.T) {
+	cfg := &Config{Header: "X-Tenant-ID", Domain: "example.com"}
+
+	var got string
+	engine := gin.New()
+	engine.Use(NewMiddleware(cfg, proxy.NewConfig(nil)).Handler)
+	engine.GET("/", func(c *gin.Context) {
+		got = FromContext(c.Request.Context())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "globex.example.com"
+	req.Header.Set("X-Tenant-ID", "acme")
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "globex", got, "a client can't name another tenant in the header")
+}
+
+func TestMiddlewareRequiresTenant(t *testing.T) {
+	engine := gin.New()
+	engine.Use(NewMiddleware(&Config{Header: "X-Tenant-ID", Required: true}, nil).Handler)
+	engine.GET("/", func(c *gin.Context) {})
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Equal(t, Default, FromContext(context.Background()))
+	assert.Equal(t, "acme", FromContext(WithTenant(context.Background(), "acme")))
+}
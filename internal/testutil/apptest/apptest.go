@@ -0,0 +1,83 @@
+// Package apptest runs the complete service for a test: every module of the
+// server with its routes, middleware, JSON serialization and error mapping,
+// on a database chosen by the test.
+package apptest
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/config"
+	"github.com/things-kit/example-db/internal/ops"
+	"github.com/things-kit/example-db/internal/server"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+// App is the service started for a test
+type App struct {
+	// URL is the base URL of the API, such as http://127.0.0.1:41234
+	URL string
+	// Engine serves the API routes
+	Engine *gin.Engine
+	// OpsURL is the base URL of the ops endpoints, such as /metrics
+	OpsURL string
+}
+
+// Start boots the service on the database at dsn, which must be migrated,
+// such as one from PostgresContainer.NewDatabase, and serves its API and ops
+// endpoints on httptest servers. All are stopped when the test ends.
+// settings override configuration keys, such as "users.id_type"; opts are
+// added to the application, for example fx.Populate to reach its services.
+func Start(t testing.TB, dsn string, settings map[string]any, opts ...fx.Option) *App {
+	t.Helper()
+
+	values := map[string]any{
+		"db.dsn":        dsn,
+		"http.port":     freePort(t),
+		"http.mode":     gin.TestMode,
+		"ops.addr":      "127.0.0.1:0",
+		"logging.level": "error",
+	}
+	for k, v := range settings {
+		values[k] = v
+	}
+	v, err := config.Override(viper.New(), values)
+	require.NoError(t, err)
+
+	a := &App{}
+	var opsServer *ops.Server
+	app := fxtest.New(t,
+		fx.Supply(v),
+		server.Options(),
+		fx.Populate(&a.Engine, &opsServer),
+		fx.Options(opts...),
+	)
+	app.RequireStart()
+	t.Cleanup(app.RequireStop)
+
+	srv := httptest.NewServer(a.Engine)
+	t.Cleanup(srv.Close)
+	a.URL = srv.URL
+
+	opsSrv := httptest.NewServer(opsServer.Handler())
+	t.Cleanup(opsSrv.Close)
+	a.OpsURL = opsSrv.URL
+
+	return a
+}
+
+// freePort returns a port to give the server's own listener, which the
+// tests don't use; requests go through the httptest server instead
+func freePort(t testing.TB) int {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
@@ -0,0 +1,223 @@
+// Package chaos injects faults into database statements, so tests can check
+// how retries, the circuit breaker and timeouts react to a database that is
+// slow, failing or dropping connections. An Injector wraps the connection a
+// repository runs its statements on:
+//
+//	in := chaos.New(1)
+//	repo = repo.WrapDB(in.Wrap)
+//	in.Next(chaos.Transient, chaos.Drop) // the next two statements fail
+//	in.SetErrorRate(0.2, nil)            // then one in five does
+//
+// Faults are drawn from a seeded source, so a test sees the same sequence on
+// every run as long as it issues statements in the same order.
+package chaos
+
+import (
+	"context"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/things-kit/example-db/internal/user/userdb"
+)
+
+// DBTX is the statement interface of *pgxpool.Pool and pgx.Tx that the
+// user repository runs its queries on
+type DBTX = userdb.DBTX
+
+// Fault is what happens to one statement: it is delayed by Latency, then
+// fails with Err instead of reaching the database if Err is set
+type Fault struct {
+	Latency time.Duration
+	Err     error
+}
+
+// Faults for Next
+var (
+	// Pass lets a statement through untouched
+	Pass = Fault{}
+	// Transient fails a statement with a serialization failure, which is
+	// safe to retry
+	Transient = Fault{Err: ErrTransient}
+	// Drop fails a statement as if the server had reset the connection
+	Drop = Fault{Err: ErrDropped}
+	// Fatal fails a statement with an error that is not worth retrying
+	Fatal = Fault{Err: ErrFatal}
+)
+
+// Delay returns a fault delaying a statement by d
+func Delay(d time.Duration) Fault {
+	return Fault{Latency: d}
+}
+
+// Injected errors. Each matches the classification of a real one:
+// ErrTransient is retried, ErrDropped only for reads and ErrFatal never.
+var (
+	ErrTransient error = &pgconn.PgError{Severity: "ERROR", Code: "40001", Message: "could not serialize access (injected)"}
+	ErrDropped   error = &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+	ErrFatal     error = &pgconn.PgError{Severity: "ERROR", Code: "XX000", Message: "internal error (injected)"}
+)
+
+// Injector decides the fault of every statement run through the connections
+// it wraps. The scripted faults of Next come first; after them each
+// statement is delayed by the configured latency and fails at the configured
+// rates. It is safe for concurrent use.
+type Injector struct {
+	mu        sync.Mutex
+	rand      *rand.Rand
+	script    []Fault
+	latency   time.Duration
+	errorRate float64
+	err       error
+	dropRate  float64
+	calls     int
+	injected  int
+}
+
+// New creates an injector letting every statement through, drawing random
+// faults from seed
+func New(seed uint64) *Injector {
+	return &Injector{rand: rand.New(rand.NewPCG(seed, seed))}
+}
+
+// Next queues faults for the next statements, one each
+func (in *Injector) Next(faults ...Fault) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.script = append(in.script, faults...)
+}
+
+// SetLatency delays every unscripted statement by d
+func (in *Injector) SetLatency(d time.Duration) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.latency = d
+}
+
+// SetErrorRate fails the given fraction of unscripted statements with err,
+// or with ErrTransient if err is nil
+func (in *Injector) SetErrorRate(rate float64, err error) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if err == nil {
+		err = ErrTransient
+	}
+	in.errorRate, in.err = rate, err
+}
+
+// SetDropRate fails the given fraction of unscripted statements with
+// ErrDropped
+func (in *Injector) SetDropRate(rate float64) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.dropRate = rate
+}
+
+// Reset clears the script and the rates, letting every statement through
+func (in *Injector) Reset() {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.script, in.latency, in.errorRate, in.err, in.dropRate = nil, 0, 0, nil, 0
+}
+
+// Calls returns the number of statements seen and how many of them failed
+// with an injected error
+func (in *Injector) Calls() (calls, injected int) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.calls, in.injected
+}
+
+// next returns the fault of the next statement
+func (in *Injector) next() Fault {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	in.calls++
+	var f Fault
+	if len(in.script) > 0 {
+		f, in.script = in.script[0], in.script[1:]
+	} else {
+		// Draw both numbers every time so one rate doesn't shift the
+		// sequence of the other
+		errDraw, dropDraw := in.rand.Float64(), in.rand.Float64()
+		f.Latency = in.latency
+		switch {
+		case errDraw < in.errorRate:
+			f.Err = in.err
+		case dropDraw < in.dropRate:
+			f.Err = ErrDropped
+		}
+	}
+	if f.Err != nil {
+		in.injected++
+	}
+	return f
+}
+
+// inject applies the next fault: it waits out the latency, or returns the
+// context's error if ctx ends first, then returns the fault's error
+func (in *Injector) inject(ctx context.Context) error {
+	f := in.next()
+	if f.Latency > 0 {
+		timer := time.NewTimer(f.Latency)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return f.Err
+}
+
+// Wrap returns db with faults injected into its statements
+func (in *Injector) Wrap(db DBTX) DBTX {
+	return faultyDB{db: db, in: in}
+}
+
+type faultyDB struct {
+	db DBTX
+	in *Injector
+}
+
+func (f faultyDB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	if err := f.in.inject(ctx); err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	return f.db.Exec(ctx, sql, args...)
+}
+
+func (f faultyDB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	if err := f.in.inject(ctx); err != nil {
+		return nil, err
+	}
+	return f.db.Query(ctx, sql, args...)
+}
+
+// QueryRow reports an injected error when the row is scanned, as pgx does
+func (f faultyDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	if err := f.in.inject(ctx); err != nil {
+		return errRow{err: err}
+	}
+	return f.db.QueryRow(ctx, sql, args...)
+}
+
+func (f faultyDB) CopyFrom(ctx context.Context, table pgx.Identifier, columns []string, rows pgx.CopyFromSource) (int64, error) {
+	if err := f.in.inject(ctx); err != nil {
+		return 0, err
+	}
+	return f.db.CopyFrom(ctx, table, columns, rows)
+}
+
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(...any) error {
+	return r.err
+}
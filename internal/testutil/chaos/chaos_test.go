@@ -0,0 +1,102 @@
+package chaos
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// okDB succeeds every statement, counting the ones that reach it
+type okDB struct {
+	calls int
+}
+
+func (d *okDB) Exec(context.Context, string, ...any) (pgconn.CommandTag, error) {
+	d.calls++
+	return pgconn.NewCommandTag("UPDATE 1"), nil
+}
+
+func (d *okDB) Query(context.Context, string, ...any) (pgx.Rows, error) {
+	d.calls++
+	return nil, nil
+}
+
+func (d *okDB) QueryRow(context.Context, string, ...any) pgx.Row {
+	d.calls++
+	return errRow{}
+}
+
+func (d *okDB) CopyFrom(context.Context, pgx.Identifier, []string, pgx.CopyFromSource) (int64, error) {
+	d.calls++
+	return 0, nil
+}
+
+func TestScriptedFaults(t *testing.T) {
+	ctx := context.Background()
+	d := &okDB{}
+	in := New(1)
+	db := in.Wrap(d)
+
+	in.Next(Transient, Pass, Drop, Fatal)
+	_, err := db.Exec(ctx, "UPDATE")
+	assert.ErrorIs(t, err, ErrTransient)
+	_, err = db.Exec(ctx, "UPDATE")
+	assert.NoError(t, err)
+	assert.ErrorIs(t, db.QueryRow(ctx, "SELECT").Scan(), ErrDropped)
+	_, err = db.CopyFrom(ctx, pgx.Identifier{"users"}, nil, nil)
+	assert.ErrorIs(t, err, ErrFatal)
+	_, err = db.Exec(ctx, "UPDATE")
+	assert.NoError(t, err, "statements pass once the script is used up")
+
+	assert.Equal(t, 2, d.calls, "failed statements don't reach the database")
+	calls, injected := in.Calls()
+	assert.Equal(t, 5, calls)
+	assert.Equal(t, 3, injected)
+}
+
+func TestErrorRateIsDeterministic(t *testing.T) {
+	run := func() []bool {
+		in := New(42)
+		in.SetErrorRate(0.3, nil)
+		in.SetDropRate(0.2)
+		db := in.Wrap(&okDB{})
+
+		failed := make([]bool, 100)
+		for i := range failed {
+			_, err := db.Exec(context.Background(), "UPDATE")
+			failed[i] = err != nil
+		}
+		return failed
+	}
+
+	first := run()
+	assert.Equal(t, first, run(), "the same seed injects the same faults")
+	assert.Contains(t, first, true)
+	assert.Contains(t, first, false)
+}
+
+func TestLatencyHonoursContext(t *testing.T) {
+	in := New(1)
+	in.Next(Delay(time.Hour))
+	db := in.Wrap(&okDB{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := db.Exec(ctx, "UPDATE")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestReset(t *testing.T) {
+	in := New(1)
+	in.Next(Fatal)
+	in.SetErrorRate(1, nil)
+	in.Reset()
+
+	_, err := in.Wrap(&okDB{}).Exec(context.Background(), "UPDATE")
+	assert.NoError(t, err)
+}
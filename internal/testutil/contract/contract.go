@@ -0,0 +1,218 @@
+// Package contract checks HTTP traffic against the OpenAPI document of the
+// api package, so that handlers can't drift from the documented contract
+// unnoticed. Tests send their requests through a Validator's client:
+//
+//	v := contract.New(t)
+//	c := client.New(srv.URL, client.WithHTTPClient(v.Client()))
+//
+// Every response must match the document. A request the document rejects
+// must be rejected by the service too, with a 4xx.
+package contract
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/api"
+)
+
+func init() {
+	openapi3filter.RegisterBodyDecoder("application/merge-patch+json", openapi3filter.JSONBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("application/x-ndjson", decodeNDJSON)
+}
+
+// decodeNDJSON decodes newline-delimited JSON as an array of its values, the
+// schema the document gives streamed responses
+func decodeNDJSON(body io.Reader, _ http.Header, _ *openapi3.SchemaRef, _ openapi3filter.EncodingFn) (any, error) {
+	dec := json.NewDecoder(body)
+	dec.UseNumber()
+
+	values := []any{}
+	for {
+		var v any
+		err := dec.Decode(&v)
+		if errors.Is(err, io.EOF) {
+			return values, nil
+		}
+		if err != nil {
+			return nil, &openapi3filter.ParseError{Kind: openapi3filter.KindInvalidFormat, Cause: err}
+		}
+		values = append(values, v)
+	}
+}
+
+// Load parses and validates the OpenAPI document
+func Load(ctx context.Context) (*openapi3.T, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(api.Spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the OpenAPI document: %w", err)
+	}
+	if err := doc.Validate(ctx); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	return doc, nil
+}
+
+// Validator checks requests and their responses against the OpenAPI
+// document, failing its test on every violation. It is safe for concurrent
+// use.
+type Validator struct {
+	t      testing.TB
+	doc    *openapi3.T
+	router routers.Router
+
+	mu sync.Mutex
+	// covered holds the operations that answered with a 2xx
+	covered map[string]bool
+}
+
+// New loads the document for t
+func New(t testing.TB) *Validator {
+	t.Helper()
+
+	doc, err := Load(context.Background())
+	require.NoError(t, err)
+	// Match requests to any host, such as an httptest server
+	doc.Servers = nil
+
+	router, err := gorillamux.NewRouter(doc)
+	require.NoError(t, err)
+
+	return &Validator{t: t, doc: doc, router: router, covered: map[string]bool{}}
+}
+
+// Client returns an HTTP client whose traffic is checked
+func (v *Validator) Client() *http.Client {
+	return &http.Client{Transport: v.Transport(http.DefaultTransport)}
+}
+
+// Transport wraps next so that its traffic is checked
+func (v *Validator) Transport(next http.RoundTripper) http.RoundTripper {
+	return roundTripper(func(req *http.Request) (*http.Response, error) {
+		var reqBody []byte
+		if req.Body != nil {
+			var err error
+			if reqBody, err = io.ReadAll(req.Body); err != nil {
+				return nil, err
+			}
+			req.Body.Close()
+			req.Body = io.NopCloser(bytes.NewReader(reqBody))
+		}
+
+		resp, err := next.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+
+		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+		if err := v.Check(req, reqBody, resp.StatusCode, resp.Header, respBody); err != nil {
+			v.t.Errorf("contract: %s %s: %v", req.Method, req.URL.RequestURI(), err)
+		}
+		return resp, nil
+	})
+}
+
+// Check validates a request and the response it got
+func (v *Validator) Check(req *http.Request, reqBody []byte, status int, header http.Header, respBody []byte) error {
+	route, params, err := v.router.FindRoute(req)
+	if err != nil {
+		if status == http.StatusNotFound || status == http.StatusMethodNotAllowed {
+			return nil
+		}
+		return fmt.Errorf("undocumented operation answered with %d", status)
+	}
+
+	ctx := req.Context()
+	req = req.Clone(ctx)
+	req.Body = io.NopCloser(bytes.NewReader(reqBody))
+
+	reqOpts := &openapi3filter.Options{
+		MultiError:          true,
+		SkipSettingDefaults: true,
+		AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+	}
+	reqOpts.WithCustomSchemaErrorFunc(schemaError)
+	input := &openapi3filter.RequestValidationInput{
+		Request:    req,
+		PathParams: params,
+		Route:      route,
+		Options:    reqOpts,
+	}
+	reqErr := openapi3filter.ValidateRequest(ctx, input)
+	if reqErr != nil && (status < 400 || status >= 500) {
+		return fmt.Errorf("request violates the document but was answered with %d: %w", status, reqErr)
+	}
+
+	respOpts := &openapi3filter.Options{
+		MultiError:            true,
+		IncludeResponseStatus: true,
+	}
+	respOpts.WithCustomSchemaErrorFunc(schemaError)
+	err = openapi3filter.ValidateResponse(ctx, &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 status,
+		Header:                 header,
+		Body:                   io.NopCloser(bytes.NewReader(respBody)),
+		Options:                respOpts,
+	})
+	if err != nil {
+		return fmt.Errorf("response %d violates the document: %w", status, err)
+	}
+
+	if status >= 200 && status < 300 {
+		v.mu.Lock()
+		v.covered[route.Operation.OperationID] = true
+		v.mu.Unlock()
+	}
+	return nil
+}
+
+// schemaError describes a schema violation by its location alone, without
+// the schema and value dumps of the default message
+func schemaError(err *openapi3.SchemaError) string {
+	return fmt.Sprintf("%s at /%s", err.Reason, strings.Join(err.JSONPointer(), "/"))
+}
+
+// Uncovered returns the operations of the document that never answered a
+// request with a 2xx, sorted
+func (v *Validator) Uncovered() []string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	var ops []string
+	for _, item := range v.doc.Paths.Map() {
+		for _, op := range item.Operations() {
+			if !v.covered[op.OperationID] {
+				ops = append(ops, op.OperationID)
+			}
+		}
+	}
+	slices.Sort(ops)
+	return ops
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
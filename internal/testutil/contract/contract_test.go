@@ -0,0 +1,142 @@
+package contract
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/quota"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/user"
+	"github.com/things-kit/example-db/internal/webhook"
+)
+
+// TestRoutesDocumented checks that the document and the registered routes
+// list the same operations
+func TestRoutesDocumented(t *testing.T) {
+	doc, err := Load(t.Context())
+	require.NoError(t, err)
+
+	engine := gin.New()
+	svc := user.NewService(user.NewMemoryRepository(), nil, nil, nil, nil, testutil.NopLogger{})
+	user.NewHandler(svc, &user.Config{}, nil, nil, testutil.NopLogger{}).RegisterRoutes(engine)
+	webhook.NewHandler(nil, nil, nil, nil, testutil.NopLogger{}).RegisterRoutes(engine)
+	quota.NewHandler(nil, nil, testutil.NopLogger{}).RegisterRoutes(engine)
+
+	param := regexp.MustCompile(`:(\w+)`)
+	routes := map[string]bool{}
+	for _, r := range engine.Routes() {
+		path := param.ReplaceAllString(r.Path, "{$1}")
+		routes[r.Method+" "+path] = true
+		item := doc.Paths.Value(path)
+		assert.True(t, item != nil && item.GetOperation(r.Method) != nil, "%s %s is not documented", r.Method, path)
+	}
+
+	for path, item := range doc.Paths.Map() {
+		for method := range item.Operations() {
+			assert.True(t, routes[method+" "+path], "%s %s is documented but not routed", method, path)
+		}
+	}
+}
+
+const validUser = `{"id":1,"name":"Ann","email":"ann@example.com","created_at":"2024-01-02T03:04:05Z",` +
+	`"updated_at":"2024-01-02T03:04:05Z","status":"active","preferences":{}}`
+
+func TestCheck(t *testing.T) {
+	v := New(t)
+	header := http.Header{"Content-Type": {"application/json"}}
+
+	check := func(method, target, reqBody string, status int, respBody string) error {
+		req := httptest.NewRequest(method, target, strings.NewReader(reqBody))
+		if reqBody != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		return v.Check(req, []byte(reqBody), status, header, []byte(respBody))
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		assert.NoError(t, check(http.MethodGet, "/users/1", "", http.StatusOK, validUser))
+		assert.NoError(t, check(http.MethodGet, "/users?limit=10", "", http.StatusOK, "["+validUser+"]"))
+		assert.NoError(t, check(http.MethodPost, "/users", `{"name":"Ann","email":"ann@example.com"}`, http.StatusCreated, validUser))
+		assert.NoError(t, check(http.MethodDelete, "/users/1", "", http.StatusNoContent, ""))
+	})
+
+	t.Run("UndocumentedField", func(t *testing.T) {
+		body := strings.Replace(validUser, `"status"`, `"is_admin":false,"status"`, 1)
+		assert.ErrorContains(t, check(http.MethodGet, "/users/1", "", http.StatusOK, body), "is_admin")
+	})
+
+	t.Run("MissingField", func(t *testing.T) {
+		body := strings.Replace(validUser, `"status":"active",`, "", 1)
+		assert.ErrorContains(t, check(http.MethodGet, "/users/1", "", http.StatusOK, body), "status")
+	})
+
+	t.Run("UndocumentedStatus", func(t *testing.T) {
+		assert.Error(t, check(http.MethodGet, "/users/1", "", http.StatusOK, "null"))
+		assert.Error(t, check(http.MethodGet, "/users/1", "", http.StatusNotFound, `{"message":"gone"}`))
+	})
+
+	t.Run("InvalidRequestRejected", func(t *testing.T) {
+		assert.NoError(t, check(http.MethodGet, "/users?limit=5000", "", http.StatusBadRequest, `{"error":"invalid limit"}`))
+		assert.NoError(t, check(http.MethodPost, "/users", `{"name":"Ann"}`, http.StatusBadRequest, `{"error":"invalid email"}`))
+	})
+
+	t.Run("InvalidRequestAccepted", func(t *testing.T) {
+		assert.ErrorContains(t, check(http.MethodGet, "/users?limit=5000", "", http.StatusOK, "[]"), "answered with 200")
+	})
+
+	t.Run("UndocumentedOperation", func(t *testing.T) {
+		assert.NoError(t, check(http.MethodGet, "/groups", "", http.StatusNotFound, "404 page not found"))
+		assert.Error(t, check(http.MethodGet, "/groups", "", http.StatusOK, "[]"))
+	})
+
+	t.Run("Stream", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/users/stream", nil)
+		ndjson := http.Header{"Content-Type": {"application/x-ndjson"}}
+		assert.NoError(t, v.Check(req, nil, http.StatusOK, ndjson, []byte(validUser+"\n"+validUser+"\n")))
+		assert.NoError(t, v.Check(req, nil, http.StatusOK, ndjson, nil))
+		assert.Error(t, v.Check(req, nil, http.StatusOK, ndjson, []byte(`{"id":1}`+"\n")))
+	})
+}
+
+// TestClient runs the user API on the in-memory repository through a
+// validated client
+func TestClient(t *testing.T) {
+	v := New(t)
+
+	repo := user.NewMemoryRepository()
+	svc := user.NewService(repo, nil, nil, nil, nil, testutil.NopLogger{})
+	_, err := repo.Create(t.Context(), user.CreateUserRequest{Name: "Ann", Email: "ann@example.com"})
+	require.NoError(t, err)
+
+	engine := gin.New()
+	user.NewHandler(svc, &user.Config{IDType: user.IDSerial}, nil, nil, testutil.NopLogger{}).RegisterRoutes(engine)
+	srv := httptest.NewServer(engine)
+	defer srv.Close()
+
+	c := v.Client()
+	for _, target := range []string{
+		"/users/1",
+		"/users/1?expand=profile",
+		"/users?limit=1&count=true",
+		"/users/stream",
+		"/users/1/audit",
+		"/users/changes",
+		"/users/changes?since=yesterday",
+		"/users/999",
+		"/users/abc",
+		"/users?limit=-1",
+	} {
+		resp, err := c.Get(srv.URL + target)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	assert.NotContains(t, v.Uncovered(), "getUser")
+	assert.Contains(t, v.Uncovered(), "createUser")
+}
@@ -0,0 +1,161 @@
+// Package factory builds test users with deterministic fake data, so tests
+// only spell out the fields they care about:
+//
+//	u := factory.User().WithEmail("ann@example.com").Create(t, repo)
+package factory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/prefs"
+	"github.com/things-kit/example-db/internal/user"
+)
+
+// Creator is where built users are created, such as *user.Repository or
+// *user.Service
+type Creator interface {
+	Create(ctx context.Context, req user.CreateUserRequest) (*user.User, error)
+}
+
+// Copier bulk-inserts users, such as *user.Repository
+type Copier interface {
+	CopyFrom(ctx context.Context, reqs []user.CreateUserRequest) (int64, error)
+}
+
+// seedBatch is the number of users Seed inserts with one COPY
+const seedBatch = 5000
+
+// seq numbers the users built by the process. Each user's fake data is drawn
+// from a faker seeded with its number, so the same sequence of calls always
+// yields the same users.
+var seq atomic.Uint64
+
+// UserBuilder builds one user. Fields not set are filled with fake data.
+type UserBuilder struct {
+	req    user.CreateUserRequest
+	status string
+	admin  bool
+	prefs  prefs.Preferences
+}
+
+// User starts building a user with a fake name and a unique example.com email
+func User() *UserBuilder {
+	n := seq.Add(1)
+	f := gofakeit.New(n)
+	first, last := f.FirstName(), f.LastName()
+	return &UserBuilder{req: user.CreateUserRequest{
+		Name:  first + " " + last,
+		Email: strings.ToLower(fmt.Sprintf("%s.%s.%d@example.com", first, last, n)),
+	}}
+}
+
+// WithName sets the user's name
+func (b *UserBuilder) WithName(name string) *UserBuilder {
+	b.req.Name = name
+	return b
+}
+
+// WithEmail sets the user's email
+func (b *UserBuilder) WithEmail(email string) *UserBuilder {
+	b.req.Email = email
+	return b
+}
+
+// WithStatus sets the user's account status after it is created
+func (b *UserBuilder) WithStatus(status string) *UserBuilder {
+	b.status = status
+	return b
+}
+
+// Admin makes the user an administrator after it is created
+func (b *UserBuilder) Admin() *UserBuilder {
+	b.admin = true
+	return b
+}
+
+// WithPreferences sets the user's preferences after it is created
+func (b *UserBuilder) WithPreferences(p prefs.Preferences) *UserBuilder {
+	b.prefs = p
+	return b
+}
+
+// Request returns the request creating the user, for tests that send it
+// themselves, such as through the API
+func (b *UserBuilder) Request() user.CreateUserRequest {
+	return b.req
+}
+
+// Create creates the user in repo, failing the test on error. A status,
+// admin flag or preferences need repo to be a *user.Repository or another
+// store with the matching setters.
+func (b *UserBuilder) Create(t testing.TB, repo Creator) *user.User {
+	t.Helper()
+
+	ctx := context.Background()
+	u, err := repo.Create(ctx, b.req)
+	require.NoError(t, err, "failed to create user %s", b.req.Email)
+
+	if b.status != "" {
+		setter, ok := repo.(interface {
+			SetStatus(ctx context.Context, id int64, status string) (*user.User, error)
+		})
+		require.True(t, ok, "%T can't set a user's status", repo)
+		u, err = setter.SetStatus(ctx, u.ID, b.status)
+		require.NoError(t, err)
+	}
+
+	if b.prefs != nil {
+		setter, ok := repo.(interface {
+			SetPreferences(ctx context.Context, id int64, p prefs.Preferences) (*user.User, error)
+		})
+		require.True(t, ok, "%T can't set a user's preferences", repo)
+		u, err = setter.SetPreferences(ctx, u.ID, b.prefs)
+		require.NoError(t, err)
+	}
+
+	if b.admin {
+		setter, ok := repo.(interface {
+			SetAdmin(ctx context.Context, id int64, admin bool) error
+		})
+		require.True(t, ok, "%T can't make a user an admin", repo)
+		require.NoError(t, setter.SetAdmin(ctx, u.ID, true))
+		u.IsAdmin = true
+	}
+
+	return u
+}
+
+// Users creates n users with fake data in repo
+func Users(t testing.TB, repo Creator, n int) []*user.User {
+	t.Helper()
+
+	users := make([]*user.User, n)
+	for i := range users {
+		users[i] = User().Create(t, repo)
+	}
+	return users
+}
+
+// Seed inserts n users with fake data in repo, seedBatch at a time with
+// COPY, for benchmarks and tests that need a large table. COPY returns no
+// rows, so neither does Seed; on a fresh database the users have IDs 1 to n.
+func Seed(t testing.TB, repo Copier, n int) {
+	t.Helper()
+
+	ctx := context.Background()
+	for n > 0 {
+		reqs := make([]user.CreateUserRequest, min(n, seedBatch))
+		for i := range reqs {
+			reqs[i] = User().Request()
+		}
+		_, err := repo.CopyFrom(ctx, reqs)
+		require.NoError(t, err, "failed to seed users")
+		n -= len(reqs)
+	}
+}
@@ -0,0 +1,66 @@
+package factory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/user"
+)
+
+// memStore records created users and supports status changes
+type memStore struct {
+	users map[int64]*user.User
+}
+
+func (s *memStore) Create(_ context.Context, req user.CreateUserRequest) (*user.User, error) {
+	if s.users == nil {
+		s.users = map[int64]*user.User{}
+	}
+	u := &user.User{ID: int64(len(s.users) + 1), Name: req.Name, Email: req.Email, Status: user.StatusActive}
+	s.users[u.ID] = u
+	return u, nil
+}
+
+func (s *memStore) SetStatus(_ context.Context, id int64, status string) (*user.User, error) {
+	s.users[id].Status = status
+	return s.users[id], nil
+}
+
+func TestUserFillsFakeData(t *testing.T) {
+	a, b := User().Request(), User().Request()
+	assert.NotEmpty(t, a.Name)
+	assert.Contains(t, a.Email, "@example.com")
+	assert.NotEqual(t, a.Email, b.Email)
+}
+
+func TestUserIsDeterministic(t *testing.T) {
+	n := seq.Load()
+	first := User().Request()
+
+	seq.Store(n)
+	assert.Equal(t, first, User().Request())
+}
+
+func TestCreate(t *testing.T) {
+	store := &memStore{}
+	u := User().WithName("Ann").WithEmail("ann@example.com").WithStatus(user.StatusSuspended).Create(t, store)
+
+	assert.Equal(t, "Ann", u.Name)
+	assert.Equal(t, "ann@example.com", u.Email)
+	assert.Equal(t, user.StatusSuspended, u.Status)
+
+	users := Users(t, store, 3)
+	require.Len(t, users, 3)
+	assert.Len(t, store.users, 4)
+}
+
+func TestSeed(t *testing.T) {
+	repo := user.NewMemoryRepository()
+	Seed(t, repo, seedBatch+10)
+
+	n, err := repo.Count(context.Background(), user.ListFilter{})
+	require.NoError(t, err)
+	assert.EqualValues(t, seedBatch+10, n)
+}
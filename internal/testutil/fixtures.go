@@ -0,0 +1,204 @@
+package testutil
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadFixtures loads datasets into the database at dsn, such as one from
+// NewDatabase, in a single transaction: either every fixture is loaded or
+// the test fails with the database untouched. Each path is a fixture file or
+// a directory whose .sql, .yaml and .yml files are loaded in name order.
+//
+// A .sql file is run as is. A .yaml file maps table names to lists of rows,
+// loaded in the order the tables appear:
+//
+//	users:
+//	  - id: 1
+//	    name: Ann
+//	    email: ann@example.com
+//	    uuid: 0190c1d4-54a5-7b6e-8c1d-4f6b3e2a9d01
+//	    created_at: 2024-01-01T12:00:00Z
+//	    preferences: {theme: dark}
+//
+// Columns left out take their default. Maps and lists are stored as JSON.
+// Sequences of the columns loaded, such as users.id, are moved past the
+// highest value, so rows created by the test don't collide with fixtures.
+func LoadFixtures(t testing.TB, dsn string, paths ...string) {
+	t.Helper()
+
+	var files []string
+	for _, path := range paths {
+		found, err := fixtureFiles(path)
+		require.NoError(t, err)
+		files = append(files, found...)
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	for _, file := range files {
+		require.NoError(t, loadFixture(ctx, tx, file), "fixture %s", file)
+	}
+	require.NoError(t, tx.Commit())
+}
+
+// fixtureFiles returns path if it is a file, or the fixture files in it in
+// name order if it is a directory
+func fixtureFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		switch filepath.Ext(e.Name()) {
+		case ".sql", ".yaml", ".yml":
+			if !e.IsDir() {
+				files = append(files, filepath.Join(path, e.Name()))
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no fixtures in %s", path)
+	}
+	return files, nil
+}
+
+func loadFixture(ctx context.Context, tx *sql.Tx, file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	switch filepath.Ext(file) {
+	case ".sql":
+		_, err = tx.ExecContext(ctx, string(data))
+		return err
+	case ".yaml", ".yml":
+		return loadYAMLFixture(ctx, tx, data)
+	default:
+		return fmt.Errorf("unknown fixture type %q, want .sql, .yaml or .yml", filepath.Ext(file))
+	}
+}
+
+// loadYAMLFixture inserts the rows of a YAML dataset, table by table in the
+// order of the document
+func loadYAMLFixture(ctx context.Context, tx *sql.Tx, data []byte) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	tables := doc.Content[0]
+	if tables.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: want a map of table names to rows", tables.Line)
+	}
+
+	for i := 0; i < len(tables.Content); i += 2 {
+		table := tables.Content[i].Value
+		var rows []map[string]any
+		if err := tables.Content[i+1].Decode(&rows); err != nil {
+			return fmt.Errorf("table %s: %w", table, err)
+		}
+
+		columns := map[string]bool{}
+		for n, row := range rows {
+			if err := insertFixtureRow(ctx, tx, table, row); err != nil {
+				return fmt.Errorf("table %s, row %d: %w", table, n+1, err)
+			}
+			for column := range row {
+				columns[column] = true
+			}
+		}
+		for column := range columns {
+			if err := advanceSequence(ctx, tx, table, column); err != nil {
+				return fmt.Errorf("table %s: %w", table, err)
+			}
+		}
+	}
+	return nil
+}
+
+func insertFixtureRow(ctx context.Context, tx *sql.Tx, table string, row map[string]any) error {
+	columns := make([]string, 0, len(row))
+	for column := range row {
+		columns = append(columns, column)
+	}
+	slices.Sort(columns)
+
+	quoted := make([]string, len(columns))
+	params := make([]string, len(columns))
+	args := make([]any, len(columns))
+	for i, column := range columns {
+		quoted[i] = pq.QuoteIdentifier(column)
+		params[i] = fmt.Sprintf("$%d", i+1)
+
+		switch v := row[column].(type) {
+		case map[string]any, []any:
+			data, err := json.Marshal(v)
+			if err != nil {
+				return fmt.Errorf("column %s: %w", column, err)
+			}
+			args[i] = string(data)
+		default:
+			args[i] = v
+		}
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		quoteTable(table), strings.Join(quoted, ", "), strings.Join(params, ", "))
+	_, err := tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+// advanceSequence moves the sequence behind table.column, if there is one,
+// past the column's highest value
+func advanceSequence(ctx context.Context, tx *sql.Tx, table, column string) error {
+	var seq sql.NullString
+	err := tx.QueryRowContext(ctx, "SELECT pg_get_serial_sequence($1, $2)", quoteTable(table), column).Scan(&seq)
+	if err != nil || !seq.Valid {
+		return err
+	}
+
+	query := fmt.Sprintf("SELECT setval($1, max(%s)) FROM %s HAVING max(%s) IS NOT NULL",
+		pq.QuoteIdentifier(column), quoteTable(table), pq.QuoteIdentifier(column))
+	_, err = tx.ExecContext(ctx, query, seq.String)
+	return err
+}
+
+// quoteTable quotes a table name, optionally qualified with its schema
+func quoteTable(table string) string {
+	parts := strings.Split(table, ".")
+	for i, part := range parts {
+		parts[i] = pq.QuoteIdentifier(part)
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,47 @@
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// update rewrites the golden files instead of comparing against them:
+//
+//	go test ./internal/user -run Golden -update
+var update = flag.Bool("update", false, "rewrite golden files with the actual output")
+
+// Golden compares got with the golden file testdata/<name>.golden of the
+// package under test. With -update the file is written instead, so a change
+// in output shows up as a diff of the golden file in review.
+func Golden(t testing.TB, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, got, 0o644))
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	require.NoError(t, err, "missing golden file, run the test with -update to create it")
+	assert.Equal(t, string(want), string(got), "output differs from %s, run the test with -update to accept it", path)
+}
+
+// GoldenJSON compares a JSON document with a golden file like Golden. The
+// document is indented first, so golden files diff line by line.
+func GoldenJSON(t testing.TB, name string, got []byte) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	require.NoError(t, json.Indent(&buf, got, "", "  "), "invalid JSON: %s", got)
+	buf.WriteByte('\n')
+	Golden(t, name, buf.Bytes())
+}
@@ -0,0 +1,15 @@
+package testutil
+
+import "github.com/things-kit/module/log"
+
+// NopLogger is a log.Logger that discards Info and Error messages.
+// Other methods are inherited from the nil embedded Logger and panic if called.
+type NopLogger struct {
+	log.Logger
+}
+
+// Info implements log.Logger
+func (NopLogger) Info(string, ...log.Field) {}
+
+// Error implements log.Logger
+func (NopLogger) Error(string, error, ...log.Field) {}
@@ -0,0 +1,106 @@
+package testutil
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Metrics is a scrape of a Prometheus endpoint, by metric name
+type Metrics map[string]*dto.MetricFamily
+
+// Labels selects series by label values; labels left out match any value
+type Labels map[string]string
+
+// ScrapeMetrics fetches and parses the metrics served at baseURL/metrics,
+// such as apptest.App.OpsURL. Take one scrape before and one after the
+// requests under test and compare them with AssertIncreased:
+//
+//	before := testutil.ScrapeMetrics(t, app.OpsURL)
+//	// ... create a user through app.URL
+//	after := testutil.ScrapeMetrics(t, app.OpsURL)
+//	after.AssertIncreased(t, before, "http_requests_total", testutil.Labels{"route": "/users", "status": "201"})
+func ScrapeMetrics(t testing.TB, baseURL string) Metrics {
+	t.Helper()
+
+	resp, err := http.Get(strings.TrimRight(baseURL, "/") + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode, "scraping metrics")
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	require.NoError(t, err, "parsing metrics")
+	return families
+}
+
+// Value returns the sum of the series of the metric matching labels, and
+// whether there is any. Counters, gauges and untyped metrics add up their
+// values; histograms and summaries their sample counts.
+func (m Metrics) Value(name string, labels Labels) (float64, bool) {
+	family, ok := m[name]
+	if !ok {
+		return 0, false
+	}
+
+	var sum float64
+	found := false
+	for _, metric := range family.GetMetric() {
+		if !matchLabels(metric, labels) {
+			continue
+		}
+		found = true
+		switch {
+		case metric.Counter != nil:
+			sum += metric.GetCounter().GetValue()
+		case metric.Gauge != nil:
+			sum += metric.GetGauge().GetValue()
+		case metric.Histogram != nil:
+			sum += float64(metric.GetHistogram().GetSampleCount())
+		case metric.Summary != nil:
+			sum += float64(metric.GetSummary().GetSampleCount())
+		case metric.Untyped != nil:
+			sum += metric.GetUntyped().GetValue()
+		}
+	}
+	return sum, found
+}
+
+// AssertSeries checks that the metric has a series matching labels
+func (m Metrics) AssertSeries(t testing.TB, name string, labels Labels) bool {
+	t.Helper()
+
+	_, ok := m.Value(name, labels)
+	return assert.True(t, ok, "no series %s%v", name, map[string]string(labels))
+}
+
+// AssertIncreased checks that the series of the metric matching labels grew
+// since the before scrape, which may not have had them yet
+func (m Metrics) AssertIncreased(t testing.TB, before Metrics, name string, labels Labels) bool {
+	t.Helper()
+
+	if !m.AssertSeries(t, name, labels) {
+		return false
+	}
+	was, _ := before.Value(name, labels)
+	now, _ := m.Value(name, labels)
+	return assert.Greater(t, now, was, "%s%v did not increase", name, map[string]string(labels))
+}
+
+func matchLabels(metric *dto.Metric, labels Labels) bool {
+	matched := 0
+	for _, pair := range metric.GetLabel() {
+		if want, ok := labels[pair.GetName()]; ok {
+			if pair.GetValue() != want {
+				return false
+			}
+			matched++
+		}
+	}
+	return matched == len(labels)
+}
@@ -0,0 +1,153 @@
+package testutil
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// MinIOEndpointEnv names the environment variable pointing the tests at an
+// existing S3-compatible server, given as host:port, instead of a container.
+// It must accept the credentials of MinIOAccessKey and MinIOSecretKey.
+const MinIOEndpointEnv = "TEST_MINIO_ENDPOINT"
+
+// Credentials of the MinIO container
+const (
+	MinIOAccessKey = "minioadmin"
+	MinIOSecretKey = "minioadmin"
+)
+
+// defaultMinIOImage is the MinIO image used unless WithImage names another
+const defaultMinIOImage = "minio/minio:RELEASE.2024-10-13T13-34-11Z"
+
+// MinIOContainer wraps a MinIO testcontainer. Container is nil when the tests
+// run against the server named by TEST_MINIO_ENDPOINT.
+type MinIOContainer struct {
+	Container testcontainers.Container
+	// Endpoint is the host:port of the S3 API
+	Endpoint string
+}
+
+// StartMinIOContainer starts a MinIO testcontainer, removed when the test
+// ends, or uses the server named by TEST_MINIO_ENDPOINT when it is set.
+// WithImage, WithEnv, WithStartupTimeout and WithStartupAttempts apply.
+func StartMinIOContainer(t testing.TB, opts ...Option) *MinIOContainer {
+	t.Helper()
+
+	if endpoint := os.Getenv(MinIOEndpointEnv); endpoint != "" {
+		return &MinIOContainer{Endpoint: endpoint}
+	}
+
+	o := newOptions(append([]Option{
+		WithImage(defaultMinIOImage),
+		WithEnv(map[string]string{
+			"MINIO_ROOT_USER":     MinIOAccessKey,
+			"MINIO_ROOT_PASSWORD": MinIOSecretKey,
+		}),
+	}, opts...))
+	ctx := context.Background()
+	c, err := startWithRetry(ctx, "minio", o.startupAttempts, func() (*testcontainers.DockerContainer, error) {
+		return testcontainers.Run(ctx, o.image,
+			testcontainers.WithCmd("server", "/data"),
+			testcontainers.WithExposedPorts("9000/tcp"),
+			testcontainers.WithEnv(o.env),
+			testcontainers.WithWaitStrategy(
+				wait.ForHTTP("/minio/health/live").
+					WithPort("9000/tcp").
+					WithStartupTimeout(o.startupTimeout)),
+		)
+	})
+	require.NoError(t, err)
+	testcontainers.CleanupContainer(t, c)
+
+	endpoint, err := c.Endpoint(ctx, "")
+	require.NoError(t, err)
+
+	return &MinIOContainer{Container: c, Endpoint: endpoint}
+}
+
+// Settings returns the configuration keys enabling object storage in bucket
+// on the server, for apptest.Start. The application creates the bucket on
+// startup.
+func (mc *MinIOContainer) Settings(bucket string) map[string]any {
+	return map[string]any{
+		"storage.enabled":    true,
+		"storage.endpoint":   mc.Endpoint,
+		"storage.access_key": MinIOAccessKey,
+		"storage.secret_key": MinIOSecretKey,
+		"storage.bucket":     bucket,
+	}
+}
+
+// Client returns a client of the server
+func (mc *MinIOContainer) Client(t testing.TB) *minio.Client {
+	t.Helper()
+
+	client, err := minio.New(mc.Endpoint, &minio.Options{
+		Creds: credentials.NewStaticV4(MinIOAccessKey, MinIOSecretKey, ""),
+	})
+	require.NoError(t, err)
+	return client
+}
+
+// Object returns the info and content of an object, failing the test if it
+// doesn't exist
+func (mc *MinIOContainer) Object(t testing.TB, bucket, key string) (minio.ObjectInfo, []byte) {
+	t.Helper()
+
+	ctx := context.Background()
+	client := mc.Client(t)
+	info, err := client.StatObject(ctx, bucket, key, minio.StatObjectOptions{})
+	require.NoError(t, err, "object %s/%s", bucket, key)
+
+	obj, err := client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	require.NoError(t, err)
+	defer obj.Close()
+
+	data, err := io.ReadAll(obj)
+	require.NoError(t, err)
+	return info, data
+}
+
+// AssertNoObject fails the test if the object exists
+func (mc *MinIOContainer) AssertNoObject(t testing.TB, bucket, key string) {
+	t.Helper()
+
+	_, err := mc.Client(t).StatObject(context.Background(), bucket, key, minio.StatObjectOptions{})
+	require.Error(t, err, "object %s/%s exists", bucket, key)
+	require.Equal(t, "NoSuchKey", minio.ToErrorResponse(err).Code, "object %s/%s: %v", bucket, key, err)
+}
+
+// Objects returns the keys of the objects under prefix, in lexical order
+func (mc *MinIOContainer) Objects(t testing.TB, bucket, prefix string) []string {
+	t.Helper()
+
+	var keys []string
+	for obj := range mc.Client(t).ListObjects(context.Background(), bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		require.NoError(t, obj.Err)
+		keys = append(keys, obj.Key)
+	}
+	return keys
+}
+
+// FetchPresigned downloads a presigned URL without credentials, as a browser
+// would, and returns the response with its body read
+func FetchPresigned(t testing.TB, url string) (*http.Response, []byte) {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, body
+}
@@ -0,0 +1,129 @@
+package testutil
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// NATSURLEnv names the environment variable pointing the tests at an existing
+// NATS server, given as a nats:// URL, instead of a container
+const NATSURLEnv = "TEST_NATS_URL"
+
+// defaultNATSImage is the NATS image used unless WithImage names another
+const defaultNATSImage = "nats:2.10-alpine"
+
+// NATSContainer wraps a NATS testcontainer. Container is nil when the tests
+// run against the server named by TEST_NATS_URL.
+type NATSContainer struct {
+	Container testcontainers.Container
+	URL       string
+}
+
+// StartNATSContainer starts a NATS testcontainer, removed when the test ends,
+// or connects to the server named by TEST_NATS_URL when it is set. WithImage,
+// WithEnv, WithStartupTimeout and WithStartupAttempts apply.
+func StartNATSContainer(t testing.TB, opts ...Option) *NATSContainer {
+	t.Helper()
+
+	if url := os.Getenv(NATSURLEnv); url != "" {
+		return &NATSContainer{URL: url}
+	}
+
+	o := newOptions(append([]Option{WithImage(defaultNATSImage)}, opts...))
+	ctx := context.Background()
+	c, err := startWithRetry(ctx, "nats", o.startupAttempts, func() (*testcontainers.DockerContainer, error) {
+		return testcontainers.Run(ctx, o.image,
+			testcontainers.WithExposedPorts("4222/tcp"),
+			testcontainers.WithEnv(o.env),
+			testcontainers.WithWaitStrategy(
+				wait.ForAll(
+					wait.ForLog("Server is ready"),
+					wait.ForListeningPort("4222/tcp"),
+				).WithDeadline(o.startupTimeout)),
+		)
+	})
+	require.NoError(t, err)
+	testcontainers.CleanupContainer(t, c)
+
+	addr, err := c.Endpoint(ctx, "")
+	require.NoError(t, err)
+
+	return &NATSContainer{Container: c, URL: "nats://" + addr}
+}
+
+// Subscription buffers the messages published to a subject from the moment
+// it was created, so a test subscribes before causing the messages it
+// expects
+type Subscription struct {
+	sub *nats.Subscription
+}
+
+// Subscribe subscribes to subject, which may hold wildcards such as
+// "users.>". The subscription and its connection are closed when the test
+// ends.
+func (nc *NATSContainer) Subscribe(t testing.TB, subject string) *Subscription {
+	t.Helper()
+
+	conn, err := nats.Connect(nc.URL, nats.Name("example-db-test"))
+	require.NoError(t, err, "failed to connect to nats at %s", nc.URL)
+	t.Cleanup(conn.Close)
+
+	sub, err := conn.SubscribeSync(subject)
+	require.NoError(t, err)
+	// The server only delivers messages published after it has processed
+	// the subscription
+	require.NoError(t, conn.Flush())
+
+	return &Subscription{sub: sub}
+}
+
+// Next returns the next message, failing the test if none arrives within
+// timeout
+func (s *Subscription) Next(t testing.TB, timeout time.Duration) *nats.Msg {
+	t.Helper()
+
+	msg, err := s.sub.NextMsg(timeout)
+	require.NoError(t, err, "no message on %s within %s", s.sub.Subject, timeout)
+	return msg
+}
+
+// WaitFor returns the first message that match accepts, skipping the others,
+// and fails the test if none arrives within timeout
+func (s *Subscription) WaitFor(t testing.TB, timeout time.Duration, match func(*nats.Msg) bool) *nats.Msg {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			t.Fatalf("no matching message on %s within %s", s.sub.Subject, timeout)
+		}
+		msg, err := s.sub.NextMsg(remaining)
+		if errors.Is(err, nats.ErrTimeout) {
+			t.Fatalf("no matching message on %s within %s", s.sub.Subject, timeout)
+		}
+		require.NoError(t, err)
+		if match(msg) {
+			return msg
+		}
+	}
+}
+
+// ExpectNone fails the test if a message arrives within d
+func (s *Subscription) ExpectNone(t testing.TB, d time.Duration) {
+	t.Helper()
+
+	msg, err := s.sub.NextMsg(d)
+	if err == nil {
+		t.Fatalf("unexpected message on %s: %s", msg.Subject, msg.Data)
+	}
+	require.ErrorIs(t, err, nats.ErrTimeout)
+}
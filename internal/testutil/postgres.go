@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"io"
 	"os"
 	"testing"
 	"time"
@@ -12,6 +13,7 @@ import (
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/modules/postgres"
 	"github.com/testcontainers/testcontainers-go/wait"
+	"github.com/things-kit/example-db/internal/migrations"
 )
 
 const (
@@ -20,36 +22,145 @@ const (
 	dbPassword = "password"
 )
 
-// PostgresContainer wraps the testcontainers postgres container
+// DSNEnv names the environment variable pointing the tests at an existing
+// database, given as a postgres:// URL, instead of a container. The role needs
+// the CREATEDB privilege for NewDatabase.
+const DSNEnv = "TEST_DATABASE_DSN"
+
+// PostgresContainer wraps the testcontainers postgres container. Container
+// is nil when the tests run against the database named by TEST_DATABASE_DSN.
 type PostgresContainer struct {
 	Container *postgres.PostgresContainer
 	DSN       string
+
+	template templateDB
+}
+
+// ImageEnv names the environment variable overriding the default Postgres
+// image, for running the tests against other server versions
+const ImageEnv = "TEST_POSTGRES_IMAGE"
+
+// defaultImage is the Postgres image used unless ImageEnv or WithImage names
+// another
+const defaultImage = "postgres:15-alpine"
+
+// Option configures the container started by StartPostgresContainer, Main or
+// StartRedisContainer
+type Option func(*options)
+
+type options struct {
+	image           string
+	startupTimeout  time.Duration
+	env             map[string]string
+	initScripts     []string
+	startupAttempts int
+}
+
+// WithImage runs the given Postgres image, such as "postgres:17-alpine"
+func WithImage(image string) Option {
+	return func(o *options) {
+		o.image = image
+	}
+}
+
+// WithStartupTimeout sets how long to wait for the container to accept
+// connections; the default is 60 seconds
+func WithStartupTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.startupTimeout = d
+	}
+}
+
+// WithStartupAttempts sets how often to try starting the container before
+// failing; the default is 3
+func WithStartupAttempts(n int) Option {
+	return func(o *options) {
+		o.startupAttempts = max(n, 1)
+	}
+}
+
+// WithEnv sets extra environment variables of the container
+func WithEnv(env map[string]string) Option {
+	return func(o *options) {
+		for k, v := range env {
+			o.env[k] = v
+		}
+	}
 }
 
-// StartPostgresContainer starts a PostgreSQL testcontainer
-func StartPostgresContainer(t *testing.T) *PostgresContainer {
+// WithInitScripts runs SQL or shell scripts when the database is first
+// created, in the given order
+func WithInitScripts(paths ...string) Option {
+	return func(o *options) {
+		o.initScripts = append(o.initScripts, paths...)
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		image:           defaultImage,
+		startupTimeout:  60 * time.Second,
+		startupAttempts: 3,
+		env:             map[string]string{},
+	}
+	if image := os.Getenv(ImageEnv); image != "" {
+		o.image = image
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// StartPostgresContainer starts a PostgreSQL testcontainer, or connects to
+// the database named by TEST_DATABASE_DSN when it is set
+func StartPostgresContainer(t testing.TB, opts ...Option) *PostgresContainer {
 	t.Helper()
 
-	ctx := context.Background()
+	pc, err := runPostgres(context.Background(), newOptions(opts))
+	require.NoError(t, err)
+	return pc
+}
+
+// runPostgres starts a PostgreSQL container with extra customizers, unless
+// TEST_DATABASE_DSN names a database to use instead
+func runPostgres(ctx context.Context, o options, extra ...testcontainers.ContainerCustomizer) (*PostgresContainer, error) {
+	if dsn := os.Getenv(DSNEnv); dsn != "" {
+		return &PostgresContainer{DSN: dsn}, nil
+	}
 
-	pgContainer, err := postgres.RunContainer(ctx,
-		testcontainers.WithImage("postgres:15-alpine"),
+	opts := append([]testcontainers.ContainerCustomizer{
+		testcontainers.WithImage(o.image),
 		postgres.WithDatabase(dbName),
 		postgres.WithUsername(dbUser),
 		postgres.WithPassword(dbPassword),
+		testcontainers.WithEnv(o.env),
 		testcontainers.WithWaitStrategy(
 			wait.ForLog("database system is ready to accept connections").
 				WithOccurrence(2).
-				WithStartupTimeout(5*time.Second)),
-	)
-	require.NoError(t, err)
+				WithStartupTimeout(o.startupTimeout)),
+	}, extra...)
+	if len(o.initScripts) > 0 {
+		opts = append(opts, postgres.WithInitScripts(o.initScripts...))
+	}
+
+	pgContainer, err := startWithRetry(ctx, "postgres", o.startupAttempts, func() (*postgres.PostgresContainer, error) {
+		return postgres.RunContainer(ctx, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	// Get connection string
 	host, err := pgContainer.Host(ctx)
-	require.NoError(t, err)
+	if err != nil {
+		return nil, err
+	}
 
 	port, err := pgContainer.MappedPort(ctx, "5432")
-	require.NoError(t, err)
+	if err != nil {
+		return nil, err
+	}
 
 	dsn := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
 		dbUser, dbPassword, host, port.Port(), dbName)
@@ -57,18 +168,74 @@ func StartPostgresContainer(t *testing.T) *PostgresContainer {
 	return &PostgresContainer{
 		Container: pgContainer,
 		DSN:       dsn,
+	}, nil
+}
+
+// startWithRetry starts a container with run, trying again after transient
+// Docker failures. The error of the last attempt carries the container's logs.
+func startWithRetry[C interface {
+	comparable
+	testcontainers.Container
+}](ctx context.Context, name string, attempts int, run func() (C, error)) (C, error) {
+	var none C
+	for attempt := 1; ; attempt++ {
+		c, err := run()
+		if err == nil {
+			return c, nil
+		}
+
+		// A container may have been created even though it failed to start
+		created := c != none
+		if attempt >= attempts {
+			var logs string
+			if created {
+				logs = containerLogs(ctx, c)
+				_ = c.Terminate(ctx)
+			}
+			return none, fmt.Errorf("failed to start %s container after %d attempts: %w%s", name, attempt, err, logs)
+		}
+
+		fmt.Fprintf(os.Stderr, "testutil: starting %s container failed (attempt %d of %d): %v\n", name, attempt, attempts, err)
+		if created {
+			_ = c.Terminate(ctx)
+		}
+		time.Sleep(time.Duration(attempt) * time.Second)
 	}
 }
 
-// Terminate stops the container
-func (pc *PostgresContainer) Terminate(t *testing.T) {
+// maxLogBytes bounds the container logs included in a startup error
+const maxLogBytes = 16 << 10
+
+// containerLogs returns the tail of a container's logs for an error message
+func containerLogs(ctx context.Context, c testcontainers.Container) string {
+	r, err := c.Logs(ctx)
+	if err != nil {
+		return fmt.Sprintf("\n(container logs unavailable: %v)", err)
+	}
+	defer r.Close()
+
+	logs, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Sprintf("\n(container logs unavailable: %v)", err)
+	}
+	if len(logs) > maxLogBytes {
+		logs = logs[len(logs)-maxLogBytes:]
+	}
+	return "\ncontainer logs:\n" + string(logs)
+}
+
+// Terminate stops the container. An external database is left as is.
+func (pc *PostgresContainer) Terminate(t testing.TB) {
 	t.Helper()
+	if pc.Container == nil {
+		return
+	}
 	ctx := context.Background()
 	require.NoError(t, pc.Container.Terminate(ctx))
 }
 
 // InitSchema initializes the database schema
-func (pc *PostgresContainer) InitSchema(t *testing.T, schemaPath string) {
+func (pc *PostgresContainer) InitSchema(t testing.TB, schemaPath string) {
 	t.Helper()
 
 	// Read schema file
@@ -84,3 +251,56 @@ func (pc *PostgresContainer) InitSchema(t *testing.T, schemaPath string) {
 	_, err = db.Exec(string(schema))
 	require.NoError(t, err)
 }
+
+// Migrate applies the versioned migrations, as the server does on startup.
+// Prefer it over InitSchema so tests run against the schema production runs.
+func (pc *PostgresContainer) Migrate(t testing.TB) {
+	t.Helper()
+	require.NoError(t, migrate(pc.DSN))
+}
+
+// migrate applies the versioned migrations to the database at dsn
+func migrate(dsn string) error {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	_, err = migrator.Up(context.Background())
+	return err
+}
+
+// Reset empties every table of the container's main database and restarts
+// their sequences, so a test can start from a clean slate without re-creating
+// the schema. Migration history is kept.
+func (pc *PostgresContainer) Reset(t testing.TB) {
+	t.Helper()
+	ResetDatabase(t, pc.DSN)
+}
+
+// ResetDatabase empties every table of the database at dsn, as Reset does
+func ResetDatabase(t testing.TB, dsn string) {
+	t.Helper()
+
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var tables sql.NullString
+	err = db.QueryRow(`
+		SELECT string_agg(format('%I.%I', schemaname, tablename), ', ')
+		FROM pg_tables
+		WHERE schemaname = 'public' AND tablename <> 'goose_db_version'`).Scan(&tables)
+	require.NoError(t, err)
+	if !tables.Valid {
+		return
+	}
+
+	_, err = db.Exec("TRUNCATE " + tables.String + " RESTART IDENTITY CASCADE")
+	require.NoError(t, err)
+}
@@ -0,0 +1,70 @@
+package testutil
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// RedisAddrEnv names the environment variable pointing the tests at an
+// existing Redis server, given as host:port, instead of a container
+const RedisAddrEnv = "TEST_REDIS_ADDR"
+
+// defaultRedisImage is the Redis image used unless WithImage names another
+const defaultRedisImage = "redis:7-alpine"
+
+// RedisContainer wraps a Redis testcontainer. Container is nil when the tests
+// run against the server named by TEST_REDIS_ADDR.
+type RedisContainer struct {
+	Container testcontainers.Container
+	Addr      string
+}
+
+// StartRedisContainer starts a Redis testcontainer, removed when the test
+// ends, or connects to the server named by TEST_REDIS_ADDR when it is set.
+// WithImage, WithEnv, WithStartupTimeout and WithStartupAttempts apply.
+func StartRedisContainer(t testing.TB, opts ...Option) *RedisContainer {
+	t.Helper()
+
+	if addr := os.Getenv(RedisAddrEnv); addr != "" {
+		return &RedisContainer{Addr: addr}
+	}
+
+	o := newOptions(append([]Option{WithImage(defaultRedisImage)}, opts...))
+	ctx := context.Background()
+	c, err := startWithRetry(ctx, "redis", o.startupAttempts, func() (*testcontainers.DockerContainer, error) {
+		return testcontainers.Run(ctx, o.image,
+			testcontainers.WithExposedPorts("6379/tcp"),
+			testcontainers.WithEnv(o.env),
+			testcontainers.WithWaitStrategy(
+				wait.ForAll(
+					wait.ForLog("Ready to accept connections"),
+					wait.ForListeningPort("6379/tcp"),
+				).WithDeadline(o.startupTimeout)),
+		)
+	})
+	require.NoError(t, err)
+	testcontainers.CleanupContainer(t, c)
+
+	addr, err := c.Endpoint(ctx, "")
+	require.NoError(t, err)
+
+	return &RedisContainer{Container: c, Addr: addr}
+}
+
+// Client returns a client of the server, closed when the test ends. The
+// server is shared by the tests using it, so they should use keys of their
+// own or flush it first.
+func (rc *RedisContainer) Client(t testing.TB) *redis.Client {
+	t.Helper()
+
+	client := redis.NewClient(&redis.Options{Addr: rc.Addr})
+	t.Cleanup(func() { client.Close() })
+	require.NoError(t, client.Ping(context.Background()).Err(), "failed to reach redis at %s", rc.Addr)
+	return client
+}
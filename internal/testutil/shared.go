@@ -0,0 +1,57 @@
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/testcontainers/testcontainers-go"
+)
+
+// sharedName names the container shared by test packages. Reusing it by name
+// lets every package, and every later run, attach to the same container
+// instead of starting one per test. The image is part of the name so runs
+// with another image don't attach to it.
+func sharedName(image string) string {
+	return "example-db-test-" + invalidNameChars.ReplaceAllString(image, "-")
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+var shared *PostgresContainer
+
+// Main starts or reuses the shared Postgres container, then runs the tests of
+// the package. Call it from TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		testutil.Main(m)
+//	}
+//
+// The container outlives the tests so the next package or run can reuse it.
+// The testcontainers reaper removes it once no test process is using it. With
+// TEST_DATABASE_DSN set, no container is started.
+func Main(m *testing.M, opts ...Option) {
+	o := newOptions(opts)
+	pc, err := runPostgres(context.Background(), o, testcontainers.WithReuseByName(sharedName(o.image)))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start shared postgres container: %v\n", err)
+		os.Exit(1)
+	}
+	shared = pc
+
+	os.Exit(m.Run())
+}
+
+// Shared returns the container started by Main. Data in its main database
+// outlives the test, so tests should work in a database of their own from
+// NewDatabase.
+func Shared(t testing.TB) *PostgresContainer {
+	t.Helper()
+
+	if shared == nil {
+		t.Fatal("testutil.Shared requires TestMain to call testutil.Main")
+	}
+	return shared
+}
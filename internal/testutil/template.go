@@ -0,0 +1,166 @@
+package testutil
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/migrations"
+)
+
+// templateLock is the advisory lock key held while a template is created, as
+// test processes sharing a container may try to create it at the same time
+const templateLock = 7_001_001
+
+// templateDB tracks the template database of a container
+type templateDB struct {
+	once sync.Once
+	name string
+	err  error
+	// mu serializes clones; concurrent copies of one template can fail with
+	// "source database is being accessed by other users"
+	mu sync.Mutex
+	n  atomic.Int64
+}
+
+// NewDatabase returns the DSN of a new, migrated database for the test alone,
+// dropped when the test ends. The migrations are applied to a template
+// database on the first call; every database after that is a copy of it made
+// with CREATE DATABASE ... TEMPLATE, which takes milliseconds. Tests using
+// their own database can run with t.Parallel().
+func (pc *PostgresContainer) NewDatabase(t testing.TB) string {
+	t.Helper()
+
+	pc.template.once.Do(func() {
+		pc.template.name, pc.template.err = pc.createTemplate()
+	})
+	require.NoError(t, pc.template.err, "failed to create template database")
+
+	return pc.newDatabase(t, pc.template.name)
+}
+
+// NewEmptyDatabase returns the DSN of a new database without any migration
+// applied, dropped when the test ends, for tests that build a schema another
+// way
+func (pc *PostgresContainer) NewEmptyDatabase(t testing.TB) string {
+	t.Helper()
+	return pc.newDatabase(t, "template0")
+}
+
+// newDatabase creates a database for the test from a template
+func (pc *PostgresContainer) newDatabase(t testing.TB, template string) string {
+	t.Helper()
+
+	// The process ID keeps names unique among processes sharing the container
+	name := fmt.Sprintf("test_%d_%d", os.Getpid(), pc.template.n.Add(1))
+	pc.template.mu.Lock()
+	err := pc.exec(fmt.Sprintf("CREATE DATABASE %s TEMPLATE %s", name, template))
+	pc.template.mu.Unlock()
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		if err := pc.exec(fmt.Sprintf("DROP DATABASE IF EXISTS %s WITH (FORCE)", name)); err != nil {
+			t.Logf("failed to drop database %s: %v", name, err)
+		}
+	})
+
+	return pc.databaseDSN(name)
+}
+
+// createTemplate creates and migrates the template database unless it exists,
+// then closes it to connections so it can be copied. The template is named
+// after a hash of the migrations, so a reused container never serves a
+// template migrated by an older tree.
+func (pc *PostgresContainer) createTemplate() (string, error) {
+	name, err := templateName()
+	if err != nil {
+		return "", err
+	}
+
+	ctx := context.Background()
+	db, err := sql.Open("postgres", pc.DSN)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	// The lock is released when db closes the connection
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", templateLock); err != nil {
+		return "", err
+	}
+
+	var exists bool
+	if err := conn.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)", name).Scan(&exists); err != nil {
+		return "", err
+	}
+	if exists {
+		return name, nil
+	}
+
+	if _, err := conn.ExecContext(ctx, "CREATE DATABASE "+name); err != nil {
+		return "", err
+	}
+	if err := migrate(pc.databaseDSN(name)); err != nil {
+		return "", err
+	}
+	_, err = conn.ExecContext(ctx, fmt.Sprintf("ALTER DATABASE %s WITH IS_TEMPLATE true ALLOW_CONNECTIONS false", name))
+	return name, err
+}
+
+// templateName returns the name of the template database for the embedded
+// migrations
+func templateName() (string, error) {
+	h := sha256.New()
+	err := fs.WalkDir(migrations.FS, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(migrations.FS, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%s\x00", path, data)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return "template_" + hex.EncodeToString(h.Sum(nil))[:12], nil
+}
+
+// exec runs a statement on the container's main database
+func (pc *PostgresContainer) exec(query string) error {
+	db, err := sql.Open("postgres", pc.DSN)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.ExecContext(context.Background(), query)
+	return err
+}
+
+// databaseDSN returns pc.DSN pointed at another database
+func (pc *PostgresContainer) databaseDSN(name string) string {
+	u, err := url.Parse(pc.DSN)
+	if err != nil {
+		panic(fmt.Sprintf("invalid DSN %q: %v", pc.DSN, err))
+	}
+	u.Path = "/" + name
+	return u.String()
+}
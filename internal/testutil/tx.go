@@ -0,0 +1,33 @@
+package testutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+)
+
+// BeginTx begins a transaction that is rolled back when the test ends, so
+// nothing the test writes through it outlives the test. Bind a repository to
+// it with Repository.Bind to isolate repository tests on a shared database:
+//
+//	repo := repo.Bind(testutil.BeginTx(t, pool))
+//
+// A statement that fails aborts the transaction, so tests expecting database
+// errors, such as unique violations, should use NewDatabase instead.
+func BeginTx(t testing.TB, pool *pgxpool.Pool) pgx.Tx {
+	t.Helper()
+
+	ctx := context.Background()
+	tx, err := pool.Begin(ctx)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		if err := tx.Rollback(ctx); err != nil {
+			t.Errorf("failed to roll back test transaction: %v", err)
+		}
+	})
+	return tx
+}
@@ -0,0 +1,59 @@
+package tracing
+
+import (
+	"errors"
+
+	"github.com/spf13/viper"
+	"github.com/things-kit/example-db/internal/config"
+	"github.com/things-kit/example-db/internal/middleware"
+	"go.uber.org/fx"
+)
+
+// Module provides the tracer provider and the tracing middleware, which
+// starts the server span and tags it with the request ID. It also provides
+// the database.SQLOpener instrumenting the database/sql pool, applied with
+// fx.Decorate(database.ReopenSQLDB).
+var Module = fx.Module("tracing",
+	fx.Provide(NewConfig, NewTracerProvider, NewSQLOpener),
+	config.Validate[*Config]("tracing"),
+	middleware.AsMiddleware(NewMiddleware),
+	middleware.AsMiddleware(NewRequestIDMiddleware),
+)
+
+// Config holds the tracing configuration
+type Config struct {
+	Enabled     bool    `mapstructure:"enabled"`
+	ServiceName string  `mapstructure:"service_name"`
+	Endpoint    string  `mapstructure:"endpoint"`
+	Insecure    bool    `mapstructure:"insecure"`
+	SampleRatio float64 `mapstructure:"sample_ratio"`
+}
+
+// NewConfig loads the tracing configuration from the "tracing" key
+func NewConfig(v *viper.Viper) *Config {
+	cfg := &Config{
+		Enabled:     false,
+		ServiceName: "example-db",
+		Endpoint:    "localhost:4318",
+		Insecure:    true,
+		SampleRatio: 1,
+	}
+
+	if v != nil {
+		_ = v.UnmarshalKey("tracing", cfg)
+	}
+
+	return cfg
+}
+
+// Validate checks the exporter settings of enabled tracing
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	return errors.Join(
+		config.Required("service_name", c.ServiceName),
+		config.Required("endpoint", c.Endpoint),
+		config.Between("sample_ratio", c.SampleRatio, 0, 1),
+	)
+}
@@ -0,0 +1,117 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/XSAM/otelsql"
+	"github.com/gin-gonic/gin"
+	"github.com/things-kit/example-db/internal/database"
+	"github.com/things-kit/example-db/internal/middleware"
+	"github.com/things-kit/example-db/internal/reqlog"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/fx"
+)
+
+// NewTracerProvider creates the tracer provider and installs it as the global
+// provider, so packages using otel.Tracer pick it up. When tracing is disabled
+// a no-op provider is returned.
+func NewTracerProvider(lc fx.Lifecycle, cfg *Config) (trace.TracerProvider, error) {
+	if !cfg.Enabled {
+		return noop.NewTracerProvider(), nil
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(cfg.ServiceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			return tp.Shutdown(ctx)
+		},
+	})
+
+	return tp, nil
+}
+
+// NewMiddleware starts a server span for every request. It runs before all
+// other middleware so their work is part of the span.
+func NewMiddleware(cfg *Config, tp trace.TracerProvider) middleware.Middleware {
+	return middleware.Middleware{
+		Name:    "tracing",
+		Order:   0,
+		Handler: newHandler(cfg.ServiceName, tp),
+	}
+}
+
+func newHandler(service string, tp trace.TracerProvider) gin.HandlerFunc {
+	return otelgin.Middleware(service, otelgin.WithTracerProvider(tp))
+}
+
+// RequestIDKey is the server span attribute holding the request ID, named
+// like the request_id log field so traces and logs can be joined
+const RequestIDKey = attribute.Key("request_id")
+
+// NewRequestIDMiddleware records the request ID on the server span. It runs
+// right after the middleware starting the span, which the request ID
+// middleware runs before.
+func NewRequestIDMiddleware() middleware.Middleware {
+	return middleware.Middleware{
+		Name:    "tracing_request_id",
+		Order:   1,
+		Handler: tagRequestID,
+	}
+}
+
+func tagRequestID(c *gin.Context) {
+	ctx := c.Request.Context()
+	if id := reqlog.RequestID(ctx); id != "" {
+		trace.SpanFromContext(ctx).SetAttributes(RequestIDKey.String(id))
+	}
+	c.Next()
+}
+
+// NewSQLOpener returns the opener of the database/sql pool, whose driver
+// records a span for every statement. It returns nil when tracing is
+// disabled, which keeps the pool of the sqlc module.
+func NewSQLOpener(cfg *Config, tp trace.TracerProvider) database.SQLOpener {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	return func(c driver.Connector) *sql.DB {
+		return otelsql.OpenDB(c,
+			otelsql.WithTracerProvider(tp),
+			otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+		)
+	}
+}
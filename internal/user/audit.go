@@ -0,0 +1,189 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/things-kit/example-db/internal/audit"
+	"github.com/things-kit/example-db/internal/tenant"
+	"github.com/things-kit/example-db/internal/user/userdb"
+)
+
+// Audit actions recorded for user mutations
+const (
+	AuditCreate     = "create"
+	AuditUpdate     = "update"
+	AuditDelete     = "delete"
+	AuditGrantAdmin = "grant_admin"
+	AuditSetAvatar  = "set_avatar"
+
+	AuditUpdatePreferences = "update_preferences"
+	AuditSuspend           = "suspend"
+	AuditActivate          = "activate"
+	AuditDeactivate        = "deactivate"
+	AuditUpdateProfile     = "update_profile"
+	AuditErase             = "erase"
+	AuditMerge             = "merge"
+)
+
+// AuditEntry is one recorded change to a user, with JSON snapshots of the
+// affected fields before and after
+type AuditEntry struct {
+	ID        int64           `json:"id"`
+	UserID    int64           `json:"user_id"`
+	Action    string          `json:"action"`
+	Actor     string          `json:"actor"`
+	Old       json.RawMessage `json:"old,omitempty"`
+	New       json.RawMessage `json:"new,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// GetForUpdate retrieves a user and locks the row until the transaction ends.
+// Call it inside WithTx.
+func (r *Repository) GetForUpdate(ctx context.Context, id int64) (*User, error) {
+	var row userdb.GetUserForUpdateRow
+	err := r.write(ctx, "GetForUpdate", func(ctx context.Context, q conn) (err error) {
+		row, err = q.GetUserForUpdate(ctx, userdb.GetUserForUpdateParams{
+			ID:       id,
+			TenantID: tenant.FromContext(ctx),
+		})
+		return err
+	})
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	user := User(row)
+	return &user, nil
+}
+
+// AddAudit records a change to a user by the actor in ctx. Call it inside
+// WithTx so the entry commits or rolls back with the change.
+func (r *Repository) AddAudit(ctx context.Context, id int64, action string, before, after any) error {
+	oldData, err := snapshot(before)
+	if err != nil {
+		return err
+	}
+	newData, err := snapshot(after)
+	if err != nil {
+		return err
+	}
+
+	err = r.write(ctx, "AddAudit", func(ctx context.Context, q conn) error {
+		return q.InsertAuditEntry(ctx, userdb.InsertAuditEntryParams{
+			UserID:    id,
+			Action:    action,
+			Actor:     audit.Actor(ctx),
+			OldData:   oldData,
+			NewData:   newData,
+			CreatedAt: r.clock.Now(),
+			TenantID:  tenant.FromContext(ctx),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// ListAudit retrieves up to limit changes to a user, newest first
+func (r *Repository) ListAudit(ctx context.Context, id int64, limit int) ([]AuditEntry, error) {
+	ctx, span := tracer.Start(ctx, "user.Repository.ListAudit")
+	defer span.End()
+
+	var rows []userdb.ListAuditEntriesRow
+	err := r.read(ctx, "ListAudit", func(ctx context.Context, q conn) (err error) {
+		rows, err = q.ListAuditEntries(ctx, userdb.ListAuditEntriesParams{
+			UserID:   id,
+			Limit:    int32(limit),
+			TenantID: tenant.FromContext(ctx),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+
+	entries := make([]AuditEntry, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, AuditEntry{
+			ID:        row.ID,
+			UserID:    row.UserID,
+			Action:    row.Action,
+			Actor:     row.Actor,
+			Old:       row.OldData,
+			New:       row.NewData,
+			CreatedAt: row.CreatedAt,
+		})
+	}
+
+	return entries, nil
+}
+
+// MoveAudit hands the audit entries of user from to user to, such as when
+// from is merged into to. It returns the number of entries moved.
+func (r *Repository) MoveAudit(ctx context.Context, from, to int64) (int64, error) {
+	var n int64
+	err := r.write(ctx, "MoveAudit", func(ctx context.Context, q conn) (err error) {
+		n, err = q.MoveAuditEntries(ctx, userdb.MoveAuditEntriesParams{
+			ToUserID:   to,
+			FromUserID: from,
+			TenantID:   tenant.FromContext(ctx),
+		})
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to move audit entries: %w", err)
+	}
+
+	return n, nil
+}
+
+// redactedFields are the snapshot fields holding personal data. The audit
+// log outlives the users it records, so their values are replaced by
+// redactedValue: an entry shows that they changed, not what they were.
+var redactedFields = []string{"email", "name", "phone", "address", "bio", "avatar_url"}
+
+const redactedValue = `"[REDACTED]"`
+
+// snapshot encodes v as JSON with its personal data redacted, or returns nil
+// for a nil value
+func snapshot(v any) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audit snapshot: %w", err)
+	}
+	if string(data) == "null" {
+		return nil, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(data, &fields) != nil {
+		return data, nil
+	}
+	redacted := false
+	for _, name := range redactedFields {
+		if _, ok := fields[name]; ok {
+			fields[name] = json.RawMessage(redactedValue)
+			redacted = true
+		}
+	}
+	if !redacted {
+		return data, nil
+	}
+	return json.Marshal(fields)
+}
@@ -0,0 +1,37 @@
+package user
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	data, err := snapshot(&User{ID: 1, Name: "John", Email: "john@example.com", IsAdmin: true})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"name":"[REDACTED]"`)
+	assert.Contains(t, string(data), `"email":"[REDACTED]"`)
+	assert.Contains(t, string(data), `"is_admin":true`)
+	assert.NotContains(t, string(data), "john")
+
+	data, err = snapshot(&Profile{UserID: 1, Phone: "+1 555 0100", Address: "1 Main St", Bio: "Hi, I'm John", AvatarURL: "https://example.com/john.png"})
+	require.NoError(t, err)
+	for _, field := range []string{"phone", "address", "bio", "avatar_url"} {
+		assert.Contains(t, string(data), `"`+field+`":"[REDACTED]"`)
+	}
+	assert.Contains(t, string(data), `"user_id":1`)
+	assert.NotContains(t, string(data), "John")
+	assert.NotContains(t, string(data), "john")
+
+	data, err = snapshot(map[string]bool{"is_admin": true})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"is_admin": true}`, string(data))
+
+	var missing *User
+	for _, v := range []any{nil, missing} {
+		data, err := snapshot(v)
+		require.NoError(t, err)
+		assert.Nil(t, data, "absent snapshots are stored as NULL")
+	}
+}
@@ -0,0 +1,113 @@
+package user
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/things-kit/example-db/internal/reqlog"
+	"github.com/things-kit/module/log"
+)
+
+// MaxAvatarSize is the largest accepted avatar upload in bytes
+const MaxAvatarSize = 5 << 20
+
+// ErrInvalidAvatar is returned when an upload is not a supported image
+var ErrInvalidAvatar = errors.New("avatar must be a PNG, JPEG, GIF or WebP image")
+
+// ErrAvatarTooLarge is returned when an upload is larger than MaxAvatarSize
+var ErrAvatarTooLarge = errors.New("avatar is larger than 5 MB")
+
+// ErrNoAvatar is returned when a user has not uploaded an avatar
+var ErrNoAvatar = errors.New("user has no avatar")
+
+// avatarExtensions maps accepted content types to object key extensions
+var avatarExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// AvatarURL is a time-limited download link for a user's avatar
+type AvatarURL struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// UploadAvatar stores an avatar image and points the user at it. The content
+// type is sniffed from the data rather than trusted from the client.
+func (s *Service) UploadAvatar(ctx context.Context, id int64, r io.Reader) error {
+	data, err := io.ReadAll(io.LimitReader(r, MaxAvatarSize+1))
+	if err != nil {
+		return fmt.Errorf("failed to read avatar: %w", err)
+	}
+	if len(data) > MaxAvatarSize {
+		return ErrAvatarTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, ok := avatarExtensions[contentType]
+	if !ok {
+		return ErrInvalidAvatar
+	}
+
+	if err := s.EnsureActive(ctx, id); err != nil {
+		return err
+	}
+
+	oldKey, err := s.repo.GetAvatarKey(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	name, err := s.ids.UUID()
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("avatars/%d/%s%s", id, name, ext)
+	if err := s.store.Put(ctx, key, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		return err
+	}
+
+	err = s.repo.WithTx(ctx, func(repo UserRepository) error {
+		if err := repo.SetAvatar(ctx, id, key); err != nil {
+			return err
+		}
+		return repo.AddAudit(ctx, id, AuditSetAvatar,
+			map[string]string{"avatar_key": oldKey}, map[string]string{"avatar_key": key})
+	})
+	if err != nil {
+		return err
+	}
+
+	if oldKey != "" {
+		if err := s.store.Delete(ctx, oldKey); err != nil {
+			reqlog.FromContext(ctx, s.log).Error("Failed to delete previous avatar", err, log.Field{Key: "key", Value: oldKey})
+		}
+	}
+
+	return nil
+}
+
+// AvatarURL returns a presigned download URL for a user's avatar
+func (s *Service) AvatarURL(ctx context.Context, id int64) (*AvatarURL, error) {
+	key, err := s.repo.GetAvatarKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if key == "" {
+		return nil, ErrNoAvatar
+	}
+
+	url, expires, err := s.store.PresignGet(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AvatarURL{URL: url, ExpiresAt: expires}, nil
+}
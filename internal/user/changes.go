@@ -0,0 +1,203 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/things-kit/example-db/internal/tenant"
+	"github.com/things-kit/example-db/internal/user/userdb"
+)
+
+// Change types of the changes feed
+const (
+	ChangeCreated = "created"
+	ChangeUpdated = "updated"
+	ChangeDeleted = "deleted"
+)
+
+// maxChanges is the largest page of the changes feed. The handler asks for
+// one change more, to tell whether there is another page.
+const maxChanges = 500
+
+// ChangeFilter selects a page of the changes feed
+type ChangeFilter struct {
+	// AfterSeq skips changes up to this sequence number, the cursor of the
+	// previous page
+	AfterSeq int64
+	// Since skips changes recorded at or before this time
+	Since time.Time
+	// NotBefore is the oldest position still served; older ones get
+	// ErrResyncRequired. The zero time serves every position.
+	NotBefore time.Time
+	Limit     int
+}
+
+// Change is the current state of a user that changed after the position of
+// a ChangeFilter. The feed is built from the audit log, which records every
+// mutation in its transaction.
+type Change struct {
+	// Seq is the ID of the latest audit entry of the user; the next page
+	// starts after it
+	Seq int64
+	// Type is ChangeDeleted for deleted, erased and removed users,
+	// ChangeCreated for users created after the position and ChangeUpdated
+	// otherwise
+	Type string
+	// User is the current state of the user. For users that no longer have
+	// a row, hard deleted or purged, only the IDs are set.
+	User *User
+	// DeletedAt is set for deleted users. For removed users it is the time
+	// of their latest audit entry.
+	DeletedAt *time.Time
+}
+
+// parseSince reads the since parameter of the changes feed: empty for all
+// changes, the cursor of a previous page or an RFC 3339 timestamp
+func parseSince(since string) (ChangeFilter, bool) {
+	var f ChangeFilter
+	if since == "" {
+		return f, true
+	}
+	if seq, err := strconv.ParseInt(since, 10, 64); err == nil && seq >= 0 {
+		f.AfterSeq = seq
+		return f, true
+	}
+	at, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		return f, false
+	}
+	f.Since = at.UTC()
+	return f, true
+}
+
+// newChange builds a Change from the state of a user
+func newChange(seq int64, created bool, u *User, deletedAt *time.Time) Change {
+	c := Change{Seq: seq, Type: ChangeUpdated, User: u, DeletedAt: deletedAt}
+	switch {
+	case deletedAt != nil:
+		c.Type = ChangeDeleted
+	case created:
+		c.Type = ChangeCreated
+	}
+	return c
+}
+
+// removedChange builds the tombstone of a user that no longer has a row from
+// its latest audit entry
+func removedChange(seq, id int64, uid uuid.UUID, changedAt time.Time) Change {
+	return Change{Seq: seq, Type: ChangeDeleted, User: &User{ID: id, UUID: uid}, DeletedAt: &changedAt}
+}
+
+// SetPurgeRetention sets how long deleted users are kept before the purge
+// worker removes them. The changes feed answers older positions with
+// ErrResyncRequired.
+func (s *Service) SetPurgeRetention(d time.Duration) {
+	s.retention = d
+}
+
+// Changes returns the users changed after the position of f, live or
+// deleted, in the order of their latest change. A user changed several times
+// is returned once, in its current state.
+func (s *Service) Changes(ctx context.Context, f ChangeFilter) ([]Change, error) {
+	if s.retention > 0 {
+		f.NotBefore = s.clock.Now().Add(-s.retention)
+	}
+	return s.repo.ListChanges(ctx, f)
+}
+
+// ListChanges returns up to f.Limit users with audit entries after the
+// position of f, in the order of their latest entry. Entries are listed in
+// the order their transactions committed, once no transaction before theirs
+// is running.
+func (r *Repository) ListChanges(ctx context.Context, f ChangeFilter) ([]Change, error) {
+	ctx, span := tracer.Start(ctx, "user.Repository.ListChanges")
+	defer span.End()
+
+	if !f.Since.IsZero() && f.Since.Before(f.NotBefore) {
+		return nil, ErrResyncRequired
+	}
+
+	tenantID := tenant.FromContext(ctx)
+	after := userdb.GetAuditPositionRow{XactID: pgtype.Uint64{Valid: true}}
+	if f.AfterSeq > 0 {
+		err := r.read(ctx, "ListChanges", func(ctx context.Context, q conn) (err error) {
+			after, err = q.GetAuditPosition(ctx, userdb.GetAuditPositionParams{ID: f.AfterSeq, TenantID: tenantID})
+			return err
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrResyncRequired
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list changes: %w", err)
+		}
+		if after.CreatedAt.Before(f.NotBefore) {
+			return nil, ErrResyncRequired
+		}
+	}
+
+	var (
+		rows  []userdb.ListUserChangesRow
+		users []userdb.GetChangedUsersRow
+	)
+	err := r.read(ctx, "ListChanges", func(ctx context.Context, q conn) (err error) {
+		rows, err = q.ListUserChanges(ctx, userdb.ListUserChangesParams{
+			TenantID:    tenantID,
+			AfterXactID: after.XactID,
+			AfterID:     f.AfterSeq,
+			Since:       f.Since.UTC(),
+			MaxRows:     int32(min(f.Limit, maxChanges+1)),
+		})
+		if err != nil || len(rows) == 0 {
+			return err
+		}
+		ids := make([]int64, len(rows))
+		for i, row := range rows {
+			ids[i] = row.UserID
+		}
+		users, err = q.GetChangedUsers(ctx, userdb.GetChangedUsersParams{Ids: ids, TenantID: tenantID})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changes: %w", err)
+	}
+
+	byID := make(map[int64]userdb.GetChangedUsersRow, len(users))
+	for _, u := range users {
+		byID[u.ID] = u
+	}
+	changes := make([]Change, 0, len(rows))
+	for _, row := range rows {
+		found, ok := byID[row.UserID]
+		if !ok {
+			// Entries without a user snapshot leave the UUID empty
+			var uid uuid.UUID
+			if row.UUID != "" {
+				if uid, err = uuid.Parse(row.UUID); err != nil {
+					return nil, fmt.Errorf("failed to list changes: invalid UUID of user %d: %w", row.UserID, err)
+				}
+			}
+			changes = append(changes, removedChange(row.Seq, row.UserID, uid, row.ChangedAt))
+			continue
+		}
+		u := User{
+			ID:          found.ID,
+			Name:        found.Name,
+			Email:       found.Email,
+			CreatedAt:   found.CreatedAt,
+			UpdatedAt:   found.UpdatedAt,
+			IsAdmin:     found.IsAdmin,
+			UUID:        found.UUID,
+			Preferences: found.Preferences,
+			Status:      found.Status,
+		}
+		changes = append(changes, newChange(row.Seq, row.Created, &u, found.DeletedAt))
+	}
+
+	return changes, nil
+}
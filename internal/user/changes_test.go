@@ -0,0 +1,94 @@
+package user_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/clock"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/user"
+)
+
+func changes(t *testing.T, engine *gin.Engine, query string) user.ChangesResponse {
+	t.Helper()
+
+	rec := serveRequest(engine, http.MethodGet, "/users/changes"+query)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp user.ChangesResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestChanges(t *testing.T) {
+	engine, svc, ann := newExportEngine(t)
+	ctx := context.Background()
+
+	resp := changes(t, engine, "")
+	require.Len(t, resp.Changes, 1)
+	assert.Equal(t, user.ChangeCreated, resp.Changes[0].Type)
+	assert.EqualValues(t, ann.ID, resp.Changes[0].ID)
+	assert.Equal(t, ann.Email, resp.Changes[0].User.Email)
+	assert.False(t, resp.HasMore)
+	cursor := resp.Cursor
+
+	_, err := svc.Update(ctx, ann.ID, user.CreateUserRequest{Name: "Ann B", Email: ann.Email})
+	require.NoError(t, err)
+	bob, err := svc.Create(ctx, user.CreateUserRequest{Name: "Bob", Email: "bob@example.com"})
+	require.NoError(t, err)
+	require.NoError(t, svc.Delete(ctx, ann.ID))
+
+	// Ann's latest change is after Bob's creation
+	resp = changes(t, engine, "?limit=1&since="+cursor)
+	require.Len(t, resp.Changes, 1)
+	assert.EqualValues(t, bob.ID, resp.Changes[0].ID)
+	assert.Equal(t, user.ChangeCreated, resp.Changes[0].Type)
+	assert.True(t, resp.HasMore)
+
+	resp = changes(t, engine, "?since="+resp.Cursor)
+	require.Len(t, resp.Changes, 1)
+	tombstone := resp.Changes[0]
+	assert.Equal(t, user.ChangeDeleted, tombstone.Type)
+	assert.EqualValues(t, ann.ID, tombstone.ID)
+	assert.Nil(t, tombstone.User, "a tombstone carries no user data")
+	assert.NotNil(t, tombstone.DeletedAt)
+	assert.False(t, resp.HasMore)
+
+	// Nothing new: the cursor stays
+	last := resp.Cursor
+	resp = changes(t, engine, "?since="+last)
+	assert.Empty(t, resp.Changes)
+	assert.Equal(t, last, resp.Cursor)
+
+	resp = changes(t, engine, "?since=2000-01-01T00:00:00Z")
+	assert.Len(t, resp.Changes, 2)
+	resp = changes(t, engine, "?since=2999-01-01T00:00:00Z")
+	assert.Empty(t, resp.Changes)
+	assert.Empty(t, resp.Cursor)
+
+	t.Run("Errors", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serveRequest(engine, http.MethodGet, "/users/changes?since=yesterday").Code)
+		assert.Equal(t, http.StatusBadRequest, serveRequest(engine, http.MethodGet, "/users/changes?limit=0").Code)
+		assert.Equal(t, http.StatusGone, serveRequest(engine, http.MethodGet, "/users/changes?since=999999").Code, "an unknown cursor asks for a resync")
+	})
+}
+
+func TestChangesRetentionUsesClock(t *testing.T) {
+	now := clock.NewFake(time.Now())
+	svc := user.NewService(user.NewMemoryRepository(), nil, nil, now, nil, testutil.NopLogger{})
+	svc.SetPurgeRetention(time.Hour)
+	ctx := context.Background()
+
+	since := time.Now().Add(-time.Minute)
+	_, err := svc.Changes(ctx, user.ChangeFilter{Since: since, Limit: 10})
+	require.NoError(t, err)
+
+	now.Advance(2 * time.Hour)
+	_, err = svc.Changes(ctx, user.ChangeFilter{Since: since, Limit: 10})
+	assert.ErrorIs(t, err, user.ErrResyncRequired, "the retention window moves with the clock")
+}
@@ -0,0 +1,86 @@
+package user
+
+import (
+	"errors"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/things-kit/example-db/internal/config"
+	"github.com/things-kit/example-db/internal/database"
+)
+
+// IDType selects how users are identified in the API
+type IDType string
+
+const (
+	// IDSerial exposes the bigserial primary key
+	IDSerial IDType = "bigserial"
+	// IDUUID exposes the UUIDv7 key, so IDs are not guessable or sequential
+	IDUUID IDType = "uuid"
+)
+
+// Storage selects how users are persisted
+type Storage string
+
+const (
+	// StorageState stores the current state of users in the users table
+	StorageState Storage = "state"
+	// StorageEvents appends every change of a user to the user_events table
+	// and keeps the users table as a projection of it; see EventStore
+	StorageEvents Storage = "events"
+)
+
+// Config holds the user API configuration
+type Config struct {
+	IDType IDType `mapstructure:"id_type"`
+	// CountCacheTTL caches the totals of GET /users?count=true for that long,
+	// counted separately from the page. 0 counts with a window function on
+	// the page query instead, which is exact but scans every matching row.
+	CountCacheTTL time.Duration `mapstructure:"count_cache_ttl"`
+	// CursorSecret signs the pagination cursors of GET /users. Replicas must
+	// share it; if empty, a random secret is generated on startup.
+	CursorSecret string `mapstructure:"cursor_secret"`
+	// Storage is StorageState or StorageEvents. Reads are served by the users
+	// table either way, so the API behaves the same.
+	Storage Storage `mapstructure:"storage"`
+}
+
+// NewConfig loads the user configuration from the "users" key
+func NewConfig(v *viper.Viper) *Config {
+	cfg := &Config{
+		IDType:  IDSerial,
+		Storage: StorageState,
+	}
+
+	if v != nil {
+		_ = v.UnmarshalKey("users", cfg)
+	}
+
+	return cfg
+}
+
+// Validate checks the ID type, the count cache TTL and the storage
+func (c *Config) Validate() error {
+	return errors.Join(
+		config.OneOf("id_type", string(c.IDType), string(IDSerial), string(IDUUID)),
+		config.OneOf("storage", string(c.Storage), string(StorageState), string(StorageEvents)),
+		config.NonNegative("count_cache_ttl", c.CountCacheTTL),
+	)
+}
+
+// NewReloadListener applies the count cache TTL and the slow query threshold
+// of the "db" key when the configuration changes
+func NewReloadListener(r *Repository, h *Handler) config.Listener {
+	return config.Listener{
+		Name: "users",
+		Reload: func(v *viper.Viper) error {
+			cfg, db := NewConfig(v), database.NewConfig(v)
+			if err := errors.Join(cfg.Validate(), db.Validate()); err != nil {
+				return err
+			}
+			r.slow.setThreshold(db.SlowQueryThreshold)
+			h.setCountCacheTTL(cfg.CountCacheTTL)
+			return nil
+		},
+	}
+}
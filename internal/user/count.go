@@ -0,0 +1,69 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/things-kit/example-db/internal/tenant"
+)
+
+// countCache holds the number of users matching a filter for a while, so
+// paging through a large table doesn't count it on every page
+type countCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]countEntry
+}
+
+type countEntry struct {
+	n       int64
+	expires time.Time
+}
+
+// newCountCache returns a cache keeping counts for ttl, or nil if ttl is not
+// positive
+func newCountCache(ttl time.Duration) *countCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &countCache{ttl: ttl, now: time.Now, entries: map[string]countEntry{}}
+}
+
+// get returns the cached count of users matching f in the tenant of ctx,
+// calling count when there is none or it has expired
+func (c *countCache) get(ctx context.Context, f ListFilter, count func() (int64, error)) (int64, error) {
+	// The page and order don't change the count
+	f.Limit, f.Offset, f.After, f.SortBy, f.Ascending = 0, 0, nil, "", false
+	data, err := json.Marshal(f)
+	if err != nil {
+		return count()
+	}
+	key := tenant.FromContext(ctx) + "/" + string(data)
+
+	now := c.now()
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.n, nil
+	}
+
+	n, err := count()
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = countEntry{n: n, expires: now.Add(c.ttl)}
+	return n, nil
+}
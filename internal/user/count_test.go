@@ -0,0 +1,46 @@
+package user
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/tenant"
+)
+
+func TestCountCache(t *testing.T) {
+	assert.Nil(t, newCountCache(0))
+
+	c := newCountCache(time.Minute)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	calls := 0
+	count := func() (int64, error) {
+		calls++
+		return int64(calls * 10), nil
+	}
+	ctx := context.Background()
+
+	n, err := c.get(ctx, ListFilter{Limit: 10}, count)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), n)
+
+	n, err = c.get(ctx, ListFilter{Limit: 10, Offset: 10, SortBy: SortUpdatedAt}, count)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), n, "other pages share the count")
+
+	_, err = c.get(ctx, ListFilter{Status: StatusAll}, count)
+	require.NoError(t, err)
+	_, err = c.get(tenant.WithTenant(ctx, "acme"), ListFilter{}, count)
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls, "filters and tenants are counted separately")
+
+	now = now.Add(time.Minute)
+	n, err = c.get(ctx, ListFilter{}, count)
+	require.NoError(t, err)
+	assert.Equal(t, int64(40), n, "expired counts are refreshed")
+	assert.Len(t, c.entries, 1, "expired entries are dropped")
+}
@@ -0,0 +1,50 @@
+package user
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	codec := newCursorCodec("secret")
+	created := time.Date(2024, 5, 1, 12, 0, 0, 123000, time.UTC)
+	u := &User{ID: 42, CreatedAt: created, UpdatedAt: created.Add(time.Hour)}
+
+	f := ListFilter{SortBy: SortCreatedAt}
+	after, err := codec.decode(f, codec.encode(f, u))
+	require.NoError(t, err)
+	assert.Equal(t, &Keyset{Key: created, ID: 42}, after)
+
+	f = ListFilter{SortBy: SortUpdatedAt, Ascending: true}
+	after, err = codec.decode(f, codec.encode(f, u))
+	require.NoError(t, err)
+	assert.Equal(t, &Keyset{Key: created.Add(time.Hour), ID: 42}, after)
+}
+
+func TestCursorRejectsForgedCursors(t *testing.T) {
+	codec := newCursorCodec("secret")
+	f := ListFilter{SortBy: SortCreatedAt}
+	cursor := codec.encode(f, &User{ID: 42})
+
+	payload, sig, _ := strings.Cut(cursor, ".")
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"v":1,"s":"created_at","k":"0001-01-01T00:00:00Z","i":1}`))
+
+	for name, c := range map[string]string{
+		"tampered payload":  forged + "." + sig,
+		"missing signature": payload,
+		"other secret":      newCursorCodec("other").encode(f, &User{ID: 42}),
+		"random secret":     newCursorCodec("").encode(f, &User{ID: 42}),
+		"garbage":           "not-a-cursor",
+	} {
+		_, err := codec.decode(f, c)
+		assert.ErrorIs(t, err, errInvalidCursor, name)
+	}
+
+	_, err := codec.decode(ListFilter{SortBy: SortCreatedAt, Ascending: true}, cursor)
+	assert.ErrorIs(t, err, errInvalidCursor, "cursors only resume their own order")
+}
@@ -0,0 +1,203 @@
+package user
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/things-kit/example-db/internal/prefs"
+)
+
+// CreateUserRequest represents the request to create or update a user.
+// The Service validates and normalizes it before it reaches the repository.
+type CreateUserRequest struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// ProfileRequest represents the request to create or replace a user profile
+type ProfileRequest struct {
+	Phone     string `json:"phone"`
+	Address   string `json:"address"`
+	Bio       string `json:"bio"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+// MergeRequest represents the request to merge a duplicate user into the
+// surviving one
+type MergeRequest struct {
+	SurvivorID  RequestID `json:"survivor_id"`
+	DuplicateID RequestID `json:"duplicate_id"`
+}
+
+// RequestID is a user ID in a request body: a number, or a UUID string when
+// users are identified by UUID
+type RequestID string
+
+// UnmarshalJSON accepts both JSON numbers and strings
+func (id *RequestID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*id = RequestID(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("user ID must be a number or a string: %w", err)
+	}
+	*id = RequestID(n)
+	return nil
+}
+
+// UserResponse is the API representation of a user. Only the fields listed
+// here are exposed; new columns on User stay internal until mapped.
+// ID holds an int64, or a uuid.UUID when users are identified by UUID.
+type UserResponse struct {
+	ID        any       `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Status    string    `json:"status"`
+
+	Preferences prefs.Preferences `json:"preferences"`
+	// Profile is only set with ?expand=profile
+	Profile *ProfileResponse `json:"profile,omitempty"`
+}
+
+// ProfileResponse is the API representation of a profile. UpdatedAt is
+// missing for users without a profile.
+type ProfileResponse struct {
+	Phone     string     `json:"phone"`
+	Address   string     `json:"address"`
+	Bio       string     `json:"bio"`
+	AvatarURL string     `json:"avatar_url"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// NewProfileResponse maps a Profile to its API representation; a nil profile
+// maps to an empty one
+func NewProfileResponse(p *Profile) *ProfileResponse {
+	if p == nil {
+		return &ProfileResponse{}
+	}
+
+	updated := p.UpdatedAt
+	return &ProfileResponse{
+		Phone:     p.Phone,
+		Address:   p.Address,
+		Bio:       p.Bio,
+		AvatarURL: p.AvatarURL,
+		UpdatedAt: &updated,
+	}
+}
+
+// NewUserResponse maps a User to its API representation
+func NewUserResponse(u *User, ids IDType) UserResponse {
+	var id any = u.ID
+	if ids == IDUUID {
+		id = u.UUID
+	}
+
+	resp := UserResponse{
+		ID:        id,
+		Name:      u.Name,
+		Email:     u.Email,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+		Status:    u.Status,
+
+		Preferences: u.Preferences,
+	}
+	if resp.Preferences == nil {
+		resp.Preferences = prefs.Preferences{}
+	}
+	return resp
+}
+
+// NewUserResponses maps users to their API representation, never returning nil
+// so an empty list encodes as []
+func NewUserResponses(users []*User, ids IDType) []UserResponse {
+	resp := make([]UserResponse, 0, len(users))
+	for _, u := range users {
+		resp = append(resp, NewUserResponse(u, ids))
+	}
+	return resp
+}
+
+// ChangeResponse is the API representation of a Change. A deleted user is a
+// tombstone holding only its ID and deletion time.
+type ChangeResponse struct {
+	Type      string        `json:"type"`
+	ID        any           `json:"id"`
+	User      *UserResponse `json:"user,omitempty"`
+	DeletedAt *time.Time    `json:"deleted_at,omitempty"`
+}
+
+// NewChangeResponse maps a Change to its API representation
+func NewChangeResponse(c Change, ids IDType) ChangeResponse {
+	user := NewUserResponse(c.User, ids)
+	resp := ChangeResponse{Type: c.Type, ID: user.ID}
+	if c.Type == ChangeDeleted {
+		resp.DeletedAt = c.DeletedAt
+	} else {
+		resp.User = &user
+	}
+	return resp
+}
+
+// ChangesResponse is a page of the changes feed
+type ChangesResponse struct {
+	Changes []ChangeResponse `json:"changes"`
+	// Cursor is the since of the next request. It is empty when there were
+	// no changes after a timestamp, which is then passed again.
+	Cursor  string `json:"cursor,omitempty"`
+	HasMore bool   `json:"has_more"`
+}
+
+// ExportResponse is the API representation of an Export
+type ExportResponse struct {
+	User    UserResponse           `json:"user"`
+	Profile *ProfileResponse       `json:"profile"`
+	Audit   []AuditEntry           `json:"audit"`
+	Objects []ExportObjectResponse `json:"objects"`
+}
+
+// ExportObjectResponse describes a stored object of a user. The JSON export
+// carries its content in Data; the ZIP export stores it in the file at Path.
+type ExportObjectResponse struct {
+	Key         string `json:"key"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+	Data        []byte `json:"data,omitempty"`
+	Path        string `json:"path,omitempty"`
+}
+
+// NewExportResponse maps an Export to its API representation. With archive
+// set, objects point at their file in the ZIP archive instead of carrying
+// their content.
+func NewExportResponse(e *Export, ids IDType, archive bool) ExportResponse {
+	resp := ExportResponse{
+		User:    NewUserResponse(e.User, ids),
+		Profile: NewProfileResponse(e.Profile),
+		Audit:   e.Audit,
+		Objects: make([]ExportObjectResponse, len(e.Objects)),
+	}
+	if resp.Audit == nil {
+		resp.Audit = []AuditEntry{}
+	}
+	for i, obj := range e.Objects {
+		resp.Objects[i] = ExportObjectResponse{Key: obj.Key, ContentType: obj.ContentType, Size: len(obj.Data)}
+		if archive {
+			resp.Objects[i].Path = exportObjectPath(obj.Key)
+		} else {
+			resp.Objects[i].Data = obj.Data
+		}
+	}
+	return resp
+}
+
+// exportObjectPath is the file of a stored object in a ZIP export
+func exportObjectPath(key string) string {
+	return "objects/" + key
+}
@@ -0,0 +1,46 @@
+package user
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewUserResponse(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	u := &User{ID: 1, Name: "John", Email: "john@example.com", CreatedAt: now, UpdatedAt: now, IsAdmin: true, Status: StatusActive}
+
+	data, err := json.Marshal(NewUserResponse(u, IDSerial))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":1,"name":"John","email":"john@example.com",
+		"created_at":"2024-01-01T12:00:00Z","updated_at":"2024-01-01T12:00:00Z","status":"active","preferences":{}}`, string(data),
+		"internal columns such as is_admin are not exposed")
+}
+
+func TestNewUserResponses(t *testing.T) {
+	assert.Equal(t, []UserResponse{}, NewUserResponses(nil, IDSerial))
+
+	resp := NewUserResponses([]*User{{ID: 1}, {ID: 2}}, IDSerial)
+	require.Len(t, resp, 2)
+	assert.Equal(t, int64(2), resp[1].ID)
+}
+
+func TestNewUserResponseWithUUID(t *testing.T) {
+	key := uuid.MustParse("01890a5d-ac96-774b-bcce-b302099a8057")
+
+	resp := NewUserResponse(&User{ID: 1, UUID: key}, IDUUID)
+	assert.Equal(t, key, resp.ID, "the bigserial ID stays internal")
+}
+
+func TestNewProfileResponse(t *testing.T) {
+	assert.Equal(t, &ProfileResponse{}, NewProfileResponse(nil))
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	resp := NewProfileResponse(&Profile{UserID: 1, Bio: "Hi", UpdatedAt: now})
+	assert.Equal(t, "Hi", resp.Bio)
+	assert.Equal(t, &now, resp.UpdatedAt)
+}
@@ -0,0 +1,494 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/things-kit/example-db/internal/prefs"
+	"github.com/things-kit/example-db/internal/tenant"
+)
+
+// Types of the events in the user_events table
+const (
+	EventUserCreated        = "UserCreated"
+	EventNameChanged        = "NameChanged"
+	EventEmailChanged       = "EmailChanged"
+	EventPreferencesChanged = "PreferencesChanged"
+	EventStatusChanged      = "StatusChanged"
+	EventAdminChanged       = "AdminChanged"
+	EventAvatarChanged      = "AvatarChanged"
+	EventUserDeleted        = "UserDeleted"
+)
+
+// StoredEvent is a change of a user in the user_events table. The events of
+// a user are numbered from 1 by Version.
+type StoredEvent struct {
+	UserID     int64
+	Version    int
+	Type       string
+	Data       json.RawMessage
+	TenantID   string
+	OccurredAt time.Time
+}
+
+// eventData is the payload of every event type. UserCreated sets every field,
+// other events only the one they change.
+type eventData struct {
+	UUID        uuid.UUID         `json:"uuid,omitzero"`
+	Name        string            `json:"name,omitempty"`
+	Email       string            `json:"email,omitempty"`
+	CreatedAt   time.Time         `json:"created_at,omitzero"`
+	IsAdmin     bool              `json:"is_admin,omitempty"`
+	Preferences prefs.Preferences `json:"preferences,omitempty"`
+	Status      string            `json:"status,omitempty"`
+	AvatarKey   string            `json:"avatar_key,omitempty"`
+}
+
+// aggregate is a user folded from its events, with the columns of the users
+// table the User model leaves out
+type aggregate struct {
+	User
+	TenantID  string
+	AvatarKey string
+	DeletedAt *time.Time
+	Version   int
+
+	// pending are the events recorded since the aggregate was loaded
+	pending []StoredEvent
+}
+
+// apply folds an event into the aggregate
+func (a *aggregate) apply(e StoredEvent) error {
+	var d eventData
+	if err := json.Unmarshal(e.Data, &d); err != nil {
+		return fmt.Errorf("failed to decode %s event %d of user %d: %w", e.Type, e.Version, e.UserID, err)
+	}
+
+	switch e.Type {
+	case EventUserCreated:
+		a.User = User{
+			ID:          e.UserID,
+			Name:        d.Name,
+			Email:       d.Email,
+			CreatedAt:   e.OccurredAt,
+			IsAdmin:     d.IsAdmin,
+			UUID:        d.UUID,
+			Preferences: d.Preferences,
+			Status:      d.Status,
+		}
+		if !d.CreatedAt.IsZero() {
+			a.CreatedAt = d.CreatedAt
+		}
+		if a.Preferences == nil {
+			a.Preferences = prefs.Preferences{}
+		}
+		if a.Status == "" {
+			a.Status = StatusActive
+		}
+		a.TenantID = e.TenantID
+		a.AvatarKey = d.AvatarKey
+	case EventNameChanged:
+		a.Name = d.Name
+	case EventEmailChanged:
+		a.Email = d.Email
+	case EventPreferencesChanged:
+		a.Preferences = d.Preferences
+		if a.Preferences == nil {
+			a.Preferences = prefs.Preferences{}
+		}
+	case EventStatusChanged:
+		a.Status = d.Status
+	case EventAdminChanged:
+		a.IsAdmin = d.IsAdmin
+	case EventAvatarChanged:
+		a.AvatarKey = d.AvatarKey
+	case EventUserDeleted:
+		deletedAt := e.OccurredAt
+		a.DeletedAt = &deletedAt
+	default:
+		return fmt.Errorf("unknown event type %q of user %d", e.Type, e.UserID)
+	}
+
+	a.UpdatedAt = e.OccurredAt
+	a.Version = e.Version
+	return nil
+}
+
+// record applies a new event to the aggregate and queues it to be appended
+func (a *aggregate) record(eventType string, data eventData, at time.Time) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+
+	e := StoredEvent{
+		UserID:     a.ID,
+		Version:    a.Version + 1,
+		Type:       eventType,
+		Data:       raw,
+		TenantID:   a.TenantID,
+		OccurredAt: at,
+	}
+	if err := a.apply(e); err != nil {
+		return err
+	}
+	a.pending = append(a.pending, e)
+	return nil
+}
+
+// EventStore is the event-sourced UserRepository selected with users.storage
+// "events". Every change of a user is appended to the user_events table, the
+// source of truth, and the users table is a projection of those events
+// written in the same transaction. Reads are served from the projection by
+// the embedded Repository, so the API behaves as with the state storage;
+// Replay rebuilds the projection from the events. Profiles are not event
+// sourced, and deleted users stay in the projection until they are purged
+// whatever the soft_delete flag.
+type EventStore struct {
+	*Repository
+}
+
+// NewEventStore creates an event store keeping its projection with r
+func NewEventStore(r *Repository) *EventStore {
+	return &EventStore{Repository: r}
+}
+
+// WithTx runs fn in a transaction like Repository.WithTx, handing it an event
+// store bound to the transaction
+func (s *EventStore) WithTx(ctx context.Context, fn func(repo UserRepository) error) error {
+	return s.Repository.WithTx(ctx, func(repo UserRepository) error {
+		return fn(&EventStore{Repository: repo.(*Repository)})
+	})
+}
+
+// Create records a UserCreated event for a new user
+func (s *EventStore) Create(ctx context.Context, req CreateUserRequest) (*User, error) {
+	ctx, span := tracer.Start(ctx, "user.EventStore.Create")
+	defer span.End()
+
+	var user *User
+	err := s.WithTx(ctx, func(repo UserRepository) (err error) {
+		user, err = repo.(*EventStore).create(ctx, req)
+		return err
+	})
+	return user, err
+}
+
+// CopyFrom creates the users one by one, as each needs its own UserCreated
+// event. Like Repository.CopyFrom it is all or nothing.
+func (s *EventStore) CopyFrom(ctx context.Context, reqs []CreateUserRequest) (int64, error) {
+	ctx, span := tracer.Start(ctx, "user.EventStore.CopyFrom")
+	defer span.End()
+
+	err := s.WithTx(ctx, func(repo UserRepository) error {
+		for _, req := range reqs {
+			if _, err := repo.(*EventStore).create(ctx, req); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(reqs)), nil
+}
+
+// create takes the next user ID and records the UserCreated event. It must
+// run inside WithTx.
+func (s *EventStore) create(ctx context.Context, req CreateUserRequest) (*User, error) {
+	key, err := s.ids.UUID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate user uuid: %w", err)
+	}
+
+	var id int64
+	err = s.withTimeout(ctx, func(ctx context.Context) error {
+		return s.tx.QueryRow(ctx, `SELECT nextval(pg_get_serial_sequence('users', 'id'))`).Scan(&id)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	a := &aggregate{User: User{ID: id}, TenantID: tenant.FromContext(ctx)}
+	if err := a.record(EventUserCreated, eventData{UUID: key, Name: req.Name, Email: req.Email}, s.clock.Now()); err != nil {
+		return nil, err
+	}
+	return s.commit(ctx, a)
+}
+
+// Update records NameChanged and EmailChanged for the fields that differ
+func (s *EventStore) Update(ctx context.Context, id int64, req CreateUserRequest) (*User, error) {
+	ctx, span := tracer.Start(ctx, "user.EventStore.Update")
+	defer span.End()
+
+	return s.change(ctx, id, func(a *aggregate, now time.Time) error {
+		if req.Name != a.Name {
+			if err := a.record(EventNameChanged, eventData{Name: req.Name}, now); err != nil {
+				return err
+			}
+		}
+		if req.Email != a.Email {
+			return a.record(EventEmailChanged, eventData{Email: req.Email}, now)
+		}
+		return nil
+	})
+}
+
+// Delete records UserDeleted, which soft-deletes the user in the projection
+// whatever the soft_delete flag, so the avatar is kept until the purge
+func (s *EventStore) Delete(ctx context.Context, id int64) (string, error) {
+	ctx, span := tracer.Start(ctx, "user.EventStore.Delete")
+	defer span.End()
+
+	_, err := s.change(ctx, id, func(a *aggregate, now time.Time) error {
+		return a.record(EventUserDeleted, eventData{}, now)
+	})
+	return "", err
+}
+
+// SetAdmin records AdminChanged unless the user already has that right
+func (s *EventStore) SetAdmin(ctx context.Context, id int64, admin bool) error {
+	ctx, span := tracer.Start(ctx, "user.EventStore.SetAdmin")
+	defer span.End()
+
+	_, err := s.change(ctx, id, func(a *aggregate, now time.Time) error {
+		if a.IsAdmin == admin {
+			return nil
+		}
+		return a.record(EventAdminChanged, eventData{IsAdmin: admin}, now)
+	})
+	return err
+}
+
+// SetPreferences records PreferencesChanged with the new preferences
+func (s *EventStore) SetPreferences(ctx context.Context, id int64, p prefs.Preferences) (*User, error) {
+	ctx, span := tracer.Start(ctx, "user.EventStore.SetPreferences")
+	defer span.End()
+
+	return s.change(ctx, id, func(a *aggregate, now time.Time) error {
+		return a.record(EventPreferencesChanged, eventData{Preferences: p}, now)
+	})
+}
+
+// SetStatus records StatusChanged
+func (s *EventStore) SetStatus(ctx context.Context, id int64, status string) (*User, error) {
+	ctx, span := tracer.Start(ctx, "user.EventStore.SetStatus")
+	defer span.End()
+
+	return s.change(ctx, id, func(a *aggregate, now time.Time) error {
+		return a.record(EventStatusChanged, eventData{Status: status}, now)
+	})
+}
+
+// SetAvatar records AvatarChanged
+func (s *EventStore) SetAvatar(ctx context.Context, id int64, key string) error {
+	ctx, span := tracer.Start(ctx, "user.EventStore.SetAvatar")
+	defer span.End()
+
+	_, err := s.change(ctx, id, func(a *aggregate, now time.Time) error {
+		return a.record(EventAvatarChanged, eventData{AvatarKey: key}, now)
+	})
+	return err
+}
+
+// change loads a user in a transaction, lets decide record events on it and
+// commits them
+func (s *EventStore) change(ctx context.Context, id int64, decide func(a *aggregate, now time.Time) error) (*User, error) {
+	var user *User
+	err := s.WithTx(ctx, func(repo UserRepository) error {
+		es := repo.(*EventStore)
+		a, err := es.load(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := decide(a, es.clock.Now()); err != nil {
+			return err
+		}
+		user, err = es.commit(ctx, a)
+		return err
+	})
+	return user, err
+}
+
+// load locks the projection row of a user, which serializes changes of the
+// user, and folds its events. A user created before the event store was
+// enabled has no events yet; its current row is recorded as its UserCreated
+// event. load must run inside WithTx.
+func (s *EventStore) load(ctx context.Context, id int64) (*aggregate, error) {
+	current, err := s.Repository.GetForUpdate(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := s.fold(ctx, id)
+	if err != nil || a.Version > 0 {
+		return a, err
+	}
+
+	avatarKey, err := s.Repository.GetAvatarKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	a = &aggregate{User: User{ID: id}, TenantID: tenant.FromContext(ctx)}
+	err = a.record(EventUserCreated, eventData{
+		UUID:        current.UUID,
+		Name:        current.Name,
+		Email:       current.Email,
+		CreatedAt:   current.CreatedAt,
+		IsAdmin:     current.IsAdmin,
+		Preferences: current.Preferences,
+		Status:      current.Status,
+		AvatarKey:   avatarKey,
+	}, current.UpdatedAt)
+	return a, err
+}
+
+// fold reads the events of a user and folds them into an aggregate, whose
+// Version is 0 when there are none. It must run inside WithTx.
+func (s *EventStore) fold(ctx context.Context, id int64) (*aggregate, error) {
+	query := `
+		SELECT user_id, version, type, data, tenant_id, occurred_at
+		FROM user_events
+		WHERE user_id = $1
+		ORDER BY version
+	`
+
+	var evts []StoredEvent
+	err := s.withTimeout(ctx, func(ctx context.Context) error {
+		rows, err := s.tx.Query(ctx, query, id)
+		if err != nil {
+			return err
+		}
+		evts, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (StoredEvent, error) {
+			var e StoredEvent
+			err := row.Scan(&e.UserID, &e.Version, &e.Type, &e.Data, &e.TenantID, &e.OccurredAt)
+			return e, err
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user events: %w", err)
+	}
+
+	a := &aggregate{}
+	for _, e := range evts {
+		if err := a.apply(e); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// commit appends the pending events of an aggregate and writes its state to
+// the users table. It must run inside WithTx.
+func (s *EventStore) commit(ctx context.Context, a *aggregate) (*User, error) {
+	if len(a.pending) == 0 {
+		user := a.User
+		return &user, nil
+	}
+
+	query := `
+		INSERT INTO user_events (user_id, version, type, data, tenant_id, occurred_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	err := s.withTimeout(ctx, func(ctx context.Context) error {
+		for _, e := range a.pending {
+			if _, err := s.tx.Exec(ctx, query, e.UserID, e.Version, e.Type, e.Data, e.TenantID, e.OccurredAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to append user events: %w", err)
+	}
+	a.pending = nil
+
+	return s.project(ctx, a)
+}
+
+// projectQuery writes the state of an aggregate to its row of the users
+// table, creating the row if it doesn't exist
+var projectQuery = `
+	INSERT INTO users (id, uuid, tenant_id, name, email, created_at, updated_at, deleted_at, avatar_key, is_admin, preferences, status)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, $11, $12)
+	ON CONFLICT (id) DO UPDATE
+	SET name = EXCLUDED.name, email = EXCLUDED.email, updated_at = EXCLUDED.updated_at,
+		deleted_at = EXCLUDED.deleted_at, avatar_key = EXCLUDED.avatar_key, is_admin = EXCLUDED.is_admin,
+		preferences = EXCLUDED.preferences, status = EXCLUDED.status
+	RETURNING ` + strings.Join(usersTable.Columns, ", ")
+
+// project writes the state of an aggregate to the users table. It must run
+// inside WithTx.
+func (s *EventStore) project(ctx context.Context, a *aggregate) (*User, error) {
+	var user User
+	err := s.withTimeout(ctx, func(ctx context.Context) error {
+		row := s.tx.QueryRow(ctx, projectQuery,
+			a.ID, a.UUID, a.TenantID, a.Name, a.Email, a.CreatedAt, a.UpdatedAt, a.DeletedAt,
+			a.AvatarKey, a.IsAdmin, a.Preferences, a.Status)
+		return usersTable.Scan(row, &user)
+	})
+	if isUniqueViolation(err) {
+		return nil, ErrEmailTaken
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to project user: %w", err)
+	}
+
+	return &user, nil
+}
+
+// Replay rebuilds the users table from the event log: the events of every
+// user are folded and written over its row, recreating rows that are
+// missing. Users without events are left alone, and so are deleted users,
+// which would otherwise come back after being purged. Each user is replayed
+// in its own transaction; Replay returns the number of users written.
+func (s *EventStore) Replay(ctx context.Context) (int64, error) {
+	ctx, span := tracer.Start(ctx, "user.EventStore.Replay")
+	defer span.End()
+
+	var ids []int64
+	err := s.withTimeout(ctx, func(ctx context.Context) error {
+		rows, err := s.pool.Query(ctx, `SELECT DISTINCT user_id FROM user_events ORDER BY user_id`)
+		if err != nil {
+			return err
+		}
+		ids, err = pgx.CollectRows(rows, pgx.RowTo[int64])
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list event-sourced users: %w", err)
+	}
+
+	var n int64
+	for _, id := range ids {
+		var replayed bool
+		err := s.WithTx(ctx, func(repo UserRepository) error {
+			es := repo.(*EventStore)
+			a, err := es.fold(ctx, id)
+			if err != nil || a.DeletedAt != nil {
+				return err
+			}
+			_, err = es.project(ctx, a)
+			replayed = err == nil
+			return err
+		})
+		if err != nil {
+			return n, fmt.Errorf("failed to replay user %d: %w", id, err)
+		}
+		if replayed {
+			n++
+		}
+	}
+
+	return n, nil
+}
@@ -0,0 +1,56 @@
+package user
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/prefs"
+)
+
+func TestAggregateFoldsEvents(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	key := uuid.New()
+
+	a := &aggregate{User: User{ID: 7}, TenantID: "acme"}
+	require.NoError(t, a.record(EventUserCreated, eventData{UUID: key, Name: "Ann", Email: "ann@example.com"}, created))
+	require.NoError(t, a.record(EventEmailChanged, eventData{Email: "anne@example.com"}, created.Add(time.Hour)))
+	require.NoError(t, a.record(EventPreferencesChanged, eventData{Preferences: prefs.Preferences{"theme": "dark"}}, created.Add(2*time.Hour)))
+	require.NoError(t, a.record(EventAdminChanged, eventData{IsAdmin: true}, created.Add(3*time.Hour)))
+	require.NoError(t, a.record(EventAdminChanged, eventData{IsAdmin: false}, created.Add(4*time.Hour)))
+	require.Len(t, a.pending, 5)
+
+	// Folding the recorded events again yields the same state
+	replayed := &aggregate{}
+	for _, e := range a.pending {
+		require.NoError(t, replayed.apply(e))
+	}
+	replayed.pending = a.pending
+	assert.Equal(t, a, replayed)
+
+	assert.Equal(t, User{
+		ID:          7,
+		Name:        "Ann",
+		Email:       "anne@example.com",
+		CreatedAt:   created,
+		UpdatedAt:   created.Add(4 * time.Hour),
+		UUID:        key,
+		Preferences: prefs.Preferences{"theme": "dark"},
+		Status:      StatusActive,
+	}, a.User)
+	assert.Equal(t, "acme", a.TenantID)
+	assert.Equal(t, 5, a.Version)
+	assert.Nil(t, a.DeletedAt)
+
+	require.NoError(t, a.record(EventUserDeleted, eventData{}, created.Add(5*time.Hour)))
+	require.NotNil(t, a.DeletedAt)
+	assert.Equal(t, created.Add(5*time.Hour), *a.DeletedAt)
+}
+
+func TestAggregateRejectsUnknownEvents(t *testing.T) {
+	a := &aggregate{}
+	err := a.apply(StoredEvent{UserID: 1, Version: 1, Type: "UserRenamed", Data: []byte(`{}`)})
+	assert.ErrorContains(t, err, `unknown event type "UserRenamed"`)
+}
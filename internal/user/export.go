@@ -0,0 +1,207 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/things-kit/example-db/internal/events"
+	"github.com/things-kit/example-db/internal/reqlog"
+	"github.com/things-kit/example-db/internal/tenant"
+	"github.com/things-kit/example-db/internal/user/userdb"
+	"github.com/things-kit/module/log"
+)
+
+// maxExportAudit bounds the audit entries included in an export
+const maxExportAudit = 10000
+
+// ErasedName is the name an erased user is left with
+const ErasedName = "Erased user"
+
+// erasedEmail returns the placeholder email of an erased user. It is unique
+// per user and uses a reserved domain that can't receive mail.
+func erasedEmail(id int64) string {
+	return fmt.Sprintf("erased-%d@erased.invalid", id)
+}
+
+// Export is everything stored about a user, for a data takeout request
+type Export struct {
+	User    *User
+	Profile *Profile
+	// Audit lists the recorded changes of the user, newest first
+	Audit []AuditEntry
+	// Objects holds the files the user stored, such as their avatar
+	Objects []ExportObject
+}
+
+// ExportObject is a stored file of a user
+type ExportObject struct {
+	Key         string
+	ContentType string
+	Data        []byte
+}
+
+// Export collects the data stored about a user: the user row, the profile,
+// the audit history and the stored objects
+func (s *Service) Export(ctx context.Context, id int64) (*Export, error) {
+	user, profile, err := s.repo.GetWithProfile(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := s.repo.ListAudit(ctx, id, maxExportAudit)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := s.repo.GetAvatarKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &Export{User: user, Profile: profile, Audit: entries, Objects: []ExportObject{}}
+	if key != "" {
+		obj, err := s.readObject(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		export.Objects = append(export.Objects, obj)
+	}
+
+	return export, nil
+}
+
+// readObject downloads a stored object of at most MaxAvatarSize bytes
+func (s *Service) readObject(ctx context.Context, key string) (ExportObject, error) {
+	body, err := s.store.Get(ctx, key)
+	if err != nil {
+		return ExportObject{}, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, MaxAvatarSize+1))
+	if err != nil {
+		return ExportObject{}, fmt.Errorf("failed to read object %s: %w", key, err)
+	}
+	if len(data) > MaxAvatarSize {
+		return ExportObject{}, fmt.Errorf("object %s is larger than %d bytes", key, MaxAvatarSize)
+	}
+
+	return ExportObject{Key: key, ContentType: http.DetectContentType(data), Data: data}, nil
+}
+
+// Erase removes the personal data of a user, live or soft-deleted, for a
+// right to erasure request. The user row is kept, deleted and anonymized, so
+// references to the ID stay valid; the profile is deleted and the snapshots
+// in the audit log, the event log and the outbox are scrubbed. Erase records
+// an audit entry and a UserErased event without personal data, and deletes
+// the avatar from object storage once the transaction committed.
+func (s *Service) Erase(ctx context.Context, id int64) error {
+	var key string
+	err := s.repo.WithTx(ctx, func(repo UserRepository) error {
+		var err error
+		if key, err = repo.Erase(ctx, id); err != nil {
+			return err
+		}
+		if err := repo.AddAudit(ctx, id, AuditErase, nil, nil); err != nil {
+			return err
+		}
+		return s.recordEvent(ctx, repo.Tx(), events.UserErased, id, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	if key != "" {
+		if err := s.store.Delete(ctx, key); err != nil {
+			reqlog.FromContext(ctx, s.log).Error("Failed to delete avatar of erased user", err, log.Field{Key: "key", Value: key})
+		}
+	}
+
+	return nil
+}
+
+// Erase anonymizes a user, live or soft-deleted, and scrubs their personal
+// data from the other tables: the profile and read model entry are deleted,
+// and the audit snapshots, event log and outbox payloads are cleared. It
+// returns the key of the avatar the user had, or "", for the caller to delete
+// once the transaction committed.
+func (r *Repository) Erase(ctx context.Context, id int64) (string, error) {
+	ctx, span := tracer.Start(ctx, "user.Repository.Erase")
+	defer span.End()
+
+	var key string
+	err := r.WithTx(ctx, func(repo UserRepository) (err error) {
+		key, err = repo.(*Repository).erase(ctx, id)
+		return err
+	})
+	return key, err
+}
+
+// erase runs the statements of Erase. It must run inside WithTx.
+func (r *Repository) erase(ctx context.Context, id int64) (string, error) {
+	tenantID := tenant.FromContext(ctx)
+	name, email := ErasedName, erasedEmail(id)
+
+	var key string
+	err := r.write(ctx, "Erase", func(ctx context.Context, q conn) (err error) {
+		key, err = q.EraseUser(ctx, userdb.EraseUserParams{
+			Name:     name,
+			Email:    email,
+			ID:       id,
+			ErasedAt: r.clock.Now(),
+			TenantID: tenantID,
+		})
+		if err != nil {
+			return err
+		}
+		if err := q.DeleteProfile(ctx, userdb.DeleteProfileParams{UserID: id, TenantID: tenantID}); err != nil {
+			return err
+		}
+		if err := q.EraseAuditEntries(ctx, userdb.EraseAuditEntriesParams{UserID: id, TenantID: tenantID}); err != nil {
+			return err
+		}
+		err = q.EraseUserEvents(ctx, userdb.EraseUserEventsParams{Name: name, Email: email, UserID: id, TenantID: tenantID})
+		if err != nil {
+			return err
+		}
+		if err := q.EraseOutboxEvents(ctx, id); err != nil {
+			return err
+		}
+		return q.DeleteUserDirectoryEntry(ctx, id)
+	})
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrNotFound
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to erase user: %w", err)
+	}
+
+	return key, nil
+}
+
+// Erase records UserDeleted for a live user, so replaying the event log
+// doesn't bring them back, and then erases them like Repository.Erase, which
+// also scrubs the personal data from their events
+func (s *EventStore) Erase(ctx context.Context, id int64) (string, error) {
+	ctx, span := tracer.Start(ctx, "user.EventStore.Erase")
+	defer span.End()
+
+	var key string
+	err := s.WithTx(ctx, func(repo UserRepository) error {
+		es := repo.(*EventStore)
+		if _, err := es.Delete(ctx, id); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+
+		var err error
+		key, err = es.Repository.Erase(ctx, id)
+		return err
+	})
+	return key, err
+}
@@ -0,0 +1,114 @@
+package user_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/mail"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/user"
+)
+
+// newExportEngine serves the user API on an in-memory repository with a user
+// that has a profile and an audit history
+func newExportEngine(t *testing.T) (*gin.Engine, *user.Service, *user.User) {
+	t.Helper()
+
+	queue := mail.NewQueue(nil, &mail.Config{QueueSize: 1}, testutil.NopLogger{})
+	svc := user.NewService(user.NewMemoryRepository(), nil, queue, nil, nil, testutil.NopLogger{})
+	ctx := context.Background()
+
+	u, err := svc.Create(ctx, user.CreateUserRequest{Name: "Ann", Email: "ann@example.com"})
+	require.NoError(t, err)
+	_, err = svc.UpdateProfile(ctx, u.ID, user.ProfileRequest{Phone: "+1 555 0100", Bio: "Hi"})
+	require.NoError(t, err)
+
+	engine := gin.New()
+	user.NewHandler(svc, &user.Config{IDType: user.IDSerial}, nil, nil, testutil.NopLogger{}).RegisterRoutes(engine)
+	return engine, svc, u
+}
+
+func serveRequest(engine *gin.Engine, method, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	return rec
+}
+
+func TestExport(t *testing.T) {
+	engine, _, u := newExportEngine(t)
+
+	rec := serveRequest(engine, http.MethodGet, "/users/1/export")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, `attachment; filename="user-1-export.json"`, rec.Header().Get("Content-Disposition"))
+
+	var export user.ExportResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &export))
+	assert.Equal(t, u.Email, export.User.Email)
+	assert.Equal(t, "+1 555 0100", export.Profile.Phone)
+	require.Len(t, export.Audit, 2)
+	assert.Equal(t, user.AuditUpdateProfile, export.Audit[0].Action)
+	assert.Equal(t, user.AuditCreate, export.Audit[1].Action)
+	assert.Empty(t, export.Objects)
+
+	t.Run("Zip", func(t *testing.T) {
+		rec := serveRequest(engine, http.MethodGet, "/users/1/export?format=zip")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "application/zip", rec.Header().Get("Content-Type"))
+
+		archive, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+		require.NoError(t, err)
+		require.Len(t, archive.File, 1)
+		assert.Equal(t, "export.json", archive.File[0].Name)
+
+		f, err := archive.File[0].Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(f)
+		require.NoError(t, err)
+
+		// The archive is indented, so compare it as JSON
+		want, err := json.Marshal(export)
+		require.NoError(t, err)
+		assert.JSONEq(t, string(want), string(data))
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serveRequest(engine, http.MethodGet, "/users/1/export?format=xml").Code)
+		assert.Equal(t, http.StatusNotFound, serveRequest(engine, http.MethodGet, "/users/99/export").Code)
+	})
+}
+
+func TestErase(t *testing.T) {
+	engine, svc, u := newExportEngine(t)
+	ctx := context.Background()
+
+	rec := serveRequest(engine, http.MethodDelete, "/users/1/erase")
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+	_, err := svc.GetByID(ctx, u.ID)
+	assert.ErrorIs(t, err, user.ErrNotFound)
+	assert.Equal(t, http.StatusNotFound, serveRequest(engine, http.MethodGet, "/users/1/export").Code)
+
+	entries, err := svc.Audit(ctx, u.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, user.AuditErase, entries[0].Action)
+	for _, e := range entries {
+		assert.Empty(t, e.Old, e.Action)
+		assert.Empty(t, e.New, e.Action)
+	}
+
+	// The email can be used again
+	_, err = svc.Create(ctx, user.CreateUserRequest{Name: "Ann", Email: u.Email})
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusNotFound, serveRequest(engine, http.MethodDelete, "/users/99/erase").Code)
+}
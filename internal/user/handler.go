@@ -1,42 +1,185 @@
 package user
 
 import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/things-kit/example-db/internal/database"
+	"github.com/things-kit/example-db/internal/feature"
+	"github.com/things-kit/example-db/internal/i18n"
+	"github.com/things-kit/example-db/internal/middleware"
+	"github.com/things-kit/example-db/internal/prefs"
+	"github.com/things-kit/example-db/internal/reqlog"
+	"github.com/things-kit/example-db/internal/storage"
 	"github.com/things-kit/module/log"
 )
 
 // Handler handles HTTP requests for users
 type Handler struct {
-	repo *Repository
-	log  log.Logger
+	svc     *Service
+	ids     IDType
+	counts  atomic.Pointer[countCache]
+	cursors *cursorCodec
+	flags   *feature.Flags
+	chain   middleware.Chain
+	log     log.Logger
 }
 
-// NewHandler creates a new user handler
-func NewHandler(repo *Repository, logger log.Logger) *Handler {
-	return &Handler{
-		repo: repo,
-		log:  logger,
+// NewHandler creates a new user handler. flags may be nil to use the default
+// of every feature flag.
+func NewHandler(svc *Service, cfg *Config, flags *feature.Flags, chain middleware.Chain, logger log.Logger) *Handler {
+	h := &Handler{
+		svc:     svc,
+		ids:     cfg.IDType,
+		cursors: newCursorCodec(cfg.CursorSecret),
+		flags:   flags,
+		chain:   chain,
+		log:     logger,
 	}
+	h.counts.Store(newCountCache(cfg.CountCacheTTL))
+	return h
+}
+
+// setCountCacheTTL replaces the count cache if its TTL changed; 0 counts
+// with the page query again
+func (h *Handler) setCountCacheTTL(ttl time.Duration) {
+	current := h.counts.Load()
+	if current == nil && ttl <= 0 || current != nil && current.ttl == ttl {
+		return
+	}
+	h.counts.Store(newCountCache(ttl))
 }
 
 // RegisterRoutes registers the user routes
 func (h *Handler) RegisterRoutes(engine *gin.Engine) {
-	// Health check
+	// Health check for load balancers that probe the API port; the ops
+	// port serves /healthz and /readyz
 	engine.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
 
 	// User routes
-	users := engine.Group("/users")
+	users := engine.Group("/users", h.chain...)
 	{
 		users.POST("", h.Create)
 		users.GET("", h.List)
+		users.POST("/import", h.Import)
+		users.GET("/stream", h.Stream)
+		users.GET("/changes", h.Changes)
 		users.GET("/:id", h.GetByID)
 		users.PUT("/:id", h.Update)
 		users.DELETE("/:id", h.Delete)
+		users.PATCH("/:id/preferences", h.PatchPreferences)
+		users.PUT("/:id/profile", h.UpdateProfile)
+		users.POST("/:id/suspend", h.SetStatus(h.svc.Suspend))
+		users.POST("/:id/activate", h.SetStatus(h.svc.Activate))
+		users.POST("/:id/deactivate", h.SetStatus(h.svc.Deactivate))
+		users.POST("/:id/avatar", h.UploadAvatar)
+		users.GET("/:id/avatar", h.GetAvatar)
+		users.GET("/:id/audit", h.Audit)
+		users.GET("/:id/export", h.Export)
+		users.DELETE("/:id/erase", h.Erase)
+	}
+
+	// Admin routes; restrict them to operators at the proxy
+	admin := engine.Group("/admin", h.chain...)
+	{
+		admin.POST("/users/merge", h.Merge)
+	}
+}
+
+// userID reads the :id path parameter, resolving a UUID key to the user ID
+// when users are identified by UUID. It responds and returns false when the
+// parameter is invalid.
+func (h *Handler) userID(c *gin.Context) (int64, bool) {
+	id, ok := h.parseID(c, c.Param("id"))
+	if ok {
+		h.withUserID(c, id)
+	}
+	return id, ok
+}
+
+// parseID parses a user ID from a request, resolving a UUID key to the user
+// ID when users are identified by UUID. It responds and returns false when
+// the ID is invalid.
+func (h *Handler) parseID(c *gin.Context, raw string) (int64, bool) {
+	if h.ids != IDUUID {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.Request.Context(), "Invalid user ID")})
+			return 0, false
+		}
+		return id, true
+	}
+
+	key, err := uuid.Parse(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.Request.Context(), "Invalid user ID")})
+		return 0, false
+	}
+
+	id, err := h.svc.ResolveID(c.Request.Context(), key)
+	if err != nil {
+		h.fail(c, err, "Failed to get user")
+		return 0, false
+	}
+	return id, true
+}
+
+// withUserID records the user a request acts on in its context, so it is
+// logged with every message about the request
+func (h *Handler) withUserID(c *gin.Context, id int64) {
+	c.Request = c.Request.WithContext(reqlog.WithFields(c.Request.Context(), log.Field{Key: "user_id", Value: id}))
+}
+
+// logger returns the handler's logger with the fields of the request
+func (h *Handler) logger(c *gin.Context) log.Logger {
+	return reqlog.FromContext(c.Request.Context(), h.log)
+}
+
+// fail responds with the status code matching an error from the Service.
+// Unexpected errors are reported and answered with 500 and msg.
+func (h *Handler) fail(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, ErrInvalid), errors.Is(err, ErrInvalidAvatar):
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Localize(c.Request.Context(), err)})
+	case errors.Is(err, ErrAvatarTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": i18n.Localize(c.Request.Context(), err)})
+	case errors.Is(err, ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c.Request.Context(), "User not found")})
+	case errors.Is(err, ErrNoAvatar):
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c.Request.Context(), "Avatar not found")})
+	case errors.Is(err, ErrEmailTaken), errors.Is(err, ErrStatusConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": i18n.Localize(c.Request.Context(), err)})
+	case errors.Is(err, ErrInactive):
+		c.JSON(http.StatusForbidden, gin.H{"error": i18n.Localize(c.Request.Context(), err)})
+	case errors.Is(err, ErrResyncRequired):
+		c.JSON(http.StatusGone, gin.H{"error": i18n.Localize(c.Request.Context(), err)})
+	case errors.Is(err, storage.ErrDisabled):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": i18n.T(c.Request.Context(), "Avatar storage is not available")})
+	case database.IsTimeout(err):
+		_ = c.Error(err)
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": i18n.T(c.Request.Context(), "Database query timed out")})
+	case errors.Is(err, database.ErrUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": i18n.T(c.Request.Context(), "Database is unavailable")})
+	case errors.Is(err, database.ErrOverloaded):
+		c.Header("Retry-After", "1")
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": i18n.T(c.Request.Context(), "Too many requests, try again")})
+	default:
+		_ = c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c.Request.Context(), msg)})
 	}
 }
 
@@ -44,99 +187,672 @@ func (h *Handler) RegisterRoutes(engine *gin.Engine) {
 func (h *Handler) Create(c *gin.Context) {
 	var req CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		h.log.Error("Invalid request", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		h.logger(c).Error("Invalid request", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Localize(c.Request.Context(), err)})
 		return
 	}
 
-	user, err := h.repo.Create(c.Request.Context(), req)
+	user, err := h.svc.Create(c.Request.Context(), req)
 	if err != nil {
-		h.log.Error("Failed to create user", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		h.logger(c).Error("Failed to create user", err)
+		h.fail(c, err, "Failed to create user")
 		return
 	}
 
-	h.log.Info("User created",
-		log.Field{Key: "id", Value: user.ID},
+	h.logger(c).Info("User created",
+		log.Field{Key: "user_id", Value: user.ID},
 		log.Field{Key: "email", Value: user.Email},
 	)
-	c.JSON(http.StatusCreated, user)
+	c.JSON(http.StatusCreated, NewUserResponse(user, h.ids))
 }
 
-// List handles GET /users
+// Import handles POST /users/import with a JSON array of users, created in
+// bulk with COPY with their audit entries and events
+func (h *Handler) Import(c *gin.Context) {
+	var reqs []CreateUserRequest
+	if err := c.ShouldBindJSON(&reqs); err != nil {
+		h.logger(c).Error("Invalid request", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Localize(c.Request.Context(), err)})
+		return
+	}
+
+	n, err := h.svc.Import(c.Request.Context(), reqs)
+	if err != nil {
+		h.logger(c).Error("Failed to import users", err)
+		h.fail(c, err, "Failed to import users")
+		return
+	}
+
+	h.logger(c).Info("Users imported", log.Field{Key: "count", Value: n})
+	c.JSON(http.StatusCreated, gin.H{"imported": n})
+}
+
+// List handles GET /users. Users can be filtered with created_after,
+// created_before, updated_after and updated_before (RFC 3339), with
+// preference.<key>=<value> and with status=active|suspended|deactivated|all
+// (default: all but suspended), and ordered with sort=created_at|updated_at
+// and order=asc|desc. limit and offset select a page; with count=true the
+// number of matching users is returned in the X-Total-Count header.
+// expand=profile embeds each user's profile. A full page comes with an
+// X-Next-Cursor header, whose value passed as cursor returns the next page
+// in place of offset, while the cursor_pagination flag is on.
 func (h *Handler) List(c *gin.Context) {
-	users, err := h.repo.List(c.Request.Context())
+	filter, err := parseListFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Localize(c.Request.Context(), err)})
+		return
+	}
+	cursors := h.flags.Enabled(c.Request.Context(), feature.CursorPagination)
+	if cursor := c.Query("cursor"); cursor != "" {
+		if !cursors {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.Request.Context(), "invalid cursor: cursor pagination is disabled")})
+			return
+		}
+		if filter.Offset > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.Request.Context(), "invalid cursor: can't be combined with offset")})
+			return
+		}
+		if filter.After, err = h.cursors.decode(filter, cursor); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Localize(c.Request.Context(), err)})
+			return
+		}
+	}
+	expand, err := parseExpand(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Localize(c.Request.Context(), err)})
+		return
+	}
+	count, err := strconv.ParseBool(c.DefaultQuery("count", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.Request.Context(), "invalid count: must be true or false")})
+		return
+	}
+
+	ctx := c.Request.Context()
+	counts := h.counts.Load()
+	var (
+		users []*User
+		total int64
+	)
+	switch {
+	case !count:
+		users, err = h.svc.List(ctx, filter)
+	case counts != nil:
+		users, err = h.svc.List(ctx, filter)
+		if err == nil {
+			total, err = counts.get(ctx, filter, func() (int64, error) {
+				return h.svc.Count(ctx, filter)
+			})
+		}
+	default:
+		users, total, err = h.svc.ListWithTotal(ctx, filter)
+	}
 	if err != nil {
-		h.log.Error("Failed to list users", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list users"})
+		h.logger(c).Error("Failed to list users", err)
+		h.fail(c, err, "Failed to list users")
 		return
 	}
 
-	c.JSON(http.StatusOK, users)
+	resp := NewUserResponses(users, h.ids)
+	if expand.profile {
+		profiles, err := h.svc.Profiles(ctx, users)
+		if err != nil {
+			h.logger(c).Error("Failed to list profiles", err)
+			h.fail(c, err, "Failed to list users")
+			return
+		}
+		for i, u := range users {
+			resp[i].Profile = NewProfileResponse(profiles[u.ID])
+		}
+	}
+
+	if count {
+		c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	}
+	if cursors && filter.Limit > 0 && len(users) == filter.Limit {
+		c.Header("X-Next-Cursor", h.cursors.encode(filter, users[len(users)-1]))
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
-// GetByID handles GET /users/:id
+// streamFlushRows is the number of users Stream writes between flushes
+const streamFlushRows = 500
+
+// Stream handles GET /users/stream, writing every user matching the List
+// filters as newline-delimited JSON. The status is sent with the first user,
+// so a failure after that can only be reported by ending the stream early.
+func (h *Handler) Stream(c *gin.Context) {
+	filter, err := parseListFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Localize(c.Request.Context(), err)})
+		return
+	}
+
+	enc := json.NewEncoder(c.Writer)
+	written := 0
+	err = h.svc.Stream(c.Request.Context(), filter, func(u *User) error {
+		if written == 0 {
+			c.Header("Content-Type", "application/x-ndjson")
+			c.Status(http.StatusOK)
+		}
+		if err := enc.Encode(NewUserResponse(u, h.ids)); err != nil {
+			return fmt.Errorf("failed to write user: %w", err)
+		}
+		if written++; written%streamFlushRows == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	})
+
+	switch {
+	case err != nil && written == 0:
+		h.logger(c).Error("Failed to stream users", err)
+		h.fail(c, err, "Failed to stream users")
+	case err != nil:
+		h.logger(c).Error("Failed to stream users", err, log.Field{Key: "written", Value: written})
+		_ = c.Error(err)
+	case written == 0:
+		c.Data(http.StatusOK, "application/x-ndjson", nil)
+	}
+}
+
+// expansion holds the related resources requested with ?expand
+type expansion struct {
+	profile bool
+}
+
+// parseExpand reads the comma-separated expand query parameter
+func parseExpand(c *gin.Context) (expansion, error) {
+	var e expansion
+	for _, name := range strings.Split(c.Query("expand"), ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case "profile":
+			e.profile = true
+		default:
+			return e, i18n.Errorf("invalid expand: unknown resource %q", name)
+		}
+	}
+	return e, nil
+}
+
+// maxListLimit bounds the page size of List
+const maxListLimit = 1000
+
+// parseListFilter reads the List query parameters
+func parseListFilter(c *gin.Context) (ListFilter, error) {
+	var f ListFilter
+
+	for param, dst := range map[string]**time.Time{
+		"created_after":  &f.CreatedAfter,
+		"created_before": &f.CreatedBefore,
+		"updated_after":  &f.UpdatedAfter,
+		"updated_before": &f.UpdatedBefore,
+	} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return f, i18n.Errorf("invalid %s: expected an RFC 3339 timestamp", param)
+		}
+		t = t.UTC()
+		*dst = &t
+	}
+
+	for param, values := range c.Request.URL.Query() {
+		key, ok := strings.CutPrefix(param, "preference.")
+		if !ok {
+			continue
+		}
+		if key == "" {
+			return f, i18n.Errorf("invalid %s: missing preference name", param)
+		}
+		if f.Preferences == nil {
+			f.Preferences = prefs.Preferences{}
+		}
+		f.Preferences[key] = preferenceValue(values[0])
+	}
+
+	switch f.Status = c.Query("status"); f.Status {
+	case "", StatusActive, StatusSuspended, StatusDeactivated, StatusAll:
+	default:
+		return f, i18n.Errorf("invalid status: must be %s, %s, %s or %s",
+			StatusActive, StatusSuspended, StatusDeactivated, StatusAll)
+	}
+
+	for param, dst := range map[string]*int{"limit": &f.Limit, "offset": &f.Offset} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return f, i18n.Errorf("invalid %s: expected a non-negative integer", param)
+		}
+		*dst = n
+	}
+	if f.Limit > maxListLimit {
+		return f, i18n.Errorf("invalid limit: must be at most %d", maxListLimit)
+	}
+	// The offset is an int32 in the query
+	if f.Offset > math.MaxInt32 {
+		return f, i18n.Errorf("invalid offset: must be at most %d", math.MaxInt32)
+	}
+
+	switch f.SortBy = c.DefaultQuery("sort", SortCreatedAt); f.SortBy {
+	case SortCreatedAt, SortUpdatedAt:
+	default:
+		return f, i18n.Errorf("invalid sort: must be %s or %s", SortCreatedAt, SortUpdatedAt)
+	}
+
+	switch order := c.DefaultQuery("order", "desc"); order {
+	case "asc":
+		f.Ascending = true
+	case "desc":
+	default:
+		return f, i18n.Errorf("invalid order: must be asc or desc")
+	}
+
+	return f, nil
+}
+
+// preferenceValue reads a preference filter value: JSON booleans and numbers
+// keep their type, anything else matches as a string
+func preferenceValue(s string) any {
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err == nil {
+		switch v.(type) {
+		case bool, float64:
+			return v
+		}
+	}
+	return s
+}
+
+// GetByID handles GET /users/:id; expand=profile embeds the user's profile
 func (h *Handler) GetByID(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
+	id, ok := h.userID(c)
+	if !ok {
+		return
+	}
+	expand, err := parseExpand(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Localize(c.Request.Context(), err)})
 		return
 	}
 
-	user, err := h.repo.GetByID(c.Request.Context(), id)
+	var (
+		user    *User
+		profile *Profile
+	)
+	if expand.profile {
+		user, profile, err = h.svc.GetWithProfile(c.Request.Context(), id)
+	} else {
+		user, err = h.svc.GetByID(c.Request.Context(), id)
+	}
 	if err != nil {
-		h.log.Error("Failed to get user", err, log.Field{Key: "id", Value: id})
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		h.logger(c).Error("Failed to get user", err)
+		h.fail(c, err, "Failed to get user")
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	resp := NewUserResponse(user, h.ids)
+	if expand.profile {
+		resp.Profile = NewProfileResponse(profile)
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // Update handles PUT /users/:id
 func (h *Handler) Update(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+	id, ok := h.userID(c)
+	if !ok {
 		return
 	}
 
 	var req CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		h.log.Error("Invalid request", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		h.logger(c).Error("Invalid request", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Localize(c.Request.Context(), err)})
 		return
 	}
 
-	user, err := h.repo.Update(c.Request.Context(), id, req)
+	user, err := h.svc.Update(c.Request.Context(), id, req)
 	if err != nil {
-		h.log.Error("Failed to update user", err, log.Field{Key: "id", Value: id})
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		h.logger(c).Error("Failed to update user", err)
+		h.fail(c, err, "Failed to update user")
 		return
 	}
 
-	h.log.Info("User updated", log.Field{Key: "id", Value: user.ID})
-	c.JSON(http.StatusOK, user)
+	h.logger(c).Info("User updated")
+	c.JSON(http.StatusOK, NewUserResponse(user, h.ids))
 }
 
 // Delete handles DELETE /users/:id
 func (h *Handler) Delete(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
+	id, ok := h.userID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Delete(c.Request.Context(), id); err != nil {
+		h.logger(c).Error("Failed to delete user", err)
+		h.fail(c, err, "Failed to delete user")
+		return
+	}
+
+	h.logger(c).Info("User deleted")
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// PatchPreferences handles PATCH /users/:id/preferences with a JSON merge
+// patch: objects are merged, null removes a key and other values replace it
+func (h *Handler) PatchPreferences(c *gin.Context) {
+	id, ok := h.userID(c)
+	if !ok {
+		return
+	}
+
+	var patch prefs.Preferences
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		h.logger(c).Error("Invalid request", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.Request.Context(), "Preferences must be a JSON object")})
+		return
+	}
+
+	user, err := h.svc.PatchPreferences(c.Request.Context(), id, patch)
+	if err != nil {
+		h.logger(c).Error("Failed to update preferences", err)
+		h.fail(c, err, "Failed to update preferences")
+		return
+	}
+
+	h.logger(c).Info("Preferences updated")
+	c.JSON(http.StatusOK, NewUserResponse(user, h.ids))
+}
+
+// UpdateProfile handles PUT /users/:id/profile, creating or replacing the
+// user's profile
+func (h *Handler) UpdateProfile(c *gin.Context) {
+	id, ok := h.userID(c)
+	if !ok {
+		return
+	}
+
+	var req ProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger(c).Error("Invalid request", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Localize(c.Request.Context(), err)})
+		return
+	}
+
+	profile, err := h.svc.UpdateProfile(c.Request.Context(), id, req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		h.logger(c).Error("Failed to update profile", err)
+		h.fail(c, err, "Failed to update profile")
 		return
 	}
 
-	err = h.repo.Delete(c.Request.Context(), id)
+	h.logger(c).Info("Profile updated")
+	c.JSON(http.StatusOK, NewProfileResponse(profile))
+}
+
+// SetStatus returns the handler of POST /users/:id/suspend, /activate and
+// /deactivate, which change the status with change
+func (h *Handler) SetStatus(change func(ctx context.Context, id int64) (*User, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := h.userID(c)
+		if !ok {
+			return
+		}
+
+		user, err := change(c.Request.Context(), id)
+		if err != nil {
+			h.logger(c).Error("Failed to change status", err)
+			h.fail(c, err, "Failed to change status")
+			return
+		}
+
+		h.logger(c).Info("Status changed", log.Field{Key: "status", Value: user.Status})
+		c.JSON(http.StatusOK, NewUserResponse(user, h.ids))
+	}
+}
+
+// UploadAvatar handles POST /users/:id/avatar with a multipart "avatar" file.
+// A body larger than the avatar limit is answered with 413.
+func (h *Handler) UploadAvatar(c *gin.Context) {
+	id, ok := h.userID(c)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxAvatarSize+(1<<20))
+	file, err := c.FormFile("avatar")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		h.fail(c, ErrAvatarTooLarge, "Failed to upload avatar")
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.Request.Context(), "Missing avatar file")})
+		return
+	}
+
+	f, err := file.Open()
 	if err != nil {
-		h.log.Error("Failed to delete user", err, log.Field{Key: "id", Value: id})
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.Request.Context(), "Invalid avatar file")})
+		return
+	}
+	defer f.Close()
+
+	if err := h.svc.UploadAvatar(c.Request.Context(), id, f); err != nil {
+		h.logger(c).Error("Failed to upload avatar", err)
+		h.fail(c, err, "Failed to upload avatar")
+		return
+	}
+
+	h.logger(c).Info("Avatar uploaded")
+	c.Status(http.StatusNoContent)
+}
+
+// GetAvatar handles GET /users/:id/avatar
+func (h *Handler) GetAvatar(c *gin.Context) {
+	id, ok := h.userID(c)
+	if !ok {
+		return
+	}
+
+	avatar, err := h.svc.AvatarURL(c.Request.Context(), id)
+	if err != nil {
+		h.logger(c).Error("Failed to get avatar", err)
+		h.fail(c, err, "Failed to get avatar")
+		return
+	}
+
+	c.JSON(http.StatusOK, avatar)
+}
+
+// Audit handles GET /users/:id/audit, returning the user's recorded changes
+// newest first. ?limit= caps the number of entries (default 50, at most 500).
+func (h *Handler) Audit(c *gin.Context) {
+	id, ok := h.userID(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.Request.Context(), "limit must be between 1 and 500")})
+		return
+	}
+
+	entries, err := h.svc.Audit(c.Request.Context(), id, limit)
+	if err != nil {
+		h.logger(c).Error("Failed to get audit log", err)
+		h.fail(c, err, "Failed to get audit log")
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// Export handles GET /users/:id/export, returning everything stored about the
+// user for download: the user, profile, audit history and stored objects as
+// JSON, or with ?format=zip as a ZIP archive of export.json and the objects
+func (h *Handler) Export(c *gin.Context) {
+	id, ok := h.userID(c)
+	if !ok {
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "zip" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.Request.Context(), "format must be json or zip")})
 		return
 	}
 
-	h.log.Info("User deleted", log.Field{Key: "id", Value: id})
+	export, err := h.svc.Export(c.Request.Context(), id)
+	if err != nil {
+		h.logger(c).Error("Failed to export user", err)
+		h.fail(c, err, "Failed to export user")
+		return
+	}
+
+	h.logger(c).Info("User exported")
+	filename := fmt.Sprintf("user-%s-export.%s", c.Param("id"), format)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if format == "json" {
+		c.JSON(http.StatusOK, NewExportResponse(export, h.ids, false))
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Status(http.StatusOK)
+	if err := writeExportArchive(c.Writer, NewExportResponse(export, h.ids, true), export.Objects); err != nil {
+		// The status is sent; the client is left with a truncated archive
+		h.logger(c).Error("Failed to write export archive", err)
+		_ = c.Error(err)
+	}
+}
+
+// writeExportArchive writes a ZIP archive of export.json and the objects
+func writeExportArchive(w io.Writer, resp ExportResponse, objects []ExportObject) error {
+	zw := zip.NewWriter(w)
+
+	f, err := zw.Create("export.json")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(resp); err != nil {
+		return err
+	}
+
+	for _, obj := range objects {
+		f, err := zw.Create(exportObjectPath(obj.Key))
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(obj.Data); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// Erase handles DELETE /users/:id/erase, removing the personal data of the
+// user for good. Unlike Delete it can't be undone.
+func (h *Handler) Erase(c *gin.Context) {
+	id, ok := h.userID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Erase(c.Request.Context(), id); err != nil {
+		h.logger(c).Error("Failed to erase user", err)
+		h.fail(c, err, "Failed to erase user")
+		return
+	}
+
+	h.logger(c).Info("User erased")
 	c.JSON(http.StatusNoContent, nil)
 }
+
+// Merge handles POST /admin/users/merge
+func (h *Handler) Merge(c *gin.Context) {
+	var req MergeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger(c).Error("Invalid request", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Localize(c.Request.Context(), err)})
+		return
+	}
+
+	survivorID, ok := h.parseID(c, string(req.SurvivorID))
+	if !ok {
+		return
+	}
+	duplicateID, ok := h.parseID(c, string(req.DuplicateID))
+	if !ok {
+		return
+	}
+	h.withUserID(c, survivorID)
+
+	user, err := h.svc.Merge(c.Request.Context(), survivorID, duplicateID)
+	if err != nil {
+		h.logger(c).Error("Failed to merge users", err)
+		h.fail(c, err, "Failed to merge users")
+		return
+	}
+
+	h.logger(c).Info("Users merged", log.Field{Key: "merged_id", Value: duplicateID})
+	c.JSON(http.StatusOK, NewUserResponse(user, h.ids))
+}
+
+// Changes handles GET /users/changes, returning the users created, updated or
+// deleted after ?since=, the cursor of the previous page or an RFC 3339
+// timestamp, so clients can sync the user list incrementally. A since older
+// than the purge retention gets 410 Gone: the client downloads the users
+// again.
+func (h *Handler) Changes(c *gin.Context) {
+	since := c.Query("since")
+	filter, ok := parseSince(since)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.Request.Context(), "invalid since: expected a cursor or an RFC 3339 timestamp")})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > maxChanges {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.Request.Context(), "limit must be between 1 and 500")})
+		return
+	}
+	// One more change tells whether there is another page
+	filter.Limit = limit + 1
+
+	changes, err := h.svc.Changes(c.Request.Context(), filter)
+	if err != nil {
+		h.logger(c).Error("Failed to list changes", err)
+		h.fail(c, err, "Failed to list changes")
+		return
+	}
+
+	resp := ChangesResponse{Changes: make([]ChangeResponse, 0, len(changes))}
+	if len(changes) > limit {
+		changes, resp.HasMore = changes[:limit], true
+	}
+	for _, change := range changes {
+		resp.Changes = append(resp.Changes, NewChangeResponse(change, h.ids))
+	}
+	switch {
+	case len(changes) > 0:
+		resp.Cursor = strconv.FormatInt(changes[len(changes)-1].Seq, 10)
+	case filter.AfterSeq > 0 || since == "":
+		resp.Cursor = strconv.FormatInt(filter.AfterSeq, 10)
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,175 @@
+package user_test
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/things-kit/example-db/internal/mail"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/user"
+)
+
+// The fuzz targets send malformed input to the handlers, which must answer
+// with a 4xx and a JSON error, never a 5xx or a panic. The engine has no
+// recovery middleware, so a panic fails the target. They run on the
+// in-memory repository, which takes inputs the SQL path rejects, so limits
+// the queries depend on are checked on the request itself; run one for a
+// while with
+//
+//	go test ./internal/user -run '^$' -fuzz FuzzCreateBody -fuzztime 1m
+
+// newFuzzEngine returns the user API on an in-memory repository holding
+// user 1
+func newFuzzEngine(tb testing.TB) *gin.Engine {
+	tb.Helper()
+
+	repo := user.NewMemoryRepository()
+	queue := mail.NewQueue(nil, &mail.Config{QueueSize: 1}, testutil.NopLogger{})
+	svc := user.NewService(repo, nil, queue, nil, nil, testutil.NopLogger{})
+	if _, err := repo.Create(tb.Context(), user.CreateUserRequest{Name: "John", Email: "john@example.com"}); err != nil {
+		tb.Fatal(err)
+	}
+
+	engine := gin.New()
+	user.NewHandler(svc, &user.Config{IDType: user.IDSerial}, nil, nil, testutil.NopLogger{}).RegisterRoutes(engine)
+	return engine
+}
+
+// serve sends req and checks that the response is no server error, and that
+// an error response carries a JSON body. It returns the status code.
+func serve(t *testing.T, engine *gin.Engine, req *http.Request) int {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+
+	if w.Code >= http.StatusInternalServerError {
+		t.Fatalf("%s %s: got %d: %s", req.Method, req.URL, w.Code, w.Body)
+	}
+	if w.Code >= http.StatusBadRequest && !json.Valid(w.Body.Bytes()) {
+		t.Fatalf("%s %s: got %d with a body that isn't JSON: %q", req.Method, req.URL, w.Code, w.Body)
+	}
+	return w.Code
+}
+
+func jsonRequest(method, target, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func FuzzCreateBody(f *testing.F) {
+	for _, body := range []string{
+		`{"name":"Ann","email":"ann@example.com"}`,
+		`{"name":"John","email":"john@example.com"}`,
+		`{"name":"","email":""}`,
+		`{"name":"Ann","email":"not-an-email"}`,
+		`{"name":1,"email":true}`,
+		`{"name":"Ann","email":"ann@example.com","extra":[1,2,{}]}`,
+		`{"name":"\u0000","email":"a@b.c"}`,
+		`[]`,
+		`null`,
+		`{"name":`,
+		``,
+	} {
+		f.Add(body)
+	}
+
+	engine := newFuzzEngine(f)
+	f.Fuzz(func(t *testing.T, body string) {
+		serve(t, engine, jsonRequest(http.MethodPost, "/users", body))
+		serve(t, engine, jsonRequest(http.MethodPut, "/users/1", body))
+		serve(t, engine, jsonRequest(http.MethodPut, "/users/1/profile", body))
+		serve(t, engine, jsonRequest(http.MethodPost, "/users/import", body))
+	})
+}
+
+func FuzzPatchPreferences(f *testing.F) {
+	for _, body := range []string{
+		`{"theme":"dark"}`,
+		`{"theme":null}`,
+		`{"notifications":{"email":false,"sms":null}}`,
+		`{"a":{"b":{"c":{"d":[1,"2",3.5e300]}}}}`,
+		`"dark"`,
+		`{}`,
+		`{`,
+	} {
+		f.Add(body)
+	}
+
+	engine := newFuzzEngine(f)
+	f.Fuzz(func(t *testing.T, body string) {
+		serve(t, engine, jsonRequest(http.MethodPatch, "/users/1/preferences", body))
+	})
+}
+
+func FuzzUserID(f *testing.F) {
+	for _, id := range []string{
+		"1", "2", "0", "-1", "abc", "1.5", "1e3", "9223372036854775807", "9223372036854775808",
+		"01890a5d-ac96-774b-bcce-b302099a8057", " 1", "0x1",
+	} {
+		f.Add(id)
+	}
+
+	engine := newFuzzEngine(f)
+	f.Fuzz(func(t *testing.T, id string) {
+		path := "/users/" + url.PathEscape(id)
+		serve(t, engine, httptest.NewRequest(http.MethodGet, path, nil))
+		serve(t, engine, httptest.NewRequest(http.MethodGet, path+"/audit", nil))
+		serve(t, engine, httptest.NewRequest(http.MethodGet, path+"/avatar", nil))
+		serve(t, engine, httptest.NewRequest(http.MethodPost, path+"/suspend", nil))
+	})
+}
+
+func FuzzListQuery(f *testing.F) {
+	for _, query := range []string{
+		"limit=10&offset=20",
+		"limit=-1",
+		"limit=99999999999999999999",
+		"offset=abc",
+		"offset=2147483647",
+		"offset=2147483648",
+		"offset=4294967296",
+		"sort=updated_at&order=asc",
+		"sort=name&order=sideways",
+		"status=all",
+		"status=unknown",
+		"created_after=2024-01-01T00:00:00Z&created_before=not-a-time",
+		"preference.theme=dark&preference.beta=true",
+		"preference.=x",
+		"count=true&expand=profile",
+		"count=maybe&expand=everything",
+		"cursor=abc.def",
+		"%zz=1&;=;",
+	} {
+		f.Add(query)
+	}
+
+	engine := newFuzzEngine(f)
+	f.Fuzz(func(t *testing.T, query string) {
+		for _, path := range []string{"/users", "/users/stream"} {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.URL.RawQuery = query
+			code := serve(t, engine, req)
+
+			// limit and offset are int32 parameters of the SQL query, which
+			// the in-memory repository doesn't convert: a larger value must
+			// be rejected by the handler, not wrap in the repository
+			if code >= http.StatusBadRequest {
+				continue
+			}
+			for _, param := range []string{"limit", "offset"} {
+				if n, err := strconv.ParseInt(req.URL.Query().Get(param), 10, 64); err == nil && n > math.MaxInt32 {
+					t.Fatalf("GET %s: got %d for %s=%d, which overflows the query", req.URL, code, param, n)
+				}
+			}
+		}
+	})
+}
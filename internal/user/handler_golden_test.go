@@ -0,0 +1,121 @@
+package user_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/things-kit/example-db/internal/prefs"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/user"
+	"github.com/things-kit/example-db/internal/user/usermock"
+	"go.uber.org/mock/gomock"
+)
+
+// The golden tests pin the shape of API responses; run them with -update to
+// accept a change, which then shows up in testdata.
+
+var goldenTime = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func goldenUser(id int64, name string) *user.User {
+	return &user.User{
+		ID:          id,
+		UUID:        uuid.MustParse("01890a5d-ac96-774b-bcce-b302099a8057"),
+		Name:        name,
+		Email:       "john@example.com",
+		Status:      user.StatusActive,
+		CreatedAt:   goldenTime,
+		UpdatedAt:   goldenTime,
+		Preferences: prefs.Preferences{"theme": "dark"},
+	}
+}
+
+func TestHandlerGolden(t *testing.T) {
+	tests := []struct {
+		name   string
+		ids    user.IDType
+		path   string
+		expect func(repo *usermock.MockUserRepository)
+		status int
+	}{
+		{
+			name: "get_user",
+			path: "/users/1",
+			expect: func(repo *usermock.MockUserRepository) {
+				repo.EXPECT().GetByID(gomock.Any(), int64(1)).Return(goldenUser(1, "John"), nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "get_user_uuid",
+			ids:  user.IDUUID,
+			path: "/users/01890a5d-ac96-774b-bcce-b302099a8057",
+			expect: func(repo *usermock.MockUserRepository) {
+				repo.EXPECT().GetIDByUUID(gomock.Any(), gomock.Any()).Return(int64(1), nil)
+				repo.EXPECT().GetByID(gomock.Any(), int64(1)).Return(goldenUser(1, "John"), nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "list_users",
+			path: "/users",
+			expect: func(repo *usermock.MockUserRepository) {
+				repo.EXPECT().List(gomock.Any(), gomock.Any()).
+					Return([]*user.User{goldenUser(1, "John"), goldenUser(2, "Jane")}, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "list_users_empty",
+			path: "/users",
+			expect: func(repo *usermock.MockUserRepository) {
+				repo.EXPECT().List(gomock.Any(), gomock.Any()).Return(nil, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "user_not_found",
+			path: "/users/2",
+			expect: func(repo *usermock.MockUserRepository) {
+				repo.EXPECT().GetByID(gomock.Any(), int64(2)).Return(nil, user.ErrNotFound)
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			name: "internal_error",
+			path: "/users/3",
+			expect: func(repo *usermock.MockUserRepository) {
+				repo.EXPECT().GetByID(gomock.Any(), int64(3)).Return(nil, errors.New("connection refused"))
+			},
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:   "invalid_id",
+			path:   "/users/abc",
+			expect: func(*usermock.MockUserRepository) {},
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := tt.ids
+			if ids == "" {
+				ids = user.IDSerial
+			}
+			engine, repo := newTestHandlerWithIDs(t, ids)
+			tt.expect(repo)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			engine.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			testutil.GoldenJSON(t, tt.name, w.Body.Bytes())
+		})
+	}
+}
@@ -0,0 +1,428 @@
+package user_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/things-kit/example-db/internal/feature"
+	"github.com/things-kit/example-db/internal/prefs"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/user"
+	"github.com/things-kit/example-db/internal/user/usermock"
+	"go.uber.org/mock/gomock"
+)
+
+func newTestHandler(t *testing.T) (*gin.Engine, *usermock.MockUserRepository) {
+	t.Helper()
+	return newTestHandlerWithIDs(t, user.IDSerial)
+}
+
+func newTestHandlerWithIDs(t *testing.T, ids user.IDType) (*gin.Engine, *usermock.MockUserRepository) {
+	t.Helper()
+
+	repo := usermock.NewMockUserRepository(gomock.NewController(t))
+	svc := user.NewService(repo, nil, nil, nil, nil, testutil.NopLogger{})
+
+	engine := gin.New()
+	user.NewHandler(svc, &user.Config{IDType: ids}, nil, nil, testutil.NopLogger{}).RegisterRoutes(engine)
+	return engine, repo
+}
+
+func TestHandlerGetByID(t *testing.T) {
+	engine, repo := newTestHandler(t)
+
+	repo.EXPECT().GetByID(gomock.Any(), int64(1)).Return(&user.User{ID: 1, Name: "John"}, nil)
+	repo.EXPECT().GetByID(gomock.Any(), int64(2)).Return(nil, user.ErrNotFound)
+	repo.EXPECT().GetByID(gomock.Any(), int64(3)).Return(nil, fmt.Errorf("failed to get user: %w", context.DeadlineExceeded))
+	repo.EXPECT().GetByID(gomock.Any(), int64(4)).Return(nil, errors.New("connection refused"))
+
+	for id, status := range map[int]int{
+		1: http.StatusOK,
+		2: http.StatusNotFound,
+		3: http.StatusGatewayTimeout,
+		4: http.StatusInternalServerError,
+	} {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/users/%d", id), nil))
+		assert.Equal(t, status, w.Code, "user %d", id)
+	}
+}
+
+func TestHandlerGetByUUID(t *testing.T) {
+	engine, repo := newTestHandlerWithIDs(t, user.IDUUID)
+
+	key := uuid.MustParse("01890a5d-ac96-774b-bcce-b302099a8057")
+	repo.EXPECT().GetIDByUUID(gomock.Any(), key).Return(int64(7), nil)
+	repo.EXPECT().GetByID(gomock.Any(), int64(7)).Return(&user.User{ID: 7, UUID: key}, nil)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/"+key.String(), nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":"01890a5d-ac96-774b-bcce-b302099a8057"`)
+
+	for path, status := range map[string]int{
+		"/users/7": http.StatusBadRequest,
+		"/users/01890a5d-ac96-774b-bcce-000000000000": http.StatusNotFound,
+	} {
+		if status == http.StatusNotFound {
+			repo.EXPECT().GetIDByUUID(gomock.Any(), gomock.Any()).Return(int64(0), user.ErrNotFound)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, status, w.Code, path)
+	}
+}
+
+func TestHandlerCreate(t *testing.T) {
+	engine, repo := newTestHandler(t)
+
+	repo.EXPECT().WithTx(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, fn func(user.UserRepository) error) error { return fn(repo) })
+	repo.EXPECT().Create(gomock.Any(), user.CreateUserRequest{Name: "John", Email: "john@example.com"}).
+		Return(nil, fmt.Errorf("failed to create user: %w", user.ErrEmailTaken))
+
+	for body, status := range map[string]int{
+		`{"name":"John","email":"not-an-email"}`:       http.StatusBadRequest,
+		`{"name":"","email":"john@example.com"}`:       http.StatusBadRequest,
+		`{"name":"John","email":" JOHN@example.com "}`: http.StatusConflict,
+		`{"name":`: http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, status, w.Code, body)
+	}
+}
+
+func TestHandlerListPassesFilter(t *testing.T) {
+	engine, repo := newTestHandler(t)
+
+	repo.EXPECT().
+		List(gomock.Any(), user.ListFilter{
+			SortBy:      user.SortUpdatedAt,
+			Ascending:   true,
+			Preferences: prefs.Preferences{"theme": "dark", "beta": true},
+		}).
+		Return([]*user.User{{ID: 1}, {ID: 2}}, nil)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users?sort=updated_at&order=asc&preference.theme=dark&preference.beta=true", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"id":1,"name":"","email":"","created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z","status":"","preferences":{}},
+		{"id":2,"name":"","email":"","created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z","status":"","preferences":{}}]`, w.Body.String())
+}
+
+// outboxTx accepts the outbox insert of a mutation
+type outboxTx struct{ pgx.Tx }
+
+func (outboxTx) Exec(context.Context, string, ...any) (pgconn.CommandTag, error) {
+	return pgconn.NewCommandTag("INSERT 0 1"), nil
+}
+
+func TestHandlerPatchPreferences(t *testing.T) {
+	engine, repo := newTestHandler(t)
+
+	old := &user.User{ID: 1, Status: user.StatusActive, Preferences: prefs.Preferences{"theme": "dark", "beta": true}}
+	merged := prefs.Preferences{"theme": "light"}
+
+	repo.EXPECT().WithTx(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, fn func(user.UserRepository) error) error { return fn(repo) })
+	repo.EXPECT().GetForUpdate(gomock.Any(), int64(1)).Return(old, nil)
+	repo.EXPECT().SetPreferences(gomock.Any(), int64(1), merged).Return(&user.User{ID: 1, Preferences: merged}, nil)
+	repo.EXPECT().AddAudit(gomock.Any(), int64(1), user.AuditUpdatePreferences, old.Preferences, merged).Return(nil)
+	repo.EXPECT().Tx().Return(outboxTx{})
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/users/1/preferences",
+		strings.NewReader(`{"theme":"light","beta":null}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"preferences":{"theme":"light"}`)
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/users/1/preferences", strings.NewReader(`["theme"]`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandlerSetStatus(t *testing.T) {
+	engine, repo := newTestHandler(t)
+
+	repo.EXPECT().WithTx(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, fn func(user.UserRepository) error) error { return fn(repo) })
+	repo.EXPECT().GetForUpdate(gomock.Any(), int64(1)).Return(&user.User{ID: 1, Status: user.StatusActive}, nil)
+	repo.EXPECT().SetStatus(gomock.Any(), int64(1), user.StatusSuspended).
+		Return(&user.User{ID: 1, Status: user.StatusSuspended}, nil)
+	repo.EXPECT().AddAudit(gomock.Any(), int64(1), user.AuditSuspend,
+		map[string]string{"status": "active"}, map[string]string{"status": "suspended"}).Return(nil)
+	repo.EXPECT().Tx().Return(outboxTx{})
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/users/1/suspend", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"suspended"`)
+
+	// A deactivated user can't be suspended
+	repo.EXPECT().GetForUpdate(gomock.Any(), int64(2)).Return(&user.User{ID: 2, Status: user.StatusDeactivated}, nil)
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/users/2/suspend", nil))
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestHandlerExpandProfile(t *testing.T) {
+	engine, repo := newTestHandler(t)
+	ctrl := gomock.NewController(t)
+	profiles := usermock.NewMockProfileRepository(ctrl)
+
+	repo.EXPECT().GetWithProfile(gomock.Any(), int64(1)).
+		Return(&user.User{ID: 1}, &user.Profile{UserID: 1, Phone: "+1 555 0100"}, nil)
+	repo.EXPECT().List(gomock.Any(), user.ListFilter{SortBy: user.SortCreatedAt}).
+		Return([]*user.User{{ID: 1}, {ID: 2}}, nil)
+	repo.EXPECT().Profiles().Return(profiles)
+	profiles.EXPECT().List(gomock.Any(), []int64{1, 2}).
+		Return(map[int64]*user.Profile{1: {UserID: 1, Bio: "Hi"}}, nil)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1?expand=profile", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"phone":"+1 555 0100"`)
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users?expand=profile", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"bio":"Hi"`)
+	assert.Contains(t, w.Body.String(), `"profile":{"phone":"","address":"","bio":"","avatar_url":""}`,
+		"users without a profile get an empty one")
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1?expand=friends", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandlerUpdateProfile(t *testing.T) {
+	engine, repo := newTestHandler(t)
+	profiles := usermock.NewMockProfileRepository(gomock.NewController(t))
+
+	req := user.ProfileRequest{Phone: "+1 555 0100", AvatarURL: "https://example.com/a.png"}
+	updated := &user.Profile{UserID: 1, Phone: req.Phone, AvatarURL: req.AvatarURL}
+
+	repo.EXPECT().WithTx(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, fn func(user.UserRepository) error) error { return fn(repo) })
+	repo.EXPECT().GetForUpdate(gomock.Any(), int64(1)).Return(&user.User{ID: 1, Status: user.StatusActive}, nil)
+	repo.EXPECT().Profiles().Return(profiles).Times(2)
+	profiles.EXPECT().Get(gomock.Any(), int64(1)).Return(nil, user.ErrNotFound)
+	profiles.EXPECT().Upsert(gomock.Any(), int64(1), req).Return(updated, nil)
+	repo.EXPECT().AddAudit(gomock.Any(), int64(1), user.AuditUpdateProfile, (*user.Profile)(nil), updated).Return(nil)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/users/1/profile",
+		strings.NewReader(`{"phone":" +1 555 0100 ","avatar_url":"https://example.com/a.png"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"phone":"+1 555 0100"`)
+
+	for _, body := range []string{
+		`{"avatar_url":"javascript:alert(1)"}`,
+		`{"phone":"` + strings.Repeat("1", 33) + `"}`,
+	} {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/users/1/profile", strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
+
+func TestHandlerRejectsChangesToInactiveUsers(t *testing.T) {
+	engine, repo := newTestHandler(t)
+
+	repo.EXPECT().WithTx(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, fn func(user.UserRepository) error) error { return fn(repo) }).Times(2)
+	repo.EXPECT().GetForUpdate(gomock.Any(), int64(1)).Return(&user.User{ID: 1, Status: user.StatusSuspended}, nil).Times(2)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodPut, "/users/1", strings.NewReader(`{"name":"Ann","email":"ann@example.com"}`)),
+		httptest.NewRequest(http.MethodPatch, "/users/1/preferences", strings.NewReader(`{"theme":"light"}`)),
+	} {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code, "%s %s", req.Method, req.URL)
+		assert.Contains(t, w.Body.String(), "suspended")
+	}
+}
+
+func TestHandlerUploadAvatarTooLarge(t *testing.T) {
+	engine, _ := newTestHandler(t)
+
+	// One file just over the limit, read by the service, and one over the
+	// limit of the request body
+	for _, size := range []int{user.MaxAvatarSize + 1, user.MaxAvatarSize + 2<<20} {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		part, err := mw.CreateFormFile("avatar", "big.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = part.Write(bytes.Repeat([]byte{0}, size))
+		_ = mw.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/users/1/avatar", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code, "size %d", size)
+	}
+}
+
+func TestHandlerImport(t *testing.T) {
+	engine, repo := newTestHandler(t)
+
+	repo.EXPECT().WithTx(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, fn func(user.UserRepository) error) error { return fn(repo) })
+	repo.EXPECT().CopyFrom(gomock.Any(), []user.CreateUserRequest{
+		{Name: "Ann", Email: "ann@example.com"},
+		{Name: "Bob", Email: "bob@example.com"},
+	}).Return(int64(2), nil)
+	// Every imported user is audited and announced like a created one
+	for i, email := range []string{"ann@example.com", "bob@example.com"} {
+		u := &user.User{ID: int64(i + 1), Email: email}
+		repo.EXPECT().GetByEmail(gomock.Any(), email).Return(u, nil)
+		repo.EXPECT().AddAudit(gomock.Any(), u.ID, user.AuditCreate, nil, u).Return(nil)
+	}
+	repo.EXPECT().Tx().Return(outboxTx{}).Times(2)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/users/import",
+		strings.NewReader(`[{"name":"Ann","email":"ANN@example.com"},{"name":" Bob ","email":"bob@example.com"}]`)))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"imported":2}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/users/import",
+		strings.NewReader(`[{"name":"Ann","email":"ann@example.com"},{"name":"","email":"bob@example.com"}]`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code, "nothing is copied when any user is invalid")
+	assert.Contains(t, w.Body.String(), "user 1")
+}
+
+func TestHandlerStream(t *testing.T) {
+	engine, repo := newTestHandler(t)
+
+	repo.EXPECT().Stream(gomock.Any(), user.ListFilter{SortBy: user.SortCreatedAt, Status: user.StatusAll}, gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ user.ListFilter, fn func(*user.User) error) error {
+			for id := range int64(3) {
+				if err := fn(&user.User{ID: id + 1}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	repo.EXPECT().Stream(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("connection refused"))
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/stream?status=all", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Contains(t, lines[2], `"id":3`)
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/stream", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code, "errors before the first user keep their status")
+}
+
+func TestHandlerListCount(t *testing.T) {
+	engine, repo := newTestHandler(t)
+
+	filter := user.ListFilter{SortBy: user.SortCreatedAt, Limit: 2, Offset: 4}
+	repo.EXPECT().ListWithTotal(gomock.Any(), filter).Return([]*user.User{{ID: 5}, {ID: 6}}, int64(9), nil)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users?limit=2&offset=4&count=true", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "9", w.Header().Get("X-Total-Count"))
+
+	for _, query := range []string{"limit=-1", "limit=1001", "offset=x", "offset=2147483648", "count=maybe"} {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestHandlerListCursor(t *testing.T) {
+	engine, repo := newTestHandler(t)
+
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	repo.EXPECT().
+		List(gomock.Any(), user.ListFilter{SortBy: user.SortCreatedAt, Limit: 2}).
+		Return([]*user.User{{ID: 1}, {ID: 2, CreatedAt: created}}, nil)
+	repo.EXPECT().
+		List(gomock.Any(), user.ListFilter{SortBy: user.SortCreatedAt, Limit: 2, After: &user.Keyset{Key: created, ID: 2}}).
+		Return([]*user.User{{ID: 3}}, nil)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users?limit=2", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	cursor := w.Header().Get("X-Next-Cursor")
+	assert.NotEmpty(t, cursor)
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users?limit=2&cursor="+cursor, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("X-Next-Cursor"), "the last page has no next cursor")
+
+	for _, query := range []string{"cursor=forged", "cursor=" + cursor + "&offset=2", "cursor=" + cursor + "&order=asc"} {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users?limit=2&"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestHandlerListCursorFlagOff(t *testing.T) {
+	repo := usermock.NewMockUserRepository(gomock.NewController(t))
+	svc := user.NewService(repo, nil, nil, nil, nil, testutil.NopLogger{})
+	engine := gin.New()
+	flags := feature.New(&feature.Config{Flags: map[string]feature.Flag{
+		feature.CursorPagination: {Enabled: false},
+	}})
+	user.NewHandler(svc, &user.Config{IDType: user.IDSerial}, flags, nil, testutil.NopLogger{}).RegisterRoutes(engine)
+
+	repo.EXPECT().List(gomock.Any(), gomock.Any()).Return([]*user.User{{ID: 1}, {ID: 2}}, nil)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users?limit=2", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("X-Next-Cursor"))
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users?limit=2&cursor=abc", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandlerListCachedCount(t *testing.T) {
+	repo := usermock.NewMockUserRepository(gomock.NewController(t))
+	svc := user.NewService(repo, nil, nil, nil, nil, testutil.NopLogger{})
+	engine := gin.New()
+	cfg := &user.Config{IDType: user.IDSerial, CountCacheTTL: time.Minute}
+	user.NewHandler(svc, cfg, nil, nil, testutil.NopLogger{}).RegisterRoutes(engine)
+
+	repo.EXPECT().List(gomock.Any(), gomock.Any()).Return([]*user.User{{ID: 1}}, nil).Times(2)
+	repo.EXPECT().Count(gomock.Any(), gomock.Any()).Return(int64(1234), nil)
+
+	for _, page := range []string{"offset=0", "offset=1"} {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users?limit=1&count=true&"+page, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "1234", w.Header().Get("X-Total-Count"), page)
+	}
+}
@@ -0,0 +1,35 @@
+package user
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseListFilter(t *testing.T) {
+	parse := func(query string) (ListFilter, error) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/users?"+query, nil)
+		return parseListFilter(c)
+	}
+
+	f, err := parse("")
+	require.NoError(t, err)
+	assert.Equal(t, ListFilter{SortBy: SortCreatedAt}, f, "newest first by default")
+
+	f, err = parse("created_after=2024-01-02T03:04:05%2B02:00&sort=updated_at&order=asc")
+	require.NoError(t, err)
+	require.NotNil(t, f.CreatedAfter)
+	assert.Equal(t, time.Date(2024, 1, 2, 1, 4, 5, 0, time.UTC), *f.CreatedAfter)
+	assert.Equal(t, SortUpdatedAt, f.SortBy)
+	assert.True(t, f.Ascending)
+
+	for _, query := range []string{"updated_before=yesterday", "sort=name", "order=up"} {
+		_, err := parse(query)
+		assert.Error(t, err, query)
+	}
+}
@@ -0,0 +1,129 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/things-kit/example-db/internal/reqlog"
+	"github.com/things-kit/module/log"
+)
+
+// instrumentedDB wraps a DBTX, recording the duration of every statement and
+// logging the ones slower than the slow query threshold
+type instrumentedDB struct {
+	db      DBTX
+	metrics *Metrics
+	slow    *slowQueryLog
+}
+
+func (i instrumentedDB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	defer i.observe(ctx, sql, args, time.Now())
+	return i.db.Exec(ctx, sql, args...)
+}
+
+func (i instrumentedDB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	defer i.observe(ctx, sql, args, time.Now())
+	return i.db.Query(ctx, sql, args...)
+}
+
+// CopyFrom is timed as a COPY statement; its rows are not logged
+func (i instrumentedDB) CopyFrom(ctx context.Context, table pgx.Identifier, columns []string, rows pgx.CopyFromSource) (int64, error) {
+	defer i.observe(ctx, "COPY "+strings.Join(table, ".")+" FROM STDIN", nil, time.Now())
+	return i.db.CopyFrom(ctx, table, columns, rows)
+}
+
+// QueryRow defers execution until Scan, so the row is timed when it is scanned
+func (i instrumentedDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return timedRow{row: i.db.QueryRow(ctx, sql, args...), ctx: ctx, sql: sql, args: args, start: time.Now(), db: i}
+}
+
+func (i instrumentedDB) observe(ctx context.Context, sql string, args []any, start time.Time) {
+	i.metrics.observeQuery(sql, start)
+	i.slow.observe(ctx, sql, args, time.Since(start))
+}
+
+type timedRow struct {
+	row   pgx.Row
+	ctx   context.Context
+	sql   string
+	args  []any
+	start time.Time
+	db    instrumentedDB
+}
+
+func (r timedRow) Scan(dest ...any) error {
+	defer r.db.observe(r.ctx, r.sql, r.args, r.start)
+	return r.row.Scan(dest...)
+}
+
+// slowQueryLog logs statements that take longer than threshold, with the
+// request fields recorded in the statement's context. A nil *slowQueryLog logs
+// nothing.
+type slowQueryLog struct {
+	threshold atomic.Int64
+	log       log.Logger
+}
+
+func newSlowQueryLog(threshold time.Duration, logger log.Logger) *slowQueryLog {
+	s := &slowQueryLog{log: logger}
+	s.setThreshold(threshold)
+	return s
+}
+
+// setThreshold changes the threshold; 0 stops logging
+func (s *slowQueryLog) setThreshold(threshold time.Duration) {
+	s.threshold.Store(int64(threshold))
+}
+
+func (s *slowQueryLog) observe(ctx context.Context, sql string, args []any, elapsed time.Duration) {
+	if s == nil || s.log == nil {
+		return
+	}
+	threshold := time.Duration(s.threshold.Load())
+	if threshold <= 0 || elapsed < threshold {
+		return
+	}
+
+	reqlog.FromContext(ctx, s.log).Info("Slow query",
+		log.Field{Key: "query", Value: queryName(sql)},
+		log.Field{Key: "duration_ms", Value: elapsed.Milliseconds()},
+		log.Field{Key: "args", Value: redactArgs(args)},
+	)
+}
+
+var queryNamePattern = regexp.MustCompile(`--\s*name:\s*([\w.]+)`)
+
+// queryName returns the name in a statement's "-- name:" header, as written by
+// sqlc and the crud package, or its operation and table for other statements
+func queryName(sql string) string {
+	if m := queryNamePattern.FindStringSubmatch(sql); m != nil {
+		return m[1]
+	}
+	op, table := statementLabels(sql)
+	return op + " " + table
+}
+
+// redactArgs renders statement arguments for logging. Strings and bytes may
+// hold personal data such as names and emails, so only their type is kept.
+func redactArgs(args []any) []string {
+	out := make([]string, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case nil:
+			out[i] = "NULL"
+		case bool, int, int16, int32, int64, uint32, uint64, float32, float64:
+			out[i] = fmt.Sprint(v)
+		case time.Time:
+			out[i] = v.Format(time.RFC3339)
+		default:
+			out[i] = fmt.Sprintf("<%T>", v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,58 @@
+package user
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/reqlog"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/module/log"
+)
+
+// recordingLogger keeps the fields of every Info message
+type recordingLogger struct {
+	testutil.NopLogger
+	infos []map[string]any
+}
+
+func (l *recordingLogger) Info(_ string, fields ...log.Field) {
+	m := map[string]any{}
+	for _, f := range fields {
+		m[f.Key] = f.Value
+	}
+	l.infos = append(l.infos, m)
+}
+
+func TestSlowQueryLog(t *testing.T) {
+	logger := &recordingLogger{}
+	d := &blockingDB{started: make(chan struct{}), release: make(chan struct{})}
+	repo := newRepository(nil, d, NewMetrics())
+	repo.slow = newSlowQueryLog(10*time.Millisecond, logger)
+	repo.q = repo.queries(d)
+
+	go func() {
+		<-d.started
+		time.Sleep(20 * time.Millisecond)
+		close(d.release)
+	}()
+
+	ctx := reqlog.WithFields(context.Background(), log.Field{Key: "request_id", Value: "req-1"})
+	_, err := repo.GetByID(ctx, 42)
+	require.NoError(t, err)
+
+	require.Len(t, logger.infos, 1)
+	assert.Equal(t, "users.get", logger.infos[0]["query"])
+	assert.Equal(t, []string{"42", "<string>"}, logger.infos[0]["args"], "the tenant is redacted like any string")
+	assert.Equal(t, "req-1", logger.infos[0]["request_id"])
+}
+
+func TestRedactArgs(t *testing.T) {
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.Equal(t,
+		[]string{"42", "true", "2024-01-02T03:04:05Z", "NULL", "<string>", "<[]uint8>"},
+		redactArgs([]any{int64(42), true, at, nil, "john@example.com", []byte("x")}),
+	)
+}
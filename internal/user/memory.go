@@ -0,0 +1,773 @@
+package user
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/things-kit/example-db/internal/audit"
+	"github.com/things-kit/example-db/internal/prefs"
+	"github.com/things-kit/example-db/internal/tenant"
+)
+
+// MemoryRepository is a UserRepository keeping users in memory, for handler
+// tests and local demos without Postgres. It follows the contract of the SQL
+// Repository, which the tests in the usertest package check for both:
+// users belong to the tenant in the context, deletes are soft, emails are
+// unique per tenant among live users, and a failed WithTx leaves no trace.
+// It is safe for concurrent use. Transactions run one at a time and hold the
+// repository until they end, so fn must only use the repository it is given.
+type MemoryRepository struct {
+	mu *sync.Mutex
+	db *memoryDB
+	// inTx is set on the repository WithTx hands to fn, which runs with mu
+	// already held
+	inTx bool
+}
+
+// memoryDB is the state of a MemoryRepository
+type memoryDB struct {
+	users    map[int64]*memoryUser
+	profiles map[int64]*Profile
+	audit    []memoryAudit
+	lastID   int64
+}
+
+// memoryUser is a row of the users table
+type memoryUser struct {
+	User
+	TenantID  string
+	AvatarKey string
+	DeletedAt *time.Time
+}
+
+// memoryAudit is a row of the audit_log table
+type memoryAudit struct {
+	AuditEntry
+	TenantID string
+}
+
+// memoryTx is what Tx returns inside WithTx. It discards the statements run on
+// it, so events written to the outbox are dropped; its other methods panic.
+type memoryTx struct {
+	pgx.Tx
+}
+
+// Exec implements pgx.Tx
+func (memoryTx) Exec(context.Context, string, ...any) (pgconn.CommandTag, error) {
+	return pgconn.NewCommandTag("INSERT 0 1"), nil
+}
+
+// NewMemoryRepository creates an empty in-memory repository
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		mu: &sync.Mutex{},
+		db: &memoryDB{users: map[int64]*memoryUser{}, profiles: map[int64]*Profile{}},
+	}
+}
+
+// lock holds the repository for one call and returns the function releasing
+// it. Inside WithTx the transaction already holds it.
+func (r *MemoryRepository) lock() func() {
+	if r.inTx {
+		return func() {}
+	}
+	r.mu.Lock()
+	return r.mu.Unlock
+}
+
+// memoryNow returns the current time at the precision Postgres stores
+func memoryNow() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// WithTx runs fn with the repository held, undoing every change fn made if it
+// returns an error. If r is already in a transaction, fn joins it.
+func (r *MemoryRepository) WithTx(ctx context.Context, fn func(repo UserRepository) error) error {
+	if r.inTx {
+		return fn(r)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	saved := r.db.clone()
+	if err := fn(&MemoryRepository{mu: r.mu, db: r.db, inTx: true}); err != nil {
+		*r.db = *saved
+		return err
+	}
+	return nil
+}
+
+// Tx returns a transaction discarding its statements inside WithTx, or nil
+func (r *MemoryRepository) Tx() pgx.Tx {
+	if !r.inTx {
+		return nil
+	}
+	return memoryTx{}
+}
+
+// Create creates a new user
+func (r *MemoryRepository) Create(ctx context.Context, req CreateUserRequest) (*User, error) {
+	defer r.lock()()
+
+	id := tenant.FromContext(ctx)
+	if r.db.emailTaken(id, req.Email, 0) {
+		return nil, ErrEmailTaken
+	}
+	return r.db.insert(id, req, memoryNow()).copy(), nil
+}
+
+// CopyFrom inserts users in bulk, all or none of them
+func (r *MemoryRepository) CopyFrom(ctx context.Context, reqs []CreateUserRequest) (int64, error) {
+	defer r.lock()()
+
+	id := tenant.FromContext(ctx)
+	seen := make(map[string]bool, len(reqs))
+	for _, req := range reqs {
+		if seen[req.Email] || r.db.emailTaken(id, req.Email, 0) {
+			return 0, ErrEmailTaken
+		}
+		seen[req.Email] = true
+	}
+
+	ts := memoryNow()
+	for _, req := range reqs {
+		r.db.insert(id, req, ts)
+	}
+	return int64(len(reqs)), nil
+}
+
+// GetByID retrieves a user by ID
+func (r *MemoryRepository) GetByID(ctx context.Context, id int64) (*User, error) {
+	defer r.lock()()
+
+	u, err := r.db.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return u.copy(), nil
+}
+
+// GetForUpdate retrieves a user; the transaction already holds every row
+func (r *MemoryRepository) GetForUpdate(ctx context.Context, id int64) (*User, error) {
+	return r.GetByID(ctx, id)
+}
+
+// GetByEmail retrieves a user by email
+func (r *MemoryRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
+	defer r.lock()()
+
+	id := tenant.FromContext(ctx)
+	for _, u := range r.db.users {
+		if u.live(id) && u.Email == email {
+			return u.copy(), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// GetIDByUUID resolves the UUID key of a user to its ID
+func (r *MemoryRepository) GetIDByUUID(ctx context.Context, key uuid.UUID) (int64, error) {
+	defer r.lock()()
+
+	id := tenant.FromContext(ctx)
+	for _, u := range r.db.users {
+		if u.live(id) && u.UUID == key {
+			return u.ID, nil
+		}
+	}
+	return 0, ErrNotFound
+}
+
+// List retrieves the users matching the filter
+func (r *MemoryRepository) List(ctx context.Context, f ListFilter) ([]*User, error) {
+	defer r.lock()()
+
+	users, err := r.db.list(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	return paginate(users, f), nil
+}
+
+// ListWithTotal retrieves a page of the users matching the filter and the
+// number of users matching it regardless of Limit, Offset and After
+func (r *MemoryRepository) ListWithTotal(ctx context.Context, f ListFilter) ([]*User, int64, error) {
+	defer r.lock()()
+
+	users, err := r.db.list(ctx, f)
+	if err != nil {
+		return nil, 0, err
+	}
+	return paginate(users, f), int64(len(users)), nil
+}
+
+// Count returns the number of users matching the filter, ignoring its Limit,
+// Offset, After and order
+func (r *MemoryRepository) Count(ctx context.Context, f ListFilter) (int64, error) {
+	defer r.lock()()
+
+	users, err := r.db.list(ctx, f)
+	return int64(len(users)), err
+}
+
+// Stream calls fn for every user matching the filter, in the order of List.
+// The users are copied first, so fn runs without holding the repository.
+func (r *MemoryRepository) Stream(ctx context.Context, f ListFilter, fn func(*User) error) error {
+	users, err := r.List(ctx, f)
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		if err := fn(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Update updates a user
+func (r *MemoryRepository) Update(ctx context.Context, id int64, req CreateUserRequest) (*User, error) {
+	defer r.lock()()
+
+	u, err := r.db.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if r.db.emailTaken(u.TenantID, req.Email, id) {
+		return nil, ErrEmailTaken
+	}
+
+	u.Name, u.Email, u.UpdatedAt = req.Name, req.Email, memoryNow()
+	return u.copy(), nil
+}
+
+// Delete soft-deletes a user
+func (r *MemoryRepository) Delete(ctx context.Context, id int64) (string, error) {
+	defer r.lock()()
+
+	u, err := r.db.get(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	ts := memoryNow()
+	u.DeletedAt, u.UpdatedAt = &ts, ts
+	return "", nil
+}
+
+// SetAdmin sets whether a user has administrator rights
+func (r *MemoryRepository) SetAdmin(ctx context.Context, id int64, admin bool) error {
+	_, err := r.change(ctx, id, func(u *memoryUser) { u.IsAdmin = admin })
+	return err
+}
+
+// SetPreferences replaces a user's preferences
+func (r *MemoryRepository) SetPreferences(ctx context.Context, id int64, p prefs.Preferences) (*User, error) {
+	return r.change(ctx, id, func(u *memoryUser) { u.Preferences = maps.Clone(p) })
+}
+
+// SetStatus changes the account status of a user
+func (r *MemoryRepository) SetStatus(ctx context.Context, id int64, status string) (*User, error) {
+	return r.change(ctx, id, func(u *memoryUser) { u.Status = status })
+}
+
+// SetAvatar stores the object key of a user's avatar
+func (r *MemoryRepository) SetAvatar(ctx context.Context, id int64, key string) error {
+	_, err := r.change(ctx, id, func(u *memoryUser) { u.AvatarKey = key })
+	return err
+}
+
+// change applies fn to a live user and touches its updated_at
+func (r *MemoryRepository) change(ctx context.Context, id int64, fn func(u *memoryUser)) (*User, error) {
+	defer r.lock()()
+
+	u, err := r.db.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	fn(u)
+	u.UpdatedAt = memoryNow()
+	return u.copy(), nil
+}
+
+// GetAvatarKey retrieves the object key of a user's avatar, or "" if the
+// user has none
+func (r *MemoryRepository) GetAvatarKey(ctx context.Context, id int64) (string, error) {
+	defer r.lock()()
+
+	u, err := r.db.get(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	return u.AvatarKey, nil
+}
+
+// Erase anonymizes a user, live or soft-deleted, deletes their profile and
+// clears the snapshots of their audit entries. It returns the key of the
+// avatar the user had, or "".
+func (r *MemoryRepository) Erase(ctx context.Context, id int64) (string, error) {
+	defer r.lock()()
+
+	tenantID := tenant.FromContext(ctx)
+	u, ok := r.db.users[id]
+	if !ok || u.TenantID != tenantID {
+		return "", ErrNotFound
+	}
+
+	key := u.AvatarKey
+	ts := memoryNow()
+	u.Name, u.Email, u.Preferences, u.AvatarKey = ErasedName, erasedEmail(id), prefs.Preferences{}, ""
+	u.Status, u.UpdatedAt = StatusDeactivated, ts
+	if u.DeletedAt == nil {
+		u.DeletedAt = &ts
+	}
+
+	delete(r.db.profiles, id)
+	for i := range r.db.audit {
+		if e := &r.db.audit[i]; e.UserID == id && e.TenantID == tenantID {
+			e.Old, e.New = nil, nil
+		}
+	}
+	return key, nil
+}
+
+// AddAudit records a change to a user by the actor in ctx
+func (r *MemoryRepository) AddAudit(ctx context.Context, id int64, action string, before, after any) error {
+	oldData, err := snapshot(before)
+	if err != nil {
+		return err
+	}
+	newData, err := snapshot(after)
+	if err != nil {
+		return err
+	}
+
+	defer r.lock()()
+
+	r.db.audit = append(r.db.audit, memoryAudit{
+		AuditEntry: AuditEntry{
+			ID:        int64(len(r.db.audit) + 1),
+			UserID:    id,
+			Action:    action,
+			Actor:     audit.Actor(ctx),
+			Old:       oldData,
+			New:       newData,
+			CreatedAt: memoryNow(),
+		},
+		TenantID: tenant.FromContext(ctx),
+	})
+	return nil
+}
+
+// ListAudit retrieves up to limit changes to a user, newest first
+func (r *MemoryRepository) ListAudit(ctx context.Context, id int64, limit int) ([]AuditEntry, error) {
+	defer r.lock()()
+
+	tenantID := tenant.FromContext(ctx)
+	entries := make([]AuditEntry, 0)
+	for i := len(r.db.audit) - 1; i >= 0 && len(entries) < limit; i-- {
+		if e := r.db.audit[i]; e.UserID == id && e.TenantID == tenantID {
+			entries = append(entries, e.AuditEntry)
+		}
+	}
+	return entries, nil
+}
+
+// MoveAudit hands the audit entries of user from to user to
+func (r *MemoryRepository) MoveAudit(ctx context.Context, from, to int64) (int64, error) {
+	defer r.lock()()
+
+	tenantID := tenant.FromContext(ctx)
+	var n int64
+	for i := range r.db.audit {
+		if e := &r.db.audit[i]; e.UserID == from && e.TenantID == tenantID {
+			e.UserID = to
+			n++
+		}
+	}
+	return n, nil
+}
+
+// ListChanges returns up to f.Limit users with audit entries after the
+// position of f, in the order of their latest entry. Entries are written
+// under the lock, so their IDs are in commit order.
+func (r *MemoryRepository) ListChanges(ctx context.Context, f ChangeFilter) ([]Change, error) {
+	defer r.lock()()
+
+	tenantID := tenant.FromContext(ctx)
+	if !f.Since.IsZero() && f.Since.Before(f.NotBefore) {
+		return nil, ErrResyncRequired
+	}
+	if f.AfterSeq > 0 {
+		i := f.AfterSeq - 1
+		if i >= int64(len(r.db.audit)) || r.db.audit[i].TenantID != tenantID || r.db.audit[i].CreatedAt.Before(f.NotBefore) {
+			return nil, ErrResyncRequired
+		}
+	}
+
+	type changed struct {
+		seq     int64
+		created bool
+		latest  AuditEntry
+	}
+	users := map[int64]*changed{}
+	for _, e := range r.db.audit {
+		if e.TenantID != tenantID || e.ID <= f.AfterSeq || !e.CreatedAt.After(f.Since) {
+			continue
+		}
+		c := users[e.UserID]
+		if c == nil {
+			c = &changed{}
+			users[e.UserID] = c
+		}
+		if e.ID > c.seq {
+			c.seq, c.latest = e.ID, e.AuditEntry
+		}
+		c.created = c.created || e.Action == AuditCreate
+	}
+
+	ids := slices.SortedFunc(maps.Keys(users), func(a, b int64) int {
+		return cmp.Compare(users[a].seq, users[b].seq)
+	})
+	if len(ids) > f.Limit {
+		ids = ids[:f.Limit]
+	}
+
+	changes := make([]Change, 0, len(ids))
+	for _, id := range ids {
+		c := users[id]
+		u, ok := r.db.users[id]
+		if !ok || u.TenantID != tenantID {
+			changes = append(changes, removedChange(c.seq, id, snapshotUUID(c.latest), c.latest.CreatedAt))
+			continue
+		}
+		var deletedAt *time.Time
+		if u.DeletedAt != nil {
+			at := *u.DeletedAt
+			deletedAt = &at
+		}
+		changes = append(changes, newChange(c.seq, c.created, u.copy(), deletedAt))
+	}
+	return changes, nil
+}
+
+// snapshotUUID returns the UUID recorded in the snapshots of an audit entry,
+// or the zero UUID for erased entries
+func snapshotUUID(e AuditEntry) uuid.UUID {
+	for _, data := range []json.RawMessage{e.Old, e.New} {
+		var u struct {
+			UUID uuid.UUID `json:"uuid"`
+		}
+		if json.Unmarshal(data, &u) == nil && u.UUID != uuid.Nil {
+			return u.UUID
+		}
+	}
+	return uuid.Nil
+}
+
+// GetWithProfile retrieves a user and their profile. The profile is nil if
+// the user has none.
+func (r *MemoryRepository) GetWithProfile(ctx context.Context, id int64) (*User, *Profile, error) {
+	defer r.lock()()
+
+	u, err := r.db.get(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return u.copy(), r.db.profile(id), nil
+}
+
+// Profiles returns the repository of user profiles
+func (r *MemoryRepository) Profiles() ProfileRepository {
+	return memoryProfiles{r: r}
+}
+
+// memoryProfiles implements ProfileRepository on a MemoryRepository, so it
+// joins the repository's transaction
+type memoryProfiles struct {
+	r *MemoryRepository
+}
+
+// Get retrieves the profile of a user, returning ErrNotFound if they have none
+func (p memoryProfiles) Get(ctx context.Context, userID int64) (*Profile, error) {
+	defer p.r.lock()()
+
+	if _, err := p.r.db.get(ctx, userID); err != nil {
+		return nil, err
+	}
+	profile := p.r.db.profile(userID)
+	if profile == nil {
+		return nil, ErrNotFound
+	}
+	return profile, nil
+}
+
+// List retrieves the profiles of the users, keyed by user ID. Users without a
+// profile are missing from the map.
+func (p memoryProfiles) List(ctx context.Context, userIDs []int64) (map[int64]*Profile, error) {
+	defer p.r.lock()()
+
+	profiles := make(map[int64]*Profile, len(userIDs))
+	for _, id := range userIDs {
+		if u, ok := p.r.db.users[id]; ok && u.TenantID == tenant.FromContext(ctx) {
+			if profile := p.r.db.profile(id); profile != nil {
+				profiles[id] = profile
+			}
+		}
+	}
+	return profiles, nil
+}
+
+// Upsert creates or replaces the profile of a user
+func (p memoryProfiles) Upsert(ctx context.Context, userID int64, req ProfileRequest) (*Profile, error) {
+	defer p.r.lock()()
+
+	if _, err := p.r.db.get(ctx, userID); err != nil {
+		return nil, err
+	}
+	p.r.db.profiles[userID] = &Profile{
+		UserID:    userID,
+		Phone:     req.Phone,
+		Address:   req.Address,
+		Bio:       req.Bio,
+		AvatarURL: req.AvatarURL,
+		UpdatedAt: memoryNow(),
+	}
+	return p.r.db.profile(userID), nil
+}
+
+// clone returns a copy of the state that later changes don't affect
+func (db *memoryDB) clone() *memoryDB {
+	c := &memoryDB{
+		users:    make(map[int64]*memoryUser, len(db.users)),
+		profiles: make(map[int64]*Profile, len(db.profiles)),
+		audit:    slices.Clone(db.audit),
+		lastID:   db.lastID,
+	}
+	for id, u := range db.users {
+		row := *u
+		c.users[id] = &row
+	}
+	for id, p := range db.profiles {
+		profile := *p
+		c.profiles[id] = &profile
+	}
+	return c
+}
+
+// insert adds a user with the column defaults of the users table
+func (db *memoryDB) insert(tenantID string, req CreateUserRequest, ts time.Time) *memoryUser {
+	db.lastID++
+	u := &memoryUser{
+		User: User{
+			ID:          db.lastID,
+			Name:        req.Name,
+			Email:       req.Email,
+			CreatedAt:   ts,
+			UpdatedAt:   ts,
+			UUID:        uuid.Must(uuid.NewV7()),
+			Preferences: prefs.Preferences{},
+			Status:      StatusActive,
+		},
+		TenantID: tenantID,
+	}
+	db.users[u.ID] = u
+	return u
+}
+
+// get returns the live user with the ID in the tenant of ctx
+func (db *memoryDB) get(ctx context.Context, id int64) (*memoryUser, error) {
+	u, ok := db.users[id]
+	if !ok || !u.live(tenant.FromContext(ctx)) {
+		return nil, ErrNotFound
+	}
+	return u, nil
+}
+
+// emailTaken reports whether a live user of the tenant other than except
+// has the email
+func (db *memoryDB) emailTaken(tenantID, email string, except int64) bool {
+	for _, u := range db.users {
+		if u.live(tenantID) && u.Email == email && u.ID != except {
+			return true
+		}
+	}
+	return false
+}
+
+// profile returns a copy of the profile of a user, or nil
+func (db *memoryDB) profile(userID int64) *Profile {
+	p, ok := db.profiles[userID]
+	if !ok {
+		return nil
+	}
+	profile := *p
+	return &profile
+}
+
+// list returns copies of the users matching the filter in the order of
+// ListUsers, ignoring Limit and Offset
+func (db *memoryDB) list(ctx context.Context, f ListFilter) ([]*User, error) {
+	var contains any
+	if len(f.Preferences) > 0 {
+		var err error
+		if contains, err = normalizeJSON(f.Preferences); err != nil {
+			return nil, fmt.Errorf("failed to encode preference filter: %w", err)
+		}
+	}
+
+	tenantID := tenant.FromContext(ctx)
+	var users []*User
+	for _, u := range db.users {
+		if !u.live(tenantID) || !u.matches(f) {
+			continue
+		}
+		if contains != nil {
+			have, err := normalizeJSON(u.Preferences)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode preferences: %w", err)
+			}
+			if !jsonContains(have, contains) {
+				continue
+			}
+		}
+		users = append(users, u.copy())
+	}
+
+	slices.SortFunc(users, func(a, b *User) int {
+		return comparePosition(f, sortKey(f, a), a.ID, sortKey(f, b), b.ID)
+	})
+	if f.After != nil {
+		users = slices.DeleteFunc(users, func(u *User) bool {
+			return comparePosition(f, sortKey(f, u), u.ID, f.After.Key, f.After.ID) <= 0
+		})
+	}
+	return users, nil
+}
+
+// paginate applies the Limit and Offset of the filter
+func paginate(users []*User, f ListFilter) []*User {
+	if f.Offset >= len(users) {
+		return nil
+	}
+	users = users[f.Offset:]
+	if f.Limit > 0 && f.Limit < len(users) {
+		users = users[:f.Limit]
+	}
+	return users
+}
+
+// sortKey returns the value of the sort field of u
+func sortKey(f ListFilter, u *User) time.Time {
+	if f.SortBy == SortUpdatedAt {
+		return u.UpdatedAt
+	}
+	return u.CreatedAt
+}
+
+// comparePosition orders two users as ListUsers does: by the sort field in
+// the direction of the filter, then by ascending ID
+func comparePosition(f ListFilter, aKey time.Time, aID int64, bKey time.Time, bID int64) int {
+	c := aKey.Compare(bKey)
+	if !f.Ascending {
+		c = -c
+	}
+	if c != 0 {
+		return c
+	}
+	switch {
+	case aID < bID:
+		return -1
+	case aID > bID:
+		return 1
+	}
+	return 0
+}
+
+// live reports whether the user belongs to the tenant and isn't deleted
+func (u *memoryUser) live(tenantID string) bool {
+	return u.TenantID == tenantID && u.DeletedAt == nil
+}
+
+// matches applies the time and status conditions of the filter
+func (u *memoryUser) matches(f ListFilter) bool {
+	switch {
+	case f.CreatedAfter != nil && u.CreatedAt.Before(*f.CreatedAfter),
+		f.CreatedBefore != nil && !u.CreatedAt.Before(*f.CreatedBefore),
+		f.UpdatedAfter != nil && u.UpdatedAt.Before(*f.UpdatedAfter),
+		f.UpdatedBefore != nil && !u.UpdatedAt.Before(*f.UpdatedBefore):
+		return false
+	}
+
+	switch f.Status {
+	case "":
+		return u.Status != StatusSuspended
+	case StatusAll:
+		return true
+	default:
+		return u.Status == f.Status
+	}
+}
+
+// copy returns the User of the row, which the caller may modify
+func (u *memoryUser) copy() *User {
+	user := u.User
+	user.Preferences = maps.Clone(u.Preferences)
+	return &user
+}
+
+// normalizeJSON decodes the JSON encoding of v, so values compare as they do
+// in a jsonb column
+func normalizeJSON(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var out any
+	err = dec.Decode(&out)
+	return out, err
+}
+
+// jsonContains implements the jsonb @> operator on decoded JSON values
+func jsonContains(have, want any) bool {
+	switch want := want.(type) {
+	case map[string]any:
+		obj, ok := have.(map[string]any)
+		if !ok {
+			return false
+		}
+		for k, v := range want {
+			if got, ok := obj[k]; !ok || !jsonContains(got, v) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		arr, ok := have.([]any)
+		if !ok {
+			return false
+		}
+		for _, v := range want {
+			if !slices.ContainsFunc(arr, func(got any) bool { return jsonContains(got, v) }) {
+				return false
+			}
+		}
+		return true
+	default:
+		return have == want
+	}
+}
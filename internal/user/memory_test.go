@@ -0,0 +1,67 @@
+package user_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/mail"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/user"
+	"github.com/things-kit/example-db/internal/user/usertest"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	usertest.TestRepository(t, func(t *testing.T) user.UserRepository {
+		return user.NewMemoryRepository()
+	})
+}
+
+func TestMemoryRepositoryConcurrentCreates(t *testing.T) {
+	repo := user.NewMemoryRepository()
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = repo.WithTx(ctx, func(tx user.UserRepository) error {
+				_, err := tx.Create(ctx, user.CreateUserRequest{Name: "User", Email: fmt.Sprintf("user%d@example.com", i)})
+				return err
+			})
+		}()
+	}
+	wg.Wait()
+
+	n, err := repo.Count(ctx, user.ListFilter{})
+	require.NoError(t, err)
+	assert.EqualValues(t, 20, n)
+}
+
+// TestMemoryRepositoryHandler runs the API on the in-memory repository,
+// which needs no expectations for every call the way a mock does
+func TestMemoryRepositoryHandler(t *testing.T) {
+	queue := mail.NewQueue(nil, &mail.Config{QueueSize: 1}, testutil.NopLogger{})
+	svc := user.NewService(user.NewMemoryRepository(), nil, queue, nil, nil, testutil.NopLogger{})
+	engine := gin.New()
+	user.NewHandler(svc, &user.Config{IDType: user.IDSerial}, nil, nil, testutil.NopLogger{}).RegisterRoutes(engine)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"John","email":"john@example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	engine.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"email":"john@example.com"`)
+}
@@ -0,0 +1,138 @@
+package user
+
+import (
+	"context"
+	"errors"
+
+	"github.com/things-kit/example-db/internal/events"
+	"github.com/things-kit/example-db/internal/i18n"
+	"github.com/things-kit/example-db/internal/reqlog"
+	"github.com/things-kit/module/log"
+)
+
+// mergeData is the data of a UserMerged event
+type mergeData struct {
+	MergedID int64 `json:"merged_id"`
+}
+
+// Merge folds the duplicate user into the survivor, in one transaction. The
+// survivor keeps its fields and takes over what it lacks from the duplicate:
+// the profile and the avatar. The duplicate's audit entries are moved to the
+// survivor and the duplicate is deleted. The merge is recorded in the audit
+// log of the survivor, the deletion in that of the duplicate, and as a
+// UserDeleted event for the duplicate and a UserMerged event for the
+// survivor.
+func (s *Service) Merge(ctx context.Context, survivorID, duplicateID int64) (*User, error) {
+	if survivorID == duplicateID {
+		return nil, i18n.Wrap(ErrInvalid, "a user can't be merged into itself")
+	}
+
+	var survivor *User
+	var orphan, deleted string
+	err := s.repo.WithTx(ctx, func(repo UserRepository) error {
+		// Lock both rows in ID order, so concurrent merges of the same pair
+		// can't deadlock
+		locked := make(map[int64]*User, 2)
+		for _, id := range []int64{min(survivorID, duplicateID), max(survivorID, duplicateID)} {
+			u, err := repo.GetForUpdate(ctx, id)
+			if err != nil {
+				return err
+			}
+			locked[id] = u
+		}
+		survivor = locked[survivorID]
+		duplicate := locked[duplicateID]
+
+		if err := mergeProfile(ctx, repo, survivorID, duplicateID); err != nil {
+			return err
+		}
+
+		var err error
+		if orphan, err = mergeAvatar(ctx, repo, survivorID, duplicateID); err != nil {
+			return err
+		}
+
+		if _, err := repo.MoveAudit(ctx, duplicateID, survivorID); err != nil {
+			return err
+		}
+		// mergeAvatar cleared the duplicate's avatar, so a hard delete only
+		// returns a key set since
+		if deleted, err = repo.Delete(ctx, duplicateID); err != nil {
+			return err
+		}
+		// The duplicate keeps the entry of its deletion, for the changes feed
+		if err := repo.AddAudit(ctx, duplicateID, AuditDelete, duplicate, nil); err != nil {
+			return err
+		}
+		if survivor, err = repo.GetByID(ctx, survivorID); err != nil {
+			return err
+		}
+		if err := repo.AddAudit(ctx, survivorID, AuditMerge, duplicate, survivor); err != nil {
+			return err
+		}
+		if err := s.recordEvent(ctx, repo.Tx(), events.UserDeleted, duplicateID, nil); err != nil {
+			return err
+		}
+		return s.recordEvent(ctx, repo.Tx(), events.UserMerged, survivorID, mergeData{MergedID: duplicateID})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range []string{orphan, deleted} {
+		if key == "" {
+			continue
+		}
+		if err := s.store.Delete(ctx, key); err != nil {
+			reqlog.FromContext(ctx, s.log).Error("Failed to delete avatar of merged user", err, log.Field{Key: "key", Value: key})
+		}
+	}
+
+	return survivor, nil
+}
+
+// mergeProfile gives the survivor the duplicate's profile if it has none
+func mergeProfile(ctx context.Context, repo UserRepository, survivorID, duplicateID int64) error {
+	_, err := repo.Profiles().Get(ctx, survivorID)
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	profile, err := repo.Profiles().Get(ctx, duplicateID)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.Profiles().Upsert(ctx, survivorID, ProfileRequest{
+		Phone:     profile.Phone,
+		Address:   profile.Address,
+		Bio:       profile.Bio,
+		AvatarURL: profile.AvatarURL,
+	})
+	return err
+}
+
+// mergeAvatar gives the survivor the duplicate's avatar if it has none. It
+// returns the key of the duplicate's avatar when the survivor kept its own,
+// for the caller to delete once the transaction committed.
+func mergeAvatar(ctx context.Context, repo UserRepository, survivorID, duplicateID int64) (string, error) {
+	key, err := repo.GetAvatarKey(ctx, duplicateID)
+	if err != nil || key == "" {
+		return "", err
+	}
+
+	own, err := repo.GetAvatarKey(ctx, survivorID)
+	if err != nil {
+		return "", err
+	}
+	if err := repo.SetAvatar(ctx, duplicateID, ""); err != nil {
+		return "", err
+	}
+	if own != "" {
+		return key, nil
+	}
+	return "", repo.SetAvatar(ctx, survivorID, key)
+}
@@ -0,0 +1,84 @@
+package user_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/user"
+)
+
+func TestMerge(t *testing.T) {
+	engine, svc, survivor := newExportEngine(t)
+	ctx := context.Background()
+
+	// The duplicate has a profile too, which the survivor keeps its own over
+	duplicate, err := svc.Create(ctx, user.CreateUserRequest{Name: "Ann Smith", Email: "ann.smith@example.com"})
+	require.NoError(t, err)
+	_, err = svc.UpdateProfile(ctx, duplicate.ID, user.ProfileRequest{Phone: "+1 555 0199"})
+	require.NoError(t, err)
+
+	merge := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/admin/users/merge", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		engine.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := merge(`{"survivor_id": 1, "duplicate_id": "2"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp user.UserResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, survivor.Email, resp.Email)
+
+	_, err = svc.GetByID(ctx, duplicate.ID)
+	assert.ErrorIs(t, err, user.ErrNotFound)
+
+	_, profile, err := svc.GetWithProfile(ctx, survivor.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "+1 555 0100", profile.Phone)
+
+	entries, err := svc.Audit(ctx, survivor.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 5, "the duplicate's entries are moved")
+	assert.Equal(t, user.AuditMerge, entries[0].Action)
+	assert.Contains(t, string(entries[0].Old), fmt.Sprintf(`"id":%d`, duplicate.ID))
+	assert.NotContains(t, string(entries[0].Old), duplicate.Email, "snapshots are redacted")
+
+	entries, err = svc.Audit(ctx, duplicate.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, user.AuditDelete, entries[0].Action)
+
+	t.Run("Errors", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, merge(`{"survivor_id": 1, "duplicate_id": 1}`).Code)
+		assert.Equal(t, http.StatusBadRequest, merge(`{"survivor_id": 1, "duplicate_id": "x"}`).Code)
+		assert.Equal(t, http.StatusBadRequest, merge(`{"survivor_id": 1}`).Code)
+		assert.Equal(t, http.StatusNotFound, merge(`{"survivor_id": 1, "duplicate_id": 2}`).Code, "already merged")
+	})
+}
+
+func TestMergeTakesProfile(t *testing.T) {
+	_, svc, _ := newExportEngine(t)
+	ctx := context.Background()
+
+	survivor, err := svc.Create(ctx, user.CreateUserRequest{Name: "Bob", Email: "bob@example.com"})
+	require.NoError(t, err)
+
+	merged, err := svc.Merge(ctx, survivor.ID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, survivor.ID, merged.ID)
+
+	_, profile, err := svc.GetWithProfile(ctx, survivor.ID)
+	require.NoError(t, err)
+	require.NotNil(t, profile)
+	assert.Equal(t, "+1 555 0100", profile.Phone)
+	assert.Equal(t, "Hi", profile.Bio)
+}
@@ -0,0 +1,83 @@
+package user
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics counts repository activity.
+// It implements expvar.Var so it can be published under /debug/vars, and
+// exposes Prometheus collectors through Collectors.
+type Metrics struct {
+	// GetByIDQueries counts GetByID lookups that actually hit the database
+	GetByIDQueries atomic.Int64
+	// GetByIDCoalesced counts GetByID lookups served by an in-flight query for the same ID
+	GetByIDCoalesced atomic.Int64
+	// QueryDuration observes the duration of every statement by operation and table
+	QueryDuration *prometheus.HistogramVec
+}
+
+// NewMetrics creates a new set of repository metrics
+func NewMetrics() *Metrics {
+	return &Metrics{
+		QueryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "db_query_duration_seconds",
+			Help:    "Duration of repository statements by operation and table.",
+			Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"operation", "table"}),
+	}
+}
+
+// String returns the counters as a JSON object
+func (m *Metrics) String() string {
+	b, _ := json.Marshal(map[string]int64{
+		"get_by_id_queries_total":   m.GetByIDQueries.Load(),
+		"get_by_id_coalesced_total": m.GetByIDCoalesced.Load(),
+	})
+	return string(b)
+}
+
+// Collectors returns the Prometheus collectors for these metrics
+func (m *Metrics) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.QueryDuration,
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "user_get_by_id_queries_total",
+			Help: "GetByID lookups that hit the database.",
+		}, func() float64 { return float64(m.GetByIDQueries.Load()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "user_get_by_id_coalesced_total",
+			Help: "GetByID lookups served by an in-flight query for the same ID.",
+		}, func() float64 { return float64(m.GetByIDCoalesced.Load()) }),
+	}
+}
+
+// observeQuery records the duration of a statement started at start
+func (m *Metrics) observeQuery(query string, start time.Time) {
+	op, table := statementLabels(query)
+	m.QueryDuration.WithLabelValues(op, table).Observe(time.Since(start).Seconds())
+}
+
+var tablePattern = regexp.MustCompile(`(?i)\b(?:copy|from|into|update)\s+([a-z_][a-z0-9_]*)`)
+
+// statementLabels derives low-cardinality labels from a SQL statement: the
+// leading keyword and the first table it touches. Leading comment lines, such
+// as the "-- name:" header of sqlc queries, are skipped.
+func statementLabels(query string) (op, table string) {
+	op, table = "unknown", "unknown"
+	for _, line := range strings.Split(query, "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 && !strings.HasPrefix(fields[0], "--") {
+			op = strings.ToLower(fields[0])
+			break
+		}
+	}
+	if m := tablePattern.FindStringSubmatch(query); m != nil {
+		table = strings.ToLower(m[1])
+	}
+	return op, table
+}
@@ -0,0 +1,29 @@
+package user
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatementLabels(t *testing.T) {
+	tests := []struct {
+		query string
+		op    string
+		table string
+	}{
+		{"\n\t\tSELECT id FROM users WHERE id = $1", "select", "users"},
+		{"INSERT INTO outbox (id) VALUES ($1)", "insert", "outbox"},
+		{"UPDATE users SET name = $1", "update", "users"},
+		{"DELETE FROM users WHERE id IN (SELECT id FROM users)", "delete", "users"},
+		{"-- name: GetUser :one\nSELECT id FROM users WHERE id = $1", "select", "users"},
+		{"COPY users FROM STDIN", "copy", "users"},
+		{"", "unknown", "unknown"},
+	}
+
+	for _, tt := range tests {
+		op, table := statementLabels(tt.query)
+		assert.Equal(t, tt.op, op, tt.query)
+		assert.Equal(t, tt.table, table, tt.query)
+	}
+}
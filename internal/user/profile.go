@@ -0,0 +1,170 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/things-kit/example-db/internal/tenant"
+	"github.com/things-kit/example-db/internal/user/userdb"
+)
+
+// Profile is the optional extended information of a user, stored one-to-one
+// in the profiles table
+type Profile struct {
+	UserID    int64     `json:"user_id"`
+	Phone     string    `json:"phone"`
+	Address   string    `json:"address"`
+	Bio       string    `json:"bio"`
+	AvatarURL string    `json:"avatar_url"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// profileRepository implements ProfileRepository on the connection of a
+// Repository, so it joins the Repository's transaction
+type profileRepository struct {
+	r *Repository
+}
+
+// Profiles returns the repository of user profiles
+func (r *Repository) Profiles() ProfileRepository {
+	return profileRepository{r: r}
+}
+
+// Get retrieves the profile of a user, returning ErrNotFound if they have none
+func (p profileRepository) Get(ctx context.Context, userID int64) (*Profile, error) {
+	ctx, span := tracer.Start(ctx, "user.ProfileRepository.Get")
+	defer span.End()
+
+	var row userdb.GetProfileRow
+	err := p.r.read(ctx, "GetProfile", func(ctx context.Context, q conn) (err error) {
+		row, err = q.GetProfile(ctx, userdb.GetProfileParams{
+			UserID:   userID,
+			TenantID: tenant.FromContext(ctx),
+		})
+		return err
+	})
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get profile: %w", err)
+	}
+
+	profile := Profile(row)
+	return &profile, nil
+}
+
+// List retrieves the profiles of the users in one query, keyed by user ID.
+// Users without a profile are missing from the map.
+func (p profileRepository) List(ctx context.Context, userIDs []int64) (map[int64]*Profile, error) {
+	ctx, span := tracer.Start(ctx, "user.ProfileRepository.List")
+	defer span.End()
+
+	profiles := make(map[int64]*Profile, len(userIDs))
+	if len(userIDs) == 0 {
+		return profiles, nil
+	}
+
+	var rows []userdb.ListProfilesRow
+	err := p.r.read(ctx, "ListProfiles", func(ctx context.Context, q conn) (err error) {
+		rows, err = q.ListProfiles(ctx, userdb.ListProfilesParams{
+			UserIds:  userIDs,
+			TenantID: tenant.FromContext(ctx),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list profiles: %w", err)
+	}
+
+	for _, row := range rows {
+		profile := Profile(row)
+		profiles[profile.UserID] = &profile
+	}
+
+	return profiles, nil
+}
+
+// Upsert creates or replaces the profile of a user
+func (p profileRepository) Upsert(ctx context.Context, userID int64, req ProfileRequest) (*Profile, error) {
+	ctx, span := tracer.Start(ctx, "user.ProfileRepository.Upsert")
+	defer span.End()
+
+	var row userdb.UpsertProfileRow
+	err := p.r.write(ctx, "UpsertProfile", func(ctx context.Context, q conn) (err error) {
+		row, err = q.UpsertProfile(ctx, userdb.UpsertProfileParams{
+			UserID:    userID,
+			Phone:     req.Phone,
+			Address:   req.Address,
+			Bio:       req.Bio,
+			AvatarURL: req.AvatarURL,
+			UpdatedAt: p.r.clock.Now(),
+			TenantID:  tenant.FromContext(ctx),
+		})
+		return err
+	})
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to save profile: %w", err)
+	}
+
+	profile := Profile(row)
+	return &profile, nil
+}
+
+// GetWithProfile retrieves a user and their profile with a single join. The
+// profile is nil if the user has none.
+func (r *Repository) GetWithProfile(ctx context.Context, id int64) (*User, *Profile, error) {
+	ctx, span := tracer.Start(ctx, "user.Repository.GetWithProfile")
+	defer span.End()
+
+	var row userdb.GetUserWithProfileRow
+	err := r.read(ctx, "GetWithProfile", func(ctx context.Context, q conn) (err error) {
+		row, err = q.GetUserWithProfile(ctx, userdb.GetUserWithProfileParams{
+			ID:       id,
+			TenantID: tenant.FromContext(ctx),
+		})
+		return err
+	})
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	user := &User{
+		ID:          row.ID,
+		Name:        row.Name,
+		Email:       row.Email,
+		CreatedAt:   row.CreatedAt,
+		UpdatedAt:   row.UpdatedAt,
+		IsAdmin:     row.IsAdmin,
+		UUID:        row.UUID,
+		Preferences: row.Preferences,
+		Status:      row.Status,
+	}
+	if !row.HasProfile {
+		return user, nil, nil
+	}
+
+	return user, &Profile{
+		UserID:    row.ID,
+		Phone:     row.Phone,
+		Address:   row.Address,
+		Bio:       row.Bio,
+		AvatarURL: row.AvatarURL,
+		UpdatedAt: row.ProfileUpdatedAt,
+	}, nil
+}
@@ -0,0 +1,268 @@
+-- name: GetUserByEmail :one
+SELECT id, name, email, created_at, updated_at, is_admin, uuid, preferences, status
+FROM users
+WHERE email = $1 AND tenant_id = sqlc.arg(tenant_id) AND deleted_at IS NULL;
+
+-- name: ListUsers :many
+SELECT id, name, email, created_at, updated_at, is_admin, uuid, preferences, status
+FROM users
+WHERE tenant_id = sqlc.arg(tenant_id) AND deleted_at IS NULL
+  AND (sqlc.narg(created_after)::timestamp IS NULL OR created_at >= sqlc.narg(created_after))
+  AND (sqlc.narg(created_before)::timestamp IS NULL OR created_at < sqlc.narg(created_before))
+  AND (sqlc.narg(updated_after)::timestamp IS NULL OR updated_at >= sqlc.narg(updated_after))
+  AND (sqlc.narg(updated_before)::timestamp IS NULL OR updated_at < sqlc.narg(updated_before))
+  AND (sqlc.narg(preferences)::jsonb IS NULL OR preferences @> sqlc.narg(preferences))
+  AND CASE sqlc.arg(status)::text
+        WHEN '' THEN status <> 'suspended'
+        WHEN 'all' THEN true
+        ELSE status::text = sqlc.arg(status)::text
+      END
+  -- Keyset: only users after (after_key, after_id) in the order below
+  AND (sqlc.narg(after_id)::bigint IS NULL OR CASE
+        WHEN sqlc.arg(sort_by)::text = 'updated_at' AND sqlc.arg(ascending)::bool
+          THEN (updated_at, id) > (sqlc.narg(after_key)::timestamp, sqlc.narg(after_id)::bigint)
+        WHEN sqlc.arg(sort_by)::text = 'updated_at'
+          THEN updated_at < sqlc.narg(after_key)::timestamp OR (updated_at = sqlc.narg(after_key)::timestamp AND id > sqlc.narg(after_id)::bigint)
+        WHEN sqlc.arg(ascending)::bool
+          THEN (created_at, id) > (sqlc.narg(after_key)::timestamp, sqlc.narg(after_id)::bigint)
+        ELSE created_at < sqlc.narg(after_key)::timestamp OR (created_at = sqlc.narg(after_key)::timestamp AND id > sqlc.narg(after_id)::bigint)
+      END)
+ORDER BY
+  CASE WHEN sqlc.arg(sort_by)::text = 'updated_at' AND sqlc.arg(ascending)::bool THEN updated_at END ASC,
+  CASE WHEN sqlc.arg(sort_by)::text = 'updated_at' AND NOT sqlc.arg(ascending)::bool THEN updated_at END DESC,
+  CASE WHEN sqlc.arg(sort_by)::text <> 'updated_at' AND sqlc.arg(ascending)::bool THEN created_at END ASC,
+  CASE WHEN sqlc.arg(sort_by)::text <> 'updated_at' AND NOT sqlc.arg(ascending)::bool THEN created_at END DESC,
+  id
+LIMIT NULLIF(sqlc.arg(row_limit)::int, 0) OFFSET sqlc.arg(row_offset)::int;
+
+-- ListUsersWithTotal is ListUsers with the number of matching users on every
+-- row, counted before LIMIT and OFFSET apply.
+-- name: ListUsersWithTotal :many
+SELECT id, name, email, created_at, updated_at, is_admin, uuid, preferences, status,
+  count(*) OVER () AS total
+FROM users
+WHERE tenant_id = sqlc.arg(tenant_id) AND deleted_at IS NULL
+  AND (sqlc.narg(created_after)::timestamp IS NULL OR created_at >= sqlc.narg(created_after))
+  AND (sqlc.narg(created_before)::timestamp IS NULL OR created_at < sqlc.narg(created_before))
+  AND (sqlc.narg(updated_after)::timestamp IS NULL OR updated_at >= sqlc.narg(updated_after))
+  AND (sqlc.narg(updated_before)::timestamp IS NULL OR updated_at < sqlc.narg(updated_before))
+  AND (sqlc.narg(preferences)::jsonb IS NULL OR preferences @> sqlc.narg(preferences))
+  AND CASE sqlc.arg(status)::text
+        WHEN '' THEN status <> 'suspended'
+        WHEN 'all' THEN true
+        ELSE status::text = sqlc.arg(status)::text
+      END
+ORDER BY
+  CASE WHEN sqlc.arg(sort_by)::text = 'updated_at' AND sqlc.arg(ascending)::bool THEN updated_at END ASC,
+  CASE WHEN sqlc.arg(sort_by)::text = 'updated_at' AND NOT sqlc.arg(ascending)::bool THEN updated_at END DESC,
+  CASE WHEN sqlc.arg(sort_by)::text <> 'updated_at' AND sqlc.arg(ascending)::bool THEN created_at END ASC,
+  CASE WHEN sqlc.arg(sort_by)::text <> 'updated_at' AND NOT sqlc.arg(ascending)::bool THEN created_at END DESC,
+  id
+LIMIT NULLIF(sqlc.arg(row_limit)::int, 0) OFFSET sqlc.arg(row_offset)::int;
+
+-- name: CountUsers :one
+SELECT count(*)
+FROM users
+WHERE tenant_id = sqlc.arg(tenant_id) AND deleted_at IS NULL
+  AND (sqlc.narg(created_after)::timestamp IS NULL OR created_at >= sqlc.narg(created_after))
+  AND (sqlc.narg(created_before)::timestamp IS NULL OR created_at < sqlc.narg(created_before))
+  AND (sqlc.narg(updated_after)::timestamp IS NULL OR updated_at >= sqlc.narg(updated_after))
+  AND (sqlc.narg(updated_before)::timestamp IS NULL OR updated_at < sqlc.narg(updated_before))
+  AND (sqlc.narg(preferences)::jsonb IS NULL OR preferences @> sqlc.narg(preferences))
+  AND CASE sqlc.arg(status)::text
+        WHEN '' THEN status <> 'suspended'
+        WHEN 'all' THEN true
+        ELSE status::text = sqlc.arg(status)::text
+      END;
+
+-- name: CopyUsers :copyfrom
+INSERT INTO users (name, email, created_at, updated_at, uuid, tenant_id)
+VALUES ($1, $2, $3, $4, $5, sqlc.arg(tenant_id));
+
+-- name: GetUserIDByUUID :one
+SELECT id
+FROM users
+WHERE uuid = $1 AND tenant_id = sqlc.arg(tenant_id) AND deleted_at IS NULL;
+
+-- name: GetUserForUpdate :one
+SELECT id, name, email, created_at, updated_at, is_admin, uuid, preferences, status
+FROM users
+WHERE id = $1 AND tenant_id = sqlc.arg(tenant_id) AND deleted_at IS NULL
+FOR UPDATE;
+
+-- name: SetUserAdmin :execrows
+UPDATE users
+SET is_admin = $1, updated_at = $2
+WHERE id = $3 AND tenant_id = sqlc.arg(tenant_id) AND deleted_at IS NULL;
+
+-- name: SetUserPreferences :one
+UPDATE users
+SET preferences = $1, updated_at = $2
+WHERE id = $3 AND tenant_id = sqlc.arg(tenant_id) AND deleted_at IS NULL
+RETURNING id, name, email, created_at, updated_at, is_admin, uuid, preferences, status;
+
+-- name: SetUserStatus :one
+UPDATE users
+SET status = $1, updated_at = $2
+WHERE id = $3 AND tenant_id = sqlc.arg(tenant_id) AND deleted_at IS NULL
+RETURNING id, name, email, created_at, updated_at, is_admin, uuid, preferences, status;
+
+-- name: SetUserAvatar :execrows
+UPDATE users
+SET avatar_key = $1, updated_at = $2
+WHERE id = $3 AND tenant_id = sqlc.arg(tenant_id) AND deleted_at IS NULL;
+
+-- name: GetUserAvatarKey :one
+SELECT COALESCE(avatar_key, '')::text AS avatar_key
+FROM users
+WHERE id = $1 AND tenant_id = sqlc.arg(tenant_id) AND deleted_at IS NULL;
+
+-- PurgeDeletedUsers runs for all tenants. The event log of the purged users
+-- goes with them, as it holds their personal data. It returns the avatar key
+-- of every purged user, empty for none, for the caller to delete the objects
+-- once the transaction committed.
+-- name: PurgeDeletedUsers :many
+WITH purged AS (
+    DELETE FROM users
+    WHERE id IN (
+        SELECT d.id FROM users d
+        WHERE d.deleted_at < sqlc.arg(before)
+        ORDER BY d.deleted_at
+        LIMIT sqlc.arg(max_rows)
+    )
+    RETURNING id, avatar_key
+), purged_events AS (
+    DELETE FROM user_events
+    WHERE user_id IN (SELECT id FROM purged)
+)
+SELECT COALESCE(avatar_key, '')::text AS avatar_key FROM purged;
+
+-- name: InsertAuditEntry :exec
+INSERT INTO audit_log (user_id, action, actor, old_data, new_data, created_at, tenant_id)
+VALUES ($1, $2, $3, $4, $5, $6, sqlc.arg(tenant_id));
+
+-- name: ListAuditEntries :many
+SELECT id, user_id, action, actor, old_data, new_data, created_at
+FROM audit_log
+WHERE user_id = $1 AND tenant_id = sqlc.arg(tenant_id)
+ORDER BY id DESC
+LIMIT $2;
+
+-- name: GetUserWithProfile :one
+SELECT u.id, u.name, u.email, u.created_at, u.updated_at, u.is_admin, u.uuid, u.preferences, u.status,
+       (p.user_id IS NOT NULL)::bool AS has_profile,
+       COALESCE(p.phone, '')::text AS phone,
+       COALESCE(p.address, '')::text AS address,
+       COALESCE(p.bio, '')::text AS bio,
+       COALESCE(p.avatar_url, '')::text AS avatar_url,
+       COALESCE(p.updated_at, u.updated_at)::timestamp AS profile_updated_at
+FROM users u
+LEFT JOIN profiles p ON p.user_id = u.id
+WHERE u.id = $1 AND u.tenant_id = sqlc.arg(tenant_id) AND u.deleted_at IS NULL;
+
+-- name: GetProfile :one
+SELECT user_id, phone, address, bio, avatar_url, updated_at
+FROM profiles
+WHERE user_id = $1 AND tenant_id = sqlc.arg(tenant_id);
+
+-- name: ListProfiles :many
+SELECT user_id, phone, address, bio, avatar_url, updated_at
+FROM profiles
+WHERE user_id = ANY(sqlc.arg(user_ids)::bigint[]) AND tenant_id = sqlc.arg(tenant_id);
+
+-- name: UpsertProfile :one
+INSERT INTO profiles (user_id, tenant_id, phone, address, bio, avatar_url, updated_at)
+VALUES ($1, sqlc.arg(tenant_id), $2, $3, $4, $5, $6)
+ON CONFLICT (user_id) DO UPDATE
+SET phone = EXCLUDED.phone,
+    address = EXCLUDED.address,
+    bio = EXCLUDED.bio,
+    avatar_url = EXCLUDED.avatar_url,
+    updated_at = EXCLUDED.updated_at
+WHERE profiles.tenant_id = EXCLUDED.tenant_id
+RETURNING user_id, phone, address, bio, avatar_url, updated_at;
+
+-- EraseUser replaces the personal data of a user, live or soft-deleted, and
+-- returns the avatar key it cleared
+-- name: EraseUser :one
+UPDATE users u
+SET name = $1, email = $2, preferences = '{}', avatar_key = NULL, status = 'deactivated',
+    updated_at = sqlc.arg(erased_at), deleted_at = COALESCE(u.deleted_at, sqlc.arg(erased_at))
+FROM (
+    SELECT o.id, o.avatar_key FROM users o
+    WHERE o.id = $3 AND o.tenant_id = sqlc.arg(tenant_id)
+    FOR UPDATE
+) old
+WHERE u.id = old.id
+RETURNING COALESCE(old.avatar_key, '')::text AS avatar_key;
+
+-- name: DeleteProfile :exec
+DELETE FROM profiles
+WHERE user_id = $1 AND tenant_id = sqlc.arg(tenant_id);
+
+-- name: EraseAuditEntries :exec
+UPDATE audit_log
+SET old_data = NULL, new_data = NULL
+WHERE user_id = $1 AND tenant_id = sqlc.arg(tenant_id);
+
+-- EraseUserEvents replaces the name and email in the events of a user and
+-- drops their preferences and avatar key, so a replay yields the erased user
+-- name: EraseUserEvents :exec
+UPDATE user_events
+SET data = jsonb_strip_nulls((data - 'preferences' - 'avatar_key') || jsonb_build_object(
+    'name', CASE WHEN data->>'name' IS NOT NULL THEN sqlc.arg(name)::text END,
+    'email', CASE WHEN data->>'email' IS NOT NULL THEN sqlc.arg(email)::text END))
+WHERE user_id = sqlc.arg(user_id) AND tenant_id = sqlc.arg(tenant_id);
+
+-- EraseOutboxEvents drops the data of a user's events and keeps their
+-- envelopes. The outbox has no tenant column, but user IDs are unique.
+-- name: EraseOutboxEvents :exec
+UPDATE outbox
+SET payload = payload - 'data'
+WHERE aggregate_id = $1 AND event_type LIKE 'user.%';
+
+-- name: DeleteUserDirectoryEntry :exec
+DELETE FROM user_directory
+WHERE user_id = $1;
+
+-- MoveAuditEntries hands the audit history of a merged user to the survivor
+-- name: MoveAuditEntries :execrows
+UPDATE audit_log
+SET user_id = sqlc.arg(to_user_id)
+WHERE user_id = sqlc.arg(from_user_id) AND tenant_id = sqlc.arg(tenant_id);
+
+-- GetAuditPosition returns the transaction and time of an audit entry, the
+-- position of a changes feed cursor
+-- name: GetAuditPosition :one
+SELECT xact_id, created_at
+FROM audit_log
+WHERE id = $1 AND tenant_id = sqlc.arg(tenant_id);
+
+-- ListUserChanges returns the users with audit entries after the position
+-- (after_xact_id, after_id) and since, in the order of their latest entry.
+-- Entries are ordered by their transaction and only listed once every
+-- transaction before theirs has ended, so an entry committed late can't fall
+-- behind a position already returned. The uuid is read from the snapshots of
+-- the latest entry, for users that no longer have a row.
+-- name: ListUserChanges :many
+SELECT user_id, seq, created, changed_at, uuid
+FROM (
+    SELECT DISTINCT ON (user_id) user_id, id AS seq, xact_id, created_at AS changed_at,
+           COALESCE(old_data->>'uuid', new_data->>'uuid', '')::text AS uuid,
+           (bool_or(action = 'create') OVER (PARTITION BY user_id))::bool AS created
+    FROM audit_log
+    WHERE tenant_id = sqlc.arg(tenant_id)
+      AND (xact_id, id) > (sqlc.arg(after_xact_id)::xid8, sqlc.arg(after_id)::bigint)
+      AND xact_id < pg_snapshot_xmin(pg_current_snapshot())
+      AND created_at > sqlc.arg(since)::timestamp
+    ORDER BY user_id, xact_id DESC, id DESC
+) c
+ORDER BY xact_id, seq
+LIMIT sqlc.arg(max_rows)::int;
+
+-- GetChangedUsers returns the users of a page of the changes feed, live or
+-- deleted. Users without a row were hard deleted or purged.
+-- name: GetChangedUsers :many
+SELECT id, name, email, created_at, updated_at, is_admin, uuid, preferences, status, deleted_at
+FROM users
+WHERE id = ANY(sqlc.arg(ids)::bigint[]) AND tenant_id = sqlc.arg(tenant_id);
@@ -1,55 +1,374 @@
 package user
 
+//go:generate sqlc generate -f ../../sqlc.yaml
+
 import (
 	"context"
-	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"strconv"
 	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/things-kit/example-db/internal/clock"
+	"github.com/things-kit/example-db/internal/crud"
+	"github.com/things-kit/example-db/internal/database"
+	"github.com/things-kit/example-db/internal/feature"
+	"github.com/things-kit/example-db/internal/idgen"
+	"github.com/things-kit/example-db/internal/prefs"
+	"github.com/things-kit/example-db/internal/tenant"
+	"github.com/things-kit/example-db/internal/user/userdb"
+	"github.com/things-kit/module/log"
+	"go.opentelemetry.io/otel"
+	"go.uber.org/fx"
+	"golang.org/x/sync/singleflight"
 )
 
-// User represents a user in the system
+// tracer records a span around each repository method
+var tracer = otel.Tracer("github.com/things-kit/example-db/internal/user")
+
+// sharedQueryTimeout bounds a coalesced GetByID query, which is detached from
+// the cancellation of any single caller
+const sharedQueryTimeout = 10 * time.Second
+
+// User is the persistence model of a user. It carries internal columns and
+// is never returned by the API directly; see UserResponse.
 type User struct {
 	ID        int64     `json:"id"`
 	Name      string    `json:"name"`
 	Email     string    `json:"email"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	IsAdmin   bool      `json:"is_admin"`
+	UUID      uuid.UUID `json:"uuid"`
+
+	Preferences prefs.Preferences `json:"preferences"`
+	Status      string            `json:"status"`
+}
+
+// Account statuses of a user
+const (
+	StatusActive      = "active"
+	StatusSuspended   = "suspended"
+	StatusDeactivated = "deactivated"
+)
+
+// StatusAll lists users of every status in a ListFilter
+const StatusAll = "all"
+
+// Sort fields accepted by ListFilter
+const (
+	SortCreatedAt = "created_at"
+	SortUpdatedAt = "updated_at"
+)
+
+// ListFilter narrows and orders the users returned by List. The zero value
+// matches every user, newest first.
+type ListFilter struct {
+	// CreatedAfter and CreatedBefore bound created_at (inclusive, exclusive)
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	// UpdatedAfter and UpdatedBefore bound updated_at (inclusive, exclusive)
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+	// SortBy is SortCreatedAt (the default) or SortUpdatedAt
+	SortBy string
+	// Ascending sorts oldest first
+	Ascending bool
+	// Preferences matches users whose preferences contain these values
+	Preferences prefs.Preferences
+	// Status matches users with the status, or of every status for StatusAll.
+	// The default lists all users except suspended ones.
+	Status string
+	// Limit caps the number of users returned; 0 returns all of them
+	Limit int
+	// Offset skips that many users before the first one returned
+	Offset int
+	// After resumes the list after the user at this position of the order
+	After *Keyset
 }
 
-// CreateUserRequest represents the request to create a user
-type CreateUserRequest struct {
-	Name  string `json:"name" binding:"required"`
-	Email string `json:"email" binding:"required,email"`
+// Keyset is the position of a user in the List order: the value of the
+// sort field and the ID breaking its ties
+type Keyset struct {
+	Key time.Time
+	ID  int64
 }
 
-// Repository handles user data operations
+// DBTX is the subset of *pgxpool.Pool and pgx.Tx used by the repository
+type DBTX = userdb.DBTX
+
+// Repository handles user data operations.
+// Plain Create, GetByID, Update and Delete run the generic statements of the
+// crud package. Other statements are the sqlc-generated queries in the userdb
+// package, built from query.sql; run `sqlc generate` after changing it.
+// Read-only methods run on a healthy read replica when one is configured.
+// Calls that fail with a transient error are retried; inside a transaction the
+// whole transaction is retried instead of single statements. Every query is
+// bounded by the configured statement timeout, and calls fail fast while the
+// circuit breaker is open or no limiter slot is free.
 type Repository struct {
-	db *sql.DB
+	pool     *pgxpool.Pool
+	db       DBTX
+	q        conn
+	wrap     func(DBTX) DBTX
+	replicas *database.Replicas
+	retrier  *database.Retrier
+	breaker  *database.Breaker
+	limiter  *database.Limiter
+	timeout  time.Duration
+	metrics  *Metrics
+	slow     *slowQueryLog
+	flags    *feature.Flags
+	tx       pgx.Tx
+	group    singleflight.Group
+	clock    clock.Clock
+	ids      idgen.Generator
+}
+
+// RepositoryParams holds the repository dependencies. Replicas, Retrier,
+// Breaker, Limiter and Flags may be nil. Clock and IDs default to the system
+// clock and random UUIDs; tests replace them to assert exact values.
+type RepositoryParams struct {
+	fx.In
+
+	Pool     *pgxpool.Pool
+	Replicas *database.Replicas `optional:"true"`
+	Retrier  *database.Retrier  `optional:"true"`
+	Breaker  *database.Breaker  `optional:"true"`
+	Limiter  *database.Limiter  `optional:"true"`
+	Config   *database.Config
+	Metrics  *Metrics
+	Logger   log.Logger      `optional:"true"`
+	Flags    *feature.Flags  `optional:"true"`
+	Clock    clock.Clock     `optional:"true"`
+	IDs      idgen.Generator `optional:"true"`
 }
 
 // NewRepository creates a new user repository
-func NewRepository(db *sql.DB) *Repository {
-	return &Repository{db: db}
+func NewRepository(p RepositoryParams) *Repository {
+	repo := &Repository{
+		pool:     p.Pool,
+		replicas: p.Replicas,
+		retrier:  p.Retrier,
+		breaker:  p.Breaker,
+		limiter:  p.Limiter,
+		timeout:  p.Config.StatementTimeout,
+		metrics:  p.Metrics,
+		slow:     newSlowQueryLog(p.Config.SlowQueryThreshold, p.Logger),
+		flags:    p.Flags,
+		clock:    clock.Or(p.Clock),
+		ids:      idgen.Or(p.IDs),
+	}
+	repo.db = p.Pool
+	repo.q = repo.queries(p.Pool)
+	return repo
+}
+
+// AsUserRepository provides the UserRepository the Service depends on: the
+// SQL repository, or the event store on top of it when users.storage is
+// "events"
+func AsUserRepository(r *Repository, cfg *Config) UserRepository {
+	if cfg.Storage == StorageEvents {
+		return NewEventStore(r)
+	}
+	return r
+}
+
+// newRepository creates a repository running its queries on db
+func newRepository(pool *pgxpool.Pool, db DBTX, metrics *Metrics) *Repository {
+	repo := &Repository{pool: pool, db: db, metrics: metrics, clock: clock.System{}, ids: idgen.Random{}}
+	repo.q = repo.queries(db)
+	return repo
+}
+
+// WrapDB returns a repository like r whose statements run on wrap(db) for
+// every connection db it uses, including replicas and transactions. Tests
+// use it to inject faults below the retries, circuit breaker, limiter and
+// statement timeout, which still apply.
+func (r *Repository) WrapDB(wrap func(DBTX) DBTX) *Repository {
+	repo := &Repository{
+		pool:     r.pool,
+		db:       r.db,
+		wrap:     wrap,
+		replicas: r.replicas,
+		retrier:  r.retrier,
+		breaker:  r.breaker,
+		limiter:  r.limiter,
+		timeout:  r.timeout,
+		metrics:  r.metrics,
+		slow:     r.slow,
+		flags:    r.flags,
+		tx:       r.tx,
+		clock:    r.clock,
+		ids:      r.ids,
+	}
+	repo.q = repo.queries(r.db)
+	return repo
+}
+
+// queries returns the statements running on db, instrumented with the
+// repository's metrics and slow query log
+func (r *Repository) queries(db DBTX) conn {
+	db = r.instrument(db)
+	return conn{
+		Queries:   userdb.New(db),
+		users:     crud.New(usersTable, db, r.clock),
+		hardUsers: crud.New(usersHardDeleteTable, db, r.clock),
+	}
+}
+
+// instrument wraps db with the repository's wrapper, metrics and slow query log
+func (r *Repository) instrument(db DBTX) DBTX {
+	if r.wrap != nil {
+		db = r.wrap(db)
+	}
+	return instrumentedDB{db: db, metrics: r.metrics, slow: r.slow}
+}
+
+// bind returns a repository running its queries on tx. It shares the
+// instrumentation and statement timeout but not the replicas, retries,
+// breaker or limiter, which apply to the transaction as a whole.
+func (r *Repository) bind(tx pgx.Tx) *Repository {
+	repo := &Repository{pool: r.pool, db: tx, wrap: r.wrap, timeout: r.timeout, metrics: r.metrics, slow: r.slow, flags: r.flags, tx: tx, clock: r.clock, ids: r.ids}
+	repo.q = repo.queries(tx)
+	return repo
+}
+
+// Bind returns a repository running every call in tx, which the caller
+// commits or rolls back. WithTx on the returned repository joins tx. Tests use
+// it to roll back everything a test wrote.
+func (r *Repository) Bind(tx pgx.Tx) *Repository {
+	return r.bind(tx)
+}
+
+// Tx returns the transaction the repository is bound to inside WithTx, or nil
+func (r *Repository) Tx() pgx.Tx {
+	return r.tx
+}
+
+// read runs fn with the queries of a healthy replica, falling back to the
+// primary when there is none. A replica that fails is taken out of rotation
+// and the read is retried on the primary; a read that timed out is not.
+func (r *Repository) read(ctx context.Context, op string, fn func(ctx context.Context, q conn) error) error {
+	return r.attempt(ctx, op, func() error {
+		if replica := r.replicas.Pick(); replica != nil {
+			err := r.withTimeout(ctx, func(ctx context.Context) error {
+				return fn(ctx, r.queries(replica.Pool))
+			})
+			if err == nil || errors.Is(err, pgx.ErrNoRows) || errors.Is(err, crud.ErrNotFound) ||
+				database.IsTimeout(err) || ctx.Err() != nil {
+				return err
+			}
+			replica.MarkDown()
+		}
+		return r.withTimeout(ctx, func(ctx context.Context) error {
+			return fn(ctx, r.q)
+		})
+	})
+}
+
+// write runs fn with the queries of the primary. Only errors after which
+// the write is known not to have been applied are retried.
+func (r *Repository) write(ctx context.Context, op string, fn func(ctx context.Context, q conn) error) error {
+	return r.attemptWrite(ctx, op, func() error {
+		return r.withTimeout(ctx, func(ctx context.Context) error {
+			return fn(ctx, r.q)
+		})
+	})
+}
+
+// attempt runs fn once it holds a limiter slot and the circuit breaker lets
+// it through, retrying transient errors
+func (r *Repository) attempt(ctx context.Context, op string, fn func() error) error {
+	return r.retrier.Do(ctx, op, func() error {
+		return r.limiter.Do(ctx, func() error {
+			return r.breaker.Do(fn)
+		})
+	})
+}
+
+// attemptWrite is attempt for writes, see database.Retrier.DoWrite
+func (r *Repository) attemptWrite(ctx context.Context, op string, fn func() error) error {
+	return r.retrier.DoWrite(ctx, op, func() error {
+		return r.limiter.Do(ctx, func() error {
+			return r.breaker.Do(fn)
+		})
+	})
+}
+
+// withTimeout runs fn with ctx bounded by the statement timeout
+func (r *Repository) withTimeout(ctx context.Context, fn func(ctx context.Context) error) error {
+	if r.timeout <= 0 {
+		return fn(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return fn(ctx)
+}
+
+// WithTx runs fn as a unit of work: every call fn makes on repo runs in one
+// database transaction, which is committed if fn returns nil and rolled back
+// otherwise. Use repo.Tx to write other tables, such as the outbox, in the
+// same transaction. If r is already bound to a transaction, fn joins it.
+// A transaction that fails with a serialization failure or deadlock is run
+// again from the start, so fn must not have side effects outside the database.
+func (r *Repository) WithTx(ctx context.Context, fn func(repo UserRepository) error) error {
+	if r.tx != nil {
+		return fn(r)
+	}
+
+	return r.attemptWrite(ctx, "transaction", func() error {
+		tx, err := r.pool.Begin(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback(ctx)
+
+		if err := fn(r.bind(tx)); err != nil {
+			return err
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			return fmt.Errorf("failed to commit transaction: %w", err)
+		}
+
+		return nil
+	})
 }
 
 // Create creates a new user
 func (r *Repository) Create(ctx context.Context, req CreateUserRequest) (*User, error) {
-	query := `
-		INSERT INTO users (name, email, created_at, updated_at)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, name, email, created_at, updated_at
-	`
-
-	now := time.Now()
-	user := &User{}
-
-	err := r.db.QueryRowContext(ctx, query, req.Name, req.Email, now, now).Scan(
-		&user.ID,
-		&user.Name,
-		&user.Email,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	ctx, span := tracer.Start(ctx, "user.Repository.Create")
+	defer span.End()
+
+	key, err := r.ids.UUID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate user uuid: %w", err)
+	}
+
+	now := r.clock.Now()
+	var user *User
+	err = r.write(ctx, "Create", func(ctx context.Context, q conn) (err error) {
+		user, err = q.users.Create(ctx, &User{
+			Name:      req.Name,
+			Email:     req.Email,
+			CreatedAt: now,
+			UpdatedAt: now,
+			UUID:      key,
+		})
+		return err
+	})
+	if isUniqueViolation(err) {
+		return nil, ErrEmailTaken
+	}
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
@@ -58,25 +377,96 @@ func (r *Repository) Create(ctx context.Context, req CreateUserRequest) (*User,
 	return user, nil
 }
 
-// GetByID retrieves a user by ID
+// CopyFrom inserts users in bulk with the COPY protocol and returns the number
+// inserted. It is all or nothing: if any email is taken, no user is inserted
+// and ErrEmailTaken is returned. Unlike Create it returns no rows, so callers
+// needing the new IDs look the users up afterwards.
+func (r *Repository) CopyFrom(ctx context.Context, reqs []CreateUserRequest) (int64, error) {
+	ctx, span := tracer.Start(ctx, "user.Repository.CopyFrom")
+	defer span.End()
+
+	now := r.clock.Now()
+	rows := make([]userdb.CopyUsersParams, len(reqs))
+	for i, req := range reqs {
+		key, err := r.ids.UUID()
+		if err != nil {
+			return 0, fmt.Errorf("failed to generate user uuid: %w", err)
+		}
+		rows[i] = userdb.CopyUsersParams{
+			Name:      req.Name,
+			Email:     req.Email,
+			CreatedAt: now,
+			UpdatedAt: now,
+			UUID:      key,
+			TenantID:  tenant.FromContext(ctx),
+		}
+	}
+
+	var n int64
+	err := r.write(ctx, "CopyFrom", func(ctx context.Context, q conn) (err error) {
+		n, err = q.CopyUsers(ctx, rows)
+		return err
+	})
+	if isUniqueViolation(err) {
+		return 0, ErrEmailTaken
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy users: %w", err)
+	}
+
+	return n, nil
+}
+
+// GetByID retrieves a user by ID.
+// Concurrent lookups for the same ID share a single database query. That query
+// is not cancelled with any one caller; each caller stops waiting when its own
+// context is done.
 func (r *Repository) GetByID(ctx context.Context, id int64) (*User, error) {
-	query := `
-		SELECT id, name, email, created_at, updated_at
-		FROM users
-		WHERE id = $1
-	`
-
-	user := &User{}
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&user.ID,
-		&user.Name,
-		&user.Email,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	ctx, span := tracer.Start(ctx, "user.Repository.GetByID")
+	defer span.End()
+
+	leader := false
+	// Keyed by tenant too, so a lookup never receives another tenant's user
+	key := tenant.FromContext(ctx) + "/" + strconv.FormatInt(id, 10)
+	ch := r.group.DoChan(key, func() (any, error) {
+		leader = true
+		r.metrics.GetByIDQueries.Add(1)
+
+		queryCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sharedQueryTimeout)
+		defer cancel()
+		return r.getByID(queryCtx, id)
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("failed to get user: %w", ctx.Err())
+	case res := <-ch:
+		// leader is safe to read: it is written before the result is sent
+		if !leader {
+			r.metrics.GetByIDCoalesced.Add(1)
+		}
 
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("user not found")
+		if res.Err != nil {
+			return nil, res.Err
+		}
+
+		// Hand every caller its own copy so shared results can't be mutated
+		user := *res.Val.(*User)
+		return &user, nil
+	}
+}
+
+// getByID queries a single user by ID
+func (r *Repository) getByID(ctx context.Context, id int64) (*User, error) {
+	var user *User
+	err := r.read(ctx, "GetByID", func(ctx context.Context, q conn) (err error) {
+		user, err = q.users.Get(ctx, id)
+		return err
+	})
+
+	if errors.Is(err, crud.ErrNotFound) {
+		return nil, ErrNotFound
 	}
 
 	if err != nil {
@@ -88,89 +478,246 @@ func (r *Repository) GetByID(ctx context.Context, id int64) (*User, error) {
 
 // GetByEmail retrieves a user by email
 func (r *Repository) GetByEmail(ctx context.Context, email string) (*User, error) {
-	query := `
-		SELECT id, name, email, created_at, updated_at
-		FROM users
-		WHERE email = $1
-	`
-
-	user := &User{}
-	err := r.db.QueryRowContext(ctx, query, email).Scan(
-		&user.ID,
-		&user.Name,
-		&user.Email,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("user not found")
+	ctx, span := tracer.Start(ctx, "user.Repository.GetByEmail")
+	defer span.End()
+
+	var row userdb.GetUserByEmailRow
+	err := r.read(ctx, "GetByEmail", func(ctx context.Context, q conn) (err error) {
+		row, err = q.GetUserByEmail(ctx, userdb.GetUserByEmailParams{
+			Email:    email,
+			TenantID: tenant.FromContext(ctx),
+		})
+		return err
+	})
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
 	}
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	return user, nil
+	user := User(row)
+	return &user, nil
+}
+
+// GetIDByUUID resolves the UUID key of a user to its ID
+func (r *Repository) GetIDByUUID(ctx context.Context, key uuid.UUID) (int64, error) {
+	ctx, span := tracer.Start(ctx, "user.Repository.GetIDByUUID")
+	defer span.End()
+
+	var id int64
+	err := r.read(ctx, "GetIDByUUID", func(ctx context.Context, q conn) (err error) {
+		id, err = q.GetUserIDByUUID(ctx, userdb.GetUserIDByUUIDParams{
+			UUID:     key,
+			TenantID: tenant.FromContext(ctx),
+		})
+		return err
+	})
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return id, nil
 }
 
-// List retrieves all users
-func (r *Repository) List(ctx context.Context) ([]*User, error) {
-	query := `
-		SELECT id, name, email, created_at, updated_at
-		FROM users
-		ORDER BY created_at DESC
-	`
+// List retrieves the users matching the filter
+func (r *Repository) List(ctx context.Context, f ListFilter) ([]*User, error) {
+	ctx, span := tracer.Start(ctx, "user.Repository.List")
+	defer span.End()
+
+	params, err := listParams(ctx, f)
+	if err != nil {
+		return nil, err
+	}
 
-	rows, err := r.db.QueryContext(ctx, query)
+	var rows []userdb.ListUsersRow
+	err = r.read(ctx, "List", func(ctx context.Context, q conn) (err error) {
+		rows, err = q.ListUsers(ctx, params)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list users: %w", err)
 	}
-	defer rows.Close()
 
 	var users []*User
-	for rows.Next() {
-		user := &User{}
-		err := rows.Scan(
-			&user.ID,
-			&user.Name,
-			&user.Email,
-			&user.CreatedAt,
-			&user.UpdatedAt,
-		)
+	for _, row := range rows {
+		user := User(row)
+		users = append(users, &user)
+	}
+
+	return users, nil
+}
+
+// ListWithTotal retrieves the users matching the filter and the number of
+// users matching it regardless of Limit, Offset and After, in one query. The
+// total is read from a window function on the returned rows, so a page past
+// the last user is followed by a count query. The window function would only
+// count the users after a keyset, so a filter with After lists and counts
+// separately.
+func (r *Repository) ListWithTotal(ctx context.Context, f ListFilter) ([]*User, int64, error) {
+	ctx, span := tracer.Start(ctx, "user.Repository.ListWithTotal")
+	defer span.End()
+
+	if f.After != nil {
+		users, err := r.List(ctx, f)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan user: %w", err)
+			return nil, 0, err
 		}
-		users = append(users, user)
+		total, err := r.Count(ctx, f)
+		return users, total, err
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating users: %w", err)
+	params, err := listParams(ctx, f)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	return users, nil
+	var rows []userdb.ListUsersWithTotalRow
+	err = r.read(ctx, "ListWithTotal", func(ctx context.Context, q conn) (err error) {
+		rows, err = q.ListUsersWithTotal(ctx, userdb.ListUsersWithTotalParams{
+			TenantID:      params.TenantID,
+			CreatedAfter:  params.CreatedAfter,
+			CreatedBefore: params.CreatedBefore,
+			UpdatedAfter:  params.UpdatedAfter,
+			UpdatedBefore: params.UpdatedBefore,
+			Preferences:   params.Preferences,
+			Status:        params.Status,
+			SortBy:        params.SortBy,
+			Ascending:     params.Ascending,
+			RowOffset:     params.RowOffset,
+			RowLimit:      params.RowLimit,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	if len(rows) == 0 && f.Offset > 0 {
+		total, err := r.Count(ctx, f)
+		return nil, total, err
+	}
+
+	var (
+		users []*User
+		total int64
+	)
+	for _, row := range rows {
+		total = row.Total
+		users = append(users, &User{
+			ID:          row.ID,
+			Name:        row.Name,
+			Email:       row.Email,
+			CreatedAt:   row.CreatedAt,
+			UpdatedAt:   row.UpdatedAt,
+			IsAdmin:     row.IsAdmin,
+			UUID:        row.UUID,
+			Preferences: row.Preferences,
+			Status:      row.Status,
+		})
+	}
+
+	return users, total, nil
+}
+
+// Count returns the number of users matching the filter, ignoring its Limit,
+// Offset, After and order
+func (r *Repository) Count(ctx context.Context, f ListFilter) (int64, error) {
+	ctx, span := tracer.Start(ctx, "user.Repository.Count")
+	defer span.End()
+
+	params, err := listParams(ctx, f)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int64
+	err = r.read(ctx, "Count", func(ctx context.Context, q conn) (err error) {
+		n, err = q.CountUsers(ctx, userdb.CountUsersParams{
+			TenantID:      params.TenantID,
+			CreatedAfter:  params.CreatedAfter,
+			CreatedBefore: params.CreatedBefore,
+			UpdatedAfter:  params.UpdatedAfter,
+			UpdatedBefore: params.UpdatedBefore,
+			Preferences:   params.Preferences,
+			Status:        params.Status,
+		})
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	return n, nil
+}
+
+// listParams converts a filter to the parameters of the ListUsers queries
+func listParams(ctx context.Context, f ListFilter) (userdb.ListUsersParams, error) {
+	if f.Limit > math.MaxInt32 || f.Offset > math.MaxInt32 {
+		return userdb.ListUsersParams{}, fmt.Errorf("%w: limit and offset must be at most %d", ErrInvalid, math.MaxInt32)
+	}
+
+	var contains []byte
+	if len(f.Preferences) > 0 {
+		var err error
+		if contains, err = json.Marshal(f.Preferences); err != nil {
+			return userdb.ListUsersParams{}, fmt.Errorf("failed to encode preference filter: %w", err)
+		}
+	}
+
+	var (
+		afterKey *time.Time
+		afterID  *int64
+	)
+	if f.After != nil {
+		afterKey, afterID = &f.After.Key, &f.After.ID
+	}
+
+	return userdb.ListUsersParams{
+		CreatedAfter:  f.CreatedAfter,
+		CreatedBefore: f.CreatedBefore,
+		UpdatedAfter:  f.UpdatedAfter,
+		UpdatedBefore: f.UpdatedBefore,
+		Preferences:   contains,
+		Status:        f.Status,
+		TenantID:      tenant.FromContext(ctx),
+		SortBy:        f.SortBy,
+		Ascending:     f.Ascending,
+		RowLimit:      int32(f.Limit),
+		RowOffset:     int32(f.Offset),
+		AfterKey:      afterKey,
+		AfterID:       afterID,
+	}, nil
 }
 
 // Update updates a user
 func (r *Repository) Update(ctx context.Context, id int64, req CreateUserRequest) (*User, error) {
-	query := `
-		UPDATE users
-		SET name = $1, email = $2, updated_at = $3
-		WHERE id = $4
-		RETURNING id, name, email, created_at, updated_at
-	`
-
-	user := &User{}
-	err := r.db.QueryRowContext(ctx, query, req.Name, req.Email, time.Now(), id).Scan(
-		&user.ID,
-		&user.Name,
-		&user.Email,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	ctx, span := tracer.Start(ctx, "user.Repository.Update")
+	defer span.End()
+
+	var user *User
+	err := r.write(ctx, "Update", func(ctx context.Context, q conn) (err error) {
+		user, err = q.users.Update(ctx, id, &User{
+			Name:      req.Name,
+			Email:     req.Email,
+			UpdatedAt: r.clock.Now(),
+		})
+		return err
+	})
+
+	if errors.Is(err, crud.ErrNotFound) {
+		return nil, ErrNotFound
+	}
 
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("user not found")
+	if isUniqueViolation(err) {
+		return nil, ErrEmailTaken
 	}
 
 	if err != nil {
@@ -180,23 +727,207 @@ func (r *Repository) Update(ctx context.Context, id int64, req CreateUserRequest
 	return user, nil
 }
 
-// Delete deletes a user
-func (r *Repository) Delete(ctx context.Context, id int64) error {
-	query := `DELETE FROM users WHERE id = $1`
+// Delete soft-deletes a user. The row is kept until PurgeDeleted removes it,
+// unless the soft_delete flag is off for the tenant; then it is removed at
+// once and Delete returns the key of the avatar the user had, or "", for the
+// caller to delete once the transaction committed.
+func (r *Repository) Delete(ctx context.Context, id int64) (string, error) {
+	ctx, span := tracer.Start(ctx, "user.Repository.Delete")
+	defer span.End()
+
+	soft := r.flags.Enabled(ctx, feature.SoftDelete)
+	var key string
+	err := r.write(ctx, "Delete", func(ctx context.Context, q conn) (err error) {
+		if soft {
+			return q.users.Delete(ctx, id)
+		}
+		key, err = q.GetUserAvatarKey(ctx, userdb.GetUserAvatarKeyParams{
+			ID:       id,
+			TenantID: tenant.FromContext(ctx),
+		})
+		if err != nil {
+			return err
+		}
+		return q.hardUsers.Delete(ctx, id)
+	})
+
+	if errors.Is(err, crud.ErrNotFound) || errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrNotFound
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	return key, nil
+}
+
+// SetAdmin sets whether a user has administrator rights
+func (r *Repository) SetAdmin(ctx context.Context, id int64, admin bool) error {
+	ctx, span := tracer.Start(ctx, "user.Repository.SetAdmin")
+	defer span.End()
+
+	var rows int64
+	err := r.write(ctx, "SetAdmin", func(ctx context.Context, q conn) (err error) {
+		rows, err = q.SetUserAdmin(ctx, userdb.SetUserAdminParams{
+			IsAdmin:   admin,
+			UpdatedAt: r.clock.Now(),
+			ID:        id,
+			TenantID:  tenant.FromContext(ctx),
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set admin: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// SetPreferences replaces a user's preferences
+func (r *Repository) SetPreferences(ctx context.Context, id int64, p prefs.Preferences) (*User, error) {
+	ctx, span := tracer.Start(ctx, "user.Repository.SetPreferences")
+	defer span.End()
+
+	var row userdb.SetUserPreferencesRow
+	err := r.write(ctx, "SetPreferences", func(ctx context.Context, q conn) (err error) {
+		row, err = q.SetUserPreferences(ctx, userdb.SetUserPreferencesParams{
+			Preferences: p,
+			UpdatedAt:   r.clock.Now(),
+			ID:          id,
+			TenantID:    tenant.FromContext(ctx),
+		})
+		return err
+	})
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
 
-	result, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
-		return fmt.Errorf("failed to delete user: %w", err)
+		return nil, fmt.Errorf("failed to set preferences: %w", err)
 	}
 
-	rows, err := result.RowsAffected()
+	user := User(row)
+	return &user, nil
+}
+
+// SetStatus changes the account status of a user
+func (r *Repository) SetStatus(ctx context.Context, id int64, status string) (*User, error) {
+	ctx, span := tracer.Start(ctx, "user.Repository.SetStatus")
+	defer span.End()
+
+	var row userdb.SetUserStatusRow
+	err := r.write(ctx, "SetStatus", func(ctx context.Context, q conn) (err error) {
+		row, err = q.SetUserStatus(ctx, userdb.SetUserStatusParams{
+			Status:    status,
+			UpdatedAt: r.clock.Now(),
+			ID:        id,
+			TenantID:  tenant.FromContext(ctx),
+		})
+		return err
+	})
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to set status: %w", err)
+	}
+
+	user := User(row)
+	return &user, nil
+}
+
+// SetAvatar stores the object key of a user's avatar
+func (r *Repository) SetAvatar(ctx context.Context, id int64, key string) error {
+	ctx, span := tracer.Start(ctx, "user.Repository.SetAvatar")
+	defer span.End()
+
+	var rows int64
+	err := r.write(ctx, "SetAvatar", func(ctx context.Context, q conn) (err error) {
+		rows, err = q.SetUserAvatar(ctx, userdb.SetUserAvatarParams{
+			AvatarKey: pgtype.Text{String: key, Valid: true},
+			UpdatedAt: r.clock.Now(),
+			ID:        id,
+			TenantID:  tenant.FromContext(ctx),
+		})
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return fmt.Errorf("failed to set avatar: %w", err)
 	}
 
 	if rows == 0 {
-		return fmt.Errorf("user not found")
+		return ErrNotFound
 	}
 
 	return nil
 }
+
+// GetAvatarKey retrieves the object key of a user's avatar, or "" if the
+// user has none
+func (r *Repository) GetAvatarKey(ctx context.Context, id int64) (string, error) {
+	ctx, span := tracer.Start(ctx, "user.Repository.GetAvatarKey")
+	defer span.End()
+
+	var key string
+	err := r.read(ctx, "GetAvatarKey", func(ctx context.Context, q conn) (err error) {
+		key, err = q.GetUserAvatarKey(ctx, userdb.GetUserAvatarKeyParams{
+			ID:       id,
+			TenantID: tenant.FromContext(ctx),
+		})
+		return err
+	})
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrNotFound
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to get avatar: %w", err)
+	}
+
+	return key, nil
+}
+
+// PurgeDeleted permanently deletes up to limit users that were soft-deleted
+// before the given time, along with their events. It returns the number of
+// users removed and the keys of the avatars they had, for the caller to
+// delete once the transaction committed.
+func (r *Repository) PurgeDeleted(ctx context.Context, before time.Time, limit int) (int64, []string, error) {
+	ctx, span := tracer.Start(ctx, "user.Repository.PurgeDeleted")
+	defer span.End()
+
+	var keys []string
+	err := r.write(ctx, "PurgeDeleted", func(ctx context.Context, q conn) (err error) {
+		keys, err = q.PurgeDeletedUsers(ctx, userdb.PurgeDeletedUsersParams{
+			Before:  &before,
+			MaxRows: int32(limit),
+		})
+		return err
+	})
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to purge users: %w", err)
+	}
+
+	avatars := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if key != "" {
+			avatars = append(avatars, key)
+		}
+	}
+	return int64(len(keys)), avatars, nil
+}
+
+// isUniqueViolation reports whether err is a unique constraint violation,
+// which for users means the email is taken
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
@@ -0,0 +1,79 @@
+package user
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/database"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/testutil/chaos"
+)
+
+// The tests inject faults below the repository's resilience layers and check
+// how the retries, circuit breaker and statement timeout react
+
+func newChaosRepository(cfg *database.Config) (*Repository, *chaos.Injector) {
+	repo := newRepository(nil, &execDB{}, NewMetrics())
+	repo.retrier = database.NewRetrier(cfg, testutil.NopLogger{})
+	repo.breaker = database.NewBreaker(cfg, testutil.NopLogger{})
+	repo.timeout = cfg.StatementTimeout
+
+	in := chaos.New(1)
+	return repo.WrapDB(in.Wrap), in
+}
+
+func TestRepositoryRetriesInjectedFaults(t *testing.T) {
+	repo, in := newChaosRepository(&database.Config{Retry: database.RetryConfig{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+	}})
+	ctx := context.Background()
+
+	in.Next(chaos.Transient, chaos.Transient)
+	require.NoError(t, repo.SetAdmin(ctx, 1, true))
+	calls, injected := in.Calls()
+	assert.Equal(t, 3, calls, "serialization failures are retried")
+	assert.Equal(t, 2, injected)
+
+	in.Next(chaos.Drop)
+	assert.ErrorIs(t, repo.SetAdmin(ctx, 1, true), chaos.ErrDropped)
+	calls, _ = in.Calls()
+	assert.Equal(t, 4, calls, "a write whose connection dropped may have been applied")
+
+	in.Next(chaos.Fatal)
+	assert.ErrorIs(t, repo.SetAdmin(ctx, 1, true), chaos.ErrFatal)
+	calls, _ = in.Calls()
+	assert.Equal(t, 5, calls, "other errors are not retried")
+}
+
+func TestRepositoryBreakerOpensOnInjectedFaults(t *testing.T) {
+	repo, in := newChaosRepository(&database.Config{Breaker: database.BreakerConfig{
+		Enabled:          true,
+		FailureThreshold: 2,
+		OpenTimeout:      time.Hour,
+		HalfOpenRequests: 1,
+	}})
+	ctx := context.Background()
+
+	in.SetDropRate(1)
+	for range 2 {
+		assert.ErrorIs(t, repo.SetAdmin(ctx, 1, true), chaos.ErrDropped)
+	}
+
+	assert.ErrorIs(t, repo.SetAdmin(ctx, 1, true), database.ErrUnavailable)
+	calls, _ := in.Calls()
+	assert.Equal(t, 2, calls, "an open breaker fails fast without touching the database")
+}
+
+func TestRepositoryTimesOutInjectedLatency(t *testing.T) {
+	repo, in := newChaosRepository(&database.Config{StatementTimeout: 20 * time.Millisecond})
+
+	in.SetLatency(time.Hour)
+	err := repo.SetAdmin(context.Background(), 1, true)
+	require.Error(t, err)
+	assert.True(t, database.IsTimeout(err))
+}
@@ -0,0 +1,49 @@
+package user
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/clock"
+	"github.com/things-kit/example-db/internal/idgen"
+	"github.com/things-kit/example-db/internal/prefs"
+	"github.com/things-kit/example-db/internal/tenant"
+)
+
+// The repository takes created_at, updated_at and UUIDs from its clock and
+// ID generator, so the statement arguments are known exactly
+
+func TestRepositoryUsesClockAndIDs(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	key := idgen.SequenceUUID(1)
+
+	repo, mock := newMockRepository(t)
+	fake := clock.NewFake(now)
+	repo.clock, repo.ids = fake, idgen.NewSequence()
+
+	mock.ExpectQuery(`users\.create`).
+		WithArgs("John", "john@example.com", now, now, key, tenant.Default).
+		WillReturnRows(pgxmock.NewRows(userColumns).
+			AddRow(int64(1), "John", "john@example.com", now, now, false, key, prefs.Preferences{}, StatusActive))
+
+	u, err := repo.Create(ctx, CreateUserRequest{Name: "John", Email: "john@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, now, u.CreatedAt)
+	assert.Equal(t, key, u.UUID)
+
+	later := fake.Advance(time.Hour)
+	args := anyArgs(5)
+	args[2] = later
+	mock.ExpectQuery(`users\.update`).WithArgs(args...).
+		WillReturnRows(pgxmock.NewRows(userColumns).
+			AddRow(int64(1), "Jane", "john@example.com", now, later, false, key, prefs.Preferences{}, StatusActive))
+
+	u, err = repo.Update(ctx, 1, CreateUserRequest{Name: "Jane", Email: "john@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, later, u.UpdatedAt)
+}
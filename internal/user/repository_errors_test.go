@@ -0,0 +1,218 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/feature"
+	"github.com/things-kit/example-db/internal/prefs"
+)
+
+// The tests cover repository branches a real database rarely takes: driver
+// errors, rows that fail to scan and statements matching no row. pgxmock
+// plays the database; expectations match the statement names the queries
+// start with and the number of their arguments.
+
+var userColumns = []string{"id", "name", "email", "created_at", "updated_at", "is_admin", "uuid", "preferences", "status"}
+
+var errDriver = errors.New("read tcp 10.0.0.1:5432: connection reset by peer")
+
+func newMockRepository(t *testing.T) (*Repository, pgxmock.PgxConnIface) {
+	t.Helper()
+
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	return newRepository(nil, mock, NewMetrics()), mock
+}
+
+// anyArgs matches n arguments of any value
+func anyArgs(n int) []any {
+	args := make([]any, n)
+	for i := range args {
+		args[i] = pgxmock.AnyArg()
+	}
+	return args
+}
+
+func mockUserRow(rows *pgxmock.Rows, id int64) *pgxmock.Rows {
+	now := time.Now()
+	return rows.AddRow(id, "John", "john@example.com", now, now, false, uuid.New(), prefs.Preferences{}, StatusActive)
+}
+
+func TestRepositoryGetByIDErrors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("DriverError", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectQuery(`users\.get`).WithArgs(anyArgs(2)...).WillReturnError(errDriver)
+
+		_, err := repo.GetByID(ctx, 1)
+		assert.ErrorIs(t, err, errDriver)
+		assert.ErrorContains(t, err, "failed to get user")
+	})
+
+	t.Run("NoRows", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectQuery(`users\.get`).WithArgs(anyArgs(2)...).WillReturnRows(pgxmock.NewRows(userColumns))
+
+		_, err := repo.GetByID(ctx, 1)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("ScanError", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		rows := pgxmock.NewRows(userColumns).
+			AddRow(int64(1), "John", "john@example.com", "yesterday", time.Now(), false, uuid.New(), prefs.Preferences{}, StatusActive)
+		mock.ExpectQuery(`users\.get`).WithArgs(anyArgs(2)...).WillReturnRows(rows)
+
+		_, err := repo.GetByID(ctx, 1)
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, ErrNotFound)
+		assert.ErrorContains(t, err, "failed to get user")
+	})
+}
+
+func TestRepositoryListErrors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("DriverError", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectQuery(`ListUsers`).WithArgs(anyArgs(13)...).WillReturnError(errDriver)
+
+		_, err := repo.List(ctx, ListFilter{})
+		assert.ErrorIs(t, err, errDriver)
+		assert.ErrorContains(t, err, "failed to list users")
+	})
+
+	t.Run("RowError", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		rows := mockUserRow(mockUserRow(pgxmock.NewRows(userColumns), 1), 2).RowError(1, errDriver)
+		mock.ExpectQuery(`ListUsers`).WithArgs(anyArgs(13)...).WillReturnRows(rows)
+
+		users, err := repo.List(ctx, ListFilter{})
+		assert.ErrorIs(t, err, errDriver)
+		assert.Nil(t, users)
+	})
+}
+
+func TestRepositoryUpdateErrors(t *testing.T) {
+	ctx := context.Background()
+	req := CreateUserRequest{Name: "John", Email: "john@example.com"}
+
+	t.Run("NoRowsAffected", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectQuery(`users\.update`).WithArgs(anyArgs(5)...).WillReturnRows(pgxmock.NewRows(userColumns))
+
+		_, err := repo.Update(ctx, 1, req)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("EmailTaken", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectQuery(`users\.update`).WithArgs(anyArgs(5)...).WillReturnError(&pgconn.PgError{Code: "23505", ConstraintName: "users_email_key"})
+
+		_, err := repo.Update(ctx, 1, req)
+		assert.ErrorIs(t, err, ErrEmailTaken)
+	})
+
+	t.Run("DriverError", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectQuery(`users\.update`).WithArgs(anyArgs(5)...).WillReturnError(errDriver)
+
+		_, err := repo.Update(ctx, 1, req)
+		assert.ErrorIs(t, err, errDriver)
+		assert.ErrorContains(t, err, "failed to update user")
+	})
+}
+
+func TestRepositoryDeleteErrors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("NoRowsAffected", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectExec(`users\.delete`).WithArgs(anyArgs(3)...).WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+		_, err := repo.Delete(ctx, 1)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("DriverError", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectExec(`users\.delete`).WithArgs(anyArgs(3)...).WillReturnError(errDriver)
+
+		_, err := repo.Delete(ctx, 1)
+		assert.ErrorIs(t, err, errDriver)
+		assert.ErrorContains(t, err, "failed to delete user")
+	})
+}
+
+func TestRepositoryHardDeleteReturnsAvatarKey(t *testing.T) {
+	ctx := context.Background()
+	flags := feature.New(&feature.Config{Flags: map[string]feature.Flag{feature.SoftDelete: {}}})
+
+	t.Run("Deleted", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		repo.flags = flags
+		mock.ExpectQuery(`GetUserAvatarKey`).WithArgs(anyArgs(2)...).
+			WillReturnRows(pgxmock.NewRows([]string{"avatar_key"}).AddRow("avatars/1.png"))
+		mock.ExpectExec(`users\.delete`).WithArgs(anyArgs(2)...).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+
+		key, err := repo.Delete(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, "avatars/1.png", key, "the caller deletes the avatar of the removed row")
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		repo.flags = flags
+		mock.ExpectQuery(`GetUserAvatarKey`).WithArgs(anyArgs(2)...).WillReturnRows(pgxmock.NewRows([]string{"avatar_key"}))
+
+		_, err := repo.Delete(ctx, 1)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestRepositorySetAdminNoRowsAffected(t *testing.T) {
+	repo, mock := newMockRepository(t)
+	mock.ExpectExec(`SetUserAdmin`).WithArgs(anyArgs(4)...).WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	assert.ErrorIs(t, repo.SetAdmin(context.Background(), 1, true), ErrNotFound)
+}
+
+func TestRepositoryListChangesErrors(t *testing.T) {
+	ctx := context.Background()
+	changeColumns := []string{"user_id", "seq", "created", "changed_at", "uuid"}
+
+	t.Run("LimitClamped", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		args := append(anyArgs(4), int32(maxChanges+1))
+		mock.ExpectQuery(`ListUserChanges`).WithArgs(args...).WillReturnRows(pgxmock.NewRows(changeColumns))
+
+		changes, err := repo.ListChanges(ctx, ChangeFilter{Limit: math.MaxInt32 + 1})
+		require.NoError(t, err)
+		assert.Empty(t, changes)
+	})
+
+	t.Run("InvalidUUID", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		rows := pgxmock.NewRows(changeColumns).AddRow(int64(1), int64(7), false, time.Now(), "not-a-uuid")
+		mock.ExpectQuery(`ListUserChanges`).WithArgs(anyArgs(5)...).WillReturnRows(rows)
+		mock.ExpectQuery(`GetChangedUsers`).WithArgs(anyArgs(2)...).
+			WillReturnRows(pgxmock.NewRows(append(userColumns, "deleted_at")))
+
+		_, err := repo.ListChanges(ctx, ChangeFilter{Limit: 10})
+		assert.ErrorContains(t, err, "invalid UUID of user 1")
+	})
+}
@@ -0,0 +1,201 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/database"
+	"github.com/things-kit/example-db/internal/tenant"
+)
+
+// blockingDB serves a single user row for every query, holding each query
+// open until release is closed
+type blockingDB struct {
+	queries atomic.Int64
+	started chan struct{}
+	release chan struct{}
+}
+
+func (d *blockingDB) Exec(context.Context, string, ...any) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, errors.New("not supported")
+}
+
+func (d *blockingDB) Query(context.Context, string, ...any) (pgx.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func (d *blockingDB) CopyFrom(context.Context, pgx.Identifier, []string, pgx.CopyFromSource) (int64, error) {
+	return 0, errors.New("not supported")
+}
+
+func (d *blockingDB) QueryRow(ctx context.Context, _ string, args ...any) pgx.Row {
+	return userRow{d: d, ctx: ctx, id: args[0].(int64)}
+}
+
+type userRow struct {
+	d   *blockingDB
+	ctx context.Context
+	id  int64
+}
+
+// Scan runs the query, as pgx does for QueryRow
+func (r userRow) Scan(dest ...any) error {
+	if r.d.queries.Add(1) == 1 {
+		close(r.d.started)
+	}
+
+	select {
+	case <-r.d.release:
+	case <-r.ctx.Done():
+		return r.ctx.Err()
+	}
+
+	now := time.Now()
+	*dest[0].(*int64) = r.id
+	*dest[1].(*string) = "John"
+	*dest[2].(*string) = "john@example.com"
+	*dest[3].(*time.Time) = now
+	*dest[4].(*time.Time) = now
+	return nil
+}
+
+func newBlockingRepository(t *testing.T) (*Repository, *blockingDB, *Metrics) {
+	t.Helper()
+
+	d := &blockingDB{started: make(chan struct{}), release: make(chan struct{})}
+	metrics := NewMetrics()
+	return newRepository(nil, d, metrics), d, metrics
+}
+
+func TestGetByIDCoalescesConcurrentCalls(t *testing.T) {
+	repo, d, metrics := newBlockingRepository(t)
+	ctx := context.Background()
+
+	const callers = 10
+	results := make([]*User, callers)
+	errs := make([]error, callers)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		results[0], errs[0] = repo.GetByID(ctx, 42)
+	}()
+	<-d.started
+
+	for i := 1; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = repo.GetByID(ctx, 42)
+		}(i)
+	}
+
+	// Give the followers time to join the in-flight query before it returns
+	time.Sleep(50 * time.Millisecond)
+	close(d.release)
+	wg.Wait()
+
+	for i := 0; i < callers; i++ {
+		require.NoError(t, errs[i])
+		assert.Equal(t, int64(42), results[i].ID)
+	}
+	assert.NotSame(t, results[0], results[1])
+	assert.Equal(t, int64(1), d.queries.Load())
+	assert.Equal(t, int64(1), metrics.GetByIDQueries.Load())
+	assert.Equal(t, int64(callers-1), metrics.GetByIDCoalesced.Load())
+}
+
+func TestGetByIDDoesNotCoalesceAcrossTenants(t *testing.T) {
+	repo, d, _ := newBlockingRepository(t)
+
+	var wg sync.WaitGroup
+	for _, id := range []string{"acme", "globex"} {
+		wg.Add(1)
+		go func(ctx context.Context) {
+			defer wg.Done()
+			_, err := repo.GetByID(ctx, 42)
+			assert.NoError(t, err)
+		}(tenant.WithTenant(context.Background(), id))
+	}
+
+	<-d.started
+	time.Sleep(50 * time.Millisecond)
+	close(d.release)
+	wg.Wait()
+
+	assert.Equal(t, int64(2), d.queries.Load())
+}
+
+func TestGetByIDCancelledCallerDoesNotFailOthers(t *testing.T) {
+	repo, d, _ := newBlockingRepository(t)
+
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := repo.GetByID(leaderCtx, 7)
+		leaderErr <- err
+	}()
+	<-d.started
+
+	type result struct {
+		user *User
+		err  error
+	}
+	follower := make(chan result, 1)
+	go func() {
+		u, err := repo.GetByID(context.Background(), 7)
+		follower <- result{u, err}
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-leaderErr, context.Canceled)
+
+	close(d.release)
+	res := <-follower
+	require.NoError(t, res.err)
+	assert.Equal(t, int64(7), res.user.ID)
+}
+
+// execDB records Exec calls and reports one affected row
+type execDB struct {
+	blockingDB
+	sql  string
+	args []any
+}
+
+func (d *execDB) Exec(_ context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	d.sql, d.args = sql, args
+	return pgconn.NewCommandTag("UPDATE 1"), nil
+}
+
+func TestRepositoryRunsGeneratedQueries(t *testing.T) {
+	d := &execDB{}
+	repo := newRepository(nil, d, NewMetrics())
+
+	ctx := tenant.WithTenant(context.Background(), "acme")
+	require.NoError(t, repo.SetAdmin(ctx, 9, true))
+	assert.Contains(t, d.sql, "-- name: SetUserAdmin :execrows")
+	require.Len(t, d.args, 4)
+	assert.Equal(t, true, d.args[0])
+	assert.Equal(t, int64(9), d.args[2])
+	assert.Equal(t, "acme", d.args[3], "queries are scoped to the tenant")
+}
+
+func TestStatementTimeout(t *testing.T) {
+	repo, _, _ := newBlockingRepository(t)
+	repo.timeout = 20 * time.Millisecond
+
+	_, err := repo.GetByID(context.Background(), 1)
+	require.Error(t, err)
+	assert.True(t, database.IsTimeout(err), "a query that never returns times out")
+}
@@ -0,0 +1,548 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	netmail "net/mail"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/things-kit/example-db/internal/clock"
+	"github.com/things-kit/example-db/internal/events"
+	"github.com/things-kit/example-db/internal/i18n"
+	"github.com/things-kit/example-db/internal/idgen"
+	"github.com/things-kit/example-db/internal/mail"
+	"github.com/things-kit/example-db/internal/outbox"
+	"github.com/things-kit/example-db/internal/prefs"
+	"github.com/things-kit/example-db/internal/reqlog"
+	"github.com/things-kit/example-db/internal/storage"
+	"github.com/things-kit/example-db/internal/tenant"
+	"github.com/things-kit/module/log"
+)
+
+// Errors returned by the Service. Handlers map them to HTTP status codes.
+var (
+	// ErrNotFound is returned when no live user has the given ID or email
+	ErrNotFound = errors.New("user not found")
+	// ErrEmailTaken is returned when another user already has the email
+	ErrEmailTaken = errors.New("email is already in use")
+	// ErrInvalid wraps the reason a request failed validation
+	ErrInvalid = errors.New("invalid user")
+	// ErrStatusConflict is returned for a status change the user's current
+	// status doesn't allow
+	ErrStatusConflict = errors.New("status change not allowed")
+	// ErrInactive is returned for suspended and deactivated users by
+	// EnsureActive and by the changes they can't make to their account
+	ErrInactive = errors.New("user is not active")
+	// ErrResyncRequired is returned for a changes feed position that is
+	// unknown or older than the purge retention: deletions after it may no
+	// longer be listed, so the client has to download the users again
+	ErrResyncRequired = errors.New("changes since this position are no longer available")
+)
+
+// transitions lists the statuses each status can change to
+var transitions = map[string][]string{
+	StatusActive:      {StatusSuspended, StatusDeactivated},
+	StatusSuspended:   {StatusActive, StatusDeactivated},
+	StatusDeactivated: {StatusActive},
+}
+
+// maxNameLength matches the size of the users.name and users.email columns
+const maxNameLength = 255
+
+// maxPreferencesSize bounds the encoded preferences of a user
+const maxPreferencesSize = 8 << 10
+
+// maxImportSize bounds the users created by one Import
+const maxImportSize = 10000
+
+// Limits of the profile fields; maxPhoneLength matches profiles.phone
+const (
+	maxPhoneLength       = 32
+	maxProfileTextLength = 2000
+)
+
+//go:generate mockgen -source=service.go -destination=usermock/repository.go -package=usermock
+
+// UserRepository is the data access the Service needs. *Repository implements
+// it against Postgres; unit tests can use the generated mock in usermock.
+type UserRepository interface {
+	Create(ctx context.Context, req CreateUserRequest) (*User, error)
+	CopyFrom(ctx context.Context, reqs []CreateUserRequest) (int64, error)
+	GetByID(ctx context.Context, id int64) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	GetIDByUUID(ctx context.Context, key uuid.UUID) (int64, error)
+	GetForUpdate(ctx context.Context, id int64) (*User, error)
+	List(ctx context.Context, f ListFilter) ([]*User, error)
+	ListWithTotal(ctx context.Context, f ListFilter) ([]*User, int64, error)
+	Count(ctx context.Context, f ListFilter) (int64, error)
+	Stream(ctx context.Context, f ListFilter, fn func(*User) error) error
+	Update(ctx context.Context, id int64, req CreateUserRequest) (*User, error)
+	Delete(ctx context.Context, id int64) (string, error)
+	SetAdmin(ctx context.Context, id int64, admin bool) error
+	SetPreferences(ctx context.Context, id int64, p prefs.Preferences) (*User, error)
+	SetStatus(ctx context.Context, id int64, status string) (*User, error)
+	SetAvatar(ctx context.Context, id int64, key string) error
+	GetAvatarKey(ctx context.Context, id int64) (string, error)
+	Erase(ctx context.Context, id int64) (string, error)
+	AddAudit(ctx context.Context, id int64, action string, before, after any) error
+	ListAudit(ctx context.Context, id int64, limit int) ([]AuditEntry, error)
+	MoveAudit(ctx context.Context, from, to int64) (int64, error)
+	ListChanges(ctx context.Context, f ChangeFilter) ([]Change, error)
+	GetWithProfile(ctx context.Context, id int64) (*User, *Profile, error)
+	Profiles() ProfileRepository
+	WithTx(ctx context.Context, fn func(repo UserRepository) error) error
+	Tx() pgx.Tx
+}
+
+// ProfileRepository is the data access for user profiles. The one returned by
+// UserRepository.Profiles shares its transaction.
+type ProfileRepository interface {
+	Get(ctx context.Context, userID int64) (*Profile, error)
+	List(ctx context.Context, userIDs []int64) (map[int64]*Profile, error)
+	Upsert(ctx context.Context, userID int64, req ProfileRequest) (*Profile, error)
+}
+
+// Service owns the user business rules: it validates requests, runs
+// mutations in transactions and records change events.
+// Events are written to the outbox in the same transaction as the mutation
+// and forwarded to the broker by the outbox relay. Every mutation is also
+// recorded in the audit log within that transaction.
+type Service struct {
+	repo  UserRepository
+	store *storage.Store
+	mail  *mail.Queue
+	clock clock.Clock
+	ids   idgen.Generator
+	log   log.Logger
+	// retention is how long the purge worker keeps deleted users, 0 when
+	// it doesn't run
+	retention time.Duration
+}
+
+// NewService creates a new user service. clk and ids stamp and identify
+// events and avatars; nil uses the system clock and random UUIDs.
+func NewService(repo UserRepository, store *storage.Store, mailQueue *mail.Queue, clk clock.Clock, ids idgen.Generator, logger log.Logger) *Service {
+	return &Service{
+		repo:  repo,
+		store: store,
+		mail:  mailQueue,
+		clock: clock.Or(clk),
+		ids:   idgen.Or(ids),
+		log:   logger,
+	}
+}
+
+// Create creates a user and records a UserCreated event
+func (s *Service) Create(ctx context.Context, req CreateUserRequest) (*User, error) {
+	req, err := normalize(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var user *User
+	err = s.repo.WithTx(ctx, func(repo UserRepository) error {
+		var err error
+		if user, err = repo.Create(ctx, req); err != nil {
+			return err
+		}
+		if err := repo.AddAudit(ctx, user.ID, AuditCreate, nil, user); err != nil {
+			return err
+		}
+		return s.recordEvent(ctx, repo.Tx(), events.UserCreated, user.ID, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.sendWelcome(user)
+	return user, nil
+}
+
+// Import creates users in bulk and returns the number created. All users are
+// validated first and either all or none are created. Like Create, every
+// imported user gets an audit entry and a UserCreated event in the same
+// transaction, but no welcome email.
+func (s *Service) Import(ctx context.Context, reqs []CreateUserRequest) (int64, error) {
+	if len(reqs) > maxImportSize {
+		return 0, i18n.Wrap(ErrInvalid, "at most %d users can be imported at once", maxImportSize)
+	}
+
+	normalized := make([]CreateUserRequest, len(reqs))
+	for i, req := range reqs {
+		var err error
+		if normalized[i], err = normalize(req); err != nil {
+			return 0, fmt.Errorf("user %d: %w", i, err)
+		}
+	}
+
+	var n int64
+	err := s.repo.WithTx(ctx, func(repo UserRepository) (err error) {
+		if n, err = repo.CopyFrom(ctx, normalized); err != nil {
+			return err
+		}
+
+		// COPY returns no rows: look the new users up for their IDs
+		for _, req := range normalized {
+			user, err := repo.GetByEmail(ctx, req.Email)
+			if err != nil {
+				return err
+			}
+			if err := repo.AddAudit(ctx, user.ID, AuditCreate, nil, user); err != nil {
+				return err
+			}
+			if err := s.recordEvent(ctx, repo.Tx(), events.UserCreated, user.ID, user); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// GetByID retrieves a user by ID
+func (s *Service) GetByID(ctx context.Context, id int64) (*User, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// ResolveID returns the ID of the user with the UUID key
+func (s *Service) ResolveID(ctx context.Context, key uuid.UUID) (int64, error) {
+	return s.repo.GetIDByUUID(ctx, key)
+}
+
+// GetByEmail retrieves a user by email
+func (s *Service) GetByEmail(ctx context.Context, email string) (*User, error) {
+	return s.repo.GetByEmail(ctx, email)
+}
+
+// List retrieves the users matching the filter
+func (s *Service) List(ctx context.Context, f ListFilter) ([]*User, error) {
+	return s.repo.List(ctx, f)
+}
+
+// ListWithTotal retrieves a page of the users matching the filter and the
+// number of users matching it in all
+func (s *Service) ListWithTotal(ctx context.Context, f ListFilter) ([]*User, int64, error) {
+	return s.repo.ListWithTotal(ctx, f)
+}
+
+// Count returns the number of users matching the filter
+func (s *Service) Count(ctx context.Context, f ListFilter) (int64, error) {
+	return s.repo.Count(ctx, f)
+}
+
+// Stream calls fn for every user matching the filter, reading them in
+// batches instead of all at once
+func (s *Service) Stream(ctx context.Context, f ListFilter, fn func(*User) error) error {
+	return s.repo.Stream(ctx, f, fn)
+}
+
+// GrantAdmin gives a user administrator rights
+func (s *Service) GrantAdmin(ctx context.Context, id int64) error {
+	return s.repo.WithTx(ctx, func(repo UserRepository) error {
+		if err := repo.SetAdmin(ctx, id, true); err != nil {
+			return err
+		}
+		return repo.AddAudit(ctx, id, AuditGrantAdmin, nil, map[string]bool{"is_admin": true})
+	})
+}
+
+// Audit retrieves up to limit recorded changes to a user, newest first
+func (s *Service) Audit(ctx context.Context, id int64, limit int) ([]AuditEntry, error) {
+	return s.repo.ListAudit(ctx, id, limit)
+}
+
+// Update updates a user and records a UserUpdated event
+func (s *Service) Update(ctx context.Context, id int64, req CreateUserRequest) (*User, error) {
+	req, err := normalize(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var user *User
+	err = s.repo.WithTx(ctx, func(repo UserRepository) error {
+		old, err := repo.GetForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := checkActive(old); err != nil {
+			return err
+		}
+		if user, err = repo.Update(ctx, id, req); err != nil {
+			return err
+		}
+		if err := repo.AddAudit(ctx, id, AuditUpdate, old, user); err != nil {
+			return err
+		}
+		return s.recordEvent(ctx, repo.Tx(), events.UserUpdated, user.ID, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// Delete deletes a user and records a UserDeleted event. The avatar of a
+// user deleted for good is deleted from object storage once the transaction
+// committed.
+func (s *Service) Delete(ctx context.Context, id int64) error {
+	var key string
+	err := s.repo.WithTx(ctx, func(repo UserRepository) error {
+		old, err := repo.GetForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if key, err = repo.Delete(ctx, id); err != nil {
+			return err
+		}
+		if err := repo.AddAudit(ctx, id, AuditDelete, old, nil); err != nil {
+			return err
+		}
+		return s.recordEvent(ctx, repo.Tx(), events.UserDeleted, id, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	if key != "" {
+		if err := s.store.Delete(ctx, key); err != nil {
+			reqlog.FromContext(ctx, s.log).Error("Failed to delete avatar of deleted user", err, log.Field{Key: "key", Value: key})
+		}
+	}
+
+	return nil
+}
+
+// PatchPreferences applies a JSON merge patch to a user's preferences and
+// records a UserUpdated event
+func (s *Service) PatchPreferences(ctx context.Context, id int64, patch prefs.Preferences) (*User, error) {
+	var user *User
+	err := s.repo.WithTx(ctx, func(repo UserRepository) error {
+		old, err := repo.GetForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := checkActive(old); err != nil {
+			return err
+		}
+
+		merged := old.Preferences.Merge(patch)
+		if data, err := json.Marshal(merged); err != nil || len(data) > maxPreferencesSize {
+			return i18n.Wrap(ErrInvalid, "preferences are larger than %d bytes", maxPreferencesSize)
+		}
+
+		if user, err = repo.SetPreferences(ctx, id, merged); err != nil {
+			return err
+		}
+		if err := repo.AddAudit(ctx, id, AuditUpdatePreferences, old.Preferences, user.Preferences); err != nil {
+			return err
+		}
+		return s.recordEvent(ctx, repo.Tx(), events.UserUpdated, user.ID, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// GetWithProfile retrieves a user and their profile, which is nil if they
+// have none
+func (s *Service) GetWithProfile(ctx context.Context, id int64) (*User, *Profile, error) {
+	return s.repo.GetWithProfile(ctx, id)
+}
+
+// Profiles retrieves the profiles of users in one query, keyed by user ID
+func (s *Service) Profiles(ctx context.Context, users []*User) (map[int64]*Profile, error) {
+	ids := make([]int64, len(users))
+	for i, u := range users {
+		ids[i] = u.ID
+	}
+	return s.repo.Profiles().List(ctx, ids)
+}
+
+// UpdateProfile creates or replaces the profile of a user
+func (s *Service) UpdateProfile(ctx context.Context, id int64, req ProfileRequest) (*Profile, error) {
+	req, err := normalizeProfile(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var profile *Profile
+	err = s.repo.WithTx(ctx, func(repo UserRepository) error {
+		u, err := repo.GetForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := checkActive(u); err != nil {
+			return err
+		}
+
+		old, err := repo.Profiles().Get(ctx, id)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		if profile, err = repo.Profiles().Upsert(ctx, id, req); err != nil {
+			return err
+		}
+		return repo.AddAudit(ctx, id, AuditUpdateProfile, old, profile)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+// Suspend suspends a user, hiding them from default listings and blocking
+// their logins
+func (s *Service) Suspend(ctx context.Context, id int64) (*User, error) {
+	return s.setStatus(ctx, id, StatusSuspended, AuditSuspend)
+}
+
+// Activate reactivates a suspended or deactivated user
+func (s *Service) Activate(ctx context.Context, id int64) (*User, error) {
+	return s.setStatus(ctx, id, StatusActive, AuditActivate)
+}
+
+// Deactivate deactivates a user who closed their account
+func (s *Service) Deactivate(ctx context.Context, id int64) (*User, error) {
+	return s.setStatus(ctx, id, StatusDeactivated, AuditDeactivate)
+}
+
+// setStatus changes the status of a user if the current status allows it and
+// records a UserUpdated event
+func (s *Service) setStatus(ctx context.Context, id int64, status, action string) (*User, error) {
+	var user *User
+	err := s.repo.WithTx(ctx, func(repo UserRepository) error {
+		old, err := repo.GetForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(transitions[old.Status], status) {
+			return i18n.Wrap(ErrStatusConflict, "user is %s", old.Status)
+		}
+
+		if user, err = repo.SetStatus(ctx, id, status); err != nil {
+			return err
+		}
+		if err := repo.AddAudit(ctx, id, action,
+			map[string]string{"status": old.Status}, map[string]string{"status": status}); err != nil {
+			return err
+		}
+		return s.recordEvent(ctx, repo.Tx(), events.UserUpdated, user.ID, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// EnsureActive returns an error wrapping ErrInactive unless the user is
+// active. Authentication must call it before letting a user log in, so
+// suspended and deactivated users can't. UploadAvatar calls it too; Update,
+// PatchPreferences and UpdateProfile check the row they lock instead.
+func (s *Service) EnsureActive(ctx context.Context, id int64) error {
+	user, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return checkActive(user)
+}
+
+// checkActive returns an error wrapping ErrInactive unless u is active.
+// Suspended and deactivated accounts are frozen: their name, email,
+// preferences, profile and avatar can't change until they are reactivated.
+func checkActive(u *User) error {
+	if u.Status != StatusActive {
+		return i18n.Wrap(ErrInactive, "user is %s", u.Status)
+	}
+	return nil
+}
+
+// normalize trims the name, lowercases the email and checks both, returning
+// an error wrapping ErrInvalid if the request can't be stored
+func normalize(req CreateUserRequest) (CreateUserRequest, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+
+	switch {
+	case req.Name == "":
+		return req, i18n.Wrap(ErrInvalid, "name is required")
+	case len(req.Name) > maxNameLength:
+		return req, i18n.Wrap(ErrInvalid, "name is longer than %d characters", maxNameLength)
+	case req.Email == "":
+		return req, i18n.Wrap(ErrInvalid, "email is required")
+	case len(req.Email) > maxNameLength:
+		return req, i18n.Wrap(ErrInvalid, "email is longer than %d characters", maxNameLength)
+	}
+
+	// Reject display-name forms such as "John <john@example.com>"
+	if addr, err := netmail.ParseAddress(req.Email); err != nil || addr.Address != req.Email {
+		return req, i18n.Wrap(ErrInvalid, "email is not a valid address")
+	}
+
+	return req, nil
+}
+
+// normalizeProfile trims the profile fields and checks their length and the
+// avatar URL, returning an error wrapping ErrInvalid if they can't be stored
+func normalizeProfile(req ProfileRequest) (ProfileRequest, error) {
+	req.Phone = strings.TrimSpace(req.Phone)
+	req.Address = strings.TrimSpace(req.Address)
+	req.Bio = strings.TrimSpace(req.Bio)
+	req.AvatarURL = strings.TrimSpace(req.AvatarURL)
+
+	switch {
+	case len(req.Phone) > maxPhoneLength:
+		return req, i18n.Wrap(ErrInvalid, "phone is longer than %d characters", maxPhoneLength)
+	case len(req.Address) > maxProfileTextLength:
+		return req, i18n.Wrap(ErrInvalid, "address is longer than %d characters", maxProfileTextLength)
+	case len(req.Bio) > maxProfileTextLength:
+		return req, i18n.Wrap(ErrInvalid, "bio is longer than %d characters", maxProfileTextLength)
+	}
+
+	if req.AvatarURL != "" {
+		u, err := url.Parse(req.AvatarURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return req, i18n.Wrap(ErrInvalid, "avatar_url must be an http or https URL")
+		}
+	}
+
+	return req, nil
+}
+
+// recordEvent writes an event of the context's tenant to the outbox within the
+// given transaction
+func (s *Service) recordEvent(ctx context.Context, tx pgx.Tx, eventType string, id int64, data any) error {
+	evt, err := events.New(eventType, id, data, s.clock, s.ids)
+	if err != nil {
+		return err
+	}
+	evt.TenantID = tenant.FromContext(ctx)
+	return outbox.Write(ctx, tx, evt)
+}
+
+// sendWelcome queues the welcome email; failures never fail the signup
+func (s *Service) sendWelcome(user *User) {
+	msg, err := mail.Render("welcome", user.Email, user)
+	if err == nil {
+		err = s.mail.Enqueue(msg)
+	}
+
+	if err != nil {
+		s.log.Error("Failed to queue welcome email", err, log.Field{Key: "id", Value: user.ID})
+	}
+}
@@ -0,0 +1,26 @@
+package user_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/user"
+	"github.com/things-kit/example-db/internal/user/usermock"
+	"go.uber.org/mock/gomock"
+)
+
+func TestServiceEnsureActive(t *testing.T) {
+	ctx := context.Background()
+	repo := usermock.NewMockUserRepository(gomock.NewController(t))
+	svc := user.NewService(repo, nil, nil, nil, nil, testutil.NopLogger{})
+
+	repo.EXPECT().GetByID(gomock.Any(), int64(1)).Return(&user.User{ID: 1, Status: user.StatusActive}, nil)
+	repo.EXPECT().GetByID(gomock.Any(), int64(2)).Return(&user.User{ID: 2, Status: user.StatusSuspended}, nil)
+	repo.EXPECT().GetByID(gomock.Any(), int64(3)).Return(nil, user.ErrNotFound)
+
+	assert.NoError(t, svc.EnsureActive(ctx, 1))
+	assert.ErrorIs(t, svc.EnsureActive(ctx, 2), user.ErrInactive)
+	assert.ErrorIs(t, svc.EnsureActive(ctx, 3), user.ErrNotFound)
+}
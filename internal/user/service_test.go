@@ -0,0 +1,27 @@
+package user
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	req, err := normalize(CreateUserRequest{Name: "  John ", Email: " John@Example.COM "})
+	require.NoError(t, err)
+	assert.Equal(t, CreateUserRequest{Name: "John", Email: "john@example.com"}, req)
+
+	for name, req := range map[string]CreateUserRequest{
+		"missing name":  {Email: "john@example.com"},
+		"blank name":    {Name: "   ", Email: "john@example.com"},
+		"long name":     {Name: strings.Repeat("a", maxNameLength+1), Email: "john@example.com"},
+		"missing email": {Name: "John"},
+		"bad email":     {Name: "John", Email: "not-an-email"},
+		"display name":  {Name: "John", Email: "John <john@example.com>"},
+	} {
+		_, err := normalize(req)
+		assert.ErrorIs(t, err, ErrInvalid, name)
+	}
+}
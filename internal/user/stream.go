@@ -0,0 +1,111 @@
+package user
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/things-kit/example-db/internal/user/userdb"
+)
+
+// streamCursor names the cursor of Stream; it is local to its transaction
+const streamCursor = "users_stream"
+
+// streamBatch is the number of rows Stream fetches at a time
+const streamBatch = 1000
+
+// Stream calls fn for every user matching the filter, in the order of List,
+// without holding them all in memory. It reads the users from a cursor in a
+// read-only transaction, streamBatch rows at a time, so the statement timeout
+// bounds each fetch rather than the whole stream.
+//
+// The stream holds a limiter slot and a connection until it ends, and its
+// database errors count towards the circuit breaker; errors fn returns don't.
+// Stream is not retried, as fn may already have seen some users; it stops at
+// the first error fn returns.
+func (r *Repository) Stream(ctx context.Context, f ListFilter, fn func(*User) error) error {
+	ctx, span := tracer.Start(ctx, "user.Repository.Stream")
+	defer span.End()
+
+	params, err := listParams(ctx, f)
+	if err != nil {
+		return err
+	}
+
+	var fnErr error
+	err = r.limiter.Do(ctx, func() error {
+		return r.breaker.Do(func() error {
+			return r.stream(ctx, params, func(u *User) bool {
+				fnErr = fn(u)
+				return fnErr == nil
+			})
+		})
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stream users: %w", err)
+	}
+	return nil
+}
+
+// stream runs the cursor of Stream, calling yield for every user until it
+// returns false. Only database errors are returned.
+func (r *Repository) stream(ctx context.Context, params userdb.ListUsersParams, yield func(*User) bool) error {
+	pool := r.pool
+	if replica := r.replicas.Pick(); replica != nil {
+		pool = replica.Pool
+	}
+
+	tx, err := pool.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	cursor := userdb.New(&cursorDB{DBTX: r.instrument(tx), name: streamCursor, batch: streamBatch})
+	for {
+		var rows []userdb.ListUsersRow
+		err := r.withTimeout(ctx, func(ctx context.Context) (err error) {
+			rows, err = cursor.ListUsers(ctx, params)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		for _, row := range rows {
+			user := User(row)
+			if !yield(&user) {
+				return nil
+			}
+		}
+	}
+}
+
+// cursorDB runs the statement of a generated query through a cursor: the
+// first Query declares the cursor over the statement, and every Query,
+// including the first, fetches the next batch of its rows. sqlc can't
+// generate cursors, so Stream runs the generated ListUsers on a cursorDB
+// until it returns no rows.
+type cursorDB struct {
+	DBTX
+	name     string
+	batch    int
+	declared bool
+}
+
+// Query implements DBTX
+func (c *cursorDB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	if !c.declared {
+		if _, err := c.DBTX.Exec(ctx, "DECLARE "+c.name+" NO SCROLL CURSOR FOR\n"+sql, args...); err != nil {
+			return nil, err
+		}
+		c.declared = true
+	}
+	return c.DBTX.Query(ctx, fmt.Sprintf("FETCH %d FROM %s", c.batch, c.name))
+}
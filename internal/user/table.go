@@ -0,0 +1,48 @@
+package user
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/things-kit/example-db/internal/crud"
+	"github.com/things-kit/example-db/internal/tenant"
+	"github.com/things-kit/example-db/internal/user/userdb"
+)
+
+// usersTable maps User to the users table for the generic CRUD statements
+var usersTable = crud.Table[User]{
+	Name:    "users",
+	Key:     "id",
+	Columns: []string{"id", "name", "email", "created_at", "updated_at", "is_admin", "uuid", "preferences", "status"},
+	Scan: func(row pgx.Row, u *User) error {
+		return row.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt, &u.UpdatedAt, &u.IsAdmin, &u.UUID, &u.Preferences, &u.Status)
+	},
+	InsertColumns: []string{"name", "email", "created_at", "updated_at", "uuid"},
+	InsertValues: func(u *User) []any {
+		return []any{u.Name, u.Email, u.CreatedAt, u.UpdatedAt, u.UUID}
+	},
+	UpdateColumns: []string{"name", "email", "updated_at"},
+	UpdateValues: func(u *User) []any {
+		return []any{u.Name, u.Email, u.UpdatedAt}
+	},
+	SoftDelete: "deleted_at",
+	Touch:      "updated_at",
+	Scope:      "tenant_id",
+	ScopeValue: func(ctx context.Context) any { return tenant.FromContext(ctx) },
+}
+
+// usersHardDeleteTable is usersTable with Delete removing the row, for when
+// the soft_delete flag is off
+var usersHardDeleteTable = func() crud.Table[User] {
+	t := usersTable
+	t.SoftDelete, t.Touch = "", ""
+	return t
+}()
+
+// conn is what the repository runs its statements on: the generic CRUD
+// statements for users and the sqlc-generated queries for everything else
+type conn struct {
+	*userdb.Queries
+	users     crud.Repo[User]
+	hardUsers crud.Repo[User]
+}
@@ -0,0 +1,596 @@
+// Package usertest checks that a user.UserRepository keeps the contract the
+// Service relies on. The same tests run against the SQL repository in the
+// integration tests and against the in-memory one in unit tests, so the two
+// can't drift apart.
+package usertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/prefs"
+	"github.com/things-kit/example-db/internal/tenant"
+	"github.com/things-kit/example-db/internal/user"
+)
+
+// TestRepository runs the contract tests on the repositories returned by
+// newRepo, which must be empty and not shared with other tests
+func TestRepository(t *testing.T, newRepo func(t *testing.T) user.UserRepository) {
+	tests := map[string]func(t *testing.T, repo user.UserRepository){
+		"CreateAndGet":      testCreateAndGet,
+		"EmailTaken":        testEmailTaken,
+		"NotFound":          testNotFound,
+		"Update":            testUpdate,
+		"Delete":            testDelete,
+		"Tenants":           testTenants,
+		"ListOrder":         testListOrder,
+		"ListFilter":        testListFilter,
+		"ListPages":         testListPages,
+		"CopyFrom":          testCopyFrom,
+		"WithTx":            testWithTx,
+		"AdminAndAvatar":    testAdminAndAvatar,
+		"Profiles":          testProfiles,
+		"Audit":             testAudit,
+		"Erase":             testErase,
+		"Changes":           testChanges,
+		"PreferencesFilter": testPreferencesFilter,
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test(t, newRepo(t))
+		})
+	}
+}
+
+// create creates users named after their position, one millisecond apart so
+// their order by created_at is unambiguous
+func create(t *testing.T, ctx context.Context, repo user.UserRepository, n int) []*user.User {
+	t.Helper()
+
+	users := make([]*user.User, n)
+	for i := range users {
+		if i > 0 {
+			time.Sleep(time.Millisecond)
+		}
+		u, err := repo.Create(ctx, user.CreateUserRequest{
+			Name:  fmt.Sprintf("User %d", i),
+			Email: fmt.Sprintf("user%d@example.com", i),
+		})
+		require.NoError(t, err)
+		users[i] = u
+	}
+	return users
+}
+
+// ids returns the IDs of users in order
+func ids(users []*user.User) []int64 {
+	out := make([]int64, len(users))
+	for i, u := range users {
+		out[i] = u.ID
+	}
+	return out
+}
+
+func testCreateAndGet(t *testing.T, repo user.UserRepository) {
+	ctx := context.Background()
+
+	created, err := repo.Create(ctx, user.CreateUserRequest{Name: "Ann", Email: "ann@example.com"})
+	require.NoError(t, err)
+	assert.Positive(t, created.ID)
+	assert.NotZero(t, created.UUID)
+	assert.Equal(t, user.StatusActive, created.Status)
+	assert.Empty(t, created.Preferences)
+	assert.False(t, created.IsAdmin)
+	assert.False(t, created.CreatedAt.IsZero())
+
+	got, err := repo.GetByID(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, created, got)
+
+	got, err = repo.GetByEmail(ctx, "ann@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, got.ID)
+
+	id, err := repo.GetIDByUUID(ctx, created.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, id)
+}
+
+func testEmailTaken(t *testing.T, repo user.UserRepository) {
+	ctx := context.Background()
+	req := user.CreateUserRequest{Name: "Ann", Email: "ann@example.com"}
+
+	first, err := repo.Create(ctx, req)
+	require.NoError(t, err)
+
+	_, err = repo.Create(ctx, req)
+	assert.ErrorIs(t, err, user.ErrEmailTaken)
+
+	// A deleted user no longer holds on to the address
+	_, err = repo.Delete(ctx, first.ID)
+	require.NoError(t, err)
+	_, err = repo.Create(ctx, req)
+	assert.NoError(t, err)
+}
+
+func testNotFound(t *testing.T, repo user.UserRepository) {
+	ctx := context.Background()
+	const missing = 1 << 40
+
+	_, err := repo.GetByID(ctx, missing)
+	assert.ErrorIs(t, err, user.ErrNotFound)
+	_, err = repo.GetByEmail(ctx, "nobody@example.com")
+	assert.ErrorIs(t, err, user.ErrNotFound)
+	_, err = repo.Update(ctx, missing, user.CreateUserRequest{Name: "Ann", Email: "ann@example.com"})
+	assert.ErrorIs(t, err, user.ErrNotFound)
+	_, err = repo.Delete(ctx, missing)
+	assert.ErrorIs(t, err, user.ErrNotFound)
+	assert.ErrorIs(t, repo.SetAdmin(ctx, missing, true), user.ErrNotFound)
+	_, err = repo.SetStatus(ctx, missing, user.StatusSuspended)
+	assert.ErrorIs(t, err, user.ErrNotFound)
+	_, err = repo.SetPreferences(ctx, missing, prefs.Preferences{"theme": "dark"})
+	assert.ErrorIs(t, err, user.ErrNotFound)
+	assert.ErrorIs(t, repo.SetAvatar(ctx, missing, "avatars/1"), user.ErrNotFound)
+	_, err = repo.GetAvatarKey(ctx, missing)
+	assert.ErrorIs(t, err, user.ErrNotFound)
+	_, _, err = repo.GetWithProfile(ctx, missing)
+	assert.ErrorIs(t, err, user.ErrNotFound)
+}
+
+func testUpdate(t *testing.T, repo user.UserRepository) {
+	ctx := context.Background()
+	users := create(t, ctx, repo, 2)
+
+	updated, err := repo.Update(ctx, users[0].ID, user.CreateUserRequest{Name: "Ann", Email: "ann@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "Ann", updated.Name)
+	assert.Equal(t, "ann@example.com", updated.Email)
+	assert.Equal(t, users[0].CreatedAt, updated.CreatedAt)
+	assert.False(t, updated.UpdatedAt.Before(users[0].UpdatedAt))
+
+	// Keeping one's own email is no conflict, taking another user's is
+	_, err = repo.Update(ctx, users[0].ID, user.CreateUserRequest{Name: "Anna", Email: "ann@example.com"})
+	assert.NoError(t, err)
+	_, err = repo.Update(ctx, users[1].ID, user.CreateUserRequest{Name: "Ben", Email: "ann@example.com"})
+	assert.ErrorIs(t, err, user.ErrEmailTaken)
+}
+
+func testDelete(t *testing.T, repo user.UserRepository) {
+	ctx := context.Background()
+	users := create(t, ctx, repo, 2)
+
+	_, err := repo.Delete(ctx, users[0].ID)
+	require.NoError(t, err)
+
+	_, err = repo.GetByID(ctx, users[0].ID)
+	assert.ErrorIs(t, err, user.ErrNotFound)
+	_, err = repo.Delete(ctx, users[0].ID)
+	assert.ErrorIs(t, err, user.ErrNotFound)
+
+	listed, err := repo.List(ctx, user.ListFilter{Status: user.StatusAll})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{users[1].ID}, ids(listed))
+}
+
+func testTenants(t *testing.T, repo user.UserRepository) {
+	acme := tenant.WithTenant(context.Background(), "acme")
+	globex := tenant.WithTenant(context.Background(), "globex")
+	req := user.CreateUserRequest{Name: "Ann", Email: "ann@example.com"}
+
+	ann, err := repo.Create(acme, req)
+	require.NoError(t, err)
+
+	// Emails are unique per tenant only
+	_, err = repo.Create(globex, req)
+	require.NoError(t, err)
+
+	_, err = repo.GetByID(globex, ann.ID)
+	assert.ErrorIs(t, err, user.ErrNotFound)
+	_, err = repo.Update(globex, ann.ID, user.CreateUserRequest{Name: "Eve", Email: "eve@example.com"})
+	assert.ErrorIs(t, err, user.ErrNotFound)
+	_, err = repo.Delete(globex, ann.ID)
+	assert.ErrorIs(t, err, user.ErrNotFound)
+
+	listed, err := repo.List(acme, user.ListFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{ann.ID}, ids(listed))
+}
+
+func testListOrder(t *testing.T, repo user.UserRepository) {
+	ctx := context.Background()
+	users := create(t, ctx, repo, 3)
+
+	// Touch the oldest user, making it the most recently updated
+	time.Sleep(time.Millisecond)
+	require.NoError(t, repo.SetAdmin(ctx, users[0].ID, true))
+
+	for name, tt := range map[string]struct {
+		filter user.ListFilter
+		want   []int64
+	}{
+		"Default":         {user.ListFilter{}, []int64{users[2].ID, users[1].ID, users[0].ID}},
+		"CreatedAsc":      {user.ListFilter{Ascending: true}, []int64{users[0].ID, users[1].ID, users[2].ID}},
+		"UpdatedDesc":     {user.ListFilter{SortBy: user.SortUpdatedAt}, []int64{users[0].ID, users[2].ID, users[1].ID}},
+		"UpdatedAsc":      {user.ListFilter{SortBy: user.SortUpdatedAt, Ascending: true}, []int64{users[1].ID, users[2].ID, users[0].ID}},
+		"CreatedAfter":    {user.ListFilter{CreatedAfter: &users[1].CreatedAt}, []int64{users[2].ID, users[1].ID}},
+		"CreatedBefore":   {user.ListFilter{CreatedBefore: &users[1].CreatedAt}, []int64{users[0].ID}},
+		"LimitAndOffset":  {user.ListFilter{Limit: 1, Offset: 1}, []int64{users[1].ID}},
+		"OffsetPastLast":  {user.ListFilter{Offset: 3}, nil},
+		"AfterKeysetDesc": {user.ListFilter{After: &user.Keyset{Key: users[2].CreatedAt, ID: users[2].ID}}, []int64{users[1].ID, users[0].ID}},
+		"AfterKeysetAsc":  {user.ListFilter{Ascending: true, After: &user.Keyset{Key: users[0].CreatedAt, ID: users[0].ID}}, []int64{users[1].ID, users[2].ID}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			listed, err := repo.List(ctx, tt.filter)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, nilIfEmpty(ids(listed)))
+		})
+	}
+}
+
+func nilIfEmpty(ids []int64) []int64 {
+	if len(ids) == 0 {
+		return nil
+	}
+	return ids
+}
+
+func testListFilter(t *testing.T, repo user.UserRepository) {
+	ctx := context.Background()
+	users := create(t, ctx, repo, 3)
+
+	_, err := repo.SetStatus(ctx, users[0].ID, user.StatusSuspended)
+	require.NoError(t, err)
+	_, err = repo.SetStatus(ctx, users[1].ID, user.StatusDeactivated)
+	require.NoError(t, err)
+
+	for status, want := range map[string][]int64{
+		"":                     {users[2].ID, users[1].ID},
+		user.StatusAll:         {users[2].ID, users[1].ID, users[0].ID},
+		user.StatusSuspended:   {users[0].ID},
+		user.StatusDeactivated: {users[1].ID},
+		user.StatusActive:      {users[2].ID},
+	} {
+		listed, err := repo.List(ctx, user.ListFilter{Status: status})
+		require.NoError(t, err)
+		assert.Equal(t, want, ids(listed), "status %q", status)
+
+		n, err := repo.Count(ctx, user.ListFilter{Status: status})
+		require.NoError(t, err)
+		assert.EqualValues(t, len(want), n, "status %q", status)
+	}
+}
+
+func testPreferencesFilter(t *testing.T, repo user.UserRepository) {
+	ctx := context.Background()
+	users := create(t, ctx, repo, 3)
+
+	_, err := repo.SetPreferences(ctx, users[0].ID, prefs.Preferences{"theme": "dark", "beta": true, "tags": []any{"a", "b"}})
+	require.NoError(t, err)
+	updated, err := repo.SetPreferences(ctx, users[1].ID, prefs.Preferences{"theme": "light"})
+	require.NoError(t, err)
+	assert.Equal(t, "light", updated.Preferences.String("theme", ""))
+
+	for name, tt := range map[string]struct {
+		filter prefs.Preferences
+		want   []int64
+	}{
+		"String":   {prefs.Preferences{"theme": "dark"}, []int64{users[0].ID}},
+		"Bool":     {prefs.Preferences{"beta": true}, []int64{users[0].ID}},
+		"Both":     {prefs.Preferences{"theme": "dark", "beta": false}, nil},
+		"Array":    {prefs.Preferences{"tags": []any{"b"}}, []int64{users[0].ID}},
+		"NoMatch":  {prefs.Preferences{"theme": "blue"}, nil},
+		"Unfilled": {prefs.Preferences{}, []int64{users[2].ID, users[1].ID, users[0].ID}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			listed, err := repo.List(ctx, user.ListFilter{Preferences: tt.filter})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, nilIfEmpty(ids(listed)))
+		})
+	}
+}
+
+func testListPages(t *testing.T, repo user.UserRepository) {
+	ctx := context.Background()
+	users := create(t, ctx, repo, 5)
+
+	page, total, err := repo.ListWithTotal(ctx, user.ListFilter{Ascending: true, Limit: 2, Offset: 2})
+	require.NoError(t, err)
+	assert.EqualValues(t, 5, total)
+	assert.Equal(t, []int64{users[2].ID, users[3].ID}, ids(page))
+
+	page, total, err = repo.ListWithTotal(ctx, user.ListFilter{Offset: 10})
+	require.NoError(t, err)
+	assert.EqualValues(t, 5, total)
+	assert.Empty(t, page)
+
+	var streamed []*user.User
+	require.NoError(t, repo.Stream(ctx, user.ListFilter{Ascending: true}, func(u *user.User) error {
+		streamed = append(streamed, u)
+		return nil
+	}))
+	assert.Equal(t, ids(users), ids(streamed))
+
+	stop := errors.New("stop")
+	n := 0
+	err = repo.Stream(ctx, user.ListFilter{}, func(*user.User) error {
+		n++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, n)
+}
+
+func testCopyFrom(t *testing.T, repo user.UserRepository) {
+	ctx := context.Background()
+
+	n, err := repo.CopyFrom(ctx, []user.CreateUserRequest{
+		{Name: "Ann", Email: "ann@example.com"},
+		{Name: "Ben", Email: "ben@example.com"},
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, n)
+
+	// One taken email fails the whole batch
+	_, err = repo.CopyFrom(ctx, []user.CreateUserRequest{
+		{Name: "Cid", Email: "cid@example.com"},
+		{Name: "Ann", Email: "ann@example.com"},
+	})
+	assert.ErrorIs(t, err, user.ErrEmailTaken)
+
+	count, err := repo.Count(ctx, user.ListFilter{})
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, count)
+
+	_, err = repo.GetByEmail(ctx, "cid@example.com")
+	assert.ErrorIs(t, err, user.ErrNotFound)
+}
+
+func testWithTx(t *testing.T, repo user.UserRepository) {
+	ctx := context.Background()
+
+	assert.Nil(t, repo.Tx())
+
+	var committed *user.User
+	require.NoError(t, repo.WithTx(ctx, func(tx user.UserRepository) error {
+		assert.NotNil(t, tx.Tx())
+
+		var err error
+		committed, err = tx.Create(ctx, user.CreateUserRequest{Name: "Ann", Email: "ann@example.com"})
+		if err != nil {
+			return err
+		}
+
+		// A nested WithTx joins the transaction
+		return tx.WithTx(ctx, func(tx user.UserRepository) error {
+			return tx.SetAdmin(ctx, committed.ID, true)
+		})
+	}))
+
+	got, err := repo.GetByID(ctx, committed.ID)
+	require.NoError(t, err)
+	assert.True(t, got.IsAdmin)
+
+	failed := errors.New("failed")
+	err = repo.WithTx(ctx, func(tx user.UserRepository) error {
+		if _, err := tx.Create(ctx, user.CreateUserRequest{Name: "Ben", Email: "ben@example.com"}); err != nil {
+			return err
+		}
+		if _, err := tx.Update(ctx, committed.ID, user.CreateUserRequest{Name: "Anna", Email: "ann@example.com"}); err != nil {
+			return err
+		}
+		if err := tx.AddAudit(ctx, committed.ID, user.AuditUpdate, nil, nil); err != nil {
+			return err
+		}
+		return failed
+	})
+	assert.ErrorIs(t, err, failed)
+
+	_, err = repo.GetByEmail(ctx, "ben@example.com")
+	assert.ErrorIs(t, err, user.ErrNotFound)
+	got, err = repo.GetByID(ctx, committed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Ann", got.Name)
+	entries, err := repo.ListAudit(ctx, committed.ID, 10)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func testAdminAndAvatar(t *testing.T, repo user.UserRepository) {
+	ctx := context.Background()
+	u := create(t, ctx, repo, 1)[0]
+
+	require.NoError(t, repo.SetAdmin(ctx, u.ID, true))
+	got, err := repo.GetByID(ctx, u.ID)
+	require.NoError(t, err)
+	assert.True(t, got.IsAdmin)
+
+	key, err := repo.GetAvatarKey(ctx, u.ID)
+	require.NoError(t, err)
+	assert.Empty(t, key)
+
+	require.NoError(t, repo.SetAvatar(ctx, u.ID, "avatars/1.png"))
+	key, err = repo.GetAvatarKey(ctx, u.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "avatars/1.png", key)
+}
+
+func testProfiles(t *testing.T, repo user.UserRepository) {
+	ctx := context.Background()
+	users := create(t, ctx, repo, 2)
+	profiles := repo.Profiles()
+
+	_, err := profiles.Get(ctx, users[0].ID)
+	assert.ErrorIs(t, err, user.ErrNotFound)
+
+	_, profile, err := repo.GetWithProfile(ctx, users[0].ID)
+	require.NoError(t, err)
+	assert.Nil(t, profile)
+
+	saved, err := profiles.Upsert(ctx, users[0].ID, user.ProfileRequest{Phone: "+1 555 0100", Bio: "Hi"})
+	require.NoError(t, err)
+	assert.Equal(t, users[0].ID, saved.UserID)
+	assert.Equal(t, "+1 555 0100", saved.Phone)
+
+	saved, err = profiles.Upsert(ctx, users[0].ID, user.ProfileRequest{Bio: "Hello"})
+	require.NoError(t, err)
+	assert.Empty(t, saved.Phone)
+	assert.Equal(t, "Hello", saved.Bio)
+
+	got, err := profiles.Get(ctx, users[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, saved, got)
+
+	u, profile, err := repo.GetWithProfile(ctx, users[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, users[0].ID, u.ID)
+	assert.Equal(t, saved, profile)
+
+	listed, err := profiles.List(ctx, ids(users))
+	require.NoError(t, err)
+	assert.Equal(t, map[int64]*user.Profile{users[0].ID: saved}, listed)
+}
+
+func testAudit(t *testing.T, repo user.UserRepository) {
+	ctx := context.Background()
+	u := create(t, ctx, repo, 1)[0]
+
+	require.NoError(t, repo.AddAudit(ctx, u.ID, user.AuditCreate, nil, u))
+	require.NoError(t, repo.AddAudit(ctx, u.ID, user.AuditGrantAdmin, map[string]bool{"is_admin": false}, map[string]bool{"is_admin": true}))
+
+	entries, err := repo.ListAudit(ctx, u.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, user.AuditGrantAdmin, entries[0].Action)
+	assert.JSONEq(t, `{"is_admin": false}`, string(entries[0].Old))
+	assert.JSONEq(t, `{"is_admin": true}`, string(entries[0].New))
+	assert.Equal(t, user.AuditCreate, entries[1].Action)
+	assert.Empty(t, entries[1].Old)
+
+	entries, err = repo.ListAudit(ctx, u.ID, 1)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, user.AuditGrantAdmin, entries[0].Action)
+
+	other, err := repo.Create(ctx, user.CreateUserRequest{Name: "Other", Email: "other@example.com"})
+	require.NoError(t, err)
+	n, err := repo.MoveAudit(tenant.WithTenant(ctx, "other"), u.ID, other.ID)
+	require.NoError(t, err)
+	assert.Zero(t, n, "entries of other tenants are not moved")
+
+	n, err = repo.MoveAudit(ctx, u.ID, other.ID)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, n)
+	entries, err = repo.ListAudit(ctx, other.ID, 10)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+	entries, err = repo.ListAudit(ctx, u.ID, 10)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func testChanges(t *testing.T, repo user.UserRepository) {
+	ctx := context.Background()
+	users := create(t, ctx, repo, 3)
+	for _, u := range users {
+		require.NoError(t, repo.AddAudit(ctx, u.ID, user.AuditCreate, nil, u))
+	}
+
+	changes, err := repo.ListChanges(ctx, user.ChangeFilter{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, users[0].ID, changes[0].User.ID)
+	assert.Equal(t, user.ChangeCreated, changes[0].Type)
+	assert.Less(t, changes[0].Seq, changes[1].Seq)
+
+	changes, err = repo.ListChanges(ctx, user.ChangeFilter{AfterSeq: changes[1].Seq, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, users[2].ID, changes[0].User.ID)
+	cursor := changes[0].Seq
+
+	require.NoError(t, repo.AddAudit(ctx, users[1].ID, user.AuditDelete, users[1], nil))
+	_, err = repo.Delete(ctx, users[1].ID)
+	require.NoError(t, err)
+	require.NoError(t, repo.AddAudit(ctx, users[0].ID, user.AuditUpdate, nil, nil))
+
+	changes, err = repo.ListChanges(ctx, user.ChangeFilter{AfterSeq: cursor, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, users[1].ID, changes[0].User.ID)
+	assert.Equal(t, user.ChangeDeleted, changes[0].Type)
+	assert.NotNil(t, changes[0].DeletedAt)
+	assert.Equal(t, users[0].ID, changes[1].User.ID)
+	assert.Equal(t, user.ChangeUpdated, changes[1].Type)
+	assert.Nil(t, changes[1].DeletedAt)
+
+	changes, err = repo.ListChanges(ctx, user.ChangeFilter{Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, changes, 3, "a user changed several times is listed once")
+
+	changes, err = repo.ListChanges(ctx, user.ChangeFilter{Since: time.Now().Add(time.Hour), Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	changes, err = repo.ListChanges(tenant.WithTenant(ctx, "other"), user.ChangeFilter{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	_, err = repo.ListChanges(ctx, user.ChangeFilter{AfterSeq: cursor, NotBefore: time.Now().Add(time.Hour), Limit: 10})
+	assert.ErrorIs(t, err, user.ErrResyncRequired, "a cursor older than the purge retention")
+	_, err = repo.ListChanges(ctx, user.ChangeFilter{Since: time.Now().Add(-2 * time.Hour), NotBefore: time.Now().Add(-time.Hour), Limit: 10})
+	assert.ErrorIs(t, err, user.ErrResyncRequired, "a timestamp older than the purge retention")
+	_, err = repo.ListChanges(ctx, user.ChangeFilter{AfterSeq: 1 << 40, Limit: 10})
+	assert.ErrorIs(t, err, user.ErrResyncRequired, "an unknown cursor")
+	_, err = repo.ListChanges(tenant.WithTenant(ctx, "other"), user.ChangeFilter{AfterSeq: cursor, Limit: 10})
+	assert.ErrorIs(t, err, user.ErrResyncRequired, "a cursor of another tenant")
+}
+
+func testErase(t *testing.T, repo user.UserRepository) {
+	ctx := context.Background()
+	users := create(t, ctx, repo, 2)
+	u := users[0]
+
+	require.NoError(t, repo.SetAvatar(ctx, u.ID, "avatars/1/a.png"))
+	_, err := repo.Profiles().Upsert(ctx, u.ID, user.ProfileRequest{Phone: "+1 555 0100"})
+	require.NoError(t, err)
+	require.NoError(t, repo.AddAudit(ctx, u.ID, user.AuditCreate, nil, u))
+	require.NoError(t, repo.AddAudit(ctx, users[1].ID, user.AuditCreate, nil, users[1]))
+
+	_, err = repo.Erase(tenant.WithTenant(ctx, "other"), u.ID)
+	assert.ErrorIs(t, err, user.ErrNotFound)
+
+	key, err := repo.Erase(ctx, u.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "avatars/1/a.png", key)
+
+	_, err = repo.GetByID(ctx, u.ID)
+	assert.ErrorIs(t, err, user.ErrNotFound)
+	_, err = repo.Profiles().Get(ctx, u.ID)
+	assert.ErrorIs(t, err, user.ErrNotFound)
+
+	entries, err := repo.ListAudit(ctx, u.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, user.AuditCreate, entries[0].Action, "the entry is kept")
+	assert.Empty(t, entries[0].New, "its snapshot is cleared")
+
+	entries, err = repo.ListAudit(ctx, users[1].ID, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.NotEmpty(t, entries[0].New, "other users are untouched")
+
+	// The email is free again, and erasing a deleted user is allowed
+	_, err = repo.Create(ctx, user.CreateUserRequest{Name: "New", Email: u.Email})
+	require.NoError(t, err)
+	key, err = repo.Erase(ctx, u.ID)
+	require.NoError(t, err)
+	assert.Empty(t, key)
+
+	_, err = repo.Erase(ctx, 999999)
+	assert.ErrorIs(t, err, user.ErrNotFound)
+}
@@ -0,0 +1,229 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/things-kit/example-db/internal/events"
+	"github.com/things-kit/example-db/internal/tenant"
+	"github.com/things-kit/module/log"
+)
+
+// Headers set on every delivery
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderEventID   = "X-Webhook-Event-Id"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Sign returns the signature of a delivery: the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed by the webhook secret, prefixed with "sha256=".
+// Receivers recompute it to verify the sender and reject stale timestamps.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatcher delivers events to the active webhooks of their tenant. It
+// implements events.Publisher: Publish stores a delivery job per webhook, and
+// background workers send due jobs, retrying failed attempts with
+// exponential backoff. Jobs stay in the database until they are delivered or
+// run out of attempts, so no delivery is lost on a restart.
+type Dispatcher struct {
+	repo   *Repository
+	cfg    *Config
+	client *http.Client
+	log    log.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewDispatcher creates a new dispatcher
+func NewDispatcher(repo *Repository, cfg *Config, logger log.Logger) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		repo:   repo,
+		cfg:    cfg,
+		client: NewClient(cfg.Timeout, cfg.AllowPrivate),
+		log:    logger,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Publish stores a delivery job of the event for every active webhook of the
+// event's tenant. It returns once the jobs are stored, so the outbox relay
+// only marks the event published when its deliveries are safe.
+func (d *Dispatcher) Publish(ctx context.Context, evt events.Event) error {
+	body, err := json.Marshal(evt)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	tenantID := evt.TenantID
+	if tenantID == "" {
+		tenantID = tenant.Default
+	}
+	return d.repo.Enqueue(ctx, tenantID, evt.ID, evt.Type, body)
+}
+
+// Start launches the delivery workers
+func (d *Dispatcher) Start(context.Context) error {
+	for i := 0; i < d.cfg.Workers; i++ {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.work()
+		}()
+	}
+	return nil
+}
+
+// Stop stops the workers. Jobs being delivered are retried once their lease
+// has passed.
+func (d *Dispatcher) Stop(ctx context.Context) error {
+	d.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// work delivers due jobs until the dispatcher stops, polling when none is due
+func (d *Dispatcher) work() {
+	for {
+		// A claimed job is hidden for twice the attempt timeout, long enough
+		// to send it and record the outcome
+		j, err := d.repo.claimJob(d.ctx, 2*d.cfg.Timeout)
+		if err != nil && d.ctx.Err() == nil {
+			d.log.Error("Failed to claim webhook delivery", err)
+		}
+		if j != nil {
+			d.deliver(j)
+			continue
+		}
+
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-time.After(d.cfg.PollInterval):
+		}
+	}
+}
+
+// deliver makes one attempt to send a job, then deletes it on success or
+// after the last attempt, and schedules a retry otherwise
+func (d *Dispatcher) deliver(j *job) {
+	err := d.attempt(j)
+	if d.ctx.Err() != nil {
+		// Stopped while sending; the job is retried after its lease
+		return
+	}
+
+	if err != nil && j.attempt < d.cfg.MaxAttempts {
+		next := time.Now().Add(Backoff(j.attempt, d.cfg.InitialBackoff, d.cfg.MaxBackoff))
+		if err := d.repo.retryJob(d.ctx, j.id, next); err != nil {
+			d.log.Error("Failed to schedule webhook delivery retry", err, log.Field{Key: "webhook_id", Value: j.webhook.ID})
+		}
+		return
+	}
+
+	if err != nil {
+		d.log.Error("Webhook delivery failed", err,
+			log.Field{Key: "webhook_id", Value: j.webhook.ID},
+			log.Field{Key: "event_id", Value: j.eventID},
+			log.Field{Key: "attempts", Value: j.attempt},
+		)
+	}
+	if err := d.repo.finishJob(d.ctx, j.id); err != nil {
+		d.log.Error("Failed to finish webhook delivery", err, log.Field{Key: "webhook_id", Value: j.webhook.ID})
+	}
+}
+
+// attempt performs a single delivery and records it in the delivery log
+func (d *Dispatcher) attempt(j *job) error {
+	start := time.Now()
+	status, err := d.send(j)
+
+	record := &Delivery{
+		WebhookID:  j.webhook.ID,
+		EventID:    j.eventID,
+		EventType:  j.eventType,
+		Attempt:    j.attempt,
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	if status != 0 {
+		record.StatusCode = &status
+	}
+	if err != nil {
+		msg := err.Error()
+		record.Error = &msg
+	}
+
+	if recErr := d.repo.RecordDelivery(d.ctx, record); recErr != nil && d.ctx.Err() == nil {
+		d.log.Error("Failed to record webhook delivery", recErr, log.Field{Key: "webhook_id", Value: j.webhook.ID})
+	}
+
+	return err
+}
+
+func (d *Dispatcher) send(j *job) (int, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, j.webhook.URL, bytes.NewReader(j.body))
+	if err != nil {
+		return 0, err
+	}
+
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, j.eventType)
+	req.Header.Set(HeaderEventID, j.eventID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(HeaderSignature, Sign(j.webhook.Secret, ts, j.body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Backoff returns the wait before the next attempt: initial doubled per
+// attempt, capped at max, with full jitter
+func Backoff(attempt int, initial, max time.Duration) time.Duration {
+	d := initial << (attempt - 1)
+	if d <= 0 || d > max {
+		d = max
+	}
+	return time.Duration(rand.Int64N(int64(d)) + 1)
+}
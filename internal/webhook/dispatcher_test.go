@@ -0,0 +1,71 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"type":"user.created"}`)
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("1700000000." + string(body)))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	assert.Equal(t, want, Sign("secret", 1700000000, body))
+	assert.NotEqual(t, want, Sign("other", 1700000000, body))
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := 1; attempt <= 10; attempt++ {
+		d := Backoff(attempt, time.Second, 30*time.Second)
+		assert.Greater(t, d, time.Duration(0))
+		assert.LessOrEqual(t, d, time.Second<<(attempt-1))
+		assert.LessOrEqual(t, d, 30*time.Second)
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	for _, raw := range []string{
+		"https://hooks.example.com/users",
+		"http://203.0.113.10:8080/hook",
+	} {
+		assert.NoError(t, CheckURL(raw, false), raw)
+	}
+
+	for _, raw := range []string{
+		"ftp://hooks.example.com/users",
+		"http://localhost:8080/hook",
+		"http://api.localhost/hook",
+		"http://127.0.0.1/hook",
+		"http://10.0.0.5/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::1]/hook",
+		"http://0.0.0.0/hook",
+	} {
+		assert.ErrorIs(t, CheckURL(raw, false), ErrPrivateTarget, raw)
+	}
+
+	assert.NoError(t, CheckURL("http://localhost:8080/hook", true))
+	assert.ErrorIs(t, CheckURL("ftp://localhost/hook", true), ErrPrivateTarget)
+}
+
+func TestClientRefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	_, err := NewClient(time.Second, false).Get(srv.URL)
+	assert.ErrorIs(t, err, ErrPrivateTarget)
+
+	resp, err := NewClient(time.Second, true).Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+}
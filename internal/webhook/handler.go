@@ -0,0 +1,152 @@
+package webhook
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/things-kit/example-db/internal/i18n"
+	"github.com/things-kit/example-db/internal/idgen"
+	"github.com/things-kit/example-db/internal/middleware"
+	"github.com/things-kit/module/log"
+)
+
+// Handler handles HTTP requests for webhooks
+type Handler struct {
+	repo  *Repository
+	cfg   *Config
+	chain middleware.Chain
+	ids   idgen.Generator
+	log   log.Logger
+}
+
+// NewHandler creates a new webhook handler. ids generates the signing
+// secrets; nil uses crypto/rand. A nil cfg uses the defaults.
+func NewHandler(repo *Repository, cfg *Config, chain middleware.Chain, ids idgen.Generator, logger log.Logger) *Handler {
+	if cfg == nil {
+		cfg = NewConfig(nil)
+	}
+	return &Handler{
+		repo:  repo,
+		cfg:   cfg,
+		chain: chain,
+		ids:   idgen.Or(ids),
+		log:   logger,
+	}
+}
+
+// RegisterRoutes registers the webhook routes
+func (h *Handler) RegisterRoutes(engine *gin.Engine) {
+	webhooks := engine.Group("/webhooks", h.chain...)
+	{
+		webhooks.POST("", h.Create)
+		webhooks.GET("", h.List)
+		webhooks.GET("/:id", h.GetByID)
+		webhooks.DELETE("/:id", h.Delete)
+		webhooks.GET("/:id/deliveries", h.ListDeliveries)
+	}
+}
+
+// Create handles POST /webhooks. The signing secret is only returned here.
+// URLs on loopback or private addresses are rejected unless allow_private is
+// set.
+func (h *Handler) Create(c *gin.Context) {
+	var req CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Localize(c.Request.Context(), err)})
+		return
+	}
+	if err := CheckURL(req.URL, h.cfg.AllowPrivate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.Request.Context(), "Webhook URL must be a public http or https address")})
+		return
+	}
+
+	secret, err := h.ids.Token(32)
+	if err != nil {
+		h.log.Error("Failed to generate webhook secret", err)
+		_ = c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c.Request.Context(), "Failed to create webhook")})
+		return
+	}
+
+	webhook, err := h.repo.Create(c.Request.Context(), req.URL, secret)
+	if err != nil {
+		h.log.Error("Failed to create webhook", err)
+		_ = c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c.Request.Context(), "Failed to create webhook")})
+		return
+	}
+
+	h.log.Info("Webhook created", log.Field{Key: "id", Value: webhook.ID})
+	c.JSON(http.StatusCreated, webhook)
+}
+
+// List handles GET /webhooks
+func (h *Handler) List(c *gin.Context) {
+	webhooks, err := h.repo.List(c.Request.Context(), false)
+	if err != nil {
+		h.log.Error("Failed to list webhooks", err)
+		_ = c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c.Request.Context(), "Failed to list webhooks")})
+		return
+	}
+
+	for _, w := range webhooks {
+		w.Secret = ""
+	}
+	c.JSON(http.StatusOK, webhooks)
+}
+
+// GetByID handles GET /webhooks/:id
+func (h *Handler) GetByID(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.Request.Context(), "Invalid webhook ID")})
+		return
+	}
+
+	webhook, err := h.repo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c.Request.Context(), "Webhook not found")})
+		return
+	}
+
+	webhook.Secret = ""
+	c.JSON(http.StatusOK, webhook)
+}
+
+// Delete handles DELETE /webhooks/:id
+func (h *Handler) Delete(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.Request.Context(), "Invalid webhook ID")})
+		return
+	}
+
+	if err := h.repo.Delete(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c.Request.Context(), "Webhook not found")})
+		return
+	}
+
+	h.log.Info("Webhook deleted", log.Field{Key: "id", Value: id})
+	c.Status(http.StatusNoContent)
+}
+
+// ListDeliveries handles GET /webhooks/:id/deliveries
+func (h *Handler) ListDeliveries(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.Request.Context(), "Invalid webhook ID")})
+		return
+	}
+
+	deliveries, err := h.repo.ListDeliveries(c.Request.Context(), id, 100)
+	if err != nil {
+		h.log.Error("Failed to list webhook deliveries", err, log.Field{Key: "id", Value: id})
+		_ = c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c.Request.Context(), "Failed to list deliveries")})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
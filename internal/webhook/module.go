@@ -0,0 +1,74 @@
+package webhook
+
+import (
+	"errors"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/things-kit/example-db/internal/config"
+	"github.com/things-kit/example-db/internal/events"
+	"github.com/things-kit/module/httpgin"
+	"go.uber.org/fx"
+)
+
+// Module provides webhook management endpoints and the delivery workers.
+// Combine with fx.Decorate(WithDelivery) at the application root so the
+// outbox relay also delivers events to webhooks.
+var Module = fx.Module("webhook",
+	fx.Provide(NewConfig, NewRepository, NewDispatcher),
+	config.Validate[*Config]("webhooks"),
+	httpgin.AsGinHandler(NewHandler),
+	fx.Invoke(func(lc fx.Lifecycle, d *Dispatcher) {
+		lc.Append(fx.Hook{OnStart: d.Start, OnStop: d.Stop})
+	}),
+)
+
+// WithDelivery decorates the event publisher so every event is also
+// delivered to the registered webhooks
+func WithDelivery(p events.Publisher, d *Dispatcher) events.Publisher {
+	return events.NewMultiPublisher(p, d)
+}
+
+// Config holds the webhook delivery configuration
+type Config struct {
+	Workers int `mapstructure:"workers"`
+	// PollInterval is how often an idle worker checks for due deliveries
+	PollInterval   time.Duration `mapstructure:"poll_interval"`
+	MaxAttempts    int           `mapstructure:"max_attempts"`
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"`
+	MaxBackoff     time.Duration `mapstructure:"max_backoff"`
+	Timeout        time.Duration `mapstructure:"timeout"`
+	// AllowPrivate permits webhooks on loopback and private addresses, for
+	// local development
+	AllowPrivate bool `mapstructure:"allow_private"`
+}
+
+// NewConfig loads the webhook configuration from the "webhooks" key
+func NewConfig(v *viper.Viper) *Config {
+	cfg := &Config{
+		Workers:        4,
+		PollInterval:   time.Second,
+		MaxAttempts:    5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+		Timeout:        10 * time.Second,
+	}
+
+	if v != nil {
+		_ = v.UnmarshalKey("webhooks", cfg)
+	}
+
+	return cfg
+}
+
+// Validate checks the worker pool and retry settings
+func (c *Config) Validate() error {
+	return errors.Join(
+		config.Positive("workers", c.Workers),
+		config.Positive("poll_interval", c.PollInterval),
+		config.Positive("max_attempts", c.MaxAttempts),
+		config.Positive("initial_backoff", c.InitialBackoff),
+		config.Positive("max_backoff", c.MaxBackoff),
+		config.Positive("timeout", c.Timeout),
+	)
+}
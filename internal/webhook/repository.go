@@ -0,0 +1,329 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/things-kit/example-db/internal/tenant"
+)
+
+// Webhook is an external endpoint subscribed to user change events
+type Webhook struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Delivery is a single attempt to deliver an event to a webhook
+type Delivery struct {
+	ID         int64     `json:"id"`
+	WebhookID  int64     `json:"webhook_id"`
+	EventID    string    `json:"event_id"`
+	EventType  string    `json:"event_type"`
+	Attempt    int       `json:"attempt"`
+	StatusCode *int      `json:"status_code,omitempty"`
+	Error      *string   `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// job is a pending delivery of an event to a webhook
+type job struct {
+	id        int64
+	webhook   Webhook
+	eventID   string
+	eventType string
+	body      []byte
+	// attempt counts this attempt
+	attempt int
+}
+
+// CreateWebhookRequest represents the request to register a webhook
+type CreateWebhookRequest struct {
+	URL string `json:"url" binding:"required,url"`
+}
+
+// Repository handles webhook data operations. Webhooks belong to a tenant:
+// the CRUD methods are scoped to the tenant of the context, and Enqueue only
+// targets the webhooks of the event's tenant.
+type Repository struct {
+	db *sql.DB
+}
+
+// NewRepository creates a new webhook repository
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// inTenant runs fn in a transaction whose queries see the rows of tenantID
+// under row-level security. The database/sql pool doesn't set app.tenant_id
+// when a connection is acquired, unlike the pgx pool, so every query on
+// webhooks goes through here.
+func (r *Repository) inTenant(ctx context.Context, tenantID string, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT set_config('app.tenant_id', $1, true)", tenantID); err != nil {
+		return fmt.Errorf("failed to set tenant: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Create registers a new webhook of the context's tenant with the given
+// signing secret
+func (r *Repository) Create(ctx context.Context, url, secret string) (*Webhook, error) {
+	query := `
+		INSERT INTO webhooks (url, secret, tenant_id, created_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, url, secret, active, created_at
+	`
+
+	w := &Webhook{}
+	tenantID := tenant.FromContext(ctx)
+	err := r.inTenant(ctx, tenantID, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, query, url, secret, tenantID, time.Now()).Scan(
+			&w.ID, &w.URL, &w.Secret, &w.Active, &w.CreatedAt,
+		)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	return w, nil
+}
+
+// GetByID retrieves a webhook of the context's tenant by ID
+func (r *Repository) GetByID(ctx context.Context, id int64) (*Webhook, error) {
+	query := `
+		SELECT id, url, secret, active, created_at
+		FROM webhooks
+		WHERE id = $1 AND tenant_id = $2
+	`
+
+	w := &Webhook{}
+	tenantID := tenant.FromContext(ctx)
+	err := r.inTenant(ctx, tenantID, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, query, id, tenantID).Scan(
+			&w.ID, &w.URL, &w.Secret, &w.Active, &w.CreatedAt,
+		)
+	})
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("webhook not found")
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+
+	return w, nil
+}
+
+// List retrieves all webhooks of the context's tenant, optionally only the
+// active ones. The result is never nil, so an empty list encodes as [].
+func (r *Repository) List(ctx context.Context, activeOnly bool) ([]*Webhook, error) {
+	query := `
+		SELECT id, url, secret, active, created_at
+		FROM webhooks
+		WHERE tenant_id = $1 AND (active OR NOT $2)
+		ORDER BY id
+	`
+
+	webhooks := make([]*Webhook, 0)
+	tenantID := tenant.FromContext(ctx)
+	err := r.inTenant(ctx, tenantID, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, query, tenantID, activeOnly)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			w := &Webhook{}
+			if err := rows.Scan(&w.ID, &w.URL, &w.Secret, &w.Active, &w.CreatedAt); err != nil {
+				return err
+			}
+			webhooks = append(webhooks, w)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+// Delete deletes a webhook of the context's tenant and its delivery log
+func (r *Repository) Delete(ctx context.Context, id int64) error {
+	var rows int64
+	tenantID := tenant.FromContext(ctx)
+	err := r.inTenant(ctx, tenantID, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1 AND tenant_id = $2`, id, tenantID)
+		if err != nil {
+			return err
+		}
+		rows, err = result.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("webhook not found")
+	}
+
+	return nil
+}
+
+// Enqueue stores a delivery job of the event for every active webhook of the
+// tenant, due now. Enqueuing an event again adds no jobs, so a retried
+// Publish doesn't deliver it twice.
+func (r *Repository) Enqueue(ctx context.Context, tenantID, eventID, eventType string, body []byte) error {
+	query := `
+		INSERT INTO webhook_jobs (webhook_id, event_id, event_type, body, next_attempt_at, created_at)
+		SELECT id, $1, $2, $3, $4, $4
+		FROM webhooks
+		WHERE active AND tenant_id = $5
+		ON CONFLICT (webhook_id, event_id) DO NOTHING
+	`
+
+	err := r.inTenant(ctx, tenantID, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, query, eventID, eventType, body, time.Now(), tenantID)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enqueue webhook deliveries: %w", err)
+	}
+
+	return nil
+}
+
+// claimJob takes the oldest due job of any tenant and counts an attempt for
+// it. The job is not due again before lease has passed, so it is retried if
+// its worker dies before calling retryJob or finishJob. It returns nil when
+// no job is due.
+func (r *Repository) claimJob(ctx context.Context, lease time.Duration) (*job, error) {
+	query := `
+		UPDATE webhook_jobs j
+		SET attempts = j.attempts + 1, next_attempt_at = $2
+		FROM webhooks w
+		WHERE j.id = (
+			SELECT id
+			FROM webhook_jobs
+			WHERE next_attempt_at <= $1
+			ORDER BY next_attempt_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		) AND w.id = j.webhook_id
+		RETURNING j.id, j.event_id, j.event_type, j.body, j.attempts, w.id, w.url, w.secret
+	`
+
+	now := time.Now()
+	j := &job{}
+	err := r.inTenant(ctx, tenant.All, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, query, now, now.Add(lease)).Scan(
+			&j.id, &j.eventID, &j.eventType, &j.body, &j.attempt,
+			&j.webhook.ID, &j.webhook.URL, &j.webhook.Secret,
+		)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook delivery: %w", err)
+	}
+
+	return j, nil
+}
+
+// retryJob makes a job due again at the given time
+func (r *Repository) retryJob(ctx context.Context, id int64, at time.Time) error {
+	if _, err := r.db.ExecContext(ctx, `UPDATE webhook_jobs SET next_attempt_at = $1 WHERE id = $2`, at, id); err != nil {
+		return fmt.Errorf("failed to reschedule webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// finishJob deletes a job that was delivered or ran out of attempts
+func (r *Repository) finishJob(ctx context.Context, id int64) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM webhook_jobs WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to finish webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// RecordDelivery logs a delivery attempt
+func (r *Repository) RecordDelivery(ctx context.Context, d *Delivery) error {
+	query := `
+		INSERT INTO webhook_deliveries
+			(webhook_id, event_id, event_type, attempt, status_code, error, duration_ms, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	_, err := r.db.ExecContext(ctx, query,
+		d.WebhookID, d.EventID, d.EventType, d.Attempt, d.StatusCode, d.Error, d.DurationMS, time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record delivery: %w", err)
+	}
+
+	return nil
+}
+
+// ListDeliveries retrieves the most recent delivery attempts for a webhook
+// of the context's tenant, never returning nil
+func (r *Repository) ListDeliveries(ctx context.Context, webhookID int64, limit int) ([]*Delivery, error) {
+	query := `
+		SELECT d.id, d.webhook_id, d.event_id, d.event_type, d.attempt, d.status_code, d.error, d.duration_ms, d.created_at
+		FROM webhook_deliveries d
+		JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.webhook_id = $1 AND w.tenant_id = $2
+		ORDER BY d.id DESC
+		LIMIT $3
+	`
+
+	deliveries := make([]*Delivery, 0)
+	tenantID := tenant.FromContext(ctx)
+	err := r.inTenant(ctx, tenantID, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, query, webhookID, tenantID, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			d := &Delivery{}
+			err := rows.Scan(
+				&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Attempt,
+				&d.StatusCode, &d.Error, &d.DurationMS, &d.CreatedAt,
+			)
+			if err != nil {
+				return err
+			}
+			deliveries = append(deliveries, d)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
@@ -1,11 +1,193 @@
+-- Snapshot of the schema after all migrations in internal/migrations, which
+-- are the source of truth. Keep this file in sync when adding a migration.
+
+-- Create account status type
+DO $$ BEGIN
+    CREATE TYPE user_status AS ENUM ('active', 'suspended', 'deactivated');
+EXCEPTION WHEN duplicate_object THEN NULL;
+END $$;
+
 -- Create users table
 CREATE TABLE IF NOT EXISTS users (
     id SERIAL PRIMARY KEY,
     name VARCHAR(255) NOT NULL,
-    email VARCHAR(255) NOT NULL UNIQUE,
+    email VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
+    avatar_key TEXT,
+    is_admin BOOLEAN NOT NULL DEFAULT false,
+    uuid UUID NOT NULL,
+    preferences JSONB NOT NULL DEFAULT '{}',
+    tenant_id VARCHAR(63) NOT NULL DEFAULT 'default',
+    status user_status NOT NULL DEFAULT 'active'
+);
+
+-- Create unique index on email within a tenant; soft-deleted users don't hold on to their address
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_tenant_email ON users(tenant_id, email) WHERE deleted_at IS NULL;
+
+-- Create unique index on the UUID key
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_uuid ON users(uuid);
+
+-- Create GIN index for preference filters
+CREATE INDEX IF NOT EXISTS idx_users_preferences ON users USING GIN (preferences jsonb_path_ops);
+
+-- Create index used by the purge worker
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Create indexes for filtering and sorting users by their audit columns
+CREATE INDEX IF NOT EXISTS idx_users_created_at ON users(created_at, id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_users_updated_at ON users(updated_at, id) WHERE deleted_at IS NULL;
+
+-- Create outbox table for events written alongside user mutations
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGSERIAL PRIMARY KEY,
+    event_id UUID NOT NULL UNIQUE,
+    event_type VARCHAR(255) NOT NULL,
+    aggregate_id BIGINT NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMP
+);
+
+-- Create partial index so the relay only scans pending events
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox(id) WHERE published_at IS NULL;
+
+-- Create denormalized user directory maintained by the event consumer
+CREATE TABLE IF NOT EXISTS user_directory (
+    user_id BIGINT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Create table of events already applied by consumers, for idempotency
+CREATE TABLE IF NOT EXISTS processed_events (
+    consumer VARCHAR(255) NOT NULL,
+    event_id UUID NOT NULL,
+    processed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (consumer, event_id)
+);
+
+-- Create daily user statistics filled by the nightly rollup job
+CREATE TABLE IF NOT EXISTS user_stats_daily (
+    day DATE PRIMARY KEY,
+    total_users BIGINT NOT NULL,
+    created_users BIGINT NOT NULL,
+    deleted_users BIGINT NOT NULL
+);
+
+-- Create webhook subscriptions receiving user change events
+CREATE TABLE IF NOT EXISTS webhooks (
+    id BIGSERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    tenant_id VARCHAR(63) NOT NULL DEFAULT 'default'
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_tenant ON webhooks(tenant_id, id);
+
+-- Create log of webhook delivery attempts
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id BIGINT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_id UUID NOT NULL,
+    event_type VARCHAR(255) NOT NULL,
+    attempt INT NOT NULL,
+    status_code INT,
+    error TEXT,
+    duration_ms BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, id);
+
+-- Create pending webhook deliveries, deleted once delivered or out of attempts
+CREATE TABLE IF NOT EXISTS webhook_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id BIGINT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_id UUID NOT NULL,
+    event_type VARCHAR(255) NOT NULL,
+    body BYTEA NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (webhook_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_jobs_due ON webhook_jobs(next_attempt_at, id);
+
+-- Create audit log of user mutations. user_id has no foreign key so the
+-- history outlives purged users.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    old_data JSONB,
+    new_data JSONB,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    tenant_id VARCHAR(63) NOT NULL DEFAULT 'default',
+    -- The writing transaction; the changes feed pages in commit order on it
+    xact_id xid8 NOT NULL DEFAULT pg_current_xact_id()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_user ON audit_log(user_id, id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_tenant_xact ON audit_log(tenant_id, xact_id, id);
+
+-- Create one-to-one user profiles
+CREATE TABLE IF NOT EXISTS profiles (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    tenant_id VARCHAR(63) NOT NULL DEFAULT 'default',
+    phone VARCHAR(32) NOT NULL DEFAULT '',
+    address TEXT NOT NULL DEFAULT '',
+    bio TEXT NOT NULL DEFAULT '',
+    avatar_url TEXT NOT NULL DEFAULT '',
     updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
--- Create index on email
-CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
+-- Create the event log of the event-sourced user storage; users is its
+-- projection. The purge worker deletes the events of the users it purges.
+CREATE TABLE IF NOT EXISTS user_events (
+    user_id BIGINT NOT NULL,
+    version INT NOT NULL,
+    type VARCHAR(64) NOT NULL,
+    data JSONB NOT NULL DEFAULT '{}',
+    tenant_id VARCHAR(63) NOT NULL DEFAULT 'default',
+    occurred_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, version)
+);
+
+-- Restrict users, audit_log, profiles, user_events and webhooks rows to the tenant in app.tenant_id;
+-- '*' matches every tenant. Owners bypass the policies unless FORCE ROW LEVEL SECURITY is set.
+ALTER TABLE users ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON users;
+CREATE POLICY tenant_isolation ON users
+    USING (current_setting('app.tenant_id', true) IN (tenant_id, '*'))
+    WITH CHECK (current_setting('app.tenant_id', true) IN (tenant_id, '*'));
+
+ALTER TABLE audit_log ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON audit_log;
+CREATE POLICY tenant_isolation ON audit_log
+    USING (current_setting('app.tenant_id', true) IN (tenant_id, '*'))
+    WITH CHECK (current_setting('app.tenant_id', true) IN (tenant_id, '*'));
+
+ALTER TABLE profiles ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON profiles;
+CREATE POLICY tenant_isolation ON profiles
+    USING (current_setting('app.tenant_id', true) IN (tenant_id, '*'))
+    WITH CHECK (current_setting('app.tenant_id', true) IN (tenant_id, '*'));
+
+ALTER TABLE user_events ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON user_events;
+CREATE POLICY tenant_isolation ON user_events
+    USING (current_setting('app.tenant_id', true) IN (tenant_id, '*'))
+    WITH CHECK (current_setting('app.tenant_id', true) IN (tenant_id, '*'));
+
+ALTER TABLE webhooks ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON webhooks;
+CREATE POLICY tenant_isolation ON webhooks
+    USING (current_setting('app.tenant_id', true) IN (tenant_id, '*'))
+    WITH CHECK (current_setting('app.tenant_id', true) IN (tenant_id, '*'));
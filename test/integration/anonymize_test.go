@@ -0,0 +1,100 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/anonymize"
+	"github.com/things-kit/example-db/internal/database"
+	"github.com/things-kit/example-db/internal/tenant"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/user"
+)
+
+// TestAnonymizeCopy copies users between two databases and checks that no
+// name or email leaks, that everything else is kept and that a rerun yields
+// the same data
+func TestAnonymizeCopy(t *testing.T) {
+	ctx := context.Background()
+	open := func() *pgxpool.Pool {
+		pool, err := pgxpool.New(ctx, testutil.Shared(t).NewDatabase(t))
+		require.NoError(t, err)
+		t.Cleanup(pool.Close)
+		return pool
+	}
+	src, dst := open(), open()
+
+	repo := user.NewRepository(user.RepositoryParams{Pool: src, Config: database.NewConfig(nil), Metrics: user.NewMetrics()})
+	var originals []*user.User
+	for i := range 7 {
+		u, err := repo.Create(ctx, user.CreateUserRequest{Name: fmt.Sprintf("Real Person %d", i), Email: fmt.Sprintf("real%d@corp.example", i)})
+		require.NoError(t, err)
+		originals = append(originals, u)
+	}
+	acme, err := repo.Create(tenant.WithTenant(ctx, "acme"), user.CreateUserRequest{Name: "Acme Person", Email: "real0@corp.example"})
+	require.NoError(t, err)
+	originals = append(originals, acme)
+	require.NoError(t, repo.SetAdmin(ctx, originals[1].ID, true))
+
+	deleted, err := repo.Create(ctx, user.CreateUserRequest{Name: "Gone Person", Email: "gone@corp.example"})
+	require.NoError(t, err)
+	_, err = repo.Delete(ctx, deleted.ID)
+	require.NoError(t, err)
+
+	p, err := anonymize.New([]byte("test-key"))
+	require.NoError(t, err)
+
+	copied, err := anonymize.Copy(ctx, src, dst, p, 3)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(originals)), copied)
+
+	type row struct {
+		UUID, Tenant, Name, Email string
+		Admin                     bool
+	}
+	read := func() map[int64]row {
+		rows := map[int64]row{}
+		r, err := dst.Query(ctx, `SELECT id, uuid::text, tenant_id, name, email, is_admin FROM users`)
+		require.NoError(t, err)
+		defer r.Close()
+		for r.Next() {
+			var (
+				id int64
+				u  row
+			)
+			require.NoError(t, r.Scan(&id, &u.UUID, &u.Tenant, &u.Name, &u.Email, &u.Admin))
+			rows[id] = u
+		}
+		require.NoError(t, r.Err())
+		return rows
+	}
+
+	rows := read()
+	require.Len(t, rows, len(originals), "deleted users are skipped")
+	for _, u := range originals {
+		got, ok := rows[u.ID]
+		require.True(t, ok, "user %d", u.ID)
+		assert.Equal(t, u.UUID.String(), got.UUID)
+		assert.Equal(t, p.Name(u.Name), got.Name)
+		assert.Equal(t, p.Email(u.Email), got.Email)
+		assert.NotContains(t, got.Name, "Person")
+		assert.NotContains(t, got.Email, "corp.example")
+	}
+	assert.Equal(t, "acme", rows[acme.ID].Tenant)
+	assert.True(t, rows[originals[1].ID].Admin)
+
+	// A rerun overwrites the copy with the same pseudonyms
+	_, err = anonymize.Copy(ctx, src, dst, p, 0)
+	require.NoError(t, err)
+	assert.Equal(t, rows, read())
+
+	// New users in the target don't collide with the copied IDs
+	target := user.NewRepository(user.RepositoryParams{Pool: dst, Config: database.NewConfig(nil), Metrics: user.NewMetrics()})
+	created, err := target.Create(ctx, user.CreateUserRequest{Name: "New", Email: "new@example.com"})
+	require.NoError(t, err)
+	assert.Greater(t, created.ID, acme.ID)
+}
@@ -0,0 +1,76 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/reqlog"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/testutil/apptest"
+	"github.com/things-kit/example-db/internal/testutil/contract"
+	"github.com/things-kit/example-db/internal/testutil/factory"
+)
+
+// TestApp drives the complete service over HTTP: routes, middleware, JSON
+// serialization and error mapping. Every exchange is checked against the
+// OpenAPI document.
+func TestApp(t *testing.T) {
+	app := apptest.Start(t, testutil.Shared(t).NewDatabase(t), nil)
+	hc := contract.New(t).Client()
+
+	do := func(method, path string, body any, header ...string) *http.Response {
+		t.Helper()
+		var buf bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		}
+		req, err := http.NewRequest(method, app.URL+path, &buf)
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		resp, err := hc.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	t.Run("CreateAndGet", func(t *testing.T) {
+		resp := do(http.MethodPost, "/users", factory.User().WithEmail("ann@example.com").Request())
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.NotEmpty(t, resp.Header.Get(reqlog.Header))
+
+		var created map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+		assert.Equal(t, "ann@example.com", created["email"])
+		assert.Equal(t, "active", created["status"])
+
+		resp = do(http.MethodGet, "/users/1", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		resp := do(http.MethodPost, "/users", factory.User().WithEmail("ann@example.com").Request())
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	})
+
+	t.Run("LocalizedNotFound", func(t *testing.T) {
+		resp := do(http.MethodGet, "/users/999", nil, "Accept-Language", "de")
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, "Benutzer nicht gefunden", body["error"])
+	})
+
+	t.Run("InvalidID", func(t *testing.T) {
+		resp := do(http.MethodGet, "/users/abc", nil, reqlog.Header, "req-1")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "req-1", resp.Header.Get(reqlog.Header))
+	})
+}
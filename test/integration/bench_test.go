@@ -0,0 +1,171 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/database"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/testutil/factory"
+	"github.com/things-kit/example-db/internal/user"
+)
+
+// The benchmarks measure the repository against the test container. Run
+// them all, or one by name, with
+//
+//	go test ./test/integration -run '^$' -bench .
+//	go test ./test/integration -run '^$' -bench ListPaged -benchmem
+//
+// and compare runs before and after a change with benchstat.
+
+// benchRows is the number of users seeded for the read benchmarks
+const benchRows = 10_000
+
+// benchPageSize is the page size of BenchmarkListPaged
+const benchPageSize = 50
+
+// newBenchRepository returns a repository on a database of the benchmark's
+// own, seeded with rows users with IDs 1 to rows
+func newBenchRepository(b *testing.B, rows int) *user.Repository {
+	b.Helper()
+
+	pool, err := pgxpool.New(context.Background(), testutil.Shared(b).NewDatabase(b))
+	require.NoError(b, err)
+	b.Cleanup(pool.Close)
+
+	repo := user.NewRepository(user.RepositoryParams{
+		Pool:    pool,
+		Config:  database.NewConfig(nil),
+		Metrics: user.NewMetrics(),
+	})
+	factory.Seed(b, repo, rows)
+	return repo
+}
+
+// BenchmarkCreate measures creating one user
+func BenchmarkCreate(b *testing.B) {
+	repo := newBenchRepository(b, 0)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := range b.N {
+		req := user.CreateUserRequest{Name: "Bench User", Email: fmt.Sprintf("create.%d@example.com", i)}
+		if _, err := repo.Create(ctx, req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkGetByID measures looking up users by ID, serially and from
+// parallel goroutines, which exercises the pool and query coalescing
+func BenchmarkGetByID(b *testing.B) {
+	repo := newBenchRepository(b, benchRows)
+	ctx := context.Background()
+
+	b.Run("Serial", func(b *testing.B) {
+		for i := range b.N {
+			if _, err := repo.GetByID(ctx, int64(i%benchRows)+1); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("Parallel", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			i := 0
+			for pb.Next() {
+				if _, err := repo.GetByID(ctx, int64(i%benchRows)+1); err != nil {
+					b.Error(err)
+					return
+				}
+				i++
+			}
+		})
+	})
+}
+
+// BenchmarkListPaged measures paging through every user, with offsets and
+// with keyset cursors, and with the total count of ListWithTotal. Each
+// iteration reads one page; the pages cycle from the first to the last.
+func BenchmarkListPaged(b *testing.B) {
+	repo := newBenchRepository(b, benchRows)
+	ctx := context.Background()
+	pages := benchRows / benchPageSize
+
+	b.Run("Offset", func(b *testing.B) {
+		for i := range b.N {
+			f := user.ListFilter{Limit: benchPageSize, Offset: (i % pages) * benchPageSize}
+			if _, err := repo.List(ctx, f); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("Keyset", func(b *testing.B) {
+		var after *user.Keyset
+		for range b.N {
+			users, err := repo.List(ctx, user.ListFilter{Limit: benchPageSize, After: after})
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(users) < benchPageSize {
+				after = nil
+				continue
+			}
+			last := users[len(users)-1]
+			after = &user.Keyset{Key: last.CreatedAt, ID: last.ID}
+		}
+	})
+
+	b.Run("WithTotal", func(b *testing.B) {
+		for i := range b.N {
+			f := user.ListFilter{Limit: benchPageSize, Offset: (i % pages) * benchPageSize}
+			if _, _, err := repo.ListWithTotal(ctx, f); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkInsert compares inserting a batch of users row by row with Create
+// against a single COPY with CopyFrom
+func BenchmarkInsert(b *testing.B) {
+	repo := newBenchRepository(b, 0)
+	ctx := context.Background()
+
+	const batch = 1000
+	run := 0
+	// batchOf returns batch users with emails unique across all runs
+	batchOf := func() []user.CreateUserRequest {
+		run++
+		reqs := make([]user.CreateUserRequest, batch)
+		for i := range reqs {
+			reqs[i] = user.CreateUserRequest{
+				Name:  "Bench User",
+				Email: fmt.Sprintf("bench.%d.%d@example.com", run, i),
+			}
+		}
+		return reqs
+	}
+
+	b.Run("Create", func(b *testing.B) {
+		for range b.N {
+			for _, req := range batchOf() {
+				if _, err := repo.Create(ctx, req); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	b.Run("CopyFrom", func(b *testing.B) {
+		for range b.N {
+			if _, err := repo.CopyFrom(ctx, batchOf()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
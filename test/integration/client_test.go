@@ -0,0 +1,180 @@
+package integration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/client"
+	"github.com/things-kit/example-db/internal/database"
+	"github.com/things-kit/example-db/internal/mail"
+	"github.com/things-kit/example-db/internal/storage"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/testutil/contract"
+	"github.com/things-kit/example-db/internal/user"
+	"github.com/things-kit/example-db/internal/webhook"
+
+	_ "github.com/lib/pq"
+)
+
+// TestClient drives the user and webhook handlers through the client
+// package, backed by a real database. Every exchange is checked against the
+// OpenAPI document, so a handler drifting from it fails the test.
+func TestClient(t *testing.T) {
+	dsn := testutil.Shared(t).NewDatabase(t)
+
+	ctx := context.Background()
+
+	pool, err := pgxpool.New(ctx, dsn)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	logger := testutil.NopLogger{}
+	store, err := storage.NewStore(&storage.Config{})
+	require.NoError(t, err)
+	mailCfg := mail.NewConfig(nil)
+
+	repo := user.NewRepository(user.RepositoryParams{
+		Pool:    pool,
+		Config:  database.NewConfig(nil),
+		Metrics: user.NewMetrics(),
+	})
+	svc := user.NewService(repo, store, mail.NewQueue(mail.NewLogMailer(logger), mailCfg, logger), nil, nil, logger)
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	user.NewHandler(svc, user.NewConfig(nil), nil, nil, logger).RegisterRoutes(engine)
+	webhook.NewHandler(webhook.NewRepository(db), nil, nil, nil, logger).RegisterRoutes(engine)
+
+	srv := httptest.NewServer(engine)
+	defer srv.Close()
+
+	v := contract.New(t)
+	c := client.New(srv.URL, client.WithHTTPClient(v.Client()))
+	defer func() {
+		t.Logf("operations without a successful call: %v", v.Uncovered())
+	}()
+
+	t.Run("CRUD", func(t *testing.T) {
+		created, err := c.CreateUser(ctx, client.UserRequest{Name: "Ann", Email: "ann@example.com"})
+		require.NoError(t, err)
+		assert.NotEmpty(t, created.ID)
+		assert.Equal(t, client.StatusActive, created.Status)
+
+		_, err = c.CreateUser(ctx, client.UserRequest{Name: "Ann", Email: "ann@example.com"})
+		assert.True(t, client.IsConflict(err), "got %v", err)
+
+		updated, err := c.UpdateUser(ctx, created.ID, client.UserRequest{Name: "Anne", Email: "anne@example.com"})
+		require.NoError(t, err)
+		assert.Equal(t, "Anne", updated.Name)
+
+		_, err = c.UpdateProfile(ctx, created.ID, client.ProfileRequest{Bio: "hello"})
+		require.NoError(t, err)
+
+		got, err := c.GetUser(ctx, created.ID, true)
+		require.NoError(t, err)
+		assert.Equal(t, "anne@example.com", got.Email)
+		require.NotNil(t, got.Profile)
+		assert.Equal(t, "hello", got.Profile.Bio)
+
+		patched, err := c.PatchPreferences(ctx, created.ID, map[string]any{"theme": "dark"})
+		require.NoError(t, err)
+		assert.Equal(t, "dark", patched.Preferences["theme"])
+
+		entries, err := c.Audit(ctx, created.ID, 0)
+		require.NoError(t, err)
+		assert.NotEmpty(t, entries)
+
+		require.NoError(t, c.DeleteUser(ctx, created.ID))
+		_, err = c.GetUser(ctx, created.ID, false)
+		assert.True(t, client.IsNotFound(err), "got %v", err)
+	})
+
+	t.Run("Status", func(t *testing.T) {
+		created, err := c.CreateUser(ctx, client.UserRequest{Name: "Sam", Email: "sam@example.com"})
+		require.NoError(t, err)
+
+		suspended, err := c.SuspendUser(ctx, created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, client.StatusSuspended, suspended.Status)
+
+		_, err = c.SuspendUser(ctx, created.ID)
+		assert.True(t, client.IsConflict(err), "got %v", err)
+
+		active, err := c.ActivateUser(ctx, created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, client.StatusActive, active.Status)
+	})
+
+	t.Run("Pagination", func(t *testing.T) {
+		reqs := make([]client.UserRequest, 25)
+		for i := range reqs {
+			reqs[i] = client.UserRequest{Name: "Page User", Email: fmt.Sprintf("page%d@example.com", i)}
+		}
+		imported, err := c.ImportUsers(ctx, reqs)
+		require.NoError(t, err)
+		assert.EqualValues(t, len(reqs), imported)
+
+		page, err := c.ListUsers(ctx, client.ListOptions{Limit: 10, Count: true})
+		require.NoError(t, err)
+		assert.Len(t, page.Users, 10)
+		assert.NotEmpty(t, page.NextCursor)
+		assert.GreaterOrEqual(t, page.Total, int64(len(reqs)))
+
+		seen := map[client.ID]bool{}
+		for u, err := range c.Users(ctx, client.ListOptions{Limit: 10}) {
+			require.NoError(t, err)
+			assert.False(t, seen[u.ID], "user %s listed twice", u.ID)
+			seen[u.ID] = true
+		}
+		assert.Len(t, seen, int(page.Total))
+
+		streamed := 0
+		for _, err := range c.StreamUsers(ctx, client.ListOptions{}) {
+			require.NoError(t, err)
+			streamed++
+		}
+		assert.Equal(t, len(seen), streamed)
+	})
+
+	t.Run("Avatar", func(t *testing.T) {
+		created, err := c.CreateUser(ctx, client.UserRequest{Name: "Ava", Email: "ava@example.com"})
+		require.NoError(t, err)
+
+		_, err = c.GetAvatar(ctx, created.ID)
+		assert.True(t, client.IsNotFound(err), "got %v", err)
+	})
+
+	t.Run("Webhooks", func(t *testing.T) {
+		created, err := c.CreateWebhook(ctx, "https://hooks.example.com/users")
+		require.NoError(t, err)
+		assert.NotEmpty(t, created.Secret)
+
+		got, err := c.GetWebhook(ctx, created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, created.URL, got.URL)
+		assert.Empty(t, got.Secret)
+
+		all, err := c.ListWebhooks(ctx)
+		require.NoError(t, err)
+		assert.Len(t, all, 1)
+
+		deliveries, err := c.ListDeliveries(ctx, created.ID)
+		require.NoError(t, err)
+		assert.Empty(t, deliveries)
+
+		require.NoError(t, c.DeleteWebhook(ctx, created.ID))
+		_, err = c.GetWebhook(ctx, created.ID)
+		assert.True(t, client.IsNotFound(err), "got %v", err)
+	})
+}
@@ -0,0 +1,60 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/clock"
+	"github.com/things-kit/example-db/internal/idgen"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/testutil/apptest"
+	"go.uber.org/fx"
+)
+
+// TestDeterministicClockAndIDs replaces the clock and ID generator of the
+// application, so the timestamps and UUIDs the API returns are known exactly
+func TestDeterministicClockAndIDs(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(start)
+	app := apptest.Start(t, testutil.Shared(t).NewDatabase(t), map[string]any{"users.id_type": "uuid"},
+		fx.Decorate(func(clock.Clock) clock.Clock { return fake }),
+		fx.Decorate(func(idgen.Generator) idgen.Generator { return idgen.NewSequence() }),
+	)
+
+	send := func(method, path, body string) map[string]any {
+		req, err := http.NewRequest(method, app.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Less(t, resp.StatusCode, 300)
+
+		var got map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+		return got
+	}
+	// The database may return the times in another location, so compare
+	// instants
+	at := func(v any) time.Time {
+		ts, err := time.Parse(time.RFC3339Nano, v.(string))
+		require.NoError(t, err)
+		return ts
+	}
+
+	created := send(http.MethodPost, "/users", `{"name":"Ann","email":"ann@example.com"}`)
+	id := idgen.SequenceUUID(1).String()
+	assert.Equal(t, id, created["id"])
+	assert.WithinDuration(t, start, at(created["created_at"]), 0)
+	assert.WithinDuration(t, start, at(created["updated_at"]), 0)
+
+	later := fake.Advance(90 * time.Minute)
+	updated := send(http.MethodPut, "/users/"+id, `{"name":"Ann Archer","email":"ann@example.com"}`)
+	assert.WithinDuration(t, start, at(updated["created_at"]), 0)
+	assert.WithinDuration(t, later, at(updated["updated_at"]), 0)
+}
@@ -0,0 +1,262 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/database"
+	"github.com/things-kit/example-db/internal/mail"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/user"
+)
+
+// The tests race writers against the same user and check that the row locks
+// taken by GetForUpdate serialize them, the unique email index lets one
+// creator win, and the event versions have no gaps or duplicates. Run them
+// with -race to also catch data races in the repository.
+
+// newConcurrencyService returns a service on a pool of at most maxConns
+// connections to dsn
+func newConcurrencyService(t *testing.T, dsn string, maxConns int32) (*user.Service, *user.Repository, *pgxpool.Pool) {
+	t.Helper()
+
+	cfg, err := pgxpool.ParseConfig(dsn)
+	require.NoError(t, err)
+	cfg.MaxConns = maxConns
+	pool, err := pgxpool.NewWithConfig(context.Background(), cfg)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	repo := user.NewRepository(user.RepositoryParams{
+		Pool:    pool,
+		Config:  database.NewConfig(nil),
+		Metrics: user.NewMetrics(),
+	})
+	queue := mail.NewQueue(nil, &mail.Config{QueueSize: 1}, testutil.NopLogger{})
+	return user.NewService(repo, nil, queue, nil, nil, testutil.NopLogger{}), repo, pool
+}
+
+// parallel runs fn n times at once and returns the errors
+func parallel(n int, fn func(i int) error) []error {
+	errs := make([]error, n)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			errs[i] = fn(i)
+		}()
+	}
+	close(start)
+	wg.Wait()
+	return errs
+}
+
+func TestConcurrentCreateSameEmail(t *testing.T) {
+	svc, _, _ := newConcurrencyService(t, testutil.Shared(t).NewDatabase(t), 10)
+	ctx := context.Background()
+
+	errs := parallel(10, func(i int) error {
+		_, err := svc.Create(ctx, user.CreateUserRequest{Name: fmt.Sprintf("User %d", i), Email: "same@example.com"})
+		return err
+	})
+
+	created := 0
+	for _, err := range errs {
+		if err == nil {
+			created++
+			continue
+		}
+		assert.ErrorIs(t, err, user.ErrEmailTaken)
+	}
+	assert.Equal(t, 1, created, "exactly one creator wins the email")
+
+	n, err := svc.Count(ctx, user.ListFilter{})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+}
+
+func TestConcurrentUpdatesAreSerialized(t *testing.T) {
+	svc, _, _ := newConcurrencyService(t, testutil.Shared(t).NewDatabase(t), 10)
+	ctx := context.Background()
+
+	u, err := svc.Create(ctx, user.CreateUserRequest{Name: "Start", Email: "ann@example.com"})
+	require.NoError(t, err)
+
+	const writers = 20
+	errs := parallel(writers, func(i int) error {
+		_, err := svc.Update(ctx, u.ID, user.CreateUserRequest{Name: fmt.Sprintf("Writer %d", i), Email: "ann@example.com"})
+		return err
+	})
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+
+	entries, err := svc.Audit(ctx, u.ID, 100)
+	require.NoError(t, err)
+	require.Len(t, entries, writers+1, "no update was lost")
+	assert.Equal(t, user.AuditCreate, entries[writers].Action)
+
+	// Each update must have seen the one before it: replayed oldest first,
+	// the audit entries form one chain of updated_at values. Names are
+	// redacted in the snapshots, so the chain can't be followed by name.
+	updatedAt := func(data json.RawMessage) time.Time {
+		var v struct {
+			UpdatedAt time.Time `json:"updated_at"`
+		}
+		require.NoError(t, json.Unmarshal(data, &v))
+		return v.UpdatedAt
+	}
+	prev := updatedAt(entries[writers].New)
+	for i := writers - 1; i >= 0; i-- {
+		assert.WithinDuration(t, prev, updatedAt(entries[i].Old), time.Microsecond, "audit entry %d", entries[i].ID)
+		prev = updatedAt(entries[i].New)
+	}
+
+	final, err := svc.GetByID(ctx, u.ID)
+	require.NoError(t, err)
+	assert.WithinDuration(t, prev, final.UpdatedAt, time.Microsecond)
+}
+
+func TestConcurrentUpdateAndDelete(t *testing.T) {
+	svc, _, _ := newConcurrencyService(t, testutil.Shared(t).NewDatabase(t), 10)
+	ctx := context.Background()
+
+	u, err := svc.Create(ctx, user.CreateUserRequest{Name: "Ann", Email: "ann@example.com"})
+	require.NoError(t, err)
+
+	var (
+		mu      sync.Mutex
+		deletes int
+	)
+	errs := parallel(20, func(i int) error {
+		if i%4 == 0 {
+			err := svc.Delete(ctx, u.ID)
+			if err == nil {
+				mu.Lock()
+				deletes++
+				mu.Unlock()
+			}
+			return err
+		}
+		_, err := svc.Update(ctx, u.ID, user.CreateUserRequest{Name: fmt.Sprintf("Writer %d", i), Email: "ann@example.com"})
+		return err
+	})
+	for _, err := range errs {
+		if err != nil {
+			assert.ErrorIs(t, err, user.ErrNotFound, "writers after the delete find no user")
+		}
+	}
+	assert.Equal(t, 1, deletes, "the user is deleted once")
+
+	_, err = svc.GetByID(ctx, u.ID)
+	assert.ErrorIs(t, err, user.ErrNotFound)
+
+	entries, err := svc.Audit(ctx, u.ID, 100)
+	require.NoError(t, err)
+	assert.Equal(t, user.AuditDelete, entries[0].Action, "nothing is written after the delete")
+}
+
+func TestConcurrentEventStoreVersions(t *testing.T) {
+	dsn := testutil.Shared(t).NewDatabase(t)
+	_, repo, pool := newConcurrencyService(t, dsn, 10)
+	store := user.NewEventStore(repo)
+	ctx := context.Background()
+
+	u, err := store.Create(ctx, user.CreateUserRequest{Name: "Ann", Email: "ann@example.com"})
+	require.NoError(t, err)
+
+	const writers = 10
+	errs := parallel(writers, func(i int) error {
+		_, err := store.Update(ctx, u.ID, user.CreateUserRequest{Name: "Ann", Email: fmt.Sprintf("ann%d@example.com", i)})
+		return err
+	})
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+
+	rows, err := pool.Query(ctx, `SELECT version FROM user_events WHERE user_id = $1 ORDER BY version`, u.ID)
+	require.NoError(t, err)
+	versions, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	require.NoError(t, err)
+	require.Len(t, versions, writers+1)
+	for i, v := range versions {
+		assert.Equal(t, i+1, v, "versions are contiguous")
+	}
+}
+
+// TestRepositoryStress runs a mix of calls from many goroutines on a small
+// pool and checks that every connection is back in the pool afterwards
+func TestRepositoryStress(t *testing.T) {
+	if testing.Short() {
+		t.Skip("stress test")
+	}
+
+	const maxConns = 4
+	svc, repo, pool := newConcurrencyService(t, testutil.Shared(t).NewDatabase(t), maxConns)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var ids []int64
+	for i := range 10 {
+		u, err := svc.Create(ctx, user.CreateUserRequest{Name: "User", Email: fmt.Sprintf("user%d@example.com", i)})
+		require.NoError(t, err)
+		ids = append(ids, u.ID)
+	}
+
+	errs := parallel(50, func(i int) error {
+		for j := range 20 {
+			id := ids[(i+j)%len(ids)]
+			var err error
+			switch j % 5 {
+			case 0:
+				_, err = svc.Update(ctx, id, user.CreateUserRequest{Name: fmt.Sprintf("User %d-%d", i, j), Email: fmt.Sprintf("user%d@example.com", (i+j)%len(ids))})
+			case 1:
+				_, err = svc.List(ctx, user.ListFilter{Limit: 5})
+			case 2:
+				err = repo.WithTx(ctx, func(tx user.UserRepository) error {
+					_, err := tx.GetForUpdate(ctx, id)
+					return err
+				})
+			case 3:
+				// A call abandoned halfway must still return its connection
+				short, cancel := context.WithTimeout(ctx, time.Millisecond)
+				_, err = svc.GetByID(short, id)
+				cancel()
+				if errors.Is(err, context.DeadlineExceeded) || database.IsTimeout(err) {
+					err = nil
+				}
+			default:
+				_, err = svc.GetByID(ctx, id)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+
+	stat := pool.Stat()
+	assert.Zero(t, stat.AcquiredConns(), "no connection leaked")
+	assert.LessOrEqual(t, stat.TotalConns(), int32(maxConns))
+
+	var backends int
+	require.NoError(t, pool.QueryRow(ctx,
+		`SELECT count(*) FROM pg_stat_activity WHERE datname = current_database() AND state LIKE 'idle in transaction%'`,
+	).Scan(&backends))
+	assert.Zero(t, backends, "no transaction was left open")
+}
@@ -0,0 +1,29 @@
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/database"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/user"
+	"github.com/things-kit/example-db/internal/user/usertest"
+)
+
+// TestRepositoryConformance runs the contract tests the in-memory repository
+// passes against the SQL repository
+func TestRepositoryConformance(t *testing.T) {
+	usertest.TestRepository(t, func(t *testing.T) user.UserRepository {
+		pool, err := pgxpool.New(context.Background(), testutil.Shared(t).NewDatabase(t))
+		require.NoError(t, err)
+		t.Cleanup(pool.Close)
+
+		return user.NewRepository(user.RepositoryParams{
+			Pool:    pool,
+			Config:  database.NewConfig(nil),
+			Metrics: user.NewMetrics(),
+		})
+	})
+}
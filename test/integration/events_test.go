@@ -0,0 +1,69 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/client"
+	"github.com/things-kit/example-db/internal/events"
+	"github.com/things-kit/example-db/internal/tenant"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/testutil/apptest"
+)
+
+// TestEventsPublished checks that user mutations reach the broker: the
+// service writes them to the outbox and the relay publishes them to NATS
+func TestEventsPublished(t *testing.T) {
+	nc := testutil.StartNATSContainer(t)
+	sub := nc.Subscribe(t, "users.>")
+
+	app := apptest.Start(t, testutil.Shared(t).NewDatabase(t), map[string]any{
+		"nats.enabled":         true,
+		"nats.url":             nc.URL,
+		"outbox.poll_interval": 50 * time.Millisecond,
+	})
+	c := client.New(app.URL)
+	ctx := context.Background()
+
+	created, err := c.CreateUser(ctx, client.UserRequest{Name: "Ann", Email: "ann@example.com"})
+	require.NoError(t, err)
+	_, err = c.UpdateUser(ctx, created.ID, client.UserRequest{Name: "Anne", Email: "anne@example.com"})
+	require.NoError(t, err)
+	require.NoError(t, c.DeleteUser(ctx, created.ID))
+
+	// The relay publishes in outbox order
+	for _, want := range []struct {
+		eventType string
+		email     string
+	}{
+		{events.UserCreated, "ann@example.com"},
+		{events.UserUpdated, "anne@example.com"},
+		{events.UserDeleted, ""},
+	} {
+		msg := sub.Next(t, 10*time.Second)
+		assert.Equal(t, "users."+want.eventType, msg.Subject)
+
+		var evt events.Event
+		require.NoError(t, json.Unmarshal(msg.Data, &evt))
+		assert.Equal(t, want.eventType, evt.Type)
+		assert.Equal(t, events.SchemaVersion, evt.SchemaVersion)
+		assert.Equal(t, tenant.Default, evt.TenantID)
+		assert.Equal(t, string(created.ID), strconv.FormatInt(evt.AggregateID, 10))
+
+		if want.email != "" {
+			var data struct {
+				Email string `json:"email"`
+			}
+			require.NoError(t, json.Unmarshal(evt.Data, &data))
+			assert.Equal(t, want.email, data.Email)
+		}
+	}
+
+	// Published rows are marked, so the relay doesn't send them again
+	sub.ExpectNone(t, 500*time.Millisecond)
+}
@@ -0,0 +1,114 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/database"
+	"github.com/things-kit/example-db/internal/prefs"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/user"
+)
+
+func TestEventStore(t *testing.T) {
+	dsn := testutil.Shared(t).NewDatabase(t)
+
+	ctx := context.Background()
+
+	pool, err := pgxpool.New(ctx, dsn)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	repo := user.NewRepository(user.RepositoryParams{
+		Pool:    pool,
+		Config:  database.NewConfig(nil),
+		Metrics: user.NewMetrics(),
+	})
+	store := user.NewEventStore(repo)
+
+	eventTypes := func(id int64) []string {
+		rows, err := pool.Query(ctx, `SELECT type FROM user_events WHERE user_id = $1 ORDER BY version`, id)
+		require.NoError(t, err)
+		types, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		require.NoError(t, err)
+		return types
+	}
+
+	created, err := store.Create(ctx, user.CreateUserRequest{Name: "Ann", Email: "ann@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, user.StatusActive, created.Status)
+
+	_, err = store.Create(ctx, user.CreateUserRequest{Name: "Ann", Email: "ann@example.com"})
+	assert.ErrorIs(t, err, user.ErrEmailTaken)
+
+	updated, err := store.Update(ctx, created.ID, user.CreateUserRequest{Name: "Ann", Email: "anne@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "anne@example.com", updated.Email)
+
+	_, err = store.SetPreferences(ctx, created.ID, prefs.Preferences{"theme": "dark"})
+	require.NoError(t, err)
+	_, err = store.SetStatus(ctx, created.ID, user.StatusSuspended)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		user.EventUserCreated,
+		user.EventEmailChanged,
+		user.EventPreferencesChanged,
+		user.EventStatusChanged,
+	}, eventTypes(created.ID))
+
+	// Reads come from the projection
+	got, err := store.GetByID(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "anne@example.com", got.Email)
+	assert.Equal(t, user.StatusSuspended, got.Status)
+	assert.Equal(t, "dark", got.Preferences.String("theme", ""))
+
+	t.Run("UserWithoutEvents", func(t *testing.T) {
+		// Created with the state storage before switching to events
+		legacy, err := repo.Create(ctx, user.CreateUserRequest{Name: "Lee", Email: "lee@example.com"})
+		require.NoError(t, err)
+
+		_, err = store.Update(ctx, legacy.ID, user.CreateUserRequest{Name: "Leo", Email: "lee@example.com"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{user.EventUserCreated, user.EventNameChanged}, eventTypes(legacy.ID))
+	})
+
+	t.Run("Replay", func(t *testing.T) {
+		_, err := store.Delete(ctx, created.ID)
+		require.NoError(t, err)
+
+		_, err = pool.Exec(ctx, `DELETE FROM users`)
+		require.NoError(t, err)
+
+		n, err := store.Replay(ctx)
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, n)
+
+		var rows int
+		require.NoError(t, pool.QueryRow(ctx, `SELECT count(*) FROM users WHERE id = $1`, created.ID).Scan(&rows))
+		assert.Zero(t, rows, "a deleted user is not brought back, as it may have been purged")
+
+		users, err := store.List(ctx, user.ListFilter{})
+		require.NoError(t, err)
+		require.Len(t, users, 1)
+		assert.Equal(t, "Leo", users[0].Name)
+	})
+
+	t.Run("PurgeDeletesEvents", func(t *testing.T) {
+		gone, err := store.Create(ctx, user.CreateUserRequest{Name: "Gus", Email: "gus@example.com"})
+		require.NoError(t, err)
+		_, err = store.Delete(ctx, gone.ID)
+		require.NoError(t, err)
+
+		purged, _, err := repo.PurgeDeleted(ctx, time.Now().Add(time.Minute), 100)
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, purged)
+		assert.Empty(t, eventTypes(gone.ID), "the personal data in the events goes with the user")
+	})
+}
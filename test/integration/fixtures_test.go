@@ -0,0 +1,54 @@
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/database"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/testutil/factory"
+	"github.com/things-kit/example-db/internal/user"
+)
+
+func TestLoadFixtures(t *testing.T) {
+	dsn := testutil.Shared(t).NewDatabase(t)
+	testutil.LoadFixtures(t, dsn, "testdata/fixtures")
+
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dsn)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	repo := user.NewRepository(user.RepositoryParams{
+		Pool:    pool,
+		Config:  database.NewConfig(nil),
+		Metrics: user.NewMetrics(),
+	})
+
+	ids := func(users []*user.User) []int64 {
+		var ids []int64
+		for _, u := range users {
+			ids = append(ids, u.ID)
+		}
+		return ids
+	}
+
+	users, err := repo.List(ctx, user.ListFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{5, 2, 1}, ids(users), "suspended and deleted users are left out")
+	assert.Equal(t, "dark", users[2].Preferences["theme"], "maps are stored as JSON")
+
+	users, err = repo.List(ctx, user.ListFilter{Limit: 2, Offset: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2, 1}, ids(users))
+
+	created := factory.User().Create(t, repo)
+	assert.EqualValues(t, 6, created.ID, "the sequence continues after the fixtures")
+
+	var deliveries int
+	require.NoError(t, pool.QueryRow(ctx, "SELECT count(*) FROM webhook_deliveries WHERE webhook_id = 1").Scan(&deliveries))
+	assert.Equal(t, 1, deliveries, "SQL fixtures are loaded too")
+}
@@ -0,0 +1,72 @@
+package integration
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/httpcache"
+	"github.com/things-kit/example-db/internal/testutil"
+)
+
+// TestRedisStore runs the HTTP cache store against Redis, with two stores
+// standing in for two replicas of the service
+func TestRedisStore(t *testing.T) {
+	rc := testutil.StartRedisContainer(t)
+	client := rc.Client(t)
+	require.NoError(t, client.FlushDB(t.Context()).Err())
+
+	ctx := t.Context()
+	a := httpcache.NewRedisStore(rc.Client(t))
+	b := httpcache.NewRedisStore(rc.Client(t))
+
+	t.Run("SetAndGet", func(t *testing.T) {
+		entry := &httpcache.Entry{
+			Status:   http.StatusOK,
+			Header:   http.Header{"Content-Type": {"application/json"}},
+			Body:     []byte(`{"id":1}`),
+			StoredAt: time.Now().UTC().Truncate(time.Second),
+		}
+		require.NoError(t, a.Set(ctx, "users/1", entry, time.Minute))
+
+		got, err := b.Get(ctx, "users/1")
+		require.NoError(t, err)
+		assert.Equal(t, entry, got)
+
+		got, err = b.Get(ctx, "users/2")
+		require.NoError(t, err)
+		assert.Nil(t, got)
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		require.NoError(t, a.Set(ctx, "users/3", &httpcache.Entry{Status: http.StatusOK}, time.Second))
+		assert.Eventually(t, func() bool {
+			got, err := b.Get(ctx, "users/3")
+			return err == nil && got == nil
+		}, 5*time.Second, 100*time.Millisecond)
+	})
+
+	t.Run("Claim", func(t *testing.T) {
+		ok, err := a.Claim(ctx, "users/4", time.Minute)
+		require.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = b.Claim(ctx, "users/4", time.Minute)
+		require.NoError(t, err)
+		assert.False(t, ok, "a second replica claimed the same key")
+	})
+
+	t.Run("Invalidate", func(t *testing.T) {
+		gen, err := b.Generation(ctx, "acme")
+		require.NoError(t, err)
+		assert.Zero(t, gen)
+
+		require.NoError(t, a.Invalidate(ctx, "acme"))
+
+		gen, err = b.Generation(ctx, "acme")
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, gen, "the invalidation didn't reach the other replica")
+	})
+}
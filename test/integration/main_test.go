@@ -0,0 +1,13 @@
+package integration
+
+import (
+	"testing"
+
+	"github.com/things-kit/example-db/internal/testutil"
+)
+
+// TestMain runs every test against one shared Postgres container; each test
+// works in its own copy of the template database
+func TestMain(m *testing.M) {
+	testutil.Main(m)
+}
@@ -0,0 +1,48 @@
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/client"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/testutil/apptest"
+)
+
+// TestMetrics scrapes /metrics around a few API calls, so instruments that
+// are no longer registered or no longer fed fail here rather than on a
+// dashboard
+func TestMetrics(t *testing.T) {
+	app := apptest.Start(t, testutil.Shared(t).NewDatabase(t), nil)
+	c := client.New(app.URL)
+	ctx := context.Background()
+
+	before := testutil.ScrapeMetrics(t, app.OpsURL)
+	for _, name := range []string{
+		"go_goroutines",
+		"db_pool_max_open_connections",
+		"go_sql_open_connections",
+		"pgxpool_acquires_total",
+		"user_get_by_id_queries_total",
+	} {
+		before.AssertSeries(t, name, nil)
+	}
+
+	u, err := c.CreateUser(ctx, client.UserRequest{Name: "Ann", Email: "ann@example.com"})
+	require.NoError(t, err)
+	_, err = c.GetUser(ctx, u.ID, false)
+	require.NoError(t, err)
+	_, err = c.GetUser(ctx, "999", false)
+	require.True(t, client.IsNotFound(err))
+
+	after := testutil.ScrapeMetrics(t, app.OpsURL)
+	after.AssertIncreased(t, before, "http_requests_total", testutil.Labels{"method": "POST", "route": "/users", "status": "201"})
+	after.AssertIncreased(t, before, "http_requests_total", testutil.Labels{"method": "GET", "route": "/users/:id", "status": "200"})
+	after.AssertIncreased(t, before, "http_requests_total", testutil.Labels{"method": "GET", "route": "/users/:id", "status": "404"})
+	after.AssertIncreased(t, before, "http_request_duration_seconds", testutil.Labels{"method": "POST", "route": "/users"})
+	after.AssertIncreased(t, before, "db_query_duration_seconds", testutil.Labels{"operation": "insert", "table": "users"})
+	after.AssertIncreased(t, before, "db_query_duration_seconds", testutil.Labels{"operation": "select", "table": "users"})
+	after.AssertIncreased(t, before, "pgxpool_acquires_total", nil)
+	after.AssertIncreased(t, before, "user_get_by_id_queries_total", nil)
+}
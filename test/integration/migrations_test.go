@@ -0,0 +1,45 @@
+package integration
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/migrations"
+	"github.com/things-kit/example-db/internal/testutil"
+
+	_ "github.com/lib/pq"
+)
+
+// TestMigrations checks that every migration applies in order, rolls back
+// cleanly and applies again
+func TestMigrations(t *testing.T) {
+	dsn := testutil.Shared(t).NewDatabase(t)
+
+	ctx := context.Background()
+
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	migrator, err := migrations.NewMigrator(db)
+	require.NoError(t, err)
+
+	status, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	for _, s := range status {
+		assert.Equal(t, goose.StateApplied, s.State, s.Source.Path)
+	}
+
+	for range status {
+		_, err := migrator.Down(ctx)
+		require.NoError(t, err)
+	}
+
+	results, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Len(t, results, len(status))
+}
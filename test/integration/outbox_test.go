@@ -0,0 +1,204 @@
+package integration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/events"
+	"github.com/things-kit/example-db/internal/outbox"
+	"github.com/things-kit/example-db/internal/readmodel"
+	"github.com/things-kit/example-db/internal/testutil"
+)
+
+// The relay delivers at least once: a relay killed after the broker accepted
+// events but before it marked them published sends them again after a
+// restart. These tests check that a consumer skipping events it has seen, as
+// the read model projector does, still applies every event exactly once.
+
+// relayConsumer stands in for the broker and an idempotent consumer behind it.
+// It counts the deliveries of every event and applies them with the read
+// model projector.
+type relayConsumer struct {
+	projector *readmodel.Projector
+
+	mu         sync.Mutex
+	deliveries map[string]int
+	// first holds event IDs in the order of their first delivery
+	first []string
+	total int
+	// crash, if set, is called after delivery number crashAt
+	crashAt int
+	crash   func(ctx context.Context)
+}
+
+func newRelayConsumer(db *sql.DB) *relayConsumer {
+	return &relayConsumer{projector: readmodel.NewProjector(db), deliveries: map[string]int{}}
+}
+
+// Publish implements events.Publisher
+func (c *relayConsumer) Publish(ctx context.Context, evt events.Event) error {
+	if err := c.projector.Apply(ctx, evt); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.deliveries[evt.ID]++
+	if c.deliveries[evt.ID] == 1 {
+		c.first = append(c.first, evt.ID)
+	}
+	c.total++
+	var crash func(ctx context.Context)
+	if c.total == c.crashAt {
+		crash, c.crash = c.crash, nil
+	}
+	c.mu.Unlock()
+
+	if crash != nil {
+		crash(ctx)
+	}
+	return nil
+}
+
+// counts returns a copy of the delivery counts and first delivery order
+func (c *relayConsumer) counts() (map[string]int, []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	deliveries := make(map[string]int, len(c.deliveries))
+	for id, n := range c.deliveries {
+		deliveries[id] = n
+	}
+	return deliveries, append([]string(nil), c.first...)
+}
+
+// writeOutboxEvents writes n UserCreated events to the outbox, for users
+// numbered from first, and returns their IDs in outbox order
+func writeOutboxEvents(t *testing.T, pool *pgxpool.Pool, first, n int) []string {
+	t.Helper()
+
+	ids := make([]string, n)
+	for i := range n {
+		id := int64(first + i)
+		evt, err := events.New(events.UserCreated, id, map[string]any{
+			"id":         id,
+			"name":       fmt.Sprintf("User %d", id),
+			"email":      fmt.Sprintf("user%d@example.com", id),
+			"updated_at": time.Now().UTC(),
+		}, nil, nil)
+		require.NoError(t, err)
+		require.NoError(t, outbox.Write(context.Background(), pool, evt))
+		ids[i] = evt.ID
+	}
+	return ids
+}
+
+// pendingOutbox returns the number of unpublished outbox rows
+func pendingOutbox(t *testing.T, db *sql.DB) int {
+	t.Helper()
+
+	var n int
+	require.NoError(t, db.QueryRow(`SELECT count(*) FROM outbox WHERE published_at IS NULL`).Scan(&n))
+	return n
+}
+
+// openOutbox returns a pgx pool to write events with and the database/sql
+// handle the relay and projector run on
+func openOutbox(t *testing.T) (*pgxpool.Pool, *sql.DB) {
+	t.Helper()
+
+	dsn := testutil.Shared(t).NewDatabase(t)
+	pool, err := pgxpool.New(context.Background(), dsn)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return pool, db
+}
+
+// startRelay starts a relay publishing to c and stops it at the end of the test
+func startRelay(t *testing.T, db *sql.DB, c *relayConsumer, cfg *outbox.Config) {
+	t.Helper()
+
+	relay := outbox.NewRelay(db, c, cfg, testutil.NopLogger{})
+	require.NoError(t, relay.Start(context.Background()))
+	t.Cleanup(func() { _ = relay.Stop(context.Background()) })
+}
+
+func TestOutboxRelayRestart(t *testing.T) {
+	pool, db := openOutbox(t)
+	cfg := &outbox.Config{PollInterval: 20 * time.Millisecond, BatchSize: 10}
+	c := newRelayConsumer(db)
+
+	ids := writeOutboxEvents(t, pool, 1, 50)
+
+	// Kill the relay halfway through its third batch: events 21 to 25 reached
+	// the consumer, but the batch is never marked published
+	relay := outbox.NewRelay(db, c, cfg, testutil.NopLogger{})
+	stopped := make(chan struct{})
+	c.crashAt = 25
+	c.crash = func(ctx context.Context) {
+		go func() {
+			_ = relay.Stop(context.Background())
+			close(stopped)
+		}()
+		<-ctx.Done()
+	}
+	require.NoError(t, relay.Start(context.Background()))
+
+	select {
+	case <-stopped:
+	case <-time.After(30 * time.Second):
+		t.Fatal("relay did not crash")
+	}
+	assert.Equal(t, 30, pendingOutbox(t, db), "the crashed batch is still pending")
+
+	// A new relay resumes with the crashed batch while more events arrive
+	startRelay(t, db, c, cfg)
+	ids = append(ids, writeOutboxEvents(t, pool, 51, 20)...)
+	require.Eventually(t, func() bool { return pendingOutbox(t, db) == 0 }, 30*time.Second, 20*time.Millisecond)
+
+	deliveries, first := c.counts()
+	assert.Equal(t, ids, first, "every event is delivered, in outbox order")
+	for i, id := range ids {
+		want := 1
+		if i >= 20 && i < 25 {
+			want = 2
+		}
+		assert.Equal(t, want, deliveries[id], "deliveries of event %d", i+1)
+	}
+
+	// The consumer applied every event once
+	var processed, entries int
+	require.NoError(t, db.QueryRow(`SELECT count(*) FROM processed_events WHERE consumer = 'user_directory'`).Scan(&processed))
+	require.NoError(t, db.QueryRow(`SELECT count(*) FROM user_directory`).Scan(&entries))
+	assert.Equal(t, len(ids), processed)
+	assert.Equal(t, len(ids), entries)
+}
+
+func TestOutboxConcurrentRelays(t *testing.T) {
+	pool, db := openOutbox(t)
+	cfg := &outbox.Config{PollInterval: 10 * time.Millisecond, BatchSize: 10}
+	c := newRelayConsumer(db)
+
+	// SKIP LOCKED hands every row to one relay, so nothing is sent twice
+	for range 3 {
+		startRelay(t, db, c, cfg)
+	}
+	ids := writeOutboxEvents(t, pool, 1, 200)
+	require.Eventually(t, func() bool { return pendingOutbox(t, db) == 0 }, 30*time.Second, 20*time.Millisecond)
+
+	deliveries, _ := c.counts()
+	require.Len(t, deliveries, len(ids))
+	for i, id := range ids {
+		assert.Equal(t, 1, deliveries[id], "deliveries of event %d", i+1)
+	}
+}
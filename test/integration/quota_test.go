@@ -0,0 +1,61 @@
+package integration
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/quota"
+	"github.com/things-kit/example-db/internal/testutil"
+)
+
+// TestQuotaRedisStore runs the quota store against Redis, with two stores
+// standing in for two replicas of the service
+func TestQuotaRedisStore(t *testing.T) {
+	rc := testutil.StartRedisContainer(t)
+	client := rc.Client(t)
+	require.NoError(t, client.FlushDB(t.Context()).Err())
+
+	ctx := t.Context()
+	a := quota.NewRedisStore(rc.Client(t))
+	b := quota.NewRedisStore(rc.Client(t))
+
+	t.Run("SharedCount", func(t *testing.T) {
+		expires := time.Now().Add(time.Hour)
+
+		var wg sync.WaitGroup
+		for i := range 50 {
+			store := a
+			if i%2 == 1 {
+				store = b
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := store.Incr(ctx, "default:2024-01-02:1", expires)
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+
+		n, err := b.Get(ctx, "default:2024-01-02:1")
+		require.NoError(t, err)
+		assert.EqualValues(t, 50, n, "a replica's count was lost")
+
+		n, err = a.Get(ctx, "default:2024-01-02:2")
+		require.NoError(t, err)
+		assert.Zero(t, n)
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		_, err := a.Incr(ctx, "default:2024-01-02:3", time.Now().Add(time.Second))
+		require.NoError(t, err)
+
+		assert.Eventually(t, func() bool {
+			n, err := b.Get(ctx, "default:2024-01-02:3")
+			return err == nil && n == 0
+		}, 5*time.Second, 100*time.Millisecond)
+	})
+}
@@ -1,41 +1,430 @@
 package integration
 
 import (
-"context"
-"database/sql"
-"testing"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
 
-"github.com/stretchr/testify/assert"
-"github.com/stretchr/testify/require"
-"github.com/things-kit/example-db/internal/testutil"
-"github.com/things-kit/example-db/internal/user"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/database"
+	"github.com/things-kit/example-db/internal/prefs"
+	"github.com/things-kit/example-db/internal/seed"
+	"github.com/things-kit/example-db/internal/tenant"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/user"
 
-_ "github.com/lib/pq"
+	_ "github.com/lib/pq"
 )
 
 func TestUserRepo(t *testing.T) {
-pgContainer := testutil.StartPostgresContainer(t)
-defer pgContainer.Terminate(t)
+	dsn := testutil.Shared(t).NewDatabase(t)
 
-pgContainer.InitSchema(t, "../../schema.sql")
+	ctx := context.Background()
 
-db, err := sql.Open("postgres", pgContainer.DSN)
-require.NoError(t, err)
-defer db.Close()
+	pool, err := pgxpool.New(ctx, dsn)
+	require.NoError(t, err)
+	defer pool.Close()
 
-require.NoError(t, db.Ping())
+	require.NoError(t, pool.Ping(ctx))
 
-repo := user.NewRepository(db)
-ctx := context.Background()
+	repo := user.NewRepository(user.RepositoryParams{
+		Pool:    pool,
+		Config:  database.NewConfig(nil),
+		Metrics: user.NewMetrics(),
+	})
 
-t.Run("CreateAndGetUser", func(t *testing.T) {
-req := user.CreateUserRequest{Name: "John", Email: "john@example.com"}
-created, err := repo.Create(ctx, req)
-require.NoError(t, err)
-assert.NotZero(t, created.ID)
+	t.Run("CreateAndGetUser", func(t *testing.T) {
+		req := user.CreateUserRequest{Name: "John", Email: "john@example.com"}
+		created, err := repo.Create(ctx, req)
+		require.NoError(t, err)
+		assert.NotZero(t, created.ID)
 
-retrieved, err := repo.GetByID(ctx, created.ID)
-require.NoError(t, err)
-assert.Equal(t, created.Name, retrieved.Name)
-})
+		retrieved, err := repo.GetByID(ctx, created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, created.Name, retrieved.Name)
+	})
+
+	t.Run("UUIDKey", func(t *testing.T) {
+		created, err := repo.Create(ctx, user.CreateUserRequest{Name: "Uma", Email: "uma@example.com"})
+		require.NoError(t, err)
+		assert.Equal(t, uuid.Version(7), created.UUID.Version())
+
+		id, err := repo.GetIDByUUID(ctx, created.UUID)
+		require.NoError(t, err)
+		assert.Equal(t, created.ID, id)
+
+		_, err = repo.GetIDByUUID(ctx, uuid.New())
+		assert.ErrorIs(t, err, user.ErrNotFound)
+	})
+
+	t.Run("ConcurrentGetByID", func(t *testing.T) {
+		created, err := repo.Create(ctx, user.CreateUserRequest{Name: "Jane", Email: "jane@example.com"})
+		require.NoError(t, err)
+
+		const callers = 10
+		results := make([]*user.User, callers)
+		errs := make([]error, callers)
+
+		var wg sync.WaitGroup
+		for i := 0; i < callers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i], errs[i] = repo.GetByID(ctx, created.ID)
+			}(i)
+		}
+		wg.Wait()
+
+		for i := 0; i < callers; i++ {
+			require.NoError(t, errs[i])
+			assert.Equal(t, created.Email, results[i].Email)
+		}
+		assert.NotSame(t, results[0], results[1])
+	})
+
+	t.Run("SoftDeleteAndPurge", func(t *testing.T) {
+		created, err := repo.Create(ctx, user.CreateUserRequest{Name: "Gone", Email: "gone@example.com"})
+		require.NoError(t, err)
+
+		_, err = repo.Delete(ctx, created.ID)
+		require.NoError(t, err)
+
+		_, err = repo.GetByID(ctx, created.ID)
+		assert.Error(t, err)
+
+		// The email is free again once the user is deleted
+		again, err := repo.Create(ctx, user.CreateUserRequest{Name: "Back", Email: "gone@example.com"})
+		require.NoError(t, err)
+
+		purged, _, err := repo.PurgeDeleted(ctx, time.Now().Add(time.Minute), 100)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), purged)
+
+		_, err = repo.GetByID(ctx, again.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("ListFilterAndSort", func(t *testing.T) {
+		since := time.Now().Add(-time.Second)
+		first, err := repo.Create(ctx, user.CreateUserRequest{Name: "First", Email: "first@example.com"})
+		require.NoError(t, err)
+		second, err := repo.Create(ctx, user.CreateUserRequest{Name: "Second", Email: "second@example.com"})
+		require.NoError(t, err)
+
+		_, err = repo.Update(ctx, first.ID, user.CreateUserRequest{Name: "First", Email: "first@example.com"})
+		require.NoError(t, err)
+
+		users, err := repo.List(ctx, user.ListFilter{CreatedAfter: &since, Ascending: true})
+		require.NoError(t, err)
+		require.Len(t, users, 2)
+		assert.Equal(t, []int64{first.ID, second.ID}, []int64{users[0].ID, users[1].ID})
+
+		users, err = repo.List(ctx, user.ListFilter{CreatedAfter: &since, SortBy: user.SortUpdatedAt})
+		require.NoError(t, err)
+		require.Len(t, users, 2)
+		assert.Equal(t, first.ID, users[0].ID, "the most recently updated user comes first")
+	})
+
+	t.Run("PreferencesFilter", func(t *testing.T) {
+		dark, err := repo.Create(ctx, user.CreateUserRequest{Name: "Dark", Email: "dark@example.com"})
+		require.NoError(t, err)
+		assert.Empty(t, dark.Preferences)
+
+		updated, err := repo.SetPreferences(ctx, dark.ID, prefs.Preferences{"theme": "dark", "beta": true})
+		require.NoError(t, err)
+		assert.Equal(t, "dark", updated.Preferences.String("theme", ""))
+
+		users, err := repo.List(ctx, user.ListFilter{Preferences: prefs.Preferences{"theme": "dark", "beta": true}})
+		require.NoError(t, err)
+		require.Len(t, users, 1)
+		assert.Equal(t, dark.ID, users[0].ID)
+
+		users, err = repo.List(ctx, user.ListFilter{Preferences: prefs.Preferences{"theme": "light"}})
+		require.NoError(t, err)
+		assert.Empty(t, users)
+	})
+
+	t.Run("SuspendedUsersAreHidden", func(t *testing.T) {
+		created, err := repo.Create(ctx, user.CreateUserRequest{Name: "Sam", Email: "sam@example.com"})
+		require.NoError(t, err)
+		assert.Equal(t, user.StatusActive, created.Status)
+
+		suspended, err := repo.SetStatus(ctx, created.ID, user.StatusSuspended)
+		require.NoError(t, err)
+		assert.Equal(t, user.StatusSuspended, suspended.Status)
+
+		ids := func(f user.ListFilter) []int64 {
+			users, err := repo.List(ctx, f)
+			require.NoError(t, err)
+			var ids []int64
+			for _, u := range users {
+				ids = append(ids, u.ID)
+			}
+			return ids
+		}
+		assert.NotContains(t, ids(user.ListFilter{}), created.ID)
+		assert.Equal(t, []int64{created.ID}, ids(user.ListFilter{Status: user.StatusSuspended}))
+		assert.Contains(t, ids(user.ListFilter{Status: user.StatusAll}), created.ID)
+	})
+
+	t.Run("TenantIsolation", func(t *testing.T) {
+		acme := tenant.WithTenant(ctx, "acme")
+		globex := tenant.WithTenant(ctx, "globex")
+
+		a, err := repo.Create(acme, user.CreateUserRequest{Name: "Ann", Email: "ann@example.com"})
+		require.NoError(t, err)
+		_, err = repo.Create(globex, user.CreateUserRequest{Name: "Ann", Email: "ann@example.com"})
+		require.NoError(t, err, "emails are unique per tenant")
+
+		_, err = repo.GetByID(globex, a.ID)
+		assert.ErrorIs(t, err, user.ErrNotFound)
+		_, err = repo.GetIDByUUID(globex, a.UUID)
+		assert.ErrorIs(t, err, user.ErrNotFound)
+		_, err = repo.Update(globex, a.ID, user.CreateUserRequest{Name: "Eve", Email: "eve@example.com"})
+		assert.ErrorIs(t, err, user.ErrNotFound)
+		assert.ErrorIs(t, repo.SetAdmin(globex, a.ID, true), user.ErrNotFound)
+		_, err = repo.Delete(globex, a.ID)
+		assert.ErrorIs(t, err, user.ErrNotFound)
+
+		users, err := repo.List(globex, user.ListFilter{})
+		require.NoError(t, err)
+		require.Len(t, users, 1)
+		assert.NotEqual(t, a.ID, users[0].ID)
+
+		got, err := repo.GetByEmail(acme, "ann@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, a.ID, got.ID)
+	})
+
+	t.Run("Profiles", func(t *testing.T) {
+		u, err := repo.Create(ctx, user.CreateUserRequest{Name: "Pat", Email: "pat@example.com"})
+		require.NoError(t, err)
+
+		got, profile, err := repo.GetWithProfile(ctx, u.ID)
+		require.NoError(t, err)
+		assert.Equal(t, u.ID, got.ID)
+		assert.Nil(t, profile, "users start without a profile")
+
+		_, err = repo.Profiles().Upsert(ctx, u.ID, user.ProfileRequest{Phone: "+1 555 0100"})
+		require.NoError(t, err)
+		updated, err := repo.Profiles().Upsert(ctx, u.ID, user.ProfileRequest{Bio: "Hi"})
+		require.NoError(t, err)
+		assert.Empty(t, updated.Phone, "upsert replaces the whole profile")
+
+		_, profile, err = repo.GetWithProfile(ctx, u.ID)
+		require.NoError(t, err)
+		require.NotNil(t, profile)
+		assert.Equal(t, "Hi", profile.Bio)
+
+		profiles, err := repo.Profiles().List(ctx, []int64{u.ID, u.ID + 1000})
+		require.NoError(t, err)
+		assert.Len(t, profiles, 1)
+
+		_, err = repo.Profiles().Get(tenant.WithTenant(ctx, "globex"), u.ID)
+		assert.ErrorIs(t, err, user.ErrNotFound)
+	})
+
+	t.Run("CopyFrom", func(t *testing.T) {
+		reqs := seed.FakeUsers(100, 7)
+		n, err := repo.CopyFrom(ctx, reqs)
+		require.NoError(t, err)
+		assert.Equal(t, int64(100), n)
+
+		got, err := repo.GetByEmail(ctx, reqs[42].Email)
+		require.NoError(t, err)
+		assert.Equal(t, user.StatusActive, got.Status)
+		assert.NotEqual(t, uuid.Nil, got.UUID)
+
+		_, err = repo.CopyFrom(ctx, []user.CreateUserRequest{
+			{Name: "New", Email: "new-copy@example.com"},
+			reqs[0],
+		})
+		assert.ErrorIs(t, err, user.ErrEmailTaken)
+		_, err = repo.GetByEmail(ctx, "new-copy@example.com")
+		assert.ErrorIs(t, err, user.ErrNotFound, "a failed COPY inserts nothing")
+	})
+
+	t.Run("ListWithTotal", func(t *testing.T) {
+		filter := user.ListFilter{Status: user.StatusAll}
+		all, err := repo.List(ctx, filter)
+		require.NoError(t, err)
+
+		filter.Limit, filter.Offset = 2, 1
+		page, total, err := repo.ListWithTotal(ctx, filter)
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(all)), total)
+		assert.Equal(t, all[1:3], page)
+
+		filter.Offset = len(all)
+		page, total, err = repo.ListWithTotal(ctx, filter)
+		require.NoError(t, err)
+		assert.Empty(t, page)
+		assert.Equal(t, int64(len(all)), total, "pages past the end still count")
+
+		n, err := repo.Count(ctx, filter)
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(all)), n)
+	})
+
+	t.Run("Keyset", func(t *testing.T) {
+		for _, filter := range []user.ListFilter{
+			{Status: user.StatusAll, SortBy: user.SortCreatedAt},
+			{Status: user.StatusAll, SortBy: user.SortUpdatedAt, Ascending: true},
+		} {
+			all, err := repo.List(ctx, filter)
+			require.NoError(t, err)
+
+			var paged []*user.User
+			filter.Limit = 7
+			for {
+				page, err := repo.List(ctx, filter)
+				require.NoError(t, err)
+				paged = append(paged, page...)
+				if len(page) < filter.Limit {
+					break
+				}
+				last := page[len(page)-1]
+				filter.After = &user.Keyset{Key: last.CreatedAt, ID: last.ID}
+				if filter.SortBy == user.SortUpdatedAt {
+					filter.After.Key = last.UpdatedAt
+				}
+			}
+			assert.Equal(t, all, paged, "pages resume after ties on the sort field")
+
+			_, total, err := repo.ListWithTotal(ctx, filter)
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(all)), total, "the total ignores the keyset")
+		}
+	})
+
+	t.Run("StreamMatchesList", func(t *testing.T) {
+		reqs := make([]user.CreateUserRequest, 1500)
+		for i := range reqs {
+			reqs[i] = user.CreateUserRequest{Name: "Stream", Email: fmt.Sprintf("stream.%d@example.com", i)}
+		}
+		_, err := repo.CopyFrom(ctx, reqs)
+		require.NoError(t, err)
+
+		filter := user.ListFilter{Status: user.StatusAll, Ascending: true}
+		listed, err := repo.List(ctx, filter)
+		require.NoError(t, err)
+		require.Greater(t, len(listed), 1000, "spans more than one fetch")
+
+		var streamed []*user.User
+		require.NoError(t, repo.Stream(ctx, filter, func(u *user.User) error {
+			streamed = append(streamed, u)
+			return nil
+		}))
+		assert.Equal(t, listed, streamed)
+
+		stop := errors.New("stop")
+		err = repo.Stream(ctx, filter, func(*user.User) error { return stop })
+		assert.ErrorIs(t, err, stop)
+	})
+
+	t.Run("WithTxRollsBack", func(t *testing.T) {
+		boom := errors.New("boom")
+		err := repo.WithTx(ctx, func(repo user.UserRepository) error {
+			created, err := repo.Create(ctx, user.CreateUserRequest{Name: "Tx", Email: "tx@example.com"})
+			if err != nil {
+				return err
+			}
+			if err := repo.AddAudit(ctx, created.ID, user.AuditCreate, nil, created); err != nil {
+				return err
+			}
+			return boom
+		})
+		require.ErrorIs(t, err, boom)
+
+		_, err = repo.GetByEmail(ctx, "tx@example.com")
+		assert.Error(t, err, "the user is rolled back with the transaction")
+	})
+
+	t.Run("ChangesInCommitOrder", func(t *testing.T) {
+		early, err := repo.Create(ctx, user.CreateUserRequest{Name: "Early", Email: "early@example.com"})
+		require.NoError(t, err)
+		late, err := repo.Create(ctx, user.CreateUserRequest{Name: "Late", Email: "late@example.com"})
+		require.NoError(t, err)
+		require.NoError(t, repo.AddAudit(ctx, late.ID, user.AuditCreate, nil, late))
+		changes, err := repo.ListChanges(ctx, user.ChangeFilter{Limit: 1000})
+		require.NoError(t, err)
+		cursor := changes[len(changes)-1].Seq
+
+		// A transaction writes an entry and stays open while a later one
+		// commits: the feed waits for it instead of moving past it
+		written, release := make(chan struct{}), make(chan struct{})
+		done := make(chan error)
+		go func() {
+			done <- repo.WithTx(ctx, func(repo user.UserRepository) error {
+				if err := repo.AddAudit(ctx, early.ID, user.AuditUpdate, nil, nil); err != nil {
+					return err
+				}
+				close(written)
+				<-release
+				return nil
+			})
+		}()
+		<-written
+		require.NoError(t, repo.AddAudit(ctx, late.ID, user.AuditUpdate, nil, nil))
+
+		changes, err = repo.ListChanges(ctx, user.ChangeFilter{AfterSeq: cursor, Limit: 10})
+		require.NoError(t, err)
+		assert.Empty(t, changes, "entries after a running transaction are held back")
+
+		close(release)
+		require.NoError(t, <-done)
+		changes, err = repo.ListChanges(ctx, user.ChangeFilter{AfterSeq: cursor, Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, changes, 2)
+		assert.Equal(t, early.ID, changes[0].User.ID, "the entry of the older transaction comes first")
+		assert.Equal(t, late.ID, changes[1].User.ID)
+	})
+
+	t.Run("ChangesListRemovedUsers", func(t *testing.T) {
+		removed, err := repo.Create(ctx, user.CreateUserRequest{Name: "Removed", Email: "removed@example.com"})
+		require.NoError(t, err)
+		require.NoError(t, repo.AddAudit(ctx, removed.ID, user.AuditCreate, nil, removed))
+		changes, err := repo.ListChanges(ctx, user.ChangeFilter{Limit: 1000})
+		require.NoError(t, err)
+		cursor := changes[len(changes)-1].Seq
+
+		require.NoError(t, repo.AddAudit(ctx, removed.ID, user.AuditDelete, removed, nil))
+		_, err = repo.Delete(ctx, removed.ID)
+		require.NoError(t, err)
+		_, _, err = repo.PurgeDeleted(ctx, time.Now().Add(time.Minute), 100)
+		require.NoError(t, err)
+
+		changes, err = repo.ListChanges(ctx, user.ChangeFilter{AfterSeq: cursor, Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, changes, 1)
+		assert.Equal(t, user.ChangeDeleted, changes[0].Type, "a purged user is a tombstone")
+		assert.Equal(t, removed.ID, changes[0].User.ID)
+		assert.Equal(t, removed.UUID, changes[0].User.UUID, "read from the audit snapshot")
+		assert.NotNil(t, changes[0].DeletedAt)
+
+		_, err = repo.ListChanges(ctx, user.ChangeFilter{AfterSeq: cursor, NotBefore: time.Now().Add(time.Hour), Limit: 10})
+		assert.ErrorIs(t, err, user.ErrResyncRequired, "a position older than the purge retention")
+	})
+
+	t.Run("SeedDemoUsers", func(t *testing.T) {
+		seeded, err := seed.Demo(ctx, repo)
+		require.NoError(t, err)
+		require.Len(t, seeded, len(seed.DemoUsers))
+
+		again, err := seed.Demo(ctx, repo)
+		require.NoError(t, err)
+		for i := range seeded {
+			assert.Equal(t, seeded[i].ID, again[i].ID)
+		}
+
+		created, err := seed.Fake(ctx, repo, 25, 7)
+		require.NoError(t, err)
+		assert.Equal(t, 25, created)
+	})
 }
@@ -0,0 +1,52 @@
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/database"
+	"github.com/things-kit/example-db/internal/migrations"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/testutil/factory"
+	"github.com/things-kit/example-db/internal/user"
+
+	_ "github.com/lib/pq"
+)
+
+func TestResetDatabase(t *testing.T) {
+	dsn := testutil.Shared(t).NewDatabase(t)
+
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dsn)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	repo := user.NewRepository(user.RepositoryParams{
+		Pool:    pool,
+		Config:  database.NewConfig(nil),
+		Metrics: user.NewMetrics(),
+	})
+
+	for range 2 {
+		t.Run("CleanSlate", func(t *testing.T) {
+			testutil.ResetDatabase(t, dsn)
+
+			users, err := repo.List(ctx, user.ListFilter{})
+			require.NoError(t, err)
+			assert.Empty(t, users)
+
+			created := factory.User().Create(t, repo)
+			assert.EqualValues(t, 1, created.ID, "sequences restart")
+			require.NoError(t, repo.AddAudit(ctx, created.ID, user.AuditCreate, nil, created))
+		})
+	}
+
+	var applied int
+	require.NoError(t, pool.QueryRow(ctx, "SELECT count(*) FROM goose_db_version WHERE version_id > 0").Scan(&applied))
+	files, err := migrations.FS.ReadDir(".")
+	require.NoError(t, err)
+	assert.Equal(t, len(files), applied, "migration history is kept")
+}
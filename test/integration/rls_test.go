@@ -0,0 +1,75 @@
+package integration
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/database"
+	"github.com/things-kit/example-db/internal/tenant"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/user"
+	"go.uber.org/fx/fxtest"
+)
+
+// TestRowLevelSecurity checks that the database itself isolates tenants when
+// the application connects as a role that doesn't own the tables
+func TestRowLevelSecurity(t *testing.T) {
+	dsn := testutil.Shared(t).NewDatabase(t)
+
+	ctx := context.Background()
+
+	owner, err := pgxpool.New(ctx, dsn)
+	require.NoError(t, err)
+	defer owner.Close()
+
+	// Roles belong to the whole server, so the role may exist from another
+	// test run on the shared container
+	_, err = owner.Exec(ctx, `
+		DO $$ BEGIN
+			CREATE ROLE app LOGIN PASSWORD 'app';
+		EXCEPTION WHEN duplicate_object OR unique_violation THEN NULL;
+		END $$;
+		GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO app;
+		GRANT USAGE ON ALL SEQUENCES IN SCHEMA public TO app;
+	`)
+	require.NoError(t, err)
+
+	cfg := database.NewConfig(nil)
+	appDSN, err := url.Parse(dsn)
+	require.NoError(t, err)
+	appDSN.User = url.UserPassword("app", "app")
+	cfg.DSN = appDSN.String()
+	cfg.RowLevelSecurity = true
+
+	lc := fxtest.NewLifecycle(t)
+	pool, err := database.NewPool(database.PoolParams{Lifecycle: lc, Config: cfg})
+	require.NoError(t, err)
+	lc.RequireStart()
+	defer lc.RequireStop()
+
+	repo := user.NewRepository(user.RepositoryParams{Pool: pool, Config: cfg, Metrics: user.NewMetrics()})
+
+	acme := tenant.WithTenant(ctx, "acme")
+	created, err := repo.Create(acme, user.CreateUserRequest{Name: "Ann", Email: "ann@example.com"})
+	require.NoError(t, err)
+
+	// An unscoped query only sees the rows of the connection's tenant
+	count := func(ctx context.Context) int {
+		var n int
+		require.NoError(t, pool.QueryRow(ctx, "SELECT count(*) FROM users WHERE id = $1", created.ID).Scan(&n))
+		return n
+	}
+	assert.Equal(t, 1, count(acme))
+	assert.Equal(t, 0, count(tenant.WithTenant(ctx, "globex")))
+	assert.Equal(t, 0, count(ctx), "the default tenant sees no other tenant's rows")
+	assert.Equal(t, 1, count(tenant.WithTenant(ctx, tenant.All)))
+
+	// Writes into another tenant are rejected by the policy
+	_, err = pool.Exec(tenant.WithTenant(ctx, "globex"),
+		"INSERT INTO users (name, email, uuid, tenant_id) VALUES ('Eve', 'eve@example.com', gen_random_uuid(), 'acme')")
+	assert.ErrorContains(t, err, "row-level security")
+}
@@ -0,0 +1,101 @@
+package integration
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/testutil"
+
+	_ "github.com/lib/pq"
+)
+
+// catalogQueries describe a schema, one row per object. Every column is
+// text, so rows compare as strings. The goose version table exists only in
+// migrated databases and is left out.
+var catalogQueries = map[string]string{
+	"column": `
+		SELECT table_name, column_name, udt_name,
+			coalesce(character_maximum_length::text, ''), is_nullable, coalesce(column_default, '')
+		FROM information_schema.columns
+		WHERE table_schema = 'public' AND table_name <> 'goose_db_version'`,
+	"constraint": `
+		SELECT conrelid::regclass::text, conname, pg_get_constraintdef(oid)
+		FROM pg_constraint
+		WHERE connamespace = 'public'::regnamespace AND conrelid::regclass::text <> 'goose_db_version'`,
+	"index": `
+		SELECT tablename, indexname, indexdef
+		FROM pg_indexes
+		WHERE schemaname = 'public' AND tablename <> 'goose_db_version'`,
+	"enum": `
+		SELECT t.typname, string_agg(e.enumlabel, ',' ORDER BY e.enumsortorder)
+		FROM pg_type t JOIN pg_enum e ON e.enumtypid = t.oid
+		WHERE t.typnamespace = 'public'::regnamespace
+		GROUP BY t.typname`,
+	"row security": `
+		SELECT relname, relrowsecurity::text, relforcerowsecurity::text
+		FROM pg_class
+		WHERE relnamespace = 'public'::regnamespace AND relkind = 'r' AND relname <> 'goose_db_version'`,
+	"policy": `
+		SELECT tablename, policyname, permissive, roles::text, cmd, coalesce(qual, ''), coalesce(with_check, '')
+		FROM pg_policies
+		WHERE schemaname = 'public'`,
+}
+
+// TestSchemaSnapshot checks that schema.sql builds the same schema as the
+// migration chain, so that the snapshot can't drift from the migrations
+// silently
+func TestSchemaSnapshot(t *testing.T) {
+	pgContainer := testutil.Shared(t)
+
+	migrated, err := sql.Open("postgres", pgContainer.NewDatabase(t))
+	require.NoError(t, err)
+	defer migrated.Close()
+
+	snapshot, err := sql.Open("postgres", pgContainer.NewEmptyDatabase(t))
+	require.NoError(t, err)
+	defer snapshot.Close()
+
+	schema, err := os.ReadFile("../../schema.sql")
+	require.NoError(t, err)
+	_, err = snapshot.ExecContext(t.Context(), string(schema))
+	require.NoError(t, err, "failed to apply schema.sql")
+
+	want := describeSchema(t, migrated)
+	got := describeSchema(t, snapshot)
+	assert.Equal(t, want, got, "schema.sql differs from the migrations (-migrations +schema.sql)")
+}
+
+// describeSchema lists the objects of the public schema of db, sorted
+func describeSchema(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+
+	var objects []string
+	for kind, query := range catalogQueries {
+		rows, err := db.QueryContext(t.Context(), query)
+		require.NoError(t, err, kind)
+
+		cols, err := rows.Columns()
+		require.NoError(t, err)
+		values := make([]string, len(cols))
+		dest := make([]any, len(cols))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+
+		for rows.Next() {
+			require.NoError(t, rows.Scan(dest...))
+			objects = append(objects, fmt.Sprintf("%s %s", kind, strings.Join(values, " | ")))
+		}
+		require.NoError(t, rows.Err())
+		rows.Close()
+	}
+
+	slices.Sort(objects)
+	return objects
+}
@@ -0,0 +1,27 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/things-kit/example-db/client"
+	"github.com/things-kit/example-db/internal/smoke"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/testutil/apptest"
+)
+
+// TestSmoke runs the smoke scenario of cmd/smoketest against the complete
+// service on a test database, as a gate before a release:
+//
+//	go test ./test/integration -run TestSmoke -v
+func TestSmoke(t *testing.T) {
+	app := apptest.Start(t, testutil.Shared(t).NewDatabase(t), nil)
+
+	var out bytes.Buffer
+	_, err := smoke.Run(context.Background(), client.New(app.URL), &out)
+	t.Log("\n" + out.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
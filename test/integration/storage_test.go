@@ -0,0 +1,103 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/client"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/testutil/apptest"
+)
+
+// pngHeader is enough of a PNG file for content sniffing
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// TestAvatarStorage uploads avatars through the API to MinIO and downloads
+// them again through their presigned URLs
+func TestAvatarStorage(t *testing.T) {
+	mc := testutil.StartMinIOContainer(t)
+
+	settings := mc.Settings("avatars")
+	settings["storage.presign_expiry"] = 2 * time.Second
+	// Purge deleted users right away
+	settings["purge.retention"] = time.Millisecond
+	settings["purge.interval"] = 100 * time.Millisecond
+	app := apptest.Start(t, testutil.Shared(t).NewDatabase(t), settings)
+	c := client.New(app.URL)
+	ctx := context.Background()
+
+	created, err := c.CreateUser(ctx, client.UserRequest{Name: "Ann", Email: "ann@example.com"})
+	require.NoError(t, err)
+	prefix := "avatars/" + string(created.ID) + "/"
+
+	first := slices.Concat(pngHeader, []byte("first"))
+	require.NoError(t, c.UploadAvatar(ctx, created.ID, "first.png", bytes.NewReader(first)))
+
+	keys := mc.Objects(t, "avatars", prefix)
+	require.Len(t, keys, 1)
+	firstKey := keys[0]
+	assert.True(t, strings.HasSuffix(firstKey, ".png"), firstKey)
+
+	info, data := mc.Object(t, "avatars", firstKey)
+	assert.Equal(t, "image/png", info.ContentType)
+	assert.Equal(t, first, data)
+
+	t.Run("PresignedURL", func(t *testing.T) {
+		avatar, err := c.GetAvatar(ctx, created.ID)
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(2*time.Second), avatar.ExpiresAt, time.Second)
+
+		resp, body := testutil.FetchPresigned(t, avatar.URL)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "image/png", resp.Header.Get("Content-Type"))
+		assert.Equal(t, first, body)
+
+		// The link stops working once it expires
+		time.Sleep(time.Until(avatar.ExpiresAt) + time.Second)
+		resp, _ = testutil.FetchPresigned(t, avatar.URL)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		second := slices.Concat(pngHeader, []byte("second"))
+		require.NoError(t, c.UploadAvatar(ctx, created.ID, "second.png", bytes.NewReader(second)))
+
+		// The previous object is removed with the upload of the next
+		mc.AssertNoObject(t, "avatars", firstKey)
+		keys := mc.Objects(t, "avatars", prefix)
+		require.Len(t, keys, 1)
+		_, data := mc.Object(t, "avatars", keys[0])
+		assert.Equal(t, second, data)
+
+		avatar, err := c.GetAvatar(ctx, created.ID)
+		require.NoError(t, err)
+		_, body := testutil.FetchPresigned(t, avatar.URL)
+		assert.Equal(t, second, body)
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		err := c.UploadAvatar(ctx, created.ID, "notes.txt", strings.NewReader("not an image"))
+		assert.Error(t, err)
+		assert.Len(t, mc.Objects(t, "avatars", prefix), 1)
+	})
+
+	t.Run("Purged", func(t *testing.T) {
+		gone, err := c.CreateUser(ctx, client.UserRequest{Name: "Gus", Email: "gus@example.com"})
+		require.NoError(t, err)
+		require.NoError(t, c.UploadAvatar(ctx, gone.ID, "gone.png", bytes.NewReader(first)))
+		goneKeys := mc.Objects(t, "avatars", "avatars/"+string(gone.ID)+"/")
+		require.Len(t, goneKeys, 1)
+
+		require.NoError(t, c.DeleteUser(ctx, gone.ID))
+		assert.Eventually(t, func() bool {
+			return len(mc.Objects(t, "avatars", "avatars/"+string(gone.ID)+"/")) == 0
+		}, 10*time.Second, 100*time.Millisecond, "the purge worker deletes the avatar of the purged user")
+	})
+}
@@ -0,0 +1,46 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/database"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/testutil/factory"
+	"github.com/things-kit/example-db/internal/user"
+)
+
+// TestDatabasePerTest runs parallel subtests, each on its own copy of the
+// template database
+func TestDatabasePerTest(t *testing.T) {
+	pgContainer := testutil.Shared(t)
+
+	for i := range 4 {
+		t.Run(fmt.Sprintf("Database%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			pool, err := pgxpool.New(ctx, pgContainer.NewDatabase(t))
+			require.NoError(t, err)
+			t.Cleanup(pool.Close)
+
+			repo := user.NewRepository(user.RepositoryParams{
+				Pool:    pool,
+				Config:  database.NewConfig(nil),
+				Metrics: user.NewMetrics(),
+			})
+
+			// Every database starts empty, so the same email never conflicts
+			created := factory.User().WithEmail("ann@example.com").Create(t, repo)
+			assert.EqualValues(t, 1, created.ID)
+
+			users, err := repo.List(ctx, user.ListFilter{})
+			require.NoError(t, err)
+			assert.Len(t, users, 1)
+		})
+	}
+}
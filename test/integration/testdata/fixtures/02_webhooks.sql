@@ -0,0 +1,4 @@
+-- A webhook with one failed delivery
+INSERT INTO webhooks (id, url, secret) VALUES (1, 'https://hooks.example.com/users', 'fixture-secret');
+INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, attempt, status_code, duration_ms)
+VALUES (1, '0190c1d4-54a5-7b6e-8c1d-4f6b3e2a9e01', 'user.created', 1, 500, 42);
@@ -0,0 +1,102 @@
+package integration
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/reqlog"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/testutil/apptest"
+	"github.com/things-kit/example-db/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/fx"
+)
+
+// spanExporter collects the spans of every test in the package. The
+// repository and SQL tracers take the global provider, which can be set only
+// once, so tests tell their spans apart by trace ID.
+var spanExporter = sync.OnceValues(func() (*tracetest.InMemoryExporter, trace.TracerProvider) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(tp)
+	return exporter, tp
+})
+
+// TestTracePropagation checks that a request produces one connected trace:
+// the server span tagged with the request ID, the repository span below it
+// and the SQL spans below that
+func TestTracePropagation(t *testing.T) {
+	exporter, tp := spanExporter()
+	app := apptest.Start(t, testutil.Shared(t).NewDatabase(t), nil,
+		fx.Decorate(func(trace.TracerProvider) trace.TracerProvider { return tp }),
+	)
+
+	req, err := http.NewRequest(http.MethodPost, app.URL+"/users", strings.NewReader(`{"name":"Ann","email":"ann@example.com"}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(reqlog.Header, "trace-test-1")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var server tracetest.SpanStub
+	for _, s := range exporter.GetSpans() {
+		if s.SpanKind == trace.SpanKindServer && hasAttribute(s, tracing.RequestIDKey.String("trace-test-1")) {
+			server = s
+		}
+	}
+	require.True(t, server.SpanContext.IsValid(), "no server span tagged with the request ID")
+	assert.Equal(t, "POST /users", server.Name)
+
+	byID := map[trace.SpanID]tracetest.SpanStub{}
+	for _, s := range exporter.GetSpans() {
+		if s.SpanContext.TraceID() == server.SpanContext.TraceID() {
+			byID[s.SpanContext.SpanID()] = s
+		}
+	}
+	// descends reports whether s is below ancestor in the trace
+	descends := func(s tracetest.SpanStub, ancestor trace.SpanID) bool {
+		for s.Parent.IsValid() {
+			if s.Parent.SpanID() == ancestor {
+				return true
+			}
+			s = byID[s.Parent.SpanID()]
+		}
+		return false
+	}
+
+	var repo tracetest.SpanStub
+	for _, s := range byID {
+		if s.Name == "user.Repository.Create" {
+			repo = s
+		}
+	}
+	require.True(t, repo.SpanContext.IsValid(), "no repository span in the trace")
+	assert.True(t, descends(repo, server.SpanContext.SpanID()), "the repository span is below the server span")
+
+	sql := 0
+	for _, s := range byID {
+		if strings.Contains(s.InstrumentationScope.Name, "otelpgx") && descends(s, repo.SpanContext.SpanID()) {
+			sql++
+		}
+	}
+	assert.Positive(t, sql, "SQL spans are below the repository span")
+}
+
+func hasAttribute(s tracetest.SpanStub, kv attribute.KeyValue) bool {
+	for _, a := range s.Attributes {
+		if a.Key == kv.Key && a.Value.Emit() == kv.Value.Emit() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/database"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/testutil/factory"
+	"github.com/things-kit/example-db/internal/user"
+)
+
+// TestRolledBackTx checks that repository tests bound to a test transaction
+// leave nothing behind
+func TestRolledBackTx(t *testing.T) {
+	dsn := testutil.Shared(t).NewDatabase(t)
+
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dsn)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	repo := user.NewRepository(user.RepositoryParams{
+		Pool:    pool,
+		Config:  database.NewConfig(nil),
+		Metrics: user.NewMetrics(),
+	})
+
+	for range 2 {
+		t.Run("Isolated", func(t *testing.T) {
+			repo := repo.Bind(testutil.BeginTx(t, pool))
+
+			// WithTx joins the test transaction instead of committing
+			err := repo.WithTx(ctx, func(repo user.UserRepository) error {
+				_, err := repo.Create(ctx, factory.User().Request())
+				return err
+			})
+			require.NoError(t, err)
+
+			users, err := repo.List(ctx, user.ListFilter{})
+			require.NoError(t, err)
+			assert.Len(t, users, 1)
+		})
+	}
+
+	users, err := repo.List(ctx, user.ListFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, users)
+}
@@ -0,0 +1,60 @@
+package integration
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/things-kit/example-db/internal/events"
+	"github.com/things-kit/example-db/internal/tenant"
+	"github.com/things-kit/example-db/internal/testutil"
+	"github.com/things-kit/example-db/internal/webhook"
+
+	_ "github.com/lib/pq"
+)
+
+// TestWebhookTenants checks that a tenant only manages its own webhooks and
+// that they only receive the events of its users
+func TestWebhookTenants(t *testing.T) {
+	db, err := sql.Open("postgres", testutil.Shared(t).NewDatabase(t))
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	acme := tenant.WithTenant(ctx, "acme")
+	globex := tenant.WithTenant(ctx, "globex")
+
+	repo := webhook.NewRepository(db)
+	acmeHook, err := repo.Create(acme, "https://acme.example.com/hook", "secret")
+	require.NoError(t, err)
+	globexHook, err := repo.Create(globex, "https://globex.example.com/hook", "secret")
+	require.NoError(t, err)
+
+	listed, err := repo.List(acme, false)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, acmeHook.ID, listed[0].ID)
+
+	_, err = repo.GetByID(acme, globexHook.ID)
+	assert.Error(t, err, "another tenant's webhook is not found")
+	assert.Error(t, repo.Delete(acme, globexHook.ID))
+	_, err = repo.GetByID(globex, globexHook.ID)
+	require.NoError(t, err, "a failed delete leaves the webhook")
+
+	// Events are only queued for the webhooks of their tenant
+	d := webhook.NewDispatcher(repo, webhook.NewConfig(nil), testutil.NopLogger{})
+	evt, err := events.New(events.UserCreated, 1, nil, nil, nil)
+	require.NoError(t, err)
+	evt.TenantID = "acme"
+	require.NoError(t, d.Publish(ctx, evt))
+
+	jobs := func(webhookID int64) int {
+		var n int
+		require.NoError(t, db.QueryRow("SELECT count(*) FROM webhook_jobs WHERE webhook_id = $1", webhookID).Scan(&n))
+		return n
+	}
+	assert.Equal(t, 1, jobs(acmeHook.ID))
+	assert.Equal(t, 0, jobs(globexHook.ID))
+}
@@ -4,6 +4,7 @@
 set -e
 
 BASE_URL="http://localhost:8080"
+OPS_URL="http://localhost:9090"
 GREEN='\033[0;32m'
 BLUE='\033[0;34m'
 NC='\033[0m' # No Color
@@ -12,7 +13,7 @@ echo -e "${BLUE}=== Example-DB API Manual Test ===${NC}\n"
 
 # Health check
 echo -e "${GREEN}1. Health Check${NC}"
-curl -s $BASE_URL/health | jq .
+curl -s $OPS_URL/healthz | jq .
 echo -e "\n"
 
 # Create user 1